@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/export"
+)
+
+// handleArchive packs the repository, or one performance, into a single
+// compressed file, or restores one with --extract
+func handleArchive(args []string) {
+	archiveFlags := flag.NewFlagSet("archive", flag.ExitOnError)
+	output := archiveFlags.String("o", "", "Archive file to write (.tar.gz, .tgz or .zip)")
+	extract := archiveFlags.String("extract", "", "Restore the repository of an archive")
+	privacy := addPrivacyFlags(archiveFlags)
+
+	rest := parseInterspersed(archiveFlags, args)
+
+	if *extract != "" {
+		if len(rest) > 1 {
+			fmt.Fprintf(os.Stderr, "Usage: lcg archive --extract <file> [directory]\n")
+			os.Exit(1)
+		}
+		dir := "."
+		if len(rest) == 1 {
+			dir = rest[0]
+		}
+		handleArchiveExtract(*extract, dir)
+		return
+	}
+
+	if *output == "" || len(rest) > 1 {
+		fmt.Fprintf(os.Stderr, "Error: an output file is required\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg archive [performance] -o <file.tar.gz|file.zip>\n")
+		os.Exit(1)
+	}
+	format, err := export.ArchiveFormatFor(*output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
+	archive := &export.Archive{}
+	if len(rest) == 1 {
+		performance := selectPerformance(repo, rest[0])
+		archive.Performances = []*core.Performance{performance}
+		if archive.Commits, err = repo.PerformanceCommits(performance); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading performance commits: %v\n", err)
+			os.Exit(1)
+		}
+	} else if archive, err = export.RepositoryArchive(repo); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// An archive restores as a repository, whose commits must keep their
+	// code to match their hashes, so redacted commits are left out too
+	redaction := export.Redact(archive.Commits, privacy(repo).Strict())
+	reportOmitted(redaction.Omitted())
+	archive.Commits = redaction.Commits
+	for i, performance := range archive.Performances {
+		archive.Performances[i] = redaction.Performance(performance)
+	}
+
+	tags, err := repo.Tags()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading tags: %v\n", err)
+		os.Exit(1)
+	}
+	kept := make(map[string]bool, len(archive.Commits))
+	for _, commit := range archive.Commits {
+		kept[commit.Hash] = true
+	}
+	archive.Tags = make(map[string]string)
+	for name, hash := range tags {
+		if kept[hash] {
+			archive.Tags[name] = hash
+		}
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
+		os.Exit(1)
+	}
+	if err := export.WriteArchive(file, format, archive); err != nil {
+		file.Close()
+		os.Remove(*output)
+		fmt.Fprintf(os.Stderr, "Error writing archive: %v\n", err)
+		os.Exit(1)
+	}
+	if err := file.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing archive: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Archived %d commits, %d performances and %d tags to %s\n",
+		len(archive.Commits), len(archive.Performances), len(archive.Tags), *output)
+}
+
+// handleArchiveExtract restores an archive's repository into dir
+func handleArchiveExtract(archivePath, dir string) {
+	if err := export.ExtractArchive(archivePath, dir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	repo, err := core.LoadRepository(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading restored repository: %v\n", err)
+		os.Exit(1)
+	}
+	commits, err := repo.History()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading restored history: %v\n", err)
+		os.Exit(1)
+	}
+	performances, err := repo.ListPerformances()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing restored performances: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Restored %d commits and %d performances into %s\n", len(commits), len(performances), dir)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/replay"
+)
+
+func handleBisect(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: bisect subcommand is required\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg bisect start|good|bad|skip|status|replay|reset\n")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "start":
+		handleBisectStart(args[1:])
+	case "good", "bad", "skip":
+		handleBisectMark(args[0], args[1:])
+	case "status":
+		repo, _ := loadRepository()
+		printBisectStep(repo, bisectStep(repo.BisectStatus()), false)
+	case "replay":
+		repo, _ := loadRepository()
+		printBisectStep(repo, bisectStep(repo.BisectStatus()), true)
+	case "reset":
+		repo, _ := loadRepository()
+		if err := repo.BisectReset(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Bisect ended")
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown bisect subcommand: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// handleBisectStart begins searching for the execution that broke the sound
+func handleBisectStart(args []string) {
+	startFlags := flag.NewFlagSet("bisect start", flag.ExitOnError)
+	buffer := startFlags.String("buffer", "", "Only test commits of this buffer")
+	outDir := startFlags.String("out", "", "Write every buffer as of each candidate into files in this directory")
+	oscTarget := startFlags.String("osc", "", "Send every buffer as of each candidate to an OSC target, e.g. localhost:57120")
+	oscAddress := startFlags.String("osc-address", replay.DefaultOSCAddress, "OSC address for --osc")
+
+	refs := parseInterspersed(startFlags, args)
+	if len(refs) > 2 {
+		fmt.Fprintf(os.Stderr, "Error: too many commits\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg bisect start [<bad> [<good>]] [--buffer name] [--out dir] [--osc host:port]\n")
+		os.Exit(1)
+	}
+	var bad, good string
+	if len(refs) > 0 {
+		bad = refs[0]
+	}
+	if len(refs) > 1 {
+		good = refs[1]
+	}
+
+	options := core.Bisect{Buffer: *buffer, ReplayDir: *outDir, ReplayOSC: *oscTarget}
+	if *oscTarget != "" {
+		options.ReplayOSCAddress = *oscAddress
+	}
+
+	repo, _ := loadRepository()
+	printBisectStep(repo, bisectStep(repo.BisectStart(bad, good, options)), true)
+}
+
+// handleBisectMark records how a commit sounded, the current candidate
+// unless one is named
+func handleBisectMark(mark string, args []string) {
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "Error: too many commits\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg bisect %s [<hash|tag>]\n", mark)
+		os.Exit(1)
+	}
+	ref := ""
+	if len(args) == 1 {
+		ref = args[0]
+	}
+
+	repo, _ := loadRepository()
+
+	var step *core.BisectStep
+	var err error
+	if mark == "skip" {
+		step, err = repo.BisectSkip(ref)
+	} else {
+		step, err = repo.BisectMark(ref, mark == "good")
+	}
+	printBisectStep(repo, bisectStep(step, err), true)
+}
+
+// bisectStep exits on a failed bisect operation
+func bisectStep(step *core.BisectStep, err error) *core.BisectStep {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return step
+}
+
+// printBisectStep shows the commit to test next, or the culprit, and with
+// play sends the candidate to the bisect's replay targets
+func printBisectStep(repo *core.LiveCodeRepository, step *core.BisectStep, play bool) {
+	if step.Culprit != nil {
+		if len(step.Suspects) == 0 {
+			fmt.Printf("First bad commit:\n")
+		} else {
+			fmt.Printf("The first bad commit is one of these; skipped commits could not be told apart:\n")
+		}
+		for _, commit := range append(step.Suspects, step.Culprit) {
+			printBisectCommit(commit)
+		}
+		fmt.Printf("\nEnd the bisect with lcg bisect reset\n")
+		return
+	}
+
+	if step.Current == nil {
+		fmt.Printf("Bisecting %d commits up to %s\n", step.Remaining, colorHash(step.Bisect.Bad[:8]))
+		fmt.Printf("Mark a commit that sounded right with lcg bisect good <hash|tag>\n")
+		return
+	}
+
+	plural := "s"
+	if step.Steps == 1 {
+		plural = ""
+	}
+	fmt.Printf("Bisecting: %d commits may be the culprit (about %d test%s left)\n", step.Remaining, step.Steps, plural)
+	printBisectCommit(step.Current)
+
+	if play {
+		if err := replayBisectCandidate(repo, step); err != nil {
+			fmt.Fprintf(os.Stderr, "Error replaying candidate: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	fmt.Printf("\nListen, then run lcg bisect good, bad or skip\n")
+}
+
+// printBisectCommit shows a commit with its code
+func printBisectCommit(commit *core.Commit) {
+	fmt.Printf("\n%s %s [%s] %s\n", colorHash(commit.Hash), colorTime(commit.Timestamp.Format("15:04:05")),
+		commit.Metadata.Buffer, commit.Message)
+	for _, line := range strings.Split(strings.TrimRight(commit.Content, "\n"), "\n") {
+		fmt.Printf("    %s\n", colorDim(line))
+	}
+}
+
+// replayBisectCandidate sends every buffer as it was once the candidate was
+// executed, so the candidate is heard in context
+func replayBisectCandidate(repo *core.LiveCodeRepository, step *core.BisectStep) error {
+	state := step.Bisect
+	if state.ReplayDir == "" && state.ReplayOSC == "" {
+		return nil
+	}
+
+	var sinks []replay.Sink
+	if state.ReplayDir != "" {
+		sink, err := replay.FileSink(state.ReplayDir)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, sink)
+	}
+	if state.ReplayOSC != "" {
+		oscSink, err := replay.NewOSCSink(state.ReplayOSC, state.ReplayOSCAddress)
+		if err != nil {
+			return err
+		}
+		defer oscSink.Close()
+		sinks = append(sinks, oscSink.Send)
+	}
+
+	buffers, err := repo.BuffersAt(step.Current.Hash)
+	if err != nil {
+		return err
+	}
+	for i, commit := range buffers {
+		event := replay.Event{Commit: commit, Index: i, Total: len(buffers)}
+		for _, sink := range sinks {
+			if err := sink(event); err != nil {
+				return err
+			}
+		}
+	}
+
+	targets := []string{}
+	if state.ReplayDir != "" {
+		targets = append(targets, state.ReplayDir)
+	}
+	if state.ReplayOSC != "" {
+		targets = append(targets, state.ReplayOSC)
+	}
+	noun := "buffers"
+	if len(buffers) == 1 {
+		noun = "buffer"
+	}
+	fmt.Printf("\nReplayed %d %s to %s\n", len(buffers), noun, strings.Join(targets, " and "))
+	return nil
+}
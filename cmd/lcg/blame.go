@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// handleBlame shows which commit introduced each line of a buffer
+func handleBlame(args []string) {
+	blameFlags := flag.NewFlagSet("blame", flag.ExitOnError)
+	buffer := blameFlags.String("buffer", "", "Buffer to blame")
+	jsonOutput := blameFlags.Bool("json", false, "Print the lines as a JSON array")
+
+	rest := parseInterspersed(blameFlags, args)
+	if *buffer == "" && len(rest) == 1 {
+		*buffer = rest[0]
+	} else if *buffer == "" || len(rest) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: one buffer is required\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg blame --buffer <name> [--json]\n")
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
+	lines, err := repo.Blame(*buffer)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOutput {
+		type blameLine struct {
+			Line      int       `json:"line"`
+			Text      string    `json:"text"`
+			Hash      string    `json:"hash"`
+			Author    string    `json:"author"`
+			Timestamp time.Time `json:"timestamp"`
+			Message   string    `json:"message"`
+		}
+		out := make([]blameLine, len(lines))
+		for i, line := range lines {
+			out[i] = blameLine{
+				Line:      line.Number,
+				Text:      line.Text,
+				Hash:      line.Commit.Hash,
+				Author:    line.Commit.Author,
+				Timestamp: line.Commit.Timestamp,
+				Message:   line.Commit.Message,
+			}
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding blame: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	authorWidth, numberWidth := 0, len(fmt.Sprint(len(lines)))
+	for _, line := range lines {
+		authorWidth = max(authorWidth, len(line.Commit.Author))
+	}
+
+	// Consecutive lines from the same commit only name it once
+	previous := ""
+	for _, line := range lines {
+		origin := fmt.Sprintf("%s %s %s", colorHash(line.Commit.Hash[:8]), padRight(line.Commit.Author, authorWidth),
+			colorTime(line.Commit.Timestamp.Format("15:04:05")))
+		if line.Commit.Hash == previous {
+			origin = strings.Repeat(" ", 8+1+authorWidth+1+8)
+		}
+		previous = line.Commit.Hash
+		fmt.Printf("%s %*d | %s\n", origin, numberWidth, line.Number, line.Text)
+	}
+}
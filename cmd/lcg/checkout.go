@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/livecodegit/pkg/watchers/sonicpi"
+)
+
+func handleCheckout(args []string) {
+	checkoutFlags := flag.NewFlagSet("checkout", flag.ExitOnError)
+	dir := checkoutFlags.String("dir", "", "Directory to write the buffer file to (default: the repository)")
+	run := checkoutFlags.Bool("run", false, "Also run the code in the running Sonic Pi")
+	sonicPi := sonicPiFlags(checkoutFlags)
+
+	refs := parseInterspersed(checkoutFlags, args)
+	if len(refs) != 1 {
+		fmt.Fprintf(os.Stderr, "Error: a commit hash or tag is required\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg checkout <hash|tag> [--dir dir] [--run]\n")
+		os.Exit(1)
+	}
+
+	repo, path := loadRepository()
+
+	commit, err := repo.ResolveCommit(refs[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *run && !strings.EqualFold(commit.Metadata.Language, "sonicpi") {
+		fmt.Fprintf(os.Stderr, "Error: --run needs a Sonic Pi commit, %s is %s\n", commit.Hash[:8], commit.Metadata.Language)
+		os.Exit(1)
+	}
+
+	if *dir == "" {
+		*dir = path
+	}
+	written, err := repo.Checkout(commit, *dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Checked out %s to %s\n", commit.Hash[:8], written)
+
+	if *run {
+		client := sonicPi.connect()
+		defer client.Close()
+		if err := client.RunCode(commit.Content); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running in Sonic Pi: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Sent %s to Sonic Pi\n", commit.Hash[:8])
+	}
+}
+
+// sonicPiOptions are the flags locating the Sonic Pi server code is run in
+type sonicPiOptions struct {
+	flags *flag.FlagSet
+	port  *int
+	token *int
+}
+
+// sonicPiFlags adds the flags locating the Sonic Pi server
+func sonicPiFlags(flags *flag.FlagSet) sonicPiOptions {
+	return sonicPiOptions{
+		flags: flags,
+		port:  flags.Int("sonicpi-port", sonicpi.DefaultAPIPort, "Port of the Sonic Pi server (default: from Sonic Pi's log)"),
+		token: flags.Int("sonicpi-token", 0, "Token of the Sonic Pi server (default: from Sonic Pi's log)"),
+	}
+}
+
+// connect reaches the Sonic Pi server, with the port and token Sonic Pi
+// logged unless given
+func (o sonicPiOptions) connect() *sonicpi.Client {
+	config := sonicpi.DiscoverAPI()
+	if flagWasSet(o.flags, "sonicpi-port") {
+		config.Port = *o.port
+	}
+	if flagWasSet(o.flags, "sonicpi-token") {
+		config.Token = int32(*o.token)
+	}
+
+	client, err := sonicpi.NewClient(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return client
+}
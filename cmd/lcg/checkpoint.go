@@ -0,0 +1,197 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/replay"
+)
+
+func handleCheckpoint(args []string) {
+	subcommand := "list"
+	if len(args) > 0 {
+		subcommand = args[0]
+		args = args[1:]
+	}
+
+	switch subcommand {
+	case "save":
+		handleCheckpointSave(args)
+	case "restore":
+		handleCheckpointRestore(args)
+	case "list":
+		handleCheckpointList(args)
+	case "remove":
+		handleCheckpointRemove(args)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown checkpoint command: %s\n", subcommand)
+		fmt.Fprintf(os.Stderr, "Usage: lcg checkpoint [save|restore|list|remove] [options]\n")
+		os.Exit(1)
+	}
+}
+
+func handleCheckpointSave(args []string) {
+	saveFlags := flag.NewFlagSet("checkpoint save", flag.ExitOnError)
+	saveFlags.Parse(args)
+
+	if saveFlags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Error: a checkpoint name is required (quote names containing spaces)\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg checkpoint save <name>\n")
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
+	checkpoint, err := repo.SaveCheckpoint(saveFlags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving checkpoint: %v\n", err)
+		os.Exit(1)
+	}
+
+	noun := "buffers"
+	if len(checkpoint.Buffers) == 1 {
+		noun = "buffer"
+	}
+	fmt.Printf("Saved checkpoint %s (%d %s)\n", checkpoint.Name, len(checkpoint.Buffers), noun)
+}
+
+// handleCheckpointRestore pushes every buffer of a checkpoint back to the
+// live environment: into the buffer files an editor follows, and optionally
+// to an OSC target that re-evaluates the code
+func handleCheckpointRestore(args []string) {
+	restoreFlags := flag.NewFlagSet("checkpoint restore", flag.ExitOnError)
+	outDir := restoreFlags.String("out", "", "Write the buffers into files in this directory (default: the repository, unless --osc is given)")
+	oscTarget := restoreFlags.String("osc", "", "Send the buffers to an OSC target, e.g. localhost:57120")
+	oscAddress := restoreFlags.String("osc-address", replay.DefaultOSCAddress, "OSC address for --osc")
+
+	names := parseInterspersed(restoreFlags, args)
+	if len(names) > 1 {
+		fmt.Fprintf(os.Stderr, "Usage: lcg checkpoint restore [name] [--out dir] [--osc host:port]\n")
+		os.Exit(1)
+	}
+	var name string
+	if len(names) == 1 {
+		name = names[0]
+	}
+
+	repo, path := loadRepository()
+
+	checkpoint, err := repo.GetCheckpoint(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	commits, err := repo.CheckpointCommits(checkpoint)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading checkpoint: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *outDir == "" && *oscTarget == "" {
+		*outDir = path
+	}
+	targets := restoreCommits(commits, *outDir, *oscTarget, *oscAddress)
+
+	noun := "buffers"
+	if len(commits) == 1 {
+		noun = "buffer"
+	}
+	fmt.Printf("Restored checkpoint %s: %d %s to %s\n", checkpoint.Name, len(commits), noun, strings.Join(targets, " and "))
+}
+
+// restoreCommits writes each commit into its buffer file in outDir and sends
+// it to an OSC target, when given, and returns the targets written to
+func restoreCommits(commits []*core.Commit, outDir, oscTarget, oscAddress string) []string {
+	var sinks []replay.Sink
+	var targets []string
+	if outDir != "" {
+		sink, err := replay.FileSink(outDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		sinks = append(sinks, sink)
+		targets = append(targets, outDir)
+	}
+	if oscTarget != "" {
+		oscSink, err := replay.NewOSCSink(oscTarget, oscAddress)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer oscSink.Close()
+		sinks = append(sinks, oscSink.Send)
+		targets = append(targets, oscTarget)
+	}
+
+	for i, commit := range commits {
+		event := replay.Event{Commit: commit, Index: i, Total: len(commits)}
+		for _, sink := range sinks {
+			if err := sink(event); err != nil {
+				fmt.Fprintf(os.Stderr, "Error restoring %s: %v\n", commit.Metadata.Buffer, err)
+				os.Exit(1)
+			}
+		}
+	}
+	return targets
+}
+
+func handleCheckpointList(args []string) {
+	listFlags := flag.NewFlagSet("checkpoint list", flag.ExitOnError)
+	listFlags.Parse(args)
+
+	repo, _ := loadRepository()
+
+	checkpoints, err := repo.Checkpoints()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading checkpoints: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(checkpoints) == 0 {
+		fmt.Println("No checkpoints (save one with 'lcg checkpoint save')")
+		return
+	}
+
+	nameWidth := 0
+	for _, checkpoint := range checkpoints {
+		nameWidth = max(nameWidth, len(checkpoint.Name))
+	}
+
+	for _, checkpoint := range checkpoints {
+		buffers := make([]string, 0, len(checkpoint.Buffers))
+		for buffer := range checkpoint.Buffers {
+			buffers = append(buffers, buffer)
+		}
+		sort.Strings(buffers)
+
+		fmt.Printf("%s %s %s  %s\n",
+			padRight(checkpoint.Name, nameWidth),
+			colorTime(checkpoint.Time.Format("2006-01-02 15:04:05")),
+			colorHash(checkpoint.Commit[:8]),
+			strings.Join(buffers, ", "))
+	}
+}
+
+func handleCheckpointRemove(args []string) {
+	removeFlags := flag.NewFlagSet("checkpoint remove", flag.ExitOnError)
+	removeFlags.Parse(args)
+
+	if removeFlags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: lcg checkpoint remove <name>\n")
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
+	if err := repo.RemoveCheckpoint(removeFlags.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Removed checkpoint %s\n", removeFlags.Arg(0))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+func handleConfig(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: config command is required\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg config <set|get|unset|list> [key] [value]\n")
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
+	config, err := repo.Config()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "set":
+		if len(args) != 3 {
+			fmt.Fprintf(os.Stderr, "Usage: lcg config set <key> <value>\n")
+			os.Exit(1)
+		}
+		updateConfig(config, config.Set(args[1], args[2]))
+	case "unset":
+		if len(args) != 2 {
+			fmt.Fprintf(os.Stderr, "Usage: lcg config unset <key>\n")
+			os.Exit(1)
+		}
+		updateConfig(config, config.Unset(args[1]))
+	case "get":
+		if len(args) != 2 {
+			fmt.Fprintf(os.Stderr, "Usage: lcg config get <key>\n")
+			os.Exit(1)
+		}
+		value, err := config.Get(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		// Like git, an unset key prints nothing and fails
+		if value == "" {
+			os.Exit(1)
+		}
+		fmt.Println(value)
+	case "list":
+		settings := config.List()
+		keys := make([]string, 0, len(settings))
+		for key := range settings {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Printf("%s=%s\n", key, settings[key])
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown config command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// updateConfig saves config after a successful change
+func updateConfig(config core.ConfigInterface, err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := config.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/livecodegit/pkg/daemon"
+	"github.com/livecodegit/pkg/journal"
+	"github.com/livecodegit/pkg/watchers"
+)
+
+// daemonLogFile receives the output of a daemon, next to the journal
+const daemonLogFile = "daemon.log"
+
+// detachTimeout is how long a process started in the background gets to come
+// up, and to stop
+const detachTimeout = 10 * time.Second
+
+func handleDaemon(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: daemon subcommand is required\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg daemon start|stop|status|run\n")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "start":
+		handleDaemonStart(args[1:])
+	case "stop":
+		handleDaemonStop(args[1:])
+	case "status":
+		handleDaemonStatus(args[1:])
+	case "run":
+		handleDaemonRun(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown daemon subcommand: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// handleDaemonStart runs 'lcg daemon run' in the background and waits for
+// its control socket to answer
+func handleDaemonStart(args []string) {
+	startFlags := flag.NewFlagSet("daemon start", flag.ExitOnError)
+	configPath := startFlags.String("config", "", "Path to watcher configuration file")
+	controlPort := startFlags.Int("control", 0, "Accept OSC control messages on this UDP port (default: control_port from config)")
+	startFlags.Parse(args)
+
+	_, path := loadRepository()
+	if daemon.Running(path) {
+		fmt.Fprintf(os.Stderr, "Error: a daemon is already running for %s\n", path)
+		os.Exit(1)
+	}
+
+	runArgs := []string{"daemon", "run"}
+	if *configPath != "" {
+		absPath, err := filepath.Abs(*configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error resolving path %s: %v\n", *configPath, err)
+			os.Exit(1)
+		}
+		runArgs = append(runArgs, "--config", absPath)
+	}
+	if flagWasSet(startFlags, "control") {
+		runArgs = append(runArgs, "--control", strconv.Itoa(*controlPort))
+	}
+
+	cmd, logPath, err := spawnDetached(path, runArgs, daemonLogFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting daemon: %v\n", err)
+		os.Exit(1)
+	}
+	if err := waitForDetached(cmd, detachTimeout, func() bool { return daemon.Running(path) }); err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting daemon: %v\n", err)
+		fmt.Fprintf(os.Stderr, "See %s for details\n", logPath)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Daemon started (pid %d)\n", cmd.Process.Pid)
+	fmt.Printf("Control socket: %s\n", daemon.SocketPath(path))
+	fmt.Printf("Output: %s\n", logPath)
+}
+
+// spawnDetached starts lcg with args in the background, appending its output
+// to the named file of the logs directory of the repository at path
+func spawnDetached(path string, args []string, logName string) (*exec.Cmd, string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to find the lcg executable: %w", err)
+	}
+
+	logDir := journal.Dir(path)
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return nil, "", fmt.Errorf("failed to create logs directory: %w", err)
+	}
+	logPath := filepath.Join(logDir, logName)
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open %s: %w", logPath, err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(executable, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = detachedProcess()
+	if err := cmd.Start(); err != nil {
+		return nil, "", err
+	}
+	return cmd, logPath, nil
+}
+
+// waitForDetached waits until ready reports that the process started as cmd
+// is up, failing if it exits first
+func waitForDetached(cmd *exec.Cmd, timeout time.Duration, ready func() bool) error {
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	deadline := time.After(timeout)
+	for {
+		select {
+		case err := <-exited:
+			if err == nil {
+				return fmt.Errorf("the process exited")
+			}
+			return fmt.Errorf("the process exited: %v", err)
+		case <-deadline:
+			return fmt.Errorf("the process did not start within %s", timeout)
+		case <-time.After(100 * time.Millisecond):
+			if ready() {
+				return nil
+			}
+		}
+	}
+}
+
+// handleDaemonRun runs the watcher service in the foreground, controlled
+// through the repository's socket; 'lcg daemon start' runs it detached
+func handleDaemonRun(args []string) {
+	runFlags := flag.NewFlagSet("daemon run", flag.ExitOnError)
+	configPath := runFlags.String("config", "", "Path to watcher configuration file")
+	controlPort := runFlags.Int("control", 0, "Accept OSC control messages on this UDP port (default: control_port from config)")
+	runFlags.Parse(args)
+
+	repo, path := loadRepository()
+	if *configPath == "" {
+		*configPath = watchers.ResolveConfigPath(path)
+	}
+
+	multi := watchers.NewMultiRepoService()
+	service, err := multi.AddRepository(path, repo, *configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing watcher service: %v\n", err)
+		os.Exit(1)
+	}
+	if flagWasSet(runFlags, "control") {
+		if *controlPort < 0 || *controlPort > 65535 {
+			fmt.Fprintf(os.Stderr, "Error: invalid control port %d\n", *controlPort)
+			os.Exit(1)
+		}
+		service.SetControlPort(*controlPort)
+	}
+	if len(service.GetEnabledWatchers()) == 0 && service.ControlPort() == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no watchers are enabled\n")
+		fmt.Fprintf(os.Stderr, "Enable a watcher first: lcg watch --enable <watcher-name>\n")
+		os.Exit(1)
+	}
+
+	// Listening first makes a second daemon fail before its watchers clash
+	// over ports with the first
+	stop := make(chan struct{}, 1)
+	socketPath := daemon.SocketPath(path)
+	server, err := daemon.Listen(path, func(request daemon.Request) daemon.Response {
+		switch request.Command {
+		case daemon.CommandPing:
+			return daemon.Response{OK: true}
+		case daemon.CommandStatus:
+			return daemon.Response{OK: true, Status: &daemon.Status{
+				Repository: path,
+				Socket:     socketPath,
+				Service:    service.GetState(),
+			}}
+		case daemon.CommandFlush:
+			return daemon.Response{OK: true, Flushed: service.Flush()}
+		case daemon.CommandStop:
+			select {
+			case stop <- struct{}{}:
+			default:
+			}
+			return daemon.Response{OK: true}
+		}
+		return daemon.Response{Error: fmt.Sprintf("unknown command %q", request.Command)}
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = multi.Start()
+	printStartResults(multi, err != nil)
+	if err != nil {
+		server.Close()
+		var startErr *watchers.StartError
+		if errors.As(err, &startErr) {
+			fmt.Fprintf(os.Stderr, "Error starting watcher service: nothing was started\n")
+		} else {
+			fmt.Fprintf(os.Stderr, "Error starting watcher service: %v\n", err)
+		}
+		os.Exit(1)
+	}
+	go server.Serve()
+
+	writeServiceStates(multi)
+	fmt.Printf("Daemon (pid %d) listening on %s\n", os.Getpid(), socketPath)
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	stateTicker := time.NewTicker(5 * time.Second)
+	defer stateTicker.Stop()
+
+	for running := true; running; {
+		select {
+		case <-sigChan:
+			running = false
+		case <-stop:
+			running = false
+		case <-stateTicker.C:
+			writeServiceStates(multi)
+		}
+	}
+
+	fmt.Printf("Daemon stopping\n")
+	if err := multi.Stop(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error stopping service: %v\n", err)
+	}
+	server.Close()
+	for _, r := range multi.Repositories() {
+		watchers.RemoveServiceState(watchers.GetStatePath(r.Path))
+	}
+}
+
+// handleDaemonStop asks the daemon to stop and waits until it has
+func handleDaemonStop(args []string) {
+	stopFlags := flag.NewFlagSet("daemon stop", flag.ExitOnError)
+	stopFlags.Parse(args)
+
+	_, path := loadRepository()
+
+	if _, err := daemon.Call(path, daemon.Request{Command: daemon.CommandStop}, daemon.DefaultTimeout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The socket answers until the watchers have stopped
+	deadline := time.Now().Add(detachTimeout)
+	for daemon.Running(path) {
+		if time.Now().After(deadline) {
+			fmt.Fprintf(os.Stderr, "Error: the daemon is still running after %s\n", detachTimeout)
+			os.Exit(1)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	fmt.Println("Daemon stopped")
+}
+
+// handleFlush asks the daemon to commit the executions waiting in its batch
+func handleFlush(args []string) {
+	flushFlags := flag.NewFlagSet("flush", flag.ExitOnError)
+	flushFlags.Parse(args)
+
+	_, path := loadRepository()
+
+	response, err := daemon.Call(path, daemon.Request{Command: daemon.CommandFlush}, daemon.DefaultTimeout)
+	if errors.Is(err, daemon.ErrNotRunning) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "A foreground 'lcg watch' flushes on %s sent to its control port\n", watchers.ControlFlush)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Committed the batch of %d buffers\n", response.Flushed)
+}
+
+// handleDaemonStatus reports on the daemon through its socket; it exits with
+// status 1 when no daemon is running, for scripts
+func handleDaemonStatus(args []string) {
+	statusFlags := flag.NewFlagSet("daemon status", flag.ExitOnError)
+	jsonOutput := statusFlags.Bool("json", false, "Output the status as JSON")
+	statusFlags.Parse(args)
+
+	_, path := loadRepository()
+
+	response, err := daemon.Call(path, daemon.Request{Command: daemon.CommandStatus}, daemon.DefaultTimeout)
+	if errors.Is(err, daemon.ErrNotRunning) {
+		fmt.Printf("Daemon: %s\n", colorDim("not running"))
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	status := response.Status
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(status); err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding status: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	state := status.Service
+	fmt.Printf("Daemon: %s (pid %d, up %s)\n", colorResult(true, "running"), state.PID, formatElapsed(time.Since(state.StartedAt)))
+	fmt.Printf("  Repository: %s\n", status.Repository)
+	fmt.Printf("  Socket: %s\n", status.Socket)
+	if len(state.Watchers) > 0 {
+		fmt.Printf("  Watchers: %s\n", paint(ansiGreen, strings.Join(state.Watchers, ", ")))
+	}
+	fmt.Printf("  Executions: %d\n", state.Stats.TotalExecutions)
+	fmt.Printf("  Commits: %d\n", state.Stats.TotalCommits)
+	fmt.Printf("  Pending Events: %d\n", state.Stats.PendingEvents)
+	if state.Stats.QueuedEvents > 0 {
+		fmt.Printf("  Queued for Retry: %d\n", state.Stats.QueuedEvents)
+	}
+	if state.Stats.Latency.Samples > 0 {
+		fmt.Printf("  Commit Latency: %s\n", formatLatency(state.Stats.Latency))
+	}
+	if state.ControlPort > 0 {
+		fmt.Printf("  OSC Control: UDP port %d\n", state.ControlPort)
+	}
+	if !state.Stats.LastExecution.IsZero() {
+		fmt.Printf("  Last Execution: %s\n", colorTime(state.Stats.LastExecution.Format("2006-01-02 15:04:05")))
+	}
+}
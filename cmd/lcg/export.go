@@ -0,0 +1,437 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/export"
+)
+
+func handleExport(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: export format is required\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg export <json|csv|parquet|git|html|markdown|bundle|completions> [options]\n")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "json":
+		handleExportJSON(args[1:])
+	case "csv", "parquet":
+		handleExportTable(args[0], args[1:])
+	case "git":
+		handleExportGit(args[1:])
+	case "completions":
+		handleExportCompletions(args[1:])
+	case "html", "markdown", "md", "bundle":
+		handleExportPerformance(args[0], args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown export format: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+func handleExportJSON(args []string) {
+	jsonFlags := flag.NewFlagSet("export json", flag.ExitOnError)
+	output := jsonFlags.String("o", "", "Write the export to a file instead of stdout")
+	schema := jsonFlags.Bool("schema", false, "Print the JSON Schema describing the export format")
+	anonymizer := addAnonymizeFlags(jsonFlags)
+	privacy := addPrivacyFlags(jsonFlags)
+
+	jsonFlags.Parse(args)
+
+	if *schema {
+		os.Stdout.Write(export.Schema())
+		return
+	}
+
+	repo, _ := loadRepository()
+
+	dump, err := export.BuildJSON(repo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error building export: %v\n", err)
+		os.Exit(1)
+	}
+	total := len(dump.Commits)
+	dump.Redact(privacy(repo))
+	reportOmitted(total - len(dump.Commits))
+	if a := anonymizer(); a != nil {
+		dump.Anonymize(a)
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if err := export.WriteJSON(out, dump); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing export: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *output != "" {
+		fmt.Printf("Exported %d commits and %d performances to %s (schema %s)\n",
+			len(dump.Commits), len(dump.Performances), *output, export.SchemaVersion)
+	}
+}
+
+// handleExportTable exports one row per commit for data analysis
+func handleExportTable(format string, args []string) {
+	tableFlags := flag.NewFlagSet("export "+format, flag.ExitOnError)
+	output := tableFlags.String("o", "", "Write the export to a file instead of stdout")
+	anonymizer := addAnonymizeFlags(tableFlags)
+	privacy := addPrivacyFlags(tableFlags)
+
+	tableFlags.Parse(args)
+
+	if format == "parquet" && *output == "" && isTerminal(os.Stdout) {
+		fmt.Fprintf(os.Stderr, "Error: Parquet is binary; write it to a file with -o\n")
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
+	commits, err := repo.History()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+		os.Exit(1)
+	}
+	redaction := export.Redact(commits, privacy(repo))
+	commits = redaction.Commits
+	reportOmitted(redaction.Omitted())
+	if a := anonymizer(); a != nil {
+		commits = a.Commits(commits)
+	}
+	rows := export.BuildRows(commits)
+
+	out := os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if format == "csv" {
+		err = export.WriteCSV(out, rows)
+	} else {
+		err = export.WriteParquet(out, rows)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing export: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *output != "" {
+		fmt.Printf("Exported %d commits to %s\n", len(rows), *output)
+	}
+}
+
+// handleExportGit converts the history into a Git repository
+func handleExportGit(args []string) {
+	gitFlags := flag.NewFlagSet("export git", flag.ExitOnError)
+	branch := gitFlags.String("branch", "main", "Branch to create in the Git repository")
+	anonymizer := addAnonymizeFlags(gitFlags)
+	privacy := addPrivacyFlags(gitFlags)
+
+	dirs := parseInterspersed(gitFlags, args)
+	if len(dirs) != 1 {
+		fmt.Fprintf(os.Stderr, "Error: a target directory is required\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg export git <dir> [--branch name]\n")
+		os.Exit(1)
+	}
+	dir := dirs[0]
+
+	if _, err := exec.LookPath("git"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: git is required to export a Git repository\n")
+		os.Exit(1)
+	}
+
+	// Never mix an export into existing files
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: %s is not empty\n", dir)
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
+	commits, err := repo.History()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+		os.Exit(1)
+	}
+	redaction := export.Redact(commits, privacy(repo))
+	commits = redaction.Commits
+	reportOmitted(redaction.Omitted())
+	if a := anonymizer(); a != nil {
+		commits = a.Commits(commits)
+	}
+	tags, err := repo.Tags()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading tags: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", dir, err)
+		os.Exit(1)
+	}
+
+	for _, gitArgs := range [][]string{
+		{"init", "-q"},
+		{"symbolic-ref", "HEAD", "refs/heads/" + *branch},
+	} {
+		if err := runGit(dir, nil, gitArgs...); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating Git repository: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var stream bytes.Buffer
+	if err := export.WriteGitFastImport(&stream, commits, tags, *branch); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing export: %v\n", err)
+		os.Exit(1)
+	}
+	if err := runGit(dir, &stream, "fast-import", "--quiet"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error importing into Git: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Check the buffers out so the repository is ready to publish
+	if len(commits) > 0 {
+		if err := runGit(dir, nil, "reset", "--hard", "-q"); err != nil {
+			fmt.Fprintf(os.Stderr, "Error checking out %s: %v\n", *branch, err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("Exported %d commits to Git repository %s (branch %s)\n", len(commits), dir, *branch)
+}
+
+// runGit runs a git command in dir, including its output in any error
+func runGit(dir string, stdin io.Reader, args ...string) error {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdin = stdin
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// handleExportPerformance writes one performance as a timeline document for
+// a blog post, or as a compact bundle for offline review
+func handleExportPerformance(format string, args []string) {
+	performanceFlags := flag.NewFlagSet("export "+format, flag.ExitOnError)
+	output := performanceFlags.String("o", "", "Write the export to a file instead of stdout")
+	anonymizer := addAnonymizeFlags(performanceFlags)
+	privacy := addPrivacyFlags(performanceFlags)
+
+	refs := parseInterspersed(performanceFlags, args)
+	if len(refs) > 1 {
+		fmt.Fprintf(os.Stderr, "Usage: lcg export %s [performance] [-o file]\n", format)
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
+	// Without an argument, export the active performance or else the latest
+	var performance *core.Performance
+	if len(refs) == 1 {
+		performance = selectPerformance(repo, refs[0])
+	} else if performance, _ = repo.GetCurrentPerformance(); performance == nil {
+		performances, err := repo.ListPerformances()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing performances: %v\n", err)
+			os.Exit(1)
+		}
+		if len(performances) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: no performances recorded (start one with 'lcg performance start')\n")
+			os.Exit(1)
+		}
+		performance = performances[len(performances)-1]
+	}
+
+	commits, err := repo.PerformanceCommits(performance)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading performance commits: %v\n", err)
+		os.Exit(1)
+	}
+	redaction := export.Redact(commits, privacy(repo))
+	performance, commits = redaction.Performance(performance), redaction.Commits
+	reportOmitted(redaction.Omitted())
+	if a := anonymizer(); a != nil {
+		performance = a.Performance(performance)
+		commits = a.Commits(commits)
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	switch format {
+	case "bundle":
+		err = export.WriteBundle(out, export.BuildBundle(performance, commits))
+	case "html":
+		err = export.WriteHTML(out, export.BuildTimeline(performance, commits))
+	default:
+		err = export.WriteMarkdown(out, export.BuildTimeline(performance, commits))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing export: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *output != "" {
+		fmt.Printf("Exported %s (%d commits) to %s\n", performance.Name, len(commits), *output)
+	}
+}
+
+// handleExportCompletions ranks past vocabulary for editor autocompletion
+func handleExportCompletions(args []string) {
+	completionFlags := flag.NewFlagSet("export completions", flag.ExitOnError)
+	output := completionFlags.String("o", "", "Write the export to a file instead of stdout")
+	format := completionFlags.String("format", "json", "Output format: json, vscode (snippets) or words (dictionary)")
+	language := completionFlags.String("lang", "", "Only one language")
+	author := completionFlags.String("author", "", "Only code written by one author")
+	minCount := completionFlags.Int("min-count", 2, "Leave out what was written fewer times")
+	limit := completionFlags.Int("limit", 500, "Most candidates per language (0 for all)")
+	privacy := addPrivacyFlags(completionFlags)
+
+	completionFlags.Parse(args)
+
+	var write func(io.Writer, *export.CompletionSet) error
+	switch *format {
+	case "json":
+		write = export.WriteCompletionsJSON
+	case "vscode":
+		write = export.WriteCompletionsVSCode
+	case "words":
+		write = export.WriteCompletionsWords
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown completions format %s (json, vscode or words)\n", *format)
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
+	commits, err := repo.History()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+		os.Exit(1)
+	}
+	commits = export.Redact(commits, privacy(repo)).Commits
+	if *author != "" {
+		mine := commits[:0]
+		for _, commit := range commits {
+			if commit.Author == *author {
+				mine = append(mine, commit)
+			}
+		}
+		commits = mine
+	}
+
+	set := export.BuildCompletions(commits, export.CompletionOptions{
+		Language: strings.ToLower(*language),
+		MinCount: *minCount,
+		Limit:    *limit,
+	})
+
+	out := os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if err := write(out, set); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing export: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *output != "" {
+		total := 0
+		for _, completions := range set.Languages {
+			total += len(completions)
+		}
+		fmt.Printf("Exported %d completions in %d languages to %s\n", total, len(set.Languages), *output)
+	}
+}
+
+// addAnonymizeFlags registers the anonymization flags shared by all export
+// formats. The returned function gives the configured anonymizer after
+// parsing, or nil when no anonymization was asked for.
+func addAnonymizeFlags(flags *flag.FlagSet) func() *export.Anonymizer {
+	anonymize := flags.Bool("anonymize", false, "Replace authors with pseudonyms and strip hostnames, user names and file paths")
+	hashContent := flags.Bool("hash-content", false, "Replace each line of code with a salted hash, keeping its structure")
+
+	return func() *export.Anonymizer {
+		if !*anonymize && !*hashContent {
+			return nil
+		}
+
+		anonymizer, err := export.NewAnonymizer(export.AnonymizeOptions{
+			Identity:    *anonymize,
+			Paths:       *anonymize,
+			HashContent: *hashContent,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error preparing anonymization: %v\n", err)
+			os.Exit(1)
+		}
+		return anonymizer
+	}
+}
+
+// addPrivacyFlags registers --include-private, shared by all export formats.
+// The returned function gives the privacy rules to apply after parsing: the
+// repository's, or none when private commits are to be included.
+func addPrivacyFlags(flags *flag.FlagSet) func(repo *core.LiveCodeRepository) *core.PrivacyRules {
+	includePrivate := flags.Bool("include-private", false, "Export private and redacted commits as recorded")
+
+	return func(repo *core.LiveCodeRepository) *core.PrivacyRules {
+		if *includePrivate {
+			return &core.PrivacyRules{}
+		}
+
+		rules, err := repo.Privacy()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading privacy rules: %v\n", err)
+			os.Exit(1)
+		}
+		return rules
+	}
+}
+
+// reportOmitted tells, on stderr so exports can be piped, how many private
+// commits an export left out
+func reportOmitted(omitted int) {
+	if omitted > 0 {
+		fmt.Fprintf(os.Stderr, "Left out %d private commits (use --include-private to export them)\n", omitted)
+	}
+}
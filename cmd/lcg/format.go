@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/livecodegit/pkg/storage"
+	"github.com/livecodegit/pkg/watchers"
+)
+
+// handleFormat shows how the repository stores its objects, and changes the
+// compression of new objects and whether their content goes in blobs
+func handleFormat(args []string) {
+	formatFlags := flag.NewFlagSet("format", flag.ExitOnError)
+	compression := formatFlags.String("compression", "", "Compress new objects: gzip or none")
+	blobs := formatFlags.String("blobs", "", "Store the content of new commits in shared blobs: on or off")
+	formatFlags.Parse(args)
+
+	repo, _ := loadRepository()
+
+	if *compression != "" {
+		if err := repo.SetCompression(*compression); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *blobs != "" {
+		if *blobs != "on" && *blobs != "off" {
+			fmt.Fprintf(os.Stderr, "Error: --blobs must be on or off\n")
+			os.Exit(1)
+		}
+		if err := repo.SetBlobs(*blobs == "on"); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	format, err := repo.Format()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading format: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Version:     %d\n", format.Version)
+	fmt.Printf("Backend:     %s\n", format.Backend)
+	fmt.Printf("Compression: %s\n", format.Compression)
+	if format.Blobs {
+		fmt.Println("Blobs:       on")
+	} else {
+		fmt.Println("Blobs:       off")
+	}
+	if format.S3 != nil {
+		pending, err := repo.S3Pending()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading pending uploads: %v\n", err)
+			os.Exit(1)
+		}
+		location := "s3://" + format.S3.Bucket
+		if format.S3.Prefix != "" {
+			location += "/" + strings.Trim(format.S3.Prefix, "/")
+		}
+		fmt.Printf("S3:          %s (%d uploads pending)\n", location, len(pending))
+	}
+	switch {
+	case format.Encryption == nil:
+		fmt.Println("Encryption:  off")
+	case format.Encryption.KeyFile != "":
+		fmt.Printf("Encryption:  %s, key file %s\n", format.Encryption.Cipher, format.Encryption.KeyFile)
+	default:
+		fmt.Printf("Encryption:  %s, passphrase\n", format.Encryption.Cipher)
+	}
+}
+
+// handleEncrypt encrypts the repository's objects at rest, with a key file
+// or a passphrase
+func handleEncrypt(args []string) {
+	encryptFlags := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	keyFile := encryptFlags.String("key-file", "", "Encrypt with the key in this file, generated if missing (default: a passphrase from "+storage.PassphraseEnv+")")
+	encryptFlags.Parse(args)
+
+	repo, _ := loadRepository()
+
+	format, err := repo.Format()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading format: %v\n", err)
+		os.Exit(1)
+	}
+	if *keyFile != "" && format.Encryption == nil {
+		if _, err := os.Stat(*keyFile); os.IsNotExist(err) {
+			if err := storage.GenerateKeyFile(*keyFile); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Generated a new key in %s; keep a copy somewhere safe, the repository can't be read without it\n", *keyFile)
+		}
+	}
+
+	encrypted, err := repo.Encrypt(*keyFile, watchers.PendingFile, watchers.RetryFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encrypting: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Repository encrypted: %d stored objects encrypted\n", encrypted)
+}
+
+// handleMigrate rehashes the repository for the current format version
+func handleMigrate(args []string) {
+	migrateFlags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	migrateFlags.Parse(args)
+
+	repo, _ := loadRepository()
+
+	result, err := repo.Migrate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error migrating: %v\n", err)
+		os.Exit(1)
+	}
+
+	if result.From >= result.To {
+		fmt.Printf("Already at format version %d\n", result.To)
+		return
+	}
+	fmt.Printf("Migrated from format version %d to %d: rehashed %d commits\n", result.From, result.To, len(result.Rehashed))
+}
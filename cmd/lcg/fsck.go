@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// handleFsck checks the repository for inconsistencies, optionally repairing
+// the index and HEAD. Exits with status 1 while problems remain.
+func handleFsck(args []string) {
+	fsckFlags := flag.NewFlagSet("fsck", flag.ExitOnError)
+	repair := fsckFlags.Bool("repair", false, "Fix the index and HEAD to match the objects")
+
+	fsckFlags.Parse(args)
+
+	repo, _ := loadRepository()
+
+	result, err := repo.Fsck(*repair)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error checking repository: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(result.Problems) == 0 {
+		fmt.Printf("Checked %d objects; no problems found\n", result.Objects)
+		return
+	}
+
+	for _, problem := range result.Problems {
+		hash := problem.Hash
+		if len(hash) > 8 {
+			hash = hash[:8]
+		}
+		status := colorResult(false, "✗")
+		if problem.Repaired {
+			status = colorResult(true, "✓")
+		}
+		fmt.Printf("  %s %s %s %s\n", status, padRight(problem.Kind, 14), colorHash(padRight(hash, 8)), problem.Detail)
+	}
+
+	unrepaired, repairable := result.Unrepaired(), 0
+	for _, problem := range result.Problems {
+		if problem.Repairable() {
+			repairable++
+		}
+	}
+	fmt.Printf("Checked %d objects; found %d problems", result.Objects, len(result.Problems))
+	if *repair {
+		fmt.Printf(", repaired %d", len(result.Problems)-unrepaired)
+	}
+	fmt.Println()
+
+	if unrepaired > 0 {
+		if !*repair && repairable > 0 {
+			fmt.Fprintf(os.Stderr, "Run lcg fsck --repair to fix the index and HEAD\n")
+		}
+		os.Exit(1)
+	}
+}
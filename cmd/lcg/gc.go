@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+// handleGC deletes objects no longer reachable from HEAD, tags or performances
+func handleGC(args []string) {
+	gcFlags := flag.NewFlagSet("gc", flag.ExitOnError)
+	dryRun := gcFlags.Bool("dry-run", false, "Report unreachable objects without deleting them")
+
+	gcFlags.Parse(args)
+
+	repo, _ := loadRepository()
+
+	result, err := repo.GC(*dryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error collecting garbage: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(result.Unreadable) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %d reachable objects can't be read, so no blob was removed; run 'lcg fsck' for details\n", len(result.Unreadable))
+	}
+	if len(result.Unreachable) == 0 && len(result.Blobs) == 0 {
+		fmt.Printf("Nothing to remove; %d objects are reachable\n", result.Reachable)
+		return
+	}
+
+	if result.DryRun {
+		for _, hash := range result.Unreachable {
+			description := colorDim("(unreadable)")
+			if commit, err := repo.GetCommit(hash); err == nil {
+				description = fmt.Sprintf("%s %s", colorTime(commit.Timestamp.Format("2006-01-02 15:04:05")), commit.Message)
+			}
+			fmt.Printf("  %s %s\n", colorHash(hash[:8]), description)
+		}
+		fmt.Printf("Would remove %s; %d are reachable\n", gcSummary(result), result.Reachable)
+		return
+	}
+
+	fmt.Printf("Removed %s; %d are reachable\n", gcSummary(result), result.Reachable)
+}
+
+// gcSummary describes what a garbage collection removes, e.g.
+// "3 unreachable objects and 2 unused blobs (1.5 KiB)"
+func gcSummary(result *core.GCResult) string {
+	summary := fmt.Sprintf("%d unreachable objects", len(result.Unreachable))
+	if len(result.Blobs) > 0 {
+		summary += fmt.Sprintf(" and %d unused blobs", len(result.Blobs))
+	}
+	return fmt.Sprintf("%s (%s)", summary, formatBytes(result.Bytes))
+}
+
+// formatBytes renders a size with a binary unit, e.g. 1.5 KiB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// handleRepack moves loose objects and packfiles into a single packfile
+func handleRepack(args []string) {
+	repackFlags := flag.NewFlagSet("repack", flag.ExitOnError)
+	repackFlags.Parse(args)
+
+	repo, _ := loadRepository()
+
+	result, err := repo.Repack()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error repacking: %v\n", err)
+		os.Exit(1)
+	}
+
+	if result.Pack == "" {
+		fmt.Println("Nothing to repack")
+		return
+	}
+	fmt.Printf("Packed %d objects into %s (%d loose objects, %d packfiles merged)\n",
+		result.Objects, result.Pack, result.Loose, result.Packs)
+}
+
+// handleCountObjects shows how many commits and blobs the repository stores,
+// and how much storing identical content once saved
+func handleCountObjects(args []string) {
+	countFlags := flag.NewFlagSet("count-objects", flag.ExitOnError)
+	countFlags.Parse(args)
+
+	repo, _ := loadRepository()
+
+	count, err := repo.CountObjects()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error counting objects: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Commits: %d (%s)\n", count.Commits, formatBytes(count.CommitBytes))
+	fmt.Printf("Blobs:   %d (%s), content of %d commits\n", count.Blobs, formatBytes(count.BlobBytes), count.BlobCommits)
+	if count.BlobCommits > 0 {
+		fmt.Printf("Saved:   %s by storing identical content once\n", formatBytes(count.Saved()))
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+func handleGrep(args []string) {
+	grepFlags := flag.NewFlagSet("grep", flag.ExitOnError)
+	buffer := grepFlags.String("buffer", "", "Only read commits in this buffer")
+	since := grepFlags.String("since", "", "Only read commits after this time (e.g. 30m, 2024-05-01, 21:00)")
+	hashesOnly := grepFlags.Bool("l", false, "Show only the hashes of matching commits")
+	ignoreCase := grepFlags.Bool("i", false, "Ignore case")
+
+	rest := parseInterspersed(grepFlags, args)
+	if len(rest) != 1 {
+		fmt.Fprintf(os.Stderr, "Error: one pattern is required\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg grep [--buffer name] [--since time] [-l] [-i] <regexp>\n")
+		os.Exit(1)
+	}
+
+	expression := rest[0]
+	if *ignoreCase {
+		expression = "(?i)" + expression
+	}
+	pattern, err := regexp.Compile(expression)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid pattern: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := core.GrepOptions{Buffer: *buffer}
+	if opts.Since, err = parseTimeFlag(*since); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --since value: %v\n", err)
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
+	// Lines are printed as they are found rather than collected
+	found := false
+	err = repo.Grep(pattern, opts, func(match core.GrepMatch) bool {
+		found = true
+		if *hashesOnly {
+			fmt.Println(match.Hash)
+			return false
+		}
+		fmt.Printf("%s:%s:%s\n", colorHash(match.Hash[:8]), paint(ansiGreen, fmt.Sprintf("%d", match.Line)), match.Text)
+		return true
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error searching commits: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Like grep, no match is a failure scripts can test for
+	if !found {
+		os.Exit(1)
+	}
+}
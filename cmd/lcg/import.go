@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/livecodegit/pkg/importer"
+)
+
+func handleImport(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: import source is required\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg import git <path> [options]\n")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "git":
+		handleImportGit(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown import source: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// handleImportGit synthesizes commits from the history of a Git repository
+// of livecoding files, e.g. an archive of past sets
+func handleImportGit(args []string) {
+	gitFlags := flag.NewFlagSet("import git", flag.ExitOnError)
+	rev := gitFlags.String("rev", "HEAD", "Revision to import")
+	name := gitFlags.String("performance", "", "Name of the performance spanning the import (default: the directory name)")
+
+	paths := parseInterspersed(gitFlags, args)
+	if len(paths) != 1 {
+		fmt.Fprintf(os.Stderr, "Error: a Git repository path is required\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg import git <path> [--rev rev] [--performance name]\n")
+		os.Exit(1)
+	}
+	path := paths[0]
+
+	if _, err := exec.LookPath("git"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: git is required to import a Git repository\n")
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
+	// History is kept in commit order, so old commits can't follow new ones
+	existing, err := repo.History()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+		os.Exit(1)
+	}
+	if len(existing) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: the repository already has %d commits; import into a new repository\n", len(existing))
+		os.Exit(1)
+	}
+
+	history, err := importer.ReadGit(path, *rev)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading Git repository: %v\n", err)
+		os.Exit(1)
+	}
+	if len(history.Commits) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no livecoding files found in %s\n", path)
+		os.Exit(1)
+	}
+
+	for _, commit := range history.Commits {
+		if err := repo.ImportCommit(commit); err != nil {
+			fmt.Fprintf(os.Stderr, "Error importing commit: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	for tag, commit := range history.Tags {
+		if err := repo.Tag(tag, commit.Hash); err != nil {
+			fmt.Fprintf(os.Stderr, "Error importing tag %s: %v\n", tag, err)
+			os.Exit(1)
+		}
+	}
+
+	if *name == "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			*name = filepath.Base(abs)
+		}
+	}
+	performance, err := repo.ImportPerformance(*name, history.Commits)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating performance: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Imported %d commits across %d buffers and %d tags from %s\n",
+		len(history.Commits), len(performance.Buffers), len(history.Tags), path)
+	fmt.Printf("Performance %s (%s)\n", performance.Name, performance.ID)
+}
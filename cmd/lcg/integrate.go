@@ -0,0 +1,279 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/livecodegit/pkg/integrate"
+	"github.com/livecodegit/pkg/watchers"
+	"github.com/livecodegit/pkg/watchers/emacs"
+	"github.com/livecodegit/pkg/watchers/pulsar"
+	"github.com/livecodegit/pkg/watchers/tidal"
+)
+
+func handleIntegrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: integration target is required\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg integrate <tidal|sonicpi|emacs|pulsar> [options]\n")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "tidal":
+		handleIntegrateTidal(args[1:])
+	case "sonicpi":
+		handleIntegrateSonicPi(args[1:])
+	case "emacs":
+		handleIntegrateEmacs(args[1:])
+	case "pulsar":
+		handleIntegratePulsar(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown integration target: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+func handleIntegrateTidal(args []string) {
+	tidalFlags := flag.NewFlagSet("integrate tidal", flag.ExitOnError)
+	port := tidalFlags.Int("port", tidal.DefaultHookPort, "UDP port of the tidal-hook watcher (default: from watcher config)")
+	connections := tidalFlags.Int("connections", 12, "Number of connections (d1..dN) to forward")
+	bootPath := tidalFlags.String("boot", "", "Append the hook to this BootTidal.hs instead of printing it")
+	verify := tidalFlags.Bool("verify", false, "Send a test evaluation through GHCi and wait for it to arrive")
+	ghciCommand := tidalFlags.String("ghci", "", "GHCi command used by --verify (default: from watcher config)")
+	author := tidalFlags.String("author", "", "Record evaluations from this session as this performer")
+	configPath := tidalFlags.String("config", "", "Path to watcher configuration file")
+
+	tidalFlags.Parse(args)
+
+	if *connections < 1 {
+		fmt.Fprintf(os.Stderr, "Error: --connections must be at least 1\n")
+		os.Exit(1)
+	}
+
+	// Fall back to the watcher configuration so the hook and the watcher agree
+	configManager := loadIntegrationConfig(*configPath)
+	if !flagWasSet(tidalFlags, "port") {
+		*port = configuredPort(configManager, "tidal-hook", "hook_port", *port)
+	}
+
+	if *ghciCommand == "" {
+		*ghciCommand = "ghci"
+		if config, exists := configManager.GetWatcherConfig("tidal-ghci"); exists && config.Options["ghci_command"] != "" {
+			*ghciCommand = config.Options["ghci_command"]
+		}
+	}
+
+	if *verify {
+		fmt.Printf("Sending a test evaluation through '%s' to port %d...\n", *ghciCommand, *port)
+		event, err := integrate.VerifyTidal(*ghciCommand, *port, 15*time.Second)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Verification failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Received test evaluation from buffer %s: the hook is wired correctly\n", event.Buffer)
+		return
+	}
+
+	fragment := integrate.TidalBootFragment(*port, *connections, *author)
+
+	if *bootPath == "" {
+		fmt.Print(fragment)
+		return
+	}
+
+	replaced, err := integrate.InstallTidalBootFragment(*bootPath, fragment)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error installing hook: %v\n", err)
+		os.Exit(1)
+	}
+
+	if replaced {
+		fmt.Printf("Updated LiveCodeGit hook in %s\n", *bootPath)
+	} else {
+		fmt.Printf("Added LiveCodeGit hook to %s\n", *bootPath)
+	}
+
+	if config, exists := configManager.GetWatcherConfig("tidal-hook"); !exists || !config.Enabled {
+		fmt.Printf("Enable the receiving watcher with: lcg watch --enable tidal-hook\n")
+	}
+}
+
+func handleIntegrateSonicPi(args []string) {
+	sonicPiFlags := flag.NewFlagSet("integrate sonicpi", flag.ExitOnError)
+	port := sonicPiFlags.Int("port", 4559, "UDP port of the sonicpi-osc watcher (default: from watcher config)")
+	initPath := sonicPiFlags.String("init", "", "Sonic Pi init.rb to write the hook into (default: detected)")
+	printOnly := sonicPiFlags.Bool("print", false, "Print the hook instead of writing it")
+	verify := sonicPiFlags.Bool("verify", false, "Wait for a Run from Sonic Pi to arrive")
+	timeout := sonicPiFlags.Duration("timeout", 60*time.Second, "How long --verify waits for a Run")
+	author := sonicPiFlags.String("author", "", "Record Runs from this Sonic Pi as this performer")
+	configPath := sonicPiFlags.String("config", "", "Path to watcher configuration file")
+
+	sonicPiFlags.Parse(args)
+
+	configManager := loadIntegrationConfig(*configPath)
+	if !flagWasSet(sonicPiFlags, "port") {
+		*port = configuredPort(configManager, "sonicpi-osc", "osc_port", *port)
+	}
+
+	if *verify {
+		fmt.Printf("Listening on port %d: press Run in Sonic Pi within %s...\n", *port, *timeout)
+		event, err := integrate.VerifySonicPi(*port, *timeout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Verification failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Received Run from buffer %s: the hook is wired correctly\n", event.Buffer)
+		return
+	}
+
+	fragment := integrate.SonicPiInitFragment(*port, *author)
+
+	if *printOnly {
+		fmt.Print(fragment)
+		return
+	}
+
+	if *initPath == "" {
+		*initPath = integrate.DefaultSonicPiInitPath()
+	}
+
+	replaced, err := integrate.InstallSonicPiInitFragment(*initPath, fragment)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error installing hook: %v\n", err)
+		os.Exit(1)
+	}
+
+	if replaced {
+		fmt.Printf("Updated LiveCodeGit hook in %s\n", *initPath)
+	} else {
+		fmt.Printf("Added LiveCodeGit hook to %s\n", *initPath)
+	}
+	fmt.Printf("Restart Sonic Pi to load it, then check with: lcg integrate sonicpi --verify\n")
+
+	if config, exists := configManager.GetWatcherConfig("sonicpi-osc"); !exists || !config.Enabled {
+		fmt.Printf("Enable the receiving watcher with: lcg watch --enable sonicpi-osc\n")
+	}
+}
+
+func handleIntegrateEmacs(args []string) {
+	emacsFlags := flag.NewFlagSet("integrate emacs", flag.ExitOnError)
+	port := emacsFlags.Int("port", emacs.DefaultPort, "TCP port of the emacs watcher (default: from watcher config)")
+	transcript := emacsFlags.String("transcript", "", "Transcript file tidal.el's evaluations are appended to (default: from watcher config)")
+	initPath := emacsFlags.String("init", "", "Append the hook to this Emacs init file instead of printing it")
+	configPath := emacsFlags.String("config", "", "Path to watcher configuration file")
+
+	emacsFlags.Parse(args)
+
+	configManager := loadIntegrationConfig(*configPath)
+	if !flagWasSet(emacsFlags, "port") {
+		*port = configuredPort(configManager, "emacs", "tcp_port", *port)
+	}
+	config, exists := configManager.GetWatcherConfig("emacs")
+	if !flagWasSet(emacsFlags, "transcript") && exists {
+		*transcript = config.Options["transcript"]
+	}
+
+	fragment := integrate.EmacsFragment(*port, *transcript)
+
+	if *initPath == "" {
+		fmt.Print(fragment)
+		return
+	}
+
+	replaced, err := integrate.InstallEmacsFragment(*initPath, fragment)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error installing hook: %v\n", err)
+		os.Exit(1)
+	}
+
+	if replaced {
+		fmt.Printf("Updated LiveCodeGit hook in %s\n", *initPath)
+	} else {
+		fmt.Printf("Added LiveCodeGit hook to %s\n", *initPath)
+	}
+	fmt.Printf("Evaluate it, or restart Emacs, to load it\n")
+
+	if *transcript != "" && (!exists || config.Options["transcript"] != *transcript) {
+		fmt.Printf("Point the watcher at the transcript with: lcg watch --set emacs.transcript=%s\n", *transcript)
+	}
+	if !exists || !config.Enabled {
+		fmt.Printf("Enable the receiving watcher with: lcg watch --enable emacs\n")
+	}
+}
+
+func handleIntegratePulsar(args []string) {
+	pulsarFlags := flag.NewFlagSet("integrate pulsar", flag.ExitOnError)
+	wrapperPath := pulsarFlags.String("wrapper", integrate.DefaultPulsarWrapperPath(), "Where to write the GHCi wrapper")
+	logPath := pulsarFlags.String("log", "", "Log the wrapper writes (default: from watcher config)")
+	ghciCommand := pulsarFlags.String("ghci", "", "GHCi command the wrapper runs (default: from watcher config)")
+	printOnly := pulsarFlags.Bool("print", false, "Print the wrapper instead of writing it")
+	configPath := pulsarFlags.String("config", "", "Path to watcher configuration file")
+
+	pulsarFlags.Parse(args)
+
+	configManager := loadIntegrationConfig(*configPath)
+	config, exists := configManager.GetWatcherConfig("pulsar")
+	watchedLog := config.Options["log_path"]
+	if watchedLog == "" {
+		watchedLog = pulsar.DefaultLogPath()
+	}
+	if *logPath == "" {
+		*logPath = watchedLog
+	}
+	if *ghciCommand == "" {
+		*ghciCommand = "ghci"
+		if tidalConfig, exists := configManager.GetWatcherConfig("tidal-ghci"); exists && tidalConfig.Options["ghci_command"] != "" {
+			*ghciCommand = tidalConfig.Options["ghci_command"]
+		}
+	}
+
+	script := integrate.PulsarWrapper(*ghciCommand, *logPath)
+
+	if *printOnly {
+		fmt.Print(script)
+		return
+	}
+
+	if err := integrate.InstallPulsarWrapper(*wrapperPath, script); err != nil {
+		fmt.Fprintf(os.Stderr, "Error installing wrapper: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Wrote GHCi wrapper to %s\n", *wrapperPath)
+	fmt.Printf("Set the tidalcycles package's GHCi path to it in Pulsar, then restart Tidal\n")
+
+	if *logPath != watchedLog {
+		fmt.Printf("Point the watcher at the log with: lcg watch --set pulsar.log_path=%s\n", *logPath)
+	}
+	if !exists || !config.Enabled {
+		fmt.Printf("Enable the receiving watcher with: lcg watch --enable pulsar\n")
+	}
+}
+
+// loadIntegrationConfig loads the watcher configuration the generated hooks report to
+func loadIntegrationConfig(configPath string) *watchers.ConfigManager {
+	if configPath == "" {
+		configPath = watchers.GetDefaultConfigPath()
+	}
+
+	configManager := watchers.NewConfigManager(configPath)
+	if err := configManager.LoadConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading watcher configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	return configManager
+}
+
+// configuredPort returns a watcher's port option, or fallback when it is unset or invalid
+func configuredPort(configManager *watchers.ConfigManager, watcher, option string, fallback int) int {
+	if config, exists := configManager.GetWatcherConfig(watcher); exists {
+		if port, err := strconv.Atoi(config.Options[option]); err == nil {
+			return port
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/livecodegit/pkg/journal"
+)
+
+// journalLevels orders journal levels by severity
+var journalLevels = map[string]int{journal.LevelInfo: 0, journal.LevelWarn: 1, journal.LevelError: 2}
+
+// handleLogs shows the watcher service journal, optionally following it
+func handleLogs(args []string) {
+	logsFlags := flag.NewFlagSet("logs", flag.ExitOnError)
+	limit := logsFlags.Int("n", 50, "Number of entries to show (0 for all)")
+	follow := logsFlags.Bool("follow", false, "Keep showing new entries as they are written")
+	logsFlags.BoolVar(follow, "f", false, "Shorthand for --follow")
+	level := logsFlags.String("level", journal.LevelInfo, "Only show entries of this level or worse (info, warn, error)")
+	event := logsFlags.String("event", "", "Only show one kind of entry (service, watcher, commit, pending, control, maintenance, error)")
+	asJSON := logsFlags.Bool("json", false, "Print entries as JSON lines")
+
+	logsFlags.Parse(args)
+
+	minimum, known := journalLevels[*level]
+	if !known {
+		fmt.Fprintf(os.Stderr, "Error: unknown level %s (use info, warn or error)\n", *level)
+		os.Exit(1)
+	}
+
+	_, path := loadRepository()
+
+	show := func(entry journal.Entry) {
+		if journalLevels[entry.Level] < minimum || (*event != "" && entry.Event != *event) {
+			return
+		}
+		if *asJSON {
+			data, _ := json.Marshal(entry)
+			fmt.Println(string(data))
+			return
+		}
+		fmt.Println(formatJournalEntry(entry))
+	}
+
+	// Filter before limiting, so -n counts the entries shown
+	entries, err := journal.Read(path, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading journal: %v\n", err)
+		os.Exit(1)
+	}
+	var shown []journal.Entry
+	for _, entry := range entries {
+		if journalLevels[entry.Level] >= minimum && (*event == "" || entry.Event == *event) {
+			shown = append(shown, entry)
+		}
+	}
+	if *limit > 0 && len(shown) > *limit {
+		shown = shown[len(shown)-*limit:]
+	}
+
+	if len(shown) == 0 && !*follow {
+		fmt.Printf("No journal entries in %s\n", journal.Dir(path))
+		return
+	}
+	for _, entry := range shown {
+		show(entry)
+	}
+
+	if !*follow {
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := journal.Follow(ctx, path, 250*time.Millisecond, show); err != nil {
+		fmt.Fprintf(os.Stderr, "Error following journal: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// formatJournalEntry renders an entry as one line: time, level, kind,
+// message and its fields
+func formatJournalEntry(entry journal.Entry) string {
+	level := padRight(strings.ToUpper(entry.Level), 5)
+	switch entry.Level {
+	case journal.LevelError:
+		level = colorResult(false, level)
+	case journal.LevelWarn:
+		level = paint(ansiYellow, level)
+	default:
+		level = colorDim(level)
+	}
+
+	keys := make([]string, 0, len(entry.Fields))
+	for key := range entry.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var fields []string
+	for _, key := range keys {
+		value := entry.Fields[key]
+		if key == "hash" && len(value) > 8 {
+			value = colorHash(value[:8])
+		}
+		fields = append(fields, fmt.Sprintf("%s=%s", key, value))
+	}
+
+	line := fmt.Sprintf("%s %s %s %s", colorTime(entry.Time.Local().Format("2006-01-02 15:04:05")), level,
+		padRight(entry.Event, 7), entry.Message)
+	if len(fields) > 0 {
+		line += " " + colorDim(strings.Join(fields, " "))
+	}
+	return line
+}
@@ -1,25 +1,36 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/storage"
 )
 
 const (
 	version = "0.1.0"
+
+	// uploadWait is how long a command waits for uploads to the s3 backend
+	// before exiting
+	uploadWait = 10 * time.Second
 )
 
 func main() {
-	if len(os.Args) < 2 {
+	cliArgs := configureColor(os.Args[1:])
+	if len(cliArgs) < 1 {
 		printUsageToStderr()
 		os.Exit(1)
 	}
 
-	command := os.Args[1]
-	args := os.Args[2:]
+	command := cliArgs[0]
+	args := cliArgs[1:]
 
 	switch command {
 	case "init":
@@ -28,8 +39,80 @@ func main() {
 		handleCommit(args)
 	case "log":
 		handleLog(args)
+	case "search":
+		handleSearch(args)
+	case "grep":
+		handleGrep(args)
+	case "tui":
+		handleTUI(args)
+	case "serve":
+		handleServe(args)
+	case "push":
+		handlePush(args)
+	case "pull":
+		handlePull(args)
+	case "clone":
+		handleClone(args)
+	case "export":
+		handleExport(args)
+	case "archive":
+		handleArchive(args)
+	case "import":
+		handleImport(args)
+	case "timeline":
+		handleTimeline(args)
+	case "status":
+		handleStatus(args)
+	case "stats":
+		handleStats(args)
+	case "gc":
+		handleGC(args)
+	case "repack":
+		handleRepack(args)
+	case "count-objects":
+		handleCountObjects(args)
+	case "format":
+		handleFormat(args)
+	case "migrate":
+		handleMigrate(args)
+	case "encrypt":
+		handleEncrypt(args)
+	case "fsck":
+		handleFsck(args)
+	case "unlock":
+		handleUnlock(args)
+	case "bisect":
+		handleBisect(args)
+	case "checkpoint":
+		handleCheckpoint(args)
+	case "snapshot":
+		handleSnapshot(args)
+	case "privacy":
+		handlePrivacy(args)
+	case "blame":
+		handleBlame(args)
+	case "logs":
+		handleLogs(args)
+	case "config":
+		handleConfig(args)
+	case "performance":
+		handlePerformance(args)
+	case "template":
+		handleTemplate(args)
+	case "replay":
+		handleReplay(args)
+	case "checkout":
+		handleCheckout(args)
+	case "pending":
+		handlePending(args)
+	case "integrate":
+		handleIntegrate(args)
 	case "watch":
 		handleWatch(args)
+	case "flush":
+		handleFlush(args)
+	case "daemon":
+		handleDaemon(args)
 	case "version":
 		fmt.Printf("LiveCodeGit version %s\n", version)
 	case "help", "--help", "-h":
@@ -39,13 +122,45 @@ func main() {
 		printUsageToStderr()
 		os.Exit(1)
 	}
+
+	// The s3 backend uploads in the background; what isn't sent by then is
+	// left pending for the next command
+	storage.WaitForUploads(uploadWait)
+}
+
+// loadRepository opens the repository in the current directory, exiting with
+// a hint when there is none
+func loadRepository() (*core.LiveCodeRepository, string) {
+	path, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting current directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Commands needing the whole index load it when they first do
+	repo, err := core.OpenRepository(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading repository: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Make sure you're in a LiveCodeGit repository (run 'lcg init' first)\n")
+		os.Exit(1)
+	}
+
+	return repo, path
 }
 
 func handleInit(args []string) {
-	var path string
+	initFlags := flag.NewFlagSet("init", flag.ExitOnError)
+	backend := initFlags.String("backend", storage.BackendFiles, "Object storage: files (one file per object), kv (a single key-value file) or s3 (files mirrored to a bucket)")
+	var s3 storage.S3Config
+	initFlags.StringVar(&s3.Bucket, "s3-bucket", "", "Bucket the s3 backend mirrors objects to")
+	initFlags.StringVar(&s3.Prefix, "s3-prefix", "", "Prefix of the keys in the bucket")
+	initFlags.StringVar(&s3.Region, "s3-region", "", "Region of the bucket (default: us-east-1)")
+	initFlags.StringVar(&s3.Endpoint, "s3-endpoint", "", "URL of an S3-compatible service (default: AWS)")
+	compression := initFlags.String("compression", storage.CompressionNone, "Compress objects: gzip or none")
 
-	if len(args) > 0 {
-		path = args[0]
+	var path string
+	if rest := parseInterspersed(initFlags, args); len(rest) > 0 {
+		path = rest[0]
 	} else {
 		var err error
 		path, err = os.Getwd()
@@ -56,10 +171,36 @@ func handleInit(args []string) {
 	}
 
 	repo := core.NewRepository(path)
-	if err := repo.Init(path); err != nil {
+	if !storage.ValidCompression(*compression) {
+		fmt.Fprintf(os.Stderr, "Error: unknown compression %q (expected gzip or none)\n", *compression)
+		os.Exit(1)
+	}
+	if *backend == storage.BackendS3 && s3.Bucket == "" {
+		fmt.Fprintf(os.Stderr, "Error: the s3 backend needs --s3-bucket\n")
+		os.Exit(1)
+	}
+	if err := repo.InitWithBackend(path, *backend); err != nil {
 		fmt.Fprintf(os.Stderr, "Error initializing repository: %v\n", err)
 		os.Exit(1)
 	}
+	if *backend == storage.BackendS3 {
+		if err := repo.SetS3(s3); err != nil {
+			fmt.Fprintf(os.Stderr, "Error initializing repository: %v\n", err)
+			os.Exit(1)
+		}
+		// The bucket may hold a set mirrored from another machine
+		if fetched, err := repo.S3Fetch(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to fetch from the bucket: %v\n", err)
+		} else if fetched > 0 {
+			fmt.Printf("Fetched %d objects and performances from the bucket\n", fetched)
+		}
+	}
+	if *compression != storage.CompressionNone {
+		if err := repo.SetCompression(*compression); err != nil {
+			fmt.Fprintf(os.Stderr, "Error initializing repository: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
 	fmt.Printf("Initialized empty LiveCodeGit repository in %s\n", path)
 }
@@ -68,35 +209,89 @@ func handleCommit(args []string) {
 	commitFlags := flag.NewFlagSet("commit", flag.ExitOnError)
 	message := commitFlags.String("m", "", "Commit message")
 	content := commitFlags.String("c", "", "Code content to commit")
-	language := commitFlags.String("l", "unknown", "Programming language")
-	buffer := commitFlags.String("b", "main", "Buffer name")
+	file := commitFlags.String("f", "", "Read code content from a file")
+	fromStdin := commitFlags.Bool("stdin", false, "Read code content from standard input")
+	language := commitFlags.String("l", "unknown", "Programming language (default: defaults.language from config)")
+	buffer := commitFlags.String("b", "main", "Buffer name (default: defaults.buffer from config)")
+	amend := commitFlags.Bool("amend", false, "Replace the last commit; options not given keep its values")
+	noVerify := commitFlags.Bool("no-verify", false, "Don't run the pre-commit and post-commit hooks")
+
+	positional := parseInterspersed(commitFlags, args)
 
-	commitFlags.Parse(args)
+	// A lone "-" argument reads from stdin, like --stdin
+	if len(positional) == 1 && positional[0] == "-" {
+		*fromStdin = true
+	} else if len(positional) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: unexpected argument %q\n", positional[0])
+		fmt.Fprintf(os.Stderr, "Usage: lcg commit -m <message> [-c code | -f file | --stdin | -] [options]\n")
+		os.Exit(1)
+	}
 
-	if *message == "" {
+	if *message == "" && !*amend {
 		fmt.Fprintf(os.Stderr, "Error: commit message is required (-m)\n")
 		os.Exit(1)
 	}
 
-	if *content == "" {
-		fmt.Fprintf(os.Stderr, "Error: code content is required (-c)\n")
+	sources := 0
+	for _, set := range []bool{*content != "", *file != "", *fromStdin} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		fmt.Fprintf(os.Stderr, "Error: only one of -c, -f and --stdin can be used\n")
 		os.Exit(1)
 	}
 
-	// Get current directory
-	path, err := os.Getwd()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting current directory: %v\n", err)
+	if *file != "" {
+		data, err := os.ReadFile(*file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading content file: %v\n", err)
+			os.Exit(1)
+		}
+		*content = string(data)
+
+		// Infer the language from the file extension unless given explicitly
+		if !flagWasSet(commitFlags, "l") {
+			*language = core.LanguageFromExtension(*file)
+		}
+	}
+
+	if *fromStdin {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading content from stdin: %v\n", err)
+			os.Exit(1)
+		}
+		*content = string(data)
+	}
+
+	if *content == "" && !*amend {
+		fmt.Fprintf(os.Stderr, "Error: code content is required (-c, -f or --stdin)\n")
 		os.Exit(1)
 	}
 
-	// Load repository
-	repo, err := core.LoadRepository(path)
+	repo, _ := loadRepository()
+	repo.SetHooksEnabled(!*noVerify)
+
+	if *amend {
+		amendCommit(repo, commitFlags, *message, *content, *language, *buffer, *file != "")
+		return
+	}
+
+	// Fall back to the repository's configured defaults
+	config, err := repo.Config()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading repository: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Make sure you're in a LiveCodeGit repository (run 'lcg init' first)\n")
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
 		os.Exit(1)
 	}
+	defaults := config.Settings().Defaults
+	if !flagWasSet(commitFlags, "l") && *file == "" && defaults.Language != "" {
+		*language = defaults.Language
+	}
+	if !flagWasSet(commitFlags, "b") && defaults.Buffer != "" {
+		*buffer = defaults.Buffer
+	}
 
 	// Create execution metadata
 	metadata := core.ExecutionMetadata{
@@ -113,38 +308,179 @@ func handleCommit(args []string) {
 		os.Exit(1)
 	}
 
-	fmt.Printf("Created commit %s\n", commit.Hash[:8])
+	if err := repo.FlushPerformance(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update performance: %v\n", err)
+	}
+
+	fmt.Printf("Created commit %s\n", colorHash(commit.Hash[:8]))
 	fmt.Printf("Message: %s\n", commit.Message)
 }
 
+// amendCommit replaces the HEAD commit, keeping the message, content, language
+// and buffer that weren't given
+func amendCommit(repo *core.LiveCodeRepository, flags *flag.FlagSet, message, content, language, buffer string, fromFile bool) {
+	commits, err := repo.Log(1)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error retrieving HEAD commit: %v\n", err)
+		os.Exit(1)
+	}
+	if len(commits) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no commit to amend\n")
+		os.Exit(1)
+	}
+	head := commits[0]
+
+	metadata := head.Metadata
+	if message == "" {
+		message = head.Message
+	}
+	if content == "" {
+		content = head.Content
+	}
+	if flagWasSet(flags, "l") || fromFile {
+		metadata.Language = language
+	}
+	if flagWasSet(flags, "b") {
+		metadata.Buffer = buffer
+	}
+
+	commit, err := repo.Amend(content, message, metadata)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error amending commit: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Amended commit %s (was %s)\n", colorHash(commit.Hash[:8]), colorHash(head.Hash[:8]))
+	fmt.Printf("Message: %s\n", commit.Message)
+}
+
+// parseInterspersed parses flags given before or after positional arguments,
+// e.g. 'lcg replay <id> --speed 2', and returns the positional arguments
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for len(args) > 0 {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) > 0 {
+			positional = append(positional, args[0])
+			args = args[1:]
+		}
+	}
+	return positional
+}
+
+// formatAuthor renders an author as "name <email>", or just the name
+func formatAuthor(name, email string) string {
+	if email == "" {
+		return name
+	}
+	return fmt.Sprintf("%s <%s>", name, email)
+}
+
+// flagWasSet reports whether a flag was explicitly passed on the command line
+func flagWasSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
 func handleLog(args []string) {
 	logFlags := flag.NewFlagSet("log", flag.ExitOnError)
 	limit := logFlags.Int("n", 10, "Number of commits to show")
+	skip := logFlags.Int("skip", 0, "Skip this many commits first, e.g. for the next page")
+	jsonOutput := logFlags.Bool("json", false, "Print commits as a JSON array")
+	language := logFlags.String("lang", "", "Only show commits in this language")
+	buffer := logFlags.String("buffer", "", "Only show commits from this buffer")
+	author := logFlags.String("author", "", "Only show commits by this author")
+	since := logFlags.String("since", "", "Only show commits after this time (e.g. 30m, 2024-05-01, 21:00)")
+	failed := logFlags.Bool("failed", false, "Only show failed executions")
+	success := logFlags.Bool("success", false, "Only show successful executions")
+	minRMS := logFlags.Float64("min-rms", 0, "Only show commits whose sound was at least this loud (0-1, from an audio analyzer)")
+	format := logFlags.String("format", "", "Print each commit with a Go template, e.g. \"{{.Hash}} {{.Message}}\"")
+	oneline := logFlags.Bool("oneline", false, "Print each commit on one line")
 
 	logFlags.Parse(args)
 
-	// Get current directory
-	path, err := os.Getwd()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting current directory: %v\n", err)
+	if *failed && *success {
+		fmt.Fprintf(os.Stderr, "Error: --failed and --success cannot be used together\n")
 		os.Exit(1)
 	}
 
-	// Load repository
-	repo, err := core.LoadRepository(path)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading repository: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Make sure you're in a LiveCodeGit repository (run 'lcg init' first)\n")
+	if *oneline {
+		if *format != "" {
+			fmt.Fprintf(os.Stderr, "Error: --format and --oneline cannot be used together\n")
+			os.Exit(1)
+		}
+		*format = onelineLogFormat
+	}
+
+	if *format != "" && *jsonOutput {
+		fmt.Fprintf(os.Stderr, "Error: --json cannot be combined with --format or --oneline\n")
 		os.Exit(1)
 	}
 
+	var formatTemplate *template.Template
+	if *format != "" {
+		var err error
+		formatTemplate, err = parseLogFormat(*format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing log format: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	filter := core.LogFilter{
+		Language: *language,
+		Buffer:   *buffer,
+		Author:   *author,
+		MinRMS:   *minRMS,
+	}
+	if *failed || *success {
+		filter.Success = success
+	}
+	var err error
+	if filter.Since, err = parseTimeFlag(*since); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --since value: %v\n", err)
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
 	// Get commit log
-	commits, err := repo.Log(*limit)
+	commits, err := repo.LogPage(filter, *skip, *limit)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error retrieving commit log: %v\n", err)
 		os.Exit(1)
 	}
 
+	if *jsonOutput {
+		if commits == nil {
+			commits = []*core.Commit{}
+		}
+		data, err := json.MarshalIndent(commits, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding commit log: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	if formatTemplate != nil {
+		for _, commit := range commits {
+			if err := formatTemplate.Execute(os.Stdout, commit); err != nil {
+				fmt.Fprintf(os.Stderr, "\nError formatting commit %s: %v\n", commit.Hash, err)
+				os.Exit(1)
+			}
+			fmt.Println()
+		}
+		return
+	}
+
 	if len(commits) == 0 {
 		fmt.Println("No commits found")
 		return
@@ -152,15 +488,34 @@ func handleLog(args []string) {
 
 	// Display commits
 	for i, commit := range commits {
-		fmt.Printf("commit %s", commit.Hash)
+		fmt.Printf("commit %s", colorHash(commit.Hash))
 		if commit.Parent != "" {
-			fmt.Printf(" (parent: %s)", commit.Parent[:8])
+			fmt.Printf(" (parent: %s)", colorHash(commit.Parent[:8]))
+		}
+		if commit.BufferParent != "" && commit.BufferParent != commit.Parent {
+			fmt.Printf(" (%s parent: %s)", commit.Metadata.Buffer, colorHash(commit.BufferParent[:8]))
 		}
 		fmt.Printf("\n")
-		fmt.Printf("Date: %s\n", commit.Timestamp.Format("Mon Jan 2 15:04:05 2006"))
-		fmt.Printf("Author: %s\n", commit.Author)
-		fmt.Printf("Language: %s\n", commit.Metadata.Language)
+		fmt.Printf("Date: %s\n", colorTime(commit.Timestamp.Format("Mon Jan 2 15:04:05 2006")))
+		fmt.Printf("Author: %s\n", formatAuthor(commit.Author, commit.AuthorEmail))
+		fmt.Printf("Language: %s\n", colorLanguage(commit.Metadata.Language))
 		fmt.Printf("Buffer: %s\n", commit.Metadata.Buffer)
+		if !commit.Metadata.Success {
+			fmt.Printf("Result: %s\n", colorResult(false, "error "+commit.Metadata.ErrorMessage))
+		}
+		if audio := commit.Metadata.Audio; audio != nil {
+			fmt.Printf("Audio: rms %.2f, %.1f onsets/s, centroid %.0f Hz\n", audio.RMS, audio.OnsetDensity, audio.SpectralCentroid)
+		}
+		if sound := commit.Metadata.Sound; sound != nil {
+			fmt.Printf("Sound: cycle %.2f, %.1f events/cycle, orbits %s, %s\n", sound.Cycle, sound.EventDensity,
+				strings.Trim(fmt.Sprint(sound.Orbits), "[]"), strings.Join(sound.Sounds, " "))
+		}
+		if collapsed := commit.Metadata.Collapsed; collapsed > 0 {
+			fmt.Printf("Collapsed: %d earlier executions\n", collapsed)
+		}
+		if watchers := commit.Metadata.Watchers; len(watchers) > 1 {
+			fmt.Printf("Seen by: %s\n", strings.Join(watchers, ", "))
+		}
 		fmt.Printf("\n    %s\n", commit.Message)
 
 		if i < len(commits)-1 {
@@ -169,62 +524,256 @@ func handleLog(args []string) {
 	}
 }
 
+// onelineLogFormat is the --oneline preset for lcg log
+const onelineLogFormat = `{{short .Hash}} {{.Timestamp.Format "15:04:05"}} [{{.Metadata.Buffer}}] {{.Message}}`
+
+// parseLogFormat parses a --format template. Besides the commit fields,
+// templates can use short (abbreviated hash) and firstLine.
+func parseLogFormat(format string) (*template.Template, error) {
+	funcs := template.FuncMap{
+		"short": func(hash string) string {
+			if len(hash) > 8 {
+				return hash[:8]
+			}
+			return hash
+		},
+		"firstLine": func(text string) string {
+			line, _, _ := strings.Cut(text, "\n")
+			return line
+		},
+	}
+
+	return template.New("log").Funcs(funcs).Parse(format)
+}
+
 func printUsage() {
-	fmt.Printf("LiveCodeGit - A Git-like Version Control System for Livecoding\n\n")
-	fmt.Printf("Usage: lcg <command> [options]\n\n")
-	fmt.Printf("Commands:\n")
-	fmt.Printf("  init [path]           Initialize a new repository\n")
-	fmt.Printf("  commit                Create a new commit\n")
-	fmt.Printf("    -m <message>        Commit message (required)\n")
-	fmt.Printf("    -c <content>        Code content (required)\n")
-	fmt.Printf("    -l <language>       Programming language (default: unknown)\n")
-	fmt.Printf("    -b <buffer>         Buffer name (default: main)\n")
-	fmt.Printf("  log                   Show commit history\n")
-	fmt.Printf("    -n <number>         Number of commits to show (default: 10)\n")
-	fmt.Printf("  watch                 Start watching for code executions\n")
-	fmt.Printf("    --lang <language>   Watch specific language (sonicpi, tidal)\n")
-	fmt.Printf("    --list              List available watchers\n")
-	fmt.Printf("    --status            Show watcher status\n")
-	fmt.Printf("    --enable <name>     Enable a watcher\n")
-	fmt.Printf("    --disable <name>    Disable a watcher\n")
-	fmt.Printf("  version               Show version information\n")
-	fmt.Printf("  help                  Show this help message\n\n")
-	fmt.Printf("Examples:\n")
-	fmt.Printf("  lcg init                                    # Initialize repository in current directory\n")
-	fmt.Printf("  lcg init /path/to/project                   # Initialize repository in specific path\n")
-	fmt.Printf("  lcg commit -m \"Add bass line\" -c \"bass.play\" -l sonicpi\n")
-	fmt.Printf("  lcg log -n 5                                # Show last 5 commits\n")
-	fmt.Printf("  lcg watch --lang sonicpi                    # Start watching Sonic Pi executions\n")
-	fmt.Printf("  lcg watch --list                            # List available watchers\n")
-	fmt.Printf("  lcg watch --enable sonicpi-osc              # Enable Sonic Pi OSC watcher\n")
+	writeUsage(os.Stdout)
 }
 
 func printUsageToStderr() {
-	fmt.Fprintf(os.Stderr, "LiveCodeGit - A Git-like Version Control System for Livecoding\n\n")
-	fmt.Fprintf(os.Stderr, "Usage: lcg <command> [options]\n\n")
-	fmt.Fprintf(os.Stderr, "Commands:\n")
-	fmt.Fprintf(os.Stderr, "  init [path]           Initialize a new repository\n")
-	fmt.Fprintf(os.Stderr, "  commit                Create a new commit\n")
-	fmt.Fprintf(os.Stderr, "    -m <message>        Commit message (required)\n")
-	fmt.Fprintf(os.Stderr, "    -c <content>        Code content (required)\n")
-	fmt.Fprintf(os.Stderr, "    -l <language>       Programming language (default: unknown)\n")
-	fmt.Fprintf(os.Stderr, "    -b <buffer>         Buffer name (default: main)\n")
-	fmt.Fprintf(os.Stderr, "  log                   Show commit history\n")
-	fmt.Fprintf(os.Stderr, "    -n <number>         Number of commits to show (default: 10)\n")
-	fmt.Fprintf(os.Stderr, "  watch                 Start watching for code executions\n")
-	fmt.Fprintf(os.Stderr, "    --lang <language>   Watch specific language (sonicpi, tidal)\n")
-	fmt.Fprintf(os.Stderr, "    --list              List available watchers\n")
-	fmt.Fprintf(os.Stderr, "    --status            Show watcher status\n")
-	fmt.Fprintf(os.Stderr, "    --enable <name>     Enable a watcher\n")
-	fmt.Fprintf(os.Stderr, "    --disable <name>    Disable a watcher\n")
-	fmt.Fprintf(os.Stderr, "  version               Show version information\n")
-	fmt.Fprintf(os.Stderr, "  help                  Show this help message\n\n")
-	fmt.Fprintf(os.Stderr, "Examples:\n")
-	fmt.Fprintf(os.Stderr, "  lcg init                                    # Initialize repository in current directory\n")
-	fmt.Fprintf(os.Stderr, "  lcg init /path/to/project                   # Initialize repository in specific path\n")
-	fmt.Fprintf(os.Stderr, "  lcg commit -m \"Add bass line\" -c \"bass.play\" -l sonicpi\n")
-	fmt.Fprintf(os.Stderr, "  lcg log -n 5                                # Show last 5 commits\n")
-	fmt.Fprintf(os.Stderr, "  lcg watch --lang sonicpi                    # Start watching Sonic Pi executions\n")
-	fmt.Fprintf(os.Stderr, "  lcg watch --list                            # List available watchers\n")
-	fmt.Fprintf(os.Stderr, "  lcg watch --enable sonicpi-osc              # Enable Sonic Pi OSC watcher\n")
+	writeUsage(os.Stderr)
+}
+
+func writeUsage(w io.Writer) {
+	fmt.Fprintf(w, "LiveCodeGit - A Git-like Version Control System for Livecoding\n\n")
+	fmt.Fprintf(w, "Usage: lcg <command> [options]\n\n")
+	fmt.Fprintf(w, "Commands:\n")
+	fmt.Fprintf(w, "  init [path]           Initialize a new repository\n")
+	fmt.Fprintf(w, "    --backend <name>    Object storage: files (default), kv, a single key-value file, or s3\n")
+	fmt.Fprintf(w, "    --s3-bucket <name>  Bucket the s3 backend mirrors objects to (--s3-prefix, --s3-region, --s3-endpoint)\n")
+	fmt.Fprintf(w, "    --compression gzip  Compress objects (default: none)\n")
+	fmt.Fprintf(w, "  format                Show the format version and how objects are stored\n")
+	fmt.Fprintf(w, "    --compression <c>   Compress new objects with gzip, or none\n")
+	fmt.Fprintf(w, "    --blobs on|off      Store the content of new commits in shared blobs\n")
+	fmt.Fprintf(w, "  migrate               Rehash an older repository with SHA-256 for the current format\n")
+	fmt.Fprintf(w, "  encrypt               Encrypt objects at rest with AES-256-GCM, using a passphrase from %s\n", storage.PassphraseEnv)
+	fmt.Fprintf(w, "    --key-file <path>   Use the key in a file instead, generated if missing\n")
+	fmt.Fprintf(w, "  commit                Create a new commit\n")
+	fmt.Fprintf(w, "    -m <message>        Commit message (required)\n")
+	fmt.Fprintf(w, "    -c <content>        Code content (or use -f / --stdin)\n")
+	fmt.Fprintf(w, "    -f <path>           Read code content from a file\n")
+	fmt.Fprintf(w, "    --stdin, -          Read code content from standard input\n")
+	fmt.Fprintf(w, "    -l <language>       Programming language (default: inferred from -f, else defaults.language, else unknown)\n")
+	fmt.Fprintf(w, "    -b <buffer>         Buffer name (default: defaults.buffer, else main)\n")
+	fmt.Fprintf(w, "    --amend             Replace the last commit; options not given keep its values\n")
+	fmt.Fprintf(w, "    --no-verify         Skip the pre-commit and post-commit hooks\n")
+	fmt.Fprintf(w, "  log                   Show commit history\n")
+	fmt.Fprintf(w, "    -n <number>         Number of commits to show (default: 10)\n")
+	fmt.Fprintf(w, "    --lang <language>   Only show one language\n")
+	fmt.Fprintf(w, "    --buffer <name>     Only show one buffer\n")
+	fmt.Fprintf(w, "    --author <name>     Only show one author\n")
+	fmt.Fprintf(w, "    --since <time>      Only show commits after a time\n")
+	fmt.Fprintf(w, "    --failed/--success  Only show failed or successful executions\n")
+	fmt.Fprintf(w, "    --min-rms <level>   Only show commits made during loud sections (see /lcg/audio)\n")
+	fmt.Fprintf(w, "    --json              Print commits as a JSON array\n")
+	fmt.Fprintf(w, "    --format <template> Print each commit with a Go template (helpers: short, firstLine)\n")
+	fmt.Fprintf(w, "    --oneline           Print each commit on one line\n")
+	fmt.Fprintf(w, "  search <query>        Search commit contents and messages\n")
+	fmt.Fprintf(w, "    --lang <language>   Only search one language\n")
+	fmt.Fprintf(w, "    --buffer <name>     Only search one buffer\n")
+	fmt.Fprintf(w, "    --since/--until <t> Limit to a time range (e.g. 30m, 21:00, 2024-05-01)\n")
+	fmt.Fprintf(w, "    -C <number>         Lines of context around matches\n")
+	fmt.Fprintf(w, "  grep <regexp>         Print content lines matching a regular expression (exits 1 on none)\n")
+	fmt.Fprintf(w, "    --buffer <name>     Only read one buffer\n")
+	fmt.Fprintf(w, "    --since <time>      Only read commits after a time\n")
+	fmt.Fprintf(w, "    -l                  Print only the hashes of matching commits\n")
+	fmt.Fprintf(w, "    -i                  Ignore case\n")
+	fmt.Fprintf(w, "  config set <key> <v>  Set user.name, user.email, defaults.language, defaults.buffer or remote.<name>\n")
+	fmt.Fprintf(w, "  config get|unset <key>, config list\n")
+	fmt.Fprintf(w, "  tui                   Browse history interactively (checkout, tag, replay)\n")
+	fmt.Fprintf(w, "    -n <number>         Number of recent commits to browse (default: 500)\n")
+	fmt.Fprintf(w, "    --buffer <name>     Only show one buffer initially\n")
+	fmt.Fprintf(w, "  serve                 Browse history in a web browser: timeline, diffs, buffers, performances\n")
+	fmt.Fprintf(w, "    --addr <host:port>  Address to listen on (default: localhost:7070)\n")
+	fmt.Fprintf(w, "    --include-private   Show private and redacted commits as recorded\n")
+	fmt.Fprintf(w, "    --sync              Let other machines push and pull (token in $LCG_SYNC_TOKEN)\n")
+	fmt.Fprintf(w, "  push <remote>         Send a remote the commits, tags and performances it's missing\n")
+	fmt.Fprintf(w, "  pull <remote>         Fetch the commits, tags and performances missing here\n")
+	fmt.Fprintf(w, "                        (a URL, or a name set with config set remote.<name> <url>)\n")
+	fmt.Fprintf(w, "  clone <src> [dir]     Copy a repository from a path or a served URL\n")
+	fmt.Fprintf(w, "  export json           Export the full repository as JSON\n")
+	fmt.Fprintf(w, "    -o <file>           Write to a file instead of stdout\n")
+	fmt.Fprintf(w, "    --schema            Print the JSON Schema of the export format\n")
+	fmt.Fprintf(w, "  export csv|parquet    Export one row per commit (timing, buffer, size, diff stats, BPM)\n")
+	fmt.Fprintf(w, "    -o <file>           Write to a file instead of stdout (required for Parquet on a terminal)\n")
+	fmt.Fprintf(w, "  export html|markdown  Write a performance timeline for a blog post ([id|name], default: latest)\n")
+	fmt.Fprintf(w, "    -o <file>           Write to a file instead of stdout\n")
+	fmt.Fprintf(w, "  export bundle         Write a performance as a compact bundle for offline review ([id|name])\n")
+	fmt.Fprintf(w, "  export completions    Rank past code for editor autocompletion (--format json|vscode|words)\n")
+	fmt.Fprintf(w, "    --lang/--author     Only one language or author\n")
+	fmt.Fprintf(w, "    --min-count <n>     Leave out what was written fewer than n times (default: 2)\n")
+	fmt.Fprintf(w, "  export git <dir>      Convert the history into a Git repository, one file per buffer\n")
+	fmt.Fprintf(w, "    --branch <name>     Branch to create (default: main)\n")
+	fmt.Fprintf(w, "    --anonymize         Pseudonymize authors, strip hostnames, user names and paths (all formats)\n")
+	fmt.Fprintf(w, "    --hash-content      Replace code lines with salted hashes, keeping structure (all formats)\n")
+	fmt.Fprintf(w, "    --include-private   Export private and redacted commits as recorded (all formats)\n")
+	fmt.Fprintf(w, "  archive [perf] -o <f> Pack the repository, or one performance, into a .tar.gz or .zip file\n")
+	fmt.Fprintf(w, "    --include-private   Keep private and redacted commits (left out by default)\n")
+	fmt.Fprintf(w, "  archive --extract <f> Restore an archived repository ([directory], default: current)\n")
+	fmt.Fprintf(w, "  privacy [list]        List the buffers and commits that aren't public\n")
+	fmt.Fprintf(w, "  privacy set <level>   Set public, redacted (no code) or private (left out) for exports\n")
+	fmt.Fprintf(w, "    <hash|tag>...       Commits to set, overriding their buffer\n")
+	fmt.Fprintf(w, "    --buffer <name>     Set every commit of a buffer\n")
+	fmt.Fprintf(w, "  import git <path>     Import the history of a Git repository of livecoding files\n")
+	fmt.Fprintf(w, "    --rev <rev>         Revision to import (default: HEAD)\n")
+	fmt.Fprintf(w, "    --performance <n>   Name of the performance spanning the import (default: the directory name)\n")
+	fmt.Fprintf(w, "  timeline merge        Merge ensemble members' repositories into one read-only timeline\n")
+	fmt.Fprintf(w, "    [name=]<repo>...    Repositories to merge, labeled by performer (default: user.name or directory)\n")
+	fmt.Fprintf(w, "    --out <dir>         Directory of the merged repository (required)\n")
+	fmt.Fprintf(w, "    --performance <n>   Name of the merged performance (default: Ensemble)\n")
+	fmt.Fprintf(w, "  status                Show repository and watcher status\n")
+	fmt.Fprintf(w, "  stats                 Commits per buffer and language, success rate and a timeline\n")
+	fmt.Fprintf(w, "    --performance <p>   Only count one performance (ID or name)\n")
+	fmt.Fprintf(w, "    --bucket <d>        Timeline interval, e.g. 5m (default: picked from the span)\n")
+	fmt.Fprintf(w, "    --json              Print the statistics as JSON\n")
+	fmt.Fprintf(w, "  logs                  Show the watcher service journal (.livecodegit/logs)\n")
+	fmt.Fprintf(w, "    -n <number>         Number of entries to show (default: 50)\n")
+	fmt.Fprintf(w, "    --follow, -f        Keep showing new entries\n")
+	fmt.Fprintf(w, "    --level <level>     Only info, warn or error and worse; --event <kind> for one kind\n")
+	fmt.Fprintf(w, "  gc                    Delete objects unreachable from the index, tags, performances, checkpoints and snapshots\n")
+	fmt.Fprintf(w, "    --dry-run           List what would be deleted without deleting it\n")
+	fmt.Fprintf(w, "  repack                Move loose objects and packfiles into a single packfile\n")
+	fmt.Fprintf(w, "  count-objects         Count commits and blobs, and the space shared blobs save\n")
+	fmt.Fprintf(w, "  fsck                  Check objects, parents, the index and HEAD for inconsistencies\n")
+	fmt.Fprintf(w, "    --repair            Fix the index and HEAD to match the objects\n")
+	fmt.Fprintf(w, "  unlock                Show which process holds the repository lock\n")
+	fmt.Fprintf(w, "    --force             Remove a lock left behind by a process that died\n")
+	fmt.Fprintf(w, "  blame <buffer>        Show the commit that introduced each line of a buffer (--json)\n")
+	fmt.Fprintf(w, "  bisect start          Find the execution that broke the sound\n")
+	fmt.Fprintf(w, "    [bad] [good]        Commits around the glitch (default bad: HEAD; good can be marked later)\n")
+	fmt.Fprintf(w, "    --buffer <name>     Only test commits of one buffer\n")
+	fmt.Fprintf(w, "    --out <dir>         Write every buffer as of each candidate into files in dir\n")
+	fmt.Fprintf(w, "    --osc <host:port>   Send every buffer as of each candidate as OSC (--osc-address)\n")
+	fmt.Fprintf(w, "  bisect good|bad [ref] Mark the candidate, or a named commit, as sounding right or wrong\n")
+	fmt.Fprintf(w, "  bisect skip [ref]     Set aside a commit that can't be tested\n")
+	fmt.Fprintf(w, "  bisect status|replay  Show the candidate, or send it to the replay targets again\n")
+	fmt.Fprintf(w, "  bisect reset          End the bisect\n")
+	fmt.Fprintf(w, "  checkpoint save <n>   Save the latest commit of every buffer under a name\n")
+	fmt.Fprintf(w, "  checkpoint restore    Put every buffer back as saved ([name], default: the latest)\n")
+	fmt.Fprintf(w, "    --out <dir>         Write the buffers into files in dir (default: the repository)\n")
+	fmt.Fprintf(w, "    --osc <host:port>   Send the buffers as OSC (buffer, language, code; --osc-address)\n")
+	fmt.Fprintf(w, "  checkpoint [list]     List checkpoints; checkpoint remove <name>\n")
+	fmt.Fprintf(w, "  snapshot              Save the code of every buffer as one snapshot, named by its hash\n")
+	fmt.Fprintf(w, "  snapshot restore      Put every buffer back as snapshotted ([hash], default: the latest)\n")
+	fmt.Fprintf(w, "    --out <dir>         Write the buffers into files in dir (default: the repository)\n")
+	fmt.Fprintf(w, "    --osc <host:port>   Send the buffers as OSC (buffer, language, code; --osc-address)\n")
+	fmt.Fprintf(w, "  snapshot diff <h> [h] Show the buffers changed between snapshots (default: up to now)\n")
+	fmt.Fprintf(w, "  snapshot list         List snapshots\n")
+	fmt.Fprintf(w, "  performance start     Start a performance session (optional name; ends the active one)\n")
+	fmt.Fprintf(w, "  performance end       End the active performance\n")
+	fmt.Fprintf(w, "  performance [list]    List performances with duration and commit counts\n")
+	fmt.Fprintf(w, "  performance show [id] Show a performance by ID or name (default: the active one)\n")
+	fmt.Fprintf(w, "    --template <name>   With start, plan the performance from a template\n")
+	fmt.Fprintf(w, "  performance compare   Compare a templated performance with its plan ([id|name])\n")
+	fmt.Fprintf(w, "  template add <name>   Save a performance template\n")
+	fmt.Fprintf(w, "    --duration <d>      Planned length, e.g. 45m\n")
+	fmt.Fprintf(w, "    --section <l@t>     Planned section, e.g. drop@25m (repeatable)\n")
+	fmt.Fprintf(w, "    --buffer <b:lang>   Expected buffer, e.g. d1:tidal (repeatable)\n")
+	fmt.Fprintf(w, "  template [list]       List templates; template show|remove <name>\n")
+	fmt.Fprintf(w, "  replay <performance>  Replay a performance's commits with their original timing\n")
+	fmt.Fprintf(w, "    --speed <n>         Playback speed (default: 1)\n")
+	fmt.Fprintf(w, "    --max-gap <d>       Shorten pauses longer than d, e.g. 10s\n")
+	fmt.Fprintf(w, "    --out <dir>         Write each commit into buffer files in dir\n")
+	fmt.Fprintf(w, "    --osc <host:port>   Send each commit as OSC (buffer, language, code; --osc-address)\n")
+	fmt.Fprintf(w, "    --quiet             Do not print commits as they are replayed\n")
+	fmt.Fprintf(w, "    --sonicpi           Run each Sonic Pi commit in the running Sonic Pi\n")
+	fmt.Fprintf(w, "  checkout <hash|tag>   Write a commit's code to its buffer file\n")
+	fmt.Fprintf(w, "    --dir <dir>         Directory to write to (default: the repository)\n")
+	fmt.Fprintf(w, "    --run               Also run a Sonic Pi commit in the running Sonic Pi\n")
+	fmt.Fprintf(w, "    --sonicpi-port/--sonicpi-token  Sonic Pi server (default: from Sonic Pi's log)\n")
+	fmt.Fprintf(w, "  pending [list]        List executions the watchers did not commit\n")
+	fmt.Fprintf(w, "  pending show <id>     Show a pending execution\n")
+	fmt.Fprintf(w, "  pending accept <id>.. Commit pending executions (-m <message>, --all)\n")
+	fmt.Fprintf(w, "  pending reject <id>.. Discard pending executions (--all)\n")
+	fmt.Fprintf(w, "  integrate tidal       Generate a BootTidal.hs hook reporting to the tidal-hook watcher\n")
+	fmt.Fprintf(w, "    --boot <path>       Append the hook to a BootTidal.hs file\n")
+	fmt.Fprintf(w, "    --port <port>       Hook port (default: from watcher config)\n")
+	fmt.Fprintf(w, "    --verify            Check the wiring with a test evaluation\n")
+	fmt.Fprintf(w, "    --author <name>     Commit this session's evaluations as this performer\n")
+	fmt.Fprintf(w, "  integrate sonicpi     Write a Sonic Pi init.rb hook reporting each Run to the sonicpi-osc watcher\n")
+	fmt.Fprintf(w, "    --init <path>       init.rb to write (default: detected)\n")
+	fmt.Fprintf(w, "    --print             Print the hook instead of writing it\n")
+	fmt.Fprintf(w, "    --verify            Wait for a Run from Sonic Pi\n")
+	fmt.Fprintf(w, "    --author <name>     Commit this Sonic Pi's Runs as this performer\n")
+	fmt.Fprintf(w, "  integrate emacs       Generate an Emacs hook recording tidal.el evaluations with the emacs watcher\n")
+	fmt.Fprintf(w, "    --init <path>       Append the hook to an Emacs init file\n")
+	fmt.Fprintf(w, "    --transcript <path> Record through a GHCi transcript instead of TCP\n")
+	fmt.Fprintf(w, "  integrate pulsar      Write a GHCi wrapper logging Pulsar's tidalcycles evaluations for the pulsar watcher\n")
+	fmt.Fprintf(w, "    --wrapper <path>    Where to write the wrapper (default: ~/.livecodegit/pulsar-ghci)\n")
+	fmt.Fprintf(w, "    --print             Print the wrapper instead of writing it\n")
+	fmt.Fprintf(w, "  watch                 Start watching for code executions\n")
+	fmt.Fprintf(w, "    --lang <language>   Watch specific language (sonicpi, tidal)\n")
+	fmt.Fprintf(w, "    --list              List available watchers\n")
+	fmt.Fprintf(w, "    --status            Show watcher status, including a detached watcher\n")
+	fmt.Fprintf(w, "    --enable <name>     Enable a watcher\n")
+	fmt.Fprintf(w, "    --disable <name>    Disable a watcher\n")
+	fmt.Fprintf(w, "    --set <w.opt=val>   Set a watcher option (w.author and w.author_email set its commit author)\n")
+	fmt.Fprintf(w, "    --add <name=cmd>    Add a watcher running an external program (language from --lang)\n")
+	fmt.Fprintf(w, "                        or maintenance.<opt> to schedule upkeep while idle, e.g. maintenance.enabled=true\n")
+	fmt.Fprintf(w, "    --test <name>       Run one watcher briefly and show what it would commit\n")
+	fmt.Fprintf(w, "    --wait              With --test, wait for a real execution (--timeout, default 10s)\n")
+	fmt.Fprintf(w, "    --local             Use this repository's own watcher configuration\n")
+	fmt.Fprintf(w, "    --control <port>    Accept OSC control messages (/lcg/mark, /lcg/snapshot, /lcg/checkpoint/restore, ...)\n")
+	fmt.Fprintf(w, "    --repo <path>       Watch several repositories from one process (repeatable)\n")
+	fmt.Fprintf(w, "    --detach            Keep watching in the background (pid in .livecodegit/watch.pid)\n")
+	fmt.Fprintf(w, "    --stop              Stop the watcher started with --detach\n")
+	fmt.Fprintf(w, "  daemon start          Run the watchers in the background (--config, --control <port>)\n")
+	fmt.Fprintf(w, "  daemon stop           Stop the background watchers\n")
+	fmt.Fprintf(w, "  daemon status         Ask the background watchers how they are doing (--json)\n")
+	fmt.Fprintf(w, "  daemon run            Run the daemon in the foreground, e.g. under systemd or launchd\n")
+	fmt.Fprintf(w, "  flush                 Commit the executions the daemon is batching (see batch in the watcher config)\n")
+	fmt.Fprintf(w, "  version               Show version information\n")
+	fmt.Fprintf(w, "  help                  Show this help message\n\n")
+	fmt.Fprintf(w, "Global options:\n")
+	fmt.Fprintf(w, "  --no-color            Disable colored output (also honors NO_COLOR)\n\n")
+	fmt.Fprintf(w, "Examples:\n")
+	fmt.Fprintf(w, "  lcg init                                    # Initialize repository in current directory\n")
+	fmt.Fprintf(w, "  lcg init /path/to/project                   # Initialize repository in specific path\n")
+	fmt.Fprintf(w, "  lcg config set user.name \"Alex McLean\"      # Record who is performing\n")
+	fmt.Fprintf(w, "  lcg commit -m \"Add bass line\" -c \"bass.play\" -l sonicpi\n")
+	fmt.Fprintf(w, "  lcg commit -m \"Rework drums\" -f drums.rb  # Commit a file, language inferred\n")
+	fmt.Fprintf(w, "  pbpaste | lcg commit -m \"Live edit\" -l tidal -  # Commit piped content\n")
+	fmt.Fprintf(w, "  lcg log -n 5                                # Show last 5 commits\n")
+	fmt.Fprintf(w, "  lcg log -n 5 --skip 5                       # Show the 5 before those\n")
+	fmt.Fprintf(w, "  lcg log --lang tidal --failed               # Show Tidal evaluations that errored\n")
+	fmt.Fprintf(w, "  lcg log --format \"{{.Metadata.Buffer}}: {{.Message}}\"  # Build a quick setlist\n")
+	fmt.Fprintf(w, "  lcg search -C 1 tb303                       # Find every use of the tb303 synth\n")
+	fmt.Fprintf(w, "  lcg grep -l 'every \\d+' --buffer d1         # Which d1 versions used every\n")
+	fmt.Fprintf(w, "  lcg tui --buffer d1                         # Scroll back through one buffer after the set\n")
+	fmt.Fprintf(w, "  lcg serve                                   # Project the history of a set during a talk\n")
+	fmt.Fprintf(w, "  lcg push studio                             # Take tonight's set to the studio machine\n")
+	fmt.Fprintf(w, "  lcg export parquet -o set.parquet           # Analyze a set in a notebook\n")
+	fmt.Fprintf(w, "  lcg export git ../algorave-2024             # Publish a set on GitHub\n")
+	fmt.Fprintf(w, "  lcg status                                  # Check everything before going on stage\n")
+	fmt.Fprintf(w, "  lcg performance start \"Algorave 2024\"       # Group tonight's commits into one set\n")
+	fmt.Fprintf(w, "  lcg replay \"Algorave 2024\" --speed 4        # Relive a set in a quarter of the time\n")
+	fmt.Fprintf(w, "  lcg pending accept 3f9c2a1b                 # Keep the one take worth keeping\n")
+	fmt.Fprintf(w, "  lcg integrate tidal --boot BootTidal.hs     # Track every Tidal evaluation from your editor\n")
+	fmt.Fprintf(w, "  lcg watch --lang sonicpi                    # Start watching Sonic Pi executions\n")
+	fmt.Fprintf(w, "  lcg watch --list                            # List available watchers\n")
+	fmt.Fprintf(w, "  lcg watch --enable sonicpi-osc              # Enable Sonic Pi OSC watcher\n")
+	fmt.Fprintf(w, "  lcg watch --test tidal-hook                 # Check a watcher end to end before the gig\n")
+	fmt.Fprintf(w, "  lcg watch --control 9000                    # Drive markers and checkouts from TouchOSC\n")
+	fmt.Fprintf(w, "  lcg watch --repo ~/alice --repo ~/bob       # One process, one repository per performer\n")
+	fmt.Fprintf(w, "  lcg daemon start                            # Keep watching after the terminal closes\n")
 }
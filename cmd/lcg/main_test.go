@@ -1,12 +1,23 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/daemon"
+	"github.com/livecodegit/pkg/osc"
+	"github.com/livecodegit/pkg/remote"
+	"github.com/livecodegit/pkg/watchers"
 )
 
 // Helper function to create a temporary directory for testing
@@ -181,6 +192,142 @@ func TestCLICommit(t *testing.T) {
 	}
 }
 
+func TestCLICommitFromFile(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	_, _, err := runCLI(t, binary, []string{"init"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	// Content with quotes and newlines that would be mangled by a shell argument
+	content := "d1 $ sound \"bd*2 [~ sn]\"\n  # speed \"1 2\"\n"
+	contentPath := filepath.Join(tempDir, "drums.tidal")
+	if err := os.WriteFile(contentPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write content file: %v", err)
+	}
+
+	args := []string{"commit", "-m", "From file", "-f", contentPath}
+	if _, stderr, err := runCLI(t, binary, args, tempDir); err != nil {
+		t.Fatalf("Failed to commit from file: %v (%s)", err, stderr)
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"log"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to run log command: %v", err)
+	}
+
+	if !strings.Contains(stdout, "Language: tidal") {
+		t.Errorf("Expected language to be inferred as tidal, got: %s", stdout)
+	}
+
+	// -c and -f are mutually exclusive
+	args = []string{"commit", "-m", "Both", "-c", "x", "-f", contentPath}
+	if _, _, err := runCLI(t, binary, args, tempDir); err == nil {
+		t.Errorf("Expected error when using -c and -f together")
+	}
+}
+
+func TestCLICommitFromStdin(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	_, _, err := runCLI(t, binary, []string{"init"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	for _, args := range [][]string{
+		{"commit", "-m", "Piped dash", "-l", "tidal", "-"},
+		{"commit", "-m", "Piped flag", "-l", "tidal", "--stdin"},
+		{"commit", "-", "-m", "Dash first", "-l", "tidal"},
+	} {
+		cmd := exec.Command(binary, args...)
+		cmd.Dir = tempDir
+		cmd.Stdin = strings.NewReader("d1 $ sound \"bd sn\"\n")
+
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("Failed to commit from stdin with %v: %v (%s)", args, err, output)
+		}
+
+		if !strings.Contains(string(output), "Created commit") {
+			t.Errorf("Expected commit output, got: %s", output)
+		}
+	}
+
+	// Empty stdin is rejected like missing content
+	cmd := exec.Command(binary, "commit", "-m", "Empty", "--stdin")
+	cmd.Dir = tempDir
+	cmd.Stdin = strings.NewReader("")
+	if output, err := cmd.CombinedOutput(); err == nil {
+		t.Errorf("Expected error for empty stdin content, got: %s", output)
+	}
+
+	// Stray positional arguments are refused rather than ignored
+	cmd = exec.Command(binary, "commit", "-", "extra", "-m", "Stray")
+	cmd.Dir = tempDir
+	cmd.Stdin = strings.NewReader("d1 $ sound \"bd\"\n")
+	output, err := cmd.CombinedOutput()
+	if err == nil || !strings.Contains(string(output), "unexpected argument") {
+		t.Errorf("Expected error for extra arguments, got: %s", output)
+	}
+}
+
+func TestCLICommitAmend(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	if _, stderr, err := runCLI(t, binary, []string{"commit", "--amend", "-m", "Nothing"}, tempDir); err == nil || !strings.Contains(stderr, "no commit to amend") {
+		t.Errorf("Expected amending without commits to fail, got: %s", stderr)
+	}
+
+	args := []string{"commit", "-m", "New basline", "-c", "d1 $ s \"bass\"", "-l", "tidal", "-b", "d1"}
+	if _, _, err := runCLI(t, binary, args, tempDir); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"commit", "--amend", "-m", "New bassline"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "Amended commit") {
+		t.Fatalf("Failed to amend: %s (%v)", stdout, err)
+	}
+
+	stdout, _, _ = runCLI(t, binary, []string{"log", "--json"}, tempDir)
+	var commits []core.Commit
+	if err := json.Unmarshal([]byte(stdout), &commits); err != nil {
+		t.Fatalf("Failed to parse log: %v", err)
+	}
+	if len(commits) != 1 {
+		t.Fatalf("Expected the commit to be replaced, got %d commits", len(commits))
+	}
+	amended := commits[0]
+	if amended.Message != "New bassline" || amended.Content != "d1 $ s \"bass\"" ||
+		amended.Metadata.Language != "tidal" || amended.Metadata.Buffer != "d1" {
+		t.Errorf("Expected only the message to change, got %+v", amended)
+	}
+
+	// Content re-captured from a file keeps the message
+	path := filepath.Join(tempDir, "bass.tidal")
+	if err := os.WriteFile(path, []byte("d1 $ s \"bass*2\""), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, _, err := runCLI(t, binary, []string{"commit", "--amend", "-f", path}, tempDir); err != nil {
+		t.Fatalf("Failed to amend from file: %v", err)
+	}
+	stdout, _, _ = runCLI(t, binary, []string{"log", "--format", "{{.Message}}|{{.Content}}\n"}, tempDir)
+	if strings.TrimSpace(stdout) != "New bassline|d1 $ s \"bass*2\"" {
+		t.Errorf("Expected re-captured content under the same message, got: %s", stdout)
+	}
+}
+
 func TestCLICommitWithoutRepo(t *testing.T) {
 	binary := buildCLI(t)
 	tempDir := createTempDir(t)
@@ -371,6 +518,170 @@ func TestCLILogWithLimit(t *testing.T) {
 	if strings.Contains(stdout, "Commit 1") {
 		t.Errorf("Should not contain oldest commit (Commit 1) in limited log")
 	}
+
+	// The next page
+	stdout, _, err = runCLI(t, binary, []string{"log", "-n", "3", "--skip", "3"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to run log command with skip: %v", err)
+	}
+	if strings.Count(stdout, "commit ") != 2 || !strings.Contains(stdout, "Commit 2") || strings.Contains(stdout, "Commit 3") {
+		t.Errorf("Expected commits 2 and 1 on the next page, got:\n%s", stdout)
+	}
+}
+
+func TestCLILogJSON(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	_, _, err := runCLI(t, binary, []string{"init"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	// Empty history is an empty array, not "No commits found"
+	stdout, _, err := runCLI(t, binary, []string{"log", "--json"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to run log --json: %v", err)
+	}
+	if strings.TrimSpace(stdout) != "[]" {
+		t.Errorf("Expected empty JSON array, got: %s", stdout)
+	}
+
+	for i := 1; i <= 2; i++ {
+		args := []string{"commit", "-m", fmt.Sprintf("Commit %d", i), "-c", "d1 $ s \"bd\"", "-l", "tidal", "-b", "d1"}
+		if _, _, err := runCLI(t, binary, args, tempDir); err != nil {
+			t.Fatalf("Failed to create commit %d: %v", i, err)
+		}
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"log", "--json"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to run log --json: %v", err)
+	}
+
+	var commits []struct {
+		Hash      string `json:"hash"`
+		Parent    string `json:"parent"`
+		Timestamp string `json:"timestamp"`
+		Message   string `json:"message"`
+		Metadata  struct {
+			Buffer   string `json:"buffer"`
+			Language string `json:"language"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &commits); err != nil {
+		t.Fatalf("Expected valid JSON output, got %v: %s", err, stdout)
+	}
+
+	if len(commits) != 2 {
+		t.Fatalf("Expected 2 commits, got %d", len(commits))
+	}
+
+	if commits[0].Message != "Commit 2" || commits[0].Parent != commits[1].Hash {
+		t.Errorf("Expected newest commit first with its parent linked, got %+v", commits[0])
+	}
+
+	if commits[0].Metadata.Language != "tidal" || commits[0].Metadata.Buffer != "d1" {
+		t.Errorf("Expected metadata to be included, got %+v", commits[0].Metadata)
+	}
+
+	if commits[1].Timestamp == "" {
+		t.Errorf("Expected timestamp to be included")
+	}
+}
+
+func TestCLILogFilters(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	_, _, err := runCLI(t, binary, []string{"init"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	commits := [][]string{
+		{"commit", "-m", "Kick", "-c", "sample :bd_haus", "-l", "sonicpi", "-b", "drums"},
+		{"commit", "-m", "Pattern", "-c", "d1 $ s \"bd\"", "-l", "tidal", "-b", "d1"},
+	}
+	for _, args := range commits {
+		if _, _, err := runCLI(t, binary, args, tempDir); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"log", "--lang", "tidal"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to run filtered log: %v", err)
+	}
+	if !strings.Contains(stdout, "Pattern") || strings.Contains(stdout, "Kick") {
+		t.Errorf("Expected only the tidal commit, got: %s", stdout)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"log", "--buffer", "drums", "--success"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to run filtered log: %v", err)
+	}
+	if !strings.Contains(stdout, "Kick") || strings.Contains(stdout, "Pattern") {
+		t.Errorf("Expected only the drums commit, got: %s", stdout)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"log", "--failed"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to run filtered log: %v", err)
+	}
+	if !strings.Contains(stdout, "No commits found") {
+		t.Errorf("Expected no failed commits, got: %s", stdout)
+	}
+
+	_, _, err = runCLI(t, binary, []string{"log", "--failed", "--success"}, tempDir)
+	if err == nil {
+		t.Errorf("Expected error when combining --failed and --success")
+	}
+}
+
+func TestCLILogFormat(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	_, _, err := runCLI(t, binary, []string{"init"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	commits := [][]string{
+		{"commit", "-m", "Intro", "-c", "d1 $ s \"bd\"", "-l", "tidal", "-b", "d1"},
+		{"commit", "-m", "Drop", "-c", "d2 $ s \"hh*8\"", "-l", "tidal", "-b", "d2"},
+	}
+	for _, args := range commits {
+		if _, _, err := runCLI(t, binary, args, tempDir); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"log", "--format", "{{.Metadata.Buffer}}: {{.Message}}"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to run log --format: %v", err)
+	}
+	if stdout != "d2: Drop\nd1: Intro\n" {
+		t.Errorf("Expected one formatted line per commit, got: %q", stdout)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"log", "--oneline"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to run log --oneline: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "[d2] Drop") {
+		t.Errorf("Expected 2 oneline entries, got: %s", stdout)
+	}
+
+	_, _, err = runCLI(t, binary, []string{"log", "--format", "{{.Message"}, tempDir)
+	if err == nil {
+		t.Errorf("Expected error for invalid template")
+	}
 }
 
 func TestCLILogEmptyRepository(t *testing.T) {
@@ -411,6 +722,95 @@ func TestCLILogWithoutRepo(t *testing.T) {
 	}
 }
 
+func TestCLIStatus(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	configPath := filepath.Join(tempDir, "watchers.json")
+
+	// Initialize repository
+	_, _, err := runCLI(t, binary, []string{"init"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"status", "-config", configPath}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to run status command: %v", err)
+	}
+
+	expected := []string{
+		"HEAD: (no commits)",
+		"Active Performance: none",
+		"Enabled: none",
+		"Service: not running",
+	}
+	for _, e := range expected {
+		if !strings.Contains(stdout, e) {
+			t.Errorf("Expected status to contain '%s', got: %s", e, stdout)
+		}
+	}
+
+	// Commit and check HEAD is reported
+	args := []string{"commit", "-m", "Drop the kick", "-c", "sample :bd_haus", "-l", "sonicpi"}
+	if _, _, err := runCLI(t, binary, args, tempDir); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"status", "-config", configPath}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to run status command: %v", err)
+	}
+
+	if !strings.Contains(stdout, "Drop the kick") || !strings.Contains(stdout, "Last Commit:") {
+		t.Errorf("Expected status to show HEAD commit, got: %s", stdout)
+	}
+}
+
+func TestCLISearch(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	_, _, err := runCLI(t, binary, []string{"init"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	commits := [][]string{
+		{"commit", "-m", "Acid", "-c", "use_synth :tb303\nplay :e1", "-l", "sonicpi", "-b", "bass"},
+		{"commit", "-m", "Kick", "-c", "sample :bd_haus", "-l", "sonicpi", "-b", "drums"},
+	}
+	for _, args := range commits {
+		if _, _, err := runCLI(t, binary, args, tempDir); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"search", "tb303"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to run search command: %v", err)
+	}
+
+	if !strings.Contains(stdout, "[bass]") || !strings.Contains(stdout, "1: use_synth :tb303") {
+		t.Errorf("Expected search to show the bass match, got: %s", stdout)
+	}
+
+	if strings.Contains(stdout, "Kick") {
+		t.Errorf("Expected search not to show the drums commit, got: %s", stdout)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"search", "--buffer", "drums", "tb303"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to run filtered search command: %v", err)
+	}
+
+	if !strings.Contains(stdout, "No matches found") {
+		t.Errorf("Expected no matches with buffer filter, got: %s", stdout)
+	}
+}
+
 func TestCLIUnknownCommand(t *testing.T) {
 	binary := buildCLI(t)
 
@@ -437,3 +837,1356 @@ func TestCLINoCommand(t *testing.T) {
 		t.Errorf("Expected usage information when no command provided, got: %s", stderr)
 	}
 }
+
+func TestConfigureColor(t *testing.T) {
+	defer func() { colorEnabled = false }()
+
+	args := configureColor([]string{"log", "--no-color", "-n", "5"})
+	if strings.Join(args, " ") != "log -n 5" {
+		t.Errorf("Expected --no-color to be removed, got %v", args)
+	}
+	if colorEnabled {
+		t.Errorf("Expected color to be disabled by --no-color")
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	configureColor([]string{"log"})
+	if colorEnabled {
+		t.Errorf("Expected color to be disabled by NO_COLOR")
+	}
+
+	if paint(ansiRed, "error") != "error" {
+		t.Errorf("Expected plain text when color is disabled")
+	}
+
+	colorEnabled = true
+	if colorResult(false, "error") != ansiRed+"error"+ansiReset {
+		t.Errorf("Expected red error text when color is enabled, got %q", colorResult(false, "error"))
+	}
+	if padRight("[d1]", 7) != "[d1]   " {
+		t.Errorf("Expected padded column, got %q", padRight("[d1]", 7))
+	}
+}
+
+func TestCLINoColorWhenPiped(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	if _, _, err := runCLI(t, binary, []string{"commit", "-m", "Kick", "-c", "sample :bd_haus", "-l", "sonicpi"}, tempDir); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	for _, args := range [][]string{{"log"}, {"--no-color", "log"}} {
+		stdout, _, err := runCLI(t, binary, args, tempDir)
+		if err != nil {
+			t.Fatalf("Failed to run %v: %v", args, err)
+		}
+		if strings.Contains(stdout, "\x1b[") {
+			t.Errorf("Expected no escape sequences in piped output of %v, got: %q", args, stdout)
+		}
+		if !strings.Contains(stdout, "Language: sonicpi") {
+			t.Errorf("Expected log output for %v, got: %s", args, stdout)
+		}
+	}
+}
+
+func TestCLIPending(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	_, _, err := runCLI(t, binary, []string{"init"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"pending"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to run pending: %v", err)
+	}
+	if !strings.Contains(stdout, "No pending events") {
+		t.Errorf("Expected empty inbox, got: %s", stdout)
+	}
+
+	// Seed the inbox the way a watcher with auto-commit off would
+	store := watchers.NewPendingStore(tempDir)
+	keep, err := store.Add(watchers.ExecutionEvent{Content: "d1 $ s \"bd*2\"", Buffer: "d1", Language: "tidal", Success: true}, "Tidal execution in d1")
+	if err != nil {
+		t.Fatalf("Failed to add pending event: %v", err)
+	}
+	drop, err := store.Add(watchers.ExecutionEvent{Content: "d1 $ s \"oops", Buffer: "d1", Language: "tidal"}, "Tidal execution in d1")
+	if err != nil {
+		t.Fatalf("Failed to add pending event: %v", err)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"pending", "list"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to run pending list: %v", err)
+	}
+	if !strings.Contains(stdout, keep.ID) || !strings.Contains(stdout, drop.ID) {
+		t.Errorf("Expected both pending events to be listed, got: %s", stdout)
+	}
+
+	// Accepting needs IDs or --all
+	if _, _, err := runCLI(t, binary, []string{"pending", "accept"}, tempDir); err == nil {
+		t.Errorf("Expected pending accept without IDs to fail")
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"pending", "accept", "-m", "Keeper", keep.ID[:6]}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to accept pending event: %v", err)
+	}
+	if !strings.Contains(stdout, "Accepted "+keep.ID) {
+		t.Errorf("Expected accept confirmation, got: %s", stdout)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"pending", "reject", drop.ID}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to reject pending event: %v", err)
+	}
+	if !strings.Contains(stdout, "Rejected "+drop.ID) {
+		t.Errorf("Expected reject confirmation, got: %s", stdout)
+	}
+
+	// Only the accepted event becomes a commit
+	stdout, _, err = runCLI(t, binary, []string{"log", "--json"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to run log --json: %v", err)
+	}
+
+	var commits []struct {
+		Message string `json:"message"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &commits); err != nil {
+		t.Fatalf("Failed to parse log JSON: %v", err)
+	}
+	if len(commits) != 1 || commits[0].Message != "Keeper" || commits[0].Content != "d1 $ s \"bd*2\"" {
+		t.Errorf("Expected only the accepted event to be committed, got: %+v", commits)
+	}
+
+	count, err := store.Count()
+	if err != nil {
+		t.Fatalf("Failed to count pending events: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected empty inbox after review, got %d events", count)
+	}
+}
+
+func TestCLIConfig(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	for _, args := range [][]string{
+		{"config", "set", "user.name", "Alex McLean"},
+		{"config", "set", "user.email", "alex@example.com"},
+		{"config", "set", "defaults.language", "tidal"},
+		{"config", "set", "defaults.buffer", "d1"},
+	} {
+		if _, stderr, err := runCLI(t, binary, args, tempDir); err != nil {
+			t.Fatalf("Failed to run %v: %v (%s)", args, err, stderr)
+		}
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"config", "get", "user.name"}, tempDir)
+	if err != nil || strings.TrimSpace(stdout) != "Alex McLean" {
+		t.Errorf("Expected user.name 'Alex McLean', got '%s' (%v)", stdout, err)
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"config", "set", "user.nick", "x"}, tempDir); err == nil {
+		t.Errorf("Expected unknown key to fail")
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"commit", "-m", "Kick", "-c", "d1 $ s \"bd\""}, tempDir); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"log"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to run log: %v", err)
+	}
+	for _, expected := range []string{"Author: Alex McLean <alex@example.com>", "Language: tidal", "Buffer: d1"} {
+		if !strings.Contains(stdout, expected) {
+			t.Errorf("Expected log to contain '%s', got: %s", expected, stdout)
+		}
+	}
+}
+
+func TestCLIPerformance(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"performance", "end"}, tempDir); err == nil {
+		t.Errorf("Expected ending without an active performance to fail")
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"performance", "start", "Algorave 2024"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+	if !strings.Contains(stdout, "Started performance Algorave 2024") {
+		t.Errorf("Expected start confirmation, got: %s", stdout)
+	}
+
+	for _, message := range []string{"Kick", "Bass"} {
+		if _, _, err := runCLI(t, binary, []string{"commit", "-m", message, "-c", "d1 $ s \"bd\"", "-l", "tidal", "-b", "d1"}, tempDir); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"performance", "end"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to end performance: %v", err)
+	}
+	if !strings.Contains(stdout, "2 commits") {
+		t.Errorf("Expected end summary with 2 commits, got: %s", stdout)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"performance", "list"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to list performances: %v", err)
+	}
+	if !strings.Contains(stdout, "Algorave 2024") || !strings.Contains(stdout, "2 commits") || strings.Contains(stdout, "active") {
+		t.Errorf("Expected one ended performance with 2 commits, got: %s", stdout)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"performance", "show", "Algorave 2024"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to show performance: %v", err)
+	}
+	for _, expected := range []string{"Name: Algorave 2024", "Commits: 2", "d1", "Kick", "Bass"} {
+		if !strings.Contains(stdout, expected) {
+			t.Errorf("Expected show output to contain '%s', got: %s", expected, stdout)
+		}
+	}
+}
+
+func TestCLICheckoutRun(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	// Stands in for the Sonic Pi server
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer server.Close()
+	port := strconv.Itoa(server.LocalAddr().(*net.UDPAddr).Port)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	for _, args := range [][]string{
+		{"commit", "-m", "Drums", "-c", "sample :bd_haus", "-l", "sonicpi", "-b", "drums"},
+		{"commit", "-m", "Kick", "-c", "d1 $ s \"bd\"", "-l", "tidal", "-b", "d1"},
+	} {
+		if _, _, err := runCLI(t, binary, args, tempDir); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+	hashes, _, err := runCLI(t, binary, []string{"log", "--format", "{{.Hash}}"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to list commits: %v", err)
+	}
+	lines := strings.Fields(hashes)
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 commits, got: %s", hashes)
+	}
+	tidal, sonicPi := lines[0], lines[1]
+
+	if _, _, err := runCLI(t, binary, []string{"checkout", tidal, "--run", "--sonicpi-port", port}, tempDir); err == nil {
+		t.Errorf("Expected running a Tidal commit in Sonic Pi to fail")
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"checkout", sonicPi[:8], "--run", "--sonicpi-port", port, "--sonicpi-token", "7"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to check out: %v", err)
+	}
+	if !strings.Contains(stdout, "drums.rb") || !strings.Contains(stdout, "Sent") {
+		t.Errorf("Expected the checkout and the run, got: %s", stdout)
+	}
+	if data, err := os.ReadFile(filepath.Join(tempDir, "drums.rb")); err != nil || string(data) != "sample :bd_haus" {
+		t.Errorf("Expected the buffer file, got '%s' (%v)", data, err)
+	}
+
+	buffer := make([]byte, 65536)
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := server.Read(buffer)
+	if err != nil {
+		t.Fatalf("Expected the code to reach Sonic Pi: %v", err)
+	}
+	messages, err := osc.Parse(buffer[:n])
+	if err != nil || len(messages) != 1 || messages[0].Address != "/run-code" || messages[0].Args[0] != int32(7) || messages[0].Args[1] != "sample :bd_haus" {
+		t.Errorf("Expected /run-code with the token and code, got %v (%v)", messages, err)
+	}
+}
+
+func TestCLIReplay(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	if _, _, err := runCLI(t, binary, []string{"performance", "start", "Gig"}, tempDir); err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+	for _, args := range [][]string{
+		{"commit", "-m", "Kick", "-c", "d1 $ s \"bd\"", "-l", "tidal", "-b", "d1"},
+		{"commit", "-m", "Hats", "-c", "d2 $ s \"hh*8\"", "-l", "tidal", "-b", "d2"},
+	} {
+		if _, _, err := runCLI(t, binary, args, tempDir); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"replay"}, tempDir); err == nil {
+		t.Errorf("Expected replay without a performance to fail")
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"replay", "Gig", "--speed", "100", "--out", "buffers"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to replay: %v", err)
+	}
+	for _, expected := range []string{"Replaying Gig: 2 commits", "+00:00", "[d1] Kick", "[d2] Hats", "hh*8"} {
+		if !strings.Contains(stdout, expected) {
+			t.Errorf("Expected replay output to contain '%s', got: %s", expected, stdout)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(tempDir, "buffers", "d2.tidal"))
+	if err != nil {
+		t.Fatalf("Failed to read replayed buffer: %v", err)
+	}
+	if string(data) != "d2 $ s \"hh*8\"" {
+		t.Errorf("Expected replayed buffer d2, got '%s'", data)
+	}
+}
+
+func TestCLIExportGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	for _, message := range []string{"Kick", "Snare"} {
+		if _, _, err := runCLI(t, binary, []string{"commit", "-m", message, "-c", "d1 $ s \"" + strings.ToLower(message) + "\"", "-l", "tidal", "-b", "d1"}, tempDir); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+
+	target := filepath.Join(tempDir, "published")
+	stdout, _, err := runCLI(t, binary, []string{"export", "git", target, "--branch", "set"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	if !strings.Contains(stdout, "Exported 2 commits") {
+		t.Errorf("Expected export summary, got: %s", stdout)
+	}
+
+	data, err := os.ReadFile(filepath.Join(target, "d1.tidal"))
+	if err != nil {
+		t.Fatalf("Failed to read checked out buffer: %v", err)
+	}
+	if string(data) != "d1 $ s \"snare\"" {
+		t.Errorf("Expected latest d1 code, got '%s'", data)
+	}
+
+	// An existing export is never overwritten
+	if _, _, err := runCLI(t, binary, []string{"export", "git", target}, tempDir); err == nil {
+		t.Errorf("Expected export into a non-empty directory to fail")
+	}
+}
+
+func TestCLIImportGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	source := filepath.Join(tempDir, "source")
+	if err := os.Mkdir(source, 0755); err != nil {
+		t.Fatalf("Failed to create source repository: %v", err)
+	}
+	if _, _, err := runCLI(t, binary, []string{"init"}, source); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	for _, message := range []string{"Kick", "Snare"} {
+		if _, _, err := runCLI(t, binary, []string{"commit", "-m", message, "-c", "d1 $ s \"" + strings.ToLower(message) + "\"", "-l", "tidal", "-b", "d1"}, source); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+	archive := filepath.Join(tempDir, "archive")
+	if _, _, err := runCLI(t, binary, []string{"export", "git", archive}, source); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+
+	target := filepath.Join(tempDir, "target")
+	if err := os.Mkdir(target, 0755); err != nil {
+		t.Fatalf("Failed to create target repository: %v", err)
+	}
+	if _, _, err := runCLI(t, binary, []string{"init"}, target); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"import", "git", archive, "--performance", "Archived set"}, target)
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if !strings.Contains(stdout, "Imported 2 commits across 1 buffers") {
+		t.Errorf("Expected import summary, got: %s", stdout)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"performance", "show", "Archived set"}, target)
+	if err != nil {
+		t.Fatalf("Failed to show imported performance: %v", err)
+	}
+	if !strings.Contains(stdout, "Kick") || !strings.Contains(stdout, "Snare") {
+		t.Errorf("Expected imported commits in performance, got: %s", stdout)
+	}
+
+	// Importing twice would put old commits after new ones
+	if _, _, err := runCLI(t, binary, []string{"import", "git", archive}, target); err == nil {
+		t.Errorf("Expected import into a repository with commits to fail")
+	}
+}
+
+func TestCLIGC(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	if _, _, err := runCLI(t, binary, []string{"commit", "-m", "Kick", "-c", "d1 $ s \"bd\""}, tempDir); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	orphan := filepath.Join(tempDir, ".livecodegit", "objects", "ff", strings.Repeat("0", 38))
+	if err := os.MkdirAll(filepath.Dir(orphan), 0755); err != nil {
+		t.Fatalf("Failed to create object directory: %v", err)
+	}
+	if err := os.WriteFile(orphan, []byte("{\"message\": \"partial"), 0644); err != nil {
+		t.Fatalf("Failed to write orphan: %v", err)
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"gc", "--dry-run"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to run gc --dry-run: %v", err)
+	}
+	if !strings.Contains(stdout, "ff000000") || !strings.Contains(stdout, "Would remove 1 unreachable objects") {
+		t.Errorf("Expected dry-run report of the orphan, got: %s", stdout)
+	}
+	if _, err := os.Stat(orphan); err != nil {
+		t.Fatalf("Expected dry run to keep the orphan: %v", err)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"gc"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to run gc: %v", err)
+	}
+	if !strings.Contains(stdout, "Removed 1 unreachable objects") {
+		t.Errorf("Expected removal summary, got: %s", stdout)
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Errorf("Expected orphan to be deleted")
+	}
+
+	stdout, _, _ = runCLI(t, binary, []string{"log"}, tempDir)
+	if !strings.Contains(stdout, "Kick") {
+		t.Errorf("Expected reachable commit to survive gc, got: %s", stdout)
+	}
+}
+
+func TestCLIFsck(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	if _, _, err := runCLI(t, binary, []string{"commit", "-m", "Kick", "-c", "d1 $ s \"bd\""}, tempDir); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"fsck"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "no problems found") {
+		t.Fatalf("Expected a clean repository, got: %s (%v)", stdout, err)
+	}
+
+	// A crash between writing HEAD and the index
+	head := filepath.Join(tempDir, ".livecodegit", "HEAD")
+	if err := os.WriteFile(head, []byte(strings.Repeat("0", 40)), 0644); err != nil {
+		t.Fatalf("Failed to write HEAD: %v", err)
+	}
+
+	stdout, stderr, err := runCLI(t, binary, []string{"fsck"}, tempDir)
+	if err == nil {
+		t.Errorf("Expected fsck to fail while problems remain")
+	}
+	if !strings.Contains(stdout, "bad-head") || !strings.Contains(stderr, "--repair") {
+		t.Errorf("Expected a bad HEAD and a repair hint, got: %s %s", stdout, stderr)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"fsck", "--repair"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "repaired 1") {
+		t.Errorf("Expected HEAD to be repaired, got: %s (%v)", stdout, err)
+	}
+	if _, _, err := runCLI(t, binary, []string{"fsck"}, tempDir); err != nil {
+		t.Errorf("Expected a clean repository after repair: %v", err)
+	}
+}
+
+func TestCLIBlame(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	for _, content := range []string{"d1 $ s \"bd\"\n# kick", "d1 $ s \"bd sn\"\n# kick"} {
+		if _, _, err := runCLI(t, binary, []string{"commit", "-m", "Drums", "-c", content, "-l", "tidal", "-b", "drums"}, tempDir); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+	hashes, _, err := runCLI(t, binary, []string{"log", "--format", "{{short .Hash}}"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	latest, first := strings.Fields(hashes)[0], strings.Fields(hashes)[1]
+
+	stdout, _, err := runCLI(t, binary, []string{"blame", "--buffer", "drums"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to blame: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], latest) || !strings.HasPrefix(lines[1], first) {
+		t.Errorf("Expected line 1 from %s and line 2 from %s, got:\n%s", latest, first, stdout)
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"blame", "bass"}, tempDir); err == nil {
+		t.Errorf("Expected blaming an unknown buffer to fail")
+	}
+}
+
+func TestCLIBisect(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	for _, pattern := range []string{"bd", "bd sn", "bd*2 sn"} {
+		if _, _, err := runCLI(t, binary, []string{"commit", "-m", pattern, "-c", "d1 $ s \"" + pattern + "\"", "-l", "tidal", "-b", "d1"}, tempDir); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+	first, _, err := runCLI(t, binary, []string{"log", "-n", "3", "--format", "{{.Hash}}"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	lines := strings.Fields(first)
+	good := lines[len(lines)-1]
+
+	live := filepath.Join(tempDir, "live")
+	stdout, _, err := runCLI(t, binary, []string{"bisect", "start", "--out", live}, tempDir)
+	if err != nil || !strings.Contains(stdout, "lcg bisect good") {
+		t.Fatalf("Expected to be asked for a good commit, got: %s (%v)", stdout, err)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"bisect", "good", good}, tempDir)
+	if err != nil || !strings.Contains(stdout, "bd sn") || !strings.Contains(stdout, "Replayed 1 buffer ") {
+		t.Fatalf("Expected the middle commit to be replayed, got: %s (%v)", stdout, err)
+	}
+	data, err := os.ReadFile(filepath.Join(live, "d1.tidal"))
+	if err != nil || string(data) != "d1 $ s \"bd sn\"" {
+		t.Errorf("Expected the candidate in live/d1.tidal, got '%s' (%v)", string(data), err)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"bisect", "bad"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "First bad commit") || !strings.Contains(stdout, "bd sn") {
+		t.Errorf("Expected the middle commit as the culprit, got: %s (%v)", stdout, err)
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"bisect", "reset"}, tempDir); err != nil {
+		t.Errorf("Failed to reset bisect: %v", err)
+	}
+	if _, _, err := runCLI(t, binary, []string{"bisect", "status"}, tempDir); err == nil {
+		t.Errorf("Expected no bisect in progress after reset")
+	}
+}
+
+func TestCLIPrivacy(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	for _, commit := range [][]string{{"d1", "d1 $ s \"bd\""}, {"scratch", "secret sketch"}} {
+		if _, _, err := runCLI(t, binary, []string{"commit", "-m", commit[0], "-c", commit[1], "-l", "tidal", "-b", commit[0]}, tempDir); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"privacy", "set", "private", "--buffer", "scratch"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "scratch is private") {
+		t.Fatalf("Expected scratch to be private, got: %s (%v)", stdout, err)
+	}
+	if _, _, err := runCLI(t, binary, []string{"privacy", "set", "secret", "--buffer", "scratch"}, tempDir); err == nil {
+		t.Errorf("Expected an unknown level to fail")
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"export", "json"}, tempDir)
+	if err != nil || strings.Contains(stdout, "secret sketch") || !strings.Contains(stdout, "d1 $ s") {
+		t.Errorf("Expected the private commit to be left out, got: %s (%v)", stdout, err)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"export", "csv", "--include-private"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "scratch") {
+		t.Errorf("Expected --include-private to export everything, got: %s (%v)", stdout, err)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"privacy"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "scratch") {
+		t.Errorf("Expected the private buffer to be listed, got: %s (%v)", stdout, err)
+	}
+}
+
+func TestCLIGrep(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	commits := [][]string{
+		{"d1", "d1 $ s \"bd\""},
+		{"d1", "d1 $ every 3 (fast 2) $ s \"bd sn\""},
+		{"d2", "d2 $ every 4 rev $ s \"hh\""},
+	}
+	for _, commit := range commits {
+		if _, _, err := runCLI(t, binary, []string{"commit", "-m", "Pattern", "-c", commit[1], "-l", "tidal", "-b", commit[0]}, tempDir); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"grep", `every \d`}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to grep: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], ":1:d2 $ every 4") || !strings.Contains(lines[1], ":1:d1 $ every 3") {
+		t.Errorf("Expected the d2 match before the d1 match, got:\n%s", stdout)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"grep", "-l", "EVERY", "-i", "--buffer", "d1"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to grep: %v", err)
+	}
+	if hashes := strings.Fields(stdout); len(hashes) != 1 || len(hashes[0]) != 64 {
+		t.Errorf("Expected the full hash of one d1 commit, got: %s", stdout)
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"grep", "superdirt"}, tempDir); err == nil {
+		t.Errorf("Expected no match to exit with an error")
+	}
+}
+
+func TestCLIArchive(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	for _, buffer := range []string{"d1", "d2", "scratch"} {
+		if _, _, err := runCLI(t, binary, []string{"commit", "-m", buffer, "-c", buffer + " $ s \"bd\"", "-l", "tidal", "-b", buffer}, tempDir); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+	if _, _, err := runCLI(t, binary, []string{"privacy", "set", "redacted", "--buffer", "scratch"}, tempDir); err != nil {
+		t.Fatalf("Failed to set privacy: %v", err)
+	}
+
+	archivePath := filepath.Join(tempDir, "set.zip")
+	stdout, _, err := runCLI(t, binary, []string{"archive", "-o", archivePath}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to archive: %v", err)
+	}
+	if !strings.Contains(stdout, "Archived 2 commits") {
+		t.Errorf("Expected the redacted commit to be left out, got: %s", stdout)
+	}
+
+	restored := filepath.Join(tempDir, "restored")
+	if err := os.Mkdir(restored, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if _, _, err := runCLI(t, binary, []string{"archive", "--extract", archivePath}, restored); err != nil {
+		t.Fatalf("Failed to extract: %v", err)
+	}
+	stdout, _, err = runCLI(t, binary, []string{"log", "--oneline"}, restored)
+	if err != nil {
+		t.Fatalf("Failed to read restored log: %v", err)
+	}
+	if !strings.Contains(stdout, "[d2]") || strings.Contains(stdout, "scratch") {
+		t.Errorf("Expected d1 and d2 without scratch, got: %s", stdout)
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"archive", "-o", filepath.Join(tempDir, "set.rar")}, tempDir); err == nil {
+		t.Errorf("Expected an unknown archive format to fail")
+	}
+}
+
+func TestCLICheckpoint(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	for _, buffer := range []string{"d1", "d2"} {
+		if _, _, err := runCLI(t, binary, []string{"commit", "-m", buffer, "-c", buffer + " $ s \"bd\"", "-l", "tidal", "-b", buffer}, tempDir); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"checkpoint", "save", "safe"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "Saved checkpoint safe (2 buffers)") {
+		t.Fatalf("Expected checkpoint of 2 buffers, got: %s (%v)", stdout, err)
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"commit", "-m", "Risky", "-c", "d1 $ s \"bd*16\" # crush 2", "-l", "tidal", "-b", "d1"}, tempDir); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"checkpoint", "restore"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "Restored checkpoint safe: 2 buffers") {
+		t.Fatalf("Expected the latest checkpoint to be restored, got: %s (%v)", stdout, err)
+	}
+	data, err := os.ReadFile(filepath.Join(tempDir, "d1.tidal"))
+	if err != nil || string(data) != "d1 $ s \"bd\"" {
+		t.Errorf("Expected d1.tidal as of the checkpoint, got '%s' (%v)", string(data), err)
+	}
+
+	outDir := filepath.Join(tempDir, "live")
+	if _, _, err := runCLI(t, binary, []string{"checkpoint", "restore", "safe", "--out", outDir}, tempDir); err != nil {
+		t.Fatalf("Failed to restore checkpoint: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "d2.tidal")); err != nil {
+		t.Errorf("Expected d2.tidal in --out directory: %v", err)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"checkpoint", "list"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "safe") || !strings.Contains(stdout, "d1, d2") {
+		t.Errorf("Expected checkpoint safe with d1 and d2 listed, got: %s (%v)", stdout, err)
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"checkpoint", "remove", "safe"}, tempDir); err != nil {
+		t.Fatalf("Failed to remove checkpoint: %v", err)
+	}
+	if _, _, err := runCLI(t, binary, []string{"checkpoint", "restore", "safe"}, tempDir); err == nil {
+		t.Errorf("Expected restoring a removed checkpoint to fail")
+	}
+}
+
+func TestCLIDaemon(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"daemon", "status"}, tempDir)
+	if err == nil || !strings.Contains(stdout, "not running") {
+		t.Errorf("Expected no daemon yet, got: %s (%v)", stdout, err)
+	}
+
+	// Only the control surface, on a free port, so no watcher needs enabling
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	port := strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)
+	conn.Close()
+
+	configPath := filepath.Join(tempDir, "watchers.json")
+	stdout, stderr, err := runCLI(t, binary, []string{"daemon", "start", "--config", configPath, "--control", port}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to start daemon: %v\n%s", err, stderr)
+	}
+	defer runCLI(t, binary, []string{"daemon", "stop"}, tempDir)
+	if !strings.Contains(stdout, "Daemon started") {
+		t.Errorf("Expected daemon to start, got: %s", stdout)
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"daemon", "start", "--config", configPath}, tempDir); err == nil {
+		t.Errorf("Expected a second daemon to be refused")
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"daemon", "status"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "Daemon: running") || !strings.Contains(stdout, "OSC Control: UDP port "+port) {
+		t.Errorf("Expected running daemon with control port, got: %s (%v)", stdout, err)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"status"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "Daemon: "+daemon.SocketPath(tempDir)) {
+		t.Errorf("Expected lcg status to show the daemon, got: %s (%v)", stdout, err)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"daemon", "stop"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "Daemon stopped") {
+		t.Fatalf("Expected daemon to stop, got: %s (%v)", stdout, err)
+	}
+	if _, err := os.Stat(daemon.SocketPath(tempDir)); !os.IsNotExist(err) {
+		t.Errorf("Expected the socket to be removed")
+	}
+	if _, _, err := runCLI(t, binary, []string{"daemon", "stop"}, tempDir); err == nil {
+		t.Errorf("Expected stopping a stopped daemon to fail")
+	}
+}
+
+func TestCLIWatchDetach(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	port := strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)
+	conn.Close()
+
+	configPath := filepath.Join(tempDir, "watchers.json")
+	stdout, stderr, err := runCLI(t, binary, []string{"watch", "--config", configPath, "--control", port, "--detach"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to detach watcher: %v\n%s", err, stderr)
+	}
+	defer runCLI(t, binary, []string{"watch", "--stop"}, tempDir)
+	if !strings.Contains(stdout, "Watching in the background") {
+		t.Errorf("Expected watcher to detach, got: %s", stdout)
+	}
+
+	pid, err := watchers.ReadPIDFile(watchers.GetPIDPath(tempDir))
+	if err != nil || pid == 0 {
+		t.Fatalf("Expected a pidfile, got %d (%v)", pid, err)
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"watch", "--config", configPath, "--detach"}, tempDir); err == nil {
+		t.Errorf("Expected a second detached watcher to be refused")
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"watch", "--config", configPath, "--status"}, tempDir)
+	if err != nil || !strings.Contains(stdout, fmt.Sprintf("Running: true (pid %d", pid)) || !strings.Contains(stdout, "Detached: output in") {
+		t.Errorf("Expected status of the detached watcher, got: %s (%v)", stdout, err)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"watch", "--stop"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "Stopped detached watcher") {
+		t.Fatalf("Expected detached watcher to stop, got: %s (%v)", stdout, err)
+	}
+	if _, err := os.Stat(watchers.GetPIDPath(tempDir)); !os.IsNotExist(err) {
+		t.Errorf("Expected the pidfile to be removed")
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"watch", "--config", configPath, "--status"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "Running: false") {
+		t.Errorf("Expected no running watcher, got: %s (%v)", stdout, err)
+	}
+	if _, _, err := runCLI(t, binary, []string{"watch", "--stop"}, tempDir); err == nil {
+		t.Errorf("Expected stopping without a detached watcher to fail")
+	}
+}
+
+func TestCLIPushPull(t *testing.T) {
+	binary := buildCLI(t)
+	laptopDir := createTempDir(t)
+	defer os.RemoveAll(laptopDir)
+	studioDir := createTempDir(t)
+	defer os.RemoveAll(studioDir)
+
+	for _, dir := range []string{laptopDir, studioDir} {
+		if _, _, err := runCLI(t, binary, []string{"init"}, dir); err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+	}
+
+	server := httptest.NewServer(remote.NewHandler(studioDir, ""))
+	defer server.Close()
+
+	if _, _, err := runCLI(t, binary, []string{"push", "studio"}, laptopDir); err == nil {
+		t.Errorf("Expected an unknown remote to fail")
+	}
+	if _, _, err := runCLI(t, binary, []string{"config", "set", "remote.studio", server.URL}, laptopDir); err != nil {
+		t.Fatalf("Failed to add remote: %v", err)
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"commit", "-m", "Kick", "-c", "d1 $ s \"bd\"", "-l", "tidal", "-b", "d1"}, laptopDir); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	stdout, stderr, err := runCLI(t, binary, []string{"push", "studio"}, laptopDir)
+	if err != nil || !strings.Contains(stdout, "Pushed 1 commits") {
+		t.Fatalf("Expected 1 commit pushed, got: %s %s (%v)", stdout, stderr, err)
+	}
+	stdout, _, _ = runCLI(t, binary, []string{"log"}, studioDir)
+	if !strings.Contains(stdout, "Kick") {
+		t.Errorf("Expected the pushed commit in the studio log, got: %s", stdout)
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"commit", "-m", "Hats", "-c", "d2 $ s \"hh\"", "-l", "tidal", "-b", "d2"}, studioDir); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	stdout, _, err = runCLI(t, binary, []string{"pull", server.URL}, laptopDir)
+	if err != nil || !strings.Contains(stdout, "Pulled 1 commits") {
+		t.Errorf("Expected 1 commit pulled, got: %s (%v)", stdout, err)
+	}
+	stdout, _, err = runCLI(t, binary, []string{"pull", "studio"}, laptopDir)
+	if err != nil || !strings.Contains(stdout, "Already up to date") {
+		t.Errorf("Expected nothing left to pull, got: %s (%v)", stdout, err)
+	}
+}
+
+func TestCLIClone(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	original := filepath.Join(tempDir, "set")
+	if _, _, err := runCLI(t, binary, []string{"init", original}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	if _, _, err := runCLI(t, binary, []string{"commit", "-m", "Kick", "-c", "d1 $ s \"bd\"", "-l", "tidal", "-b", "d1"}, original); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	// Without a directory, the clone is named after the source
+	copies := filepath.Join(tempDir, "copies")
+	if err := os.MkdirAll(copies, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	stdout, stderr, err := runCLI(t, binary, []string{"clone", original}, copies)
+	if err != nil || !strings.Contains(stdout, "Cloned 1 commits") {
+		t.Fatalf("Expected 1 commit cloned, got: %s %s (%v)", stdout, stderr, err)
+	}
+	stdout, _, _ = runCLI(t, binary, []string{"log"}, filepath.Join(tempDir, "copies", "set"))
+	if !strings.Contains(stdout, "Kick") {
+		t.Errorf("Expected the cloned commit in the log, got: %s", stdout)
+	}
+
+	// A clone over HTTP remembers the server as origin
+	server := httptest.NewServer(remote.NewHandler(original, ""))
+	defer server.Close()
+	clone := filepath.Join(tempDir, "studio")
+	if _, stderr, err := runCLI(t, binary, []string{"clone", server.URL, clone}, tempDir); err != nil {
+		t.Fatalf("Failed to clone over HTTP: %s (%v)", stderr, err)
+	}
+	stdout, _, _ = runCLI(t, binary, []string{"config", "get", "remote.origin"}, clone)
+	if strings.TrimSpace(stdout) != server.URL {
+		t.Errorf("Expected remote.origin %s, got '%s'", server.URL, strings.TrimSpace(stdout))
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"clone", filepath.Join(tempDir, "nothing")}, tempDir); err == nil {
+		t.Errorf("Expected cloning a missing repository to fail")
+	}
+}
+
+func TestCLITimelineMerge(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	for _, performer := range []string{"alice", "bob"} {
+		dir := filepath.Join(tempDir, performer)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if _, _, err := runCLI(t, binary, []string{"init"}, dir); err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		if _, _, err := runCLI(t, binary, []string{"commit", "-m", performer + " kick", "-b", "d1", "-c", "d1 $ s \"bd\""}, dir); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+	if _, _, err := runCLI(t, binary, []string{"config", "set", "user.name", "Bob Smith"}, filepath.Join(tempDir, "bob")); err != nil {
+		t.Fatalf("Failed to set user name: %v", err)
+	}
+
+	out := filepath.Join(tempDir, "ensemble")
+	stdout, stderr, err := runCLI(t, binary, []string{"timeline", "merge", "al=alice", "bob", "--out", out}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to merge timelines: %v (%s)", err, stderr)
+	}
+	if !strings.Contains(stdout, "Merged 2 commits") {
+		t.Errorf("Expected a merge summary, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCLI(t, binary, []string{"log", "--format", "{{.Author}} {{.Metadata.Buffer}}\n"}, out)
+	if !strings.Contains(stdout, "al al/d1") || !strings.Contains(stdout, "Bob-Smith Bob-Smith/d1") {
+		t.Errorf("Expected commits labeled by performer, got: %s", stdout)
+	}
+
+	_, stderr, err = runCLI(t, binary, []string{"commit", "-m", "Late", "-c", "hush"}, out)
+	if err == nil || !strings.Contains(stderr, "read-only") {
+		t.Errorf("Expected the merged repository to refuse commits, got: %s", stderr)
+	}
+
+	stdout, _, _ = runCLI(t, binary, []string{"status"}, out)
+	if !strings.Contains(stdout, "Merged timeline of al, Bob-Smith") {
+		t.Errorf("Expected status to show the performers, got: %s", stdout)
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"timeline", "merge", "alice", "--out", filepath.Join(tempDir, "solo")}, tempDir); err == nil {
+		t.Errorf("Expected merging a single repository to fail")
+	}
+}
+
+func TestCLIStats(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"stats"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "No commits yet") {
+		t.Errorf("Expected empty statistics, got: %s (%v)", stdout, err)
+	}
+
+	for _, buffer := range []string{"d1", "d1", "d2"} {
+		if _, _, err := runCLI(t, binary, []string{"commit", "-m", "Beat", "-b", buffer, "-l", "tidal", "-c", buffer + " $ s \"bd\""}, tempDir); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"stats"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to show statistics: %v", err)
+	}
+	for _, expected := range []string{"Commits: 3", "Buffers:", "d1", "Languages:", "tidal", "Timeline (1m intervals)"} {
+		if !strings.Contains(stdout, expected) {
+			t.Errorf("Expected %q in statistics, got: %s", expected, stdout)
+		}
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"stats", "--json"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to show statistics as JSON: %v", err)
+	}
+	var stats core.Stats
+	if err := json.Unmarshal([]byte(stdout), &stats); err != nil {
+		t.Fatalf("Failed to parse statistics: %v", err)
+	}
+	if stats.Commits != 3 || len(stats.Buffers) != 2 || stats.Buffers[0].Name != "d1" || stats.Buffers[0].Commits != 2 {
+		t.Errorf("Expected d1 with 2 of 3 commits, got %+v", stats)
+	}
+}
+
+func TestCLILogs(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"logs"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "No journal entries") {
+		t.Errorf("Expected an empty journal, got: %s (%v)", stdout, err)
+	}
+
+	logs := filepath.Join(tempDir, ".livecodegit", "logs")
+	if err := os.MkdirAll(logs, 0755); err != nil {
+		t.Fatalf("Failed to create logs directory: %v", err)
+	}
+	journal := `{"time":"2024-05-01T21:00:00Z","level":"info","event":"service","message":"service started"}
+{"time":"2024-05-01T21:00:05Z","level":"error","event":"error","message":"failed to commit execution in d1","fields":{"buffer":"d1"}}
+{"time":"2024-05-01T21:00:09Z","level":"info","event":"commit","message":"Kick","fields":{"hash":"0123456789abcdef"}}
+`
+	if err := os.WriteFile(filepath.Join(logs, "lcg.log"), []byte(journal), 0644); err != nil {
+		t.Fatalf("Failed to write journal: %v", err)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"logs"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to show logs: %v", err)
+	}
+	if !strings.Contains(stdout, "service started") || !strings.Contains(stdout, "hash=01234567") {
+		t.Errorf("Expected every entry, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCLI(t, binary, []string{"logs", "--level", "error"}, tempDir)
+	if strings.Contains(stdout, "service started") || !strings.Contains(stdout, "ERROR") || !strings.Contains(stdout, "buffer=d1") {
+		t.Errorf("Expected only the error, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCLI(t, binary, []string{"logs", "-n", "1", "--event", "service"}, tempDir)
+	if !strings.Contains(stdout, "service started") || strings.Contains(stdout, "Kick") {
+		t.Errorf("Expected only the service entry, got: %s", stdout)
+	}
+}
+
+func TestCLITemplate(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"template", "add", "Bad", "--section", "drop"}, tempDir); err == nil {
+		t.Errorf("Expected a section without an offset to be rejected")
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"template", "add", "Club set", "--duration", "45m",
+		"--section", "intro@0s", "--section", "drop@25m", "--buffer", "d1:tidal", "--buffer", "d2:tidal"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to add template: %v", err)
+	}
+	if !strings.Contains(stdout, "Saved template Club set (2 sections, 2 buffers)") {
+		t.Errorf("Expected save confirmation, got: %s", stdout)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"template", "show", "Club set"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to show template: %v", err)
+	}
+	for _, expected := range []string{"Duration: 45m0s", "+25:00 drop", "d2 tidal"} {
+		if !strings.Contains(stdout, expected) {
+			t.Errorf("Expected template output to contain '%s', got: %s", expected, stdout)
+		}
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"performance", "start", "Friday", "--template", "Missing"}, tempDir); err == nil {
+		t.Errorf("Expected starting from an unknown template to fail")
+	}
+	stdout, _, err = runCLI(t, binary, []string{"performance", "start", "Friday", "--template", "Club set"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+	if !strings.Contains(stdout, "Planned from Club set: 2 sections, 2 buffers") {
+		t.Errorf("Expected plan summary, got: %s", stdout)
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"commit", "-m", "Arp", "-c", "d3 $ s \"arpy\"", "-l", "tidal", "-b", "d3"}, tempDir); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"performance", "compare"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to compare performance: %v", err)
+	}
+	for _, expected := range []string{"planned from Club set", "of 45m0s planned", "upcoming", "unused", "(unplanned)"} {
+		if !strings.Contains(stdout, expected) {
+			t.Errorf("Expected comparison to contain '%s', got: %s", expected, stdout)
+		}
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"template", "remove", "Club set"}, tempDir); err != nil {
+		t.Fatalf("Failed to remove template: %v", err)
+	}
+	stdout, _, _ = runCLI(t, binary, []string{"template"}, tempDir)
+	if !strings.Contains(stdout, "No templates") {
+		t.Errorf("Expected no templates after removal, got: %s", stdout)
+	}
+}
+
+func TestCLIExportTimeline(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	if _, _, err := runCLI(t, binary, []string{"export", "markdown"}, tempDir); err == nil {
+		t.Errorf("Expected export without performances to fail")
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"performance", "start", "Gig"}, tempDir); err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+	if _, _, err := runCLI(t, binary, []string{"commit", "-m", "Bass", "-c", "play :e2 # bass", "-l", "sonicpi", "-b", "bass"}, tempDir); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if _, _, err := runCLI(t, binary, []string{"performance", "end"}, tempDir); err != nil {
+		t.Fatalf("Failed to end performance: %v", err)
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"export", "markdown"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to export Markdown: %v", err)
+	}
+	if !strings.Contains(stdout, "# Gig") || !strings.Contains(stdout, "```ruby\nplay :e2 # bass\n```") {
+		t.Errorf("Expected Markdown timeline of the latest performance, got: %s", stdout)
+	}
+
+	output := filepath.Join(tempDir, "gig.html")
+	stdout, _, err = runCLI(t, binary, []string{"export", "html", "Gig", "-o", output}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to export HTML: %v", err)
+	}
+	if !strings.Contains(stdout, "Exported Gig (1 commits)") {
+		t.Errorf("Expected export summary, got: %s", stdout)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read HTML: %v", err)
+	}
+	if !strings.Contains(string(data), `<span class="hl-symbol">:e2</span>`) {
+		t.Errorf("Expected highlighted Sonic Pi code in HTML, got: %s", data)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"export", "bundle", "Gig"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to export bundle: %v", err)
+	}
+	if !strings.Contains(stdout, `"code":["play :e2 # bass"]`) {
+		t.Errorf("Expected bundle with the performance's code, got: %s", stdout)
+	}
+}
+
+func TestCLIExportCompletions(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	for _, code := range []string{"d1 $ sound \"bd*2\"", "d2 $ sound \"hh*4\""} {
+		if _, _, err := runCLI(t, binary, []string{"commit", "-m", "Beat", "-c", code, "-l", "tidal", "-b", code[:2]}, tempDir); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"export", "completions", "--format", "words"}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to export completions: %v", err)
+	}
+	if stdout != "sound\n" {
+		t.Errorf("Expected only 'sound' written twice, got %q", stdout)
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"export", "completions", "--format", "emacs"}, tempDir); err == nil {
+		t.Errorf("Expected an unknown format to be rejected")
+	}
+}
+
+func TestCLIUnlock(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	stdout, _, err := runCLI(t, binary, []string{"unlock"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "isn't locked") {
+		t.Fatalf("Expected an unlocked repository, got %q (%v)", stdout, err)
+	}
+
+	// A lock left behind by a watcher that crashed
+	lock := `{"pid": 4242, "host": "stage", "time": "2026-10-15T21:00:00Z"}`
+	if err := os.WriteFile(filepath.Join(tempDir, ".livecodegit", "index.lock"), []byte(lock), 0644); err != nil {
+		t.Fatalf("Failed to write lock: %v", err)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"unlock"}, tempDir)
+	if err == nil || !strings.Contains(stdout, "process 4242 on stage") {
+		t.Errorf("Expected unlock without --force to name the holder and fail, got %q (%v)", stdout, err)
+	}
+
+	stdout, _, err = runCLI(t, binary, []string{"unlock", "--force"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "Removed the lock") {
+		t.Fatalf("Expected the lock removed, got %q (%v)", stdout, err)
+	}
+	if _, _, err := runCLI(t, binary, []string{"commit", "-m", "Kick", "-c", "d1 $ s \"bd\""}, tempDir); err != nil {
+		t.Errorf("Expected commits to work once unlocked: %v", err)
+	}
+}
+
+func TestCLIEncrypt(t *testing.T) {
+	binary := buildCLI(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, _, err := runCLI(t, binary, []string{"init"}, tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	if _, _, err := runCLI(t, binary, []string{"commit", "-m", "Drums", "-c", "sample :bd_haus", "-l", "sonicpi"}, tempDir); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	keyFile := filepath.Join(tempDir, "set.key")
+	stdout, stderr, err := runCLI(t, binary, []string{"encrypt", "--key-file", keyFile}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v\n%s", err, stderr)
+	}
+	if !strings.Contains(stdout, "Generated a new key") || !strings.Contains(stdout, "2 stored objects encrypted") {
+		t.Errorf("Expected a new key and the stored commit encrypted, got: %s", stdout)
+	}
+
+	if _, _, err := runCLI(t, binary, []string{"commit", "-m", "Bass", "-c", "synth :tb303", "-l", "sonicpi"}, tempDir); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	stdout, _, err = runCLI(t, binary, []string{"grep", "tb303"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "synth :tb303") {
+		t.Errorf("Expected encrypted commits to be searchable, got: %s (%v)", stdout, err)
+	}
+	stdout, _, err = runCLI(t, binary, []string{"format"}, tempDir)
+	if err != nil || !strings.Contains(stdout, "Encryption:  aes-256-gcm, key file "+keyFile) {
+		t.Errorf("Expected the key file in the format, got: %s (%v)", stdout, err)
+	}
+
+	os.Rename(keyFile, keyFile+".away")
+	if _, _, err := runCLI(t, binary, []string{"grep", "tb303"}, tempDir); err == nil {
+		t.Errorf("Expected reading without the key to fail")
+	}
+}
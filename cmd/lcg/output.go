@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// ANSI escape sequences used for terminal output
+const (
+	ansiReset   = "\x1b[0m"
+	ansiDim     = "\x1b[2m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+	ansiBlue    = "\x1b[34m"
+	ansiMagenta = "\x1b[35m"
+	ansiCyan    = "\x1b[36m"
+)
+
+// colorEnabled reports whether output is colorized; set by configureColor
+var colorEnabled = false
+
+// configureColor enables color when stdout is a terminal and neither the
+// global --no-color flag nor the NO_COLOR environment variable is set. It
+// returns args with --no-color removed.
+func configureColor(args []string) []string {
+	filtered := make([]string, 0, len(args))
+	noColor := false
+
+	for _, arg := range args {
+		if arg == "--no-color" || arg == "-no-color" {
+			noColor = true
+			continue
+		}
+		filtered = append(filtered, arg)
+	}
+
+	colorEnabled = !noColor && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+	return filtered
+}
+
+// isTerminal reports whether a file is attached to a terminal
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// paint wraps text in an ANSI color when color is enabled
+func paint(color, text string) string {
+	if !colorEnabled || text == "" {
+		return text
+	}
+	return color + text + ansiReset
+}
+
+// colorHash highlights a commit hash
+func colorHash(hash string) string {
+	return paint(ansiYellow, hash)
+}
+
+// colorTime highlights a timestamp
+func colorTime(text string) string {
+	return paint(ansiBlue, text)
+}
+
+// colorLanguage gives each livecoding language its own color
+func colorLanguage(language string) string {
+	switch strings.ToLower(language) {
+	case "sonicpi":
+		return paint(ansiMagenta, language)
+	case "tidal":
+		return paint(ansiCyan, language)
+	case "unknown", "":
+		return paint(ansiDim, language)
+	default:
+		return paint(ansiGreen, language)
+	}
+}
+
+// colorResult shows text as a success or an error
+func colorResult(success bool, text string) string {
+	if success {
+		return paint(ansiGreen, text)
+	}
+	return paint(ansiRed, text)
+}
+
+// colorDim de-emphasizes secondary text
+func colorDim(text string) string {
+	return paint(ansiDim, text)
+}
+
+// padRight pads text with spaces to width so columns line up; pad before painting
+func padRight(text string, width int) string {
+	if len(text) >= width {
+		return text
+	}
+	return text + strings.Repeat(" ", width-len(text))
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/watchers"
+)
+
+func handlePending(args []string) {
+	subcommand := "list"
+	if len(args) > 0 {
+		subcommand = args[0]
+		args = args[1:]
+	}
+
+	switch subcommand {
+	case "list":
+		handlePendingList(args)
+	case "show":
+		handlePendingShow(args)
+	case "accept":
+		handlePendingAccept(args)
+	case "reject":
+		handlePendingReject(args)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown pending command: %s\n", subcommand)
+		fmt.Fprintf(os.Stderr, "Usage: lcg pending [list|show|accept|reject] [options]\n")
+		os.Exit(1)
+	}
+}
+
+func handlePendingList(args []string) {
+	listFlags := flag.NewFlagSet("pending list", flag.ExitOnError)
+	listFlags.Parse(args)
+
+	_, path := loadRepository()
+
+	events, err := watchers.NewPendingStore(path).List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading pending events: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(events) == 0 {
+		fmt.Println("No pending events")
+		return
+	}
+
+	bufferWidth := len("main")
+	for _, pending := range events {
+		bufferWidth = max(bufferWidth, len(pending.Event.Buffer))
+	}
+
+	for _, pending := range events {
+		event := pending.Event
+		code, _, _ := strings.Cut(strings.TrimSpace(event.Content), "\n")
+		result := "ok   "
+		if !event.Success {
+			result = "error"
+		}
+		fmt.Printf("%s %s %s %s %s %s\n",
+			colorHash(pending.ID),
+			colorTime(pending.ReceivedAt.Format("15:04:05")),
+			colorLanguage(padRight(event.Language, 7)),
+			padRight(event.Buffer, bufferWidth),
+			colorResult(event.Success, result),
+			code)
+	}
+
+	fmt.Printf("\n%d pending event(s); review with 'lcg pending accept <id>' or 'lcg pending reject <id>'\n", len(events))
+}
+
+func handlePendingShow(args []string) {
+	showFlags := flag.NewFlagSet("pending show", flag.ExitOnError)
+	showFlags.Parse(args)
+
+	if showFlags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Error: a pending event ID is required\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg pending show <id>\n")
+		os.Exit(1)
+	}
+
+	_, path := loadRepository()
+
+	pending, err := watchers.NewPendingStore(path).Get(showFlags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	event := pending.Event
+	fmt.Printf("Pending %s\n", colorHash(pending.ID))
+	fmt.Printf("Received: %s\n", colorTime(pending.ReceivedAt.Format("2006-01-02 15:04:05")))
+	fmt.Printf("Message: %s\n", pending.Message)
+	if event.Author != "" {
+		fmt.Printf("Author: %s\n", formatAuthor(event.Author, event.AuthorEmail))
+	}
+	fmt.Printf("Language: %s\n", colorLanguage(event.Language))
+	fmt.Printf("Buffer: %s\n", event.Buffer)
+	if event.Success {
+		fmt.Printf("Result: %s\n", formatResult(true))
+	} else {
+		fmt.Printf("Result: %s %s\n", formatResult(false), event.ErrorMessage)
+	}
+	fmt.Printf("\n%s\n", event.Content)
+}
+
+func handlePendingAccept(args []string) {
+	acceptFlags := flag.NewFlagSet("pending accept", flag.ExitOnError)
+	message := acceptFlags.String("m", "", "Commit message (default: the message generated when the event was received)")
+	all := acceptFlags.Bool("all", false, "Accept every pending event")
+
+	acceptFlags.Parse(args)
+
+	repo, path := loadRepository()
+	store := watchers.NewPendingStore(path)
+	ids := pendingIDs(store, acceptFlags, *all, "accept")
+
+	for _, id := range ids {
+		pending, err := store.Get(id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		commitMessage := pending.Message
+		if *message != "" {
+			commitMessage = *message
+		}
+		if commitMessage == "" {
+			commitMessage = fmt.Sprintf("%s execution in %s", pending.Event.Language, pending.Event.Buffer)
+		}
+
+		author := core.UserConfig{Name: pending.Event.Author, Email: pending.Event.AuthorEmail}
+		commit, err := repo.CommitAs(pending.Event.Content, commitMessage, pending.Event.ToExecutionMetadata(), author)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating commit: %v\n", err)
+			os.Exit(1)
+		}
+
+		if _, err := store.Remove(pending.ID); err != nil {
+			fmt.Fprintf(os.Stderr, "Error removing pending event: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Accepted %s as commit %s\n", colorHash(pending.ID), colorHash(commit.Hash[:8]))
+	}
+
+	if err := repo.FlushPerformance(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update performance: %v\n", err)
+	}
+}
+
+func handlePendingReject(args []string) {
+	rejectFlags := flag.NewFlagSet("pending reject", flag.ExitOnError)
+	all := rejectFlags.Bool("all", false, "Reject every pending event")
+
+	rejectFlags.Parse(args)
+
+	_, path := loadRepository()
+	store := watchers.NewPendingStore(path)
+
+	for _, id := range pendingIDs(store, rejectFlags, *all, "reject") {
+		pending, err := store.Remove(id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Rejected %s\n", colorHash(pending.ID))
+	}
+}
+
+// pendingIDs returns the IDs named on the command line, or every pending ID with --all
+func pendingIDs(store *watchers.PendingStore, flags *flag.FlagSet, all bool, action string) []string {
+	if all == (flags.NArg() > 0) {
+		fmt.Fprintf(os.Stderr, "Error: give either pending event IDs or --all\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg pending %s <id>... | --all\n", action)
+		os.Exit(1)
+	}
+
+	if !all {
+		return flags.Args()
+	}
+
+	events, err := store.List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading pending events: %v\n", err)
+		os.Exit(1)
+	}
+
+	ids := make([]string, 0, len(events))
+	for _, pending := range events {
+		ids = append(ids, pending.ID)
+	}
+	return ids
+}
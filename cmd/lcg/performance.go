@@ -0,0 +1,347 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+func handlePerformance(args []string) {
+	subcommand := "list"
+	if len(args) > 0 {
+		subcommand = args[0]
+		args = args[1:]
+	}
+
+	switch subcommand {
+	case "start":
+		handlePerformanceStart(args)
+	case "end":
+		handlePerformanceEnd(args)
+	case "list":
+		handlePerformanceList(args)
+	case "show":
+		handlePerformanceShow(args)
+	case "compare":
+		handlePerformanceCompare(args)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown performance command: %s\n", subcommand)
+		fmt.Fprintf(os.Stderr, "Usage: lcg performance [start|end|list|show|compare] [options]\n")
+		os.Exit(1)
+	}
+}
+
+func handlePerformanceStart(args []string) {
+	startFlags := flag.NewFlagSet("performance start", flag.ExitOnError)
+	templateName := startFlags.String("template", "", "Plan the performance with a template's sections and buffers")
+
+	names := parseInterspersed(startFlags, args)
+	if len(names) > 1 {
+		fmt.Fprintf(os.Stderr, "Error: quote performance names containing spaces\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg performance start [name] [--template name]\n")
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
+	var template *core.Template
+	if *templateName != "" {
+		var err error
+		if template, err = repo.GetTemplate(*templateName); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var name string
+	if len(names) == 1 {
+		name = names[0]
+	} else if template != nil {
+		name = template.Name + " " + time.Now().Format("2006-01-02 15:04")
+	} else {
+		name = "Performance " + time.Now().Format("2006-01-02 15:04")
+	}
+
+	// Starting a performance ends the active one
+	if previous, _ := repo.GetCurrentPerformance(); previous != nil {
+		fmt.Printf("Ended performance %s (%d commits)\n", previous.Name, previous.CommitCount)
+	}
+
+	var performance *core.Performance
+	var err error
+	if template != nil {
+		performance, err = repo.StartPlannedPerformance(name, template)
+	} else {
+		performance, err = repo.StartPerformance(name)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting performance: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Started performance %s (%s)\n", performance.Name, colorHash(performance.ID))
+	if template != nil {
+		fmt.Printf("Planned from %s: %d sections, %d buffers\n", template.Name, len(template.Sections), len(template.Buffers))
+	}
+}
+
+func handlePerformanceEnd(args []string) {
+	endFlags := flag.NewFlagSet("performance end", flag.ExitOnError)
+	endFlags.Parse(args)
+
+	repo, _ := loadRepository()
+
+	performance, _ := repo.GetCurrentPerformance()
+	if performance == nil {
+		fmt.Fprintf(os.Stderr, "Error: no active performance (start one with 'lcg performance start')\n")
+		os.Exit(1)
+	}
+
+	if err := repo.EndPerformance(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error ending performance: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Ended performance %s (%s)\n", performance.Name, colorHash(performance.ID))
+	fmt.Printf("Duration: %s, %d commits\n", formatElapsed(performanceDuration(performance)), performance.CommitCount)
+}
+
+func handlePerformanceList(args []string) {
+	listFlags := flag.NewFlagSet("performance list", flag.ExitOnError)
+	listFlags.Parse(args)
+
+	repo, _ := loadRepository()
+
+	performances, err := repo.ListPerformances()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing performances: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(performances) == 0 {
+		fmt.Println("No performances recorded (start one with 'lcg performance start')")
+		return
+	}
+
+	idWidth := 0
+	for _, performance := range performances {
+		idWidth = max(idWidth, len(performance.ID))
+	}
+
+	for _, performance := range performances {
+		duration := formatElapsed(performanceDuration(performance))
+		if performance.EndTime.IsZero() {
+			duration = colorResult(true, padRight(duration, 8)+" active")
+		} else {
+			duration = padRight(duration, 15)
+		}
+		fmt.Printf("%s %s %s %5d commits  %s\n",
+			colorHash(padRight(performance.ID, idWidth)),
+			colorTime(performance.StartTime.Format("2006-01-02 15:04")),
+			duration,
+			performance.CommitCount,
+			performance.Name)
+	}
+}
+
+func handlePerformanceShow(args []string) {
+	showFlags := flag.NewFlagSet("performance show", flag.ExitOnError)
+	showFlags.Parse(args)
+
+	if showFlags.NArg() > 1 {
+		fmt.Fprintf(os.Stderr, "Usage: lcg performance show [id|name]\n")
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+	performance := selectPerformance(repo, showFlags.Arg(0))
+
+	commits, err := repo.PerformanceCommits(performance)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading performance commits: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Performance %s\n", colorHash(performance.ID))
+	fmt.Printf("Name: %s\n", performance.Name)
+	if performance.Author != "" {
+		fmt.Printf("Author: %s\n", performance.Author)
+	}
+	if performance.Description != "" {
+		fmt.Printf("Description: %s\n", performance.Description)
+	}
+	if performance.Template != "" {
+		fmt.Printf("Template: %s\n", performance.Template)
+	}
+	fmt.Printf("Started: %s\n", colorTime(performance.StartTime.Format("2006-01-02 15:04:05")))
+	if performance.EndTime.IsZero() {
+		fmt.Printf("Ended: %s\n", colorResult(true, "active"))
+	} else {
+		fmt.Printf("Ended: %s\n", colorTime(performance.EndTime.Format("2006-01-02 15:04:05")))
+	}
+	fmt.Printf("Duration: %s\n", formatElapsed(performanceDuration(performance)))
+	fmt.Printf("Commits: %d\n", performance.CommitCount)
+
+	if len(performance.Buffers) > 0 {
+		names := make([]string, 0, len(performance.Buffers))
+		bufferWidth := 0
+		for name := range performance.Buffers {
+			names = append(names, name)
+			bufferWidth = max(bufferWidth, len(name))
+		}
+		sort.Strings(names)
+
+		fmt.Printf("\nBuffers:\n")
+		for _, name := range names {
+			stats := performance.Buffers[name]
+			fmt.Printf("  %s %s %3d commits, %d errors\n",
+				padRight(name, bufferWidth), colorLanguage(padRight(stats.Language, 7)), stats.CommitCount, stats.ErrorCount)
+		}
+	}
+
+	if len(performance.Markers) > 0 {
+		fmt.Printf("\nMarkers:\n")
+		for _, marker := range performance.Markers {
+			at := ""
+			if marker.Commit != "" {
+				at = " at " + colorHash(marker.Commit[:8])
+			}
+			snapshot := ""
+			if len(marker.Buffers) > 0 {
+				snapshot = fmt.Sprintf(" (snapshot of %d buffers)", len(marker.Buffers))
+			}
+			if marker.Planned {
+				snapshot = " " + colorDim("(planned)")
+			}
+			fmt.Printf("  %s %s%s%s\n", colorTime(marker.Time.Format("15:04:05")), marker.Label, at, snapshot)
+		}
+	}
+
+	if len(commits) > 0 {
+		fmt.Printf("\nCommits:\n")
+		for _, commit := range commits {
+			result := ""
+			if !commit.Metadata.Success {
+				result = " " + colorResult(false, "error")
+			}
+			fmt.Printf("  %s %s [%s] %s%s\n",
+				colorHash(commit.Hash[:8]),
+				colorTime(commit.Timestamp.Format("15:04:05")),
+				commit.Metadata.Buffer,
+				commit.Message,
+				result)
+		}
+	}
+}
+
+func handlePerformanceCompare(args []string) {
+	compareFlags := flag.NewFlagSet("performance compare", flag.ExitOnError)
+	compareFlags.Parse(args)
+
+	if compareFlags.NArg() > 1 {
+		fmt.Fprintf(os.Stderr, "Usage: lcg performance compare [id|name]\n")
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+	performance := selectPerformance(repo, compareFlags.Arg(0))
+
+	if performance.Template == "" {
+		fmt.Fprintf(os.Stderr, "Error: performance %s was not started from a template\n", performance.Name)
+		os.Exit(1)
+	}
+
+	comparison := core.ComparePlan(performance)
+
+	fmt.Printf("Performance %s planned from %s\n", performance.Name, comparison.Template)
+	if comparison.PlannedDuration > 0 {
+		fmt.Printf("Duration: %s of %s planned (%s)\n",
+			formatElapsed(comparison.ActualDuration), formatElapsed(comparison.PlannedDuration),
+			formatDrift(comparison.ActualDuration-comparison.PlannedDuration, "over", "under"))
+	} else {
+		fmt.Printf("Duration: %s\n", formatElapsed(comparison.ActualDuration))
+	}
+
+	if len(comparison.Sections) > 0 {
+		fmt.Printf("\nSections:\n")
+		for _, section := range comparison.Sections {
+			planned := formatOffset(section.Planned.Sub(performance.StartTime))
+			if section.Actual.IsZero() {
+				status := colorResult(false, "missed")
+				if performance.EndTime.IsZero() && section.Planned.After(time.Now()) {
+					status = colorDim("upcoming")
+				}
+				fmt.Printf("  %s %s %s\n", colorTime(padRight(planned, 7)), padRight(section.Label, 16), status)
+				continue
+			}
+			fmt.Printf("  %s %s %s (%s)\n",
+				colorTime(padRight(planned, 7)),
+				padRight(section.Label, 16),
+				formatOffset(section.Actual.Sub(performance.StartTime)),
+				formatDrift(section.Drift(), "late", "early"))
+		}
+	}
+
+	if len(comparison.Unplanned) > 0 {
+		fmt.Printf("\nUnplanned markers:\n")
+		for _, marker := range comparison.Unplanned {
+			fmt.Printf("  %s %s\n", colorTime(formatOffset(marker.Time.Sub(performance.StartTime))), marker.Label)
+		}
+	}
+
+	if len(comparison.Buffers) > 0 {
+		fmt.Printf("\nBuffers:\n")
+		for _, buffer := range comparison.Buffers {
+			status := fmt.Sprintf("%d commits", buffer.Commits)
+			switch {
+			case buffer.Planned && buffer.Commits == 0:
+				status = colorResult(false, "unused")
+			case !buffer.Planned:
+				status += " " + colorDim("(unplanned)")
+			}
+			fmt.Printf("  %s %s %s\n", padRight(buffer.Name, 8), colorLanguage(padRight(buffer.Language, 7)), status)
+		}
+	}
+}
+
+// selectPerformance finds a performance by ID or name, or the active one when
+// ref is empty, exiting when there is none
+func selectPerformance(repo *core.LiveCodeRepository, ref string) *core.Performance {
+	var performance *core.Performance
+	var err error
+	if ref != "" {
+		performance, err = repo.GetPerformance(ref)
+	} else if performance, _ = repo.GetCurrentPerformance(); performance == nil {
+		err = fmt.Errorf("no active performance; give a performance ID or name")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return performance
+}
+
+// formatDrift describes a difference from a plan, e.g. "2m10s late"
+func formatDrift(d time.Duration, after, before string) string {
+	d = d.Round(time.Second)
+	switch {
+	case d > 0:
+		return formatElapsed(d) + " " + after
+	case d < 0:
+		return formatElapsed(-d) + " " + before
+	default:
+		return "on time"
+	}
+}
+
+// performanceDuration returns how long a performance lasted, or has lasted so far
+func performanceDuration(performance *core.Performance) time.Duration {
+	if performance.EndTime.IsZero() {
+		return time.Since(performance.StartTime)
+	}
+	return performance.EndTime.Sub(performance.StartTime)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+func handlePrivacy(args []string) {
+	if len(args) == 0 || args[0] == "list" {
+		handlePrivacyList()
+		return
+	}
+
+	switch args[0] {
+	case "set":
+		handlePrivacySet(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown privacy subcommand: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// handlePrivacySet sets the privacy level of commits or of a buffer
+func handlePrivacySet(args []string) {
+	setFlags := flag.NewFlagSet("privacy set", flag.ExitOnError)
+	buffer := setFlags.String("buffer", "", "Set the level of every commit in this buffer")
+
+	rest := parseInterspersed(setFlags, args)
+	if len(rest) == 0 || (*buffer == "" && len(rest) < 2) || (*buffer != "" && len(rest) > 1) {
+		fmt.Fprintf(os.Stderr, "Error: a level and either commits or --buffer are required\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg privacy set <public|redacted|private> <hash|tag>... | --buffer <name>\n")
+		os.Exit(1)
+	}
+
+	level, err := core.ParsePrivacyLevel(rest[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
+	if *buffer != "" {
+		if err := repo.SetBufferPrivacy(*buffer, level); err != nil {
+			fmt.Fprintf(os.Stderr, "Error setting privacy: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Buffer %s is %s\n", *buffer, level)
+		return
+	}
+
+	for _, ref := range rest[1:] {
+		commit, err := repo.ResolveCommit(ref)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := repo.SetCommitPrivacy(commit.Hash, level); err != nil {
+			fmt.Fprintf(os.Stderr, "Error setting privacy: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Commit %s is %s\n", colorHash(commit.Hash[:8]), level)
+	}
+}
+
+// handlePrivacyList shows the buffers and commits that aren't public
+func handlePrivacyList() {
+	repo, _ := loadRepository()
+
+	rules, err := repo.Privacy()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading privacy rules: %v\n", err)
+		os.Exit(1)
+	}
+	if rules.Empty() {
+		fmt.Println("Everything is public")
+		return
+	}
+
+	if len(rules.Buffers) > 0 {
+		fmt.Printf("Buffers:\n")
+		for _, buffer := range rules.SortedBuffers() {
+			fmt.Printf("  %s %s\n", padRight(buffer, 12), rules.Buffers[buffer])
+		}
+	}
+
+	if len(rules.Commits) > 0 {
+		hashes := make([]string, 0, len(rules.Commits))
+		for hash := range rules.Commits {
+			hashes = append(hashes, hash)
+		}
+		sort.Strings(hashes)
+
+		fmt.Printf("Commits:\n")
+		for _, hash := range hashes {
+			description := ""
+			if commit, err := repo.GetCommit(hash); err == nil {
+				description = fmt.Sprintf(" [%s] %s", commit.Metadata.Buffer, commit.Message)
+			}
+			fmt.Printf("  %s %s%s\n", colorHash(hash[:8]), padRight(string(rules.Commits[hash]), 8), description)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// detachedProcessFlag starts a process without a console (DETACHED_PROCESS)
+const detachedProcessFlag = 0x00000008
+
+// processAlive reports whether a process with the given pid is running
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
+
+// detachedProcess starts a process without the console of its parent, so
+// closing the terminal that started it doesn't stop it
+func detachedProcess() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: detachedProcessFlag | syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// terminateProcess stops a process; Windows can't deliver SIGTERM, so it
+// stops without the shutdown a signal would trigger
+func terminateProcess(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Kill()
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/remote"
+)
+
+func handlePush(args []string) {
+	pushFlags := flag.NewFlagSet("push", flag.ExitOnError)
+	positional := parseInterspersed(pushFlags, args)
+
+	repo, _ := loadRepository()
+	name, url := resolveRemote(repo, positional, "push")
+
+	result, err := remote.Push(repo, remote.NewClient(url, os.Getenv(remote.TokenEnv)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error pushing to %s: %v\n", name, err)
+		os.Exit(1)
+	}
+	reportSync("Pushed", "to", name, result)
+}
+
+func handlePull(args []string) {
+	pullFlags := flag.NewFlagSet("pull", flag.ExitOnError)
+	positional := parseInterspersed(pullFlags, args)
+
+	repo, _ := loadRepository()
+	name, url := resolveRemote(repo, positional, "pull")
+
+	result, err := remote.Pull(repo, remote.NewClient(url, os.Getenv(remote.TokenEnv)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error pulling from %s: %v\n", name, err)
+		os.Exit(1)
+	}
+	reportSync("Pulled", "from", name, result)
+}
+
+// resolveRemote returns the remote named by the only argument, which is a
+// URL or a name set with 'lcg config set remote.<name> <url>'
+func resolveRemote(repo *core.LiveCodeRepository, args []string, command string) (string, string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: lcg %s <remote|url>\n", command)
+		os.Exit(1)
+	}
+	name := args[0]
+	if strings.Contains(name, "://") {
+		return name, name
+	}
+
+	config, err := repo.Config()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	url, _ := config.Get(core.RemotePrefix + name)
+	if url == "" {
+		fmt.Fprintf(os.Stderr, "Error: unknown remote %s\n", name)
+		fmt.Fprintf(os.Stderr, "Add it first: lcg config set %s%s http://host:7070\n", core.RemotePrefix, name)
+		os.Exit(1)
+	}
+	return name, url
+}
+
+// reportSync tells what a push or pull changed
+func reportSync(verb, preposition, name string, result *remote.Result) {
+	for _, tag := range result.TagConflicts {
+		fmt.Fprintf(os.Stderr, "Warning: tag %s points elsewhere on each side; each side keeps its own\n", tag)
+	}
+	if result.UpToDate() {
+		fmt.Printf("Already up to date with %s\n", name)
+		return
+	}
+	fmt.Printf("%s %d commits, %d tags and %d performances %s %s\n",
+		verb, result.Commits, result.Tags, result.Performances, preposition, name)
+}
+
+func handleClone(args []string) {
+	cloneFlags := flag.NewFlagSet("clone", flag.ExitOnError)
+	positional := parseInterspersed(cloneFlags, args)
+	if len(positional) < 1 || len(positional) > 2 {
+		fmt.Fprintf(os.Stderr, "Usage: lcg clone <path|url> [directory]\n")
+		os.Exit(1)
+	}
+	from := positional[0]
+	isURL := strings.Contains(from, "://")
+
+	var source remote.Source
+	if isURL {
+		source = remote.NewClient(from, os.Getenv(remote.TokenEnv))
+	} else {
+		local, err := remote.NewLocal(from)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening %s: %v\n", from, err)
+			os.Exit(1)
+		}
+		source = local
+	}
+
+	dir := cloneDirectory(from)
+	if len(positional) == 2 {
+		dir = positional[1]
+	}
+
+	repo, result, err := remote.Clone(source, dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error cloning %s: %v\n", from, err)
+		os.Exit(1)
+	}
+
+	// A clone over HTTP remembers where it came from, for push and pull
+	if isURL {
+		config, err := repo.Config()
+		if err == nil {
+			err = config.Set(core.RemotePrefix+"origin", from)
+		}
+		if err == nil {
+			err = config.Save()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to record remote origin: %v\n", err)
+		}
+	}
+
+	for _, tag := range result.TagConflicts {
+		fmt.Fprintf(os.Stderr, "Warning: tag %s was not copied\n", tag)
+	}
+	fmt.Printf("Cloned %d commits, %d tags and %d performances from %s into %s\n",
+		result.Commits, result.Tags, result.Performances, from, dir)
+}
+
+// cloneDirectory names the directory a clone goes to when none is given:
+// the last element of the path or URL, or the host of a bare URL
+func cloneDirectory(from string) string {
+	if parsed, err := url.Parse(from); err == nil && parsed.Host != "" {
+		if name := path.Base(strings.TrimSuffix(parsed.Path, "/")); name != "." && name != "/" && name != "" {
+			return name
+		}
+		return parsed.Hostname()
+	}
+	return filepath.Base(filepath.Clean(from))
+}
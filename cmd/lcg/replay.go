@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/livecodegit/pkg/replay"
+)
+
+func handleReplay(args []string) {
+	replayFlags := flag.NewFlagSet("replay", flag.ExitOnError)
+	speed := replayFlags.Float64("speed", 1, "Playback speed; 2 replays twice as fast")
+	maxGap := replayFlags.Duration("max-gap", 0, "Longest pause between commits, e.g. 10s (default: as recorded)")
+	outDir := replayFlags.String("out", "", "Write each commit's code into buffer files in this directory")
+	oscTarget := replayFlags.String("osc", "", "Send each commit to an OSC target, e.g. localhost:57120")
+	oscAddress := replayFlags.String("osc-address", replay.DefaultOSCAddress, "OSC address for --osc")
+	quiet := replayFlags.Bool("quiet", false, "Do not print commits as they are replayed")
+	runSonicPi := replayFlags.Bool("sonicpi", false, "Run each Sonic Pi commit in the running Sonic Pi")
+	sonicPi := sonicPiFlags(replayFlags)
+
+	ref := parseInterspersed(replayFlags, args)
+
+	if len(ref) != 1 {
+		fmt.Fprintf(os.Stderr, "Error: a performance ID or name is required\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg replay <performance> [--speed n] [--out dir] [--osc host:port] [--sonicpi]\n")
+		os.Exit(1)
+	}
+	if *speed <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: --speed must be positive\n")
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
+	performance, err := repo.GetPerformance(ref[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	commits, err := repo.PerformanceCommits(performance)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading performance commits: %v\n", err)
+		os.Exit(1)
+	}
+	if len(commits) == 0 {
+		fmt.Printf("Performance %s has no commits to replay\n", performance.Name)
+		return
+	}
+
+	var sinks []replay.Sink
+	if !*quiet {
+		sinks = append(sinks, printReplayEvent)
+	}
+	if *outDir != "" {
+		sink, err := replay.FileSink(*outDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		sinks = append(sinks, sink)
+	}
+	if *oscTarget != "" {
+		oscSink, err := replay.NewOSCSink(*oscTarget, *oscAddress)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer oscSink.Close()
+		sinks = append(sinks, oscSink.Send)
+	}
+
+	if *runSonicPi {
+		client := sonicPi.connect()
+		defer client.Close()
+		sinks = append(sinks, replay.SonicPiSink(client))
+	}
+
+	player := replay.NewPlayer(commits, replay.Options{Speed: *speed, MaxGap: *maxGap}, sinks...)
+
+	fmt.Printf("Replaying %s: %d commits over %s\n\n", performance.Name, len(commits), formatElapsed(player.Duration()))
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := player.Play(ctx); err != nil {
+		if ctx.Err() != nil {
+			fmt.Printf("\nReplay stopped\n")
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printReplayEvent shows a replayed commit with its offset into the session
+func printReplayEvent(event replay.Event) error {
+	commit := event.Commit
+	result := ""
+	if !commit.Metadata.Success {
+		result = " " + colorResult(false, "error")
+	}
+
+	fmt.Printf("%s %s [%s] %s%s\n",
+		colorTime(formatOffset(event.Offset)),
+		colorHash(commit.Hash[:8]),
+		commit.Metadata.Buffer,
+		commit.Message,
+		result)
+	for _, line := range strings.Split(strings.TrimRight(commit.Content, "\n"), "\n") {
+		fmt.Printf("    %s\n", colorDim(line))
+	}
+	return nil
+}
+
+// formatOffset renders a time into a session as +mm:ss, or +h:mm:ss
+func formatOffset(d time.Duration) string {
+	d = d.Round(time.Second)
+	hours := int(d / time.Hour)
+	minutes := int(d/time.Minute) % 60
+	seconds := int(d/time.Second) % 60
+	if hours > 0 {
+		return fmt.Sprintf("+%d:%02d:%02d", hours, minutes, seconds)
+	}
+	return fmt.Sprintf("+%02d:%02d", minutes, seconds)
+}
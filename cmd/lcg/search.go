@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+func handleSearch(args []string) {
+	searchFlags := flag.NewFlagSet("search", flag.ExitOnError)
+	language := searchFlags.String("lang", "", "Only search commits in this language")
+	buffer := searchFlags.String("buffer", "", "Only search commits in this buffer")
+	since := searchFlags.String("since", "", "Only search commits after this time (e.g. 30m, 2024-05-01, 21:00)")
+	until := searchFlags.String("until", "", "Only search commits before this time")
+	context := searchFlags.Int("C", 0, "Lines of context around each match")
+	limit := searchFlags.Int("n", 0, "Maximum number of matching commits to show")
+
+	searchFlags.Parse(args)
+
+	if searchFlags.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "Error: search query is required\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg search [options] <query>\n")
+		os.Exit(1)
+	}
+	query := strings.Join(searchFlags.Args(), " ")
+
+	opts := core.SearchOptions{
+		Language:     *language,
+		Buffer:       *buffer,
+		ContextLines: *context,
+		Limit:        *limit,
+	}
+
+	var err error
+	if opts.Since, err = parseTimeFlag(*since); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --since value: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.Until, err = parseTimeFlag(*until); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --until value: %v\n", err)
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
+	results, err := repo.Search(query, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error searching history: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No matches found")
+		return
+	}
+
+	// Align the buffer and language columns across results
+	bufferWidth, languageWidth := 0, 0
+	for _, result := range results {
+		bufferWidth = max(bufferWidth, len(result.Commit.Metadata.Buffer)+2)
+		languageWidth = max(languageWidth, len(result.Commit.Metadata.Language)+1)
+	}
+
+	for i, result := range results {
+		commit := result.Commit
+		fmt.Printf("%s %s %s %s %s\n", colorHash(commit.Hash[:8]),
+			colorTime(commit.Timestamp.Format("2006-01-02 15:04:05")),
+			padRight("["+commit.Metadata.Buffer+"]", bufferWidth),
+			colorLanguage(commit.Metadata.Language)+padRight(":", languageWidth-len(commit.Metadata.Language)),
+			commit.Message)
+
+		last := 0
+		for _, line := range result.Lines {
+			if last != 0 && line.Number > last+1 {
+				fmt.Printf("    --\n")
+			}
+
+			if line.Match {
+				fmt.Printf("    %s %s\n", paint(ansiGreen, fmt.Sprintf("%d:", line.Number)), line.Text)
+			} else {
+				fmt.Printf("    %s %s\n", colorDim(fmt.Sprintf("%d-", line.Number)), line.Text)
+			}
+			last = line.Number
+		}
+
+		if i < len(results)-1 {
+			fmt.Println()
+		}
+	}
+}
+
+// parseTimeFlag parses an absolute time or a duration ago; empty input yields the zero time
+func parseTimeFlag(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	layouts := []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	// A bare clock time refers to today
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			now := time.Now()
+			return time.Date(now.Year(), now.Month(), now.Day(),
+				t.Hour(), t.Minute(), t.Second(), 0, time.Local), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized time %q", value)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/livecodegit/pkg/remote"
+	"github.com/livecodegit/pkg/web"
+)
+
+// defaultServeAddr keeps the history browser on this machine unless asked
+const defaultServeAddr = "localhost:7070"
+
+func handleServe(args []string) {
+	serveFlags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := serveFlags.String("addr", defaultServeAddr, "Address to listen on (e.g. :7070 to share on the network)")
+	includePrivate := serveFlags.Bool("include-private", false, "Show private and redacted commits as recorded")
+	sync := serveFlags.Bool("sync", false, "Let other machines push to and pull from this repository")
+
+	serveFlags.Parse(args)
+
+	repo, path := loadRepository()
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listening on %s: %v\n", *addr, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Serving %s at http://%s/\n", path, listener.Addr())
+	if !*includePrivate {
+		if rules, err := repo.Privacy(); err == nil && !rules.Empty() {
+			fmt.Printf("Privacy rules apply: private commits are hidden, redacted ones show no code\n")
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", web.NewServer(path, *includePrivate))
+	if *sync {
+		// Sync exchanges every commit, so privacy rules don't apply to it
+		token := os.Getenv(remote.TokenEnv)
+		mux.Handle("/sync/", remote.NewHandler(path, token))
+		if token == "" {
+			fmt.Printf("Sync enabled: anyone who can reach %s can push and pull (set %s to require a token)\n", listener.Addr(), remote.TokenEnv)
+		} else {
+			fmt.Printf("Sync enabled with the token in %s\n", remote.TokenEnv)
+		}
+	}
+	fmt.Printf("Press Ctrl+C to stop.\n")
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if err := server.Serve(listener); err != nil {
+		fmt.Fprintf(os.Stderr, "Error serving history: %v\n", err)
+		os.Exit(1)
+	}
+}
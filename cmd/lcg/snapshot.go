@@ -0,0 +1,196 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/diff"
+	"github.com/livecodegit/pkg/replay"
+)
+
+func handleSnapshot(args []string) {
+	subcommand := "save"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		subcommand = args[0]
+		args = args[1:]
+	}
+
+	switch subcommand {
+	case "save":
+		handleSnapshotSave(args)
+	case "restore":
+		handleSnapshotRestore(args)
+	case "diff":
+		handleSnapshotDiff(args)
+	case "list":
+		handleSnapshotList(args)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown snapshot command: %s\n", subcommand)
+		fmt.Fprintf(os.Stderr, "Usage: lcg snapshot [save|restore|diff|list] [options]\n")
+		os.Exit(1)
+	}
+}
+
+func handleSnapshotSave(args []string) {
+	saveFlags := flag.NewFlagSet("snapshot save", flag.ExitOnError)
+	saveFlags.Parse(args)
+
+	repo, _ := loadRepository()
+
+	snapshot, err := repo.Snapshot()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error taking snapshot: %v\n", err)
+		os.Exit(1)
+	}
+
+	noun := "buffers"
+	if len(snapshot.Entries) == 1 {
+		noun = "buffer"
+	}
+	fmt.Printf("Snapshot %s (%d %s)\n", colorHash(snapshot.Hash[:8]), len(snapshot.Entries), noun)
+}
+
+// handleSnapshotRestore puts every buffer of a snapshot back, like
+// checkpoint restore
+func handleSnapshotRestore(args []string) {
+	restoreFlags := flag.NewFlagSet("snapshot restore", flag.ExitOnError)
+	outDir := restoreFlags.String("out", "", "Write the buffers into files in this directory (default: the repository, unless --osc is given)")
+	oscTarget := restoreFlags.String("osc", "", "Send the buffers to an OSC target, e.g. localhost:57120")
+	oscAddress := restoreFlags.String("osc-address", replay.DefaultOSCAddress, "OSC address for --osc")
+
+	refs := parseInterspersed(restoreFlags, args)
+	if len(refs) > 1 {
+		fmt.Fprintf(os.Stderr, "Usage: lcg snapshot restore [hash] [--out dir] [--osc host:port]\n")
+		os.Exit(1)
+	}
+	var ref string
+	if len(refs) == 1 {
+		ref = refs[0]
+	}
+
+	repo, path := loadRepository()
+
+	snapshot, err := repo.GetSnapshot(ref)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	commits, err := repo.SnapshotCommits(snapshot)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading snapshot: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *outDir == "" && *oscTarget == "" {
+		*outDir = path
+	}
+	targets := restoreCommits(commits, *outDir, *oscTarget, *oscAddress)
+
+	noun := "buffers"
+	if len(commits) == 1 {
+		noun = "buffer"
+	}
+	fmt.Printf("Restored snapshot %s: %d %s to %s\n", snapshot.Hash[:8], len(commits), noun, strings.Join(targets, " and "))
+}
+
+// handleSnapshotDiff shows what changed between two snapshots, buffer by
+// buffer; without a second snapshot, up to the buffers as they are now
+func handleSnapshotDiff(args []string) {
+	diffFlags := flag.NewFlagSet("snapshot diff", flag.ExitOnError)
+	diffFlags.Parse(args)
+
+	if diffFlags.NArg() < 1 || diffFlags.NArg() > 2 {
+		fmt.Fprintf(os.Stderr, "Usage: lcg snapshot diff <hash> [hash]\n")
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
+	from, err := repo.GetSnapshot(diffFlags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	var to *core.Snapshot
+	if diffFlags.NArg() == 2 {
+		to, err = repo.GetSnapshot(diffFlags.Arg(1))
+	} else {
+		to, err = repo.CurrentSnapshot()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	changes := core.DiffSnapshots(from, to)
+	if len(changes) == 0 {
+		fmt.Println("No buffers changed")
+		return
+	}
+
+	for _, change := range changes {
+		var before, after []string
+		var fromHash, toHash string
+		if change.From != nil {
+			before = diff.Lines(change.From.Content)
+			fromHash = change.From.Commit[:8]
+		}
+		if change.To != nil {
+			after = diff.Lines(change.To.Content)
+			toHash = change.To.Commit[:8]
+		}
+
+		switch change.Kind {
+		case core.SnapshotAdded:
+			fmt.Printf("%s added %s\n", change.Buffer, colorHash(toHash))
+		case core.SnapshotRemoved:
+			fmt.Printf("%s removed (was %s)\n", change.Buffer, colorHash(fromHash))
+		default:
+			fmt.Printf("%s %s..%s\n", change.Buffer, colorHash(fromHash), colorHash(toHash))
+		}
+		for _, line := range diff.Script(before, after) {
+			switch line.Op {
+			case diff.Insert:
+				fmt.Println(colorResult(true, "+"+line.Text))
+			case diff.Delete:
+				fmt.Println(colorResult(false, "-"+line.Text))
+			default:
+				fmt.Println(" " + line.Text)
+			}
+		}
+		fmt.Println()
+	}
+}
+
+func handleSnapshotList(args []string) {
+	listFlags := flag.NewFlagSet("snapshot list", flag.ExitOnError)
+	listFlags.Parse(args)
+
+	repo, _ := loadRepository()
+
+	snapshots, err := repo.Snapshots()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading snapshots: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(snapshots) == 0 {
+		fmt.Println("No snapshots (take one with 'lcg snapshot')")
+		return
+	}
+
+	for _, snapshot := range snapshots {
+		buffers := make([]string, 0, len(snapshot.Entries))
+		for _, entry := range snapshot.Entries {
+			buffers = append(buffers, entry.Buffer)
+		}
+
+		fmt.Printf("%s %s  %s\n",
+			colorHash(snapshot.Hash[:8]),
+			colorTime(snapshot.Time.Format("2006-01-02 15:04:05")),
+			strings.Join(buffers, ", "))
+	}
+}
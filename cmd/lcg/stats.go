@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+// statsMaxTimeline bounds the buckets an explicit --bucket may produce
+const statsMaxTimeline = 1000
+
+// statsBarWidth is the width of the longest bar in the timeline
+const statsBarWidth = 30
+
+// handleStats summarizes the history, or one performance, by buffer,
+// language and time
+func handleStats(args []string) {
+	statsFlags := flag.NewFlagSet("stats", flag.ExitOnError)
+	performanceRef := statsFlags.String("performance", "", "Only count the commits of this performance (ID or name)")
+	bucket := statsFlags.Duration("bucket", 0, "Timeline interval, e.g. 5m (default: picked from the span)")
+	jsonOutput := statsFlags.Bool("json", false, "Print the statistics as JSON")
+
+	statsFlags.Parse(args)
+
+	if *bucket < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --bucket must be positive\n")
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
+	var commits []*core.Commit
+	var err error
+	if *performanceRef != "" {
+		commits, err = repo.PerformanceCommits(selectPerformance(repo, *performanceRef))
+	} else {
+		commits, err = repo.History()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *bucket > 0 && len(commits) > 0 {
+		if span := commits[len(commits)-1].Timestamp.Sub(commits[0].Timestamp); span / *bucket > statsMaxTimeline {
+			fmt.Fprintf(os.Stderr, "Error: a %s bucket splits %s into more than %d intervals\n",
+				*bucket, formatElapsed(span), statsMaxTimeline)
+			os.Exit(1)
+		}
+	}
+
+	stats := core.ComputeStats(commits, *bucket)
+
+	if *jsonOutput {
+		data, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding statistics: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	if stats.Commits == 0 {
+		fmt.Println("No commits yet")
+		return
+	}
+
+	fmt.Printf("Commits: %d (%d successful, %s, %.1f%% success)\n",
+		stats.Commits, stats.Successes, colorResult(stats.Errors == 0, fmt.Sprintf("%d errors", stats.Errors)),
+		stats.SuccessRate*100)
+	fmt.Printf("Span: %s to %s (%s)\n",
+		colorTime(stats.First.Format("2006-01-02 15:04")), colorTime(stats.Last.Format("2006-01-02 15:04")),
+		formatElapsed(stats.Last.Sub(stats.First)))
+
+	nameWidth := 0
+	for _, buffer := range stats.Buffers {
+		nameWidth = max(nameWidth, len(buffer.Name))
+	}
+	for _, language := range stats.Languages {
+		nameWidth = max(nameWidth, len(language.Name))
+	}
+
+	fmt.Printf("\nBuffers:\n")
+	for _, buffer := range stats.Buffers {
+		fmt.Printf("  %s %s %s\n", padRight(buffer.Name, nameWidth), colorLanguage(padRight(buffer.Language, 7)),
+			formatCounts(buffer))
+	}
+
+	fmt.Printf("\nLanguages:\n")
+	for _, language := range stats.Languages {
+		name := language.Name
+		if name == "" {
+			name = "(none)"
+		}
+		fmt.Printf("  %s %s\n", colorLanguage(padRight(name, nameWidth+8)), formatCounts(language))
+	}
+
+	peak := 0
+	for _, bucket := range stats.Timeline {
+		peak = max(peak, bucket.Commits)
+	}
+
+	layout := "15:04"
+	if stats.First.Format("2006-01-02") != stats.Last.Format("2006-01-02") {
+		layout = "01-02 15:04"
+	}
+
+	fmt.Printf("\nTimeline (%s intervals):\n", formatInterval(stats.BucketSize))
+	for _, bucket := range stats.Timeline {
+		// Bars are padded by hand; padRight counts bytes
+		width := bucket.Commits * statsBarWidth / max(peak, 1)
+		bar := strings.Repeat("█", width)
+		if bucket.Commits > 0 && width == 0 {
+			bar, width = "▏", 1
+		}
+		bar += strings.Repeat(" ", statsBarWidth-width)
+		tempo := ""
+		if bucket.BPM > 0 {
+			tempo = fmt.Sprintf(" %.0f bpm", bucket.BPM)
+		}
+		fmt.Printf("  %s %4d %6.2f/min %s%s\n", colorTime(bucket.Start.Format(layout)), bucket.Commits,
+			bucket.PerMinute, bar, colorDim(tempo))
+	}
+}
+
+// formatCounts renders commits and errors with the success rate
+func formatCounts(count core.CountStats) string {
+	rate := float64(count.Commits-count.Errors) / float64(count.Commits) * 100
+	return fmt.Sprintf("%4d commits %s %5.1f%%", count.Commits,
+		colorResult(count.Errors == 0, fmt.Sprintf("%3d errors", count.Errors)), rate)
+}
+
+// formatInterval renders a whole duration without zero units, e.g. 5m or 2h
+func formatInterval(d time.Duration) string {
+	text := d.String()
+	if strings.HasSuffix(text, "m0s") {
+		text = strings.TrimSuffix(text, "0s")
+	}
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return text
+}
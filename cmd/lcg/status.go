@@ -0,0 +1,199 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/daemon"
+	"github.com/livecodegit/pkg/watchers"
+)
+
+func handleStatus(args []string) {
+	statusFlags := flag.NewFlagSet("status", flag.ExitOnError)
+	configPath := statusFlags.String("config", "", "Path to watcher configuration file")
+
+	statusFlags.Parse(args)
+
+	repo, path := loadRepository()
+
+	fmt.Printf("Repository: %s\n", path)
+	if timeline, err := repo.Timeline(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else if timeline != nil {
+		names := make([]string, len(timeline.Performers))
+		for i, performer := range timeline.Performers {
+			names[i] = performer.Name
+		}
+		fmt.Printf("Merged timeline of %s (read-only)\n", strings.Join(names, ", "))
+	}
+	fmt.Println()
+
+	// HEAD and last commit
+	commits, err := repo.Log(1)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error retrieving HEAD commit: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(commits) == 0 {
+		fmt.Printf("  HEAD: (no commits)\n")
+	} else {
+		head := commits[0]
+		fmt.Printf("  HEAD: %s %s\n", colorHash(head.Hash[:8]), head.Message)
+		fmt.Printf("  Last Commit: %s (%s ago)\n",
+			colorTime(head.Timestamp.Format("2006-01-02 15:04:05")), formatElapsed(time.Since(head.Timestamp)))
+		fmt.Printf("  Last Result: %s in %s (%s)\n", formatResult(head.Metadata.Success),
+			colorLanguage(head.Metadata.Language), head.Metadata.Buffer)
+	}
+
+	// Active performance
+	performance, _ := repo.GetCurrentPerformance()
+	if performance == nil {
+		fmt.Printf("  Active Performance: none\n")
+	} else {
+		fmt.Printf("  Active Performance: %s (%s)\n", performance.Name, performance.ID)
+		fmt.Printf("    Started: %s (%s ago)\n",
+			colorTime(performance.StartTime.Format("2006-01-02 15:04:05")), formatElapsed(time.Since(performance.StartTime)))
+		fmt.Printf("    Commits: %d\n", performance.CommitCount)
+	}
+
+	// Watcher configuration
+	if *configPath == "" {
+		*configPath = watchers.ResolveConfigPath(path)
+	}
+
+	configManager := watchers.NewConfigManager(*configPath)
+	if err := configManager.LoadConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading watcher configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\nWatchers:\n")
+	enabled := configManager.GetEnabledWatchers()
+	if len(enabled) == 0 {
+		fmt.Printf("  Enabled: none\n")
+	} else {
+		fmt.Printf("  Enabled: %s\n", paint(ansiGreen, strings.Join(enabled, ", ")))
+	}
+
+	// Executions waiting in the inbox outlive the watcher process
+	if count, err := watchers.NewPendingStore(path).Count(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else if count > 0 {
+		fmt.Printf("  Inbox: %d pending (review with 'lcg pending')\n", count)
+	}
+
+	printMaintenance(configManager.GetConfig().Maintenance, path)
+
+	// Watcher service reported by a running 'lcg watch'
+	state, err := watchers.ReadServiceState(watchers.GetStatePath(path))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	if state == nil || !processAlive(state.PID) {
+		fmt.Printf("  Service: %s\n", colorDim("not running"))
+		return
+	}
+
+	fmt.Printf("  Service: %s (pid %d, up %s)\n", colorResult(true, "running"), state.PID, formatElapsed(time.Since(state.StartedAt)))
+	if daemon.Running(path) {
+		fmt.Printf("  Daemon: %s (stop with 'lcg daemon stop')\n", daemon.SocketPath(path))
+	}
+	fmt.Printf("  Executions: %d\n", state.Stats.TotalExecutions)
+	fmt.Printf("  Commits: %d\n", state.Stats.TotalCommits)
+	fmt.Printf("  Pending Events: %d\n", state.Stats.PendingEvents)
+	if state.Stats.QueuedEvents > 0 {
+		fmt.Printf("  Queued for Retry: %d\n", state.Stats.QueuedEvents)
+	}
+	if state.Stats.Latency.Samples > 0 {
+		fmt.Printf("  Commit Latency: %s\n", formatLatency(state.Stats.Latency))
+	}
+	if state.ControlPort > 0 {
+		fmt.Printf("  OSC Control: UDP port %d\n", state.ControlPort)
+	}
+	if !state.Stats.LastExecution.IsZero() {
+		fmt.Printf("  Last Execution: %s\n", colorTime(state.Stats.LastExecution.Format("2006-01-02 15:04:05")))
+	}
+}
+
+// printMaintenance shows the maintenance schedule and how each task last went
+func printMaintenance(config watchers.MaintenanceConfig, path string) {
+	results, err := watchers.ReadMaintenanceResults(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return
+	}
+	if !config.Enabled && len(results) == 0 {
+		return
+	}
+
+	if !config.Enabled {
+		fmt.Printf("  Maintenance: %s\n", colorDim("off"))
+	} else {
+		schedule := fmt.Sprintf("every %s after %s idle", config.Interval, config.Idle)
+		if config.Window != "" {
+			schedule += ", " + config.Window
+		}
+		fmt.Printf("  Maintenance: %s\n", schedule)
+	}
+
+	// Scheduled tasks first, in order, then any run before they were unscheduled
+	tasks := config.Tasks
+	var others []string
+	for task := range results {
+		if !slices.Contains(tasks, task) {
+			others = append(others, task)
+		}
+	}
+	sort.Strings(others)
+
+	for _, task := range append(slices.Clone(tasks), others...) {
+		result, ok := results[task]
+		if !ok {
+			fmt.Printf("    %s: %s\n", task, colorDim("not run yet"))
+			continue
+		}
+		outcome := colorResult(true, "ok")
+		if !result.Success {
+			outcome = colorResult(false, "failed")
+		}
+		fmt.Printf("    %s: %s %s ago, %s\n", task, outcome, formatElapsed(time.Since(result.Time)), result.Message)
+	}
+}
+
+// formatResult renders an execution result as success or error
+func formatResult(success bool) string {
+	if success {
+		return colorResult(true, "success")
+	}
+	return colorResult(false, "error")
+}
+
+// formatLatency renders commit latency stats on one line
+func formatLatency(stats watchers.LatencyStats) string {
+	return fmt.Sprintf("p50 %s, p95 %s, max %s (last %d commits)",
+		roundLatency(stats.P50), roundLatency(stats.P95), roundLatency(stats.Max), stats.Samples)
+}
+
+// roundLatency rounds a latency to a resolution that still tells apart a
+// quick commit from a slow one
+func roundLatency(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(100 * time.Microsecond).String()
+	}
+	return d.Round(10 * time.Millisecond).String()
+}
+
+// formatElapsed renders a duration at a resolution suitable for status output
+func formatElapsed(d time.Duration) string {
+	if d < time.Hour {
+		return d.Round(time.Second).String()
+	}
+	return d.Round(time.Minute).String()
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+func handleTemplate(args []string) {
+	subcommand := "list"
+	if len(args) > 0 {
+		subcommand = args[0]
+		args = args[1:]
+	}
+
+	switch subcommand {
+	case "add":
+		handleTemplateAdd(args)
+	case "list":
+		handleTemplateList(args)
+	case "show":
+		handleTemplateShow(args)
+	case "remove":
+		handleTemplateRemove(args)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown template command: %s\n", subcommand)
+		fmt.Fprintf(os.Stderr, "Usage: lcg template [add|list|show|remove] [options]\n")
+		os.Exit(1)
+	}
+}
+
+func handleTemplateAdd(args []string) {
+	addFlags := flag.NewFlagSet("template add", flag.ExitOnError)
+	description := addFlags.String("d", "", "Template description")
+	duration := addFlags.Duration("duration", 0, "Planned length of the performance, e.g. 45m")
+	var sections, buffers stringList
+	addFlags.Var(&sections, "section", "Planned section as label@offset, e.g. drop@25m (repeatable)")
+	addFlags.Var(&buffers, "buffer", "Expected buffer as name or name:language, e.g. d1:tidal (repeatable)")
+
+	names := parseInterspersed(addFlags, args)
+	if len(names) != 1 {
+		fmt.Fprintf(os.Stderr, "Error: a template name is required (quote names containing spaces)\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg template add <name> [--duration 45m] [--section label@offset]... [--buffer name:language]...\n")
+		os.Exit(1)
+	}
+
+	template := &core.Template{
+		Name:        names[0],
+		Description: *description,
+		Duration:    core.Offset(*duration),
+	}
+
+	for _, section := range sections {
+		label, at, found := strings.Cut(section, "@")
+		offset, err := time.ParseDuration(at)
+		if !found || err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid section %q (expected label@offset, e.g. drop@25m)\n", section)
+			os.Exit(1)
+		}
+		template.Sections = append(template.Sections, core.PlannedSection{Label: label, At: core.Offset(offset)})
+	}
+
+	for _, buffer := range buffers {
+		name, language, _ := strings.Cut(buffer, ":")
+		template.Buffers = append(template.Buffers, core.PlannedBuffer{Name: name, Language: language})
+	}
+
+	repo, _ := loadRepository()
+
+	if err := repo.SaveTemplate(template); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving template: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Saved template %s (%d sections, %d buffers)\n", template.Name, len(template.Sections), len(template.Buffers))
+}
+
+func handleTemplateList(args []string) {
+	listFlags := flag.NewFlagSet("template list", flag.ExitOnError)
+	listFlags.Parse(args)
+
+	repo, _ := loadRepository()
+
+	templates, err := repo.Templates()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading templates: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(templates) == 0 {
+		fmt.Println("No templates (add one with 'lcg template add')")
+		return
+	}
+
+	nameWidth := 0
+	for _, template := range templates {
+		nameWidth = max(nameWidth, len(template.Name))
+	}
+
+	for _, template := range templates {
+		length := "-"
+		if template.Duration > 0 {
+			length = time.Duration(template.Duration).String()
+		}
+		fmt.Printf("%s %s %2d sections, %2d buffers  %s\n",
+			padRight(template.Name, nameWidth),
+			colorTime(padRight(length, 8)),
+			len(template.Sections),
+			len(template.Buffers),
+			template.Description)
+	}
+}
+
+func handleTemplateShow(args []string) {
+	showFlags := flag.NewFlagSet("template show", flag.ExitOnError)
+	showFlags.Parse(args)
+
+	if showFlags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: lcg template show <name>\n")
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
+	template, err := repo.GetTemplate(showFlags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Template: %s\n", template.Name)
+	if template.Description != "" {
+		fmt.Printf("Description: %s\n", template.Description)
+	}
+	if template.Duration > 0 {
+		fmt.Printf("Duration: %s\n", time.Duration(template.Duration))
+	}
+
+	if len(template.Sections) > 0 {
+		fmt.Printf("\nSections:\n")
+		for _, section := range template.Sections {
+			fmt.Printf("  %s %s\n", colorTime(formatOffset(time.Duration(section.At))), section.Label)
+		}
+	}
+
+	if len(template.Buffers) > 0 {
+		fmt.Printf("\nBuffers:\n")
+		for _, buffer := range template.Buffers {
+			fmt.Printf("  %s %s\n", buffer.Name, colorLanguage(buffer.Language))
+		}
+	}
+}
+
+func handleTemplateRemove(args []string) {
+	removeFlags := flag.NewFlagSet("template remove", flag.ExitOnError)
+	removeFlags.Parse(args)
+
+	if removeFlags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: lcg template remove <name>\n")
+		os.Exit(1)
+	}
+
+	repo, _ := loadRepository()
+
+	if err := repo.RemoveTemplate(removeFlags.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Removed template %s\n", removeFlags.Arg(0))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+func handleTimeline(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: timeline subcommand is required\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg timeline merge <repo> <repo>... --out <dir>\n")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "merge":
+		handleTimelineMerge(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown timeline subcommand: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// handleTimelineMerge combines the repositories of an ensemble into one
+// read-only repository for reviewing the performance together
+func handleTimelineMerge(args []string) {
+	mergeFlags := flag.NewFlagSet("timeline merge", flag.ExitOnError)
+	out := mergeFlags.String("out", "", "Directory of the merged repository")
+	name := mergeFlags.String("performance", "Ensemble", "Name of the performance spanning the merged timeline")
+
+	refs := parseInterspersed(mergeFlags, args)
+	if len(refs) < 2 || *out == "" {
+		fmt.Fprintf(os.Stderr, "Error: at least two repositories and --out are required\n")
+		fmt.Fprintf(os.Stderr, "Usage: lcg timeline merge [name=]<repo> [name=]<repo>... --out <dir>\n")
+		os.Exit(1)
+	}
+
+	sources := make([]core.TimelineSource, 0, len(refs))
+	for _, ref := range refs {
+		performer, path := timelineSource(ref)
+		repo, err := core.LoadRepository(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading repository: %v\n", err)
+			os.Exit(1)
+		}
+		if performer == "" {
+			performer = timelinePerformer(repo, path)
+		}
+		sources = append(sources, core.TimelineSource{Performer: performer, Repo: repo})
+	}
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", *out, err)
+		os.Exit(1)
+	}
+
+	merged, err := core.MergeTimelines(*out, *name, sources)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error merging timelines: %v\n", err)
+		os.Exit(1)
+	}
+
+	timeline, err := merged.Timeline()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading merged timeline: %v\n", err)
+		os.Exit(1)
+	}
+
+	total := 0
+	for _, performer := range timeline.Performers {
+		fmt.Printf("  %s %4d commits from %s\n", padRight(performer.Name, 12), performer.Commits, performer.Source)
+		total += performer.Commits
+	}
+	fmt.Printf("Merged %d commits into %s (read-only)\n", total, *out)
+}
+
+// timelineSource splits a name=path argument; a plain path has no name
+func timelineSource(ref string) (string, string) {
+	if name, path, found := strings.Cut(ref, "="); found && name != "" && !strings.ContainsAny(name, `/\`) {
+		return name, path
+	}
+	return "", ref
+}
+
+// timelinePerformer names a performer after the repository's configured user,
+// or its directory
+func timelinePerformer(repo *core.LiveCodeRepository, path string) string {
+	if config, err := repo.Config(); err == nil {
+		if name := strings.Join(strings.Fields(config.Settings().User.Name), "-"); name != "" {
+			return name
+		}
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return filepath.Base(path)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/livecodegit/pkg/tui"
+)
+
+func handleTUI(args []string) {
+	tuiFlags := flag.NewFlagSet("tui", flag.ExitOnError)
+	limit := tuiFlags.Int("n", tui.DefaultLimit, "Number of recent commits to browse")
+	buffer := tuiFlags.String("buffer", "", "Only show one buffer initially")
+
+	tuiFlags.Parse(args)
+
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		fmt.Fprintf(os.Stderr, "Error: lcg tui needs an interactive terminal (use lcg log when piping)\n")
+		os.Exit(1)
+	}
+
+	repo, path := loadRepository()
+
+	options := tui.Options{
+		Limit:       *limit,
+		Buffer:      *buffer,
+		CheckoutDir: path,
+	}
+
+	if err := tui.Run(repo, options, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error running history browser: %v\n", err)
+		os.Exit(1)
+	}
+}
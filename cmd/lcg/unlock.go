@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// handleUnlock shows who holds the repository lock, and with --force removes
+// a lock left behind by a process that died
+func handleUnlock(args []string) {
+	unlockFlags := flag.NewFlagSet("unlock", flag.ExitOnError)
+	force := unlockFlags.Bool("force", false, "Remove the lock even though a process may hold it")
+	unlockFlags.Parse(args)
+
+	repo, _ := loadRepository()
+
+	holder, err := repo.LockHolder()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading lock: %v\n", err)
+		os.Exit(1)
+	}
+	if holder == nil {
+		fmt.Println("The repository isn't locked")
+		return
+	}
+
+	description := fmt.Sprintf("process %d on %s since %s", holder.PID, holder.Host, colorTime(holder.Time.Local().Format("2006-01-02 15:04:05")))
+	if !*force {
+		fmt.Printf("Locked by %s\n", description)
+		fmt.Fprintf(os.Stderr, "If that process is no longer running, remove the lock with lcg unlock --force\n")
+		os.Exit(1)
+	}
+
+	if _, err := repo.ForceUnlock(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error removing lock: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Removed the lock held by %s\n", description)
+}
@@ -1,18 +1,37 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/journal"
 	"github.com/livecodegit/pkg/watchers"
 )
 
+// watchLogFile receives the output of a watcher started with --detach
+const watchLogFile = "watch.log"
+
+// stringList collects the values of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 func handleWatch(args []string) {
 	watchFlags := flag.NewFlagSet("watch", flag.ExitOnError)
 	language := watchFlags.String("lang", "", "Language to watch (sonicpi, tidal)")
@@ -21,38 +40,82 @@ func handleWatch(args []string) {
 	showStatus := watchFlags.Bool("status", false, "Show watcher status")
 	enableWatcher := watchFlags.String("enable", "", "Enable a specific watcher")
 	disableWatcher := watchFlags.String("disable", "", "Disable a specific watcher")
+	setOption := watchFlags.String("set", "", "Set a watcher option, e.g. sonicpi-osc.osc_port=4560, or a maintenance one, e.g. maintenance.enabled=true")
+	addWatcher := watchFlags.String("add", "", "Add a watcher running an external program, e.g. foxdot=\"foxdot-watcher --port 9000\" (language from --lang)")
+	local := watchFlags.Bool("local", false, "Use this repository's own watcher configuration, creating it from the global one")
+	testWatcher := watchFlags.String("test", "", "Run one watcher briefly and show what it would commit")
+	wait := watchFlags.Bool("wait", false, "With --test, wait for a real execution instead of injecting a test one")
+	timeout := watchFlags.Duration("timeout", 10*time.Second, "With --test, how long to wait for an execution")
+	controlPort := watchFlags.Int("control", 0, "Accept OSC control messages on this UDP port (default: control_port from config)")
+	detach := watchFlags.Bool("detach", false, "Keep watching in the background, with the pid in .livecodegit/watch.pid")
+	stopDetached := watchFlags.Bool("stop", false, "Stop the watcher started with --detach")
+	var repoPaths stringList
+	watchFlags.Var(&repoPaths, "repo", "Watch this repository (repeatable) instead of the current one")
 
 	watchFlags.Parse(args)
 
-	// Get current directory
-	path, err := os.Getwd()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting current directory: %v\n", err)
+	if *detach && (*listWatchers || *showStatus || *enableWatcher != "" || *disableWatcher != "" || *setOption != "" || *addWatcher != "" || *testWatcher != "" || *stopDetached) {
+		fmt.Fprintf(os.Stderr, "Error: --detach only applies when starting to watch\n")
 		os.Exit(1)
 	}
 
-	// Load repository
-	repo, err := core.LoadRepository(path)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading repository: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Make sure you're in a LiveCodeGit repository (run 'lcg init' first)\n")
-		os.Exit(1)
+	if len(repoPaths) > 0 {
+		if *language != "" || *listWatchers || *showStatus || *enableWatcher != "" || *disableWatcher != "" || *setOption != "" || *addWatcher != "" || *local || *configPath != "" || *testWatcher != "" || *controlPort != 0 || *stopDetached {
+			fmt.Fprintf(os.Stderr, "Error: --repo only starts watching; configure each repository from inside it\n")
+			os.Exit(1)
+		}
+		if *detach {
+			absPaths := make([]string, len(repoPaths))
+			for i, repoPath := range repoPaths {
+				absPath, err := filepath.Abs(repoPath)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error resolving path %s: %v\n", repoPath, err)
+					os.Exit(1)
+				}
+				absPaths[i] = absPath
+			}
+			detachWatch(args, absPaths)
+			return
+		}
+		handleWatchRepositories(repoPaths)
+		return
 	}
 
-	// Set default config path if not provided
+	repo, path := loadRepository()
+
+	// Prefer the repository's own configuration, then the global one
+	if *local {
+		if *configPath != "" {
+			fmt.Fprintf(os.Stderr, "Error: --local and --config cannot be used together\n")
+			os.Exit(1)
+		}
+		localPath, err := watchers.InitRepoConfig(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating repository watcher configuration: %v\n", err)
+			os.Exit(1)
+		}
+		*configPath = localPath
+	}
 	if *configPath == "" {
-		*configPath = watchers.GetDefaultConfigPath()
+		*configPath = watchers.ResolveConfigPath(path)
 	}
 
-	// Create watcher service
-	service := watchers.NewWatcherService(repo, *configPath)
-
-	// Initialize service
-	if err := service.Initialize(); err != nil {
+	// Create and initialize the watcher service
+	multi := watchers.NewMultiRepoService()
+	service, err := multi.AddRepository(path, repo, *configPath)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error initializing watcher service: %v\n", err)
 		os.Exit(1)
 	}
 
+	if flagWasSet(watchFlags, "control") {
+		if *controlPort < 0 || *controlPort > 65535 {
+			fmt.Fprintf(os.Stderr, "Error: invalid control port %d\n", *controlPort)
+			os.Exit(1)
+		}
+		service.SetControlPort(*controlPort)
+	}
+
 	// Handle different watch commands
 	if *listWatchers {
 		handleListWatchers(service)
@@ -60,7 +123,12 @@ func handleWatch(args []string) {
 	}
 
 	if *showStatus {
-		handleShowStatus(service)
+		handleShowStatus(service, path)
+		return
+	}
+
+	if *stopDetached {
+		handleStopDetached(path)
 		return
 	}
 
@@ -74,12 +142,69 @@ func handleWatch(args []string) {
 		return
 	}
 
+	if *setOption != "" {
+		handleSetWatcherOption(service, *setOption)
+		return
+	}
+
+	if *addWatcher != "" {
+		handleAddWatcher(service, *addWatcher, *language)
+		return
+	}
+
+	if *testWatcher != "" {
+		handleTestWatcher(service, *testWatcher, !*wait, *timeout)
+		return
+	}
+
 	// Start watching
+	if *detach {
+		detachWatch(args, []string{path})
+		return
+	}
 	if *language != "" {
-		handleStartWatchingLanguage(service, *language)
+		handleStartWatchingLanguage(multi, service, *language)
 	} else {
-		handleStartWatchingAll(service)
+		handleStartWatchingAll(multi, service)
+	}
+}
+
+// handleWatchRepositories runs the enabled watchers of several repositories
+// in this one process, each committing to its own repository
+func handleWatchRepositories(repoPaths []string) {
+	multi := watchers.NewMultiRepoService()
+
+	for _, repoPath := range repoPaths {
+		absPath, err := filepath.Abs(repoPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error resolving path %s: %v\n", repoPath, err)
+			os.Exit(1)
+		}
+
+		repo, err := core.LoadRepository(absPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading repository %s: %v\n", absPath, err)
+			os.Exit(1)
+		}
+
+		service, err := multi.AddRepository(absPath, repo, watchers.ResolveConfigPath(absPath))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error initializing watcher service: %v\n", err)
+			os.Exit(1)
+		}
+
+		enabled := service.GetEnabledWatchers()
+		if len(enabled) == 0 {
+			fmt.Fprintf(os.Stderr, "No watchers are enabled for %s\n", absPath)
+			fmt.Fprintf(os.Stderr, "Enable one from inside it first: lcg watch --local --enable <watcher-name>\n")
+			os.Exit(1)
+		}
+
+		fmt.Printf("%s: %s\n", absPath, strings.Join(enabled, ", "))
 	}
+
+	fmt.Printf("Starting watchers for %d repositories...\n", len(repoPaths))
+	startWatcherService(multi)
 }
 
 func handleListWatchers(service *watchers.WatcherService) {
@@ -87,7 +212,7 @@ func handleListWatchers(service *watchers.WatcherService) {
 
 	fmt.Printf("Available Watchers:\n\n")
 
-	watchers := []struct {
+	available := []struct {
 		name        string
 		language    string
 		environment string
@@ -96,42 +221,108 @@ func handleListWatchers(service *watchers.WatcherService) {
 		{"sonicpi-osc", "sonicpi", "sonic-pi", "Monitors Sonic Pi OSC messages for execution events"},
 		{"sonicpi-files", "sonicpi", "sonic-pi-files", "Watches Sonic Pi workspace files for changes"},
 		{"tidal-ghci", "tidal", "tidal-cycles", "Monitors TidalCycles through GHCi interaction"},
+		{"tidal-hook", "tidal", "tidal-hook", "Receives evaluations from the BootTidal hook ('lcg integrate tidal')"},
+		{"hydra", "hydra", "hydra", "Receives Hydra evaluations from a browser snippet over WebSocket"},
+		{"strudel", "strudel", "strudel", "Receives Strudel evaluations, with tempo and cycle, from a browser snippet"},
+		{"browser", "gibber", "browser", "Receives evaluations POSTed by a snippet pasted into Gibber or another web tool"},
+		{"osc-generic", "osc", "osc-generic", "Maps the arguments of OSC messages from any environment to executions"},
+		{"editor-http", "tidal", "vscode", "Receives evaluations POSTed by VS Code or other editor extensions, with a token"},
+		{"emacs", "tidal", "emacs", "Records tidal.el evaluations from a GHCi transcript, or any mode's over TCP ('lcg integrate emacs')"},
+		{"pulsar", "tidal", "pulsar", "Records Pulsar's tidalcycles evaluations and errors from a GHCi wrapper's log ('lcg integrate pulsar')"},
+		{"editor-jsonl", "tidal", "neovim", "Reads JSON execution events from Neovim or Kakoune plugins over TCP or a named pipe"},
+		{"midi-clock", "midi", "midi-clock", "Follows incoming MIDI clock to stamp commits with tempo and beat"},
+		{"ableton-link", "link", "ableton-link", "Follows the Ableton Link session to stamp commits with tempo, beat and phase"},
+		{"tidal-superdirt", "tidal", "superdirt", "Listens to Tidal's messages to SuperDirt to stamp commits with cycle, orbits and sounds"},
+	}
+	for _, name := range service.ListWatchers() {
+		config, _ := service.GetWatcherConfig(name)
+		description := "Runs " + config.Command
+		if config.Type == watchers.WatcherTypePlugin {
+			description = "Plugin " + config.Command
+		} else if config.Type != watchers.WatcherTypeExec {
+			continue
+		}
+		available = append(available, struct {
+			name        string
+			language    string
+			environment string
+			description string
+		}{name, config.Language, config.Environment, description})
 	}
 
-	for _, w := range watchers {
-		enabled := contains(enabledWatchers, w.name)
-		status := "disabled"
-		if enabled {
-			status = "enabled"
+	for _, w := range available {
+		status := colorDim("disabled")
+		if contains(enabledWatchers, w.name) {
+			status = colorResult(true, "enabled")
 		}
 
 		fmt.Printf("  %s (%s)\n", w.name, status)
-		fmt.Printf("    Language: %s\n", w.language)
+		fmt.Printf("    Language: %s\n", colorLanguage(w.language))
 		fmt.Printf("    Environment: %s\n", w.environment)
 		fmt.Printf("    Description: %s\n", w.description)
 
 		// Show configuration
 		if config, exists := service.GetWatcherConfig(w.name); exists {
+			if config.Author != "" {
+				fmt.Printf("    Author: %s\n", formatAuthor(config.Author, config.AuthorEmail))
+			}
 			fmt.Printf("    Options:\n")
-			for key, value := range config.Options {
-				fmt.Printf("      %s: %s\n", key, value)
+			keys := make([]string, 0, len(config.Options))
+			width := 0
+			for key := range config.Options {
+				keys = append(keys, key)
+				width = max(width, len(key)+1)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Printf("      %s %s\n", padRight(key+":", width), config.Options[key])
 			}
 		}
 		fmt.Printf("\n")
 	}
 }
 
-func handleShowStatus(service *watchers.WatcherService) {
+func handleShowStatus(service *watchers.WatcherService, path string) {
 	stats := service.GetStats()
 
+	// A service running in another process, detached or in another
+	// terminal, reports through its state file
+	state, err := watchers.ReadServiceState(watchers.GetStatePath(path))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	detachedPID, err := watchers.ReadPIDFile(watchers.GetPIDPath(path))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	running := state != nil && processAlive(state.PID)
+	if running {
+		stats = state.Stats
+		stats.Running = true
+	}
+
 	fmt.Printf("Watcher Service Status:\n\n")
-	fmt.Printf("  Running: %t\n", stats.Running)
+	if running {
+		fmt.Printf("  Running: %s (pid %d, up %s)\n", colorResult(true, "true"), state.PID, formatElapsed(time.Since(state.StartedAt)))
+	} else {
+		fmt.Printf("  Running: %s\n", colorResult(stats.Running, fmt.Sprintf("%t", stats.Running)))
+	}
+	switch {
+	case running && state.PID == detachedPID:
+		fmt.Printf("  Detached: output in %s\n", filepath.Join(journal.Dir(path), watchLogFile))
+	case detachedPID > 0 && !processAlive(detachedPID):
+		fmt.Printf("  Detached: %s, see %s\n", colorResult(false, fmt.Sprintf("pid %d exited", detachedPID)),
+			filepath.Join(journal.Dir(path), watchLogFile))
+	}
 	fmt.Printf("  Active Watchers: %d\n", stats.ActiveWatchers)
 	fmt.Printf("  Total Executions: %d\n", stats.TotalExecutions)
 	fmt.Printf("  Total Commits: %d\n", stats.TotalCommits)
+	if stats.Latency.Samples > 0 {
+		fmt.Printf("  Commit Latency: %s\n", formatLatency(stats.Latency))
+	}
 
 	if !stats.LastExecution.IsZero() {
-		fmt.Printf("  Last Execution: %s\n", stats.LastExecution.Format("2006-01-02 15:04:05"))
+		fmt.Printf("  Last Execution: %s\n", colorTime(stats.LastExecution.Format("2006-01-02 15:04:05")))
 	}
 
 	fmt.Printf("\nEnabled Watchers:\n")
@@ -140,6 +331,101 @@ func handleShowStatus(service *watchers.WatcherService) {
 	}
 }
 
+// detachWatch runs this watch command again in the background, waits for its
+// watchers to start and records its pid in each watched repository
+func detachWatch(args []string, repoPaths []string) {
+	for _, repoPath := range repoPaths {
+		pid, err := watchers.ReadPIDFile(watchers.GetPIDPath(repoPath))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		if pid > 0 && processAlive(pid) {
+			fmt.Fprintf(os.Stderr, "Error: a detached watcher (pid %d) is already running for %s\n", pid, repoPath)
+			fmt.Fprintf(os.Stderr, "Stop it first: lcg watch --stop\n")
+			os.Exit(1)
+		}
+	}
+
+	cmd, logPath, err := spawnDetached(repoPaths[0], append([]string{"watch"}, withoutFlag(args, "detach")...), watchLogFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting watcher service: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The service writes its state once every watcher has started
+	started := func() bool {
+		state, _ := watchers.ReadServiceState(watchers.GetStatePath(repoPaths[0]))
+		return state != nil && state.PID == cmd.Process.Pid
+	}
+	if err := waitForDetached(cmd, detachTimeout, started); err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting watcher service: %v\n", err)
+		fmt.Fprintf(os.Stderr, "See %s for details\n", logPath)
+		os.Exit(1)
+	}
+
+	for _, repoPath := range repoPaths {
+		if err := watchers.WritePIDFile(watchers.GetPIDPath(repoPath), cmd.Process.Pid); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	fmt.Printf("Watching in the background (pid %d)\n", cmd.Process.Pid)
+	fmt.Printf("Output: %s\n", logPath)
+	fmt.Printf("Check on it with 'lcg watch --status', stop it with 'lcg watch --stop'\n")
+}
+
+// handleStopDetached stops the watcher service started with --detach
+func handleStopDetached(path string) {
+	pidPath := watchers.GetPIDPath(path)
+	pid, err := watchers.ReadPIDFile(pidPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if pid == 0 || !processAlive(pid) {
+		if pid > 0 {
+			watchers.RemovePIDFile(pidPath, pid)
+		}
+		fmt.Fprintf(os.Stderr, "Error: no detached watcher is running\n")
+		os.Exit(1)
+	}
+
+	if err := terminateProcess(pid); err != nil {
+		fmt.Fprintf(os.Stderr, "Error stopping watcher (pid %d): %v\n", pid, err)
+		os.Exit(1)
+	}
+
+	deadline := time.Now().Add(detachTimeout)
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			fmt.Fprintf(os.Stderr, "Error: the watcher (pid %d) is still running after %s\n", pid, detachTimeout)
+			os.Exit(1)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// A process killed outright leaves its files behind
+	watchers.RemovePIDFile(pidPath, pid)
+	if state, _ := watchers.ReadServiceState(watchers.GetStatePath(path)); state != nil && state.PID == pid {
+		watchers.RemoveServiceState(watchers.GetStatePath(path))
+	}
+
+	fmt.Printf("Stopped detached watcher (pid %d)\n", pid)
+}
+
+// withoutFlag drops every form of a boolean flag from command-line arguments
+func withoutFlag(args []string, name string) []string {
+	var kept []string
+	for _, arg := range args {
+		flagName, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if strings.HasPrefix(arg, "-") && flagName == name {
+			continue
+		}
+		kept = append(kept, arg)
+	}
+	return kept
+}
+
 func handleEnableWatcher(service *watchers.WatcherService, watcherName string) {
 	if err := service.EnableWatcher(watcherName); err != nil {
 		fmt.Fprintf(os.Stderr, "Error enabling watcher: %v\n", err)
@@ -158,7 +444,140 @@ func handleDisableWatcher(service *watchers.WatcherService, watcherName string)
 	fmt.Printf("Disabled watcher: %s\n", watcherName)
 }
 
-func handleStartWatchingLanguage(service *watchers.WatcherService, language string) {
+func handleSetWatcherOption(service *watchers.WatcherService, assignment string) {
+	key, value, hasValue := strings.Cut(assignment, "=")
+	watcherName, option, hasOption := strings.Cut(key, ".")
+	if !hasValue || !hasOption || watcherName == "" || option == "" {
+		fmt.Fprintf(os.Stderr, "Error: expected <watcher>.<option>=<value>, got %s\n", assignment)
+		os.Exit(1)
+	}
+
+	if err := service.SetWatcherOption(watcherName, option, value); err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting option: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Set %s.%s = %s\n", watcherName, option, value)
+}
+
+func handleAddWatcher(service *watchers.WatcherService, assignment, language string) {
+	name, command, hasCommand := strings.Cut(assignment, "=")
+	if !hasCommand || name == "" || strings.TrimSpace(command) == "" {
+		fmt.Fprintf(os.Stderr, "Error: expected <name>=<command>, got %s\n", assignment)
+		os.Exit(1)
+	}
+
+	if err := service.AddExecWatcher(name, command, language); err != nil {
+		fmt.Fprintf(os.Stderr, "Error adding watcher: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Added watcher %s running: %s\n", name, command)
+	fmt.Printf("Check it with: lcg watch --test %s --wait\n", name)
+}
+
+func handleTestWatcher(service *watchers.WatcherService, watcherName string, inject bool, timeout time.Duration) {
+	if inject {
+		fmt.Printf("Testing %s...\n", watcherName)
+	} else {
+		fmt.Printf("Testing %s; run some code within %s...\n", watcherName, timeout)
+	}
+
+	result, err := service.ProbeWatcher(watcherName, inject, timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error testing watcher: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\n  Enabled: %s\n", colorResult(result.Enabled, fmt.Sprintf("%t", result.Enabled)))
+	if result.Endpoint != "" {
+		fmt.Printf("  Listening: %s\n", result.Endpoint)
+	}
+	fmt.Printf("  Started: %s\n", colorResult(result.Started, fmt.Sprintf("%t", result.Started)))
+	if result.Started {
+		fmt.Printf("  Injected: %t\n", result.Injected)
+	}
+
+	if result.Event != nil {
+		event := result.Event
+		fmt.Printf("  Received: after %s\n", result.Elapsed.Round(time.Microsecond))
+
+		fmt.Printf("\nWould commit:\n")
+		fmt.Printf("  Message: %s\n", result.Message)
+		if event.Author != "" {
+			fmt.Printf("  Author: %s\n", formatAuthor(event.Author, event.AuthorEmail))
+		}
+		fmt.Printf("  Language: %s\n", colorLanguage(event.Language))
+		fmt.Printf("  Buffer: %s\n", event.Buffer)
+		fmt.Printf("  Environment: %s\n", event.Environment)
+		if event.Success {
+			fmt.Printf("  Result: %s\n", formatResult(true))
+		} else {
+			fmt.Printf("  Result: %s %s\n", formatResult(false), event.ErrorMessage)
+		}
+		if event.BPM > 0 {
+			fmt.Printf("  BPM: %.1f\n", event.BPM)
+		}
+		fmt.Printf("  Content:\n")
+		for _, line := range strings.Split(event.Content, "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+
+	if len(result.Diagnostics) > 0 {
+		fmt.Printf("\nDiagnostics:\n")
+		for _, diagnostic := range result.Diagnostics {
+			fmt.Printf("  - %s\n", diagnostic)
+		}
+	}
+
+	if result.Event == nil {
+		os.Exit(1)
+	}
+}
+
+// printStartResults shows how each watcher started, on stderr when the
+// service failed to start
+func printStartResults(multi *watchers.MultiRepoService, failed bool) {
+	out := os.Stdout
+	if failed {
+		out = os.Stderr
+	}
+
+	repositories := multi.Repositories()
+	for _, r := range repositories {
+		results := r.Service.StartResults()
+
+		// Repositories started before a failing one were stopped again
+		failing := false
+		for _, result := range results {
+			failing = failing || result.Err != nil
+		}
+		if len(results) == 0 || (failed && !failing) {
+			continue
+		}
+
+		if len(repositories) > 1 {
+			fmt.Fprintf(out, "%s:\n", r.Path)
+		}
+		for _, result := range results {
+			endpoint := ""
+			if result.Endpoint != "" {
+				endpoint = " on " + result.Endpoint
+			}
+			switch {
+			case result.Err != nil:
+				fmt.Fprintf(out, "  %s %s: %v\n", colorResult(false, "✗"), result.Name, result.Err)
+			case result.Started:
+				fmt.Fprintf(out, "  %s %s%s\n", colorResult(true, "✓"), result.Name, endpoint)
+			default:
+				fmt.Fprintf(out, "  %s %s%s %s\n", colorDim("-"), result.Name, endpoint, colorDim("(not started)"))
+			}
+		}
+	}
+}
+
+func handleStartWatchingLanguage(multi *watchers.MultiRepoService, service *watchers.WatcherService, language string) {
 	// Enable watchers for the specified language
 	languageWatchers := getWatchersForLanguage(language)
 	if len(languageWatchers) == 0 {
@@ -175,28 +594,48 @@ func handleStartWatchingLanguage(service *watchers.WatcherService, language stri
 	}
 
 	fmt.Printf("Starting watchers for %s...\n", language)
-	startWatcherService(service)
+	startWatcherService(multi)
 }
 
-func handleStartWatchingAll(service *watchers.WatcherService) {
+func handleStartWatchingAll(multi *watchers.MultiRepoService, service *watchers.WatcherService) {
 	enabledWatchers := service.GetEnabledWatchers()
-	if len(enabledWatchers) == 0 {
+	if len(enabledWatchers) == 0 && service.ControlPort() == 0 {
 		fmt.Printf("No watchers are enabled. Use 'lcg watch --list' to see available watchers.\n")
 		fmt.Printf("Enable a watcher first: lcg watch --enable <watcher-name>\n")
 		os.Exit(1)
 	}
 
 	fmt.Printf("Starting %d enabled watchers...\n", len(enabledWatchers))
-	startWatcherService(service)
+	if port := service.ControlPort(); port > 0 {
+		fmt.Printf("OSC control surface on UDP port %d (%s, %s, %s, ...)\n", port,
+			watchers.ControlMark, watchers.ControlSnapshot, watchers.ControlCheckout)
+	}
+	startWatcherService(multi)
 }
 
-func startWatcherService(service *watchers.WatcherService) {
+func startWatcherService(multi *watchers.MultiRepoService) {
 	// Start the service
-	if err := service.Start(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error starting watcher service: %v\n", err)
+	err := multi.Start()
+	printStartResults(multi, err != nil)
+	if err != nil {
+		var startErr *watchers.StartError
+		if errors.As(err, &startErr) {
+			fmt.Fprintf(os.Stderr, "Error starting watcher service: nothing was started\n")
+		} else {
+			fmt.Fprintf(os.Stderr, "Error starting watcher service: %v\n", err)
+		}
 		os.Exit(1)
 	}
 
+	// Report state so 'lcg status' can see this service from each repository
+	writeServiceStates(multi)
+	defer func() {
+		for _, r := range multi.Repositories() {
+			watchers.RemoveServiceState(watchers.GetStatePath(r.Path))
+			watchers.RemovePIDFile(watchers.GetPIDPath(r.Path), os.Getpid())
+		}
+	}()
+
 	fmt.Printf("Watcher service started. Monitoring for code executions...\n")
 	fmt.Printf("Press Ctrl+C to stop.\n\n")
 
@@ -208,27 +647,55 @@ func startWatcherService(service *watchers.WatcherService) {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
+	stateTicker := time.NewTicker(5 * time.Second)
+	defer stateTicker.Stop()
+
 	for {
 		select {
 		case <-sigChan:
 			fmt.Printf("\nShutting down watcher service...\n")
-			if err := service.Stop(); err != nil {
+			if err := multi.Stop(); err != nil {
 				fmt.Fprintf(os.Stderr, "Error stopping service: %v\n", err)
 			}
 
 			// Print final stats
-			stats := service.GetStats()
+			repositories := multi.Repositories()
+			if len(repositories) > 1 {
+				for _, r := range repositories {
+					stats := r.Service.GetStats()
+					fmt.Printf("%s: %d executions, %d commits\n", r.Path, stats.TotalExecutions, stats.TotalCommits)
+				}
+			}
+			stats := multi.GetStats()
 			fmt.Printf("Final stats: %d executions, %d commits\n",
 				stats.TotalExecutions, stats.TotalCommits)
+			if stats.Latency.Samples > 0 {
+				fmt.Printf("Commit latency: %s\n", formatLatency(stats.Latency))
+			}
 
 			return
 
 		case <-ticker.C:
-			stats := service.GetStats()
+			stats := multi.GetStats()
 			if stats.TotalExecutions > 0 {
-				fmt.Printf("Status: %d executions, %d commits\n",
-					stats.TotalExecutions, stats.TotalCommits)
+				fmt.Printf("Status: %d executions, %d commits", stats.TotalExecutions, stats.TotalCommits)
+				if stats.Latency.Samples > 0 {
+					fmt.Printf(", latency p95 %s", roundLatency(stats.Latency.P95))
+				}
+				fmt.Printf("\n")
 			}
+
+		case <-stateTicker.C:
+			writeServiceStates(multi)
+		}
+	}
+}
+
+// writeServiceStates writes the state file of every watched repository
+func writeServiceStates(multi *watchers.MultiRepoService) {
+	for _, r := range multi.Repositories() {
+		if err := watchers.WriteServiceState(watchers.GetStatePath(r.Path), r.Service.GetState()); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 		}
 	}
 }
@@ -0,0 +1,292 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+// BisectFile holds the state of a bisect in progress
+const BisectFile = "BISECT"
+
+// Bisect is a search for the execution that broke the sound: every commit up
+// to Good sounded right and Bad didn't, so the culprit lies between them
+type Bisect struct {
+	Good    string   `json:"good,omitempty"`
+	Bad     string   `json:"bad"`
+	Skipped []string `json:"skipped,omitempty"` // commits that couldn't be tested
+	Buffer  string   `json:"buffer,omitempty"`  // only test commits of this buffer
+
+	// Where each candidate is replayed, set when the bisect starts
+	ReplayDir        string `json:"replay_dir,omitempty"`
+	ReplayOSC        string `json:"replay_osc,omitempty"`
+	ReplayOSCAddress string `json:"replay_osc_address,omitempty"`
+}
+
+// BisectStep is where a bisect stands after each mark
+type BisectStep struct {
+	Bisect    *Bisect
+	Current   *Commit   // commit to test next; nil once the culprit is known
+	Remaining int       // commits that may still be the culprit
+	Steps     int       // tests left, roughly
+	Culprit   *Commit   // first bad commit, once found
+	Suspects  []*Commit // skipped commits that may be the culprit instead
+}
+
+// BisectStart begins a bisect between a bad commit, HEAD when empty, and an
+// optional good one. options holds the buffer to search and where to replay
+// candidates; its commits are ignored.
+func (repo *LiveCodeRepository) BisectStart(bad, good string, options Bisect) (*BisectStep, error) {
+	if !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+	if len(repo.index.AllEntries()) == 0 {
+		return nil, fmt.Errorf("no commits to bisect")
+	}
+
+	state := options
+	state.Skipped = nil
+
+	if bad == "" {
+		state.Bad = repo.index.AllEntries()[len(repo.index.AllEntries())-1].Hash
+	} else {
+		commit, err := repo.ResolveCommit(bad)
+		if err != nil {
+			return nil, err
+		}
+		state.Bad = commit.Hash
+	}
+
+	state.Good = ""
+	if good != "" {
+		commit, err := repo.ResolveCommit(good)
+		if err != nil {
+			return nil, err
+		}
+		state.Good = commit.Hash
+	}
+
+	return repo.saveBisect(&state)
+}
+
+// BisectMark records the result of testing a commit, the current candidate
+// when ref is empty, and moves to the next candidate
+func (repo *LiveCodeRepository) BisectMark(ref string, good bool) (*BisectStep, error) {
+	step, err := repo.BisectStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := repo.bisectRef(step, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	state := step.Bisect
+	position, known := repo.index.FindEntry(hash)
+	if !known {
+		return nil, fmt.Errorf("commit %s is not in the history", hash)
+	}
+	if good {
+		if badPosition, _ := repo.index.FindEntry(state.Bad); position >= badPosition {
+			return nil, fmt.Errorf("commit %s is not before the bad commit %s", hash[:8], state.Bad[:8])
+		}
+		state.Good = hash
+	} else {
+		if state.Good != "" {
+			if goodPosition, _ := repo.index.FindEntry(state.Good); position <= goodPosition {
+				return nil, fmt.Errorf("commit %s is not after the good commit %s", hash[:8], state.Good[:8])
+			}
+		}
+		state.Bad = hash
+	}
+
+	return repo.saveBisect(state)
+}
+
+// BisectSkip sets aside a commit that can't be tested, the current candidate
+// when ref is empty
+func (repo *LiveCodeRepository) BisectSkip(ref string) (*BisectStep, error) {
+	step, err := repo.BisectStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := repo.bisectRef(step, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	state := step.Bisect
+	state.Skipped = append(state.Skipped, hash)
+	return repo.saveBisect(state)
+}
+
+// BisectStatus returns the bisect in progress
+func (repo *LiveCodeRepository) BisectStatus() (*BisectStep, error) {
+	data, err := os.ReadFile(repo.bisectPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no bisect in progress")
+		}
+		return nil, fmt.Errorf("failed to read bisect state: %w", err)
+	}
+
+	var state Bisect
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse bisect state: %w", err)
+	}
+	return repo.bisectStep(&state)
+}
+
+// BisectReset ends the bisect in progress
+func (repo *LiveCodeRepository) BisectReset() error {
+	if err := os.Remove(repo.bisectPath()); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no bisect in progress")
+		}
+		return fmt.Errorf("failed to remove bisect state: %w", err)
+	}
+	return nil
+}
+
+// BuffersAt returns the latest commit of every buffer as of a commit, which
+// is what was playing once it was executed, ordered by buffer
+func (repo *LiveCodeRepository) BuffersAt(hash string) ([]*Commit, error) {
+	position, known := repo.index.FindEntry(hash)
+	if !known {
+		return nil, fmt.Errorf("commit %s is not in the history", hash)
+	}
+
+	heads := make(map[string]string)
+	for _, entry := range repo.index.AllEntries()[:position+1] {
+		heads[entry.Buffer] = entry.Hash
+	}
+
+	buffers := make([]string, 0, len(heads))
+	for buffer := range heads {
+		buffers = append(buffers, buffer)
+	}
+	sort.Strings(buffers)
+
+	commits := make([]*Commit, 0, len(buffers))
+	for _, buffer := range buffers {
+		commit, err := repo.storage.ReadCommit(heads[buffer])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit %s: %w", heads[buffer], err)
+		}
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+// bisectRef resolves the commit a mark refers to
+func (repo *LiveCodeRepository) bisectRef(step *BisectStep, ref string) (string, error) {
+	if ref != "" {
+		commit, err := repo.ResolveCommit(ref)
+		if err != nil {
+			return "", err
+		}
+		return commit.Hash, nil
+	}
+	if step.Current == nil {
+		if step.Culprit != nil {
+			return "", fmt.Errorf("the bisect is finished; name a commit to mark")
+		}
+		return "", fmt.Errorf("no commit to test yet; name a commit to mark")
+	}
+	return step.Current.Hash, nil
+}
+
+// saveBisect writes the bisect state and works out the next step
+func (repo *LiveCodeRepository) saveBisect(state *Bisect) (*BisectStep, error) {
+	step, err := repo.bisectStep(state)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal bisect state: %w", err)
+	}
+	if err := os.WriteFile(repo.bisectPath(), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write bisect state: %w", err)
+	}
+	return step, nil
+}
+
+// bisectStep picks the candidate halfway between the good and bad commits
+func (repo *LiveCodeRepository) bisectStep(state *Bisect) (*BisectStep, error) {
+	step := &BisectStep{Bisect: state}
+
+	bad, known := repo.index.FindEntry(state.Bad)
+	if !known {
+		return nil, fmt.Errorf("bad commit %s is no longer in the history", state.Bad)
+	}
+	if state.Good == "" {
+		// Anything before the bad commit may be the culprit
+		step.Remaining = bad + 1
+		return step, nil
+	}
+	good, known := repo.index.FindEntry(state.Good)
+	if !known {
+		return nil, fmt.Errorf("good commit %s is no longer in the history", state.Good)
+	}
+	if good >= bad {
+		return nil, fmt.Errorf("good commit %s must come before bad commit %s", state.Good[:8], state.Bad[:8])
+	}
+
+	skipped := make(map[string]bool, len(state.Skipped))
+	for _, hash := range state.Skipped {
+		skipped[hash] = true
+	}
+
+	var candidates, suspects []string
+	for _, entry := range repo.index.AllEntries()[good+1 : bad] {
+		if state.Buffer != "" && entry.Buffer != state.Buffer {
+			continue
+		}
+		if skipped[entry.Hash] {
+			suspects = append(suspects, entry.Hash)
+			continue
+		}
+		candidates = append(candidates, entry.Hash)
+	}
+
+	// The bad commit is a candidate until one before it is found bad
+	step.Remaining = len(candidates) + len(suspects) + 1
+	if len(candidates) > 0 {
+		for n := len(candidates) + 1; n > 1; n = (n + 1) / 2 {
+			step.Steps++
+		}
+		current, err := repo.storage.ReadCommit(candidates[len(candidates)/2])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit %s: %w", candidates[len(candidates)/2], err)
+		}
+		step.Current = current
+		return step, nil
+	}
+
+	culprit, err := repo.storage.ReadCommit(state.Bad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", state.Bad, err)
+	}
+	step.Culprit = culprit
+	for _, hash := range suspects {
+		commit, err := repo.storage.ReadCommit(hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit %s: %w", hash, err)
+		}
+		step.Suspects = append(step.Suspects, commit)
+	}
+	return step, nil
+}
+
+// bisectPath returns the path of the bisect state file
+func (repo *LiveCodeRepository) bisectPath() string {
+	return filepath.Join(repo.path, storage.RepoDir, BisectFile)
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestBisect(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	// Commit 5 of 10 broke the sound
+	var commits []*Commit
+	for i := 0; i < 10; i++ {
+		buffer := fmt.Sprintf("d%d", i%2+1)
+		commit, err := repo.Commit(fmt.Sprintf("%s $ s \"bd*%d\"", buffer, i), fmt.Sprintf("Step %d", i),
+			ExecutionMetadata{Buffer: buffer, Language: "tidal", Success: true})
+		if err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+		commits = append(commits, commit)
+	}
+	broken := 5
+
+	if _, err := repo.BisectStatus(); err == nil {
+		t.Errorf("Expected no bisect in progress")
+	}
+	if _, err := repo.BisectStart(commits[2].Hash, commits[7].Hash, Bisect{}); err == nil {
+		t.Errorf("Expected a good commit after the bad one to be refused")
+	}
+
+	step, err := repo.BisectStart("", "", Bisect{})
+	if err != nil {
+		t.Fatalf("Failed to start bisect: %v", err)
+	}
+	if step.Current != nil || step.Bisect.Bad != commits[9].Hash {
+		t.Errorf("Expected to wait for a good commit with HEAD bad, got %+v", step)
+	}
+	if _, err := repo.BisectMark("", true); err == nil {
+		t.Errorf("Expected marking without a candidate to fail")
+	}
+
+	step, err = repo.BisectMark(commits[0].Hash, true)
+	if err != nil {
+		t.Fatalf("Failed to mark good commit: %v", err)
+	}
+	if step.Remaining != 9 || step.Steps != 4 {
+		t.Errorf("Expected 9 suspects in about 4 tests, got %d in %d", step.Remaining, step.Steps)
+	}
+
+	tests := 0
+	for step.Current != nil {
+		position := -1
+		for i, commit := range commits {
+			if commit.Hash == step.Current.Hash {
+				position = i
+			}
+		}
+		if step, err = repo.BisectMark("", position < broken); err != nil {
+			t.Fatalf("Failed to mark commit: %v", err)
+		}
+		tests++
+	}
+	if step.Culprit == nil || step.Culprit.Hash != commits[broken].Hash {
+		t.Fatalf("Expected commit %d to be the culprit, got %+v", broken, step.Culprit)
+	}
+	if tests > 4 {
+		t.Errorf("Expected at most 4 tests, took %d", tests)
+	}
+
+	// Buffers as of the culprit: d2 is the culprit, d1 the commit before
+	buffers, err := repo.BuffersAt(step.Culprit.Hash)
+	if err != nil {
+		t.Fatalf("Failed to read buffers: %v", err)
+	}
+	if len(buffers) != 2 || buffers[0].Hash != commits[4].Hash || buffers[1].Hash != commits[5].Hash {
+		t.Errorf("Expected d1 and d2 as of commit 5, got %+v", buffers)
+	}
+
+	// Restricted to d1, skipping its only candidate leaves it as a suspect
+	step, err = repo.BisectStart(commits[5].Hash, commits[2].Hash, Bisect{Buffer: "d1"})
+	if err != nil {
+		t.Fatalf("Failed to restart bisect: %v", err)
+	}
+	if step.Current == nil || step.Current.Hash != commits[4].Hash {
+		t.Fatalf("Expected d1's commit 4 to be tested, got %+v", step.Current)
+	}
+	if step, err = repo.BisectSkip(""); err != nil {
+		t.Fatalf("Failed to skip commit: %v", err)
+	}
+	if step.Culprit == nil || len(step.Suspects) != 1 || step.Suspects[0].Hash != commits[4].Hash {
+		t.Errorf("Expected commit 4 as a suspect beside commit 5, got %+v", step)
+	}
+
+	if err := repo.BisectReset(); err != nil {
+		t.Fatalf("Failed to reset bisect: %v", err)
+	}
+	if err := repo.BisectReset(); err == nil {
+		t.Errorf("Expected no bisect to reset")
+	}
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/livecodegit/pkg/diff"
+)
+
+// BlameLine is one line of a buffer with the commit that introduced it
+type BlameLine struct {
+	Number int // from 1
+	Text   string
+	Commit *Commit
+}
+
+// Blame attributes each line of a buffer's latest content to the commit that
+// introduced it, following the lines through every commit of the buffer
+func (repo *LiveCodeRepository) Blame(buffer string) ([]BlameLine, error) {
+	if !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	var lines []string
+	var origins []*Commit
+	found := false
+	for _, entry := range repo.index.AllEntries() {
+		if entry.Buffer != buffer {
+			continue
+		}
+		found = true
+
+		commit, err := repo.storage.ReadCommit(entry.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit %s: %w", entry.Hash, err)
+		}
+
+		next := diff.Lines(commit.Content)
+		nextOrigins := make([]*Commit, len(next))
+		for i, match := range diff.Match(lines, next) {
+			if match >= 0 {
+				nextOrigins[i] = origins[match]
+			} else {
+				nextOrigins[i] = commit
+			}
+		}
+		lines, origins = next, nextOrigins
+	}
+	if !found {
+		return nil, fmt.Errorf("no commits in buffer %s", buffer)
+	}
+
+	blame := make([]BlameLine, len(lines))
+	for i, line := range lines {
+		blame[i] = BlameLine{Number: i + 1, Text: line, Commit: origins[i]}
+	}
+	return blame, nil
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"os"
+	"testing"
+)
+
+func TestBlame(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	metadata := ExecutionMetadata{Buffer: "drums", Language: "tidal", Success: true}
+	versions := []string{
+		"d1 $ s \"bd\"\n# kick",
+		"d1 $ s \"bd sn\"\n# kick\nd2 $ s \"hh*8\"",
+		"d1 $ s \"bd sn\"\nd2 $ s \"hh*8\"\n",
+	}
+	var commits []*Commit
+	for _, content := range versions {
+		commit, err := repo.Commit(content, "Drums", metadata)
+		if err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+		commits = append(commits, commit)
+
+		// Other buffers don't disturb the drums
+		if _, err := repo.Commit("d1 $ silence", "Other", ExecutionMetadata{Buffer: "other", Language: "tidal", Success: true}); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+
+	lines, err := repo.Blame("drums")
+	if err != nil {
+		t.Fatalf("Failed to blame: %v", err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+	if lines[0].Text != "d1 $ s \"bd sn\"" || lines[0].Commit.Hash != commits[1].Hash {
+		t.Errorf("Expected line 1 from the second commit, got %+v", lines[0])
+	}
+	if lines[1].Number != 2 || lines[1].Commit.Hash != commits[1].Hash {
+		t.Errorf("Expected line 2 from the second commit, got %+v", lines[1])
+	}
+
+	// Lines survive a commit that leaves them alone
+	if _, err := repo.Commit("# intro\nd1 $ s \"bd sn\"\nd2 $ s \"hh*8\"", "Intro", metadata); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	lines, _ = repo.Blame("drums")
+	if len(lines) != 3 || lines[0].Commit.Message != "Intro" || lines[2].Commit.Hash != commits[1].Hash {
+		t.Errorf("Expected the intro from the new commit and the rest kept, got %+v", lines)
+	}
+
+	if _, err := repo.Blame("bass"); err == nil {
+		t.Errorf("Expected blaming an unknown buffer to fail")
+	}
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+// ResolveCommit finds the commit a tag name, full hash or unique
+// abbreviated hash refers to
+func (repo *LiveCodeRepository) ResolveCommit(ref string) (*Commit, error) {
+	tags, err := repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+	if hash, exists := tags[ref]; exists {
+		ref = hash
+	}
+
+	hash, err := storage.ResolveHash(repo.storage, ref)
+	if err != nil {
+		var ambiguous *storage.AmbiguousHashError
+		if errors.As(err, &ambiguous) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("no commit or tag named %s", ref)
+	}
+
+	return repo.storage.ReadCommit(hash)
+}
+
+// isFullHash reports whether ref is a complete commit hash, SHA-1 or
+// SHA-256, which also keeps references from remote controllers out of paths
+// outside the object store
+func isFullHash(ref string) bool {
+	if len(ref) != 40 && len(ref) != 64 {
+		return false
+	}
+	for _, r := range ref {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Checkout writes a commit's code into dir, to the file CheckoutFileName
+// names, and returns the path written
+func (repo *LiveCodeRepository) Checkout(commit *Commit, dir string) (string, error) {
+	path := filepath.Join(dir, CheckoutFileName(commit))
+	if err := os.WriteFile(path, []byte(commit.Content), 0644); err != nil {
+		return "", fmt.Errorf("failed to check out %s: %w", commit.Hash, err)
+	}
+
+	return path, nil
+}
+
+// CheckoutFileName returns the file a commit is checked out to: its buffer
+// name with an extension for its language
+func CheckoutFileName(commit *Commit) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, commit.Metadata.Buffer)
+	name = strings.TrimLeft(name, ".")
+	if name == "" {
+		name = "buffer"
+	}
+
+	switch strings.ToLower(commit.Metadata.Language) {
+	case "sonicpi":
+		return name + ".rb"
+	case "tidal":
+		return name + ".tidal"
+	case "supercollider":
+		return name + ".scd"
+	case "strudel":
+		return name + ".strudel"
+	default:
+		return name + ".txt"
+	}
+}
+
+// LanguageFromExtension maps a source file extension to a livecoding
+// language, or "unknown"
+func LanguageFromExtension(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".rb", ".spi", ".sonic":
+		return "sonicpi"
+	case ".tidal", ".hs":
+		return "tidal"
+	case ".scd", ".sc":
+		return "supercollider"
+	case ".strudel":
+		return "strudel"
+	case ".js":
+		return "hydra"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckoutFileName(t *testing.T) {
+	tests := []struct {
+		buffer   string
+		language string
+		expected string
+	}{
+		{"d1", "tidal", "d1.tidal"},
+		{"main", "sonicpi", "main.rb"},
+		{"../etc/passwd", "unknown", "_etc_passwd.txt"},
+		{"", "strudel", "buffer.strudel"},
+	}
+
+	for _, test := range tests {
+		commit := &Commit{Metadata: ExecutionMetadata{Buffer: test.buffer, Language: test.language}}
+		if name := CheckoutFileName(commit); name != test.expected {
+			t.Errorf("Expected %s for buffer '%s', got %s", test.expected, test.buffer, name)
+		}
+	}
+}
+
+func TestResolveCommitAndCheckout(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	commit, err := repo.Commit("d1 $ s \"bd*4\"", "Four on the floor", ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if err := repo.Tag("drop", commit.Hash); err != nil {
+		t.Fatalf("Failed to tag commit: %v", err)
+	}
+
+	for _, ref := range []string{commit.Hash, commit.Hash[:7], strings.ToUpper(commit.Hash[:7]), "drop"} {
+		resolved, err := repo.ResolveCommit(ref)
+		if err != nil {
+			t.Fatalf("Failed to resolve '%s': %v", ref, err)
+		}
+		if resolved.Hash != commit.Hash {
+			t.Errorf("Expected %s for '%s', got %s", commit.Hash, ref, resolved.Hash)
+		}
+	}
+
+	if _, err := repo.ResolveCommit("../HEAD"); err == nil {
+		t.Errorf("Expected error for unknown reference")
+	}
+
+	path, err := repo.Checkout(commit, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to check out commit: %v", err)
+	}
+	if path != filepath.Join(tempDir, "d1.tidal") {
+		t.Errorf("Expected checkout to d1.tidal, got %s", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != commit.Content {
+		t.Errorf("Expected checked out content '%s', got '%s' (%v)", commit.Content, string(data), err)
+	}
+}
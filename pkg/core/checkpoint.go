@@ -0,0 +1,170 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+// CheckpointsFile holds the named checkpoints of a repository
+const CheckpointsFile = "checkpoints"
+
+// Checkpoint is a named state of every buffer to return to, e.g. before
+// trying something risky mid-set. Like a snapshot marker it records the
+// latest commit of each buffer, but it doesn't need a performance.
+type Checkpoint struct {
+	Name    string            `json:"name"`
+	Time    time.Time         `json:"time"`
+	Commit  string            `json:"commit"`  // HEAD when saved
+	Buffers map[string]string `json:"buffers"` // buffer -> latest commit
+}
+
+// SaveCheckpoint records the latest commit of every buffer under name,
+// replacing a checkpoint of the same name
+func (repo *LiveCodeRepository) SaveCheckpoint(name string) (*Checkpoint, error) {
+	if name == "" {
+		return nil, fmt.Errorf("checkpoint name is required")
+	}
+	if err := repo.checkWritable(); err != nil {
+		return nil, err
+	}
+	if len(repo.index.AllEntries()) == 0 {
+		return nil, fmt.Errorf("no commits to save")
+	}
+
+	checkpoints, err := repo.Checkpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoint := &Checkpoint{
+		Name:    name,
+		Time:    time.Now(),
+		Commit:  repo.index.GetHead(),
+		Buffers: repo.BufferHeads(),
+	}
+	kept := []*Checkpoint{checkpoint}
+	for _, existing := range checkpoints {
+		if existing.Name != name {
+			kept = append(kept, existing)
+		}
+	}
+
+	if err := repo.writeCheckpoints(kept); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+// Checkpoints returns the saved checkpoints, oldest first
+func (repo *LiveCodeRepository) Checkpoints() ([]*Checkpoint, error) {
+	if !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	data, err := os.ReadFile(repo.checkpointsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*Checkpoint{}, nil
+		}
+		return nil, fmt.Errorf("failed to read checkpoints: %w", err)
+	}
+
+	var checkpoints []*Checkpoint
+	if err := json.Unmarshal(data, &checkpoints); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoints: %w", err)
+	}
+	return checkpoints, nil
+}
+
+// GetCheckpoint finds a checkpoint by name; an empty name finds the latest
+func (repo *LiveCodeRepository) GetCheckpoint(name string) (*Checkpoint, error) {
+	checkpoints, err := repo.Checkpoints()
+	if err != nil {
+		return nil, err
+	}
+	if len(checkpoints) == 0 {
+		return nil, fmt.Errorf("no checkpoints saved")
+	}
+
+	if name == "" {
+		return checkpoints[len(checkpoints)-1], nil
+	}
+	for _, checkpoint := range checkpoints {
+		if checkpoint.Name == name {
+			return checkpoint, nil
+		}
+	}
+	return nil, fmt.Errorf("no checkpoint named %s", name)
+}
+
+// RemoveCheckpoint deletes a checkpoint by name
+func (repo *LiveCodeRepository) RemoveCheckpoint(name string) error {
+	checkpoints, err := repo.Checkpoints()
+	if err != nil {
+		return err
+	}
+
+	kept := make([]*Checkpoint, 0, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		if checkpoint.Name != name {
+			kept = append(kept, checkpoint)
+		}
+	}
+	if len(kept) == len(checkpoints) {
+		return fmt.Errorf("no checkpoint named %s", name)
+	}
+
+	return repo.writeCheckpoints(kept)
+}
+
+// CheckpointCommits returns the commit of each buffer of a checkpoint, by
+// buffer name
+func (repo *LiveCodeRepository) CheckpointCommits(checkpoint *Checkpoint) ([]*Commit, error) {
+	buffers := make([]string, 0, len(checkpoint.Buffers))
+	for buffer := range checkpoint.Buffers {
+		buffers = append(buffers, buffer)
+	}
+	sort.Strings(buffers)
+
+	commits := make([]*Commit, 0, len(buffers))
+	for _, buffer := range buffers {
+		commit, err := repo.storage.ReadCommit(checkpoint.Buffers[buffer])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit %s: %w", checkpoint.Buffers[buffer], err)
+		}
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+// writeCheckpoints replaces the checkpoints file
+func (repo *LiveCodeRepository) writeCheckpoints(checkpoints []*Checkpoint) error {
+	if !repo.IsInitialized() {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].Time.Before(checkpoints[j].Time)
+	})
+
+	data, err := json.MarshalIndent(checkpoints, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoints: %w", err)
+	}
+
+	if err := os.WriteFile(repo.checkpointsPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+	return nil
+}
+
+// checkpointsPath returns the location of the checkpoints file
+func (repo *LiveCodeRepository) checkpointsPath() string {
+	return filepath.Join(repo.path, storage.RepoDir, CheckpointsFile)
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCheckpoints(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	if _, err := repo.SaveCheckpoint("empty"); err == nil {
+		t.Errorf("Expected saving a checkpoint without commits to fail")
+	}
+	if _, err := repo.GetCheckpoint(""); err == nil {
+		t.Errorf("Expected getting a checkpoint without any saved to fail")
+	}
+
+	kick, err := repo.Commit("d1 $ s \"bd*4\"", "Kick", ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	hats, err := repo.Commit("d2 $ s \"hh*8\"", "Hats", ExecutionMetadata{Buffer: "d2", Language: "tidal", Success: true})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	if _, err := repo.SaveCheckpoint(""); err == nil {
+		t.Errorf("Expected saving a checkpoint without a name to fail")
+	}
+	checkpoint, err := repo.SaveCheckpoint("groove")
+	if err != nil {
+		t.Fatalf("Failed to save checkpoint: %v", err)
+	}
+	if checkpoint.Commit != hats.Hash || checkpoint.Buffers["d1"] != kick.Hash || checkpoint.Buffers["d2"] != hats.Hash {
+		t.Errorf("Expected checkpoint of both buffers at HEAD %s, got %+v", hats.Hash, checkpoint)
+	}
+
+	// Saving under an existing name replaces the checkpoint, and the
+	// latest checkpoint is the one restored by default
+	if _, err := repo.SaveCheckpoint("intro"); err != nil {
+		t.Fatalf("Failed to save checkpoint: %v", err)
+	}
+	if _, err := repo.Amend("d2 $ s \"hh*16\"", "Faster hats", ExecutionMetadata{Buffer: "d2", Language: "tidal", Success: true}); err != nil {
+		t.Fatalf("Failed to amend commit: %v", err)
+	}
+	if _, err := repo.SaveCheckpoint("groove"); err != nil {
+		t.Fatalf("Failed to replace checkpoint: %v", err)
+	}
+
+	checkpoints, err := repo.Checkpoints()
+	if err != nil {
+		t.Fatalf("Failed to list checkpoints: %v", err)
+	}
+	if len(checkpoints) != 2 || checkpoints[0].Name != "intro" || checkpoints[1].Name != "groove" {
+		t.Fatalf("Expected intro then groove, got %v", checkpoints)
+	}
+
+	latest, err := repo.GetCheckpoint("")
+	if err != nil || latest.Name != "groove" {
+		t.Fatalf("Expected latest checkpoint groove, got %v (%v)", latest, err)
+	}
+
+	// The amended commit is kept for the checkpoint that refers to it
+	intro, err := repo.GetCheckpoint("intro")
+	if err != nil {
+		t.Fatalf("Failed to get checkpoint: %v", err)
+	}
+	commits, err := repo.CheckpointCommits(intro)
+	if err != nil {
+		t.Fatalf("Failed to read checkpoint commits: %v", err)
+	}
+	if len(commits) != 2 || commits[0].Hash != kick.Hash || commits[1].Hash != hats.Hash {
+		t.Errorf("Expected d1 and d2 as of intro, got %v", commits)
+	}
+
+	if err := repo.RemoveCheckpoint("intro"); err != nil {
+		t.Fatalf("Failed to remove checkpoint: %v", err)
+	}
+	if err := repo.RemoveCheckpoint("intro"); err == nil {
+		t.Errorf("Expected removing a missing checkpoint to fail")
+	}
+	if _, err := repo.GetCheckpoint("intro"); err == nil {
+		t.Errorf("Expected removed checkpoint to be gone")
+	}
+}
@@ -0,0 +1,179 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+// ConfigFile is the name of the repository configuration file
+const ConfigFile = "config"
+
+// DefaultAuthor is the author recorded when user.name is not configured
+const DefaultAuthor = "livecoder"
+
+// RemotePrefix starts the keys naming remotes to push to and pull from,
+// e.g. remote.studio
+const RemotePrefix = "remote."
+
+// ConfigInterface defines access to repository settings by dotted key,
+// e.g. user.name
+type ConfigInterface interface {
+	Get(key string) (string, error)
+	Set(key string, value string) error
+	Unset(key string) error
+	List() map[string]string
+	Save() error
+}
+
+// RepositoryConfig holds the settings stored in .livecodegit/config
+type RepositoryConfig struct {
+	User     UserConfig     `json:"user"`
+	Defaults DefaultsConfig `json:"defaults"`
+
+	// Remotes maps remote names to the URLs of their 'lcg serve --sync'
+	Remotes map[string]string `json:"remotes,omitempty"`
+}
+
+// UserConfig identifies who commits to the repository
+type UserConfig struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// DefaultsConfig holds metadata used when a commit doesn't specify it
+type DefaultsConfig struct {
+	Language string `json:"language,omitempty"`
+	Buffer   string `json:"buffer,omitempty"`
+}
+
+// FileConfig implements ConfigInterface on top of the repository config file
+type FileConfig struct {
+	path   string
+	config RepositoryConfig
+}
+
+// LoadConfig reads the configuration of the repository at repoPath; a
+// missing file gives an empty configuration
+func LoadConfig(repoPath string) (*FileConfig, error) {
+	fc := &FileConfig{path: filepath.Join(repoPath, storage.RepoDir, ConfigFile)}
+
+	data, err := os.ReadFile(fc.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fc, nil
+		}
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &fc.config); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", fc.path, err)
+	}
+
+	return fc, nil
+}
+
+// Settings returns the parsed configuration
+func (fc *FileConfig) Settings() RepositoryConfig {
+	return fc.config
+}
+
+// field returns the setting addressed by key
+func (fc *FileConfig) field(key string) (*string, error) {
+	switch key {
+	case "user.name":
+		return &fc.config.User.Name, nil
+	case "user.email":
+		return &fc.config.User.Email, nil
+	case "defaults.language":
+		return &fc.config.Defaults.Language, nil
+	case "defaults.buffer":
+		return &fc.config.Defaults.Buffer, nil
+	default:
+		return nil, fmt.Errorf("unknown config key %s (known keys: user.name, user.email, defaults.language, defaults.buffer, remote.<name>)", key)
+	}
+}
+
+// remoteName returns the remote a key names, if it is a remote key
+func remoteName(key string) (string, bool) {
+	name, found := strings.CutPrefix(key, RemotePrefix)
+	return name, found && name != ""
+}
+
+// Get returns the value of a setting, empty when it is not set
+func (fc *FileConfig) Get(key string) (string, error) {
+	if name, ok := remoteName(key); ok {
+		return fc.config.Remotes[name], nil
+	}
+	field, err := fc.field(key)
+	if err != nil {
+		return "", err
+	}
+	return *field, nil
+}
+
+// Set changes a setting; call Save to write it
+func (fc *FileConfig) Set(key string, value string) error {
+	if name, ok := remoteName(key); ok {
+		if value == "" {
+			delete(fc.config.Remotes, name)
+			return nil
+		}
+		if fc.config.Remotes == nil {
+			fc.config.Remotes = make(map[string]string)
+		}
+		fc.config.Remotes[name] = value
+		return nil
+	}
+	field, err := fc.field(key)
+	if err != nil {
+		return err
+	}
+	*field = value
+	return nil
+}
+
+// Unset clears a setting; call Save to write it
+func (fc *FileConfig) Unset(key string) error {
+	return fc.Set(key, "")
+}
+
+// List returns every setting that has a value
+func (fc *FileConfig) List() map[string]string {
+	settings := make(map[string]string)
+	for _, key := range ConfigKeys() {
+		if value, _ := fc.Get(key); value != "" {
+			settings[key] = value
+		}
+	}
+	for name, url := range fc.config.Remotes {
+		settings[RemotePrefix+name] = url
+	}
+	return settings
+}
+
+// Save writes the configuration file
+func (fc *FileConfig) Save() error {
+	data, err := json.MarshalIndent(fc.config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	if err := os.WriteFile(fc.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	return nil
+}
+
+// ConfigKeys returns the supported setting keys in sorted order
+func ConfigKeys() []string {
+	keys := []string{"user.name", "user.email", "defaults.language", "defaults.buffer"}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"os"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	// A missing file is an empty configuration
+	config, err := LoadConfig(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if len(config.List()) != 0 {
+		t.Errorf("Expected empty config, got %v", config.List())
+	}
+
+	var _ ConfigInterface = config
+
+	if err := config.Set("user.name", "Alex"); err != nil {
+		t.Fatalf("Failed to set user.name: %v", err)
+	}
+	if err := config.Set("defaults.buffer", "d1"); err != nil {
+		t.Fatalf("Failed to set defaults.buffer: %v", err)
+	}
+	if err := config.Set("user.nickname", "x"); err == nil {
+		t.Errorf("Expected error for unknown key")
+	}
+	if err := config.Save(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	reloaded, err := LoadConfig(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+
+	name, err := reloaded.Get("user.name")
+	if err != nil || name != "Alex" {
+		t.Errorf("Expected user.name 'Alex', got '%s' (%v)", name, err)
+	}
+	if reloaded.Settings().Defaults.Buffer != "d1" {
+		t.Errorf("Expected defaults.buffer 'd1', got '%s'", reloaded.Settings().Defaults.Buffer)
+	}
+
+	if err := reloaded.Unset("user.name"); err != nil {
+		t.Fatalf("Failed to unset user.name: %v", err)
+	}
+	if settings := reloaded.List(); len(settings) != 1 || settings["defaults.buffer"] != "d1" {
+		t.Errorf("Expected only defaults.buffer after unset, got %v", settings)
+	}
+
+	// Remotes are named freely under remote.
+	if err := reloaded.Set("remote.studio", "http://studio.local:7070"); err != nil {
+		t.Fatalf("Failed to set remote.studio: %v", err)
+	}
+	if url, _ := reloaded.Get("remote.studio"); url != "http://studio.local:7070" {
+		t.Errorf("Expected remote.studio URL, got '%s'", url)
+	}
+	if settings := reloaded.List(); settings["remote.studio"] != "http://studio.local:7070" {
+		t.Errorf("Expected remote.studio in list, got %v", settings)
+	}
+	if err := reloaded.Set("remote.", "http://nowhere"); err == nil {
+		t.Errorf("Expected error for a remote without a name")
+	}
+	reloaded.Unset("remote.studio")
+	if len(reloaded.Settings().Remotes) != 0 {
+		t.Errorf("Expected remote.studio to be removed, got %v", reloaded.Settings().Remotes)
+	}
+}
+
+func TestCommitUsesConfiguredAuthor(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	commit, err := repo.Commit("play 60", "Default author", ExecutionMetadata{Buffer: "main", Language: "sonicpi", Success: true})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if commit.Author != DefaultAuthor || commit.AuthorEmail != "" {
+		t.Errorf("Expected default author '%s', got '%s' <%s>", DefaultAuthor, commit.Author, commit.AuthorEmail)
+	}
+
+	config, err := repo.Config()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	config.Set("user.name", "Alex")
+	config.Set("user.email", "alex@example.com")
+	if err := config.Save(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	// A freshly loaded repository picks the identity up from disk
+	loaded, err := LoadRepository(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to load repository: %v", err)
+	}
+
+	commit, err = loaded.Commit("play 62", "Configured author", ExecutionMetadata{Buffer: "main", Language: "sonicpi", Success: true})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if commit.Author != "Alex" || commit.AuthorEmail != "alex@example.com" {
+		t.Errorf("Expected author 'Alex' <alex@example.com>, got '%s' <%s>", commit.Author, commit.AuthorEmail)
+	}
+
+	performance, err := loaded.StartPerformance("Set")
+	if err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+	if performance.Author != "Alex" {
+		t.Errorf("Expected performance author 'Alex', got '%s'", performance.Author)
+	}
+}
+
+func TestCommitAsOverridesAuthor(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	metadata := ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}
+
+	commit, err := repo.CommitAs("d1 $ s \"bd\"", "Guest author", metadata, UserConfig{Name: "Kate", Email: "kate@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if commit.Author != "Kate" || commit.AuthorEmail != "kate@example.com" {
+		t.Errorf("Expected author 'Kate' <kate@example.com>, got '%s' <%s>", commit.Author, commit.AuthorEmail)
+	}
+
+	// Without a name the configured user is used, email included
+	commit, err = repo.CommitAs("d1 $ s \"sn\"", "No override", metadata, UserConfig{Email: "ignored@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if commit.Author != DefaultAuthor || commit.AuthorEmail != "" {
+		t.Errorf("Expected default author '%s', got '%s' <%s>", DefaultAuthor, commit.Author, commit.AuthorEmail)
+	}
+}
@@ -0,0 +1,185 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+// Format returns how the repository hashes and stores its objects, with the
+// defaults filled in
+func (repo *LiveCodeRepository) Format() (storage.Format, error) {
+	if !repo.IsInitialized() {
+		return storage.Format{}, fmt.Errorf("repository not initialized")
+	}
+
+	format, err := storage.ReadFormat(repo.path)
+	if err != nil {
+		return format, err
+	}
+	if format.Version == 0 {
+		format.Version = storage.FormatSHA1
+	}
+	if format.Backend == "" {
+		format.Backend = storage.BackendFiles
+	}
+	if format.Compression == "" {
+		format.Compression = storage.CompressionNone
+	}
+	return format, nil
+}
+
+// SetCompression sets how new objects are compressed, e.g.
+// storage.CompressionGzip. Objects already stored are read either way.
+func (repo *LiveCodeRepository) SetCompression(compression string) error {
+	fsStorage, ok := repo.storage.(*storage.FileSystemStorage)
+	if !ok || !repo.IsInitialized() {
+		return fmt.Errorf("repository not initialized")
+	}
+	if err := repo.checkWritable(); err != nil {
+		return err
+	}
+
+	return fsStorage.SetCompression(compression)
+}
+
+// SetBlobs sets whether the content of new commits is stored in blobs,
+// shared by every commit with the same content. Commits already stored are
+// read either way.
+func (repo *LiveCodeRepository) SetBlobs(blobs bool) error {
+	fsStorage, ok := repo.storage.(*storage.FileSystemStorage)
+	if !ok || !repo.IsInitialized() {
+		return fmt.Errorf("repository not initialized")
+	}
+	if err := repo.checkWritable(); err != nil {
+		return err
+	}
+
+	return fsStorage.SetBlobs(blobs)
+}
+
+// SetS3 sets where a repository using the s3 backend mirrors its objects and
+// performances
+func (repo *LiveCodeRepository) SetS3(config storage.S3Config) error {
+	fsStorage, ok := repo.storage.(*storage.FileSystemStorage)
+	if !ok || !repo.IsInitialized() {
+		return fmt.Errorf("repository not initialized")
+	}
+	if err := repo.checkWritable(); err != nil {
+		return err
+	}
+
+	return fsStorage.SetS3(config)
+}
+
+// Encrypt encrypts the repository's objects at rest, with the key in
+// keyFile or, when keyFile is empty, a passphrase from
+// storage.PassphraseEnv, and returns how many stored objects it encrypted.
+// The key or passphrase is needed from then on to read any commit. The
+// named files, of the repository directory and written with
+// FileSystemStorage.SealRepoData, are sealed too.
+func (repo *LiveCodeRepository) Encrypt(keyFile string, files ...string) (int, error) {
+	fsStorage, ok := repo.storage.(*storage.FileSystemStorage)
+	if !ok || !repo.IsInitialized() {
+		return 0, fmt.Errorf("repository not initialized")
+	}
+	if err := repo.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	// Nothing can be committed meanwhile, unsealed
+	unlock, err := repo.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	return fsStorage.Encrypt(keyFile, files...)
+}
+
+// S3Fetch downloads what the bucket of the s3 backend holds and the
+// repository doesn't, e.g. after initializing it on another machine, and
+// indexes the commits among it. It returns how many objects and
+// performances it downloaded.
+func (repo *LiveCodeRepository) S3Fetch() (int, error) {
+	fsStorage, ok := repo.storage.(*storage.FileSystemStorage)
+	if !ok || !repo.IsInitialized() {
+		return 0, fmt.Errorf("repository not initialized")
+	}
+	if err := repo.checkWritable(); err != nil {
+		return 0, err
+	}
+	unlock, err := repo.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	fetched, err := fsStorage.S3Fetch()
+	if err != nil || fetched == 0 {
+		return fetched, err
+	}
+
+	hashes, err := repo.storage.ListCommits()
+	if err != nil {
+		return fetched, fmt.Errorf("failed to list commits: %w", err)
+	}
+	indexed := false
+	for _, hash := range hashes {
+		if _, exists := repo.index.FindEntry(hash); exists {
+			continue
+		}
+		commit, err := repo.storage.ReadCommit(hash)
+		if err != nil {
+			return fetched, fmt.Errorf("failed to read commit %s: %w", hash, err)
+		}
+		repo.index.RestoreCommit(commit)
+		indexed = true
+	}
+	if !indexed {
+		return fetched, nil
+	}
+	if err := repo.index.SaveIndex(); err != nil {
+		return fetched, fmt.Errorf("failed to update index: %w", err)
+	}
+	if err := repo.storage.WriteHead(repo.index.GetHead()); err != nil {
+		return fetched, fmt.Errorf("failed to update HEAD: %w", err)
+	}
+	repo.searchIndex = nil
+	return fetched, nil
+}
+
+// S3Pending returns what the s3 backend has yet to upload, e.g. while the
+// network is down; empty for other backends
+func (repo *LiveCodeRepository) S3Pending() ([]string, error) {
+	fsStorage, ok := repo.storage.(*storage.FileSystemStorage)
+	if !ok || !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+	return fsStorage.S3Pending()
+}
+
+// CountObjects counts the commits and blobs of the repository
+func (repo *LiveCodeRepository) CountObjects() (*storage.ObjectCount, error) {
+	fsStorage, ok := repo.storage.(*storage.FileSystemStorage)
+	if !ok || !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+	return fsStorage.CountObjects()
+}
+
+// hashCommit sets a commit's hash the way the repository's format version
+// hashes commits
+func (repo *LiveCodeRepository) hashCommit(commit *Commit) error {
+	// Storage without a format file of its own is always at the current
+	// version
+	version := storage.CurrentFormatVersion
+	if versioned, ok := repo.storage.(interface{ FormatVersion() (int, error) }); ok {
+		var err error
+		if version, err = versioned.FormatVersion(); err != nil {
+			return err
+		}
+	}
+
+	commit.Hash = storage.HashCommit(commit, version)
+	return nil
+}
@@ -0,0 +1,256 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+// Kinds of problems found by Fsck
+const (
+	FsckCorrupt       = "corrupt"        // the object can't be read as a commit
+	FsckPartialWrite  = "partial-write"  // an interrupted write left the object or a temporary file cut short
+	FsckHashMismatch  = "hash-mismatch"  // the object's hash doesn't match its content
+	FsckMissingParent = "missing-parent" // the commit's parent doesn't exist
+	FsckMissingObject = "missing-object" // the index lists a commit that doesn't exist
+	FsckUnindexed     = "unindexed"      // the commit is in the history but not in the index
+	FsckStaleIndex    = "stale-index"    // the index entry doesn't match the commit
+	FsckBadHead       = "bad-head"       // HEAD is missing, dangling or behind the index
+)
+
+// FsckProblem is one inconsistency found in the repository
+type FsckProblem struct {
+	Kind     string
+	Hash     string
+	Detail   string
+	Repaired bool
+}
+
+// FsckResult reports what Fsck checked and found
+type FsckResult struct {
+	Objects  int
+	Problems []FsckProblem
+}
+
+// Repairable reports whether Fsck can fix the problem without rewriting history
+func (p FsckProblem) Repairable() bool {
+	switch p.Kind {
+	case FsckPartialWrite, FsckMissingObject, FsckUnindexed, FsckStaleIndex, FsckBadHead:
+		return true
+	}
+	return false
+}
+
+// Unrepaired returns the number of problems left in the repository
+func (r *FsckResult) Unrepaired() int {
+	count := 0
+	for _, problem := range r.Problems {
+		if !problem.Repaired {
+			count++
+		}
+	}
+	return count
+}
+
+// Fsck checks the integrity of the repository: that every object is a commit
+// whose hash matches its content, that parents exist, that the index lists
+// exactly the commits of the history and that HEAD points at the index head.
+//
+// With repair, objects and temporary files cut short by interrupted writes
+// are deleted, the index is brought back in line with the objects and HEAD
+// is reset to the index head. Corrupt objects, hash mismatches and missing
+// parents are only reported, as fixing them would rewrite history.
+func (repo *LiveCodeRepository) Fsck(repair bool) (*FsckResult, error) {
+	fsStorage, ok := repo.storage.(*storage.FileSystemStorage)
+	if !ok || !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+	if repair {
+		unlock, err := repo.lock()
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	hashes, err := fsStorage.ListCommits()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+	sort.Strings(hashes)
+
+	result := &FsckResult{Objects: len(hashes)}
+	report := func(kind, hash, detail string) {
+		problem := FsckProblem{Kind: kind, Hash: hash, Detail: detail}
+		problem.Repaired = repair && problem.Repairable()
+		result.Problems = append(result.Problems, problem)
+	}
+
+	// Leftovers of interrupted writes, which never made it into place
+	temps, err := fsStorage.PartialWrites()
+	if err != nil {
+		return nil, fmt.Errorf("failed to look for partial writes: %w", err)
+	}
+	for _, name := range temps {
+		report(FsckPartialWrite, "", fmt.Sprintf("temporary file %s", name))
+		if repair {
+			if err := fsStorage.RemovePartialWrite(name); err != nil {
+				return nil, fmt.Errorf("failed to remove %s: %w", name, err)
+			}
+		}
+	}
+
+	// Objects, and the parents they point at. An object cut short holds
+	// nothing worth keeping, and goes like one never written.
+	commits := make(map[string]*Commit, len(hashes))
+	for _, hash := range hashes {
+		commit, err := fsStorage.ReadCommit(hash)
+		if errors.Is(err, storage.ErrPartialWrite) {
+			report(FsckPartialWrite, hash, err.Error())
+			if repair {
+				if err := fsStorage.DeleteObject(hash); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err != nil {
+			report(FsckCorrupt, hash, err.Error())
+			continue
+		}
+		commits[hash] = commit
+
+		if commit.Hash != hash {
+			report(FsckHashMismatch, hash, fmt.Sprintf("object records hash %s", commit.Hash))
+		} else if !storage.VerifyHash(commit) {
+			report(FsckHashMismatch, hash, "content doesn't match the hash")
+		}
+	}
+	for _, hash := range hashes {
+		commit, ok := commits[hash]
+		if !ok || commit.Parent == "" {
+			continue
+		}
+		if !isFullHash(commit.Parent) || !fsStorage.Exists(commit.Parent) {
+			report(FsckMissingParent, hash, fmt.Sprintf("parent %s doesn't exist", commit.Parent))
+		}
+	}
+	for _, hash := range hashes {
+		commit, ok := commits[hash]
+		if !ok || commit.BufferParent == "" {
+			continue
+		}
+		if !isFullHash(commit.BufferParent) || !fsStorage.Exists(commit.BufferParent) {
+			report(FsckMissingParent, hash, fmt.Sprintf("buffer parent %s doesn't exist", commit.BufferParent))
+		}
+	}
+
+	// The index against the objects
+	indexed := make(map[string]bool, len(repo.index.AllEntries()))
+	missing := make(map[string]bool)
+	for _, entry := range repo.index.AllEntries() {
+		indexed[entry.Hash] = true
+		commit, readable := commits[entry.Hash]
+		switch {
+		case !isFullHash(entry.Hash) || !fsStorage.Exists(entry.Hash):
+			missing[entry.Hash] = true
+			report(FsckMissingObject, entry.Hash, fmt.Sprintf("indexed as %q", entry.Message))
+		case readable && !entry.Describes(commit):
+			report(FsckStaleIndex, entry.Hash, "index entry doesn't match the commit")
+			if repair {
+				repo.index.RestoreCommit(commit)
+			}
+		}
+	}
+
+	// Commits off the history, e.g. kept by a tag or left by a failed write,
+	// are for gc rather than the index
+	history := make(map[string]bool)
+	roots := []string{repo.index.GetHead()}
+	if head, err := fsStorage.ReadHead(); err == nil {
+		roots = append(roots, head)
+	}
+	for _, hash := range roots {
+		for !history[hash] {
+			commit, readable := commits[hash]
+			if !readable {
+				break
+			}
+			history[hash] = true
+			hash = commit.Parent
+		}
+	}
+
+	var restored []*Commit
+	for _, hash := range hashes {
+		if commit := commits[hash]; history[hash] && !indexed[hash] {
+			report(FsckUnindexed, hash, fmt.Sprintf("%q isn't in the index", commit.Message))
+			restored = append(restored, commit)
+		}
+	}
+
+	if repair {
+		kept := repo.index.AllEntries()[:0]
+		for _, entry := range repo.index.AllEntries() {
+			if !missing[entry.Hash] {
+				kept = append(kept, entry)
+			}
+		}
+		repo.index.Entries = kept
+
+		// Insert oldest first, so commits sharing a time keep their order
+		sort.SliceStable(restored, func(a, b int) bool {
+			return restored[a].Timestamp.Before(restored[b].Timestamp)
+		})
+		for _, commit := range restored {
+			repo.index.RestoreCommit(commit)
+		}
+
+		if err := repo.index.SaveIndex(); err != nil {
+			return nil, fmt.Errorf("failed to repair index: %w", err)
+		}
+		if len(missing) > 0 || len(restored) > 0 {
+			// Not rebuilt, since that reads every object and fails on corrupt ones
+			searchIndex := storage.NewSearchIndex(fsStorage)
+			if err := searchIndex.LoadSearchIndex(); err != nil {
+				return nil, fmt.Errorf("failed to load search index: %w", err)
+			}
+			if err := searchIndex.RemoveCommits(missing); err != nil {
+				return nil, fmt.Errorf("failed to update search index: %w", err)
+			}
+			for _, commit := range restored {
+				if err := searchIndex.AddCommit(commit); err != nil {
+					return nil, fmt.Errorf("failed to update search index: %w", err)
+				}
+			}
+			repo.searchIndex = nil
+		}
+	}
+
+	// HEAD against the (repaired) index
+	indexHead := repo.index.GetHead()
+	head, err := fsStorage.ReadHead()
+	var headProblem string
+	switch {
+	case err != nil && indexHead != "":
+		headProblem = "HEAD can't be read"
+	case err != nil:
+	case head == "" && indexHead == "":
+	case !isFullHash(head) || !fsStorage.Exists(head):
+		headProblem = fmt.Sprintf("HEAD points at %q, which doesn't exist", head)
+	case head != indexHead:
+		headProblem = fmt.Sprintf("HEAD differs from the index head %s", indexHead)
+	}
+	if headProblem != "" {
+		report(FsckBadHead, head, headProblem)
+		if repair {
+			if err := fsStorage.WriteHead(indexHead); err != nil {
+				return nil, fmt.Errorf("failed to repair HEAD: %w", err)
+			}
+		}
+	}
+
+	return result, nil
+}
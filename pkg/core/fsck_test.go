@@ -0,0 +1,169 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+func TestFsckCleanRepository(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	metadata := ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}
+	for _, message := range []string{"Kick", "Hats"} {
+		if _, err := repo.Commit("d1 $ s \"bd\" # "+message, message, metadata); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+
+	// Hashes are recomputed from commits read back from disk
+	reloaded, err := LoadRepository(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to load repository: %v", err)
+	}
+	result, err := reloaded.Fsck(false)
+	if err != nil {
+		t.Fatalf("Failed to check repository: %v", err)
+	}
+	if result.Objects != 2 || len(result.Problems) != 0 {
+		t.Errorf("Expected 2 objects without problems, got %d and %+v", result.Objects, result.Problems)
+	}
+}
+
+func TestFsckReportsAndRepairs(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	metadata := ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}
+	var commits []*Commit
+	for _, message := range []string{"Kick", "Hats", "Bass"} {
+		commit, err := repo.Commit("d1 $ s \"bd\" # "+message, message, metadata)
+		if err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+		commits = append(commits, commit)
+	}
+	fsStorage := repo.storage.(*storage.FileSystemStorage)
+
+	// The last commit's index entry was replaced by one for a missing object
+	repo.index.Entries = repo.index.Entries[:2]
+	ghost := storage.GenerateHash("ghost")
+	repo.index.Entries = append(repo.index.Entries, storage.IndexEntry{Hash: ghost, Message: "Ghost", Timestamp: time.Now()})
+	repo.index.Entries[0].Message = "Renamed"
+	if err := repo.index.SaveIndex(); err != nil {
+		t.Fatalf("Failed to save index: %v", err)
+	}
+
+	// A tampered commit, and one whose parent is gone
+	tampered := *commits[1]
+	tampered.Content = "hush"
+	if err := fsStorage.WriteCommit(&tampered); err != nil {
+		t.Fatalf("Failed to tamper with commit: %v", err)
+	}
+	orphan := &Commit{Parent: storage.GenerateHash("gone"), Timestamp: commits[0].Timestamp.Add(-time.Hour), Message: "Orphan", Content: "hush"}
+	orphan.Hash = storage.CommitHash(orphan)
+	if err := fsStorage.WriteCommit(orphan); err != nil {
+		t.Fatalf("Failed to write orphan: %v", err)
+	}
+
+	// An object cut short by a crash, and one that isn't a commit at all
+	partial := filepath.Join(tempDir, ".livecodegit", "objects", "ff", strings.Repeat("0", 38))
+	corrupt := filepath.Join(tempDir, ".livecodegit", "objects", "ee", strings.Repeat("0", 38))
+	for path, data := range map[string]string{partial: "{\"message\": \"partial", corrupt: "{\"message\": 42}"} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create object directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write corrupt object: %v", err)
+		}
+	}
+
+	result, err := repo.Fsck(false)
+	if err != nil {
+		t.Fatalf("Failed to check repository: %v", err)
+	}
+	found := make(map[string]bool)
+	for _, problem := range result.Problems {
+		if problem.Repaired {
+			t.Errorf("Expected nothing repaired without repair, got %+v", problem)
+		}
+		found[problem.Kind+" "+problem.Hash] = true
+	}
+	expected := []string{
+		FsckPartialWrite + " ff" + strings.Repeat("0", 38),
+		FsckCorrupt + " ee" + strings.Repeat("0", 38),
+		FsckHashMismatch + " " + commits[1].Hash,
+		FsckMissingParent + " " + orphan.Hash,
+		FsckMissingObject + " " + ghost,
+		FsckUnindexed + " " + commits[2].Hash,
+		FsckStaleIndex + " " + commits[0].Hash,
+		FsckBadHead + " " + commits[2].Hash,
+	}
+	for _, problem := range expected {
+		if !found[problem] {
+			t.Errorf("Expected problem %s, got %+v", problem, result.Problems)
+		}
+	}
+	if len(result.Problems) != len(expected) {
+		t.Errorf("Expected %d problems, got %d", len(expected), len(result.Problems))
+	}
+	if result.Objects != 6 {
+		t.Errorf("Expected 6 objects, got %d", result.Objects)
+	}
+
+	result, err = repo.Fsck(true)
+	if err != nil {
+		t.Fatalf("Failed to repair repository: %v", err)
+	}
+	if result.Unrepaired() != 3 {
+		t.Errorf("Expected corrupt, tampered and orphaned objects to remain, got %d unrepaired", result.Unrepaired())
+	}
+	if _, err := os.Stat(partial); !os.IsNotExist(err) {
+		t.Errorf("Expected the object cut short to be deleted, got %v", err)
+	}
+
+	reloaded, err := LoadRepository(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to load repository: %v", err)
+	}
+	if head := reloaded.index.GetHead(); head != commits[2].Hash {
+		t.Errorf("Expected the restored commit to be the index head, got %s", head)
+	}
+	if reloaded.index.GetEntry(orphan.Hash) != nil {
+		t.Errorf("Expected the orphan, outside the history, to be left to gc")
+	}
+	if entry := reloaded.index.GetEntry(commits[0].Hash); entry == nil || entry.Message != "Kick" {
+		t.Errorf("Expected the stale entry to be refreshed, got %+v", entry)
+	}
+	if reloaded.index.GetEntry(ghost) != nil {
+		t.Errorf("Expected the missing object to be dropped from the index")
+	}
+	if head, _ := fsStorage.ReadHead(); head != commits[2].Hash {
+		t.Errorf("Expected HEAD reset to the index head, got %s", head)
+	}
+
+	result, err = reloaded.Fsck(false)
+	if err != nil {
+		t.Fatalf("Failed to check repository: %v", err)
+	}
+	for _, problem := range result.Problems {
+		if problem.Repairable() {
+			t.Errorf("Expected repairable problems to be gone, got %+v", problem)
+		}
+	}
+}
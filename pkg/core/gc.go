@@ -0,0 +1,216 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+// GCResult reports the objects a garbage collection found unreachable
+type GCResult struct {
+	Reachable   int
+	Unreachable []string // sorted hashes
+	Blobs       []string // sorted hashes of blobs no kept commit refers to
+	Unreadable  []string // sorted hashes of reachable objects that couldn't be read; no blob is deleted while there are any
+	Bytes       int64    // total size of the unreachable objects and blobs
+	DryRun      bool     // nothing was deleted
+}
+
+// GC deletes objects that no longer belong to the history: orphans left
+// behind by failed writes and reverts. An object is kept when it can be
+// reached through parents from an index entry, HEAD, a tag, a performance's
+// head commit or markers, a checkpoint or a snapshot, and a blob when a kept
+// commit refers to it. Index entries count since pulled commits interleave
+// with local ones by time, leaving local commits off HEAD's parents. With
+// dryRun, only reports what would be deleted.
+func (repo *LiveCodeRepository) GC(dryRun bool) (*GCResult, error) {
+	fsStorage, ok := repo.storage.(*storage.FileSystemStorage)
+	if !ok || !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+	if !dryRun {
+		// Nothing can be committed meanwhile, e.g. reusing a blob about
+		// to be deleted
+		unlock, err := repo.lock()
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	reachable, err := repo.reachableCommits()
+	if err != nil {
+		return nil, err
+	}
+
+	hashes, err := fsStorage.ListCommits()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	result := &GCResult{DryRun: dryRun}
+	unreachable := make(map[string]bool)
+	referenced := make(map[string]bool)
+	for _, hash := range hashes {
+		if reachable[hash] {
+			result.Reachable++
+			// An unreadable object is left for fsck, and since which blobs
+			// it refers to isn't known, every blob with it
+			blobs, err := fsStorage.CommitBlobs(hash)
+			if err != nil {
+				result.Unreadable = append(result.Unreadable, hash)
+				continue
+			}
+			for _, blob := range blobs {
+				referenced[blob] = true
+			}
+			continue
+		}
+		size, err := fsStorage.ObjectSize(hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat object %s: %w", hash, err)
+		}
+		result.Bytes += size
+		result.Unreachable = append(result.Unreachable, hash)
+		unreachable[hash] = true
+	}
+	sort.Strings(result.Unreachable)
+	sort.Strings(result.Unreadable)
+
+	blobs, err := fsStorage.ListBlobs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blobs: %w", err)
+	}
+	for _, hash := range blobs {
+		if referenced[hash] || len(result.Unreadable) > 0 {
+			continue
+		}
+		size, err := fsStorage.BlobSize(hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat blob %s: %w", hash, err)
+		}
+		result.Bytes += size
+		result.Blobs = append(result.Blobs, hash)
+	}
+	sort.Strings(result.Blobs)
+
+	if dryRun {
+		return result, nil
+	}
+
+	// Commits first, so none is left referring to a deleted blob
+	for _, hash := range result.Unreachable {
+		if err := fsStorage.DeleteObject(hash); err != nil {
+			return nil, err
+		}
+	}
+	for _, hash := range result.Blobs {
+		if err := fsStorage.DeleteBlob(hash); err != nil {
+			return nil, err
+		}
+	}
+	if len(unreachable) == 0 {
+		return result, nil
+	}
+
+	searchIndex := storage.NewSearchIndex(fsStorage)
+	if err := searchIndex.LoadSearchIndex(); err != nil {
+		return nil, fmt.Errorf("failed to load search index: %w", err)
+	}
+	if err := searchIndex.RemoveCommits(unreachable); err != nil {
+		return nil, fmt.Errorf("failed to update search index: %w", err)
+	}
+	repo.searchIndex = nil
+
+	return result, nil
+}
+
+// Repack moves the loose objects and packfiles into a single packfile, which
+// is quicker to read, copy and archive than thousands of small files
+func (repo *LiveCodeRepository) Repack() (*storage.RepackResult, error) {
+	fsStorage, ok := repo.storage.(*storage.FileSystemStorage)
+	if !ok || !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+	if err := repo.checkWritable(); err != nil {
+		return nil, err
+	}
+	return fsStorage.Repack()
+}
+
+// reachableCommits returns every commit reachable through parents from the
+// index, HEAD, the tags, the performances, the checkpoints and the
+// snapshots. Missing or unreadable objects end a chain.
+func (repo *LiveCodeRepository) reachableCommits() (map[string]bool, error) {
+	var roots []string
+	for _, entry := range repo.index.AllEntries() {
+		roots = append(roots, entry.Hash)
+	}
+	if head, err := repo.storage.ReadHead(); err == nil {
+		roots = append(roots, head)
+	}
+
+	tags, err := repo.storage.ReadTags()
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range tags {
+		roots = append(roots, hash)
+	}
+
+	performances, err := repo.storage.ListPerformances()
+	if err != nil {
+		return nil, err
+	}
+	// The active performance as held in memory, since flushing it would
+	// take the lock a caller may hold
+	if repo.currentPerformance != nil {
+		performances = append(performances, repo.currentPerformance)
+	}
+	for _, performance := range performances {
+		roots = append(roots, performance.HeadCommit)
+		for _, marker := range performance.Markers {
+			roots = append(roots, marker.Commit)
+			for _, hash := range marker.Buffers {
+				roots = append(roots, hash)
+			}
+		}
+	}
+
+	checkpoints, err := repo.Checkpoints()
+	if err != nil {
+		return nil, err
+	}
+	for _, checkpoint := range checkpoints {
+		roots = append(roots, checkpoint.Commit)
+		for _, hash := range checkpoint.Buffers {
+			roots = append(roots, hash)
+		}
+	}
+
+	snapshots, err := repo.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		roots = append(roots, snapshot.Commit)
+		for _, entry := range snapshot.Entries {
+			roots = append(roots, entry.Commit)
+		}
+	}
+
+	reachable := make(map[string]bool)
+	for _, hash := range roots {
+		for isFullHash(hash) && !reachable[hash] && repo.storage.Exists(hash) {
+			reachable[hash] = true
+			commit, err := repo.storage.ReadCommit(hash)
+			if err != nil {
+				break
+			}
+			hash = commit.Parent
+		}
+	}
+
+	return reachable, nil
+}
@@ -0,0 +1,212 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+func TestGC(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	metadata := ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}
+	var commits []*Commit
+	for _, message := range []string{"Kick", "Hats"} {
+		commit, err := repo.Commit("d1 $ s \"bd hh\" # "+message, message, metadata)
+		if err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+		commits = append(commits, commit)
+	}
+
+	// An orphan left by a failed write, and an indexed commit no parent
+	// chain reaches
+	fsStorage := repo.storage.(*storage.FileSystemStorage)
+	orphan := &Commit{Hash: storage.GenerateHash("orphan"), Timestamp: time.Now(), Message: "Orphan", Content: "hush"}
+	indexed := &Commit{Hash: storage.GenerateHash("indexed"), Timestamp: time.Now().Add(-time.Hour), Message: "Indexed", Content: "hush"}
+	for _, commit := range []*Commit{orphan, indexed} {
+		if err := fsStorage.WriteCommit(commit); err != nil {
+			t.Fatalf("Failed to write orphan: %v", err)
+		}
+	}
+	repo.index.Entries = append([]storage.IndexEntry{{Hash: indexed.Hash, Timestamp: indexed.Timestamp}}, repo.index.Entries...)
+
+	// A dangling tag keeps its commit
+	tagged := &Commit{Hash: storage.GenerateHash("tagged"), Timestamp: time.Now(), Message: "Tagged", Content: "hush"}
+	if err := fsStorage.WriteCommit(tagged); err != nil {
+		t.Fatalf("Failed to write tagged commit: %v", err)
+	}
+	if err := repo.Tag("keep", tagged.Hash); err != nil {
+		t.Fatalf("Failed to tag: %v", err)
+	}
+
+	result, err := repo.GC(true)
+	if err != nil {
+		t.Fatalf("Failed to run dry-run GC: %v", err)
+	}
+	if len(result.Unreachable) != 1 || result.Reachable != 4 || result.Bytes == 0 {
+		t.Fatalf("Expected 1 unreachable and 4 reachable objects, got %d and %d", len(result.Unreachable), result.Reachable)
+	}
+	if !fsStorage.Exists(orphan.Hash) {
+		t.Fatalf("Expected dry run to keep the orphan")
+	}
+
+	if _, err := repo.GC(false); err != nil {
+		t.Fatalf("Failed to run GC: %v", err)
+	}
+	if fsStorage.Exists(orphan.Hash) {
+		t.Errorf("Expected the unreachable object to be deleted")
+	}
+	for _, commit := range append(commits, tagged, indexed) {
+		if !fsStorage.Exists(commit.Hash) {
+			t.Errorf("Expected reachable commit %s to be kept", commit.Message)
+		}
+	}
+
+	log, err := repo.Log(10)
+	if err != nil || len(log) != 3 {
+		t.Errorf("Expected 3 commits in the log after GC, got %d (%v)", len(log), err)
+	}
+	results, err := repo.Search("orphan", SearchOptions{})
+	if err != nil || len(results) != 0 {
+		t.Errorf("Expected the deleted commit to be gone from search, got %d results (%v)", len(results), err)
+	}
+
+	result, err = repo.GC(false)
+	if err != nil || len(result.Unreachable) != 0 {
+		t.Errorf("Expected nothing left to collect, got %v (%v)", result, err)
+	}
+}
+
+func TestGCBlobs(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	metadata := ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}
+	if _, err := repo.Commit("d1 $ s \"bd\"", "Kick", metadata); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	// The only commit with its content is unreachable
+	fsStorage := repo.storage.(*storage.FileSystemStorage)
+	orphan := &Commit{Hash: storage.GenerateHash("orphan"), Timestamp: time.Now(), Message: "Orphan", Content: "hush"}
+	if err := fsStorage.WriteCommit(orphan); err != nil {
+		t.Fatalf("Failed to write orphan: %v", err)
+	}
+
+	result, err := repo.GC(false)
+	if err != nil {
+		t.Fatalf("Failed to run GC: %v", err)
+	}
+	if len(result.Unreachable) != 1 || len(result.Blobs) != 1 || result.Blobs[0] != storage.BlobHash("hush") {
+		t.Fatalf("Expected the orphan and its blob to be deleted, got %v and %v", result.Unreachable, result.Blobs)
+	}
+
+	blobs, err := fsStorage.ListBlobs()
+	if err != nil || len(blobs) != 1 || blobs[0] != storage.BlobHash("d1 $ s \"bd\"") {
+		t.Errorf("Expected only the reachable commit's blob to be kept, got %v (%v)", blobs, err)
+	}
+	if _, err := repo.GetCommit(repo.index.GetHead()); err != nil {
+		t.Errorf("Expected the reachable commit to read back, got %v", err)
+	}
+}
+
+func TestGCKeepsBlobsOfUnreadableCommits(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	metadata := ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}
+	first, err := repo.Commit("d1 $ s \"bd\"", "Kick", metadata)
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if _, err := repo.Commit("d1 $ s \"bd sn\"", "Snare", metadata); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	// The first commit is still reachable, but its object can't be read
+	path := filepath.Join(tempDir, storage.RepoDir, storage.ObjectsDir, first.Hash[:2], first.Hash[2:])
+	if err := os.WriteFile(path, []byte("{\"message\": 42}"), 0644); err != nil {
+		t.Fatalf("Failed to damage object: %v", err)
+	}
+
+	result, err := repo.GC(false)
+	if err != nil {
+		t.Fatalf("Failed to run GC: %v", err)
+	}
+	if len(result.Unreadable) != 1 || result.Unreadable[0] != first.Hash {
+		t.Errorf("Expected the damaged commit reported, got %v", result.Unreadable)
+	}
+	if len(result.Blobs) != 0 {
+		t.Errorf("Expected no blob deleted, got %v", result.Blobs)
+	}
+	if blobs, err := repo.storage.(*storage.FileSystemStorage).ListBlobs(); err != nil || len(blobs) != 2 {
+		t.Errorf("Expected both blobs kept, got %v (%v)", blobs, err)
+	}
+}
+
+func TestGCKeepsLocalCommitsAfterPull(t *testing.T) {
+	laptopDir := createTempDir(t)
+	defer os.RemoveAll(laptopDir)
+	studioDir := createTempDir(t)
+	defer os.RemoveAll(studioDir)
+
+	laptop := NewRepository(laptopDir)
+	if err := laptop.Init(laptopDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	studio := NewRepository(studioDir)
+	if err := studio.Init(studioDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	metadata := ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}
+	local, err := studio.Commit("d2 $ s \"hh\"", "Local work", metadata)
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if _, err := laptop.Commit("d1 $ s \"bd\"", "Pulled work", metadata); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	// The pulled commit becomes HEAD, and its parents don't lead to the
+	// local one
+	history, _ := laptop.History()
+	if _, err := studio.ReceiveCommits(history); err != nil {
+		t.Fatalf("Failed to receive commits: %v", err)
+	}
+
+	result, err := studio.GC(false)
+	if err != nil {
+		t.Fatalf("Failed to run GC: %v", err)
+	}
+	if len(result.Unreachable) != 0 || len(result.Blobs) != 0 {
+		t.Errorf("Expected nothing collected, got %v and blobs %v", result.Unreachable, result.Blobs)
+	}
+	if commit, err := studio.GetCommit(local.Hash); err != nil || commit.Content != local.Content {
+		t.Errorf("Expected the local commit kept, got %+v (%v)", commit, err)
+	}
+	fsck, err := studio.Fsck(false)
+	if err != nil || fsck.Unrepaired() != 0 {
+		t.Errorf("Expected no problems after GC, got %+v (%v)", fsck, err)
+	}
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+// Hooks are executables in .livecodegit/hooks that receive each commit as
+// JSON on stdin: pre-commit before it is written, and can refuse it by
+// exiting non-zero, post-commit after, e.g. to change lights or scenes.
+const (
+	HooksDir       = "hooks"
+	PreCommitHook  = "pre-commit"
+	PostCommitHook = "post-commit"
+)
+
+// HookTimeout bounds how long the post-commit hook may hold up a commit
+const HookTimeout = 10 * time.Second
+
+// PreCommitTimeout bounds the pre-commit hook, which runs holding the
+// repository lock, so it ends before another writer gives up waiting
+const PreCommitTimeout = storage.DefaultLockTimeout - time.Second
+
+// ErrCommitRejected is returned, wrapped, when the pre-commit hook refuses a commit
+var ErrCommitRejected = errors.New("rejected by pre-commit hook")
+
+// SetHooksEnabled turns commit hooks on or off for this repository instance
+func (repo *LiveCodeRepository) SetHooksEnabled(enabled bool) {
+	repo.hooksDisabled = !enabled
+}
+
+// SetHookOutput sets where hooks' output and post-commit failures are
+// written; the default is standard error
+func (repo *LiveCodeRepository) SetHookOutput(w io.Writer) {
+	repo.hookOutput = w
+}
+
+// HookPath returns the path of the named hook
+func (repo *LiveCodeRepository) HookPath(name string) string {
+	return filepath.Join(repo.path, storage.RepoDir, HooksDir, name)
+}
+
+// preCommit runs the pre-commit hook, returning ErrCommitRejected when it
+// fails, with the hook's last line of output
+func (repo *LiveCodeRepository) preCommit(commit *Commit) error {
+	output, err := repo.runHook(PreCommitHook, commit, PreCommitTimeout)
+	if err == nil {
+		return nil
+	}
+
+	reason := err.Error()
+	if lines := strings.Split(strings.TrimSpace(output), "\n"); lines[len(lines)-1] != "" {
+		reason = lines[len(lines)-1]
+	}
+	return fmt.Errorf("%w: %s", ErrCommitRejected, reason)
+}
+
+// postCommit runs the post-commit hook. The commit is already written, so a
+// failure is only reported.
+func (repo *LiveCodeRepository) postCommit(commit *Commit) {
+	if _, err := repo.runHook(PostCommitHook, commit, HookTimeout); err != nil {
+		fmt.Fprintf(repo.hookWriter(), "post-commit hook failed: %v\n", err)
+	}
+}
+
+// runHook runs the named hook, if present and executable, with the commit
+// on stdin, in the repository's directory, for at most timeout. Its combined
+// output is copied to the hook output and returned.
+func (repo *LiveCodeRepository) runHook(name string, commit *Commit, timeout time.Duration) (string, error) {
+	if repo.hooksDisabled {
+		return "", nil
+	}
+
+	path := repo.HookPath(name)
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return "", nil
+	}
+	if runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+		fmt.Fprintf(repo.hookWriter(), "Ignoring %s hook: not executable (chmod +x %s)\n", name, path)
+		return "", nil
+	}
+
+	input, err := json.Marshal(commit)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode commit: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Dir = repo.path
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.Env = append(os.Environ(), "LCG_HOOK="+name, "LCG_COMMIT="+commit.Hash)
+
+	err = cmd.Run()
+	repo.hookWriter().Write(output.Bytes())
+	if ctx.Err() == context.DeadlineExceeded {
+		return output.String(), fmt.Errorf("%s hook timed out after %s", name, timeout)
+	}
+	if err != nil {
+		return output.String(), fmt.Errorf("%s hook: %w", name, err)
+	}
+	return output.String(), nil
+}
+
+// hookWriter returns where hook output goes
+func (repo *LiveCodeRepository) hookWriter() io.Writer {
+	if repo.hookOutput == nil {
+		return os.Stderr
+	}
+	return repo.hookOutput
+}
@@ -0,0 +1,144 @@
+//go:build !windows
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeHook installs a shell script as the named hook
+func writeHook(t *testing.T, repo *LiveCodeRepository, name, script string) {
+	path := repo.HookPath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create hooks directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatalf("Failed to write hook: %v", err)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	var output bytes.Buffer
+	repo.SetHookOutput(&output)
+
+	first, err := repo.Commit("d1 $ s \"bd\"", "Kick", ExecutionMetadata{Buffer: "d1", Language: "tidal"})
+	if err != nil {
+		t.Fatalf("Failed to commit without hooks: %v", err)
+	}
+
+	// The pre-commit hook refuses anything containing "silence"
+	writeHook(t, repo, PreCommitHook, `grep -q silence && { echo "no silence tonight"; exit 1; }; exit 0`+"\n")
+	writeHook(t, repo, PostCommitHook, "cat > post-commit.json\n")
+
+	_, err = repo.Commit("d1 $ silence", "Stop", ExecutionMetadata{Buffer: "d1", Language: "tidal"})
+	if !errors.Is(err, ErrCommitRejected) {
+		t.Fatalf("Expected the commit to be rejected, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "no silence tonight") {
+		t.Errorf("Expected the hook's reason in the error, got '%v'", err)
+	}
+	if head, _ := repo.Log(1); head[0].Hash != first.Hash {
+		t.Errorf("Expected HEAD to stay at %s", first.Hash)
+	}
+
+	second, err := repo.Commit("d1 $ s \"bd sn\"", "Snare", ExecutionMetadata{Buffer: "d1", Language: "tidal"})
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	// The post-commit hook ran in the repository's directory with the commit
+	data, err := os.ReadFile(filepath.Join(tempDir, "post-commit.json"))
+	if err != nil {
+		t.Fatalf("Expected the post-commit hook to write its input: %v", err)
+	}
+	var received Commit
+	if err := json.Unmarshal(data, &received); err != nil {
+		t.Fatalf("Failed to decode hook input: %v", err)
+	}
+	if received.Hash != second.Hash || received.Parent != first.Hash || received.Message != "Snare" {
+		t.Errorf("Expected the new commit on stdin, got %+v", received)
+	}
+
+	// Hooks can be skipped, and a failing post-commit hook doesn't undo the commit
+	repo.SetHooksEnabled(false)
+	if _, err := repo.Commit("d1 $ silence", "Stop", ExecutionMetadata{Buffer: "d1"}); err != nil {
+		t.Errorf("Expected hooks to be skipped: %v", err)
+	}
+	repo.SetHooksEnabled(true)
+
+	writeHook(t, repo, PostCommitHook, "exit 3\n")
+	if _, err := repo.Commit("d1 $ s \"hh\"", "Hats", ExecutionMetadata{Buffer: "d1"}); err != nil {
+		t.Errorf("Expected the commit to stand despite post-commit: %v", err)
+	}
+	if !strings.Contains(output.String(), "post-commit hook failed") {
+		t.Errorf("Expected the post-commit failure to be reported, got '%s'", output.String())
+	}
+
+	// A hook that isn't executable is ignored
+	if err := os.Chmod(repo.HookPath(PreCommitHook), 0644); err != nil {
+		t.Fatalf("Failed to chmod hook: %v", err)
+	}
+	if _, err := repo.Commit("d1 $ silence", "Stop", ExecutionMetadata{Buffer: "d1"}); err != nil {
+		t.Errorf("Expected a non-executable hook to be ignored: %v", err)
+	}
+}
+
+func TestPreCommitHookSeesStoredHash(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	repo.SetHookOutput(&bytes.Buffer{})
+	writeHook(t, repo, PreCommitHook, "echo \"$LCG_COMMIT\" > pre-commit.hash\n")
+	writeHook(t, repo, PostCommitHook, "test -e .livecodegit/index.lock && echo locked > post-commit.lock; exit 0\n")
+
+	// Another process commits after this one last read the index, so the
+	// parent is only known once the lock is held
+	other, err := LoadRepository(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to load repository: %v", err)
+	}
+	other.SetHooksEnabled(false)
+	if _, err := other.Commit("d1 $ s \"bd\"", "Kick", ExecutionMetadata{Buffer: "d1", Language: "tidal"}); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	commit, err := repo.Commit("d1 $ s \"bd sn\"", "Snare", ExecutionMetadata{Buffer: "d1", Language: "tidal"})
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(tempDir, "pre-commit.hash"))
+	if err != nil {
+		t.Fatalf("Expected the pre-commit hook to record the hash: %v", err)
+	}
+	if hash := strings.TrimSpace(string(data)); hash != commit.Hash {
+		t.Errorf("Expected LCG_COMMIT %s, got %s", commit.Hash, hash)
+	}
+	if stored, err := repo.GetCommit(commit.Hash); err != nil || stored.Parent == "" {
+		t.Errorf("Expected the stored commit on top of the other one, got %+v (%v)", stored, err)
+	}
+
+	// Amending runs the post-commit hook once the lock is released
+	if _, err := repo.Amend("d1 $ s \"bd cp\"", "Clap", ExecutionMetadata{Buffer: "d1", Language: "tidal"}); err != nil {
+		t.Fatalf("Failed to amend: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "post-commit.lock")); err == nil {
+		t.Errorf("Expected the post-commit hook to run without the lock held")
+	}
+}
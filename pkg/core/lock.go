@@ -0,0 +1,65 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+// SetLockTimeout sets how long changes wait for another process holding the
+// repository lock; zero waits storage.DefaultLockTimeout
+func (repo *LiveCodeRepository) SetLockTimeout(timeout time.Duration) {
+	repo.lockTimeout = timeout
+}
+
+// LockHolder returns the process holding the repository lock, or nil when
+// it's free
+func (repo *LiveCodeRepository) LockHolder() (*storage.LockInfo, error) {
+	fsStorage, ok := repo.storage.(*storage.FileSystemStorage)
+	if !ok || !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+	return fsStorage.ReadLock()
+}
+
+// ForceUnlock removes a repository lock left behind by a process that died,
+// returning its holder or nil when there was none
+func (repo *LiveCodeRepository) ForceUnlock() (*storage.LockInfo, error) {
+	fsStorage, ok := repo.storage.(*storage.FileSystemStorage)
+	if !ok || !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+	return fsStorage.ForceUnlock()
+}
+
+// lock takes the repository lock for a change to the index, HEAD or objects,
+// and brings the index up to date with what other processes committed
+// meanwhile; the search index follows when next loaded. The returned
+// function releases the lock.
+func (repo *LiveCodeRepository) lock() (func(), error) {
+	unlock := func() {}
+	if locker, ok := repo.storage.(storage.Locker); ok {
+		timeout := repo.lockTimeout
+		if timeout == 0 {
+			timeout = storage.DefaultLockTimeout
+		}
+		if err := locker.Lock(timeout); err != nil {
+			return nil, err
+		}
+		unlock = func() { locker.Unlock() }
+	}
+
+	if repo.index == nil {
+		repo.index = storage.NewIndex(repo.storage)
+	}
+	refreshed, err := repo.index.Refresh()
+	if err != nil {
+		unlock()
+		return nil, fmt.Errorf("failed to load index: %w", err)
+	}
+	if refreshed {
+		repo.searchIndex = nil
+	}
+	return unlock, nil
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+func TestConcurrentCommits(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if err := NewRepository(tempDir).Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	// Two processes, e.g. a running watcher and a commit from the CLI
+	var repos []*LiveCodeRepository
+	for i := 0; i < 2; i++ {
+		repo, err := LoadRepository(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to load repository: %v", err)
+		}
+		repos = append(repos, repo)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i, repo := range repos {
+		wg.Add(1)
+		go func(i int, repo *LiveCodeRepository) {
+			defer wg.Done()
+			for n := 0; n < 10; n++ {
+				metadata := ExecutionMetadata{Buffer: fmt.Sprintf("d%d", i+1), Language: "tidal", Success: true}
+				if _, err := repo.Commit(fmt.Sprintf("d%d $ s \"bd*%d\"", i+1, n), "Run", metadata); err != nil {
+					errs <- err
+				}
+			}
+		}(i, repo)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	reloaded, err := LoadRepository(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to load repository: %v", err)
+	}
+	if len(reloaded.index.Entries) != 20 {
+		t.Fatalf("Expected every commit of both processes indexed, got %d", len(reloaded.index.Entries))
+	}
+	for i, entry := range reloaded.index.Entries[1:] {
+		if entry.Parent != reloaded.index.Entries[i].Hash {
+			t.Errorf("Expected one line of history, got %s after %s", entry.Parent, reloaded.index.Entries[i].Hash)
+		}
+	}
+	results, err := reloaded.Search("bd", SearchOptions{})
+	if err != nil || len(results) != 20 {
+		t.Errorf("Expected every commit searchable, got %d (%v)", len(results), err)
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	repo.SetLockTimeout(50 * time.Millisecond)
+
+	// Left behind by a process that died
+	if err := repo.storage.(*storage.FileSystemStorage).Lock(time.Second); err != nil {
+		t.Fatalf("Failed to lock: %v", err)
+	}
+
+	metadata := ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}
+	if _, err := repo.Commit("d1 $ s \"bd\"", "Kick", metadata); !errors.Is(err, storage.ErrLocked) {
+		t.Fatalf("Expected the commit to time out on the lock, got %v", err)
+	}
+
+	holder, err := repo.ForceUnlock()
+	if err != nil || holder == nil {
+		t.Fatalf("Failed to force the lock: %+v (%v)", holder, err)
+	}
+	if _, err := repo.Commit("d1 $ s \"bd\"", "Kick", metadata); err != nil {
+		t.Errorf("Expected the commit once unlocked, got %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+// MigrateResult reports a migration to the current format version
+type MigrateResult struct {
+	From, To int               // format versions
+	Rehashed map[string]string // old hash -> new hash of every indexed commit
+}
+
+// Migrate brings the repository to the current format version, rehashing
+// every indexed commit with the canonical SHA-256 hash. Parents, the index,
+// HEAD, tags, performances, checkpoints, snapshots and privacy levels move
+// to the new hashes, and the old objects are deleted once nothing refers to them.
+// Commits outside the index are left for gc.
+func (repo *LiveCodeRepository) Migrate() (*MigrateResult, error) {
+	fsStorage, ok := repo.storage.(*storage.FileSystemStorage)
+	if !ok || !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+	if err := repo.checkWritable(); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(repo.bisectPath()); err == nil {
+		return nil, fmt.Errorf("a bisect is in progress; end it with 'lcg bisect reset' first")
+	}
+	unlock, err := repo.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	version, err := fsStorage.FormatVersion()
+	if err != nil {
+		return nil, err
+	}
+	result := &MigrateResult{From: version, To: storage.CurrentFormatVersion, Rehashed: make(map[string]string)}
+	if version >= storage.CurrentFormatVersion {
+		return result, nil
+	}
+	if err := repo.flushPerformance(); err != nil {
+		return nil, err
+	}
+
+	// New objects first, parents before their children, so a failure
+	// leaves the old history whole
+	indexed := make(map[string]bool, len(repo.index.AllEntries()))
+	for _, entry := range repo.index.AllEntries() {
+		indexed[entry.Hash] = true
+	}
+	var rehash func(hash string) (string, error)
+	rehash = func(hash string) (string, error) {
+		if !indexed[hash] {
+			return hash, nil
+		}
+		if rehashed, ok := result.Rehashed[hash]; ok {
+			return rehashed, nil
+		}
+
+		commit, err := fsStorage.ReadCommit(hash)
+		if err != nil {
+			return "", err
+		}
+		if commit.Parent, err = rehash(commit.Parent); err != nil {
+			return "", err
+		}
+		if commit.BufferParent, err = rehash(commit.BufferParent); err != nil {
+			return "", err
+		}
+		commit.Hash = storage.HashCommit(commit, result.To)
+		if err := fsStorage.WriteCommit(commit); err != nil {
+			return "", fmt.Errorf("failed to write commit %s: %w", commit.Hash, err)
+		}
+
+		result.Rehashed[hash] = commit.Hash
+		return commit.Hash, nil
+	}
+	commits := make([]*Commit, 0, len(repo.index.AllEntries()))
+	for _, entry := range repo.index.AllEntries() {
+		hash, err := rehash(entry.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rehash commit %s: %w", entry.Hash, err)
+		}
+		commit, err := fsStorage.ReadCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, commit)
+	}
+	moved := func(hash string) string {
+		if rehashed, ok := result.Rehashed[hash]; ok {
+			return rehashed
+		}
+		return hash
+	}
+
+	// Then everything referring to them
+	repo.index.Entries = nil
+	for _, commit := range commits {
+		repo.index.RestoreCommit(commit)
+	}
+	if err := repo.index.SaveIndex(); err != nil {
+		return nil, fmt.Errorf("failed to update index: %w", err)
+	}
+	if head := repo.index.GetHead(); head != "" {
+		if err := fsStorage.WriteHead(head); err != nil {
+			return nil, fmt.Errorf("failed to update HEAD: %w", err)
+		}
+	}
+
+	tags, err := fsStorage.ReadTags()
+	if err != nil {
+		return nil, err
+	}
+	for name, hash := range tags {
+		if err := fsStorage.WriteTag(name, moved(hash)); err != nil {
+			return nil, fmt.Errorf("failed to update tag %s: %w", name, err)
+		}
+	}
+
+	performances, err := fsStorage.ListPerformances()
+	if err != nil {
+		return nil, err
+	}
+	for _, performance := range performances {
+		performance.HeadCommit = moved(performance.HeadCommit)
+		for i := range performance.Markers {
+			marker := &performance.Markers[i]
+			marker.Commit = moved(marker.Commit)
+			for buffer, hash := range marker.Buffers {
+				marker.Buffers[buffer] = moved(hash)
+			}
+		}
+		if err := fsStorage.WritePerformance(performance); err != nil {
+			return nil, fmt.Errorf("failed to update performance %s: %w", performance.Name, err)
+		}
+	}
+	if err := repo.restoreCurrentPerformance(); err != nil {
+		return nil, err
+	}
+
+	checkpoints, err := repo.Checkpoints()
+	if err != nil {
+		return nil, err
+	}
+	if len(checkpoints) > 0 {
+		for _, checkpoint := range checkpoints {
+			checkpoint.Commit = moved(checkpoint.Commit)
+			for buffer, hash := range checkpoint.Buffers {
+				checkpoint.Buffers[buffer] = moved(hash)
+			}
+		}
+		if err := repo.writeCheckpoints(checkpoints); err != nil {
+			return nil, err
+		}
+	}
+
+	snapshots, err := repo.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		old := snapshot.Hash
+		snapshot.Commit = moved(snapshot.Commit)
+		for i := range snapshot.Entries {
+			snapshot.Entries[i].Commit = moved(snapshot.Entries[i].Commit)
+		}
+		snapshot.Hash = snapshotHash(snapshot.Entries)
+		if err := repo.writeSnapshot(snapshot); err != nil {
+			return nil, err
+		}
+		if snapshot.Hash != old {
+			os.Remove(filepath.Join(repo.snapshotsPath(), old))
+		}
+	}
+
+	// A commit kept private or redacted must stay so under its new hash
+	err = repo.updatePrivacy(func(rules *PrivacyRules) {
+		commits := make(map[string]PrivacyLevel, len(rules.Commits))
+		for hash, level := range rules.Commits {
+			commits[moved(hash)] = level
+		}
+		rules.Commits = commits
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fsStorage.SetFormatVersion(result.To); err != nil {
+		return nil, err
+	}
+
+	// Last, the old objects nothing refers to anymore
+	reachable, err := repo.reachableCommits()
+	if err != nil {
+		return nil, err
+	}
+	for old, rehashed := range result.Rehashed {
+		if old != rehashed && !reachable[old] {
+			if err := fsStorage.DeleteObject(old); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	searchIndex := storage.NewSearchIndex(fsStorage)
+	if err := searchIndex.RebuildSearchIndex(); err != nil {
+		return nil, fmt.Errorf("failed to rebuild search index: %w", err)
+	}
+	repo.searchIndex = nil
+
+	return result, nil
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"os"
+	"testing"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+func TestMigrate(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	// A repository made before SHA-256 hashes
+	fsStorage := repo.storage.(*storage.FileSystemStorage)
+	if err := fsStorage.SetFormatVersion(storage.FormatSHA1); err != nil {
+		t.Fatalf("Failed to set format version: %v", err)
+	}
+	if _, err := repo.StartPerformance("Old set"); err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+	kick, err := repo.Commit("d1 $ s \"bd*4\"", "Kick", ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	hats, err := repo.Commit("d2 $ s \"hh*8\"", "Hats", ExecutionMetadata{Buffer: "d2", Language: "tidal", Success: true})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	kick2, err := repo.Commit("d1 $ s \"bd*2\"", "Half kick", ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if len(kick.Hash) != 40 {
+		t.Fatalf("Expected SHA-1 hashes at format version 1, got %s", kick.Hash)
+	}
+	if err := repo.Tag("intro", kick.Hash); err != nil {
+		t.Fatalf("Failed to tag: %v", err)
+	}
+	if _, err := repo.Mark("drop"); err != nil {
+		t.Fatalf("Failed to mark: %v", err)
+	}
+	if _, err := repo.SaveCheckpoint("groove"); err != nil {
+		t.Fatalf("Failed to save checkpoint: %v", err)
+	}
+	snapshot, err := repo.Snapshot()
+	if err != nil {
+		t.Fatalf("Failed to take snapshot: %v", err)
+	}
+	if err := repo.SetCommitPrivacy(hats.Hash, PrivacyPrivate); err != nil {
+		t.Fatalf("Failed to set privacy: %v", err)
+	}
+
+	result, err := repo.Migrate()
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if result.From != storage.FormatSHA1 || result.To != storage.FormatSHA256 || len(result.Rehashed) != 3 {
+		t.Fatalf("Expected 3 commits rehashed from version 1 to 2, got %+v", result)
+	}
+
+	loaded, err := LoadRepository(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to load repository: %v", err)
+	}
+	commits, err := loaded.Log(0)
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	if len(commits) != 3 {
+		t.Fatalf("Expected 3 commits, got %d", len(commits))
+	}
+	head, kickNew := commits[0], commits[2]
+	if head.Hash != result.Rehashed[kick2.Hash] || len(head.Hash) != 64 || !storage.VerifyHash(head) {
+		t.Errorf("Expected HEAD rehashed with SHA-256, got %s", head.Hash)
+	}
+	if head.Parent != result.Rehashed[hats.Hash] || head.BufferParent != kickNew.Hash {
+		t.Errorf("Expected parents to follow the new hashes, got %s and %s", head.Parent, head.BufferParent)
+	}
+
+	if tagged, err := loaded.ResolveCommit("intro"); err != nil || tagged.Hash != kickNew.Hash {
+		t.Errorf("Expected the tag to move to %s, got %+v (%v)", kickNew.Hash, tagged, err)
+	}
+	performance, err := loaded.GetCurrentPerformance()
+	if err != nil || performance == nil || performance.HeadCommit != head.Hash ||
+		len(performance.Markers) != 1 || performance.Markers[0].Commit != head.Hash {
+		t.Errorf("Expected the performance and its marker to move to HEAD, got %+v", performance)
+	}
+	checkpoint, err := loaded.GetCheckpoint("groove")
+	if err != nil || checkpoint.Buffers["d1"] != head.Hash || checkpoint.Buffers["d2"] != result.Rehashed[hats.Hash] {
+		t.Errorf("Expected the checkpoint to move, got %+v (%v)", checkpoint, err)
+	}
+	migrated, err := loaded.GetSnapshot("")
+	if err != nil || migrated.Hash == snapshot.Hash || migrated.Entry("d1").Commit != head.Hash {
+		t.Errorf("Expected the snapshot rehashed, got %+v (%v)", migrated, err)
+	}
+	if snapshots, _ := loaded.Snapshots(); len(snapshots) != 1 {
+		t.Errorf("Expected the old snapshot replaced, got %d", len(snapshots))
+	}
+	rules, err := loaded.Privacy()
+	if err != nil {
+		t.Fatalf("Failed to read privacy rules: %v", err)
+	}
+	if level := rules.Level(commits[1]); level != PrivacyPrivate {
+		t.Errorf("Expected the private commit to stay private under its new hash, got %s", level)
+	}
+	if _, exists := rules.Commits[hats.Hash]; exists || len(rules.Commits) != 1 {
+		t.Errorf("Expected the privacy level moved off the old hash, got %v", rules.Commits)
+	}
+
+	// Nothing refers to the old objects, and everything checks out
+	if fsStorage.Exists(kick.Hash) {
+		t.Errorf("Expected the old object %s to be deleted", kick.Hash)
+	}
+	fsck, err := loaded.Fsck(false)
+	if err != nil || len(fsck.Problems) != 0 || fsck.Objects != 3 {
+		t.Errorf("Expected a clean fsck of 3 objects, got %+v (%v)", fsck, err)
+	}
+
+	// New commits hash with SHA-256, and migrating again does nothing
+	next, err := loaded.Commit("d2 $ s \"hh*16\"", "Faster hats", ExecutionMetadata{Buffer: "d2", Language: "tidal", Success: true})
+	if err != nil || len(next.Hash) != 64 || next.Parent != head.Hash {
+		t.Errorf("Expected a SHA-256 commit on top of HEAD, got %+v (%v)", next, err)
+	}
+	if again, err := loaded.Migrate(); err != nil || len(again.Rehashed) != 0 {
+		t.Errorf("Expected migrating again to do nothing, got %+v (%v)", again, err)
+	}
+}
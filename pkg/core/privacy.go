@@ -0,0 +1,167 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+// PrivacyFile holds the privacy levels of buffers and commits
+const PrivacyFile = "privacy"
+
+// PrivacyLevel says how much of a commit may leave the repository in exports
+type PrivacyLevel string
+
+const (
+	PrivacyPublic   PrivacyLevel = "public"   // exported as recorded
+	PrivacyRedacted PrivacyLevel = "redacted" // exported without its code
+	PrivacyPrivate  PrivacyLevel = "private"  // left out of exports
+)
+
+// ParsePrivacyLevel checks a privacy level given by name
+func ParsePrivacyLevel(name string) (PrivacyLevel, error) {
+	switch level := PrivacyLevel(name); level {
+	case PrivacyPublic, PrivacyRedacted, PrivacyPrivate:
+		return level, nil
+	}
+	return "", fmt.Errorf("unknown privacy level %q (public, redacted or private)", name)
+}
+
+// PrivacyRules are the levels set for buffers and single commits; anything
+// without a level is public, and a commit's own level wins over its buffer's
+type PrivacyRules struct {
+	Buffers map[string]PrivacyLevel `json:"buffers,omitempty"`
+	Commits map[string]PrivacyLevel `json:"commits,omitempty"`
+}
+
+// Level returns the privacy level of a commit
+func (r *PrivacyRules) Level(commit *Commit) PrivacyLevel {
+	if level, exists := r.Commits[commit.Hash]; exists {
+		return level
+	}
+	if level, exists := r.Buffers[commit.Metadata.Buffer]; exists {
+		return level
+	}
+	return PrivacyPublic
+}
+
+// Empty reports whether every commit is public
+func (r *PrivacyRules) Empty() bool {
+	return len(r.Buffers) == 0 && len(r.Commits) == 0
+}
+
+// Strict returns rules under which redacted commits are left out too, for
+// exports that must keep every commit's code so its hash can be verified
+func (r *PrivacyRules) Strict() *PrivacyRules {
+	strict := &PrivacyRules{}
+	for buffer, level := range r.Buffers {
+		strict.Buffers = setPrivacyLevel(strict.Buffers, buffer, strictLevel(level))
+	}
+	for hash, level := range r.Commits {
+		strict.Commits = setPrivacyLevel(strict.Commits, hash, strictLevel(level))
+	}
+	return strict
+}
+
+// strictLevel treats redacted as private
+func strictLevel(level PrivacyLevel) PrivacyLevel {
+	if level == PrivacyRedacted {
+		return PrivacyPrivate
+	}
+	return level
+}
+
+// SortedBuffers returns the buffers with a privacy level, by name
+func (r *PrivacyRules) SortedBuffers() []string {
+	buffers := make([]string, 0, len(r.Buffers))
+	for buffer := range r.Buffers {
+		buffers = append(buffers, buffer)
+	}
+	sort.Strings(buffers)
+	return buffers
+}
+
+// Privacy returns the repository's privacy rules
+func (repo *LiveCodeRepository) Privacy() (*PrivacyRules, error) {
+	rules := &PrivacyRules{}
+	data, err := os.ReadFile(repo.privacyPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return rules, nil
+		}
+		return nil, fmt.Errorf("failed to read privacy rules: %w", err)
+	}
+
+	if err := json.Unmarshal(data, rules); err != nil {
+		return nil, fmt.Errorf("failed to parse privacy rules: %w", err)
+	}
+	return rules, nil
+}
+
+// SetBufferPrivacy sets the level of every commit in a buffer
+func (repo *LiveCodeRepository) SetBufferPrivacy(buffer string, level PrivacyLevel) error {
+	if buffer == "" {
+		return fmt.Errorf("buffer name is required")
+	}
+	return repo.updatePrivacy(func(rules *PrivacyRules) {
+		rules.Buffers = setPrivacyLevel(rules.Buffers, buffer, level)
+	})
+}
+
+// SetCommitPrivacy sets the level of one commit, overriding its buffer's
+func (repo *LiveCodeRepository) SetCommitPrivacy(hash string, level PrivacyLevel) error {
+	if !repo.storage.Exists(hash) {
+		return fmt.Errorf("commit %s not found", hash)
+	}
+	return repo.updatePrivacy(func(rules *PrivacyRules) {
+		rules.Commits = setPrivacyLevel(rules.Commits, hash, level)
+	})
+}
+
+// setPrivacyLevel records a level in a map that may not exist yet
+func setPrivacyLevel(levels map[string]PrivacyLevel, key string, level PrivacyLevel) map[string]PrivacyLevel {
+	if levels == nil {
+		levels = make(map[string]PrivacyLevel)
+	}
+	levels[key] = level
+	return levels
+}
+
+// updatePrivacy changes the privacy rules and writes them back
+func (repo *LiveCodeRepository) updatePrivacy(change func(rules *PrivacyRules)) error {
+	if !repo.IsInitialized() {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	rules, err := repo.Privacy()
+	if err != nil {
+		return err
+	}
+	change(rules)
+
+	// Public buffers are the default; public commits are kept, as they may
+	// override their buffer
+	for buffer, level := range rules.Buffers {
+		if level == PrivacyPublic {
+			delete(rules.Buffers, buffer)
+		}
+	}
+
+	data, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal privacy rules: %w", err)
+	}
+	if err := os.WriteFile(repo.privacyPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write privacy rules: %w", err)
+	}
+	return nil
+}
+
+// privacyPath returns the path of the privacy file
+func (repo *LiveCodeRepository) privacyPath() string {
+	return filepath.Join(repo.path, storage.RepoDir, PrivacyFile)
+}
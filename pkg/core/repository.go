@@ -1,20 +1,40 @@
 package core
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/livecodegit/pkg/storage"
 )
 
+// DefaultPerformanceFlushInterval is how often active performance metadata is written during commits
+const DefaultPerformanceFlushInterval = 5 * time.Second
+
 // LiveCodeRepository implements the RepositoryInterface for livecoding version control
 type LiveCodeRepository struct {
 	path               string
 	storage            StorageInterface
 	index              *storage.Index
+	searchIndex        *storage.SearchIndex
 	currentPerformance *Performance
+	config             *FileConfig
+
+	// Performance metadata is written at most once per flush interval
+	performanceFlushInterval time.Duration
+	performanceDirty         bool
+	lastPerformanceFlush     time.Time
+
+	// Commit hooks, see hooks.go
+	hooksDisabled bool
+	hookOutput    io.Writer
+
+	// How long changes wait for the repository lock, see lock.go
+	lockTimeout time.Duration
 }
 
 // NewRepository creates a new LiveCodeGit repository instance
@@ -23,14 +43,37 @@ func NewRepository(path string) *LiveCodeRepository {
 	index := storage.NewIndex(fsStorage)
 
 	return &LiveCodeRepository{
-		path:    path,
-		storage: fsStorage,
-		index:   index,
+		path:                     path,
+		storage:                  fsStorage,
+		index:                    index,
+		performanceFlushInterval: DefaultPerformanceFlushInterval,
+	}
+}
+
+// NewRepositoryWithStorage creates a repository instance keeping its
+// commits, references and indexes in store rather than in the repository
+// directory at path, which still holds its configuration. Maintenance such as
+// gc, fsck and repack needs filesystem storage.
+func NewRepositoryWithStorage(path string, store StorageInterface) *LiveCodeRepository {
+	return &LiveCodeRepository{
+		path:                     path,
+		storage:                  store,
+		index:                    storage.NewIndex(store),
+		performanceFlushInterval: DefaultPerformanceFlushInterval,
 	}
 }
 
 // Init initializes a new LiveCodeGit repository
 func (repo *LiveCodeRepository) Init(path string) error {
+	return repo.InitWithBackend(path, "")
+}
+
+// InitWithBackend initializes a new repository storing its objects with a
+// storage backend, e.g. storage.BackendKV; an empty backend is the default
+func (repo *LiveCodeRepository) InitWithBackend(path, backend string) error {
+	if !storage.ValidBackend(backend) {
+		return fmt.Errorf("unknown storage backend %q", backend)
+	}
 	repo.path = path
 
 	// Check if repository already exists
@@ -44,6 +87,17 @@ func (repo *LiveCodeRepository) Init(path string) error {
 	if err := fsStorage.InitializeRepository(); err != nil {
 		return fmt.Errorf("failed to initialize repository: %w", err)
 	}
+	if err := fsStorage.SetFormatVersion(storage.CurrentFormatVersion); err != nil {
+		return fmt.Errorf("failed to initialize repository: %w", err)
+	}
+	if err := fsStorage.SetBlobs(true); err != nil {
+		return fmt.Errorf("failed to initialize repository: %w", err)
+	}
+	if backend != "" {
+		if err := fsStorage.SetBackend(backend); err != nil {
+			return fmt.Errorf("failed to initialize repository: %w", err)
+		}
+	}
 
 	// Initialize index
 	repo.storage = fsStorage
@@ -58,73 +112,311 @@ func (repo *LiveCodeRepository) Init(path string) error {
 
 // Commit creates a new commit with the given content and metadata
 func (repo *LiveCodeRepository) Commit(content string, message string, metadata ExecutionMetadata) (*Commit, error) {
+	return repo.CommitAs(content, message, metadata, UserConfig{})
+}
+
+// CommitAs creates a commit attributed to author; an empty author name falls
+// back to the configured user
+func (repo *LiveCodeRepository) CommitAs(content string, message string, metadata ExecutionMetadata, author UserConfig) (*Commit, error) {
 	if !repo.IsInitialized() {
 		return nil, fmt.Errorf("repository not initialized")
 	}
 
 	// Load index if not already loaded
 	if repo.index == nil {
-		repo.index = storage.NewIndex(repo.storage.(*storage.FileSystemStorage))
+		repo.index = storage.NewIndex(repo.storage)
 		if err := repo.index.LoadIndex(); err != nil {
 			return nil, fmt.Errorf("failed to load index: %w", err)
 		}
 	}
 
-	// Generate hash from content
-	hash := storage.GenerateHash(content + message + time.Now().String())
-
-	// Get parent commit
-	parentHash := repo.index.GetHead()
+	user := author
+	if user.Name == "" {
+		var err error
+		if user, err = repo.user(); err != nil {
+			return nil, err
+		}
+	}
 
 	// Create commit
 	commit := &Commit{
-		Hash:      hash,
-		Parent:    parentHash,
-		Timestamp: time.Now(),
-		Message:   message,
-		Author:    "livecoder", // TODO: Get from config
-		Content:   content,
-		Metadata:  metadata,
+		Timestamp:   time.Now(),
+		Message:     message,
+		Author:      user.Name,
+		AuthorEmail: user.Email,
+		Content:     content,
+		Metadata:    metadata,
+	}
+
+	if err := repo.writeCommit(commit, repo.preCommit); err != nil {
+		return nil, err
+	}
+
+	// Update current performance if active
+	if repo.currentPerformance != nil {
+		repo.currentPerformance.RecordCommit(commit)
+		repo.performanceDirty = true
+
+		if time.Since(repo.lastPerformanceFlush) >= repo.performanceFlushInterval {
+			if err := repo.FlushPerformance(); err != nil {
+				return nil, fmt.Errorf("failed to update performance: %w", err)
+			}
+		}
+	}
+
+	repo.postCommit(commit)
+	return commit, nil
+}
+
+// ImportCommit records a commit made elsewhere, keeping its time, author,
+// message, content and metadata; its hash and parent are assigned here.
+// Imported commits are not counted towards the active performance.
+func (repo *LiveCodeRepository) ImportCommit(commit *Commit) error {
+	if !repo.IsInitialized() {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	return repo.writeCommit(commit, nil)
+}
+
+// Amend replaces the HEAD commit with one holding the given content, message
+// and metadata, keeping its time, author and parent. The index entry, HEAD,
+// search index and active performance move to the new commit, and the
+// replaced object is deleted unless a tag or marker still refers to it.
+func (repo *LiveCodeRepository) Amend(content string, message string, metadata ExecutionMetadata) (*Commit, error) {
+	commit, err := repo.amend(content, message, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	// Once the lock is released, so other writers needn't wait for the hook
+	repo.postCommit(commit)
+	return commit, nil
+}
+
+// amend is Amend up to the post-commit hook, holding the repository lock
+func (repo *LiveCodeRepository) amend(content string, message string, metadata ExecutionMetadata) (*Commit, error) {
+	if repo.storage == nil || !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+	if err := repo.checkWritable(); err != nil {
+		return nil, err
+	}
+	unlock, err := repo.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	head := repo.index.GetHead()
+	if head == "" {
+		return nil, fmt.Errorf("no commit to amend")
+	}
+	replaced, err := repo.storage.ReadCommit(head)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD commit: %w", err)
+	}
+
+	commit := &Commit{
+		Parent:       replaced.Parent,
+		BufferParent: replaced.BufferParent,
+		Timestamp:    replaced.Timestamp,
+		Message:      message,
+		Author:       replaced.Author,
+		AuthorEmail:  replaced.AuthorEmail,
+		Content:      content,
+		Metadata:     metadata,
+	}
+	if metadata.Buffer != replaced.Metadata.Buffer {
+		commit.BufferParent = repo.index.BufferHead(metadata.Buffer, len(repo.index.AllEntries())-1)
+	}
+	if err := repo.hashCommit(commit); err != nil {
+		return nil, err
+	}
+	if err := repo.preCommit(commit); err != nil {
+		return nil, err
 	}
 
-	// Store commit
 	if err := repo.storage.WriteCommit(commit); err != nil {
 		return nil, fmt.Errorf("failed to write commit: %w", err)
 	}
-
-	// Update index
-	if err := repo.index.AddEntry(hash, message, parentHash, commit.Timestamp); err != nil {
+	if err := repo.index.ReplaceHead(commit); err != nil {
 		return nil, fmt.Errorf("failed to update index: %w", err)
 	}
+	if err := repo.storage.WriteHead(commit.Hash); err != nil {
+		return nil, fmt.Errorf("failed to update HEAD: %w", err)
+	}
 
-	// Update HEAD
-	if fsStorage, ok := repo.storage.(*storage.FileSystemStorage); ok {
-		if err := fsStorage.WriteHead(hash); err != nil {
-			return nil, fmt.Errorf("failed to update HEAD: %w", err)
-		}
+	searchIndex := repo.searchIndexWriter()
+	if err := searchIndex.RemoveCommits(map[string]bool{replaced.Hash: true}); err != nil {
+		return nil, fmt.Errorf("failed to update search index: %w", err)
+	}
+	if err := searchIndex.AddCommit(commit); err != nil {
+		return nil, fmt.Errorf("failed to update search index: %w", err)
 	}
 
-	// Update current performance if active
-	if repo.currentPerformance != nil {
-		repo.currentPerformance.CommitCount++
-		repo.currentPerformance.HeadCommit = hash
-		if err := repo.storage.WritePerformance(repo.currentPerformance); err != nil {
+	if repo.currentPerformance != nil && repo.currentPerformance.HeadCommit == replaced.Hash {
+		repo.currentPerformance.ReplaceHead(replaced, commit)
+		repo.performanceDirty = true
+		if err := repo.flushPerformance(); err != nil {
 			return nil, fmt.Errorf("failed to update performance: %w", err)
 		}
 	}
 
+	if replaced.Hash != commit.Hash {
+		reachable, err := repo.reachableCommits()
+		if err != nil {
+			return nil, err
+		}
+		if !reachable[replaced.Hash] {
+			if err := repo.storage.DeleteObject(replaced.Hash); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	return commit, nil
 }
 
+// writeCommit hashes commit onto HEAD and stores it with its index entries.
+// check, when not nil, sees the commit as it will be written and can refuse
+// it.
+func (repo *LiveCodeRepository) writeCommit(commit *Commit, check func(*Commit) error) error {
+	if err := repo.checkWritable(); err != nil {
+		return err
+	}
+
+	unlock, err := repo.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Generate hash from content
+	commit.Parent = repo.index.GetHead()
+	commit.BufferParent = repo.index.BufferHead(commit.Metadata.Buffer, len(repo.index.AllEntries()))
+	if err := repo.hashCommit(commit); err != nil {
+		return err
+	}
+	hash := commit.Hash
+	if check != nil {
+		if err := check(commit); err != nil {
+			return err
+		}
+	}
+
+	// Store commit
+	if err := repo.storage.WriteCommit(commit); err != nil {
+		return fmt.Errorf("failed to write commit: %w", err)
+	}
+
+	// Update index
+	if err := repo.index.AddCommit(commit); err != nil {
+		return fmt.Errorf("failed to update index: %w", err)
+	}
+
+	// Update HEAD
+	if err := repo.storage.WriteHead(hash); err != nil {
+		return fmt.Errorf("failed to update HEAD: %w", err)
+	}
+
+	// Update search index
+	if err := repo.searchIndexWriter().AddCommit(commit); err != nil {
+		return fmt.Errorf("failed to update search index: %w", err)
+	}
+
+	return nil
+}
+
+// FlushPerformance writes pending changes to the active performance metadata
+func (repo *LiveCodeRepository) FlushPerformance() error {
+	if repo.currentPerformance == nil || !repo.performanceDirty {
+		return nil
+	}
+
+	unlock, err := repo.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return repo.flushPerformance()
+}
+
+// flushPerformance is FlushPerformance for callers holding the repository
+// lock. A performance ended elsewhere meanwhile, e.g. by 'lcg performance
+// end' while a watcher runs, is not written back but no longer active here.
+func (repo *LiveCodeRepository) flushPerformance() error {
+	if repo.currentPerformance == nil || !repo.performanceDirty {
+		return nil
+	}
+
+	superseded, err := repo.performanceSuperseded()
+	if err != nil {
+		return err
+	}
+	if superseded {
+		repo.currentPerformance = nil
+		repo.performanceDirty = false
+		return nil
+	}
+
+	if err := repo.storage.WritePerformance(repo.currentPerformance); err != nil {
+		return err
+	}
+
+	repo.performanceDirty = false
+	repo.lastPerformanceFlush = time.Now()
+	return nil
+}
+
+// performanceSuperseded reports whether the stored copy of the active
+// performance has ended, been deleted or been replaced by another
+// performance since it was loaded; called with the repository lock held
+func (repo *LiveCodeRepository) performanceSuperseded() (bool, error) {
+	stored, err := repo.storage.ReadPerformance(repo.currentPerformance.ID)
+	if errors.Is(err, os.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !stored.EndTime.IsZero() || !stored.StartTime.Equal(repo.currentPerformance.StartTime), nil
+}
+
+// SetPerformanceFlushInterval sets how often performance metadata is written
+// during commits; zero writes on every commit
+func (repo *LiveCodeRepository) SetPerformanceFlushInterval(interval time.Duration) {
+	repo.performanceFlushInterval = interval
+}
+
+// PerformanceFlushInterval returns how often performance metadata is written
+func (repo *LiveCodeRepository) PerformanceFlushInterval() time.Duration {
+	return repo.performanceFlushInterval
+}
+
 // Log returns the commit history with optional limit
 func (repo *LiveCodeRepository) Log(limit int) ([]*Commit, error) {
+	return repo.LogWithFilter(LogFilter{}, limit)
+}
+
+// LogWithFilter returns the most recent commits matching filter, using the
+// metadata kept in the index so only matching commits are read
+func (repo *LiveCodeRepository) LogWithFilter(filter LogFilter, limit int) ([]*Commit, error) {
+	return repo.LogPage(filter, 0, limit)
+}
+
+// LogPage returns a page of commits matching filter, most recent first,
+// after skipping the offset most recent ones. In a repository opened with
+// OpenRepository only the end of the index is read, as far back as the page.
+func (repo *LiveCodeRepository) LogPage(filter LogFilter, offset, limit int) ([]*Commit, error) {
 	if !repo.IsInitialized() {
 		return nil, fmt.Errorf("repository not initialized")
 	}
 
 	// Load index if not already loaded
 	if repo.index == nil {
-		repo.index = storage.NewIndex(repo.storage.(*storage.FileSystemStorage))
+		repo.index = storage.NewIndex(repo.storage)
 		if err := repo.index.LoadIndex(); err != nil {
 			return nil, fmt.Errorf("failed to load index: %w", err)
 		}
@@ -134,7 +426,10 @@ func (repo *LiveCodeRepository) Log(limit int) ([]*Commit, error) {
 		limit = 50 // Default limit
 	}
 
-	entries := repo.index.GetOrderedCommits(limit)
+	entries, err := repo.index.FilterCommits(filter, offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
 	commits := make([]*Commit, 0, len(entries))
 
 	for _, entry := range entries {
@@ -148,13 +443,120 @@ func (repo *LiveCodeRepository) Log(limit int) ([]*Commit, error) {
 	return commits, nil
 }
 
-// GetCommit retrieves a specific commit by hash
+// History returns every commit in the repository, oldest first
+func (repo *LiveCodeRepository) History() ([]*Commit, error) {
+	if !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	commits := make([]*Commit, 0, len(repo.index.AllEntries()))
+	for _, entry := range repo.index.AllEntries() {
+		commit, err := repo.storage.ReadCommit(entry.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit %s: %w", entry.Hash, err)
+		}
+		commits = append(commits, commit)
+	}
+
+	return commits, nil
+}
+
+// GetCommitsByBuffer returns every commit of a buffer, oldest first, e.g.
+// everything a Tidal connection such as d3 played
+func (repo *LiveCodeRepository) GetCommitsByBuffer(buffer string) ([]*Commit, error) {
+	if !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	var commits []*Commit
+	for _, entry := range repo.index.AllEntries() {
+		if entry.Buffer != buffer {
+			continue
+		}
+
+		commit, err := repo.storage.ReadCommit(entry.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit %s: %w", entry.Hash, err)
+		}
+		commits = append(commits, commit)
+	}
+
+	return commits, nil
+}
+
+// BufferLineage returns a commit and the commits of its buffer before it,
+// newest first, following BufferParent. Commits made before buffer parents
+// were recorded continue with the previous commit of the buffer in the index.
+func (repo *LiveCodeRepository) BufferLineage(hash string, limit int) ([]*Commit, error) {
+	if !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	var commits []*Commit
+	for hash != "" && (limit <= 0 || len(commits) < limit) {
+		commit, err := repo.storage.ReadCommit(hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit %s: %w", hash, err)
+		}
+		commits = append(commits, commit)
+
+		hash = commit.BufferParent
+		if hash == "" {
+			if position, ok := repo.index.FindEntry(commit.Hash); ok {
+				hash = repo.index.BufferHead(commit.Metadata.Buffer, position)
+			}
+		}
+	}
+
+	return commits, nil
+}
+
+// GetCommit retrieves a specific commit by full or unique abbreviated hash
 func (repo *LiveCodeRepository) GetCommit(hash string) (*Commit, error) {
 	if repo.storage == nil {
 		return nil, fmt.Errorf("repository not initialized")
 	}
 
-	return repo.storage.ReadCommit(hash)
+	full, err := storage.ResolveHash(repo.storage, hash)
+	if err != nil {
+		return nil, err
+	}
+	return repo.storage.ReadCommit(full)
+}
+
+// Tag names a commit so it can be found again, e.g. a drop worth keeping
+func (repo *LiveCodeRepository) Tag(name string, hash string) error {
+	if repo.storage == nil || !repo.IsInitialized() {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	if err := repo.checkWritable(); err != nil {
+		return err
+	}
+	full, err := storage.ResolveHash(repo.storage, hash)
+	if err != nil {
+		return err
+	}
+
+	return repo.storage.WriteTag(name, full)
+}
+
+// Tags returns every tag name with the commit hash it points at
+func (repo *LiveCodeRepository) Tags() (map[string]string, error) {
+	if repo.storage == nil || !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	return repo.storage.ReadTags()
+}
+
+// SnapshotIndex saves a copy of the index, e.g. during nightly maintenance
+func (repo *LiveCodeRepository) SnapshotIndex() error {
+	if !repo.IsInitialized() {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	return repo.index.SaveSnapshot()
 }
 
 // GetCurrentPerformance returns the active performance session
@@ -162,11 +564,28 @@ func (repo *LiveCodeRepository) GetCurrentPerformance() (*Performance, error) {
 	return repo.currentPerformance, nil
 }
 
+// ListPerformances returns all recorded performances ordered by start time
+func (repo *LiveCodeRepository) ListPerformances() ([]*Performance, error) {
+	if repo.storage == nil {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	// Include unflushed changes to the active performance
+	if err := repo.FlushPerformance(); err != nil {
+		return nil, fmt.Errorf("failed to flush performance: %w", err)
+	}
+
+	return repo.storage.ListPerformances()
+}
+
 // StartPerformance begins a new performance session
 func (repo *LiveCodeRepository) StartPerformance(name string) (*Performance, error) {
 	if repo.storage == nil {
 		return nil, fmt.Errorf("repository not initialized")
 	}
+	if err := repo.checkWritable(); err != nil {
+		return nil, err
+	}
 
 	// End current performance if active
 	if repo.currentPerformance != nil {
@@ -175,14 +594,19 @@ func (repo *LiveCodeRepository) StartPerformance(name string) (*Performance, err
 		}
 	}
 
+	user, err := repo.user()
+	if err != nil {
+		return nil, err
+	}
+
 	// Create new performance
 	performance := &Performance{
 		ID:          fmt.Sprintf("perf-%d", time.Now().Unix()),
 		Name:        name,
 		StartTime:   time.Now(),
 		CommitCount: 0,
-		Branch:      "main",      // TODO: Support branches
-		Author:      "livecoder", // TODO: Get from config
+		Branch:      "main", // TODO: Support branches
+		Author:      user.Name,
 	}
 
 	if err := repo.storage.WritePerformance(performance); err != nil {
@@ -190,24 +614,206 @@ func (repo *LiveCodeRepository) StartPerformance(name string) (*Performance, err
 	}
 
 	repo.currentPerformance = performance
+	repo.performanceDirty = false
+	repo.lastPerformanceFlush = time.Now()
 	return performance, nil
 }
 
-// EndPerformance concludes the current performance session
+// EndPerformance concludes the current performance session. One already
+// ended elsewhere keeps its stored end time.
 func (repo *LiveCodeRepository) EndPerformance() error {
 	if repo.currentPerformance == nil {
 		return fmt.Errorf("no active performance session")
 	}
 
+	unlock, err := repo.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	superseded, err := repo.performanceSuperseded()
+	if err != nil {
+		return fmt.Errorf("failed to read performance: %w", err)
+	}
+	if superseded {
+		repo.currentPerformance = nil
+		repo.performanceDirty = false
+		return nil
+	}
+
 	repo.currentPerformance.EndTime = time.Now()
 	if err := repo.storage.WritePerformance(repo.currentPerformance); err != nil {
 		return fmt.Errorf("failed to update performance end time: %w", err)
 	}
 
 	repo.currentPerformance = nil
+	repo.performanceDirty = false
 	return nil
 }
 
+// ImportPerformance records a finished performance spanning imported
+// commits, oldest first, without touching the active performance
+func (repo *LiveCodeRepository) ImportPerformance(name string, commits []*Commit) (*Performance, error) {
+	if repo.storage == nil {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("performance %s has no commits", name)
+	}
+
+	first, last := commits[0], commits[len(commits)-1]
+	performance := &Performance{
+		ID:        fmt.Sprintf("perf-%d", first.Timestamp.Unix()),
+		Name:      name,
+		StartTime: first.Timestamp,
+		EndTime:   last.Timestamp,
+		Branch:    "main",
+		Author:    first.Author,
+	}
+	for _, commit := range commits {
+		performance.RecordCommit(commit)
+	}
+
+	if err := repo.storage.WritePerformance(performance); err != nil {
+		return nil, fmt.Errorf("failed to write performance: %w", err)
+	}
+
+	return performance, nil
+}
+
+// GetPerformance finds a performance by ID, unique ID prefix or name
+func (repo *LiveCodeRepository) GetPerformance(ref string) (*Performance, error) {
+	performances, err := repo.ListPerformances()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*Performance
+	for _, performance := range performances {
+		if performance.ID == ref {
+			return performance, nil
+		}
+		if strings.HasPrefix(performance.ID, ref) || strings.EqualFold(performance.Name, ref) {
+			matches = append(matches, performance)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no performance matches %s", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("%s matches %d performances; use the full ID", ref, len(matches))
+	}
+}
+
+// Mark labels the current moment of the active performance
+func (repo *LiveCodeRepository) Mark(label string) (*Marker, error) {
+	return repo.addMarker(Marker{Label: label})
+}
+
+// MarkSnapshot labels the current moment of the active performance together
+// with the latest commit of every buffer, so the whole set can be found again
+func (repo *LiveCodeRepository) MarkSnapshot(label string) (*Marker, error) {
+	return repo.addMarker(Marker{Label: label, Buffers: repo.BufferHeads()})
+}
+
+// addMarker records marker at HEAD on the active performance and writes it
+func (repo *LiveCodeRepository) addMarker(marker Marker) (*Marker, error) {
+	if repo.currentPerformance == nil {
+		return nil, fmt.Errorf("no active performance session")
+	}
+	if err := repo.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	marker.Time = time.Now()
+	marker.Commit = repo.index.GetHead()
+	if marker.Label == "" {
+		kind := "marker"
+		if marker.Buffers != nil {
+			kind = "snapshot"
+		}
+		// Planned markers don't count; they were made by a template
+		count := 1
+		for _, existing := range repo.currentPerformance.Markers {
+			if !existing.Planned {
+				count++
+			}
+		}
+		marker.Label = fmt.Sprintf("%s %d", kind, count)
+	}
+
+	repo.currentPerformance.Markers = append(repo.currentPerformance.Markers, marker)
+	repo.performanceDirty = true
+	if err := repo.FlushPerformance(); err != nil {
+		return nil, fmt.Errorf("failed to write marker: %w", err)
+	}
+
+	return &marker, nil
+}
+
+// BufferHeads returns the latest commit of every buffer
+func (repo *LiveCodeRepository) BufferHeads() map[string]string {
+	heads := make(map[string]string)
+	for _, entry := range repo.index.AllEntries() {
+		heads[entry.Buffer] = entry.Hash
+	}
+	return heads
+}
+
+// PerformanceCommits returns the commits made during a performance, oldest
+// first. A performance that hasn't ended includes every commit since it began.
+func (repo *LiveCodeRepository) PerformanceCommits(performance *Performance) ([]*Commit, error) {
+	if !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	var commits []*Commit
+	for _, entry := range repo.index.GetEntriesSince(performance.StartTime) {
+		if !performance.EndTime.IsZero() && entry.Timestamp.After(performance.EndTime) {
+			continue
+		}
+
+		commit, err := repo.storage.ReadCommit(entry.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit %s: %w", entry.Hash, err)
+		}
+		commits = append(commits, commit)
+	}
+
+	return commits, nil
+}
+
+// Config returns the repository configuration, loading it on first use
+func (repo *LiveCodeRepository) Config() (*FileConfig, error) {
+	if repo.config == nil {
+		config, err := LoadConfig(repo.path)
+		if err != nil {
+			return nil, err
+		}
+		repo.config = config
+	}
+
+	return repo.config, nil
+}
+
+// user returns the configured commit identity, defaulting the name
+func (repo *LiveCodeRepository) user() (UserConfig, error) {
+	config, err := repo.Config()
+	if err != nil {
+		return UserConfig{}, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	user := config.Settings().User
+	if user.Name == "" {
+		user.Name = DefaultAuthor
+	}
+	return user, nil
+}
+
 // IsInitialized checks if the repository is properly initialized
 func (repo *LiveCodeRepository) IsInitialized() bool {
 	repoDir := filepath.Join(repo.path, storage.RepoDir)
@@ -215,6 +821,11 @@ func (repo *LiveCodeRepository) IsInitialized() bool {
 	return err == nil
 }
 
+// GetPath returns the directory containing the repository
+func (repo *LiveCodeRepository) GetPath() string {
+	return repo.path
+}
+
 // LoadRepository loads an existing repository from the given path
 func LoadRepository(path string) (*LiveCodeRepository, error) {
 	repo := NewRepository(path)
@@ -228,5 +839,72 @@ func LoadRepository(path string) (*LiveCodeRepository, error) {
 		return nil, fmt.Errorf("failed to load repository index: %w", err)
 	}
 
+	// Resume a performance that was started by an earlier process
+	if err := repo.restoreCurrentPerformance(); err != nil {
+		return nil, fmt.Errorf("failed to restore active performance: %w", err)
+	}
+
+	return repo, nil
+}
+
+// OpenRepository loads an existing repository like LoadRepository, but reads
+// only the header of its index: reading recent history, e.g. lcg log -n 5,
+// reads only the end of the index, and the whole of it is loaded when first
+// needed
+func OpenRepository(path string) (*LiveCodeRepository, error) {
+	repo := NewRepository(path)
+
+	if !repo.IsInitialized() {
+		return nil, fmt.Errorf("no repository found at %s", path)
+	}
+
+	if err := repo.index.OpenIndex(); err != nil {
+		return nil, fmt.Errorf("failed to load repository index: %w", err)
+	}
+
+	// Resume a performance that was started by an earlier process
+	if err := repo.restoreCurrentPerformance(); err != nil {
+		return nil, fmt.Errorf("failed to restore active performance: %w", err)
+	}
+
 	return repo, nil
 }
+
+// restoreCurrentPerformance marks the most recent performance without an end time as active
+func (repo *LiveCodeRepository) restoreCurrentPerformance() error {
+	performances, err := repo.storage.ListPerformances()
+	if err != nil {
+		return err
+	}
+
+	for i := len(performances) - 1; i >= 0; i-- {
+		performance := performances[i]
+		if !performance.EndTime.IsZero() {
+			continue
+		}
+
+		// Throttled writes may have been lost in a crash, so recount from
+		// the index, reading back only as far as the performance started
+		entries, err := repo.index.RecentEntriesSince(performance.StartTime)
+		if err != nil {
+			return err
+		}
+		if len(entries) != performance.CommitCount {
+			performance.CommitCount = 0
+			performance.Buffers = nil
+			for _, entry := range entries {
+				commit, err := repo.storage.ReadCommit(entry.Hash)
+				if err != nil {
+					return err
+				}
+				performance.RecordCommit(commit)
+			}
+			repo.performanceDirty = true
+		}
+
+		repo.currentPerformance = performance
+		return nil
+	}
+
+	return nil
+}
@@ -1,6 +1,8 @@
 package core
 
 import (
+	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -62,6 +64,40 @@ func TestInit(t *testing.T) {
 	}
 }
 
+func TestInitWithBackend(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.InitWithBackend(tempDir, "bolt"); err == nil {
+		t.Errorf("Expected an unknown backend to be rejected")
+	}
+	if err := repo.InitWithBackend(tempDir, storage.BackendKV); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	commit, err := repo.Commit("d1 $ s \"bd*4\"", "Kick", ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, storage.RepoDir, storage.KVFile)); err != nil {
+		t.Errorf("Expected the commit in the key-value file: %v", err)
+	}
+
+	loaded, err := LoadRepository(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to load repository: %v", err)
+	}
+	read, err := loaded.GetCommit(commit.Hash)
+	if err != nil || read.Content != commit.Content {
+		t.Errorf("Expected commit %s back, got %+v (%v)", commit.Hash, read, err)
+	}
+	result, err := loaded.Fsck(false)
+	if err != nil || len(result.Problems) != 0 || result.Objects != 1 {
+		t.Errorf("Expected a clean fsck of one object, got %+v (%v)", result, err)
+	}
+}
+
 func TestIsInitialized(t *testing.T) {
 	tempDir := createTempDir(t)
 	defer os.RemoveAll(tempDir)
@@ -219,6 +255,154 @@ func TestLog(t *testing.T) {
 	}
 }
 
+func TestLogWithFilter(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	err := repo.Init(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	commits := []struct {
+		message  string
+		metadata ExecutionMetadata
+	}{
+		{"Kick", ExecutionMetadata{Buffer: "drums", Language: "sonicpi", Success: true}},
+		{"Broken pattern", ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: false, ErrorMessage: "parse error"}},
+		{"Fixed pattern", ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}},
+	}
+
+	for _, c := range commits {
+		if _, err := repo.Commit("code", c.message, c.metadata); err != nil {
+			t.Fatalf("Failed to create commit '%s': %v", c.message, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	log, err := repo.LogWithFilter(LogFilter{Language: "tidal"}, 10)
+	if err != nil {
+		t.Fatalf("Failed to get filtered log: %v", err)
+	}
+	if len(log) != 2 || log[0].Message != "Fixed pattern" || log[1].Message != "Broken pattern" {
+		t.Errorf("Expected the two tidal commits, most recent first, got %d commits", len(log))
+	}
+
+	failed := false
+	log, err = repo.LogWithFilter(LogFilter{Success: &failed}, 10)
+	if err != nil {
+		t.Fatalf("Failed to get filtered log: %v", err)
+	}
+	if len(log) != 1 || log[0].Metadata.ErrorMessage != "parse error" {
+		t.Errorf("Expected only the failed commit, got %d commits", len(log))
+	}
+
+	log, err = repo.LogWithFilter(LogFilter{Buffer: "d1", Author: "nobody"}, 10)
+	if err != nil {
+		t.Fatalf("Failed to get filtered log: %v", err)
+	}
+	if len(log) != 0 {
+		t.Errorf("Expected no commits for unknown author, got %d", len(log))
+	}
+
+	history, err := repo.GetCommitsByBuffer("d1")
+	if err != nil {
+		t.Fatalf("Failed to get buffer history: %v", err)
+	}
+	if len(history) != 2 || history[0].Message != "Broken pattern" || history[1].Message != "Fixed pattern" {
+		t.Errorf("Expected both d1 commits, oldest first, got %d commits", len(history))
+	}
+
+	log, err = repo.LogWithFilter(LogFilter{Since: history[1].Timestamp}, 10)
+	if err != nil {
+		t.Fatalf("Failed to get filtered log: %v", err)
+	}
+	if len(log) != 1 || log[0].Message != "Fixed pattern" {
+		t.Errorf("Expected only the commit made since the fix, got %d commits", len(log))
+	}
+}
+
+func TestTag(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	commit, err := repo.Commit("d1 $ s \"bd\"", "Drop", ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	if err := repo.Tag("drop", commit.Hash); err != nil {
+		t.Fatalf("Failed to tag commit: %v", err)
+	}
+
+	if err := repo.Tag("missing", "0000000000000000000000000000000000000000"); err == nil {
+		t.Errorf("Expected error tagging an unknown commit")
+	}
+
+	tags, err := repo.Tags()
+	if err != nil {
+		t.Fatalf("Failed to read tags: %v", err)
+	}
+	if len(tags) != 1 || tags["drop"] != commit.Hash {
+		t.Errorf("Expected tag drop -> %s, got %v", commit.Hash, tags)
+	}
+}
+
+func TestBufferLineage(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	hashes := make(map[string]string)
+	for _, c := range []struct{ name, buffer string }{
+		{"drums 1", "drums"}, {"bass 1", "bass"}, {"drums 2", "drums"}, {"bass 2", "bass"}, {"drums 3", "drums"},
+	} {
+		commit, err := repo.Commit(c.name, c.name, ExecutionMetadata{Buffer: c.buffer, Success: true})
+		if err != nil {
+			t.Fatalf("Failed to create commit '%s': %v", c.name, err)
+		}
+		hashes[c.name] = commit.Hash
+	}
+
+	bass, err := repo.GetCommit(hashes["bass 2"])
+	if err != nil {
+		t.Fatalf("Failed to read commit: %v", err)
+	}
+	if bass.Parent != hashes["drums 2"] || bass.BufferParent != hashes["bass 1"] {
+		t.Errorf("Expected bass 2 after drums 2 in the timeline and bass 1 in its buffer")
+	}
+
+	lineage, err := repo.BufferLineage(hashes["drums 3"], 0)
+	if err != nil {
+		t.Fatalf("Failed to follow lineage: %v", err)
+	}
+	if len(lineage) != 3 || lineage[0].Content != "drums 3" || lineage[1].Content != "drums 2" || lineage[2].Content != "drums 1" {
+		t.Errorf("Expected the three drums commits, newest first, got %d commits", len(lineage))
+	}
+
+	// Amending into another buffer takes that buffer's head as its parent
+	amended, err := repo.Amend("bass 3", "bass 3", ExecutionMetadata{Buffer: "bass", Success: true})
+	if err != nil {
+		t.Fatalf("Failed to amend: %v", err)
+	}
+	if amended.BufferParent != hashes["bass 2"] {
+		t.Errorf("Expected the amended commit to follow bass 2 in its buffer")
+	}
+	if lineage, _ := repo.BufferLineage(amended.Hash, 2); len(lineage) != 2 || lineage[1].Content != "bass 2" {
+		t.Errorf("Expected a limited bass lineage, got %d commits", len(lineage))
+	}
+}
+
 func TestLogWithoutInit(t *testing.T) {
 	tempDir := createTempDir(t)
 	defer os.RemoveAll(tempDir)
@@ -303,6 +487,180 @@ func TestStartAndEndPerformance(t *testing.T) {
 	}
 }
 
+func TestPerformanceWritesAreThrottled(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	err := repo.Init(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	repo.SetPerformanceFlushInterval(time.Hour)
+
+	performance, err := repo.StartPerformance("Dense Set")
+	if err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+
+	metadata := ExecutionMetadata{
+		Buffer:   "main",
+		Language: "tidal",
+		Success:  true,
+	}
+
+	var last *Commit
+	for i := 0; i < 3; i++ {
+		last, err = repo.Commit("d1 $ sound \"bd\"", "beat", metadata)
+		if err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+
+	// Nothing written yet within the flush interval
+	stored, err := repo.storage.ReadPerformance(performance.ID)
+	if err != nil {
+		t.Fatalf("Failed to read performance: %v", err)
+	}
+
+	if stored.CommitCount != 0 {
+		t.Errorf("Expected stored commit count 0 before flush, got %d", stored.CommitCount)
+	}
+
+	// A crashed process never flushed; reloading recovers the count from the index
+	reloaded, err := LoadRepository(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to load repository: %v", err)
+	}
+
+	current, _ := reloaded.GetCurrentPerformance()
+	if current == nil || current.CommitCount != 3 {
+		t.Fatalf("Expected recovered commit count 3, got %+v", current)
+	}
+
+	if current.HeadCommit != last.Hash {
+		t.Errorf("Expected recovered head '%s', got '%s'", last.Hash, current.HeadCommit)
+	}
+
+	if stats := current.Buffers["main"]; stats == nil || stats.CommitCount != 3 {
+		t.Errorf("Expected recovered buffer stats with 3 commits, got %+v", stats)
+	}
+
+	// Ending the performance always writes
+	if err := repo.EndPerformance(); err != nil {
+		t.Fatalf("Failed to end performance: %v", err)
+	}
+
+	stored, err = repo.storage.ReadPerformance(performance.ID)
+	if err != nil {
+		t.Fatalf("Failed to read performance: %v", err)
+	}
+
+	if stored.CommitCount != 3 {
+		t.Errorf("Expected stored commit count 3 after end, got %d", stored.CommitCount)
+	}
+}
+
+func TestPerformanceEndedElsewhereStaysEnded(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	// A watcher holds the performance while 'lcg performance end' runs
+	watcher := NewRepository(tempDir)
+	if err := watcher.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	performance, err := watcher.StartPerformance("Long Set")
+	if err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+	metadata := ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}
+	if _, err := watcher.Commit("d1 $ s \"bd\"", "Kick", metadata); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	cli, err := LoadRepository(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to load repository: %v", err)
+	}
+	if err := cli.EndPerformance(); err != nil {
+		t.Fatalf("Failed to end performance: %v", err)
+	}
+	ended, err := cli.storage.ReadPerformance(performance.ID)
+	if err != nil || ended.EndTime.IsZero() {
+		t.Fatalf("Expected the performance ended, got %+v (%v)", ended, err)
+	}
+
+	// Neither a commit nor a timed flush brings it back
+	if _, err := watcher.Commit("d1 $ s \"bd sn\"", "Snare", metadata); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if err := watcher.FlushPerformance(); err != nil {
+		t.Fatalf("Failed to flush performance: %v", err)
+	}
+	stored, err := watcher.storage.ReadPerformance(performance.ID)
+	if err != nil || !stored.EndTime.Equal(ended.EndTime) {
+		t.Errorf("Expected the stored end time kept, got %+v (%v)", stored, err)
+	}
+	if current, _ := watcher.GetCurrentPerformance(); current != nil {
+		t.Errorf("Expected the ended performance no longer active, got %s", current.ID)
+	}
+	if err := watcher.EndPerformance(); err == nil {
+		t.Errorf("Expected no performance left to end")
+	}
+}
+
+func TestPerformanceBufferStats(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	err := repo.Init(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	if _, err := repo.StartPerformance("Algorave"); err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+
+	commits := []ExecutionMetadata{
+		{Buffer: "drums", Language: "sonicpi", Success: true},
+		{Buffer: "bass", Language: "sonicpi", Success: true},
+		{Buffer: "drums", Language: "sonicpi", Success: false, ErrorMessage: "syntax error"},
+		{Buffer: "drums", Language: "sonicpi", Success: true},
+	}
+
+	for _, metadata := range commits {
+		if _, err := repo.Commit("code", "exec", metadata); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+
+	current, _ := repo.GetCurrentPerformance()
+
+	drums := current.Buffers["drums"]
+	if drums == nil {
+		t.Fatalf("Expected stats for buffer 'drums'")
+	}
+
+	if drums.CommitCount != 3 {
+		t.Errorf("Expected 3 drums commits, got %d", drums.CommitCount)
+	}
+
+	if drums.ErrorCount != 1 {
+		t.Errorf("Expected 1 drums error, got %d", drums.ErrorCount)
+	}
+
+	if drums.FirstActivity.After(drums.LastActivity) {
+		t.Errorf("Expected first activity before last activity")
+	}
+
+	if bass := current.Buffers["bass"]; bass == nil || bass.CommitCount != 1 {
+		t.Errorf("Expected 1 bass commit, got %+v", bass)
+	}
+}
+
 func TestEndPerformanceWithoutStart(t *testing.T) {
 	tempDir := createTempDir(t)
 	defer os.RemoveAll(tempDir)
@@ -319,6 +677,197 @@ func TestEndPerformanceWithoutStart(t *testing.T) {
 	}
 }
 
+func TestGetPerformanceAndCommits(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	metadata := ExecutionMetadata{Buffer: "main", Language: "tidal", Success: true}
+	if _, err := repo.Commit("d1 $ s \"bd\"", "Before", metadata); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	performance, err := repo.StartPerformance("Algorave")
+	if err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+	for _, message := range []string{"Kick", "Hats"} {
+		if _, err := repo.Commit("d1 $ s \"bd hh\"", message, metadata); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+	if err := repo.EndPerformance(); err != nil {
+		t.Fatalf("Failed to end performance: %v", err)
+	}
+	if _, err := repo.Commit("hush", "After", metadata); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	for _, ref := range []string{performance.ID, "algorave", performance.ID[:len(performance.ID)-2]} {
+		found, err := repo.GetPerformance(ref)
+		if err != nil {
+			t.Fatalf("Failed to find performance by '%s': %v", ref, err)
+		}
+		if found.ID != performance.ID {
+			t.Errorf("Expected performance %s for '%s', got %s", performance.ID, ref, found.ID)
+		}
+	}
+
+	if _, err := repo.GetPerformance("Soundcheck"); err == nil {
+		t.Errorf("Expected error for unknown performance")
+	}
+
+	found, _ := repo.GetPerformance(performance.ID)
+	commits, err := repo.PerformanceCommits(found)
+	if err != nil {
+		t.Fatalf("Failed to get performance commits: %v", err)
+	}
+	if len(commits) != 2 || commits[0].Message != "Kick" || commits[1].Message != "Hats" {
+		t.Fatalf("Expected commits Kick and Hats, got %d commits", len(commits))
+	}
+}
+
+func TestImportCommitAndPerformance(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	start := time.Date(2019, 3, 2, 23, 0, 0, 0, time.UTC)
+	commits := []*Commit{
+		{Timestamp: start, Message: "Kick", Author: "alice", Content: "d1 $ s \"bd\"",
+			Metadata: ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}},
+		{Timestamp: start.Add(time.Minute), Message: "Lead", Author: "alice", Content: "play 60",
+			Metadata: ExecutionMetadata{Buffer: "lead", Language: "sonicpi", Success: false}},
+	}
+	for _, commit := range commits {
+		if err := repo.ImportCommit(commit); err != nil {
+			t.Fatalf("Failed to import commit: %v", err)
+		}
+	}
+
+	if commits[0].Hash == "" || commits[1].Parent != commits[0].Hash {
+		t.Errorf("Expected imported commits to be chained, got parent '%s'", commits[1].Parent)
+	}
+
+	stored, err := repo.GetCommit(commits[1].Hash)
+	if err != nil {
+		t.Fatalf("Failed to read imported commit: %v", err)
+	}
+	if !stored.Timestamp.Equal(commits[1].Timestamp) || stored.Author != "alice" {
+		t.Errorf("Expected original time and author, got %v by %s", stored.Timestamp, stored.Author)
+	}
+
+	performance, err := repo.ImportPerformance("Club night", commits)
+	if err != nil {
+		t.Fatalf("Failed to import performance: %v", err)
+	}
+	if !performance.StartTime.Equal(start) || !performance.EndTime.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected performance to span the commits, got %v to %v", performance.StartTime, performance.EndTime)
+	}
+	if performance.CommitCount != 2 || performance.Buffers["lead"].ErrorCount != 1 {
+		t.Errorf("Expected 2 commits and 1 error, got %d commits", performance.CommitCount)
+	}
+
+	found, err := repo.GetPerformance("club night")
+	if err != nil {
+		t.Fatalf("Failed to find imported performance: %v", err)
+	}
+	imported, err := repo.PerformanceCommits(found)
+	if err != nil || len(imported) != 2 {
+		t.Errorf("Expected 2 performance commits, got %d (%v)", len(imported), err)
+	}
+
+	if _, err := repo.ImportPerformance("Empty", nil); err == nil {
+		t.Errorf("Expected error for a performance without commits")
+	}
+}
+
+func TestAmend(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	if _, err := repo.Amend("hush", "Nothing", ExecutionMetadata{}); err == nil {
+		t.Errorf("Expected amending an empty repository to fail")
+	}
+
+	if _, err := repo.StartPerformance("Set"); err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+	metadata := ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: false}
+	first, err := repo.Commit("d1 $ s \"bd\"", "Kick", metadata)
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	last, err := repo.Commit("d1 $ s \"bd sn\"", "Snrae", metadata)
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	metadata.Buffer, metadata.Success = "d2", true
+	amended, err := repo.Amend(last.Content, "Snare", metadata)
+	if err != nil {
+		t.Fatalf("Failed to amend: %v", err)
+	}
+	if amended.Hash == last.Hash || amended.Parent != first.Hash || !amended.Timestamp.Equal(last.Timestamp) {
+		t.Errorf("Expected a new commit on the same parent at the same time, got %+v", amended)
+	}
+
+	fsStorage := repo.storage.(*storage.FileSystemStorage)
+	if fsStorage.Exists(last.Hash) {
+		t.Errorf("Expected the replaced commit to be deleted")
+	}
+	if head, _ := fsStorage.ReadHead(); head != amended.Hash {
+		t.Errorf("Expected HEAD to move to the amended commit, got %s", head)
+	}
+
+	reloaded, err := LoadRepository(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to load repository: %v", err)
+	}
+	log, err := reloaded.Log(10)
+	if err != nil || len(log) != 2 || log[0].Message != "Snare" {
+		t.Fatalf("Expected the amended commit on top of 2 commits, got %d (%v)", len(log), err)
+	}
+	if results, _ := reloaded.Search("snrae", SearchOptions{}); len(results) != 0 {
+		t.Errorf("Expected the old message to be gone from the search index")
+	}
+
+	performance, _ := reloaded.GetCurrentPerformance()
+	if performance.CommitCount != 2 || performance.HeadCommit != amended.Hash {
+		t.Errorf("Expected 2 commits with the amended head, got %d at %s", performance.CommitCount, performance.HeadCommit)
+	}
+	if d1, d2 := performance.Buffers["d1"], performance.Buffers["d2"]; d1.CommitCount != 1 || d1.ErrorCount != 1 || d2.CommitCount != 1 || d2.ErrorCount != 0 {
+		t.Errorf("Expected the amended commit moved to d2, got d1 %+v and d2 %+v", d1, d2)
+	}
+
+	// A tagged commit is kept when amended
+	if err := reloaded.Tag("take-one", amended.Hash); err != nil {
+		t.Fatalf("Failed to tag: %v", err)
+	}
+	if _, err := reloaded.Amend("hush", "Silence", metadata); err != nil {
+		t.Fatalf("Failed to amend: %v", err)
+	}
+	if !fsStorage.Exists(amended.Hash) {
+		t.Errorf("Expected the tagged commit to be kept")
+	}
+	if result, err := reloaded.Fsck(false); err != nil || len(result.Problems) != 0 {
+		t.Errorf("Expected a consistent repository after amending, got %+v (%v)", result, err)
+	}
+}
+
 func TestLoadRepository(t *testing.T) {
 	tempDir := createTempDir(t)
 	defer os.RemoveAll(tempDir)
@@ -359,6 +908,51 @@ func TestLoadRepository(t *testing.T) {
 	}
 }
 
+func TestLoadRepositoryRestoresPerformance(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo1 := NewRepository(tempDir)
+	err := repo1.Init(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	performance, err := repo1.StartPerformance("Club Set")
+	if err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+
+	// A second process should pick up the running performance
+	repo2, err := LoadRepository(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to load repository: %v", err)
+	}
+
+	current, _ := repo2.GetCurrentPerformance()
+	if current == nil {
+		t.Fatalf("Expected active performance to be restored")
+	}
+
+	if current.ID != performance.ID {
+		t.Errorf("Expected performance ID '%s', got '%s'", performance.ID, current.ID)
+	}
+
+	// Once ended, it should no longer be restored
+	if err := repo2.EndPerformance(); err != nil {
+		t.Fatalf("Failed to end performance: %v", err)
+	}
+
+	repo3, err := LoadRepository(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to load repository: %v", err)
+	}
+
+	if current, _ := repo3.GetCurrentPerformance(); current != nil {
+		t.Errorf("Expected no active performance after end, got %s", current.ID)
+	}
+}
+
 func TestLoadNonExistentRepository(t *testing.T) {
 	tempDir := createTempDir(t)
 	defer os.RemoveAll(tempDir)
@@ -368,3 +962,152 @@ func TestLoadNonExistentRepository(t *testing.T) {
 		t.Errorf("Expected error when loading non-existent repository")
 	}
 }
+
+func TestLoadRepositoryRecoversDamagedIndex(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	metadata := ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}
+	for i := 0; i < 4; i++ {
+		if _, err := repo.Commit(fmt.Sprintf("d1 $ s \"bd*%d\"", i+1), fmt.Sprintf("Drop %d", i+1), metadata); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+
+	// Damage the message length of the first record, making it look longer
+	// than the rest of the file
+	indexPath := filepath.Join(tempDir, storage.RepoDir, storage.IndexFile)
+	corruptPath := filepath.Join(tempDir, storage.RepoDir, storage.IndexCorruptFile)
+	damaged, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatalf("Failed to read index: %v", err)
+	}
+	damaged[8+17+4+1] ^= 0x40
+
+	for name, open := range map[string]func(string) (*LiveCodeRepository, error){
+		"LoadRepository": LoadRepository,
+		"OpenRepository": OpenRepository,
+	} {
+		os.Remove(corruptPath)
+		if err := os.WriteFile(indexPath, damaged, 0644); err != nil {
+			t.Fatalf("Failed to write index: %v", err)
+		}
+
+		opened, err := open(tempDir)
+		if err != nil {
+			t.Fatalf("%s: failed to open repository: %v", name, err)
+		}
+		commits, err := opened.Log(10)
+		if err != nil {
+			t.Fatalf("%s: failed to read log: %v", name, err)
+		}
+		if len(commits) != 4 || commits[0].Message != "Drop 4" || commits[3].Message != "Drop 1" {
+			t.Errorf("%s: expected every commit after recovery, got %d", name, len(commits))
+		}
+		if kept, err := os.ReadFile(corruptPath); err != nil || !bytes.Equal(kept, damaged) {
+			t.Errorf("%s: expected the damaged index kept as %s (%v)", name, storage.IndexCorruptFile, err)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	err := repo.Init(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	commits := []struct {
+		content  string
+		message  string
+		metadata ExecutionMetadata
+	}{
+		{"live_loop :bass do\n  use_synth :tb303\n  play :e1\nend", "Acid bass", ExecutionMetadata{Buffer: "bass", Language: "sonicpi", Success: true}},
+		{"live_loop :drums do\n  sample :bd_haus\nend", "Kick", ExecutionMetadata{Buffer: "drums", Language: "sonicpi", Success: true}},
+		{"d1 $ s \"superpiano\"", "TB303 homage", ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}},
+	}
+
+	for _, c := range commits {
+		if _, err := repo.Commit(c.content, c.message, c.metadata); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Matches content and message, most recent first
+	results, err := repo.Search("tb303", SearchOptions{ContextLines: 1})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	if !results[0].MessageMatch || results[0].Commit.Message != "TB303 homage" {
+		t.Errorf("Expected first result to be the message match, got '%s'", results[0].Commit.Message)
+	}
+
+	lines := results[1].Lines
+	if len(lines) != 3 {
+		t.Fatalf("Expected match plus 2 context lines, got %d", len(lines))
+	}
+
+	if lines[1].Number != 2 || !lines[1].Match || lines[0].Match {
+		t.Errorf("Expected line 2 to be the match with context around it, got %+v", lines)
+	}
+
+	// Filters narrow the results
+	results, err = repo.Search("tb303", SearchOptions{Language: "tidal"})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+
+	if len(results) != 1 || results[0].Commit.Metadata.Buffer != "d1" {
+		t.Errorf("Expected only the tidal commit, got %d results", len(results))
+	}
+
+	results, err = repo.Search("live_loop", SearchOptions{Buffer: "drums"})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+
+	if len(results) != 1 || results[0].Commit.Message != "Kick" {
+		t.Errorf("Expected only the drums commit, got %d results", len(results))
+	}
+
+	results, err = repo.Search("tb303", SearchOptions{Since: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+
+	if len(results) != 0 {
+		t.Errorf("Expected no results in the future, got %d", len(results))
+	}
+
+	// The search index is rebuilt when missing
+	if err := os.Remove(filepath.Join(tempDir, storage.RepoDir, storage.SearchIndexFile)); err != nil {
+		t.Fatalf("Failed to remove search index: %v", err)
+	}
+
+	reloaded, err := LoadRepository(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to load repository: %v", err)
+	}
+
+	results, err = reloaded.Search("bd_haus", SearchOptions{})
+	if err != nil {
+		t.Fatalf("Failed to search after index removal: %v", err)
+	}
+
+	if len(results) != 1 {
+		t.Errorf("Expected 1 result after rebuild, got %d", len(results))
+	}
+}
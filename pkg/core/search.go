@@ -0,0 +1,198 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+// SearchOptions narrows down which commits Search considers
+type SearchOptions struct {
+	Language     string
+	Buffer       string
+	Since        time.Time
+	Until        time.Time
+	ContextLines int
+	Limit        int
+}
+
+// SearchLine is a line of commit content shown in a search result
+type SearchLine struct {
+	Number int    `json:"number"`
+	Text   string `json:"text"`
+	Match  bool   `json:"match"`
+}
+
+// SearchResult is a commit matching a search query
+type SearchResult struct {
+	Commit       *Commit      `json:"commit"`
+	MessageMatch bool         `json:"message_match"`
+	Lines        []SearchLine `json:"lines,omitempty"`
+}
+
+// Search finds commits whose content or message contains the query, ignoring
+// case. Results are ordered most recent first.
+func (repo *LiveCodeRepository) Search(query string, opts SearchOptions) ([]*SearchResult, error) {
+	if !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	if query == "" {
+		return nil, fmt.Errorf("search query cannot be empty")
+	}
+
+	searchIndex, err := repo.loadSearchIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := searchIndex.Candidates(query)
+	needle := strings.ToLower(query)
+	results := make([]*SearchResult, 0)
+
+	entries := repo.index.AllEntries()
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+
+		if !candidates[entry.Hash] {
+			continue
+		}
+		if !opts.Since.IsZero() && entry.Timestamp.Before(opts.Since) {
+			continue
+		}
+		if !opts.Until.IsZero() && entry.Timestamp.After(opts.Until) {
+			continue
+		}
+
+		commit, err := repo.storage.ReadCommit(entry.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit %s: %w", entry.Hash, err)
+		}
+
+		if opts.Language != "" && commit.Metadata.Language != opts.Language {
+			continue
+		}
+		if opts.Buffer != "" && commit.Metadata.Buffer != opts.Buffer {
+			continue
+		}
+
+		result := &SearchResult{
+			Commit:       commit,
+			MessageMatch: strings.Contains(strings.ToLower(commit.Message), needle),
+			Lines:        matchLines(commit.Content, needle, opts.ContextLines),
+		}
+
+		if !result.MessageMatch && len(result.Lines) == 0 {
+			continue
+		}
+
+		results = append(results, result)
+		if opts.Limit > 0 && len(results) >= opts.Limit {
+			break
+		}
+	}
+
+	return results, nil
+}
+
+// loadSearchIndex loads the search index, rebuilding it if it misses commits
+func (repo *LiveCodeRepository) loadSearchIndex() (*storage.SearchIndex, error) {
+	if repo.searchIndex != nil {
+		return repo.searchIndex, nil
+	}
+
+	searchIndex := storage.NewSearchIndex(repo.storage)
+	if err := searchIndex.LoadSearchIndex(); err != nil {
+		return nil, fmt.Errorf("failed to load search index: %w", err)
+	}
+
+	hashes := make([]string, len(repo.index.AllEntries()))
+	for i, entry := range repo.index.AllEntries() {
+		hashes[i] = entry.Hash
+	}
+
+	if !searchIndex.Covers(hashes) {
+		if err := searchIndex.RebuildSearchIndex(); err != nil {
+			return nil, fmt.Errorf("failed to rebuild search index: %w", err)
+		}
+	}
+
+	repo.searchIndex = searchIndex
+	return searchIndex, nil
+}
+
+// searchIndexWriter returns the search index to add commits to. Until a
+// search loads it, commits are appended to the file without reading it.
+func (repo *LiveCodeRepository) searchIndexWriter() *storage.SearchIndex {
+	if repo.searchIndex != nil {
+		return repo.searchIndex
+	}
+	return storage.NewSearchIndex(repo.storage)
+}
+
+// matchLines returns the lines containing needle plus surrounding context lines
+func matchLines(content, needle string, context int) []SearchLine {
+	lines := strings.Split(content, "\n")
+
+	include := make([]bool, len(lines))
+	matched := make([]bool, len(lines))
+	for i, line := range lines {
+		if !strings.Contains(strings.ToLower(line), needle) {
+			continue
+		}
+		matched[i] = true
+		for j := i - context; j <= i+context; j++ {
+			if j >= 0 && j < len(lines) {
+				include[j] = true
+			}
+		}
+	}
+
+	result := make([]SearchLine, 0)
+	for i, line := range lines {
+		if include[i] {
+			result = append(result, SearchLine{Number: i + 1, Text: line, Match: matched[i]})
+		}
+	}
+
+	return result
+}
+
+// GrepOptions narrows down which commits Grep reads
+type GrepOptions struct {
+	Buffer string
+	Since  time.Time
+}
+
+// Grep matches a regular expression against each line of commit content,
+// most recent commit first, calling fn for each matching line; fn returns
+// false to skip the rest of a commit. Commits are selected from the index,
+// so those filtered out are never read.
+func (repo *LiveCodeRepository) Grep(pattern *regexp.Regexp, opts GrepOptions, fn func(GrepMatch) bool) error {
+	if !repo.IsInitialized() {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	fsStorage, ok := repo.storage.(*storage.FileSystemStorage)
+	if !ok {
+		return fmt.Errorf("grep requires filesystem storage")
+	}
+
+	hashes := make([]string, 0)
+	entries := repo.index.AllEntries()
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if !opts.Since.IsZero() && entry.Timestamp.Before(opts.Since) {
+			continue
+		}
+		if opts.Buffer != "" && entry.Buffer != opts.Buffer {
+			continue
+		}
+		hashes = append(hashes, entry.Hash)
+	}
+
+	return fsStorage.Grep(hashes, pattern, fn)
+}
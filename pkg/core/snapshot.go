@@ -0,0 +1,271 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+// SnapshotsDir holds the session snapshots of a repository, one file per
+// snapshot named by its hash
+const SnapshotsDir = "snapshots"
+
+// Snapshot is the state of the whole set at once: like a git tree, it lists
+// every known buffer with the commit and code it was at. Its hash only
+// covers the buffers, so taking a snapshot of an unchanged set finds the
+// earlier one.
+type Snapshot struct {
+	Hash    string          `json:"hash"`
+	Time    time.Time       `json:"time"`
+	Commit  string          `json:"commit"`  // HEAD when taken
+	Entries []SnapshotEntry `json:"entries"` // sorted by buffer
+}
+
+// SnapshotEntry is one buffer of a snapshot
+type SnapshotEntry struct {
+	Buffer   string `json:"buffer"`
+	Language string `json:"language"`
+	Commit   string `json:"commit"`
+	Content  string `json:"content"`
+}
+
+// Snapshot change kinds
+const (
+	SnapshotAdded    = "added"
+	SnapshotRemoved  = "removed"
+	SnapshotModified = "modified"
+)
+
+// SnapshotChange is a buffer that differs between two snapshots. From is nil
+// for an added buffer and To for a removed one.
+type SnapshotChange struct {
+	Buffer string
+	Kind   string
+	From   *SnapshotEntry
+	To     *SnapshotEntry
+}
+
+// Entry returns the snapshot's entry for a buffer, or nil
+func (s *Snapshot) Entry(buffer string) *SnapshotEntry {
+	for i := range s.Entries {
+		if s.Entries[i].Buffer == buffer {
+			return &s.Entries[i]
+		}
+	}
+	return nil
+}
+
+// CurrentSnapshot captures the latest code of every buffer without saving it
+func (repo *LiveCodeRepository) CurrentSnapshot() (*Snapshot, error) {
+	if !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+	if len(repo.index.AllEntries()) == 0 {
+		return nil, fmt.Errorf("no commits to snapshot")
+	}
+
+	heads := repo.BufferHeads()
+	buffers := make([]string, 0, len(heads))
+	for buffer := range heads {
+		buffers = append(buffers, buffer)
+	}
+	sort.Strings(buffers)
+
+	snapshot := &Snapshot{
+		Time:    time.Now(),
+		Commit:  repo.index.GetHead(),
+		Entries: make([]SnapshotEntry, 0, len(buffers)),
+	}
+	for _, buffer := range buffers {
+		commit, err := repo.storage.ReadCommit(heads[buffer])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit %s: %w", heads[buffer], err)
+		}
+		snapshot.Entries = append(snapshot.Entries, SnapshotEntry{
+			Buffer:   buffer,
+			Language: commit.Metadata.Language,
+			Commit:   commit.Hash,
+			Content:  commit.Content,
+		})
+	}
+	snapshot.Hash = snapshotHash(snapshot.Entries)
+
+	return snapshot, nil
+}
+
+// snapshotHash names a snapshot after its buffers and their commits
+func snapshotHash(entries []SnapshotEntry) string {
+	var tree strings.Builder
+	for _, entry := range entries {
+		fmt.Fprintf(&tree, "%s %s\x00%s\n", entry.Commit, entry.Language, entry.Buffer)
+	}
+	return storage.GenerateHash(tree.String())
+}
+
+// Snapshot saves the latest code of every buffer as one snapshot. When the
+// same state was saved before, the earlier snapshot is returned.
+func (repo *LiveCodeRepository) Snapshot() (*Snapshot, error) {
+	if err := repo.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	snapshot, err := repo.CurrentSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	if existing, err := repo.readSnapshot(snapshot.Hash); err == nil {
+		return existing, nil
+	}
+	if err := repo.writeSnapshot(snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Snapshots returns the saved snapshots, oldest first
+func (repo *LiveCodeRepository) Snapshots() ([]*Snapshot, error) {
+	if !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	files, err := os.ReadDir(repo.snapshotsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*Snapshot{}, nil
+		}
+		return nil, fmt.Errorf("failed to read snapshots directory: %w", err)
+	}
+
+	snapshots := make([]*Snapshot, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() || !isFullHash(file.Name()) {
+			continue
+		}
+		snapshot, err := repo.readSnapshot(file.Name())
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+	return snapshots, nil
+}
+
+// GetSnapshot finds a snapshot by its hash or a unique prefix of it; an
+// empty ref finds the latest
+func (repo *LiveCodeRepository) GetSnapshot(ref string) (*Snapshot, error) {
+	snapshots, err := repo.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no snapshots saved")
+	}
+
+	if ref == "" {
+		return snapshots[len(snapshots)-1], nil
+	}
+	var found *Snapshot
+	for _, snapshot := range snapshots {
+		if !strings.HasPrefix(snapshot.Hash, ref) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("snapshot %s is ambiguous", ref)
+		}
+		found = snapshot
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no snapshot %s", ref)
+	}
+	return found, nil
+}
+
+// SnapshotCommits returns the commit of each buffer of a snapshot, by
+// buffer name
+func (repo *LiveCodeRepository) SnapshotCommits(snapshot *Snapshot) ([]*Commit, error) {
+	commits := make([]*Commit, 0, len(snapshot.Entries))
+	for _, entry := range snapshot.Entries {
+		commit, err := repo.storage.ReadCommit(entry.Commit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit %s: %w", entry.Commit, err)
+		}
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+// DiffSnapshots lists the buffers added, removed or changed from one
+// snapshot to another, by buffer name
+func DiffSnapshots(from, to *Snapshot) []SnapshotChange {
+	buffers := make(map[string]bool)
+	for _, entry := range from.Entries {
+		buffers[entry.Buffer] = true
+	}
+	for _, entry := range to.Entries {
+		buffers[entry.Buffer] = true
+	}
+	names := make([]string, 0, len(buffers))
+	for buffer := range buffers {
+		names = append(names, buffer)
+	}
+	sort.Strings(names)
+
+	var changes []SnapshotChange
+	for _, buffer := range names {
+		before, after := from.Entry(buffer), to.Entry(buffer)
+		switch {
+		case before == nil:
+			changes = append(changes, SnapshotChange{Buffer: buffer, Kind: SnapshotAdded, To: after})
+		case after == nil:
+			changes = append(changes, SnapshotChange{Buffer: buffer, Kind: SnapshotRemoved, From: before})
+		case before.Content != after.Content || before.Language != after.Language:
+			changes = append(changes, SnapshotChange{Buffer: buffer, Kind: SnapshotModified, From: before, To: after})
+		}
+	}
+	return changes
+}
+
+// readSnapshot reads a saved snapshot by its full hash
+func (repo *LiveCodeRepository) readSnapshot(hash string) (*Snapshot, error) {
+	data, err := os.ReadFile(filepath.Join(repo.snapshotsPath(), hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", hash, err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", hash, err)
+	}
+	return &snapshot, nil
+}
+
+// writeSnapshot saves a snapshot under its hash
+func (repo *LiveCodeRepository) writeSnapshot(snapshot *Snapshot) error {
+	if err := os.MkdirAll(repo.snapshotsPath(), 0755); err != nil {
+		return fmt.Errorf("failed to create snapshots directory: %w", err)
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo.snapshotsPath(), snapshot.Hash), data, 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// snapshotsPath returns the location of the snapshots directory
+func (repo *LiveCodeRepository) snapshotsPath() string {
+	return filepath.Join(repo.path, storage.RepoDir, SnapshotsDir)
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSnapshots(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	if _, err := repo.Snapshot(); err == nil {
+		t.Errorf("Expected a snapshot without commits to fail")
+	}
+
+	kick, err := repo.Commit("d1 $ s \"bd*4\"", "Kick", ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	hats, err := repo.Commit("d2 $ s \"hh*8\"", "Hats", ExecutionMetadata{Buffer: "d2", Language: "tidal", Success: true})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	first, err := repo.Snapshot()
+	if err != nil {
+		t.Fatalf("Failed to take snapshot: %v", err)
+	}
+	if first.Commit != hats.Hash || len(first.Entries) != 2 ||
+		first.Entry("d1").Commit != kick.Hash || first.Entry("d2").Content != hats.Content {
+		t.Errorf("Expected a snapshot of both buffers at HEAD %s, got %+v", hats.Hash, first)
+	}
+
+	// The same state is the same snapshot
+	again, err := repo.Snapshot()
+	if err != nil {
+		t.Fatalf("Failed to take snapshot: %v", err)
+	}
+	if again.Hash != first.Hash || !again.Time.Equal(first.Time) {
+		t.Errorf("Expected the unchanged set to find snapshot %s, got %s", first.Hash, again.Hash)
+	}
+
+	faster, err := repo.Commit("d2 $ s \"hh*16\"", "Faster hats", ExecutionMetadata{Buffer: "d2", Language: "tidal", Success: true})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if _, err := repo.Commit("d3 $ s \"arpy\"", "Arp", ExecutionMetadata{Buffer: "d3", Language: "tidal", Success: true}); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	second, err := repo.Snapshot()
+	if err != nil {
+		t.Fatalf("Failed to take snapshot: %v", err)
+	}
+	if second.Hash == first.Hash {
+		t.Fatalf("Expected a new snapshot after changes")
+	}
+
+	snapshots, err := repo.Snapshots()
+	if err != nil {
+		t.Fatalf("Failed to list snapshots: %v", err)
+	}
+	if len(snapshots) != 2 || snapshots[0].Hash != first.Hash || snapshots[1].Hash != second.Hash {
+		t.Errorf("Expected both snapshots oldest first, got %+v", snapshots)
+	}
+
+	latest, err := repo.GetSnapshot("")
+	if err != nil || latest.Hash != second.Hash {
+		t.Errorf("Expected the latest snapshot %s, got %+v (%v)", second.Hash, latest, err)
+	}
+	byPrefix, err := repo.GetSnapshot(first.Hash[:8])
+	if err != nil || byPrefix.Hash != first.Hash {
+		t.Errorf("Expected snapshot %s by prefix, got %+v (%v)", first.Hash, byPrefix, err)
+	}
+	if _, err := repo.GetSnapshot("zz"); err == nil {
+		t.Errorf("Expected an unknown snapshot to fail")
+	}
+
+	changes := DiffSnapshots(first, second)
+	if len(changes) != 2 ||
+		changes[0].Buffer != "d2" || changes[0].Kind != SnapshotModified || changes[0].To.Commit != faster.Hash ||
+		changes[1].Buffer != "d3" || changes[1].Kind != SnapshotAdded || changes[1].From != nil {
+		t.Errorf("Expected d2 modified and d3 added, got %+v", changes)
+	}
+	if reverse := DiffSnapshots(second, first); len(reverse) != 2 || reverse[1].Kind != SnapshotRemoved {
+		t.Errorf("Expected d3 removed going back, got %+v", reverse)
+	}
+
+	commits, err := repo.SnapshotCommits(first)
+	if err != nil {
+		t.Fatalf("Failed to read snapshot commits: %v", err)
+	}
+	if len(commits) != 2 || commits[0].Hash != kick.Hash || commits[1].Hash != hats.Hash {
+		t.Errorf("Expected the commits of the first snapshot, got %+v", commits)
+	}
+}
@@ -0,0 +1,171 @@
+package core
+
+import (
+	"sort"
+	"time"
+)
+
+// statsBucketSizes are the bucket sizes picked for a timeline, smallest first
+var statsBucketSizes = []time.Duration{
+	time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 2 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour,
+}
+
+// statsMaxBuckets is the number of buckets a timeline is kept within when
+// its bucket size is picked automatically
+const statsMaxBuckets = 24
+
+// Stats summarizes a set of commits
+type Stats struct {
+	Commits     int       `json:"commits"`
+	Successes   int       `json:"successes"`
+	Errors      int       `json:"errors"`
+	SuccessRate float64   `json:"success_rate"` // 0 to 1
+	First       time.Time `json:"first,omitempty"`
+	Last        time.Time `json:"last,omitempty"`
+
+	Buffers   []CountStats `json:"buffers"`   // most active first
+	Languages []CountStats `json:"languages"` // most active first
+
+	// Timeline splits the span into buckets of BucketSize, empty ones included
+	BucketSize time.Duration `json:"bucket_size_ns"`
+	Timeline   []StatsBucket `json:"timeline"`
+}
+
+// CountStats counts the commits of one buffer or language
+type CountStats struct {
+	Name     string `json:"name"`
+	Language string `json:"language,omitempty"` // for buffers, the latest language used
+	Commits  int    `json:"commits"`
+	Errors   int    `json:"errors"`
+}
+
+// StatsBucket is one interval of the timeline
+type StatsBucket struct {
+	Start     time.Time `json:"start"`
+	Commits   int       `json:"commits"`
+	Errors    int       `json:"errors"`
+	PerMinute float64   `json:"per_minute"`
+	BPM       float64   `json:"bpm,omitempty"` // average of the commits reporting a tempo
+}
+
+// ComputeStats summarizes commits, given oldest first. A zero bucket picks a
+// bucket size that keeps the timeline short.
+func ComputeStats(commits []*Commit, bucket time.Duration) *Stats {
+	stats := &Stats{
+		Commits:   len(commits),
+		Buffers:   []CountStats{},
+		Languages: []CountStats{},
+		Timeline:  []StatsBucket{},
+	}
+	if len(commits) == 0 {
+		return stats
+	}
+
+	stats.First, stats.Last = commits[0].Timestamp, commits[0].Timestamp
+	buffers := make(map[string]*CountStats)
+	languages := make(map[string]*CountStats)
+
+	for _, commit := range commits {
+		if commit.Timestamp.Before(stats.First) {
+			stats.First = commit.Timestamp
+		}
+		if commit.Timestamp.After(stats.Last) {
+			stats.Last = commit.Timestamp
+		}
+		if commit.Metadata.Success {
+			stats.Successes++
+		} else {
+			stats.Errors++
+		}
+
+		buffer := countFor(buffers, commit.Metadata.Buffer)
+		if commit.Metadata.Language != "" {
+			buffer.Language = commit.Metadata.Language
+		}
+		for _, count := range []*CountStats{buffer, countFor(languages, commit.Metadata.Language)} {
+			count.Commits++
+			if !commit.Metadata.Success {
+				count.Errors++
+			}
+		}
+	}
+	stats.SuccessRate = float64(stats.Successes) / float64(stats.Commits)
+	stats.Buffers = sortedCounts(buffers)
+	stats.Languages = sortedCounts(languages)
+
+	if bucket <= 0 {
+		bucket = statsBucketSize(stats.Last.Sub(stats.First))
+	}
+	stats.BucketSize = bucket
+	stats.Timeline = statsTimeline(commits, stats.First, stats.Last, bucket)
+
+	return stats
+}
+
+// countFor returns the count for name, creating it on first use
+func countFor(counts map[string]*CountStats, name string) *CountStats {
+	count, exists := counts[name]
+	if !exists {
+		count = &CountStats{Name: name}
+		counts[name] = count
+	}
+	return count
+}
+
+// sortedCounts orders counts by commits, then name
+func sortedCounts(counts map[string]*CountStats) []CountStats {
+	sorted := make([]CountStats, 0, len(counts))
+	for _, count := range counts {
+		sorted = append(sorted, *count)
+	}
+	sort.Slice(sorted, func(a, b int) bool {
+		if sorted[a].Commits != sorted[b].Commits {
+			return sorted[a].Commits > sorted[b].Commits
+		}
+		return sorted[a].Name < sorted[b].Name
+	})
+	return sorted
+}
+
+// statsBucketSize picks the smallest bucket size that splits span into at
+// most statsMaxBuckets buckets
+func statsBucketSize(span time.Duration) time.Duration {
+	for _, size := range statsBucketSizes {
+		if span/size < statsMaxBuckets {
+			return size
+		}
+	}
+	return statsBucketSizes[len(statsBucketSizes)-1]
+}
+
+// statsTimeline counts commits and averages tempo per bucket from first to
+// last, with buckets aligned to the bucket size
+func statsTimeline(commits []*Commit, first, last time.Time, bucket time.Duration) []StatsBucket {
+	start := first.Truncate(bucket)
+	timeline := make([]StatsBucket, int(last.Sub(start)/bucket)+1)
+	for i := range timeline {
+		timeline[i].Start = start.Add(time.Duration(i) * bucket)
+	}
+
+	tempoSamples := make([]int, len(timeline))
+	for _, commit := range commits {
+		i := int(commit.Timestamp.Sub(start) / bucket)
+		timeline[i].Commits++
+		if !commit.Metadata.Success {
+			timeline[i].Errors++
+		}
+		if commit.Metadata.BPM > 0 {
+			timeline[i].BPM += commit.Metadata.BPM
+			tempoSamples[i]++
+		}
+	}
+
+	for i := range timeline {
+		timeline[i].PerMinute = float64(timeline[i].Commits) / bucket.Minutes()
+		if tempoSamples[i] > 0 {
+			timeline[i].BPM /= float64(tempoSamples[i])
+		}
+	}
+	return timeline
+}
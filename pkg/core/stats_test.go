@@ -0,0 +1,71 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeStats(t *testing.T) {
+	start := time.Date(2024, 5, 1, 21, 0, 0, 0, time.UTC)
+	commit := func(offset time.Duration, buffer, language string, success bool, bpm float64) *Commit {
+		return &Commit{
+			Timestamp: start.Add(offset),
+			Metadata:  ExecutionMetadata{Buffer: buffer, Language: language, Success: success, BPM: bpm},
+		}
+	}
+	commits := []*Commit{
+		commit(0, "d1", "tidal", true, 120),
+		commit(time.Minute, "d1", "tidal", false, 0),
+		commit(2*time.Minute, "synth", "sonicpi", true, 130),
+		commit(11*time.Minute, "d1", "tidal", true, 140),
+		commit(12*time.Minute, "d2", "tidal", true, 0),
+	}
+
+	stats := ComputeStats(commits, 5*time.Minute)
+	if stats.Commits != 5 || stats.Successes != 4 || stats.Errors != 1 || stats.SuccessRate != 0.8 {
+		t.Errorf("Expected 5 commits with 1 error, got %+v", stats)
+	}
+	if !stats.First.Equal(start) || !stats.Last.Equal(start.Add(12*time.Minute)) {
+		t.Errorf("Expected the span of the commits, got %s to %s", stats.First, stats.Last)
+	}
+
+	if len(stats.Buffers) != 3 || stats.Buffers[0].Name != "d1" || stats.Buffers[0].Commits != 3 || stats.Buffers[0].Errors != 1 {
+		t.Errorf("Expected d1 to be the busiest buffer, got %+v", stats.Buffers)
+	}
+	if stats.Buffers[1].Name != "d2" || stats.Buffers[2].Language != "sonicpi" {
+		t.Errorf("Expected ties ordered by name, got %+v", stats.Buffers)
+	}
+	if len(stats.Languages) != 2 || stats.Languages[0].Name != "tidal" || stats.Languages[0].Commits != 4 {
+		t.Errorf("Expected tidal then sonicpi, got %+v", stats.Languages)
+	}
+
+	// 21:00, 21:05 (empty) and 21:10
+	if len(stats.Timeline) != 3 {
+		t.Fatalf("Expected 3 buckets, got %+v", stats.Timeline)
+	}
+	first := stats.Timeline[0]
+	if first.Commits != 3 || first.Errors != 1 || first.PerMinute != 0.6 || first.BPM != 125 {
+		t.Errorf("Expected 3 commits averaging 125 bpm in the first bucket, got %+v", first)
+	}
+	if stats.Timeline[1].Commits != 0 || !stats.Timeline[1].Start.Equal(start.Add(5*time.Minute)) {
+		t.Errorf("Expected an empty bucket at 21:05, got %+v", stats.Timeline[1])
+	}
+	if stats.Timeline[2].BPM != 140 {
+		t.Errorf("Expected commits without a tempo to be left out of the average, got %v", stats.Timeline[2].BPM)
+	}
+}
+
+func TestComputeStatsPicksBucket(t *testing.T) {
+	start := time.Date(2024, 5, 1, 21, 0, 0, 0, time.UTC)
+	commits := []*Commit{{Timestamp: start}, {Timestamp: start.Add(90 * time.Minute)}}
+
+	stats := ComputeStats(commits, 0)
+	if stats.BucketSize != 5*time.Minute || len(stats.Timeline) != 19 {
+		t.Errorf("Expected 19 buckets of 5m for 90 minutes, got %d of %s", len(stats.Timeline), stats.BucketSize)
+	}
+
+	empty := ComputeStats(nil, 0)
+	if empty.Commits != 0 || empty.Timeline == nil || empty.Buffers == nil {
+		t.Errorf("Expected empty, non-nil statistics, got %+v", empty)
+	}
+}
@@ -0,0 +1,194 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"testing"
+)
+
+// memoryStorage keeps a repository in memory, the way a database backed
+// StorageInterface would
+type memoryStorage struct {
+	commits map[string]*Commit
+	files   map[string][]byte
+	tags    map[string]string
+	head    string
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{
+		commits: make(map[string]*Commit),
+		files:   make(map[string][]byte),
+		tags:    make(map[string]string),
+	}
+}
+
+func (m *memoryStorage) WriteCommit(commit *Commit) error {
+	copied := *commit
+	m.commits[commit.Hash] = &copied
+	return nil
+}
+
+func (m *memoryStorage) ReadCommit(hash string) (*Commit, error) {
+	commit, ok := m.commits[hash]
+	if !ok {
+		return nil, fmt.Errorf("commit %s not found", hash)
+	}
+	copied := *commit
+	return &copied, nil
+}
+
+func (m *memoryStorage) WritePerformance(performance *Performance) error { return nil }
+
+func (m *memoryStorage) ReadPerformance(id string) (*Performance, error) {
+	return nil, fmt.Errorf("performance %s not found", id)
+}
+
+func (m *memoryStorage) ListPerformances() ([]*Performance, error) { return nil, nil }
+
+func (m *memoryStorage) ListCommits() ([]string, error) {
+	hashes := make([]string, 0, len(m.commits))
+	for hash := range m.commits {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	return hashes, nil
+}
+
+func (m *memoryStorage) Exists(hash string) bool {
+	_, ok := m.commits[hash]
+	return ok
+}
+
+func (m *memoryStorage) DeleteObject(hash string) error {
+	delete(m.commits, hash)
+	return nil
+}
+
+func (m *memoryStorage) ReadHead() (string, error) { return m.head, nil }
+
+func (m *memoryStorage) WriteHead(commitHash string) error {
+	m.head = commitHash
+	return nil
+}
+
+func (m *memoryStorage) ReadTags() (map[string]string, error) {
+	tags := make(map[string]string, len(m.tags))
+	for name, hash := range m.tags {
+		tags[name] = hash
+	}
+	return tags, nil
+}
+
+func (m *memoryStorage) WriteTag(name, commitHash string) error {
+	m.tags[name] = commitHash
+	return nil
+}
+
+func (m *memoryStorage) ReadRepoFile(name string) ([]byte, error) {
+	data, ok := m.files[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return data, nil
+}
+
+func (m *memoryStorage) ReadRepoFileAt(name string, p []byte, off int64) (int, error) {
+	data, ok := m.files[name]
+	if !ok {
+		return 0, os.ErrNotExist
+	}
+	if off >= int64(len(data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *memoryStorage) WriteRepoFile(name string, data []byte) error {
+	m.files[name] = append([]byte(nil), data...)
+	return nil
+}
+
+func (m *memoryStorage) AppendRepoFile(name string, data []byte) error {
+	m.files[name] = append(m.files[name], data...)
+	return nil
+}
+
+func (m *memoryStorage) StatRepoFile(name string) (os.FileInfo, error) {
+	return nil, os.ErrNotExist
+}
+
+func TestRepositoryWithStorage(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	if err := NewRepository(tempDir).Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	store := newMemoryStorage()
+	repo := NewRepositoryWithStorage(tempDir, store)
+
+	metadata := ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}
+	first, err := repo.Commit(`d1 $ s "bd"`, "Kick", metadata)
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	second, err := repo.Commit(`d1 $ s "bd sn"`, "Snare", metadata)
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	if store.head != second.Hash {
+		t.Errorf("Expected HEAD %s in the storage, got %s", second.Hash, store.head)
+	}
+	if second.Parent != first.Hash {
+		t.Errorf("Expected parent %s, got %s", first.Hash, second.Parent)
+	}
+
+	if err := repo.Tag("drop", first.Hash); err != nil {
+		t.Fatalf("Failed to tag: %v", err)
+	}
+	if store.tags["drop"] != first.Hash {
+		t.Errorf("Expected tag in the storage, got %v", store.tags)
+	}
+
+	amended, err := repo.Amend(`d1 $ s "bd sn hh"`, "Snare and hats", metadata)
+	if err != nil {
+		t.Fatalf("Failed to amend: %v", err)
+	}
+	if store.Exists(second.Hash) {
+		t.Error("Expected the amended commit to be deleted from the storage")
+	}
+
+	commits, err := repo.Log(10)
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	if len(commits) != 2 || commits[0].Hash != amended.Hash || commits[1].Hash != first.Hash {
+		t.Errorf("Unexpected log %v", commits)
+	}
+
+	// Nothing reached the repository directory
+	disk, err := LoadRepository(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to load repository: %v", err)
+	}
+	if commits, err := disk.Log(10); err != nil || len(commits) != 0 {
+		t.Errorf("Expected no commits on disk, got %d (%v)", len(commits), err)
+	}
+
+	// The index lives in the storage, so a new instance finds the history
+	reopened := NewRepositoryWithStorage(tempDir, store)
+	if err := reopened.index.LoadIndex(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	if commits, err := reopened.Log(10); err != nil || len(commits) != 2 {
+		t.Errorf("Expected 2 commits from the storage, got %d (%v)", len(commits), err)
+	}
+}
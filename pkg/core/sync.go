@@ -0,0 +1,108 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+// CommitHashes returns the hash of every commit in the index, oldest first,
+// for comparing with a copy of the repository without reading the commits
+func (repo *LiveCodeRepository) CommitHashes() []string {
+	if repo.index == nil {
+		return nil
+	}
+
+	hashes := make([]string, len(repo.index.AllEntries()))
+	for i, entry := range repo.index.AllEntries() {
+		hashes[i] = entry.Hash
+	}
+	return hashes
+}
+
+// ReceiveCommits stores commits made in another copy of this repository,
+// keeping their hashes and parents. Commits already here are skipped; the
+// others are indexed by time among the local ones, so histories recorded on
+// two machines interleave, and HEAD moves to the latest commit. No merge is
+// recorded: the index, not the chain of parents, defines the history, so
+// local commits made since the last sync may no longer be ancestors of HEAD.
+// It returns the commits added.
+func (repo *LiveCodeRepository) ReceiveCommits(commits []*Commit) ([]*Commit, error) {
+	if repo.storage == nil || !repo.IsInitialized() {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+	if err := repo.checkWritable(); err != nil {
+		return nil, err
+	}
+	unlock, err := repo.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Check everything first so a bad commit leaves the repository alone
+	for _, commit := range commits {
+		if !storage.VerifyHash(commit) {
+			return nil, fmt.Errorf("commit %s does not match its content", commit.Hash)
+		}
+	}
+
+	var added []*Commit
+	for _, commit := range commits {
+		if _, exists := repo.index.FindEntry(commit.Hash); exists {
+			continue
+		}
+		if err := repo.storage.WriteCommit(commit); err != nil {
+			return nil, fmt.Errorf("failed to write commit %s: %w", commit.Hash, err)
+		}
+		repo.index.RestoreCommit(commit)
+		added = append(added, commit)
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	if err := repo.index.SaveIndex(); err != nil {
+		return nil, fmt.Errorf("failed to update index: %w", err)
+	}
+	if err := repo.storage.WriteHead(repo.index.GetHead()); err != nil {
+		return nil, fmt.Errorf("failed to update HEAD: %w", err)
+	}
+
+	// The search index catches up with the new commits when next loaded
+	repo.searchIndex = nil
+	return added, nil
+}
+
+// ReceivePerformance stores a performance recorded in another copy of this
+// repository. It is added when missing and replaces the local copy once it
+// has ended there, unless it is the performance active here. It reports
+// whether anything was written.
+func (repo *LiveCodeRepository) ReceivePerformance(performance *Performance) (bool, error) {
+	if !repo.IsInitialized() {
+		return false, fmt.Errorf("repository not initialized")
+	}
+	if err := repo.checkWritable(); err != nil {
+		return false, err
+	}
+
+	// The ID names the performance's file
+	if performance.ID == "" || strings.HasPrefix(performance.ID, ".") || strings.ContainsAny(performance.ID, "/\\") {
+		return false, fmt.Errorf("invalid performance ID %q", performance.ID)
+	}
+	if repo.currentPerformance != nil && repo.currentPerformance.ID == performance.ID {
+		return false, nil
+	}
+
+	if local, err := repo.storage.ReadPerformance(performance.ID); err == nil {
+		if !local.EndTime.IsZero() || performance.EndTime.IsZero() {
+			return false, nil
+		}
+	}
+
+	if err := repo.storage.WritePerformance(performance); err != nil {
+		return false, fmt.Errorf("failed to write performance %s: %w", performance.Name, err)
+	}
+	return true, nil
+}
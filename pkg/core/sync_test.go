@@ -0,0 +1,150 @@
+package core
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReceiveCommits(t *testing.T) {
+	laptopDir := createTempDir(t)
+	defer os.RemoveAll(laptopDir)
+	studioDir := createTempDir(t)
+	defer os.RemoveAll(studioDir)
+
+	laptop := NewRepository(laptopDir)
+	if err := laptop.Init(laptopDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	studio := NewRepository(studioDir)
+	if err := studio.Init(studioDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	metadata := ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}
+	first, _ := laptop.Commit("d1 $ s \"bd\"", "Laptop", metadata)
+	studioCommit, _ := studio.Commit("d2 $ s \"hh\"", "Studio", metadata)
+	second, _ := laptop.Commit("d1 $ s \"bd sn\"", "Laptop again", metadata)
+
+	history, _ := laptop.History()
+	added, err := studio.ReceiveCommits(history)
+	if err != nil {
+		t.Fatalf("Failed to receive commits: %v", err)
+	}
+	if len(added) != 2 {
+		t.Fatalf("Expected 2 commits added, got %d", len(added))
+	}
+
+	// Both histories interleave by time, keeping hashes and parents
+	hashes := studio.CommitHashes()
+	expected := []string{first.Hash, studioCommit.Hash, second.Hash}
+	if len(hashes) != 3 || hashes[0] != expected[0] || hashes[1] != expected[1] || hashes[2] != expected[2] {
+		t.Errorf("Expected %v, got %v", expected, hashes)
+	}
+	if received, err := studio.GetCommit(second.Hash); err != nil || received.Parent != first.Hash {
+		t.Errorf("Expected the parent to be kept, got %+v (%v)", received, err)
+	}
+	if head, _ := studio.Log(1); len(head) != 1 || head[0].Hash != second.Hash {
+		t.Errorf("Expected HEAD to move to the latest commit")
+	}
+	if results, err := studio.Search("sn", SearchOptions{}); err != nil || len(results) != 1 {
+		t.Errorf("Expected received commits to be searchable, got %d (%v)", len(results), err)
+	}
+
+	// Receiving again changes nothing
+	if added, err := studio.ReceiveCommits(history); err != nil || len(added) != 0 {
+		t.Errorf("Expected nothing new, got %d (%v)", len(added), err)
+	}
+
+	tampered := *second
+	tampered.Hash = "0000000000000000000000000000000000000000"
+	tampered.Timestamp = time.Now()
+	if _, err := studio.ReceiveCommits([]*Commit{&tampered}); err == nil {
+		t.Errorf("Expected a commit not matching its hash to be refused")
+	}
+}
+
+func TestReceiveCommitsAfterWorkOnBothSides(t *testing.T) {
+	laptopDir := createTempDir(t)
+	defer os.RemoveAll(laptopDir)
+	studioDir := createTempDir(t)
+	defer os.RemoveAll(studioDir)
+
+	laptop := NewRepository(laptopDir)
+	if err := laptop.Init(laptopDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	studio := NewRepository(studioDir)
+	if err := studio.Init(studioDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	// Both sides start from the same commit, then work apart
+	metadata := ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}
+	shared, _ := laptop.Commit("d1 $ s \"bd\"", "Shared", metadata)
+	history, _ := laptop.History()
+	if _, err := studio.ReceiveCommits(history); err != nil {
+		t.Fatalf("Failed to receive commits: %v", err)
+	}
+	studioCommit, _ := studio.Commit("d2 $ s \"hh\"", "Studio", metadata)
+	laptopCommit, _ := laptop.Commit("d1 $ s \"bd sn\"", "Laptop", metadata)
+
+	history, _ = laptop.History()
+	if _, err := studio.ReceiveCommits(history); err != nil {
+		t.Fatalf("Failed to receive commits: %v", err)
+	}
+
+	// The index holds both lines of work; only the pulled one leads to HEAD
+	log, err := studio.Log(10)
+	if err != nil || len(log) != 3 {
+		t.Fatalf("Expected 3 commits in the log, got %d (%v)", len(log), err)
+	}
+	if log[0].Hash != laptopCommit.Hash || log[1].Hash != studioCommit.Hash || log[2].Hash != shared.Hash {
+		t.Errorf("Expected the commits of both sides by time, got %s, %s, %s", log[0].Message, log[1].Message, log[2].Message)
+	}
+	if log[0].Parent != shared.Hash || log[1].Parent != shared.Hash {
+		t.Errorf("Expected both sides to keep their parents")
+	}
+	if result, err := studio.Fsck(false); err != nil || result.Unrepaired() != 0 {
+		t.Errorf("Expected no problems after the pull, got %+v (%v)", result, err)
+	}
+
+	// Pulling the other way leaves both sides with the same history
+	history, _ = studio.History()
+	if _, err := laptop.ReceiveCommits(history); err != nil {
+		t.Fatalf("Failed to receive commits: %v", err)
+	}
+	if strings.Join(laptop.CommitHashes(), " ") != strings.Join(studio.CommitHashes(), " ") {
+		t.Errorf("Expected the same history on both sides, got %v and %v", laptop.CommitHashes(), studio.CommitHashes())
+	}
+}
+
+func TestReceivePerformance(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	remote := &Performance{ID: "perf-1", Name: "Algorave", StartTime: time.Now().Add(-time.Hour)}
+	if written, err := repo.ReceivePerformance(remote); err != nil || !written {
+		t.Fatalf("Expected a new performance to be written, got %t (%v)", written, err)
+	}
+
+	// An ended copy replaces one still open, but not the other way round
+	ended := *remote
+	ended.EndTime = time.Now()
+	if written, _ := repo.ReceivePerformance(&ended); !written {
+		t.Errorf("Expected the ended performance to replace the open one")
+	}
+	if written, _ := repo.ReceivePerformance(remote); written {
+		t.Errorf("Expected an open copy not to replace the ended one")
+	}
+
+	if _, err := repo.ReceivePerformance(&Performance{ID: "../escape"}); err == nil {
+		t.Errorf("Expected an ID with a path to be refused")
+	}
+}
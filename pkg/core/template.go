@@ -0,0 +1,335 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+// TemplatesFile is the name of the file holding a repository's performance templates
+const TemplatesFile = "templates.json"
+
+// Template plans a performance, e.g. a 45-minute club set: the sections
+// expected at offsets from the start and the buffers it should use
+type Template struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Duration    Offset           `json:"duration,omitempty"`
+	Sections    []PlannedSection `json:"sections,omitempty"`
+	Buffers     []PlannedBuffer  `json:"buffers,omitempty"`
+}
+
+// PlannedSection is a section of a template starting At into the performance
+type PlannedSection struct {
+	Label string `json:"label"`
+	At    Offset `json:"at"`
+}
+
+// PlannedBuffer is a buffer a template expects to be used
+type PlannedBuffer struct {
+	Name     string `json:"name"`
+	Language string `json:"language,omitempty"`
+}
+
+// Offset is a duration written as text such as "10m30s", so template files
+// are easy to edit by hand
+type Offset time.Duration
+
+// MarshalJSON writes the offset as a duration string
+func (o Offset) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(o).String())
+}
+
+// UnmarshalJSON reads a duration string
+func (o *Offset) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("offset must be a duration string such as \"10m\": %w", err)
+	}
+
+	d, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*o = Offset(d)
+	return nil
+}
+
+// Validate checks that a template can be used to start a performance
+func (t *Template) Validate() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return fmt.Errorf("template name is required")
+	}
+	if t.Duration < 0 {
+		return fmt.Errorf("template duration cannot be negative")
+	}
+
+	for _, section := range t.Sections {
+		if strings.TrimSpace(section.Label) == "" {
+			return fmt.Errorf("section at %s has no label", time.Duration(section.At))
+		}
+		if section.At < 0 || (t.Duration > 0 && section.At > t.Duration) {
+			return fmt.Errorf("section %s at %s is outside the template", section.Label, time.Duration(section.At))
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, buffer := range t.Buffers {
+		if buffer.Name == "" {
+			return fmt.Errorf("buffer name is required")
+		}
+		if seen[buffer.Name] {
+			return fmt.Errorf("buffer %s is listed twice", buffer.Name)
+		}
+		seen[buffer.Name] = true
+	}
+
+	return nil
+}
+
+// Templates returns the repository's performance templates sorted by name
+func (repo *LiveCodeRepository) Templates() ([]*Template, error) {
+	data, err := os.ReadFile(repo.templatesPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read templates: %w", err)
+	}
+
+	var templates []*Template
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("failed to parse templates %s: %w", repo.templatesPath(), err)
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates, nil
+}
+
+// GetTemplate finds a template by name
+func (repo *LiveCodeRepository) GetTemplate(name string) (*Template, error) {
+	templates, err := repo.Templates()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, template := range templates {
+		if template.Name == name {
+			return template, nil
+		}
+	}
+	return nil, fmt.Errorf("no template named %s", name)
+}
+
+// SaveTemplate adds a template, replacing any template with the same name
+func (repo *LiveCodeRepository) SaveTemplate(template *Template) error {
+	if err := template.Validate(); err != nil {
+		return err
+	}
+
+	templates, err := repo.Templates()
+	if err != nil {
+		return err
+	}
+
+	kept := []*Template{template}
+	for _, existing := range templates {
+		if existing.Name != template.Name {
+			kept = append(kept, existing)
+		}
+	}
+
+	return repo.writeTemplates(kept)
+}
+
+// RemoveTemplate deletes a template by name
+func (repo *LiveCodeRepository) RemoveTemplate(name string) error {
+	templates, err := repo.Templates()
+	if err != nil {
+		return err
+	}
+
+	kept := make([]*Template, 0, len(templates))
+	for _, template := range templates {
+		if template.Name != name {
+			kept = append(kept, template)
+		}
+	}
+	if len(kept) == len(templates) {
+		return fmt.Errorf("no template named %s", name)
+	}
+
+	return repo.writeTemplates(kept)
+}
+
+// writeTemplates replaces the templates file
+func (repo *LiveCodeRepository) writeTemplates(templates []*Template) error {
+	if !repo.IsInitialized() {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+
+	data, err := json.MarshalIndent(templates, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal templates: %w", err)
+	}
+
+	if err := os.WriteFile(repo.templatesPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write templates: %w", err)
+	}
+
+	return nil
+}
+
+// templatesPath returns the location of the templates file
+func (repo *LiveCodeRepository) templatesPath() string {
+	return filepath.Join(repo.path, storage.RepoDir, TemplatesFile)
+}
+
+// StartPlannedPerformance starts a performance from a template: each section
+// becomes a planned marker at its offset from now, and each buffer is
+// expected with no activity yet
+func (repo *LiveCodeRepository) StartPlannedPerformance(name string, template *Template) (*Performance, error) {
+	if err := template.Validate(); err != nil {
+		return nil, err
+	}
+
+	performance, err := repo.StartPerformance(name)
+	if err != nil {
+		return nil, err
+	}
+
+	performance.Template = template.Name
+	if performance.Description == "" {
+		performance.Description = template.Description
+	}
+	if template.Duration > 0 {
+		performance.PlannedEnd = performance.StartTime.Add(time.Duration(template.Duration))
+	}
+
+	for _, section := range template.Sections {
+		performance.Markers = append(performance.Markers, Marker{
+			Label:   section.Label,
+			Time:    performance.StartTime.Add(time.Duration(section.At)),
+			Planned: true,
+		})
+	}
+
+	if len(template.Buffers) > 0 {
+		performance.Buffers = make(map[string]*BufferStats)
+		for _, buffer := range template.Buffers {
+			performance.Buffers[buffer.Name] = &BufferStats{Language: buffer.Language, Planned: true}
+		}
+	}
+
+	repo.performanceDirty = true
+	if err := repo.FlushPerformance(); err != nil {
+		return nil, fmt.Errorf("failed to write plan: %w", err)
+	}
+
+	return performance, nil
+}
+
+// PlanComparison holds what happened in a performance next to its plan
+type PlanComparison struct {
+	Template        string
+	PlannedDuration time.Duration
+	ActualDuration  time.Duration // so far, for an active performance
+
+	Sections  []SectionComparison
+	Unplanned []Marker // markers made that the plan did not have
+	Buffers   []BufferComparison
+}
+
+// SectionComparison matches a planned section with the marker made for it
+type SectionComparison struct {
+	Label   string
+	Planned time.Time
+	Actual  time.Time // zero when the section was never marked
+}
+
+// Drift returns how late (positive) or early the section was marked
+func (s SectionComparison) Drift() time.Duration {
+	if s.Actual.IsZero() {
+		return 0
+	}
+	return s.Actual.Sub(s.Planned)
+}
+
+// BufferComparison reports whether a buffer was planned and how it was used
+type BufferComparison struct {
+	Name     string
+	Language string
+	Planned  bool
+	Commits  int
+}
+
+// ComparePlan compares a performance with its plan. Each planned section is
+// matched with the first marker made with the same label, ignoring case.
+func ComparePlan(performance *Performance) *PlanComparison {
+	comparison := &PlanComparison{Template: performance.Template}
+
+	end := performance.EndTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+	comparison.ActualDuration = end.Sub(performance.StartTime)
+	if !performance.PlannedEnd.IsZero() {
+		comparison.PlannedDuration = performance.PlannedEnd.Sub(performance.StartTime)
+	}
+
+	var made []Marker
+	for _, marker := range performance.Markers {
+		if !marker.Planned {
+			made = append(made, marker)
+		}
+	}
+
+	used := make([]bool, len(made))
+	for _, marker := range performance.Markers {
+		if !marker.Planned {
+			continue
+		}
+
+		section := SectionComparison{Label: marker.Label, Planned: marker.Time}
+		for i, candidate := range made {
+			if !used[i] && strings.EqualFold(strings.TrimSpace(candidate.Label), strings.TrimSpace(marker.Label)) {
+				used[i] = true
+				section.Actual = candidate.Time
+				break
+			}
+		}
+		comparison.Sections = append(comparison.Sections, section)
+	}
+
+	for i, marker := range made {
+		if !used[i] {
+			comparison.Unplanned = append(comparison.Unplanned, marker)
+		}
+	}
+
+	for name, stats := range performance.Buffers {
+		comparison.Buffers = append(comparison.Buffers, BufferComparison{
+			Name:     name,
+			Language: stats.Language,
+			Planned:  stats.Planned,
+			Commits:  stats.CommitCount,
+		})
+	}
+	sort.Slice(comparison.Buffers, func(i, j int) bool {
+		return comparison.Buffers[i].Name < comparison.Buffers[j].Name
+	})
+
+	return comparison
+}
@@ -0,0 +1,204 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func createTestTemplate() *Template {
+	return &Template{
+		Name:     "45-min club set",
+		Duration: Offset(45 * time.Minute),
+		Sections: []PlannedSection{
+			{Label: "intro", At: 0},
+			{Label: "drop", At: Offset(25 * time.Minute)},
+			{Label: "outro", At: Offset(40 * time.Minute)},
+		},
+		Buffers: []PlannedBuffer{{Name: "d1", Language: "tidal"}, {Name: "d2", Language: "tidal"}},
+	}
+}
+
+func TestTemplateStore(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	if templates, err := repo.Templates(); err != nil || len(templates) != 0 {
+		t.Fatalf("Expected no templates, got %d (%v)", len(templates), err)
+	}
+
+	if err := repo.SaveTemplate(createTestTemplate()); err != nil {
+		t.Fatalf("Failed to save template: %v", err)
+	}
+	if err := repo.SaveTemplate(&Template{Name: "warmup", Duration: Offset(10 * time.Minute)}); err != nil {
+		t.Fatalf("Failed to save template: %v", err)
+	}
+
+	// Saving under an existing name replaces the template
+	replacement := createTestTemplate()
+	replacement.Description = "Friday residency"
+	if err := repo.SaveTemplate(replacement); err != nil {
+		t.Fatalf("Failed to replace template: %v", err)
+	}
+
+	templates, err := repo.Templates()
+	if err != nil {
+		t.Fatalf("Failed to list templates: %v", err)
+	}
+	if len(templates) != 2 || templates[0].Name != "45-min club set" || templates[1].Name != "warmup" {
+		t.Fatalf("Expected 2 templates sorted by name, got %v", templates)
+	}
+
+	template, err := repo.GetTemplate("45-min club set")
+	if err != nil {
+		t.Fatalf("Failed to get template: %v", err)
+	}
+	if template.Description != "Friday residency" || len(template.Sections) != 3 || template.Sections[1].At != Offset(25*time.Minute) {
+		t.Errorf("Expected replaced template with 3 sections, got %+v", template)
+	}
+
+	if err := repo.RemoveTemplate("warmup"); err != nil {
+		t.Fatalf("Failed to remove template: %v", err)
+	}
+	if err := repo.RemoveTemplate("warmup"); err == nil {
+		t.Errorf("Expected removing a missing template to fail")
+	}
+	if _, err := repo.GetTemplate("warmup"); err == nil {
+		t.Errorf("Expected removed template to be gone")
+	}
+}
+
+func TestTemplateValidate(t *testing.T) {
+	tests := map[string]*Template{
+		"no name":           {Sections: []PlannedSection{{Label: "intro"}}},
+		"unlabeled section": {Name: "set", Sections: []PlannedSection{{At: Offset(time.Minute)}}},
+		"section past end":  {Name: "set", Duration: Offset(time.Minute), Sections: []PlannedSection{{Label: "late", At: Offset(2 * time.Minute)}}},
+		"duplicate buffer":  {Name: "set", Buffers: []PlannedBuffer{{Name: "d1"}, {Name: "d1"}}},
+	}
+
+	for name, template := range tests {
+		if err := template.Validate(); err == nil {
+			t.Errorf("Expected %s to be invalid", name)
+		}
+	}
+
+	if err := createTestTemplate().Validate(); err != nil {
+		t.Errorf("Expected test template to be valid, got %v", err)
+	}
+}
+
+func TestOffsetJSON(t *testing.T) {
+	data, err := json.Marshal(PlannedSection{Label: "drop", At: Offset(25*time.Minute + 30*time.Second)})
+	if err != nil {
+		t.Fatalf("Failed to marshal section: %v", err)
+	}
+	if string(data) != `{"label":"drop","at":"25m30s"}` {
+		t.Errorf("Expected offset written as a duration string, got %s", data)
+	}
+
+	var section PlannedSection
+	if err := json.Unmarshal([]byte(`{"label":"build","at":"10m"}`), &section); err != nil {
+		t.Fatalf("Failed to unmarshal section: %v", err)
+	}
+	if section.At != Offset(10*time.Minute) {
+		t.Errorf("Expected 10m, got %v", time.Duration(section.At))
+	}
+
+	if err := json.Unmarshal([]byte(`{"label":"build","at":600}`), &section); err == nil {
+		t.Errorf("Expected a numeric offset to be rejected")
+	}
+}
+
+func TestStartPlannedPerformanceAndComparePlan(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	performance, err := repo.StartPlannedPerformance("Club night", createTestTemplate())
+	if err != nil {
+		t.Fatalf("Failed to start planned performance: %v", err)
+	}
+
+	if performance.Template != "45-min club set" || performance.PlannedEnd.Sub(performance.StartTime) != 45*time.Minute {
+		t.Errorf("Expected plan of 45m from the template, got %s ending %v", performance.Template, performance.PlannedEnd)
+	}
+	if len(performance.Markers) != 3 || !performance.Markers[1].Planned || performance.Markers[1].Time.Sub(performance.StartTime) != 25*time.Minute {
+		t.Fatalf("Expected 3 planned markers, got %+v", performance.Markers)
+	}
+	if stats := performance.Buffers["d2"]; stats == nil || !stats.Planned || stats.CommitCount != 0 {
+		t.Fatalf("Expected expected buffer d2 without activity, got %+v", stats)
+	}
+
+	if _, err := repo.Commit("d1 $ s \"bd\"", "Kick", ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if _, err := repo.Commit("d3 $ s \"arpy\"", "Arp", ExecutionMetadata{Buffer: "d3", Language: "tidal", Success: true}); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if stats := performance.Buffers["d1"]; stats.CommitCount != 1 || stats.FirstActivity.IsZero() {
+		t.Errorf("Expected d1 activity to be recorded on the planned buffer, got %+v", stats)
+	}
+
+	if _, err := repo.Mark("Intro"); err != nil {
+		t.Fatalf("Failed to mark: %v", err)
+	}
+	marker, err := repo.Mark("")
+	if err != nil {
+		t.Fatalf("Failed to mark: %v", err)
+	}
+	if marker.Label != "marker 2" {
+		t.Errorf("Expected planned markers not to count towards default labels, got '%s'", marker.Label)
+	}
+
+	// The plan survives reloading the repository
+	loaded, err := LoadRepository(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to load repository: %v", err)
+	}
+	reloaded, err := loaded.GetPerformance(performance.ID)
+	if err != nil {
+		t.Fatalf("Failed to get performance: %v", err)
+	}
+
+	comparison := ComparePlan(reloaded)
+	if comparison.Template != "45-min club set" || comparison.PlannedDuration != 45*time.Minute {
+		t.Errorf("Expected 45m plan, got %s %v", comparison.Template, comparison.PlannedDuration)
+	}
+
+	if len(comparison.Sections) != 3 {
+		t.Fatalf("Expected 3 sections, got %d", len(comparison.Sections))
+	}
+	if intro := comparison.Sections[0]; intro.Actual.IsZero() || intro.Drift() < 0 || intro.Drift() > time.Minute {
+		t.Errorf("Expected intro to be marked just after the start, got drift %v", intro.Drift())
+	}
+	if drop := comparison.Sections[1]; !drop.Actual.IsZero() || drop.Drift() != 0 {
+		t.Errorf("Expected drop not to be marked, got %v", drop.Actual)
+	}
+	if len(comparison.Unplanned) != 1 || comparison.Unplanned[0].Label != "marker 2" {
+		t.Errorf("Expected one unplanned marker, got %+v", comparison.Unplanned)
+	}
+
+	expected := []BufferComparison{
+		{Name: "d1", Language: "tidal", Planned: true, Commits: 1},
+		{Name: "d2", Language: "tidal", Planned: true, Commits: 0},
+		{Name: "d3", Language: "tidal", Planned: false, Commits: 1},
+	}
+	if len(comparison.Buffers) != len(expected) {
+		t.Fatalf("Expected %d buffers, got %+v", len(expected), comparison.Buffers)
+	}
+	for i, buffer := range expected {
+		if comparison.Buffers[i] != buffer {
+			t.Errorf("Expected buffer %+v, got %+v", buffer, comparison.Buffers[i])
+		}
+	}
+}
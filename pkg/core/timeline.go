@@ -0,0 +1,197 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+// TimelineFile records, in a merged repository, where its commits came from.
+// Its presence makes the repository read-only.
+const TimelineFile = "timeline"
+
+// Timeline describes a repository merged from the repositories of an ensemble
+type Timeline struct {
+	Performers []TimelinePerformer `json:"performers"`
+}
+
+// TimelinePerformer is one performer's part of a merged timeline
+type TimelinePerformer struct {
+	Name    string `json:"name"`
+	Source  string `json:"source"` // path of the performer's repository
+	Commits int    `json:"commits"`
+}
+
+// TimelineSource is a performer's repository to merge
+type TimelineSource struct {
+	Performer string
+	Repo      *LiveCodeRepository
+}
+
+// MergeTimelines creates a read-only repository at out holding the commits of
+// every source interleaved by time. Each commit is attributed to its performer
+// and its buffer prefixed with the performer's name, e.g. alice/d1, so
+// buffers of the same name stay apart. Tags become <performer>-<tag>, and the
+// markers of every performance are gathered in one performance named name.
+func MergeTimelines(out, name string, sources []TimelineSource) (*LiveCodeRepository, error) {
+	type origin struct {
+		commit *Commit
+		source int
+		hash   string
+	}
+
+	timeline := &Timeline{}
+	seen := make(map[string]bool)
+	var merged []origin
+	for i, source := range sources {
+		// Names become part of buffer and tag names
+		if source.Performer == "" || strings.ContainsAny(source.Performer, "/\\ \t\n") || seen[source.Performer] {
+			return nil, fmt.Errorf("performer names must be unique words, got %q", source.Performer)
+		}
+		seen[source.Performer] = true
+
+		history, err := source.Repo.History()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history of %s: %w", source.Performer, err)
+		}
+		for _, commit := range history {
+			labeled := *commit
+			labeled.Author = source.Performer
+			labeled.Metadata.Buffer = source.Performer + "/" + commit.Metadata.Buffer
+			merged = append(merged, origin{commit: &labeled, source: i, hash: commit.Hash})
+		}
+		timeline.Performers = append(timeline.Performers, TimelinePerformer{
+			Name:    source.Performer,
+			Source:  source.Repo.GetPath(),
+			Commits: len(history),
+		})
+	}
+	if len(merged) == 0 {
+		return nil, fmt.Errorf("no commits to merge")
+	}
+
+	// Commits made at the same time keep their order within each repository
+	sort.SliceStable(merged, func(a, b int) bool {
+		return merged[a].commit.Timestamp.Before(merged[b].commit.Timestamp)
+	})
+
+	repo := NewRepository(out)
+	if err := repo.Init(out); err != nil {
+		return nil, err
+	}
+
+	hashes := make([]map[string]string, len(sources))
+	for i := range hashes {
+		hashes[i] = make(map[string]string)
+	}
+	commits := make([]*Commit, len(merged))
+	for i, entry := range merged {
+		if err := repo.ImportCommit(entry.commit); err != nil {
+			return nil, err
+		}
+		hashes[entry.source][entry.hash] = entry.commit.Hash
+		commits[i] = entry.commit
+	}
+
+	performance, err := repo.ImportPerformance(name, commits)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, source := range sources {
+		tags, err := source.Repo.Tags()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tags of %s: %w", source.Performer, err)
+		}
+		for tag, hash := range tags {
+			if mapped, exists := hashes[i][hash]; exists {
+				if err := repo.Tag(source.Performer+"-"+tag, mapped); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		performances, err := source.Repo.ListPerformances()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read performances of %s: %w", source.Performer, err)
+		}
+		for _, sourcePerformance := range performances {
+			for _, marker := range sourcePerformance.Markers {
+				labeled := Marker{
+					Label:   source.Performer + ": " + marker.Label,
+					Time:    marker.Time,
+					Commit:  hashes[i][marker.Commit],
+					Planned: marker.Planned,
+				}
+				if len(marker.Buffers) > 0 {
+					labeled.Buffers = make(map[string]string, len(marker.Buffers))
+					for buffer, hash := range marker.Buffers {
+						if mapped, exists := hashes[i][hash]; exists {
+							labeled.Buffers[source.Performer+"/"+buffer] = mapped
+						}
+					}
+				}
+				performance.Markers = append(performance.Markers, labeled)
+			}
+		}
+	}
+
+	names := make([]string, len(sources))
+	for i, source := range sources {
+		names[i] = source.Performer
+	}
+	performance.Author = strings.Join(names, ", ")
+	sort.SliceStable(performance.Markers, func(a, b int) bool {
+		return performance.Markers[a].Time.Before(performance.Markers[b].Time)
+	})
+	if err := repo.storage.WritePerformance(performance); err != nil {
+		return nil, fmt.Errorf("failed to write performance: %w", err)
+	}
+
+	// Written last: from here on the repository refuses changes
+	data, err := json.MarshalIndent(timeline, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal timeline: %w", err)
+	}
+	if err := os.WriteFile(repo.timelinePath(), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write timeline: %w", err)
+	}
+
+	return repo, nil
+}
+
+// Timeline returns where a merged repository's commits came from, or nil
+// for a repository that wasn't merged
+func (repo *LiveCodeRepository) Timeline() (*Timeline, error) {
+	data, err := os.ReadFile(repo.timelinePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read timeline: %w", err)
+	}
+
+	var timeline Timeline
+	if err := json.Unmarshal(data, &timeline); err != nil {
+		return nil, fmt.Errorf("failed to parse timeline: %w", err)
+	}
+	return &timeline, nil
+}
+
+// checkWritable refuses changes to a merged timeline
+func (repo *LiveCodeRepository) checkWritable() error {
+	if _, err := os.Stat(repo.timelinePath()); err == nil {
+		return fmt.Errorf("%s is a merged timeline and is read-only", repo.path)
+	}
+	return nil
+}
+
+// timelinePath returns the path of the timeline file
+func (repo *LiveCodeRepository) timelinePath() string {
+	return filepath.Join(repo.path, storage.RepoDir, TimelineFile)
+}
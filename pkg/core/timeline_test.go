@@ -0,0 +1,144 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMergeTimelines(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	start := time.Date(2024, 5, 1, 21, 0, 0, 0, time.UTC)
+	sources := make([]TimelineSource, 0, 2)
+	for i, performer := range []string{"alice", "bob"} {
+		path := filepath.Join(tempDir, performer)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		repo := NewRepository(path)
+		if err := repo.Init(path); err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+
+		// alice plays at :00 and :02, bob at :01 and :03, both in d1
+		for j := 0; j < 2; j++ {
+			commit := &Commit{
+				Timestamp: start.Add(time.Duration(2*j+i) * time.Minute),
+				Message:   performer + " change",
+				Author:    "someone",
+				Content:   "d1 $ s \"bd\"",
+				Metadata:  ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true},
+			}
+			if err := repo.ImportCommit(commit); err != nil {
+				t.Fatalf("Failed to import commit: %v", err)
+			}
+			if j == 0 {
+				if err := repo.Tag("intro", commit.Hash); err != nil {
+					t.Fatalf("Failed to tag: %v", err)
+				}
+			}
+		}
+		sources = append(sources, TimelineSource{Performer: performer, Repo: repo})
+	}
+
+	bob := sources[1].Repo
+	if _, err := bob.StartPerformance("Set"); err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+	if _, err := bob.MarkSnapshot("drop"); err != nil {
+		t.Fatalf("Failed to mark: %v", err)
+	}
+	if err := bob.EndPerformance(); err != nil {
+		t.Fatalf("Failed to end performance: %v", err)
+	}
+
+	out := filepath.Join(tempDir, "ensemble")
+	merged, err := MergeTimelines(out, "Ensemble", sources)
+	if err != nil {
+		t.Fatalf("Failed to merge timelines: %v", err)
+	}
+
+	history, err := merged.History()
+	if err != nil {
+		t.Fatalf("Failed to read merged history: %v", err)
+	}
+	if len(history) != 4 {
+		t.Fatalf("Expected 4 merged commits, got %d", len(history))
+	}
+	for i, commit := range history {
+		performer := []string{"alice", "bob"}[i%2]
+		if commit.Author != performer || commit.Metadata.Buffer != performer+"/d1" {
+			t.Errorf("Expected commit %d by %s in %s/d1, got %s in %s", i, performer, performer, commit.Author, commit.Metadata.Buffer)
+		}
+		if i > 0 && commit.Parent != history[i-1].Hash {
+			t.Errorf("Expected commits to be chained in time order")
+		}
+	}
+
+	tags, err := merged.Tags()
+	if err != nil || tags["alice-intro"] != history[0].Hash || tags["bob-intro"] != history[1].Hash {
+		t.Errorf("Expected tags prefixed by performer, got %v (%v)", tags, err)
+	}
+
+	performance, err := merged.GetPerformance("Ensemble")
+	if err != nil {
+		t.Fatalf("Failed to find merged performance: %v", err)
+	}
+	if performance.CommitCount != 4 || performance.Author != "alice, bob" {
+		t.Errorf("Expected a performance of 4 commits by alice and bob, got %d by %s", performance.CommitCount, performance.Author)
+	}
+	if len(performance.Markers) != 1 || performance.Markers[0].Label != "bob: drop" ||
+		performance.Markers[0].Buffers["bob/d1"] != history[3].Hash {
+		t.Errorf("Expected bob's snapshot remapped to the merged commits, got %+v", performance.Markers)
+	}
+
+	timeline, err := merged.Timeline()
+	if err != nil || timeline == nil || len(timeline.Performers) != 2 || timeline.Performers[1].Commits != 2 {
+		t.Errorf("Expected the timeline to record both performers, got %+v (%v)", timeline, err)
+	}
+
+	// The merged repository is read-only, also once reloaded
+	reloaded, err := LoadRepository(out)
+	if err != nil {
+		t.Fatalf("Failed to load merged repository: %v", err)
+	}
+	if _, err := reloaded.Commit("hush", "Late", ExecutionMetadata{}); err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Errorf("Expected commits to be refused, got %v", err)
+	}
+	if err := reloaded.Tag("mine", history[0].Hash); err == nil {
+		t.Errorf("Expected tags to be refused")
+	}
+	if _, err := reloaded.StartPerformance("Again"); err == nil {
+		t.Errorf("Expected performances to be refused")
+	}
+
+	// The sources are untouched
+	if bobHistory, _ := bob.History(); bobHistory[0].Metadata.Buffer != "d1" || bobHistory[0].Author != "someone" {
+		t.Errorf("Expected source commits to be unchanged, got %+v", bobHistory[0])
+	}
+	if timeline, _ := bob.Timeline(); timeline != nil {
+		t.Errorf("Expected sources not to be timelines")
+	}
+}
+
+func TestMergeTimelinesRejectsDuplicatePerformers(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	repo := NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	sources := []TimelineSource{{Performer: "alice", Repo: repo}, {Performer: "alice", Repo: repo}}
+	if _, err := MergeTimelines(filepath.Join(tempDir, "out"), "Ensemble", sources); err == nil {
+		t.Errorf("Expected duplicate performers to be rejected")
+	}
+	if _, err := MergeTimelines(filepath.Join(tempDir, "out"), "Ensemble", sources[:1]); err == nil {
+		t.Errorf("Expected merging no commits to fail")
+	}
+}
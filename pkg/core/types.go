@@ -7,7 +7,13 @@ import (
 // Type aliases for convenience
 type Commit = storage.Commit
 type ExecutionMetadata = storage.ExecutionMetadata
+type AudioFeatures = storage.AudioFeatures
+type SoundActivity = storage.SoundActivity
 type Performance = storage.Performance
+type BufferStats = storage.BufferStats
+type Marker = storage.Marker
+type LogFilter = storage.IndexFilter
+type GrepMatch = storage.GrepMatch
 
 // Repository represents a livecoding performance repository
 type Repository struct {
@@ -22,18 +28,27 @@ type RepositoryInterface interface {
 	Init(path string) error
 	Commit(content string, message string, metadata ExecutionMetadata) (*Commit, error)
 	Log(limit int) ([]*Commit, error)
+	LogWithFilter(filter LogFilter, limit int) ([]*Commit, error)
 	GetCommit(hash string) (*Commit, error)
+	Search(query string, opts SearchOptions) ([]*SearchResult, error)
 	GetCurrentPerformance() (*Performance, error)
 	StartPerformance(name string) (*Performance, error)
 	EndPerformance() error
 }
 
-// StorageInterface defines the storage operations for commits and metadata
+// StorageInterface defines the storage operations for commits and metadata,
+// the references into the history and the files the indexes are kept in.
+// Storage other processes can change too also implements storage.Locker.
 type StorageInterface interface {
 	WriteCommit(commit *Commit) error
 	ReadCommit(hash string) (*Commit, error)
 	WritePerformance(performance *Performance) error
 	ReadPerformance(id string) (*Performance, error)
+	ListPerformances() ([]*Performance, error)
 	ListCommits() ([]string, error)
 	Exists(hash string) bool
+	DeleteObject(hash string) error
+
+	storage.RefStore
+	storage.IndexStorage
 }
@@ -0,0 +1,191 @@
+// Package daemon lets lcg commands talk to a watcher service running in the
+// background, over a Unix domain socket next to the repository it watches.
+// Windows 10 and later support Unix domain sockets too, so the same socket
+// serves there instead of a named pipe.
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/livecodegit/pkg/storage"
+	"github.com/livecodegit/pkg/watchers"
+)
+
+// SocketFile is the control socket of a daemon inside the repository directory
+const SocketFile = "daemon.sock"
+
+// maxSocketPath is the longest socket path every platform accepts; sun_path
+// holds 104 bytes on macOS and 108 on Linux
+const maxSocketPath = 100
+
+// DefaultTimeout bounds a request to the daemon
+const DefaultTimeout = 5 * time.Second
+
+// Commands a daemon answers
+const (
+	CommandPing   = "ping"   // check that the daemon is up
+	CommandStatus = "status" // report the watcher service
+	CommandStop   = "stop"   // stop the watchers and exit
+	CommandFlush  = "flush"  // commit the executions waiting in the batch
+)
+
+// ErrNotRunning is returned when no daemon listens on a repository's socket
+var ErrNotRunning = errors.New("daemon is not running")
+
+// Request is one command sent to the daemon
+type Request struct {
+	Command string `json:"command"`
+}
+
+// Response answers a request
+type Response struct {
+	OK     bool    `json:"ok"`
+	Error  string  `json:"error,omitempty"`
+	Status *Status `json:"status,omitempty"`
+
+	// Flushed is how many buffers a flush committed
+	Flushed int `json:"flushed,omitempty"`
+}
+
+// Status describes a running daemon
+type Status struct {
+	Repository string                `json:"repository"`
+	Socket     string                `json:"socket"`
+	Service    watchers.ServiceState `json:"service"`
+}
+
+// Handler answers the requests of a daemon's clients
+type Handler func(Request) Response
+
+// SocketPath returns the control socket of the daemon watching a repository.
+// Paths too long for a socket fall back to one in the temporary directory,
+// named after the repository so clients find it too.
+func SocketPath(repoPath string) string {
+	path := filepath.Join(repoPath, storage.RepoDir, SocketFile)
+	if len(path) <= maxSocketPath {
+		return path
+	}
+
+	if abs, err := filepath.Abs(repoPath); err == nil {
+		repoPath = abs
+	}
+	return filepath.Join(os.TempDir(), "lcg-"+storage.GenerateHash(repoPath)[:12]+".sock")
+}
+
+// Server accepts requests on a repository's control socket
+type Server struct {
+	listener net.Listener
+	path     string
+	handler  Handler
+	wg       sync.WaitGroup
+}
+
+// Listen opens the control socket of a repository. A socket left behind by
+// a daemon that died is replaced; one that still answers is an error.
+func Listen(repoPath string, handler Handler) (*Server, error) {
+	path := SocketPath(repoPath)
+
+	if _, err := os.Stat(path); err == nil {
+		if _, err := Call(repoPath, Request{Command: CommandPing}, time.Second); err == nil {
+			return nil, fmt.Errorf("a daemon is already running for %s", repoPath)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+
+	return &Server{listener: listener, path: path, handler: handler}, nil
+}
+
+// Path returns the socket path the server listens on
+func (s *Server) Path() string {
+	return s.path
+}
+
+// Serve answers requests until the server is closed
+func (s *Server) Serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Error accepting daemon connection: %v", err)
+			continue
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// serveConn answers the one request of a connection
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(DefaultTimeout))
+
+	var request Request
+	if err := json.NewDecoder(conn).Decode(&request); err != nil {
+		json.NewEncoder(conn).Encode(Response{Error: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	if err := json.NewEncoder(conn).Encode(s.handler(request)); err != nil {
+		log.Printf("Failed to answer daemon request: %v", err)
+	}
+}
+
+// Close stops accepting requests, waits for those being answered and removes
+// the socket
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	os.Remove(s.path)
+	return err
+}
+
+// Call sends a request to the daemon watching a repository. A response that
+// isn't OK is returned as an error.
+func Call(repoPath string, request Request, timeout time.Duration) (*Response, error) {
+	conn, err := net.DialTimeout("unix", SocketPath(repoPath), timeout)
+	if err != nil {
+		return nil, ErrNotRunning
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		return nil, fmt.Errorf("failed to send request to daemon: %w", err)
+	}
+
+	var response Response
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to read daemon response: %w", err)
+	}
+	if !response.OK {
+		return &response, fmt.Errorf("daemon: %s", response.Error)
+	}
+	return &response, nil
+}
+
+// Running reports whether a daemon answers on a repository's socket
+func Running(repoPath string) bool {
+	_, err := Call(repoPath, Request{Command: CommandPing}, time.Second)
+	return err == nil
+}
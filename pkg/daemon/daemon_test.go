@@ -0,0 +1,134 @@
+package daemon
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+func createTestRepoDir(t *testing.T) string {
+	// A short base keeps the socket path within the limit
+	dir, err := os.MkdirTemp("", "lcgd")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	if err := os.MkdirAll(filepath.Join(dir, storage.RepoDir), 0755); err != nil {
+		t.Fatalf("Failed to create repository directory: %v", err)
+	}
+	return dir
+}
+
+func startTestServer(t *testing.T, repoPath string, handler Handler) *Server {
+	server, err := Listen(repoPath, handler)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go server.Serve()
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+func TestCallRoundTrip(t *testing.T) {
+	repoPath := createTestRepoDir(t)
+
+	if Running(repoPath) {
+		t.Fatalf("Expected no daemon before listening")
+	}
+	if _, err := Call(repoPath, Request{Command: CommandPing}, time.Second); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Expected ErrNotRunning, got %v", err)
+	}
+
+	server := startTestServer(t, repoPath, func(request Request) Response {
+		switch request.Command {
+		case CommandPing:
+			return Response{OK: true}
+		case CommandStatus:
+			return Response{OK: true, Status: &Status{Repository: repoPath}}
+		}
+		return Response{Error: "unknown command"}
+	})
+
+	if server.Path() != filepath.Join(repoPath, storage.RepoDir, SocketFile) {
+		t.Errorf("Expected the socket inside the repository, got %s", server.Path())
+	}
+	if !Running(repoPath) {
+		t.Errorf("Expected the daemon to answer")
+	}
+
+	response, err := Call(repoPath, Request{Command: CommandStatus}, time.Second)
+	if err != nil {
+		t.Fatalf("Failed to call status: %v", err)
+	}
+	if response.Status == nil || response.Status.Repository != repoPath {
+		t.Errorf("Expected status of %s, got %+v", repoPath, response.Status)
+	}
+
+	if _, err := Call(repoPath, Request{Command: "dance"}, time.Second); err == nil || !strings.Contains(err.Error(), "unknown command") {
+		t.Errorf("Expected the handler's error, got %v", err)
+	}
+
+	server.Close()
+	if _, err := os.Stat(server.Path()); !os.IsNotExist(err) {
+		t.Errorf("Expected the socket to be removed on close")
+	}
+	if Running(repoPath) {
+		t.Errorf("Expected no daemon after close")
+	}
+}
+
+func TestListenRefusesSecondDaemon(t *testing.T) {
+	repoPath := createTestRepoDir(t)
+	startTestServer(t, repoPath, func(Request) Response { return Response{OK: true} })
+
+	if _, err := Listen(repoPath, func(Request) Response { return Response{OK: true} }); err == nil {
+		t.Errorf("Expected a second daemon to be refused")
+	}
+	if !Running(repoPath) {
+		t.Errorf("Expected the first daemon to keep answering")
+	}
+}
+
+func TestListenReplacesStaleSocket(t *testing.T) {
+	repoPath := createTestRepoDir(t)
+
+	// A socket nobody listens on, as left by a daemon that was killed
+	path := SocketPath(repoPath)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("Expected a stale socket: %v", err)
+	}
+
+	startTestServer(t, repoPath, func(Request) Response { return Response{OK: true} })
+	if !Running(repoPath) {
+		t.Errorf("Expected the new daemon to answer")
+	}
+}
+
+func TestSocketPathFallback(t *testing.T) {
+	short := SocketPath("/srv/set")
+	if short != filepath.Join("/srv/set", storage.RepoDir, SocketFile) {
+		t.Errorf("Expected the socket inside the repository, got %s", short)
+	}
+
+	long := filepath.Join("/srv", strings.Repeat("performances/", 10), "set")
+	path := SocketPath(long)
+	if len(path) > maxSocketPath || filepath.Dir(path) != filepath.Clean(os.TempDir()) {
+		t.Errorf("Expected a short socket in the temporary directory, got %s", path)
+	}
+	if SocketPath(long) != path || SocketPath(long+"2") == path {
+		t.Errorf("Expected the fallback to be stable and distinct per repository")
+	}
+}
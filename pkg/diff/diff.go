@@ -0,0 +1,153 @@
+// Package diff compares versions of a buffer line by line. Livecoding edits
+// are small changes to short buffers, so lines are aligned with a plain
+// longest common subsequence after trimming the unchanged start and end.
+package diff
+
+import "strings"
+
+// maxCells bounds the work of the alignment; larger edits fall back to
+// matching equal lines in order
+const maxCells = 4_000_000
+
+// Lines splits content into lines, ignoring a trailing newline
+func Lines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// Match aligns two versions of a buffer: for each line of after, the index of
+// the line of before it was kept from, or -1 for a line that was added
+func Match(before, after []string) []int {
+	matches := make([]int, len(after))
+	for i := range matches {
+		matches[i] = -1
+	}
+
+	// Skip the common prefix and suffix, which is most of a livecoding edit
+	start := 0
+	for start < len(before) && start < len(after) && before[start] == after[start] {
+		matches[start] = start
+		start++
+	}
+	endBefore, endAfter := len(before), len(after)
+	for endBefore > start && endAfter > start && before[endBefore-1] == after[endAfter-1] {
+		endBefore--
+		endAfter--
+		matches[endAfter] = endBefore
+	}
+
+	a, b := before[start:endBefore], after[start:endAfter]
+	if len(a) == 0 || len(b) == 0 {
+		return matches
+	}
+
+	if len(a)*len(b) > maxCells {
+		matchInOrder(a, b, matches[start:endAfter], start)
+		return matches
+	}
+
+	// common[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:], so the alignment can be read off from the start
+	width := len(b) + 1
+	common := make([]int32, (len(a)+1)*width)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i*width+j] = common[(i+1)*width+j+1] + 1
+			} else {
+				common[i*width+j] = max(common[(i+1)*width+j], common[i*width+j+1])
+			}
+		}
+	}
+
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			matches[start+j] = start + i
+			i++
+			j++
+		case common[(i+1)*width+j] >= common[i*width+j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return matches
+}
+
+// matchInOrder matches each line of b to the next equal line of a, for edits
+// too large to align exactly
+func matchInOrder(a, b []string, matches []int, offset int) {
+	positions := make(map[string][]int)
+	for i, line := range a {
+		positions[line] = append(positions[line], i)
+	}
+
+	last := -1
+	for j, line := range b {
+		candidates := positions[line]
+		for len(candidates) > 0 && candidates[0] <= last {
+			candidates = candidates[1:]
+		}
+		if len(candidates) > 0 {
+			last = candidates[0]
+			matches[j] = offset + last
+			candidates = candidates[1:]
+		}
+		positions[line] = candidates
+	}
+}
+
+// Stats counts the lines added and removed between two versions of a buffer
+func Stats(before, after []string) (added, removed int) {
+	common := 0
+	for _, match := range Match(before, after) {
+		if match >= 0 {
+			common++
+		}
+	}
+	return len(after) - common, len(before) - common
+}
+
+// Op says what happened to a line between two versions
+type Op byte
+
+const (
+	Equal  Op = ' ' // kept
+	Insert Op = '+' // added
+	Delete Op = '-' // removed
+)
+
+// Line is one line of an edit script
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Script lists the lines of both versions in order, as a unified diff would:
+// kept lines once, and removed lines before the lines added in their place
+func Script(before, after []string) []Line {
+	script := make([]Line, 0, max(len(before), len(after)))
+	var inserted []Line
+	next := 0 // first line of before not yet in the script
+
+	for j, match := range Match(before, after) {
+		if match < 0 {
+			inserted = append(inserted, Line{Insert, after[j]})
+			continue
+		}
+		for ; next < match; next++ {
+			script = append(script, Line{Delete, before[next]})
+		}
+		script = append(script, inserted...)
+		inserted = inserted[:0]
+		script = append(script, Line{Equal, after[j]})
+		next = match + 1
+	}
+	for ; next < len(before); next++ {
+		script = append(script, Line{Delete, before[next]})
+	}
+	return append(script, inserted...)
+}
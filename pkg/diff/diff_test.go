@@ -0,0 +1,83 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	tests := []struct {
+		before  []string
+		after   []string
+		added   int
+		removed int
+	}{
+		{nil, []string{"a", "b"}, 2, 0},
+		{[]string{"a", "b"}, nil, 0, 2},
+		{[]string{"a", "b", "c"}, []string{"a", "x", "c"}, 1, 1},
+		{[]string{"a", "b", "c"}, []string{"c", "a", "b"}, 1, 1},
+		{[]string{"a"}, []string{"a"}, 0, 0},
+	}
+
+	for _, test := range tests {
+		added, removed := Stats(test.before, test.after)
+		if added != test.added || removed != test.removed {
+			t.Errorf("Expected +%d -%d for %v -> %v, got +%d -%d",
+				test.added, test.removed, test.before, test.after, added, removed)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		before  []string
+		after   []string
+		matches []int
+	}{
+		{[]string{"a", "b", "c"}, []string{"a", "x", "c"}, []int{0, -1, 2}},
+		{[]string{"a", "b", "c", "d"}, []string{"x", "b", "d", "y"}, []int{-1, 1, 3, -1}},
+		{[]string{"a", "b"}, []string{"a", "b", "b"}, []int{0, 1, -1}},
+		{nil, []string{"a"}, []int{-1}},
+	}
+
+	for _, test := range tests {
+		if matches := Match(test.before, test.after); !reflect.DeepEqual(matches, test.matches) {
+			t.Errorf("Expected %v for %v -> %v, got %v", test.matches, test.before, test.after, matches)
+		}
+	}
+
+	// Edits too large to align exactly still keep lines in order
+	matches := []int{-1, -1, -1}
+	matchInOrder([]string{"a", "b", "a"}, []string{"b", "a", "b"}, matches, 1)
+	if !reflect.DeepEqual(matches, []int{2, 3, -1}) {
+		t.Errorf("Expected [2 3 -1], got %v", matches)
+	}
+}
+
+func TestScript(t *testing.T) {
+	render := func(script []Line) []string {
+		lines := make([]string, len(script))
+		for i, line := range script {
+			lines[i] = string(line.Op) + line.Text
+		}
+		return lines
+	}
+
+	tests := []struct {
+		before []string
+		after  []string
+		script []string
+	}{
+		{[]string{"a", "b", "c"}, []string{"a", "x", "c"}, []string{" a", "-b", "+x", " c"}},
+		{[]string{"a", "b"}, []string{"x", "a", "b", "y"}, []string{"+x", " a", " b", "+y"}},
+		{[]string{"a", "b", "c"}, []string{"a"}, []string{" a", "-b", "-c"}},
+		{nil, []string{"a"}, []string{"+a"}},
+		{[]string{"a"}, nil, []string{"-a"}},
+	}
+
+	for _, test := range tests {
+		if script := render(Script(test.before, test.after)); !reflect.DeepEqual(script, test.script) {
+			t.Errorf("Expected %q for %v -> %v, got %q", test.script, test.before, test.after, script)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package export
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+// AnonymizeOptions selects what an Anonymizer removes from exported data
+type AnonymizeOptions struct {
+	// Identity replaces authors with pseudonyms and removes the local
+	// hostname and user name from text
+	Identity bool
+	// Paths reduces file paths in code, messages and errors to their base name
+	Paths bool
+	// HashContent replaces each line of code with a hash of it, keeping
+	// indentation, line counts and repeated lines comparable
+	HashContent bool
+}
+
+// pathPattern matches absolute or home-relative paths with at least two
+// segments, preceded by the start of the text or a delimiter so patterns
+// like "bd*2/4" are left alone
+var pathPattern = regexp.MustCompile(`(^|[\s"'(=,\[])((?:~|[A-Za-z]:)?[/\\][\w.\-]+(?:[/\\][\w.\-]+)+)`)
+
+// Anonymizer rewrites commits and performances for sharing. Pseudonyms and
+// content hashes are consistent within one Anonymizer, and content hashes
+// are salted so common lines can't be looked up.
+type Anonymizer struct {
+	options  AnonymizeOptions
+	authors  map[string]string
+	identity *strings.Replacer
+	salt     []byte
+}
+
+// NewAnonymizer creates an anonymizer for one export
+func NewAnonymizer(options AnonymizeOptions) (*Anonymizer, error) {
+	a := &Anonymizer{
+		options: options,
+		authors: make(map[string]string),
+		salt:    make([]byte, 16),
+	}
+
+	if _, err := rand.Read(a.salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	if options.Identity {
+		var replacements []string
+		if hostname, err := os.Hostname(); err == nil && len(hostname) >= 3 {
+			replacements = append(replacements, hostname, "<host>")
+		}
+		if current, err := user.Current(); err == nil && len(current.Username) >= 3 {
+			replacements = append(replacements, current.Username, "<user>")
+		}
+		a.identity = strings.NewReplacer(replacements...)
+	}
+
+	return a, nil
+}
+
+// Commit returns an anonymized copy of commit
+func (a *Anonymizer) Commit(commit *core.Commit) *core.Commit {
+	anonymized := *commit
+
+	anonymized.Author = a.author(commit.Author)
+	if a.options.Identity {
+		anonymized.AuthorEmail = ""
+	}
+	anonymized.Message = a.text(commit.Message)
+	anonymized.Metadata.ErrorMessage = a.text(commit.Metadata.ErrorMessage)
+
+	if a.options.HashContent {
+		anonymized.Content = a.hashContent(commit.Content)
+	} else {
+		anonymized.Content = a.text(commit.Content)
+	}
+
+	return &anonymized
+}
+
+// Commits returns anonymized copies of commits
+func (a *Anonymizer) Commits(commits []*core.Commit) []*core.Commit {
+	anonymized := make([]*core.Commit, len(commits))
+	for i, commit := range commits {
+		anonymized[i] = a.Commit(commit)
+	}
+	return anonymized
+}
+
+// Performance returns an anonymized copy of performance
+func (a *Anonymizer) Performance(performance *core.Performance) *core.Performance {
+	anonymized := *performance
+
+	anonymized.Author = a.author(performance.Author)
+	anonymized.Name = a.text(performance.Name)
+	anonymized.Description = a.text(performance.Description)
+	anonymized.Template = a.text(performance.Template)
+
+	if len(performance.Markers) > 0 {
+		anonymized.Markers = make([]core.Marker, len(performance.Markers))
+		for i, marker := range performance.Markers {
+			marker.Label = a.text(marker.Label)
+			anonymized.Markers[i] = marker
+		}
+	}
+
+	return &anonymized
+}
+
+// author maps an author to a stable pseudonym such as author-1
+func (a *Anonymizer) author(name string) string {
+	if !a.options.Identity || name == "" {
+		return name
+	}
+
+	pseudonym, exists := a.authors[name]
+	if !exists {
+		pseudonym = fmt.Sprintf("author-%d", len(a.authors)+1)
+		a.authors[name] = pseudonym
+	}
+	return pseudonym
+}
+
+// text removes identifying hosts, users and paths from free text
+func (a *Anonymizer) text(text string) string {
+	if a.options.Paths {
+		text = pathPattern.ReplaceAllStringFunc(text, func(match string) string {
+			groups := pathPattern.FindStringSubmatch(match)
+			base := filepath.Base(strings.ReplaceAll(groups[2], `\`, "/"))
+			return groups[1] + "<path>/" + base
+		})
+	}
+
+	if a.identity != nil {
+		text = a.identity.Replace(text)
+	}
+
+	return text
+}
+
+// hashContent replaces every non-blank line with a salted hash of its
+// trimmed text, keeping the indentation
+func (a *Anonymizer) hashContent(content string) string {
+	lines := strings.Split(content, "\n")
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		sum := sha256.Sum256(append(append([]byte{}, a.salt...), trimmed...))
+		lines[i] = indent + hex.EncodeToString(sum[:6])
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,94 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+func TestAnonymizerIdentityAndPaths(t *testing.T) {
+	anonymizer, err := NewAnonymizer(AnonymizeOptions{Identity: true, Paths: true})
+	if err != nil {
+		t.Fatalf("Failed to create anonymizer: %v", err)
+	}
+
+	commit := &core.Commit{
+		Author:  "alice",
+		Message: "Load /Users/alice/samples/kick.wav",
+		Content: "sample \"/Users/alice/samples/kick.wav\"\nd1 $ s \"bd*2/4\"",
+		Metadata: core.ExecutionMetadata{
+			ErrorMessage: "No such file: C:\\Users\\alice\\kick.wav",
+		},
+	}
+
+	anonymized := anonymizer.Commit(commit)
+
+	if anonymized.Author != "author-1" {
+		t.Errorf("Expected author-1, got %s", anonymized.Author)
+	}
+	if anonymizer.Commit(&core.Commit{Author: "bob"}).Author != "author-2" {
+		t.Errorf("Expected a second author to get author-2")
+	}
+	if anonymizer.Commit(&core.Commit{Author: "alice"}).Author != "author-1" {
+		t.Errorf("Expected pseudonyms to be stable")
+	}
+
+	if anonymized.Message != "Load <path>/kick.wav" {
+		t.Errorf("Expected path stripped from message, got '%s'", anonymized.Message)
+	}
+	if anonymized.Content != "sample \"<path>/kick.wav\"\nd1 $ s \"bd*2/4\"" {
+		t.Errorf("Expected only the path in content to change, got '%s'", anonymized.Content)
+	}
+	if anonymized.Metadata.ErrorMessage != "No such file: <path>/kick.wav" {
+		t.Errorf("Expected Windows path stripped from error, got '%s'", anonymized.Metadata.ErrorMessage)
+	}
+
+	// The original commit is left untouched
+	if commit.Author != "alice" {
+		t.Errorf("Expected original commit to be unchanged")
+	}
+
+	performance := anonymizer.Performance(&core.Performance{Name: "Club set", Author: "bob"})
+	if performance.Author != "author-2" || performance.Name != "Club set" {
+		t.Errorf("Expected performance author-2 'Club set', got %s '%s'", performance.Author, performance.Name)
+	}
+}
+
+func TestAnonymizerHashContent(t *testing.T) {
+	anonymizer, err := NewAnonymizer(AnonymizeOptions{HashContent: true})
+	if err != nil {
+		t.Fatalf("Failed to create anonymizer: %v", err)
+	}
+
+	content := "live_loop :drums do\n  sample :bd_haus\n\n  sample :bd_haus\nend"
+	hashed := anonymizer.Commit(&core.Commit{Author: "alice", Content: content}).Content
+
+	lines := strings.Split(hashed, "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Expected 5 lines, got %d", len(lines))
+	}
+	if strings.Contains(hashed, "sample") {
+		t.Errorf("Expected code to be hidden, got '%s'", hashed)
+	}
+	if !strings.HasPrefix(lines[1], "  ") || lines[2] != "" {
+		t.Errorf("Expected indentation and blank lines kept, got %q", lines)
+	}
+	if lines[1] != lines[3] {
+		t.Errorf("Expected equal lines to hash equally, got %q and %q", lines[1], lines[3])
+	}
+
+	// Without Identity the author is kept
+	if anonymizer.Commit(&core.Commit{Author: "alice"}).Author != "alice" {
+		t.Errorf("Expected author to be kept without identity anonymization")
+	}
+
+	// Another export uses another salt
+	other, err := NewAnonymizer(AnonymizeOptions{HashContent: true})
+	if err != nil {
+		t.Fatalf("Failed to create anonymizer: %v", err)
+	}
+	if other.Commit(&core.Commit{Content: content}).Content == hashed {
+		t.Errorf("Expected hashes to differ between exports")
+	}
+}
@@ -0,0 +1,317 @@
+package export
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/storage"
+)
+
+// ArchiveFormat is the container of a repository archive
+type ArchiveFormat string
+
+const (
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+	ArchiveZip   ArchiveFormat = "zip"
+)
+
+// ArchiveFormatFor picks the archive format from a file name
+func ArchiveFormatFor(name string) (ArchiveFormat, error) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return ArchiveTarGz, nil
+	case strings.HasSuffix(lower, ".zip"):
+		return ArchiveZip, nil
+	}
+	return "", fmt.Errorf("unknown archive format for %s (use .tar.gz, .tgz or .zip)", name)
+}
+
+// Archive is what an archive holds: commits, oldest first, with the
+// performances and tags that refer to them. The last commit becomes HEAD.
+type Archive struct {
+	Commits      []*core.Commit
+	Performances []*core.Performance
+	Tags         map[string]string
+}
+
+// RepositoryArchive collects everything a repository holds, to back it up
+func RepositoryArchive(repo *core.LiveCodeRepository) (*Archive, error) {
+	commits, err := repo.History()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	performances, err := repo.ListPerformances()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list performances: %w", err)
+	}
+	tags, err := repo.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tags: %w", err)
+	}
+
+	return &Archive{Commits: commits, Performances: performances, Tags: tags}, nil
+}
+
+// WriteArchive writes an archive as the .livecodegit directory of a
+// repository holding its contents, so extracting it anywhere restores a
+// repository. The repository is staged in a temporary directory first.
+func WriteArchive(w io.Writer, format ArchiveFormat, archive *Archive) error {
+	staging, err := os.MkdirTemp("", "lcg-archive")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if err := stageArchive(staging, archive); err != nil {
+		return err
+	}
+
+	switch format {
+	case ArchiveTarGz:
+		return writeTarGz(w, staging)
+	case ArchiveZip:
+		return writeZip(w, staging)
+	}
+	return fmt.Errorf("unknown archive format %q", format)
+}
+
+// stageArchive writes the archive's contents as a repository in dir
+func stageArchive(dir string, archive *Archive) error {
+	fs := storage.NewFileSystemStorage(dir)
+	if err := fs.InitializeRepository(); err != nil {
+		return err
+	}
+
+	index := storage.NewIndex(fs)
+	for _, commit := range archive.Commits {
+		if err := fs.WriteCommit(commit); err != nil {
+			return fmt.Errorf("failed to stage commit %s: %w", commit.Hash, err)
+		}
+		index.RestoreCommit(commit)
+	}
+	if err := index.SaveIndex(); err != nil {
+		return fmt.Errorf("failed to stage index: %w", err)
+	}
+	if len(archive.Commits) > 0 {
+		if err := fs.WriteHead(archive.Commits[len(archive.Commits)-1].Hash); err != nil {
+			return fmt.Errorf("failed to stage HEAD: %w", err)
+		}
+	}
+
+	for _, performance := range archive.Performances {
+		if err := fs.WritePerformance(performance); err != nil {
+			return fmt.Errorf("failed to stage performance %s: %w", performance.Name, err)
+		}
+	}
+	for name, hash := range archive.Tags {
+		if err := fs.WriteTag(name, hash); err != nil {
+			return fmt.Errorf("failed to stage tag %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// archiveFiles calls fn for each file of the staged repository with its
+// slash-separated name in the archive
+func archiveFiles(dir string, fn func(name, path string, info os.FileInfo) error) error {
+	root := filepath.Join(dir, storage.RepoDir)
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel), path, info)
+	})
+}
+
+// writeTarGz packs the staged repository as a gzip-compressed tar
+func writeTarGz(w io.Writer, dir string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	err := archiveFiles(dir, func(name, path string, info os.FileInfo) error {
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		return copyFile(tw, path)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return gz.Close()
+}
+
+// writeZip packs the staged repository as a zip
+func writeZip(w io.Writer, dir string) error {
+	zw := zip.NewWriter(w)
+
+	err := archiveFiles(dir, func(name, path string, info os.FileInfo) error {
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		header.Method = zip.Deflate
+		file, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		return copyFile(file, path)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return zw.Close()
+}
+
+// copyFile copies the file at path to w
+func copyFile(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// ExtractArchive restores the repository of an archive into dir, which must
+// not hold a repository yet. The format is recognized from the content.
+// Only files inside the archive's .livecodegit directory are extracted.
+func ExtractArchive(archivePath, dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, storage.RepoDir)); err == nil {
+		return fmt.Errorf("a repository already exists at %s", dir)
+	}
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	magic, err := bufio.NewReader(file).Peek(4)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		err = extractTarGz(file, dir)
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		info, statErr := file.Stat()
+		if statErr != nil {
+			return fmt.Errorf("failed to read archive: %w", statErr)
+		}
+		err = extractZip(file, info.Size(), dir)
+	default:
+		return fmt.Errorf("%s is not a tar.gz or zip archive", archivePath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract archive: %w", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, storage.RepoDir, storage.IndexFile)); err != nil {
+		return fmt.Errorf("%s does not contain a LiveCodeGit repository", archivePath)
+	}
+	return nil
+}
+
+// extractTarGz extracts the repository files of a gzip-compressed tar
+func extractTarGz(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := extractFile(dir, header.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// extractZip extracts the repository files of a zip
+func extractZip(r io.ReaderAt, size int64, dir string) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range zr.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		content, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		err = extractFile(dir, entry.Name, content)
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractFile writes one archived file below dir. Names outside the
+// repository directory, e.g. with "..", are refused rather than written
+// elsewhere.
+func extractFile(dir, name string, content io.Reader) error {
+	clean := path.Clean(name)
+	if !strings.HasPrefix(clean, storage.RepoDir+"/") || !filepath.IsLocal(filepath.FromSlash(clean)) {
+		return fmt.Errorf("unexpected file %s in archive", name)
+	}
+
+	target := filepath.Join(dir, filepath.FromSlash(clean))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,102 @@
+package export
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/storage"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	commits := createTestCommits()
+	parent := ""
+	for _, commit := range commits {
+		commit.Hash = storage.CommitHash(commit)
+		commit.Parent = parent
+		parent = commit.Hash
+	}
+	archive := &Archive{
+		Commits:      commits,
+		Performances: []*core.Performance{{ID: "perf-1", Name: "Algorave 2024", StartTime: commits[0].Timestamp, HeadCommit: parent}},
+		Tags:         map[string]string{"drop": commits[1].Hash},
+	}
+
+	for _, name := range []string{"set.tar.gz", "set.zip"} {
+		format, err := ArchiveFormatFor(name)
+		if err != nil {
+			t.Fatalf("Expected a format for %s: %v", name, err)
+		}
+
+		dir := t.TempDir()
+		path := filepath.Join(dir, name)
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatalf("Failed to create archive: %v", err)
+		}
+		if err := WriteArchive(file, format, archive); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		file.Close()
+
+		restored := filepath.Join(dir, "restored")
+		if err := ExtractArchive(path, restored); err != nil {
+			t.Fatalf("Failed to extract %s: %v", name, err)
+		}
+
+		repo, err := core.LoadRepository(restored)
+		if err != nil {
+			t.Fatalf("Failed to load restored repository: %v", err)
+		}
+		history, err := repo.History()
+		if err != nil || len(history) != 3 || history[2].Hash != parent || history[2].Content != commits[2].Content {
+			t.Errorf("Expected the 3 commits back from %s, got %d (%v)", name, len(history), err)
+		}
+		if tags, _ := repo.Tags(); tags["drop"] != commits[1].Hash {
+			t.Errorf("Expected tag drop on %s, got %v", commits[1].Hash, tags)
+		}
+		if performance, err := repo.GetPerformance("Algorave 2024"); err != nil || performance.HeadCommit != parent {
+			t.Errorf("Expected the performance back from %s: %v", name, err)
+		}
+		if result, err := repo.Fsck(false); err != nil || len(result.Problems) != 0 {
+			t.Errorf("Expected a consistent repository from %s, got %+v (%v)", name, result, err)
+		}
+
+		if err := ExtractArchive(path, restored); err == nil {
+			t.Errorf("Expected extracting over a repository to fail")
+		}
+	}
+
+	if _, err := ArchiveFormatFor("set.rar"); err == nil {
+		t.Errorf("Expected an unknown extension to fail")
+	}
+}
+
+func TestExtractArchiveRefusesPathsOutsideRepository(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	content := []byte("pwned")
+	tw.WriteHeader(&tar.Header{Name: ".livecodegit/../../escape", Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+	tw.Write(content)
+	tw.Close()
+	gz.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "evil.tar.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+
+	target := filepath.Join(dir, "target")
+	if err := ExtractArchive(path, target); err == nil {
+		t.Errorf("Expected a path outside the repository to be refused")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape")); err == nil {
+		t.Errorf("Expected nothing to be written outside the target")
+	}
+}
@@ -0,0 +1,152 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+// BundleVersion is the version of the performance bundle format
+const BundleVersion = 1
+
+// Bundle is a compact, self-contained copy of one performance for a client
+// to cache, e.g. for offline review on a phone right after a gig. Buffers and
+// code are stored once and referenced by index, since most evaluations repeat
+// code already sent.
+type Bundle struct {
+	Version     int               `json:"v"`
+	Performance *core.Performance `json:"performance"`
+	Buffers     []BundleBuffer    `json:"buffers"`
+	Code        []string          `json:"code"`
+	Commits     []BundleCommit    `json:"commits"`
+}
+
+// BundleBuffer names a buffer of a bundle
+type BundleBuffer struct {
+	Name     string `json:"name"`
+	Language string `json:"lang,omitempty"`
+}
+
+// BundleCommit is one commit of a bundle, with short keys to keep it small
+type BundleCommit struct {
+	Hash    string  `json:"h"`
+	Offset  int64   `json:"t"` // milliseconds since the performance started
+	Buffer  int     `json:"b"` // index into Buffers
+	Code    int     `json:"c"` // index into Code
+	Message string  `json:"m,omitempty"`
+	Author  string  `json:"a,omitempty"`
+	BPM     float64 `json:"p,omitempty"`
+	Failed  bool    `json:"f,omitempty"`
+	Error   string  `json:"e,omitempty"`
+}
+
+// BuildBundle packs a performance and its commits, oldest first, into a bundle
+func BuildBundle(performance *core.Performance, commits []*core.Commit) *Bundle {
+	bundle := &Bundle{
+		Version:     BundleVersion,
+		Performance: performance,
+		Buffers:     []BundleBuffer{},
+		Code:        []string{},
+		Commits:     make([]BundleCommit, 0, len(commits)),
+	}
+
+	buffers := make(map[string]int)
+	code := make(map[string]int)
+
+	for _, commit := range commits {
+		buffer, exists := buffers[commit.Metadata.Buffer]
+		if !exists {
+			buffer = len(bundle.Buffers)
+			buffers[commit.Metadata.Buffer] = buffer
+			bundle.Buffers = append(bundle.Buffers, BundleBuffer{Name: commit.Metadata.Buffer})
+		}
+		if commit.Metadata.Language != "" {
+			bundle.Buffers[buffer].Language = commit.Metadata.Language
+		}
+
+		content, exists := code[commit.Content]
+		if !exists {
+			content = len(bundle.Code)
+			code[commit.Content] = content
+			bundle.Code = append(bundle.Code, commit.Content)
+		}
+
+		// The performance author is implied
+		author := commit.Author
+		if author == performance.Author {
+			author = ""
+		}
+
+		bundle.Commits = append(bundle.Commits, BundleCommit{
+			Hash:    commit.Hash,
+			Offset:  commit.Timestamp.Sub(performance.StartTime).Milliseconds(),
+			Buffer:  buffer,
+			Code:    content,
+			Message: commit.Message,
+			Author:  author,
+			BPM:     commit.Metadata.BPM,
+			Failed:  !commit.Metadata.Success,
+			Error:   commit.Metadata.ErrorMessage,
+		})
+	}
+
+	return bundle
+}
+
+// Commit expands the i-th commit of a bundle back into a full commit
+func (b *Bundle) Commit(i int) (*core.Commit, error) {
+	if i < 0 || i >= len(b.Commits) {
+		return nil, fmt.Errorf("bundle has no commit %d", i)
+	}
+
+	c := b.Commits[i]
+	if c.Buffer < 0 || c.Buffer >= len(b.Buffers) || c.Code < 0 || c.Code >= len(b.Code) {
+		return nil, fmt.Errorf("bundle commit %s refers to missing buffer or code", c.Hash)
+	}
+
+	author := c.Author
+	if author == "" {
+		author = b.Performance.Author
+	}
+
+	return &core.Commit{
+		Hash:      c.Hash,
+		Timestamp: b.Performance.StartTime.Add(time.Duration(c.Offset) * time.Millisecond),
+		Message:   c.Message,
+		Author:    author,
+		Content:   b.Code[c.Code],
+		Metadata: core.ExecutionMetadata{
+			Buffer:       b.Buffers[c.Buffer].Name,
+			Language:     b.Buffers[c.Buffer].Language,
+			BPM:          c.BPM,
+			Success:      !c.Failed,
+			ErrorMessage: c.Error,
+		},
+	}, nil
+}
+
+// WriteBundle writes a bundle as compact JSON
+func WriteBundle(w io.Writer, bundle *Bundle) error {
+	if err := json.NewEncoder(w).Encode(bundle); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+// ReadBundle reads a bundle written by WriteBundle
+func ReadBundle(r io.Reader) (*Bundle, error) {
+	var bundle Bundle
+	if err := json.NewDecoder(r).Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	if bundle.Version != BundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", bundle.Version)
+	}
+	if bundle.Performance == nil {
+		return nil, fmt.Errorf("bundle has no performance")
+	}
+	return &bundle, nil
+}
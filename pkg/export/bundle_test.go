@@ -0,0 +1,83 @@
+package export
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	commits := createTestCommits()
+	// Re-evaluating the same code stores it once
+	repeat := *commits[0]
+	repeat.Hash = "d4"
+	repeat.Timestamp = commits[2].Timestamp.Add(time.Second)
+	commits = append(commits, &repeat)
+
+	performance := &core.Performance{
+		ID:        "perf-1",
+		Name:      "Algorave 2024",
+		StartTime: commits[0].Timestamp.Add(-time.Second),
+		Author:    "livecoder",
+	}
+
+	bundle := BuildBundle(performance, commits)
+	if len(bundle.Buffers) != 2 || len(bundle.Code) != 3 || len(bundle.Commits) != 4 {
+		t.Fatalf("Expected 2 buffers, 3 distinct code entries and 4 commits, got %d, %d and %d",
+			len(bundle.Buffers), len(bundle.Code), len(bundle.Commits))
+	}
+	if bundle.Commits[3].Code != bundle.Commits[0].Code {
+		t.Errorf("Expected repeated code to share an entry")
+	}
+	if bundle.Commits[1].Offset != 2500 {
+		t.Errorf("Expected second commit 2500ms in, got %d", bundle.Commits[1].Offset)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, bundle); err != nil {
+		t.Fatalf("Failed to write bundle: %v", err)
+	}
+	if strings.Contains(buf.String(), "\n  ") {
+		t.Errorf("Expected compact JSON")
+	}
+
+	read, err := ReadBundle(&buf)
+	if err != nil {
+		t.Fatalf("Failed to read bundle: %v", err)
+	}
+
+	for i, original := range commits {
+		commit, err := read.Commit(i)
+		if err != nil {
+			t.Fatalf("Failed to expand commit %d: %v", i, err)
+		}
+		if !commit.Timestamp.Equal(original.Timestamp) {
+			t.Errorf("Expected commit %d at %v, got %v", i, original.Timestamp, commit.Timestamp)
+		}
+		commit.Timestamp = original.Timestamp
+		expected := *original
+		// Parents and beat positions are left out of bundles
+		expected.Parent = ""
+		expected.Metadata.BeatsFromStart = 0
+		if !reflect.DeepEqual(*commit, expected) {
+			t.Errorf("Expected commit %d to round-trip as %+v, got %+v", i, expected, *commit)
+		}
+	}
+
+	if _, err := read.Commit(4); err == nil {
+		t.Errorf("Expected an error for a commit past the end")
+	}
+}
+
+func TestReadBundleRejectsUnknownVersion(t *testing.T) {
+	if _, err := ReadBundle(strings.NewReader(`{"v":99,"performance":{}}`)); err == nil {
+		t.Errorf("Expected an unknown bundle version to be rejected")
+	}
+	if _, err := ReadBundle(strings.NewReader(`{"v":1}`)); err == nil {
+		t.Errorf("Expected a bundle without a performance to be rejected")
+	}
+}
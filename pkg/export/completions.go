@@ -0,0 +1,246 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/diff"
+)
+
+// Kinds of completion candidates
+const (
+	CompletionWord   = "word"   // identifier, e.g. a function or synth name
+	CompletionSymbol = "symbol" // Ruby symbol, e.g. :bd_haus
+	CompletionString = "string" // string literal, e.g. a mini-notation pattern
+	CompletionLine   = "line"   // a whole line of code, as a snippet
+)
+
+// Completion is one piece of past vocabulary ranked by how often it was written
+type Completion struct {
+	Text     string    `json:"text"`
+	Kind     string    `json:"kind"`
+	Count    int       `json:"count"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// CompletionOptions filters the vocabulary
+type CompletionOptions struct {
+	Language string // only this language; empty for all
+	MinCount int    // drop candidates written fewer times
+	Limit    int    // keep at most this many per language; 0 for all
+}
+
+// CompletionSet holds the ranked candidates of each language
+type CompletionSet struct {
+	Version   int                     `json:"version"`
+	Languages map[string][]Completion `json:"languages"`
+}
+
+var (
+	stringPattern = regexp.MustCompile(`"(?:[^"\\\n]|\\.)*"`)
+	symbolPattern = regexp.MustCompile(`(?:^|[^\w:]):([A-Za-z_]\w*)`)
+	wordPattern   = regexp.MustCompile(`[A-Za-z_][\w']*`)
+)
+
+// minCompletionWord is the shortest word worth suggesting
+const minCompletionWord = 3
+
+// BuildCompletions ranks the words, symbols, strings and lines written in
+// commits, oldest first. Only lines new to their buffer count, so
+// re-evaluating unchanged code doesn't inflate what was written once.
+func BuildCompletions(commits []*core.Commit, options CompletionOptions) *CompletionSet {
+	type key struct{ language, kind, text string }
+	found := make(map[key]*Completion)
+	previous := make(map[string]map[string]bool) // buffer -> lines of its last commit
+
+	record := func(language, kind, text string, at time.Time) {
+		k := key{language, kind, text}
+		completion, exists := found[k]
+		if !exists {
+			completion = &Completion{Text: text, Kind: kind}
+			found[k] = completion
+		}
+		completion.Count++
+		if at.After(completion.LastUsed) {
+			completion.LastUsed = at
+		}
+	}
+
+	for _, commit := range commits {
+		language := strings.ToLower(commit.Metadata.Language)
+		lines := diff.Lines(commit.Content)
+		seen := previous[commit.Metadata.Buffer]
+		current := make(map[string]bool, len(lines))
+
+		for _, line := range lines {
+			line = strings.TrimSpace(line)
+			if line == "" || current[line] {
+				continue
+			}
+			current[line] = true
+			if seen[line] || (options.Language != "" && language != options.Language) {
+				continue
+			}
+
+			code := stripComment(line, language)
+			if code == "" {
+				continue
+			}
+			record(language, CompletionLine, code, commit.Timestamp)
+
+			for _, literal := range stringPattern.FindAllString(code, -1) {
+				if text := literal[1 : len(literal)-1]; strings.TrimSpace(text) != "" {
+					record(language, CompletionString, text, commit.Timestamp)
+				}
+			}
+			withoutStrings := stringPattern.ReplaceAllString(code, `""`)
+			if language == "sonicpi" {
+				for _, match := range symbolPattern.FindAllStringSubmatch(withoutStrings, -1) {
+					record(language, CompletionSymbol, ":"+match[1], commit.Timestamp)
+				}
+				withoutStrings = symbolPattern.ReplaceAllString(withoutStrings, " ")
+			}
+			for _, word := range wordPattern.FindAllString(withoutStrings, -1) {
+				if len(word) >= minCompletionWord {
+					record(language, CompletionWord, word, commit.Timestamp)
+				}
+			}
+		}
+
+		previous[commit.Metadata.Buffer] = current
+	}
+
+	set := &CompletionSet{Version: 1, Languages: make(map[string][]Completion)}
+	for k, completion := range found {
+		if completion.Count >= options.MinCount {
+			set.Languages[k.language] = append(set.Languages[k.language], *completion)
+		}
+	}
+
+	for language, completions := range set.Languages {
+		sort.Slice(completions, func(i, j int) bool {
+			a, b := completions[i], completions[j]
+			if a.Count != b.Count {
+				return a.Count > b.Count
+			}
+			if !a.LastUsed.Equal(b.LastUsed) {
+				return a.LastUsed.After(b.LastUsed)
+			}
+			return a.Text < b.Text
+		})
+		if options.Limit > 0 && len(completions) > options.Limit {
+			set.Languages[language] = completions[:options.Limit]
+		}
+	}
+
+	return set
+}
+
+// stripComment removes a trailing line comment, as far as a simple scan can
+// tell it apart from the comment marker inside a string
+func stripComment(line, language string) string {
+	lang, known := syntaxes[language]
+	if !known {
+		return line
+	}
+
+	masked := stringPattern.ReplaceAllStringFunc(line, func(s string) string {
+		return strings.Repeat(" ", len(s))
+	})
+	if i := strings.Index(masked, lang.comment); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
+}
+
+// languages returns the set's languages in sorted order
+func (s *CompletionSet) languages() []string {
+	languages := make([]string, 0, len(s.Languages))
+	for language := range s.Languages {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// WriteCompletionsJSON writes the set as JSON for completion engines to load
+func WriteCompletionsJSON(w io.Writer, set *CompletionSet) error {
+	data, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal completions: %w", err)
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteCompletionsWords writes one word, symbol or string per line, most
+// used first, as a dictionary file for Vim's 'dictionary' or Emacs
+func WriteCompletionsWords(w io.Writer, set *CompletionSet) error {
+	var b strings.Builder
+	seen := make(map[string]bool)
+	for _, language := range set.languages() {
+		for _, completion := range set.Languages[language] {
+			if completion.Kind == CompletionLine || strings.ContainsAny(completion.Text, " \t") || seen[completion.Text] {
+				continue
+			}
+			seen[completion.Text] = true
+			b.WriteString(completion.Text + "\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// vscodeScopes maps languages to the VS Code language IDs their extensions use
+var vscodeScopes = map[string]string{
+	"sonicpi":       "ruby,sonicpi",
+	"tidal":         "haskell,tidal",
+	"supercollider": "supercollider",
+	"strudel":       "javascript",
+	"hydra":         "javascript",
+}
+
+// WriteCompletionsVSCode writes the lines of the set as a VS Code
+// .code-snippets file, each triggered by its first word
+func WriteCompletionsVSCode(w io.Writer, set *CompletionSet) error {
+	type snippet struct {
+		Prefix      string   `json:"prefix"`
+		Body        []string `json:"body"`
+		Scope       string   `json:"scope,omitempty"`
+		Description string   `json:"description"`
+	}
+
+	snippets := make(map[string]snippet)
+	for _, language := range set.languages() {
+		for i, completion := range set.Languages[language] {
+			if completion.Kind != CompletionLine {
+				continue
+			}
+			prefix := wordPattern.FindString(completion.Text)
+			if prefix == "" {
+				prefix = completion.Text
+			}
+			// $ starts a placeholder in snippet bodies
+			body := strings.ReplaceAll(completion.Text, "$", `\$`)
+			snippets[fmt.Sprintf("%s %d", language, i+1)] = snippet{
+				Prefix:      prefix,
+				Body:        []string{body},
+				Scope:       vscodeScopes[language],
+				Description: fmt.Sprintf("Written %d times, last %s", completion.Count, completion.LastUsed.Format("2006-01-02")),
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(snippets, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal snippets: %w", err)
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
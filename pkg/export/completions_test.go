@@ -0,0 +1,129 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+func createVocabularyCommits() []*core.Commit {
+	start := time.Date(2024, 5, 1, 21, 0, 0, 0, time.UTC)
+	commit := func(offset int, buffer, language, content string) *core.Commit {
+		return &core.Commit{
+			Timestamp: start.Add(time.Duration(offset) * time.Second),
+			Content:   content,
+			Metadata:  core.ExecutionMetadata{Buffer: buffer, Language: language, Success: true},
+		}
+	}
+
+	return []*core.Commit{
+		commit(0, "d1", "tidal", "d1 $ sound \"bd*2 sn\" -- kick"),
+		// Re-evaluating unchanged code doesn't count again
+		commit(10, "d1", "tidal", "d1 $ sound \"bd*2 sn\" -- kick"),
+		commit(20, "d2", "tidal", "d2 $ every 2 (fast 2) $ sound \"bd*2 sn\""),
+		commit(30, "d1", "tidal", "d1 $ every 4 rev $ sound \"bd*2 sn\""),
+		commit(40, "live", "sonicpi", "use_synth :tb303\nplay :e2 # \"quoted\""),
+	}
+}
+
+func TestBuildCompletions(t *testing.T) {
+	set := BuildCompletions(createVocabularyCommits(), CompletionOptions{})
+
+	find := func(language, kind, text string) *Completion {
+		for i, completion := range set.Languages[language] {
+			if completion.Kind == kind && completion.Text == text {
+				return &set.Languages[language][i]
+			}
+		}
+		return nil
+	}
+
+	if pattern := find("tidal", CompletionString, "bd*2 sn"); pattern == nil || pattern.Count != 3 {
+		t.Errorf("Expected pattern 'bd*2 sn' written 3 times, got %+v", pattern)
+	}
+	if every := find("tidal", CompletionWord, "every"); every == nil || every.Count != 2 || !every.LastUsed.Equal(time.Date(2024, 5, 1, 21, 0, 30, 0, time.UTC)) {
+		t.Errorf("Expected 'every' written twice, last at 21:00:30, got %+v", every)
+	}
+	if line := find("tidal", CompletionLine, "d1 $ sound \"bd*2 sn\""); line == nil || line.Count != 1 {
+		t.Errorf("Expected the first line once without its comment, got %+v", line)
+	}
+	if find("tidal", CompletionWord, "kick") != nil || find("tidal", CompletionWord, "d1") != nil {
+		t.Errorf("Expected comments and short words to be left out")
+	}
+
+	if find("sonicpi", CompletionSymbol, ":tb303") == nil || find("sonicpi", CompletionWord, "use_synth") == nil {
+		t.Errorf("Expected Sonic Pi symbols and words, got %+v", set.Languages["sonicpi"])
+	}
+	if find("sonicpi", CompletionWord, "tb303") != nil || find("sonicpi", CompletionString, "quoted") != nil {
+		t.Errorf("Expected symbols not to double as words, and strings in comments to be left out")
+	}
+
+	// Most written first
+	if first := set.Languages["tidal"][0]; first.Text != "sound" && first.Text != "bd*2 sn" {
+		t.Errorf("Expected a candidate written 3 times first, got %+v", first)
+	}
+}
+
+func TestBuildCompletionsOptions(t *testing.T) {
+	set := BuildCompletions(createVocabularyCommits(), CompletionOptions{Language: "tidal", MinCount: 2, Limit: 2})
+
+	if _, exists := set.Languages["sonicpi"]; exists {
+		t.Errorf("Expected only tidal completions")
+	}
+	completions := set.Languages["tidal"]
+	if len(completions) != 2 {
+		t.Fatalf("Expected 2 completions with the limit, got %d", len(completions))
+	}
+	for _, completion := range completions {
+		if completion.Count < 2 {
+			t.Errorf("Expected only candidates written at least twice, got %+v", completion)
+		}
+	}
+}
+
+func TestWriteCompletions(t *testing.T) {
+	set := BuildCompletions(createVocabularyCommits(), CompletionOptions{})
+
+	var words bytes.Buffer
+	if err := WriteCompletionsWords(&words, set); err != nil {
+		t.Fatalf("Failed to write words: %v", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(words.String()), "\n") {
+		if strings.ContainsAny(line, " \t") {
+			t.Errorf("Expected single words only, got %q", line)
+		}
+	}
+	if !strings.Contains(words.String(), ":tb303\n") {
+		t.Errorf("Expected symbols in the dictionary, got:\n%s", words.String())
+	}
+
+	var vscode bytes.Buffer
+	if err := WriteCompletionsVSCode(&vscode, set); err != nil {
+		t.Fatalf("Failed to write snippets: %v", err)
+	}
+	var snippets map[string]struct {
+		Prefix string   `json:"prefix"`
+		Body   []string `json:"body"`
+		Scope  string   `json:"scope"`
+	}
+	if err := json.Unmarshal(vscode.Bytes(), &snippets); err != nil {
+		t.Fatalf("Failed to parse snippets: %v", err)
+	}
+
+	found := false
+	for _, snippet := range snippets {
+		if snippet.Body[0] == `d1 \$ every 4 rev \$ sound "bd*2 sn"` {
+			found = true
+			if snippet.Prefix != "d1" || snippet.Scope != "haskell,tidal" {
+				t.Errorf("Expected prefix d1 in tidal scope, got %+v", snippet)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected a snippet for the d1 line with escaped $, got %s", vscode.String())
+	}
+}
@@ -0,0 +1,139 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+// Trailer keys carrying each commit's execution metadata in a Git export
+const (
+	TrailerHash        = "Livecode-Hash"
+	TrailerBuffer      = "Livecode-Buffer"
+	TrailerLanguage    = "Livecode-Language"
+	TrailerSuccess     = "Livecode-Success"
+	TrailerError       = "Livecode-Error"
+	TrailerBPM         = "Livecode-BPM"
+	TrailerBeats       = "Livecode-Beats"
+	TrailerEnvironment = "Livecode-Environment"
+)
+
+// WriteGitFastImport writes commits, oldest first, as a 'git fast-import'
+// stream on branch. Each buffer is a file named as 'lcg tui' checks it out,
+// and each commit rewrites its buffer's file with its code, keeping the
+// original author, time and message; the execution metadata goes into
+// trailers. Tags that point at exported commits become lightweight Git tags.
+func WriteGitFastImport(w io.Writer, commits []*core.Commit, tags map[string]string, branch string) error {
+	out := bufio.NewWriter(w)
+	marks := make(map[string]int, len(commits))
+	ref := "refs/heads/" + branch
+
+	for i, commit := range commits {
+		mark := i + 1
+		marks[commit.Hash] = mark
+
+		fmt.Fprintf(out, "commit %s\n", ref)
+		fmt.Fprintf(out, "mark :%d\n", mark)
+		signature := gitSignature(commit)
+		fmt.Fprintf(out, "author %s\n", signature)
+		fmt.Fprintf(out, "committer %s\n", signature)
+		writeGitData(out, gitCommitMessage(commit))
+		fmt.Fprintf(out, "M 100644 inline %s\n", core.CheckoutFileName(commit))
+		writeGitData(out, commit.Content)
+		out.WriteString("\n")
+	}
+
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		mark, exists := marks[tags[name]]
+		if !exists {
+			continue
+		}
+		fmt.Fprintf(out, "reset refs/tags/%s\n", gitRefName(name))
+		fmt.Fprintf(out, "from :%d\n\n", mark)
+	}
+
+	out.WriteString("done\n")
+	return out.Flush()
+}
+
+// gitCommitMessage returns a commit's message followed by its metadata trailers
+func gitCommitMessage(commit *core.Commit) string {
+	message := strings.TrimSpace(commit.Message)
+	if message == "" {
+		message = fmt.Sprintf("%s execution in %s", commit.Metadata.Language, commit.Metadata.Buffer)
+	}
+
+	metadata := commit.Metadata
+	trailers := []string{
+		TrailerHash + ": " + commit.Hash,
+		TrailerBuffer + ": " + metadata.Buffer,
+		TrailerLanguage + ": " + metadata.Language,
+		TrailerSuccess + ": " + strconv.FormatBool(metadata.Success),
+	}
+	if metadata.ErrorMessage != "" {
+		// Trailers are single lines
+		trailers = append(trailers, TrailerError+": "+strings.Join(strings.Fields(metadata.ErrorMessage), " "))
+	}
+	if metadata.BPM != 0 {
+		trailers = append(trailers, TrailerBPM+": "+strconv.FormatFloat(metadata.BPM, 'f', -1, 64))
+	}
+	if metadata.BeatsFromStart != 0 {
+		trailers = append(trailers, TrailerBeats+": "+strconv.FormatInt(metadata.BeatsFromStart, 10))
+	}
+	if metadata.Environment != "" {
+		trailers = append(trailers, TrailerEnvironment+": "+metadata.Environment)
+	}
+
+	return message + "\n\n" + strings.Join(trailers, "\n") + "\n"
+}
+
+// gitSignature formats a commit's author and time as fast-import expects
+func gitSignature(commit *core.Commit) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '<' || r == '>' || r == '\n' {
+			return -1
+		}
+		return r
+	}, commit.Author)
+	if name = strings.TrimSpace(name); name == "" {
+		name = core.DefaultAuthor
+	}
+	email := strings.Trim(commit.AuthorEmail, "<> \n")
+
+	return fmt.Sprintf("%s <%s> %d %s", name, email, commit.Timestamp.Unix(), commit.Timestamp.Format("-0700"))
+}
+
+// gitRefName turns a tag name into a valid Git reference name
+func gitRefName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f || strings.ContainsRune("~^:?*[\\", r) {
+			return '-'
+		}
+		return r
+	}, name)
+	for strings.Contains(name, "..") {
+		name = strings.ReplaceAll(name, "..", ".")
+	}
+	name = strings.Trim(strings.ReplaceAll(name, "@{", "-"), "./")
+	name = strings.TrimSuffix(name, ".lock")
+	if name == "" {
+		name = "tag"
+	}
+	return name
+}
+
+// writeGitData writes a fast-import data block
+func writeGitData(out *bufio.Writer, data string) {
+	fmt.Fprintf(out, "data %d\n%s\n", len(data), data)
+}
@@ -0,0 +1,108 @@
+package export
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestWriteGitFastImport(t *testing.T) {
+	tags := map[string]string{"drop here": "b2", "missing": "ffff"}
+
+	var buf bytes.Buffer
+	if err := WriteGitFastImport(&buf, createTestCommits(), tags, "main"); err != nil {
+		t.Fatalf("Failed to write stream: %v", err)
+	}
+	stream := buf.String()
+
+	if count := strings.Count(stream, "commit refs/heads/main\n"); count != 3 {
+		t.Errorf("Expected 3 commits, got %d", count)
+	}
+
+	for _, expected := range []string{
+		"author livecoder <> 1714597200 +0000\n",
+		"M 100644 inline d1.tidal\ndata 12\nd1 $ s \"bd\"\n",
+		"M 100644 inline d2.tidal\n",
+		"Livecode-Hash: c3\n",
+		"Livecode-Success: false\nLivecode-Error: parse error\nLivecode-Beats: 8\n",
+		"Livecode-BPM: 128\n",
+		"reset refs/tags/drop-here\nfrom :2\n",
+	} {
+		if !strings.Contains(stream, expected) {
+			t.Errorf("Expected stream to contain %q, got:\n%s", expected, stream)
+		}
+	}
+
+	// Tags of commits outside the export are left out
+	if strings.Contains(stream, "missing") {
+		t.Errorf("Expected tag of an unexported commit to be skipped")
+	}
+	if !strings.HasSuffix(stream, "done\n") {
+		t.Errorf("Expected stream to end with done")
+	}
+}
+
+func TestWriteGitFastImportWithGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir, err := os.MkdirTemp("", "lcg-git-export-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var stream bytes.Buffer
+	if err := WriteGitFastImport(&stream, createTestCommits(), nil, "main"); err != nil {
+		t.Fatalf("Failed to write stream: %v", err)
+	}
+
+	git := func(stdin *bytes.Buffer, args ...string) string {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		if stdin != nil {
+			cmd.Stdin = stdin
+		}
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s failed: %v: %s", args[0], err, output)
+		}
+		return string(output)
+	}
+
+	git(nil, "init", "-q")
+	git(&stream, "fast-import", "--quiet")
+
+	if log := git(nil, "log", "--format=%s", "refs/heads/main"); log != "Kick, \"fuller\"\nHats\nKick\n" {
+		t.Errorf("Expected three commits, newest first, got %q", log)
+	}
+
+	// The buffer file holds the latest code of its buffer
+	if content := git(nil, "show", "refs/heads/main:d1.tidal"); content != "d1 $ stack [s \"bd*2\",\n  s \"sn\"]\n" {
+		t.Errorf("Expected latest d1 code, got %q", content)
+	}
+
+	if trailer := git(nil, "log", "-1", "--format=%(trailers:key=Livecode-Buffer,valueonly)", "refs/heads/main~1"); strings.TrimSpace(trailer) != "d2" {
+		t.Errorf("Expected buffer trailer d2, got %q", trailer)
+	}
+}
+
+func TestGitRefName(t *testing.T) {
+	tests := map[string]string{
+		"drop":           "drop",
+		"the drop":       "the-drop",
+		"v1..2":          "v1.2",
+		"../etc/passwd":  "etc/passwd",
+		"a:b~c^d?e*f[g]": "a-b-c-d-e-f-g]",
+		"final.lock":     "final",
+		"":               "tag",
+	}
+
+	for name, expected := range tests {
+		if got := gitRefName(name); got != expected {
+			t.Errorf("Expected gitRefName(%q) = %q, got %q", name, expected, got)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package export
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// syntax describes just enough of a livecoding language to highlight it
+type syntax struct {
+	comment  string // line comment prefix
+	quotes   string // string delimiters
+	symbols  bool   // Ruby-style :symbols
+	keywords map[string]bool
+}
+
+// words returns the set of space-separated words in list
+func words(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(list) {
+		set[word] = true
+	}
+	return set
+}
+
+// syntaxes holds the highlighting rules of each known language
+var syntaxes = map[string]syntax{
+	"sonicpi": {comment: "#", quotes: `"'`, symbols: true, keywords: words(
+		"live_loop with_fx use_synth use_bpm play sample sleep do end if else elsif unless loop define in_thread sync cue density times each")},
+	"tidal": {comment: "--", quotes: `"`, keywords: words(
+		"let in where do hush once solo unsolo silence stack cat fastcat slow fast every sometimes often rarely jux rev # $")},
+	"supercollider": {comment: "//", quotes: `"'`, keywords: words(
+		"var arg SynthDef Pdef Ndef Pbind Routine Task play stop fork wait loop if while do collect")},
+	"strudel": {comment: "//", quotes: "\"'`", keywords: words(
+		"const let await stack cat seq note sound s n setcps samples")},
+	"hydra": {comment: "//", quotes: "\"'`", keywords: words(
+		"osc noise voronoi shape gradient solid src out render speed bpm")},
+}
+
+// markdownLanguages maps languages to the names Markdown renderers highlight
+var markdownLanguages = map[string]string{
+	"sonicpi":       "ruby",
+	"tidal":         "haskell",
+	"supercollider": "supercollider",
+	"strudel":       "javascript",
+	"hydra":         "javascript",
+}
+
+// highlightHTML escapes code for HTML and wraps comments, strings, numbers,
+// symbols and keywords in spans with hl-* classes
+func highlightHTML(code, language string) string {
+	lang, known := syntaxes[strings.ToLower(language)]
+	if !known {
+		return html.EscapeString(code)
+	}
+
+	var out strings.Builder
+	span := func(class, text string) {
+		out.WriteString(`<span class="hl-` + class + `">` + html.EscapeString(text) + `</span>`)
+	}
+
+	runes := []rune(code)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		rest := string(runes[i:])
+
+		switch {
+		case strings.HasPrefix(rest, lang.comment):
+			end := i
+			for end < len(runes) && runes[end] != '\n' {
+				end++
+			}
+			span("comment", string(runes[i:end]))
+			i = end
+
+		case strings.ContainsRune(lang.quotes, r):
+			end := i + 1
+			for end < len(runes) && runes[end] != r && runes[end] != '\n' {
+				if runes[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(runes))
+			span("string", string(runes[i:end]))
+			i = end
+
+		case unicode.IsDigit(r) && (i == 0 || !isWordRune(runes[i-1])):
+			end := i
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.') {
+				end++
+			}
+			span("number", string(runes[i:end]))
+			i = end
+
+		case lang.symbols && r == ':' && i+1 < len(runes) && unicode.IsLetter(runes[i+1]) && (i == 0 || (!isWordRune(runes[i-1]) && runes[i-1] != ':')):
+			end := i + 1
+			for end < len(runes) && isWordRune(runes[end]) {
+				end++
+			}
+			span("symbol", string(runes[i:end]))
+			i = end
+
+		case isWordRune(r):
+			end := i
+			for end < len(runes) && (isWordRune(runes[end]) || runes[end] == '\'') {
+				end++
+			}
+			word := string(runes[i:end])
+			if lang.keywords[word] {
+				span("keyword", word)
+			} else {
+				out.WriteString(html.EscapeString(word))
+			}
+			i = end
+
+		default:
+			if lang.keywords[string(r)] {
+				span("keyword", string(r))
+			} else {
+				out.WriteString(html.EscapeString(string(r)))
+			}
+			i++
+		}
+	}
+
+	return out.String()
+}
+
+// isWordRune reports whether r can be part of an identifier
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
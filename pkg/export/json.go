@@ -0,0 +1,87 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+// JSONExport is a complete machine-readable dump of a repository
+type JSONExport struct {
+	Schema        string              `json:"$schema"`
+	SchemaVersion string              `json:"schema_version"`
+	ExportedAt    time.Time           `json:"exported_at"`
+	Head          string              `json:"head,omitempty"`
+	Commits       []*core.Commit      `json:"commits"`
+	Performances  []*core.Performance `json:"performances"`
+}
+
+// BuildJSON collects the full history and performances of a repository
+func BuildJSON(repo *core.LiveCodeRepository) (*JSONExport, error) {
+	commits, err := repo.History()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	performances, err := repo.ListPerformances()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read performances: %w", err)
+	}
+
+	export := &JSONExport{
+		Schema:        SchemaID,
+		SchemaVersion: SchemaVersion,
+		ExportedAt:    time.Now(),
+		Commits:       commits,
+		Performances:  performances,
+	}
+
+	if len(commits) > 0 {
+		export.Head = commits[len(commits)-1].Hash
+	}
+
+	return export, nil
+}
+
+// Anonymize rewrites the export's commits and performances for sharing
+func (e *JSONExport) Anonymize(anonymizer *Anonymizer) {
+	e.Commits = anonymizer.Commits(e.Commits)
+	for i, performance := range e.Performances {
+		e.Performances[i] = anonymizer.Performance(performance)
+	}
+}
+
+// Redact applies privacy rules to the export's commits and performances
+func (e *JSONExport) Redact(rules *core.PrivacyRules) {
+	redaction := Redact(e.Commits, rules)
+	e.Commits = redaction.Commits
+	for i, performance := range e.Performances {
+		e.Performances[i] = redaction.Performance(performance)
+	}
+
+	e.Head = ""
+	if len(e.Commits) > 0 {
+		e.Head = e.Commits[len(e.Commits)-1].Hash
+	}
+}
+
+// WriteJSON validates an export against the schema and writes it as indented JSON
+func WriteJSON(w io.Writer, export *JSONExport) error {
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal export: %w", err)
+	}
+
+	if err := Validate(data); err != nil {
+		return fmt.Errorf("export does not match schema %s: %w", SchemaVersion, err)
+	}
+
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+
+	return nil
+}
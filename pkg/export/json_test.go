@@ -0,0 +1,131 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+func createTestRepository(t *testing.T) (*core.LiveCodeRepository, string) {
+	tempDir, err := os.MkdirTemp("", "livecodegit-export-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+
+	repo := core.NewRepository(tempDir)
+	if err := repo.Init(tempDir); err != nil {
+		t.Fatalf("Failed to initialize test repository: %v", err)
+	}
+
+	return repo, tempDir
+}
+
+func TestSchemaIsValidJSON(t *testing.T) {
+	var schema map[string]interface{}
+	if err := json.Unmarshal(Schema(), &schema); err != nil {
+		t.Fatalf("Export schema is not valid JSON: %v", err)
+	}
+
+	if schema["$id"] != SchemaID {
+		t.Errorf("Expected schema $id '%s', got '%v'", SchemaID, schema["$id"])
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	repo, tempDir := createTestRepository(t)
+	defer os.RemoveAll(tempDir)
+
+	if _, err := repo.StartPerformance("Test Set"); err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+
+	metadata := core.ExecutionMetadata{
+		Buffer:   "drums",
+		Language: "sonicpi",
+		BPM:      128,
+		Success:  false,
+	}
+	commit, err := repo.Commit("sample :bd_haus", "Kick", metadata)
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	dump, err := BuildJSON(repo)
+	if err != nil {
+		t.Fatalf("Failed to build export: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, dump); err != nil {
+		t.Fatalf("Failed to write export: %v", err)
+	}
+
+	var decoded JSONExport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to decode export: %v", err)
+	}
+
+	if decoded.SchemaVersion != SchemaVersion {
+		t.Errorf("Expected schema version '%s', got '%s'", SchemaVersion, decoded.SchemaVersion)
+	}
+
+	if decoded.Head != commit.Hash {
+		t.Errorf("Expected head '%s', got '%s'", commit.Hash, decoded.Head)
+	}
+
+	if len(decoded.Commits) != 1 || len(decoded.Performances) != 1 {
+		t.Fatalf("Expected 1 commit and 1 performance, got %d and %d", len(decoded.Commits), len(decoded.Performances))
+	}
+
+	if decoded.Performances[0].Buffers["drums"] == nil {
+		t.Errorf("Expected performance buffer stats to be exported")
+	}
+}
+
+func TestValidateRejectsInvalidExport(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		errorMsg string
+	}{
+		{
+			name:     "missing required property",
+			document: `{"$schema": "x", "schema_version": "1", "exported_at": "2024-01-01T00:00:00Z", "commits": []}`,
+			errorMsg: `missing required property "performances"`,
+		},
+		{
+			name:     "wrong type",
+			document: `{"$schema": "x", "schema_version": "1", "exported_at": "2024-01-01T00:00:00Z", "commits": {}, "performances": []}`,
+			errorMsg: "$.commits: expected array",
+		},
+		{
+			name: "nested definition",
+			document: `{"$schema": "x", "schema_version": "1", "exported_at": "2024-01-01T00:00:00Z", "performances": [],
+				"commits": [{"hash": "a", "timestamp": "t", "message": "m", "author": "a", "content": "c",
+					"metadata": {"buffer": "b", "language": "l", "success": "yes"}}]}`,
+			errorMsg: "$.commits[0].metadata.success: expected boolean",
+		},
+		{
+			name:     "unexpected property",
+			document: `{"$schema": "x", "schema_version": "1", "exported_at": "2024-01-01T00:00:00Z", "commits": [], "performances": [], "extra": 1}`,
+			errorMsg: "$.extra: unexpected property",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate([]byte(tt.document))
+			if err == nil {
+				t.Fatalf("Expected validation error")
+			}
+
+			if !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("Expected error containing '%s', got '%v'", tt.errorMsg, err)
+			}
+		})
+	}
+}
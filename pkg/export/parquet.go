@@ -0,0 +1,301 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// The Parquet writer below covers what the commit table needs and nothing
+// more: one row group, one uncompressed PLAIN data page per column and only
+// required columns, so no definition or repetition levels are written. The
+// footer uses the Thrift compact protocol described in the Parquet format
+// specification.
+
+const parquetMagic = "PAR1"
+
+// Parquet physical types
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+)
+
+// Parquet converted types
+const (
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+)
+
+// Parquet encodings, page types and codecs
+const (
+	encodingPlain        = 0
+	encodingRLE          = 3
+	pageTypeData         = 0
+	codecUncompressed    = 0
+	repetitionRequired   = 0
+	parquetFormatVersion = 1
+)
+
+// WriteParquet writes rows as a Parquet file with one row per commit
+func WriteParquet(w io.Writer, rows []CommitRow) error {
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	chunks := make([]parquetChunk, len(commitColumns))
+	for i, c := range commitColumns {
+		data := encodeParquetColumn(c, rows)
+
+		header := newThriftWriter()
+		header.i32(1, pageTypeData)
+		header.i32(2, int32(len(data)))
+		header.i32(3, int32(len(data)))
+		header.beginStruct(5) // data_page_header
+		header.i32(1, int32(len(rows)))
+		header.i32(2, encodingPlain)
+		header.i32(3, encodingRLE)
+		header.i32(4, encodingRLE)
+		header.endStruct()
+		header.stop()
+
+		chunks[i] = parquetChunk{
+			column: c,
+			offset: int64(file.Len()),
+			size:   int64(header.buf.Len() + len(data)),
+		}
+		file.Write(header.buf.Bytes())
+		file.Write(data)
+	}
+
+	footer := encodeParquetFooter(chunks, int64(len(rows)))
+	file.Write(footer)
+	binary.Write(&file, binary.LittleEndian, uint32(len(footer)))
+	file.WriteString(parquetMagic)
+
+	if _, err := w.Write(file.Bytes()); err != nil {
+		return fmt.Errorf("failed to write Parquet file: %w", err)
+	}
+
+	return nil
+}
+
+// parquetChunk records where a column's data page was written
+type parquetChunk struct {
+	column column
+	offset int64
+	size   int64
+}
+
+// parquetType maps a column kind to its Parquet physical type
+func parquetType(kind columnKind) int32 {
+	switch kind {
+	case kindInt64, kindTimestamp:
+		return parquetInt64
+	case kindDouble:
+		return parquetDouble
+	case kindBool:
+		return parquetBoolean
+	default:
+		return parquetByteArray
+	}
+}
+
+// encodeParquetColumn PLAIN-encodes one column of rows
+func encodeParquetColumn(c column, rows []CommitRow) []byte {
+	var data bytes.Buffer
+
+	if c.kind == kindBool {
+		bits := make([]byte, (len(rows)+7)/8)
+		for i := range rows {
+			if c.value(&rows[i]).(bool) {
+				bits[i/8] |= 1 << (i % 8)
+			}
+		}
+		return bits
+	}
+
+	for i := range rows {
+		switch v := c.value(&rows[i]).(type) {
+		case string:
+			binary.Write(&data, binary.LittleEndian, uint32(len(v)))
+			data.WriteString(v)
+		case int64:
+			binary.Write(&data, binary.LittleEndian, v)
+		case float64:
+			binary.Write(&data, binary.LittleEndian, math.Float64bits(v))
+		case time.Time:
+			binary.Write(&data, binary.LittleEndian, v.UnixMicro())
+		}
+	}
+
+	return data.Bytes()
+}
+
+// encodeParquetFooter encodes the FileMetaData describing the schema and chunks
+func encodeParquetFooter(chunks []parquetChunk, numRows int64) []byte {
+	t := newThriftWriter()
+	t.i32(1, parquetFormatVersion)
+
+	// Schema: a root element followed by one element per column
+	t.listBegin(2, thriftStruct, len(chunks)+1)
+	t.beginListStruct()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(chunks)))
+	t.endStruct()
+	for _, chunk := range chunks {
+		t.beginListStruct()
+		t.i32(1, parquetType(chunk.column.kind))
+		t.i32(3, repetitionRequired)
+		t.binary(4, chunk.column.name)
+		switch chunk.column.kind {
+		case kindString:
+			t.i32(6, convertedUTF8)
+		case kindTimestamp:
+			t.i32(6, convertedTimestampMicros)
+		}
+		t.endStruct()
+	}
+
+	t.i64(3, numRows)
+
+	// A single row group holding every column
+	var totalSize int64
+	for _, chunk := range chunks {
+		totalSize += chunk.size
+	}
+
+	t.listBegin(4, thriftStruct, 1)
+	t.beginListStruct()
+	t.listBegin(1, thriftStruct, len(chunks))
+	for _, chunk := range chunks {
+		t.beginListStruct()
+		t.i64(2, chunk.offset)
+		t.beginStruct(3) // meta_data
+		t.i32(1, parquetType(chunk.column.kind))
+		t.listBegin(2, thriftI32, 2)
+		t.listI32(encodingPlain)
+		t.listI32(encodingRLE)
+		t.listBegin(3, thriftBinary, 1)
+		t.listBinary(chunk.column.name)
+		t.i32(4, codecUncompressed)
+		t.i64(5, numRows)
+		t.i64(6, chunk.size)
+		t.i64(7, chunk.size)
+		t.i64(9, chunk.offset)
+		t.endStruct()
+		t.endStruct()
+	}
+	t.i64(2, totalSize)
+	t.i64(3, numRows)
+	t.endStruct()
+
+	t.binary(6, "livecodegit")
+	t.stop()
+
+	return t.buf.Bytes()
+}
+
+// Thrift compact protocol field types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs with the Thrift compact protocol
+type thriftWriter struct {
+	buf       bytes.Buffer
+	lastField int16
+	stack     []int16
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{}
+}
+
+// fieldHeader writes a field header, using the short delta form when possible
+func (t *thriftWriter) fieldHeader(id int16, fieldType byte) {
+	delta := id - t.lastField
+	if delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		t.buf.WriteByte(fieldType)
+		t.varint(zigzag(int64(id)))
+	}
+	t.lastField = id
+}
+
+func (t *thriftWriter) i32(id int16, value int32) {
+	t.fieldHeader(id, thriftI32)
+	t.varint(zigzag(int64(value)))
+}
+
+func (t *thriftWriter) i64(id int16, value int64) {
+	t.fieldHeader(id, thriftI64)
+	t.varint(zigzag(value))
+}
+
+func (t *thriftWriter) binary(id int16, value string) {
+	t.fieldHeader(id, thriftBinary)
+	t.listBinary(value)
+}
+
+// beginStruct starts a struct-typed field; close it with endStruct
+func (t *thriftWriter) beginStruct(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.beginListStruct()
+}
+
+// beginListStruct starts a struct that is an element of a list
+func (t *thriftWriter) beginListStruct() {
+	t.stack = append(t.stack, t.lastField)
+	t.lastField = 0
+}
+
+// endStruct writes the stop byte and returns to the enclosing struct
+func (t *thriftWriter) endStruct() {
+	t.stop()
+	t.lastField = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+// stop ends the top-level struct
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
+
+// listBegin starts a list-typed field of size elements
+func (t *thriftWriter) listBegin(id int16, elementType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elementType)
+	} else {
+		t.buf.WriteByte(0xf0 | elementType)
+		t.varint(uint64(size))
+	}
+}
+
+func (t *thriftWriter) listI32(value int32) {
+	t.varint(zigzag(int64(value)))
+}
+
+func (t *thriftWriter) listBinary(value string) {
+	t.varint(uint64(len(value)))
+	t.buf.WriteString(value)
+}
+
+func (t *thriftWriter) varint(value uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(scratch[:], value)
+	t.buf.Write(scratch[:n])
+}
+
+func zigzag(value int64) uint64 {
+	return uint64((value << 1) ^ (value >> 63))
+}
@@ -0,0 +1,148 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// thriftReader decodes the Thrift compact protocol into maps of field ID to
+// value, enough to check the footer written by WriteParquet
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) varint() uint64 {
+	value, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return value
+}
+
+func (r *thriftReader) zigzag() int64 {
+	value := r.varint()
+	return int64(value>>1) ^ -int64(value&1)
+}
+
+func (r *thriftReader) value(fieldType byte) interface{} {
+	switch fieldType {
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		size := int(r.varint())
+		value := string(r.data[r.pos : r.pos+size])
+		r.pos += size
+		return value
+	case thriftList:
+		header := r.data[r.pos]
+		r.pos++
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.varint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(header & 0x0f)
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	default:
+		panic(fmt.Sprintf("unexpected thrift type %d", fieldType))
+	}
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var last int16
+
+	for {
+		header := r.data[r.pos]
+		r.pos++
+		if header == 0 {
+			return fields
+		}
+
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(header & 0x0f)
+		last = id
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	rows := BuildRows(createTestCommits())
+
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, rows); err != nil {
+		t.Fatalf("Failed to write Parquet: %v", err)
+	}
+
+	data := buf.Bytes()
+	if string(data[:4]) != parquetMagic || string(data[len(data)-4:]) != parquetMagic {
+		t.Fatalf("Expected PAR1 magic at both ends")
+	}
+
+	footerSize := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := (&thriftReader{data: data[len(data)-8-footerSize : len(data)-8]}).readStruct()
+
+	if footer[3].(int64) != 3 {
+		t.Errorf("Expected 3 rows, got %v", footer[3])
+	}
+
+	schema := footer[2].([]interface{})
+	if len(schema) != len(commitColumns)+1 {
+		t.Fatalf("Expected %d schema elements, got %d", len(commitColumns)+1, len(schema))
+	}
+
+	rowGroup := footer[4].([]interface{})[0].(map[int16]interface{})
+	chunks := rowGroup[1].([]interface{})
+
+	// Decode every column's data page and compare it with the rows
+	for i, c := range commitColumns {
+		element := schema[i+1].(map[int16]interface{})
+		if element[4] != c.name {
+			t.Errorf("Expected schema column %s, got %v", c.name, element[4])
+		}
+
+		meta := chunks[i].(map[int16]interface{})[3].(map[int16]interface{})
+		offset := int(meta[9].(int64))
+
+		page := &thriftReader{data: data, pos: offset}
+		header := page.readStruct()
+		values := data[page.pos : page.pos+int(header[3].(int64))]
+
+		if header[5].(map[int16]interface{})[1].(int64) != 3 {
+			t.Errorf("Expected 3 values in column %s", c.name)
+		}
+
+		for j := range rows {
+			var got interface{}
+			switch c.kind {
+			case kindString:
+				size := int(binary.LittleEndian.Uint32(values))
+				got, values = string(values[4:4+size]), values[4+size:]
+			case kindInt64:
+				got, values = int64(binary.LittleEndian.Uint64(values)), values[8:]
+			case kindTimestamp:
+				got, values = int64(binary.LittleEndian.Uint64(values)), values[8:]
+			case kindDouble:
+				got, values = math.Float64frombits(binary.LittleEndian.Uint64(values)), values[8:]
+			case kindBool:
+				got = values[j/8]&(1<<(j%8)) != 0
+			}
+
+			want := c.value(&rows[j])
+			if c.kind == kindTimestamp {
+				want = rows[j].Timestamp.UnixMicro()
+			}
+			if got != want {
+				t.Errorf("Expected %s[%d] = %v, got %v", c.name, j, want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package export
+
+import (
+	"github.com/livecodegit/pkg/core"
+)
+
+// Redaction is a history with the repository's privacy rules applied:
+// private commits are left out and redacted ones lose their code
+type Redaction struct {
+	Commits []*core.Commit
+
+	// Commits left out, mapped to the nearest kept commit before them, and
+	// to the nearest kept commit of their buffer
+	replaced       map[string]string
+	bufferReplaced map[string]string
+}
+
+// Redact applies privacy rules to commits, oldest first. Parents and buffer
+// parents of kept commits skip over the commits left out, so the history
+// and each buffer's lineage stay connected.
+func Redact(commits []*core.Commit, rules *core.PrivacyRules) *Redaction {
+	r := &Redaction{replaced: make(map[string]string), bufferReplaced: make(map[string]string)}
+	if rules.Empty() {
+		r.Commits = commits
+		return r
+	}
+
+	r.Commits = make([]*core.Commit, 0, len(commits))
+	kept := ""
+	keptInBuffer := make(map[string]string)
+	for _, commit := range commits {
+		level := rules.Level(commit)
+		if level == core.PrivacyPrivate {
+			r.replaced[commit.Hash] = kept
+			r.bufferReplaced[commit.Hash] = keptInBuffer[commit.Metadata.Buffer]
+			continue
+		}
+
+		redacted := *commit
+		if parent, replaced := r.replaced[commit.Parent]; replaced {
+			redacted.Parent = parent
+		}
+		if parent, replaced := r.bufferReplaced[commit.BufferParent]; replaced {
+			redacted.BufferParent = parent
+		}
+		if level == core.PrivacyRedacted {
+			redacted.Content = ""
+			redacted.Metadata.ErrorMessage = ""
+		}
+		r.Commits = append(r.Commits, &redacted)
+		kept = commit.Hash
+		keptInBuffer[commit.Metadata.Buffer] = commit.Hash
+	}
+	return r
+}
+
+// Omitted returns how many commits were left out
+func (r *Redaction) Omitted() int {
+	return len(r.replaced)
+}
+
+// Performance returns a copy of performance whose head and markers don't
+// refer to commits left out
+func (r *Redaction) Performance(performance *core.Performance) *core.Performance {
+	if len(r.replaced) == 0 {
+		return performance
+	}
+
+	redacted := *performance
+	if head, replaced := r.replaced[performance.HeadCommit]; replaced {
+		redacted.HeadCommit = head
+	}
+
+	if len(performance.Markers) > 0 {
+		redacted.Markers = make([]core.Marker, len(performance.Markers))
+		for i, marker := range performance.Markers {
+			if _, replaced := r.replaced[marker.Commit]; replaced {
+				marker.Commit = ""
+			}
+			if len(marker.Buffers) > 0 {
+				buffers := make(map[string]string, len(marker.Buffers))
+				for buffer, hash := range marker.Buffers {
+					if _, replaced := r.replaced[hash]; !replaced {
+						buffers[buffer] = hash
+					}
+				}
+				marker.Buffers = buffers
+			}
+			redacted.Markers[i] = marker
+		}
+	}
+
+	return &redacted
+}
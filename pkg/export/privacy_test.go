@@ -0,0 +1,72 @@
+package export
+
+import (
+	"testing"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+func TestRedact(t *testing.T) {
+	commits := []*core.Commit{
+		{Hash: "a", Content: "d1 $ s \"bd\"", Metadata: core.ExecutionMetadata{Buffer: "d1"}},
+		{Hash: "b", Parent: "a", Content: "experiment", Metadata: core.ExecutionMetadata{Buffer: "scratch"}},
+		{Hash: "c", Parent: "b", BufferParent: "a", Content: "d1 $ s \"bd sn\"", Metadata: core.ExecutionMetadata{Buffer: "d1", ErrorMessage: "oops"}},
+		{Hash: "d", Parent: "c", BufferParent: "b", Content: "kept", Metadata: core.ExecutionMetadata{Buffer: "scratch"}},
+	}
+	rules := &core.PrivacyRules{
+		Buffers: map[string]core.PrivacyLevel{"scratch": core.PrivacyPrivate},
+		Commits: map[string]core.PrivacyLevel{"c": core.PrivacyRedacted, "d": core.PrivacyPublic},
+	}
+
+	redaction := Redact(commits, rules)
+	if len(redaction.Commits) != 3 || redaction.Omitted() != 1 {
+		t.Fatalf("Expected 3 commits with 1 left out, got %d and %d", len(redaction.Commits), redaction.Omitted())
+	}
+
+	redacted := redaction.Commits[1]
+	if redacted.Hash != "c" || redacted.Parent != "a" {
+		t.Errorf("Expected c to follow a, got %s after %s", redacted.Hash, redacted.Parent)
+	}
+	if redacted.Content != "" || redacted.Metadata.ErrorMessage != "" {
+		t.Errorf("Expected c without code, got '%s' (%s)", redacted.Content, redacted.Metadata.ErrorMessage)
+	}
+	if commits[2].Content == "" || commits[2].Parent != "b" {
+		t.Errorf("Expected the original commit to be left alone")
+	}
+	if redaction.Commits[2].Hash != "d" || redaction.Commits[2].Content != "kept" {
+		t.Errorf("Expected d to override its buffer, got %+v", redaction.Commits[2])
+	}
+	if redacted.BufferParent != "a" || redaction.Commits[2].BufferParent != "" {
+		t.Errorf("Expected buffer parents to skip b, got %q and %q", redacted.BufferParent, redaction.Commits[2].BufferParent)
+	}
+
+	performance := &core.Performance{
+		HeadCommit: "b",
+		Markers: []core.Marker{
+			{Label: "drop", Commit: "b", Buffers: map[string]string{"d1": "a", "scratch": "b"}},
+		},
+	}
+	shared := redaction.Performance(performance)
+	if shared.HeadCommit != "a" {
+		t.Errorf("Expected head a, got %s", shared.HeadCommit)
+	}
+	marker := shared.Markers[0]
+	if marker.Commit != "" || len(marker.Buffers) != 1 || marker.Buffers["d1"] != "a" {
+		t.Errorf("Expected the marker to lose the private commit, got %+v", marker)
+	}
+	if performance.Markers[0].Commit != "b" {
+		t.Errorf("Expected the original performance to be left alone")
+	}
+
+	if all := Redact(commits, &core.PrivacyRules{}); len(all.Commits) != 4 || all.Omitted() != 0 {
+		t.Errorf("Expected no rules to keep every commit")
+	}
+
+	strict := Redact(commits, rules.Strict())
+	if len(strict.Commits) != 2 || strict.Commits[1].Hash != "d" || strict.Commits[1].Parent != "a" {
+		t.Errorf("Expected strict rules to leave out the redacted commit too, got %d commits", len(strict.Commits))
+	}
+	if rules.Commits["c"] != core.PrivacyRedacted {
+		t.Errorf("Expected the original rules to be left alone")
+	}
+}
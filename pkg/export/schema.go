@@ -0,0 +1,190 @@
+package export
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// SchemaVersion is the version of the published export schema
+const SchemaVersion = "1.3.0"
+
+// SchemaID identifies the export schema referenced by "$schema" in exports
+const SchemaID = "urn:livecodegit:export:v1"
+
+//go:embed schema/export-v1.schema.json
+var exportSchema []byte
+
+// Schema returns the JSON Schema document describing JSON exports
+func Schema() []byte {
+	return exportSchema
+}
+
+// Validate checks a JSON document against the export schema. It supports the
+// subset of JSON Schema the export schema uses: type, required, properties,
+// additionalProperties, items and local $ref.
+func Validate(data []byte) error {
+	var schema map[string]interface{}
+	if err := json.Unmarshal(exportSchema, &schema); err != nil {
+		return fmt.Errorf("failed to parse export schema: %w", err)
+	}
+
+	var instance interface{}
+	if err := json.Unmarshal(data, &instance); err != nil {
+		return fmt.Errorf("failed to parse export: %w", err)
+	}
+
+	v := &validator{root: schema}
+	return v.validate(schema, instance, "$")
+}
+
+// validator walks an instance alongside the schema
+type validator struct {
+	root map[string]interface{}
+}
+
+func (v *validator) validate(schema map[string]interface{}, instance interface{}, path string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, err := v.resolve(ref)
+		if err != nil {
+			return err
+		}
+		return v.validate(resolved, instance, path)
+	}
+
+	if expected, ok := schema["type"].(string); ok {
+		if !matchesType(expected, instance) {
+			return fmt.Errorf("%s: expected %s, got %s", path, expected, typeName(instance))
+		}
+	}
+
+	switch value := instance.(type) {
+	case map[string]interface{}:
+		return v.validateObject(schema, value, path)
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range value {
+				if err := v.validate(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (v *validator) validateObject(schema map[string]interface{}, object map[string]interface{}, path string) error {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if _, exists := object[name.(string)]; !exists {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	// Visit properties in a stable order so errors are reproducible
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		propertyPath := path + "." + name
+		if propertySchema, ok := properties[name].(map[string]interface{}); ok {
+			if err := v.validate(propertySchema, object[name], propertyPath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: unexpected property", propertyPath)
+			}
+		case map[string]interface{}:
+			if err := v.validate(additional, object[name], propertyPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolve looks up a local reference such as "#/definitions/commit"
+func (v *validator) resolve(ref string) (map[string]interface{}, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported schema reference %q", ref)
+	}
+
+	var node interface{} = v.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid schema reference %q", ref)
+		}
+		node = object[part]
+	}
+
+	resolved, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid schema reference %q", ref)
+	}
+	return resolved, nil
+}
+
+// matchesType reports whether a decoded JSON value has the given schema type
+func matchesType(expected string, instance interface{}) bool {
+	switch expected {
+	case "object":
+		_, ok := instance.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := instance.([]interface{})
+		return ok
+	case "string":
+		_, ok := instance.(string)
+		return ok
+	case "boolean":
+		_, ok := instance.(bool)
+		return ok
+	case "number":
+		_, ok := instance.(float64)
+		return ok
+	case "integer":
+		n, ok := instance.(float64)
+		return ok && n == math.Trunc(n)
+	case "null":
+		return instance == nil
+	default:
+		return true
+	}
+}
+
+// typeName describes a decoded JSON value for error messages
+func typeName(instance interface{}) string {
+	switch instance.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", instance)
+	}
+}
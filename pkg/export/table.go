@@ -0,0 +1,116 @@
+package export
+
+import (
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/diff"
+)
+
+// CommitRow is one commit flattened into a table row for data analysis
+type CommitRow struct {
+	Hash           string
+	Parent         string
+	Timestamp      time.Time
+	ElapsedSeconds float64 // since the first exported commit
+	Author         string
+	Message        string
+	Buffer         string
+	Language       string
+	Environment    string
+	Success        bool
+	ErrorMessage   string
+	BPM            float64
+	BeatsFromStart int64
+	ContentBytes   int64
+	ContentLines   int64
+	LinesAdded     int64 // compared with the previous commit in the same buffer
+	LinesRemoved   int64
+}
+
+type columnKind int
+
+const (
+	kindString columnKind = iota
+	kindInt64
+	kindDouble
+	kindBool
+	kindTimestamp
+)
+
+// column describes one column shared by the tabular export formats
+type column struct {
+	name  string
+	kind  columnKind
+	value func(row *CommitRow) interface{}
+}
+
+// commitColumns lists the columns of the tabular exports in order
+var commitColumns = []column{
+	{"hash", kindString, func(r *CommitRow) interface{} { return r.Hash }},
+	{"parent", kindString, func(r *CommitRow) interface{} { return r.Parent }},
+	{"timestamp", kindTimestamp, func(r *CommitRow) interface{} { return r.Timestamp }},
+	{"elapsed_seconds", kindDouble, func(r *CommitRow) interface{} { return r.ElapsedSeconds }},
+	{"author", kindString, func(r *CommitRow) interface{} { return r.Author }},
+	{"message", kindString, func(r *CommitRow) interface{} { return r.Message }},
+	{"buffer", kindString, func(r *CommitRow) interface{} { return r.Buffer }},
+	{"language", kindString, func(r *CommitRow) interface{} { return r.Language }},
+	{"environment", kindString, func(r *CommitRow) interface{} { return r.Environment }},
+	{"success", kindBool, func(r *CommitRow) interface{} { return r.Success }},
+	{"error_message", kindString, func(r *CommitRow) interface{} { return r.ErrorMessage }},
+	{"bpm", kindDouble, func(r *CommitRow) interface{} { return r.BPM }},
+	{"beats_from_start", kindInt64, func(r *CommitRow) interface{} { return r.BeatsFromStart }},
+	{"content_bytes", kindInt64, func(r *CommitRow) interface{} { return r.ContentBytes }},
+	{"content_lines", kindInt64, func(r *CommitRow) interface{} { return r.ContentLines }},
+	{"lines_added", kindInt64, func(r *CommitRow) interface{} { return r.LinesAdded }},
+	{"lines_removed", kindInt64, func(r *CommitRow) interface{} { return r.LinesRemoved }},
+}
+
+// ColumnNames returns the column names of the tabular exports
+func ColumnNames() []string {
+	names := make([]string, len(commitColumns))
+	for i, c := range commitColumns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// BuildRows flattens commits, oldest first, into table rows. Diff stats
+// compare each commit with the previous commit in the same buffer, since
+// livecoders evaluate buffers independently.
+func BuildRows(commits []*core.Commit) []CommitRow {
+	rows := make([]CommitRow, 0, len(commits))
+	previous := make(map[string][]string) // buffer -> lines of its last commit
+
+	for _, commit := range commits {
+		lines := diff.Lines(commit.Content)
+		added, removed := diff.Stats(previous[commit.Metadata.Buffer], lines)
+		previous[commit.Metadata.Buffer] = lines
+
+		row := CommitRow{
+			Hash:           commit.Hash,
+			Parent:         commit.Parent,
+			Timestamp:      commit.Timestamp,
+			Author:         commit.Author,
+			Message:        commit.Message,
+			Buffer:         commit.Metadata.Buffer,
+			Language:       commit.Metadata.Language,
+			Environment:    commit.Metadata.Environment,
+			Success:        commit.Metadata.Success,
+			ErrorMessage:   commit.Metadata.ErrorMessage,
+			BPM:            commit.Metadata.BPM,
+			BeatsFromStart: commit.Metadata.BeatsFromStart,
+			ContentBytes:   int64(len(commit.Content)),
+			ContentLines:   int64(len(lines)),
+			LinesAdded:     int64(added),
+			LinesRemoved:   int64(removed),
+		}
+		if len(rows) > 0 {
+			row.ElapsedSeconds = commit.Timestamp.Sub(rows[0].Timestamp).Seconds()
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
@@ -0,0 +1,87 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+// createTestCommits returns a short history across two buffers, oldest first
+func createTestCommits() []*core.Commit {
+	start := time.Date(2024, 5, 1, 21, 0, 0, 0, time.UTC)
+
+	return []*core.Commit{
+		{Hash: "a1", Timestamp: start, Message: "Kick", Author: "livecoder", Content: "d1 $ s \"bd\"\n",
+			Metadata: core.ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}},
+		{Hash: "b2", Parent: "a1", Timestamp: start.Add(1500 * time.Millisecond), Message: "Hats", Author: "livecoder", Content: "d2 $ s \"hh*8\"",
+			Metadata: core.ExecutionMetadata{Buffer: "d2", Language: "tidal", Success: true, BPM: 128}},
+		{Hash: "c3", Parent: "b2", Timestamp: start.Add(4 * time.Second), Message: "Kick, \"fuller\"", Author: "livecoder",
+			Content:  "d1 $ stack [s \"bd*2\",\n  s \"sn\"]\n",
+			Metadata: core.ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: false, ErrorMessage: "parse error", BeatsFromStart: 8}},
+	}
+}
+
+func TestBuildRows(t *testing.T) {
+	rows := BuildRows(createTestCommits())
+
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(rows))
+	}
+
+	if rows[1].ElapsedSeconds != 1.5 || rows[2].ElapsedSeconds != 4 {
+		t.Errorf("Expected elapsed seconds 1.5 and 4, got %v and %v", rows[1].ElapsedSeconds, rows[2].ElapsedSeconds)
+	}
+
+	// The first commit of a buffer adds all of its lines
+	if rows[0].LinesAdded != 1 || rows[0].LinesRemoved != 0 {
+		t.Errorf("Expected +1 -0 for the first d1 commit, got +%d -%d", rows[0].LinesAdded, rows[0].LinesRemoved)
+	}
+
+	// The third commit is compared with the first, not the d2 commit before it
+	if rows[2].LinesAdded != 2 || rows[2].LinesRemoved != 1 {
+		t.Errorf("Expected +2 -1 against the previous d1 commit, got +%d -%d", rows[2].LinesAdded, rows[2].LinesRemoved)
+	}
+
+	if rows[2].ContentLines != 2 || rows[1].ContentBytes != 13 {
+		t.Errorf("Expected 2 lines and 13 bytes, got %d lines and %d bytes", rows[2].ContentLines, rows[1].ContentBytes)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, BuildRows(createTestCommits())); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV back: %v", err)
+	}
+
+	if len(records) != 4 {
+		t.Fatalf("Expected header and 3 rows, got %d records", len(records))
+	}
+
+	header := make(map[string]int)
+	for i, name := range records[0] {
+		header[name] = i
+	}
+
+	row := records[3]
+	expected := map[string]string{
+		"hash":          "c3",
+		"timestamp":     "2024-05-01T21:00:04Z",
+		"message":       "Kick, \"fuller\"",
+		"success":       "false",
+		"error_message": "parse error",
+		"lines_added":   "2",
+	}
+	for name, value := range expected {
+		if row[header[name]] != value {
+			t.Errorf("Expected %s '%s', got '%s'", name, value, row[header[name]])
+		}
+	}
+}
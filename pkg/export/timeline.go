@@ -0,0 +1,276 @@
+package export
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+// Timeline is a performance laid out as a document: commits and the markers
+// made between them, in the order they happened
+type Timeline struct {
+	Performance *core.Performance
+	Duration    time.Duration
+	Buffers     []TimelineBuffer
+	Entries     []TimelineEntry
+}
+
+// TimelineBuffer summarizes one buffer of a timeline
+type TimelineBuffer struct {
+	Name     string
+	Language string
+	Commits  int
+	Errors   int
+}
+
+// TimelineEntry is a commit or a marker at Offset into the performance
+type TimelineEntry struct {
+	Offset time.Duration
+	Time   time.Time
+	Commit *core.Commit // nil for markers
+	Marker string
+}
+
+// BuildTimeline lays out a performance's commits and markers. Planned
+// markers from a template are left out; only what happened is shown.
+func BuildTimeline(performance *core.Performance, commits []*core.Commit) *Timeline {
+	timeline := &Timeline{Performance: performance}
+
+	end := performance.EndTime
+	if end.IsZero() && len(commits) > 0 {
+		end = commits[len(commits)-1].Timestamp
+	}
+	if end.After(performance.StartTime) {
+		timeline.Duration = end.Sub(performance.StartTime)
+	}
+
+	buffers := make(map[string]*TimelineBuffer)
+	for _, commit := range commits {
+		timeline.Entries = append(timeline.Entries, TimelineEntry{
+			Offset: commit.Timestamp.Sub(performance.StartTime),
+			Time:   commit.Timestamp,
+			Commit: commit,
+		})
+
+		buffer, exists := buffers[commit.Metadata.Buffer]
+		if !exists {
+			buffer = &TimelineBuffer{Name: commit.Metadata.Buffer}
+			buffers[commit.Metadata.Buffer] = buffer
+		}
+		buffer.Commits++
+		if commit.Metadata.Language != "" {
+			buffer.Language = commit.Metadata.Language
+		}
+		if !commit.Metadata.Success {
+			buffer.Errors++
+		}
+	}
+
+	for _, marker := range performance.Markers {
+		if marker.Planned {
+			continue
+		}
+		timeline.Entries = append(timeline.Entries, TimelineEntry{
+			Offset: marker.Time.Sub(performance.StartTime),
+			Time:   marker.Time,
+			Marker: marker.Label,
+		})
+	}
+
+	// A marker made at the same moment as a commit comes first, as a heading
+	sort.SliceStable(timeline.Entries, func(i, j int) bool {
+		a, b := timeline.Entries[i], timeline.Entries[j]
+		if a.Time.Equal(b.Time) {
+			return a.Commit == nil && b.Commit != nil
+		}
+		return a.Time.Before(b.Time)
+	})
+
+	for _, buffer := range buffers {
+		timeline.Buffers = append(timeline.Buffers, *buffer)
+	}
+	sort.Slice(timeline.Buffers, func(i, j int) bool {
+		return timeline.Buffers[i].Name < timeline.Buffers[j].Name
+	})
+
+	return timeline
+}
+
+// Commits returns the number of commits in the timeline
+func (t *Timeline) Commits() int {
+	count := 0
+	for _, entry := range t.Entries {
+		if entry.Commit != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// formatOffset renders a time into a performance as +mm:ss, or +h:mm:ss
+func formatOffset(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	d = d.Round(time.Second)
+	hours := int(d / time.Hour)
+	minutes := int(d/time.Minute) % 60
+	seconds := int(d/time.Second) % 60
+	if hours > 0 {
+		return fmt.Sprintf("+%d:%02d:%02d", hours, minutes, seconds)
+	}
+	return fmt.Sprintf("+%02d:%02d", minutes, seconds)
+}
+
+// summary describes when and by whom a performance was played
+func (t *Timeline) summary() string {
+	performance := t.Performance
+	parts := []string{
+		fmt.Sprintf("Performed %s (%s)", performance.StartTime.Format("2006-01-02 15:04"), t.Duration.Round(time.Second)),
+	}
+	if performance.Author != "" {
+		parts = append(parts, "by "+performance.Author)
+	}
+	parts = append(parts, fmt.Sprintf("%d commits across %d buffers", t.Commits(), len(t.Buffers)))
+	return strings.Join(parts, " · ")
+}
+
+// WriteMarkdown writes the timeline as Markdown, with fenced code blocks
+// tagged with a language most renderers highlight
+func WriteMarkdown(w io.Writer, t *Timeline) error {
+	var b strings.Builder
+	performance := t.Performance
+
+	fmt.Fprintf(&b, "# %s\n\n", performance.Name)
+	if performance.Description != "" {
+		fmt.Fprintf(&b, "%s\n\n", performance.Description)
+	}
+	fmt.Fprintf(&b, "%s\n\n", t.summary())
+
+	if len(t.Buffers) > 0 {
+		b.WriteString("| Buffer | Language | Commits | Errors |\n")
+		b.WriteString("| --- | --- | ---: | ---: |\n")
+		for _, buffer := range t.Buffers {
+			fmt.Fprintf(&b, "| `%s` | %s | %d | %d |\n", buffer.Name, buffer.Language, buffer.Commits, buffer.Errors)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("## Timeline\n")
+	for _, entry := range t.Entries {
+		if entry.Commit == nil {
+			fmt.Fprintf(&b, "\n### %s · %s\n", formatOffset(entry.Offset), entry.Marker)
+			continue
+		}
+
+		commit := entry.Commit
+		fmt.Fprintf(&b, "\n**%s** `%s` %s", formatOffset(entry.Offset), commit.Metadata.Buffer, commit.Message)
+		if !commit.Metadata.Success {
+			b.WriteString(" — ⚠️ **error**")
+			if commit.Metadata.ErrorMessage != "" {
+				fmt.Fprintf(&b, ": %s", strings.Join(strings.Fields(commit.Metadata.ErrorMessage), " "))
+			}
+		}
+		b.WriteString("\n\n")
+
+		// The fence must be longer than any backtick run in the code
+		fence := "```"
+		for strings.Contains(commit.Content, fence) {
+			fence += "`"
+		}
+		fmt.Fprintf(&b, "%s%s\n%s\n%s\n", fence, markdownLanguages[strings.ToLower(commit.Metadata.Language)],
+			strings.TrimRight(commit.Content, "\n"), fence)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteHTML writes the timeline as a self-contained HTML page with
+// highlighted code, ready to embed or publish
+func WriteHTML(w io.Writer, t *Timeline) error {
+	type htmlEntry struct {
+		Offset  string
+		Time    string
+		Marker  string
+		Commit  *core.Commit
+		Code    template.HTML
+		Failure string
+	}
+
+	entries := make([]htmlEntry, 0, len(t.Entries))
+	for _, entry := range t.Entries {
+		e := htmlEntry{
+			Offset: formatOffset(entry.Offset),
+			Time:   entry.Time.Format("15:04:05"),
+			Marker: entry.Marker,
+			Commit: entry.Commit,
+		}
+		if entry.Commit != nil {
+			e.Code = template.HTML(highlightHTML(strings.TrimRight(entry.Commit.Content, "\n"), entry.Commit.Metadata.Language))
+			if !entry.Commit.Metadata.Success {
+				e.Failure = "error"
+				if entry.Commit.Metadata.ErrorMessage != "" {
+					e.Failure += ": " + entry.Commit.Metadata.ErrorMessage
+				}
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	return timelineTemplate.Execute(w, map[string]interface{}{
+		"Performance": t.Performance,
+		"Summary":     t.summary(),
+		"Buffers":     t.Buffers,
+		"Entries":     entries,
+	})
+}
+
+var timelineTemplate = template.Must(template.New("timeline").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Performance.Name}}</title>
+<style>
+  body { font-family: system-ui, sans-serif; max-width: 52rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+  table { border-collapse: collapse; margin: 1rem 0; }
+  th, td { padding: 0.2rem 0.8rem; border-bottom: 1px solid #ddd; text-align: left; }
+  .summary { color: #666; }
+  .marker { margin: 2rem 0 0.5rem; border-left: 4px solid #c50; padding-left: 0.6rem; }
+  .commit { margin: 1rem 0; }
+  .commit header { font-size: 0.9rem; }
+  .offset { font-family: ui-monospace, monospace; color: #36c; }
+  .buffer { font-family: ui-monospace, monospace; background: #eee; padding: 0 0.3rem; border-radius: 3px; }
+  .failure { color: #c00; font-weight: bold; }
+  .commit.failed pre { border-left: 4px solid #c00; }
+  pre { background: #f6f6f4; padding: 0.8rem; overflow-x: auto; margin: 0.3rem 0; }
+  .hl-comment { color: #888; font-style: italic; }
+  .hl-string { color: #080; }
+  .hl-number { color: #a0a; }
+  .hl-symbol { color: #a50; }
+  .hl-keyword { color: #05a; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>{{.Performance.Name}}</h1>
+{{with .Performance.Description}}<p>{{.}}</p>
+{{end}}<p class="summary">{{.Summary}}</p>
+{{if .Buffers}}<table>
+<tr><th>Buffer</th><th>Language</th><th>Commits</th><th>Errors</th></tr>
+{{range .Buffers}}<tr><td><span class="buffer">{{.Name}}</span></td><td>{{.Language}}</td><td>{{.Commits}}</td><td>{{.Errors}}</td></tr>
+{{end}}</table>
+{{end}}<h2>Timeline</h2>
+{{range .Entries}}{{if .Commit}}<section class="commit{{if .Failure}} failed{{end}}">
+<header><span class="offset" title="{{.Time}}">{{.Offset}}</span> <span class="buffer">{{.Commit.Metadata.Buffer}}</span> {{.Commit.Message}}{{with .Failure}} <span class="failure">{{.}}</span>{{end}}</header>
+<pre><code class="language-{{.Commit.Metadata.Language}}">{{.Code}}</code></pre>
+</section>
+{{else}}<h3 class="marker"><span class="offset" title="{{.Time}}">{{.Offset}}</span> {{.Marker}}</h3>
+{{end}}{{end}}</body>
+</html>
+`))
@@ -0,0 +1,116 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+func createTestTimeline() *Timeline {
+	commits := createTestCommits()
+	start := commits[0].Timestamp.Add(-2 * time.Second)
+
+	performance := &core.Performance{
+		ID:        "perf-1",
+		Name:      "Algorave <2024>",
+		StartTime: start,
+		EndTime:   start.Add(time.Minute),
+		Author:    "livecoder",
+		Markers: []core.Marker{
+			{Label: "drop", Time: commits[1].Timestamp},
+			{Label: "outro", Time: start.Add(40 * time.Minute), Planned: true},
+		},
+	}
+
+	return BuildTimeline(performance, commits)
+}
+
+func TestBuildTimeline(t *testing.T) {
+	timeline := createTestTimeline()
+
+	if timeline.Duration != time.Minute {
+		t.Errorf("Expected duration 1m, got %v", timeline.Duration)
+	}
+	if timeline.Commits() != 3 {
+		t.Errorf("Expected 3 commits, got %d", timeline.Commits())
+	}
+
+	// The marker made with the second commit heads it; planned markers are left out
+	if len(timeline.Entries) != 4 {
+		t.Fatalf("Expected 4 entries, got %d", len(timeline.Entries))
+	}
+	if timeline.Entries[1].Marker != "drop" || timeline.Entries[2].Commit.Hash != "b2" {
+		t.Errorf("Expected marker 'drop' before commit b2, got %+v and %+v", timeline.Entries[1], timeline.Entries[2])
+	}
+	if timeline.Entries[0].Offset != 2*time.Second {
+		t.Errorf("Expected first commit at +2s, got %v", timeline.Entries[0].Offset)
+	}
+
+	if len(timeline.Buffers) != 2 || timeline.Buffers[0] != (TimelineBuffer{Name: "d1", Language: "tidal", Commits: 2, Errors: 1}) {
+		t.Errorf("Expected d1 with 2 commits and 1 error, got %+v", timeline.Buffers)
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteMarkdown(&buf, createTestTimeline()); err != nil {
+		t.Fatalf("Failed to write Markdown: %v", err)
+	}
+	output := buf.String()
+
+	for _, expected := range []string{
+		"# Algorave <2024>\n",
+		"3 commits across 2 buffers",
+		"| `d1` | tidal | 2 | 1 |",
+		"### +00:04 · drop",
+		"**+00:02** `d1` Kick\n\n```haskell\nd1 $ s \"bd\"\n```\n",
+		"**error**: parse error",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected Markdown to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, createTestTimeline()); err != nil {
+		t.Fatalf("Failed to write HTML: %v", err)
+	}
+	output := buf.String()
+
+	for _, expected := range []string{
+		"<title>Algorave &lt;2024&gt;</title>",
+		`<h3 class="marker">`,
+		`<span class="hl-string">&#34;bd&#34;</span>`,
+		`<section class="commit failed">`,
+		`<span class="failure">error: parse error</span>`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected HTML to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestHighlightHTML(t *testing.T) {
+	tests := []struct {
+		code, language, expected string
+	}{
+		{`play 60 # kick`, "sonicpi",
+			`<span class="hl-keyword">play</span> <span class="hl-number">60</span> <span class="hl-comment"># kick</span>`},
+		{`use_synth :tb303`, "sonicpi",
+			`<span class="hl-keyword">use_synth</span> <span class="hl-symbol">:tb303</span>`},
+		{`d1 $ s "bd*2" -- <b>`, "tidal",
+			`d1 <span class="hl-keyword">$</span> s <span class="hl-string">&#34;bd*2&#34;</span> <span class="hl-comment">-- &lt;b&gt;</span>`},
+		{`x < 1`, "unknown", `x &lt; 1`},
+	}
+
+	for _, test := range tests {
+		if got := highlightHTML(test.code, test.language); got != test.expected {
+			t.Errorf("Expected %s highlighted as\n%s\ngot\n%s", test.language, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Package importer reads livecoding history kept in other tools and turns it
+// into LiveCodeGit commits
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/export"
+)
+
+// GitHistory is a Git repository's history synthesized as LiveCodeGit commits
+type GitHistory struct {
+	// Commits holds one commit per livecoding file changed by each Git
+	// commit, oldest first. Hashes and parents are assigned on import.
+	Commits []*core.Commit
+
+	// Tags maps each Git tag to the last commit synthesized for its target
+	Tags map[string]*core.Commit
+}
+
+// ReadGit reads the first-parent history of rev in the Git repository at dir.
+// Each added or modified file whose extension names a livecoding language
+// becomes a commit of the buffer named by its path without the extension,
+// keeping the Git author, time and message. Commits written by 'lcg export
+// git' get their execution metadata back from their trailers.
+func ReadGit(dir, rev string) (*GitHistory, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+
+	output, err := git(dir, "rev-list", "--reverse", "--first-parent", rev)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &GitHistory{Tags: make(map[string]*core.Commit)}
+	last := make(map[string]*core.Commit) // Git commit -> last commit synthesized up to it
+	var previous string
+	var latest *core.Commit
+
+	for _, sha := range strings.Fields(string(output)) {
+		commits, err := readGitCommit(dir, previous, sha)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit %s: %w", sha, err)
+		}
+		history.Commits = append(history.Commits, commits...)
+		if len(commits) > 0 {
+			latest = commits[len(commits)-1]
+		}
+		if latest != nil {
+			last[sha] = latest
+		}
+		previous = sha
+	}
+
+	tags, err := git(dir, "for-each-ref", "--format=%(refname:short) %(objectname) %(*objectname)", "refs/tags")
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(tags)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		// Annotated tags point at their commit through the tag object
+		target := fields[len(fields)-1]
+		if commit, exists := last[target]; exists {
+			history.Tags[fields[0]] = commit
+		}
+	}
+
+	return history, nil
+}
+
+// readGitCommit synthesizes the commits of the files sha changed since
+// parent, or since nothing when parent is empty
+func readGitCommit(dir, parent, sha string) ([]*core.Commit, error) {
+	header, err := git(dir, "show", "-s", "--format=%an%x00%ae%x00%aI%x00%B", sha)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.SplitN(string(header), "\x00", 4)
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("unexpected commit header")
+	}
+	timestamp, err := time.Parse(time.RFC3339, fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid author date %q: %w", fields[2], err)
+	}
+	message, trailers := splitTrailers(fields[3])
+
+	diffArgs := []string{"diff-tree", "--no-commit-id", "-r", "-z", "--name-status"}
+	if parent == "" {
+		diffArgs = append(diffArgs, "--root", sha)
+	} else {
+		diffArgs = append(diffArgs, parent, sha)
+	}
+	diff, err := git(dir, diffArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Name-status output alternates a status and a path
+	var paths []string
+	entries := strings.Split(strings.TrimRight(string(diff), "\x00"), "\x00")
+	for i := 0; i+1 < len(entries); i += 2 {
+		if status := entries[i]; status == "A" || status == "M" {
+			paths = append(paths, entries[i+1])
+		}
+	}
+
+	// Trailers describe the single buffer an exported commit changed
+	useTrailers := len(trailers) > 0 && len(paths) == 1
+
+	var commits []*core.Commit
+	for _, file := range paths {
+		metadata := core.ExecutionMetadata{
+			Buffer:   strings.TrimSuffix(file, path.Ext(file)),
+			Language: core.LanguageFromExtension(file),
+			Success:  true,
+		}
+		if useTrailers {
+			applyTrailers(&metadata, trailers)
+		}
+		if metadata.Language == "unknown" {
+			continue
+		}
+
+		content, err := git(dir, "cat-file", "blob", sha+":"+file)
+		if err != nil {
+			return nil, err
+		}
+
+		commits = append(commits, &core.Commit{
+			Timestamp:   timestamp,
+			Message:     message,
+			Author:      fields[0],
+			AuthorEmail: fields[1],
+			Content:     string(content),
+			Metadata:    metadata,
+		})
+	}
+
+	return commits, nil
+}
+
+// splitTrailers separates the Livecode-* trailers written by 'lcg export
+// git' from the rest of a commit message
+func splitTrailers(message string) (string, map[string]string) {
+	lines := strings.Split(strings.TrimRight(message, "\n"), "\n")
+	trailers := make(map[string]string)
+
+	end := len(lines)
+	for end > 0 {
+		key, value, found := strings.Cut(lines[end-1], ": ")
+		if !found || !strings.HasPrefix(key, "Livecode-") {
+			break
+		}
+		trailers[key] = value
+		end--
+	}
+
+	return strings.TrimSpace(strings.Join(lines[:end], "\n")), trailers
+}
+
+// applyTrailers restores the execution metadata recorded in trailers
+func applyTrailers(metadata *core.ExecutionMetadata, trailers map[string]string) {
+	if buffer := trailers[export.TrailerBuffer]; buffer != "" {
+		metadata.Buffer = buffer
+	}
+	if language := trailers[export.TrailerLanguage]; language != "" {
+		metadata.Language = language
+	}
+	if success, err := strconv.ParseBool(trailers[export.TrailerSuccess]); err == nil {
+		metadata.Success = success
+	}
+	metadata.ErrorMessage = trailers[export.TrailerError]
+	if bpm, err := strconv.ParseFloat(trailers[export.TrailerBPM], 64); err == nil {
+		metadata.BPM = bpm
+	}
+	if beats, err := strconv.ParseInt(trailers[export.TrailerBeats], 10, 64); err == nil {
+		metadata.BeatsFromStart = beats
+	}
+	metadata.Environment = trailers[export.TrailerEnvironment]
+}
+
+// git runs a git command in dir and returns its output
+func git(dir string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
@@ -0,0 +1,161 @@
+package importer
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/export"
+)
+
+// createGitRepo creates an empty Git repository, skipping without git
+func createGitRepo(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir, err := os.MkdirTemp("", "lcg-git-import-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	runGit(t, dir, nil, "init", "-q")
+	return dir
+}
+
+// runGit runs a git command in dir with a fixed identity
+func runGit(t *testing.T, dir string, env []string, args ...string) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=alice", "GIT_AUTHOR_EMAIL=alice@example.com",
+		"GIT_COMMITTER_NAME=alice", "GIT_COMMITTER_EMAIL=alice@example.com")
+	cmd.Env = append(cmd.Env, env...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v: %s", args, err, output)
+	}
+}
+
+// commitFiles writes files and commits them at date
+func commitFiles(t *testing.T, dir, message, date string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	runGit(t, dir, nil, "add", "-A")
+	runGit(t, dir, []string{"GIT_AUTHOR_DATE=" + date, "GIT_COMMITTER_DATE=" + date}, "commit", "-q", "-m", message)
+}
+
+func TestReadGit(t *testing.T) {
+	dir := createGitRepo(t)
+	defer os.RemoveAll(dir)
+
+	commitFiles(t, dir, "Start the set", "2024-05-01T21:00:00+02:00", map[string]string{
+		"README.md":      "notes",
+		"drums.tidal":    `d1 $ s "bd"`,
+		"synths/lead.rb": "play 60",
+	})
+	commitFiles(t, dir, "Faster kick", "2024-05-01T21:02:00+02:00", map[string]string{
+		"drums.tidal": `d1 $ s "bd*4"`,
+	})
+	runGit(t, dir, nil, "tag", "drop")
+
+	history, err := ReadGit(dir, "")
+	if err != nil {
+		t.Fatalf("Failed to read repository: %v", err)
+	}
+
+	// README.md is not a livecoding file
+	if len(history.Commits) != 3 {
+		t.Fatalf("Expected 3 commits, got %d", len(history.Commits))
+	}
+
+	first := history.Commits[0]
+	if first.Metadata.Buffer != "drums" || first.Metadata.Language != "tidal" {
+		t.Errorf("Expected drums in tidal, got %s in %s", first.Metadata.Buffer, first.Metadata.Language)
+	}
+	if first.Author != "alice" || first.AuthorEmail != "alice@example.com" {
+		t.Errorf("Expected Git author, got %s <%s>", first.Author, first.AuthorEmail)
+	}
+	if expected := time.Date(2024, 5, 1, 19, 0, 0, 0, time.UTC); !first.Timestamp.Equal(expected) {
+		t.Errorf("Expected timestamp %v, got %v", expected, first.Timestamp)
+	}
+	if first.Message != "Start the set" || !first.Metadata.Success {
+		t.Errorf("Expected successful commit with Git message, got %q", first.Message)
+	}
+
+	if lead := history.Commits[1]; lead.Metadata.Buffer != "synths/lead" || lead.Metadata.Language != "sonicpi" {
+		t.Errorf("Expected synths/lead in sonicpi, got %s in %s", lead.Metadata.Buffer, lead.Metadata.Language)
+	}
+
+	last := history.Commits[2]
+	if last.Content != `d1 $ s "bd*4"` || last.Message != "Faster kick" {
+		t.Errorf("Expected faster kick, got %q: %q", last.Message, last.Content)
+	}
+	if history.Tags["drop"] != last {
+		t.Errorf("Expected drop tag on the last commit")
+	}
+}
+
+func TestReadGitRestoresExportedMetadata(t *testing.T) {
+	dir := createGitRepo(t)
+	defer os.RemoveAll(dir)
+
+	start := time.Date(2024, 5, 1, 21, 0, 0, 0, time.UTC)
+	commits := []*core.Commit{
+		{Hash: "a1", Timestamp: start, Message: "Kick", Author: "livecoder", Content: "d1 $ s \"bd\"",
+			Metadata: core.ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true, BPM: 128}},
+		{Hash: "b2", Timestamp: start.Add(3 * time.Second), Message: "Shapes", Author: "livecoder", Content: "osc(10).out()",
+			Metadata: core.ExecutionMetadata{Buffer: "visuals", Language: "hydra", Success: false, ErrorMessage: "parse error", BeatsFromStart: 8}},
+	}
+
+	var stream bytes.Buffer
+	if err := export.WriteGitFastImport(&stream, commits, nil, "main"); err != nil {
+		t.Fatalf("Failed to write stream: %v", err)
+	}
+	cmd := exec.Command("git", "-C", dir, "fast-import", "--quiet")
+	cmd.Stdin = &stream
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("fast-import failed: %v: %s", err, output)
+	}
+
+	history, err := ReadGit(dir, "main")
+	if err != nil {
+		t.Fatalf("Failed to read repository: %v", err)
+	}
+	if len(history.Commits) != 2 {
+		t.Fatalf("Expected 2 commits, got %d", len(history.Commits))
+	}
+
+	for i, commit := range history.Commits {
+		original := commits[i]
+		if commit.Message != original.Message {
+			t.Errorf("Expected message %q without trailers, got %q", original.Message, commit.Message)
+		}
+		if commit.Content != original.Content || !commit.Timestamp.Equal(original.Timestamp) {
+			t.Errorf("Expected content and time of %s to survive, got %q at %v", original.Hash, commit.Content, commit.Timestamp)
+		}
+		if !reflect.DeepEqual(commit.Metadata, original.Metadata) {
+			t.Errorf("Expected metadata %+v, got %+v", original.Metadata, commit.Metadata)
+		}
+	}
+}
+
+func TestSplitTrailers(t *testing.T) {
+	message, trailers := splitTrailers("Drop\n\nKeys: not a trailer\n\nLivecode-Buffer: d1\nLivecode-BPM: 120\n")
+
+	if message != "Drop\n\nKeys: not a trailer" {
+		t.Errorf("Expected message without trailers, got %q", message)
+	}
+	if len(trailers) != 2 || trailers["Livecode-Buffer"] != "d1" || trailers["Livecode-BPM"] != "120" {
+		t.Errorf("Expected two trailers, got %v", trailers)
+	}
+}
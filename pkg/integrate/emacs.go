@@ -0,0 +1,107 @@
+package integrate
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/livecodegit/pkg/watchers/emacs"
+)
+
+const (
+	emacsBeginMarker = ";; >>> livecodegit hook >>>"
+	emacsEndMarker   = ";; <<< livecodegit hook <<<"
+)
+
+// emacsSend defines lcg-send, which sends an evaluation to the emacs
+// watcher over TCP. Any mode can call it; errors are ignored so a missing
+// lcg never interrupts the performance.
+const emacsSend = `(require 'json)
+
+(defvar lcg--process nil)
+
+(defun lcg-send (content &optional buffer language)
+  "Record CONTENT, evaluated in BUFFER, with the lcg emacs watcher."
+  (ignore-errors
+    (unless (process-live-p lcg--process)
+      (setq lcg--process (open-network-stream "lcg" nil "127.0.0.1" %d))
+      (set-process-query-on-exit-flag lcg--process nil))
+    (process-send-string
+     lcg--process
+     (concat (json-encode (list (cons 'content content)
+                                (cons 'buffer (or buffer (buffer-name)))
+                                (cons 'language (or language ""))))
+             "\n"))))
+`
+
+// emacsTidalTCP records tidal.el's evaluations with lcg-send. tidal.el sends
+// a multi-line block between :{ and :} lines, so what it sends in between is
+// gathered until the block ends.
+const emacsTidalTCP = `
+(defvar lcg--tidal-in-block nil)
+(defvar lcg--tidal-block nil)
+
+(defun lcg--record-tidal (string)
+  (let ((line (string-trim-right string)))
+    (cond ((string= line ":{")
+           (setq lcg--tidal-in-block t lcg--tidal-block nil))
+          ((string= line ":}")
+           (setq lcg--tidal-in-block nil)
+           (lcg-send (mapconcat #'identity (reverse lcg--tidal-block) "\n") nil "tidal"))
+          (lcg--tidal-in-block (push line lcg--tidal-block))
+          ((not (or (string= line "") (string-prefix-p ":" line)))
+           (lcg-send line nil "tidal")))))
+
+(with-eval-after-load 'tidal
+  (advice-add 'tidal-send-string :before #'lcg--record-tidal))
+`
+
+// emacsTidalTranscript appends what tidal.el sends to GHCi to the
+// transcript the emacs watcher tails, after a line naming the buffer
+const emacsTidalTranscript = `
+(defun lcg--record-tidal (string)
+  (ignore-errors
+    (write-region (concat %s (buffer-name) "\n" (string-trim-right string "\n+") "\n")
+                  nil %s 'append 'silent)))
+
+(with-eval-after-load 'tidal
+  (advice-add 'tidal-send-string :before #'lcg--record-tidal))
+`
+
+// EmacsFragment returns the init.el fragment recording tidal.el's
+// evaluations, in the transcript file when it is set and over TCP to port
+// otherwise. Both define lcg-send for other modes to call.
+func EmacsFragment(port int, transcript string) string {
+	var b strings.Builder
+
+	b.WriteString(emacsBeginMarker + "\n")
+	b.WriteString(";; Records Emacs evaluations with LiveCodeGit.\n")
+	b.WriteString(";; Generated by 'lcg integrate emacs'; rerun it to update this block.\n")
+	b.WriteString(fmt.Sprintf(emacsSend, port))
+	if transcript == "" {
+		b.WriteString(emacsTidalTCP)
+	} else {
+		b.WriteString(fmt.Sprintf(emacsTidalTranscript, strconv.Quote(emacs.BufferMarker), strconv.Quote(transcript)))
+	}
+	b.WriteString(emacsEndMarker + "\n")
+	return b.String()
+}
+
+// InstallEmacsFragment appends the fragment to an Emacs init file, creating
+// it if needed and replacing a previously installed fragment. It reports
+// whether an old fragment was replaced.
+func InstallEmacsFragment(initPath string, fragment string) (bool, error) {
+	data, err := os.ReadFile(initPath)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read init file: %w", err)
+	}
+
+	content, replaced := replaceBlock(string(data), fragment, emacsBeginMarker, emacsEndMarker)
+
+	if err := os.WriteFile(initPath, []byte(content), 0644); err != nil {
+		return false, fmt.Errorf("failed to write init file: %w", err)
+	}
+
+	return replaced, nil
+}
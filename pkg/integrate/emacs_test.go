@@ -0,0 +1,52 @@
+package integrate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEmacsFragment(t *testing.T) {
+	fragment := EmacsFragment(7000, "")
+	if !strings.HasPrefix(fragment, emacsBeginMarker) || !strings.HasSuffix(fragment, emacsEndMarker+"\n") {
+		t.Errorf("Expected the fragment between markers")
+	}
+	if !strings.Contains(fragment, `"127.0.0.1" 7000`) {
+		t.Errorf("Expected the fragment to send to port 7000")
+	}
+	if strings.Contains(fragment, "write-region") {
+		t.Errorf("Expected no transcript without one")
+	}
+
+	fragment = EmacsFragment(7000, `/tmp/my "tidal".log`)
+	if !strings.Contains(fragment, `"-- lcg: " (buffer-name)`) {
+		t.Errorf("Expected the transcript to name the buffer")
+	}
+	if !strings.Contains(fragment, `nil "/tmp/my \"tidal\".log" 'append`) {
+		t.Errorf("Expected the quoted transcript path, got:\n%s", fragment)
+	}
+}
+
+func TestInstallEmacsFragment(t *testing.T) {
+	initPath := filepath.Join(t.TempDir(), "init.el")
+
+	// The init file is created when missing
+	if replaced, err := InstallEmacsFragment(initPath, EmacsFragment(6068, "")); err != nil || replaced {
+		t.Fatalf("Expected the fragment to be added, got %v, %v", replaced, err)
+	}
+
+	os.WriteFile(initPath, []byte("(setq inhibit-startup-screen t)\n\n"+EmacsFragment(6068, "")), 0644)
+	if replaced, err := InstallEmacsFragment(initPath, EmacsFragment(7000, "")); err != nil || !replaced {
+		t.Fatalf("Expected the fragment to be replaced, got %v, %v", replaced, err)
+	}
+
+	data, _ := os.ReadFile(initPath)
+	content := string(data)
+	if !strings.HasPrefix(content, "(setq inhibit-startup-screen t)") {
+		t.Errorf("Expected the rest of the init file to be kept")
+	}
+	if strings.Count(content, emacsBeginMarker) != 1 || strings.Contains(content, " 6068)") {
+		t.Errorf("Expected a single, updated fragment, got:\n%s", content)
+	}
+}
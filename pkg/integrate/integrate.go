@@ -0,0 +1,26 @@
+package integrate
+
+import "strings"
+
+// replaceBlock swaps the block between begin and end markers in content for
+// fragment, or appends fragment when no block exists. It reports whether an
+// existing block was replaced.
+func replaceBlock(content, fragment, begin, end string) (string, bool) {
+	start := strings.Index(content, begin)
+	stop := strings.Index(content, end)
+	if start >= 0 && stop > start {
+		stop += len(end)
+		if stop < len(content) && content[stop] == '\n' {
+			stop++
+		}
+		return content[:start] + fragment + content[stop:], true
+	}
+
+	if content != "" {
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += "\n"
+	}
+	return content + fragment, false
+}
@@ -0,0 +1,61 @@
+package integrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/livecodegit/pkg/watchers/pulsar"
+)
+
+// pulsarWrapper runs GHCi, logging every line sent to it and every line it
+// writes to stderr. stdout isn't piped, so the package still sees GHCi's
+// prompts as soon as they are written.
+const pulsarWrapper = `#!/usr/bin/env bash
+# GHCi for Pulsar's tidalcycles package, logging what the package sends and
+# GHCi's errors for the lcg pulsar watcher.
+# Generated by 'lcg integrate pulsar'; rerun it to update this file.
+log=%s
+ghci=%s
+
+record() {
+  local prefix=$1 line
+  while IFS= read -r line || [ -n "$line" ]; do
+    printf '%%s\n' "$line"
+    printf '%%s%%s\n' "$prefix" "$line" >> "$log" 2>/dev/null
+  done
+}
+
+record %s | $ghci "$@" 2> >(record %s >&2)
+`
+
+// DefaultPulsarWrapperPath returns where 'lcg integrate pulsar' writes the
+// GHCi wrapper unless told otherwise
+func DefaultPulsarWrapperPath() string {
+	return filepath.Join(filepath.Dir(pulsar.DefaultLogPath()), "pulsar-ghci")
+}
+
+// PulsarWrapper returns the script running the GHCi command for Pulsar's
+// tidalcycles package while logging to logPath for the pulsar watcher
+func PulsarWrapper(ghciCommand, logPath string) string {
+	return fmt.Sprintf(pulsarWrapper, shellQuote(logPath), shellQuote(ghciCommand),
+		shellQuote(pulsar.InputPrefix), shellQuote(pulsar.ErrorPrefix))
+}
+
+// shellQuote quotes s as a single word for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// InstallPulsarWrapper writes the wrapper script to path, executable
+func InstallPulsarWrapper(path, script string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create wrapper directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		return fmt.Errorf("failed to write wrapper: %w", err)
+	}
+	// WriteFile keeps the mode of an existing file
+	return os.Chmod(path, 0755)
+}
@@ -0,0 +1,60 @@
+package integrate
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/livecodegit/pkg/watchers/pulsar"
+)
+
+func TestPulsarWrapper(t *testing.T) {
+	script := PulsarWrapper("stack exec -- ghci", "/tmp/it's.log")
+	if !strings.Contains(script, `log='/tmp/it'\''s.log'`) {
+		t.Errorf("Expected the quoted log path, got:\n%s", script)
+	}
+	if !strings.Contains(script, `ghci='stack exec -- ghci'`) {
+		t.Errorf("Expected the GHCi command, got:\n%s", script)
+	}
+}
+
+func TestPulsarWrapperLogs(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found")
+	}
+
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "ghci.log")
+
+	// A GHCi answering each line on stdout, and failing on stderr
+	fakeGHCi := filepath.Join(dir, "fake-ghci")
+	os.WriteFile(fakeGHCi, []byte("#!/bin/sh\nwhile read -r line; do echo \"ok $line\"; echo \"<interactive>:1:1: error: $line\" >&2; done\n"), 0755)
+
+	wrapperPath := filepath.Join(dir, "bin", "pulsar-ghci")
+	if err := InstallPulsarWrapper(wrapperPath, PulsarWrapper(fakeGHCi, logPath)); err != nil {
+		t.Fatalf("Failed to install wrapper: %v", err)
+	}
+
+	cmd := exec.Command(wrapperPath)
+	cmd.Stdin = strings.NewReader("d1 $ s \"bd\"\n")
+	var stdout, stderr strings.Builder
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Wrapper failed: %v (%s)", err, stderr.String())
+	}
+
+	if stdout.String() != "ok d1 $ s \"bd\"\n" {
+		t.Errorf("Expected GHCi's stdout to pass through, got %q", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "error: d1") {
+		t.Errorf("Expected GHCi's stderr to pass through, got %q", stderr.String())
+	}
+
+	data, _ := os.ReadFile(logPath)
+	log := string(data)
+	if !strings.Contains(log, pulsar.InputPrefix+"d1 $ s \"bd\"\n") || !strings.Contains(log, pulsar.ErrorPrefix+"<interactive>:1:1: error: d1") {
+		t.Errorf("Expected input and errors in the log, got %q", log)
+	}
+}
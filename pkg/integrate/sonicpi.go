@@ -0,0 +1,135 @@
+package integrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+	"github.com/livecodegit/pkg/watchers/sonicpi"
+)
+
+const (
+	sonicPiBeginMarker = "# >>> livecodegit hook >>>"
+	sonicPiEndMarker   = "# <<< livecodegit hook <<<"
+)
+
+// sonicPiHook wraps the runtime's evaluation entry point so each Run is sent to
+// the sonicpi-osc watcher. Only runtimes exposing __spider_eval are supported.
+const sonicPiHook = `require 'socket'
+
+$lcg_notify = lambda do |code, info|
+  buffer = info.is_a?(Hash) ? info[:workspace] : nil
+  next if buffer.nil?
+  begin
+    socket = UDPSocket.new
+    socket.send("%s buffer: #{buffer}%s\n#{code}", 0, "127.0.0.1", %d)
+    socket.close
+  rescue StandardError
+    nil
+  end
+end
+
+if defined?(SonicPi::RuntimeMethods) &&
+   SonicPi::RuntimeMethods.method_defined?(:__spider_eval)
+  unless SonicPi::RuntimeMethods.method_defined?(:__lcg_spider_eval)
+    SonicPi::RuntimeMethods.module_eval do
+      alias_method :__lcg_spider_eval, :__spider_eval
+
+      def __spider_eval(code, info = {})
+        $lcg_notify.call(code, info)
+        __lcg_spider_eval(code, info)
+      end
+    end
+  end
+else
+  STDERR.puts "LiveCodeGit hook: this Sonic Pi version is not supported, use the sonicpi-files watcher instead"
+end
+`
+
+// DefaultSonicPiInitPath returns the init.rb Sonic Pi loads on boot: config/init.rb
+// for Sonic Pi 4 and later, init.rb in the settings directory before that
+func DefaultSonicPiInitPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "init.rb"
+	}
+
+	configDir := filepath.Join(homeDir, ".sonic-pi", "config")
+	if info, err := os.Stat(configDir); err == nil && info.IsDir() {
+		return filepath.Join(configDir, "init.rb")
+	}
+
+	return filepath.Join(homeDir, ".sonic-pi", "init.rb")
+}
+
+// SonicPiInitFragment returns the init.rb fragment that reports every Run to
+// the sonicpi-osc watcher on port. A non-empty author is sent with each Run
+// and recorded as its author.
+func SonicPiInitFragment(port int, author string) string {
+	authorField := ""
+	if author = strings.Join(strings.Fields(author), " "); author != "" {
+		authorField = " author: " + rubyEscape(author)
+	}
+
+	return sonicPiBeginMarker + "\n" +
+		"# Reports every Run to LiveCodeGit.\n" +
+		"# Generated by 'lcg integrate sonicpi'; rerun it to update this block.\n" +
+		fmt.Sprintf(sonicPiHook, sonicpi.HookMessagePrefix, authorField, port) +
+		sonicPiEndMarker + "\n"
+}
+
+// rubyEscape escapes s for a double-quoted Ruby string
+func rubyEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "#", `\#`).Replace(s)
+}
+
+// InstallSonicPiInitFragment writes the fragment into init.rb, creating the file
+// if needed and replacing a previously installed fragment. It reports whether
+// an old fragment was replaced.
+func InstallSonicPiInitFragment(initPath string, fragment string) (bool, error) {
+	data, err := os.ReadFile(initPath)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read init file: %w", err)
+	}
+
+	content, replaced := replaceBlock(string(data), fragment, sonicPiBeginMarker, sonicPiEndMarker)
+
+	if err := os.MkdirAll(filepath.Dir(initPath), 0755); err != nil {
+		return false, fmt.Errorf("failed to create init directory: %w", err)
+	}
+
+	if err := os.WriteFile(initPath, []byte(content), 0644); err != nil {
+		return false, fmt.Errorf("failed to write init file: %w", err)
+	}
+
+	return replaced, nil
+}
+
+// VerifySonicPi listens on the hook port until Sonic Pi reports a Run or the
+// timeout expires. The user triggers the Run from Sonic Pi.
+func VerifySonicPi(port int, timeout time.Duration) (*common.ExecutionEvent, error) {
+	received := make(chan common.ExecutionEvent, 1)
+	watcher := sonicpi.NewOSCWatcher(port, "")
+	err := watcher.Start(func(event common.ExecutionEvent) {
+		if event.ExtraData["trigger_type"] == "init_hook" {
+			select {
+			case received <- event:
+			default:
+			}
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on port %d (is 'lcg watch' already running?): %w", port, err)
+	}
+	defer watcher.Stop()
+
+	select {
+	case event := <-received:
+		return &event, nil
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("no Run received on port %d within %s; restart Sonic Pi after installing the hook", port, timeout)
+	}
+}
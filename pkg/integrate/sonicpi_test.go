@@ -0,0 +1,112 @@
+package integrate
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+	"github.com/livecodegit/pkg/watchers/sonicpi"
+)
+
+func TestSonicPiInitFragment(t *testing.T) {
+	fragment := SonicPiInitFragment(4560, "")
+
+	if !strings.HasPrefix(fragment, sonicPiBeginMarker) || !strings.HasSuffix(fragment, sonicPiEndMarker+"\n") {
+		t.Errorf("Expected fragment to be wrapped in markers")
+	}
+	if !strings.Contains(fragment, `"127.0.0.1", 4560)`) {
+		t.Errorf("Expected fragment to send to port 4560")
+	}
+	if !strings.Contains(fragment, sonicpi.HookMessagePrefix+" buffer: ") {
+		t.Errorf("Expected fragment to send hook messages")
+	}
+
+	fragment = SonicPiInitFragment(4560, "Sam #1")
+	if !strings.Contains(fragment, `buffer: #{buffer} author: Sam \#1\n`) {
+		t.Errorf("Expected fragment to send the author, got:\n%s", fragment)
+	}
+}
+
+func TestInstallSonicPiInitFragmentCreatesFile(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "lcg-integrate-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	initPath := filepath.Join(tempDir, "config", "init.rb")
+
+	replaced, err := InstallSonicPiInitFragment(initPath, SonicPiInitFragment(4559, ""))
+	if err != nil {
+		t.Fatalf("Failed to install fragment: %v", err)
+	}
+	if replaced {
+		t.Errorf("Expected a new init.rb, not a replacement")
+	}
+
+	replaced, err = InstallSonicPiInitFragment(initPath, SonicPiInitFragment(4559, ""))
+	if err != nil {
+		t.Fatalf("Failed to reinstall fragment: %v", err)
+	}
+	if !replaced {
+		t.Errorf("Expected second install to replace the existing fragment")
+	}
+
+	data, err := os.ReadFile(initPath)
+	if err != nil {
+		t.Fatalf("Failed to read init file: %v", err)
+	}
+	if string(data) != SonicPiInitFragment(4559, "") {
+		t.Errorf("Expected init.rb to contain exactly one fragment, got:\n%s", data)
+	}
+}
+
+func TestSonicPiHookMessageDelivery(t *testing.T) {
+	probe, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	port := probe.LocalAddr().(*net.UDPAddr).Port
+	probe.Close()
+
+	received := make(chan common.ExecutionEvent, 1)
+	watcher := sonicpi.NewOSCWatcher(port, "")
+	if err := watcher.Start(func(event common.ExecutionEvent) { received <- event }); err != nil {
+		t.Fatalf("Failed to start OSC watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		t.Fatalf("Failed to dial OSC watcher: %v", err)
+	}
+	defer conn.Close()
+
+	// Same shape as the message sent by the init.rb hook
+	code := "use_bpm 140\nlive_loop :drums do\n  sample :bd_haus\n  sleep 1\nend"
+	if _, err := conn.Write([]byte(sonicpi.HookMessagePrefix + " buffer: workspace_one author: Sam Aaron\n" + code)); err != nil {
+		t.Fatalf("Failed to send hook message: %v", err)
+	}
+
+	select {
+	case event := <-received:
+		if event.Buffer != "workspace_one" {
+			t.Errorf("Expected buffer workspace_one, got %s", event.Buffer)
+		}
+		if event.Content != code {
+			t.Errorf("Expected full buffer content, got %q", event.Content)
+		}
+		if event.BPM != 140 {
+			t.Errorf("Expected BPM 140 from use_bpm, got %f", event.BPM)
+		}
+		if event.Author != "Sam Aaron" {
+			t.Errorf("Expected author Sam Aaron, got %q", event.Author)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected hook message to be received")
+	}
+}
@@ -0,0 +1,193 @@
+package integrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/livecodegit/pkg/watchers/common"
+	"github.com/livecodegit/pkg/watchers/tidal"
+)
+
+const (
+	tidalBeginMarker = "-- >>> livecodegit hook >>>"
+	tidalEndMarker   = "-- <<< livecodegit hook <<<"
+
+	// tidalVerifyBuffer is the buffer name used by the verification evaluation
+	tidalVerifyBuffer = "lcg-verify"
+)
+
+// tidalHelpers defines the functions that send evaluations to the tidal-hook watcher.
+// It is a GHCi script, like BootTidal.hs itself.
+const tidalHelpers = `import qualified Control.Exception as LcgException
+import qualified Data.ByteString.Char8 as LcgBytes
+import qualified Network.Socket as LcgNet
+import qualified Network.Socket.ByteString as LcgNetBytes
+
+:{
+lcgEscape :: String -> String
+lcgEscape = concatMap escape
+  where
+    escape '"'  = "\\\""
+    escape '\\' = "\\\\"
+    escape '\n' = "\\n"
+    escape '\t' = "\\t"
+    escape c
+      | c < ' '   = ""
+      | otherwise = [c]
+
+lcgSendRaw :: String -> String -> IO ()
+lcgSendRaw buffer code = do
+  addr:_ <- LcgNet.getAddrInfo Nothing (Just "127.0.0.1") (Just "%d")
+  sock <- LcgNet.socket (LcgNet.addrFamily addr) LcgNet.Datagram LcgNet.defaultProtocol
+  _ <- LcgNetBytes.sendTo sock (LcgBytes.pack message) (LcgNet.addrAddress addr)
+  LcgNet.close sock
+  where
+    message = "{\"buffer\":\"" ++ lcgEscape buffer ++ "\",\"content\":\"" ++ lcgEscape code ++ "\"%s}"
+
+-- Never let a missing lcg interrupt the performance
+lcgSend :: String -> String -> IO ()
+lcgSend buffer code = do
+  _ <- LcgException.try (lcgSendRaw buffer code) :: IO (Either LcgException.SomeException ())
+  return ()
+
+lcgTrack :: String -> (ControlPattern -> IO ()) -> ControlPattern -> IO ()
+lcgTrack name dN pat = lcgSend name (show pat) >> dN pat
+:}
+`
+
+// TidalBootFragment returns the BootTidal.hs fragment that forwards every
+// evaluation on connections d1..dN to the tidal-hook watcher on port. A
+// non-empty author is sent with each evaluation and recorded as its author.
+func TidalBootFragment(port int, connections int, author string) string {
+	var b strings.Builder
+
+	b.WriteString(tidalBeginMarker + "\n")
+	b.WriteString("-- Forwards every pattern evaluation to LiveCodeGit.\n")
+	b.WriteString("-- Generated by 'lcg integrate tidal'; rerun it to update this block.\n")
+	authorField := ""
+	if author != "" {
+		authorField = haskellEscape(`,"author":` + asciiJSON(author))
+	}
+	b.WriteString(fmt.Sprintf(tidalHelpers, port, authorField))
+	b.WriteString("\n")
+
+	// Wrap the connections defined earlier in BootTidal.hs
+	for i := 1; i <= connections; i++ {
+		b.WriteString(fmt.Sprintf("let lcgD%d = d%d\n", i, i))
+		b.WriteString(fmt.Sprintf("let d%d = lcgTrack \"d%d\" lcgD%d\n", i, i, i))
+	}
+
+	b.WriteString(tidalEndMarker + "\n")
+	return b.String()
+}
+
+// asciiJSON quotes s as a JSON string using only ASCII, since the hook sends
+// its message as 8-bit characters
+func asciiJSON(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < ' ':
+			fmt.Fprintf(&b, "\\u%04x", r)
+		case r > '~':
+			for _, unit := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&b, "\\u%04x", unit)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// haskellEscape escapes s for a Haskell string literal
+func haskellEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+// InstallTidalBootFragment appends the fragment to a BootTidal.hs file, replacing
+// a previously installed fragment. It reports whether an old fragment was replaced.
+func InstallTidalBootFragment(bootPath string, fragment string) (bool, error) {
+	data, err := os.ReadFile(bootPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read boot file: %w", err)
+	}
+
+	content, replaced := replaceBlock(string(data), fragment, tidalBeginMarker, tidalEndMarker)
+
+	if err := os.WriteFile(bootPath, []byte(content), 0644); err != nil {
+		return false, fmt.Errorf("failed to write boot file: %w", err)
+	}
+
+	return replaced, nil
+}
+
+// VerifyTidal runs a test evaluation through GHCi using the generated helpers and
+// waits for it to arrive on the hook port, giving GHCi at most timeout to run
+func VerifyTidal(ghciCommand string, port int, timeout time.Duration) (*common.ExecutionEvent, error) {
+	fields := common.CommandFields(ghciCommand)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("GHCi command cannot be empty")
+	}
+
+	received := make(chan common.ExecutionEvent, 1)
+	watcher := tidal.NewHookWatcher(port)
+	err := watcher.Start(func(event common.ExecutionEvent) {
+		if event.Buffer == tidalVerifyBuffer {
+			select {
+			case received <- event:
+			default:
+			}
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on hook port %d (is 'lcg watch' already running?): %w", port, err)
+	}
+	defer watcher.Stop()
+
+	scriptDir, err := os.MkdirTemp("", "lcg-integrate")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(scriptDir)
+
+	script := "import Sound.Tidal.Context\n" +
+		fmt.Sprintf(tidalHelpers, port, "") +
+		fmt.Sprintf("lcgSend %q %q\n", tidalVerifyBuffer, "-- lcg integration test") +
+		":quit\n"
+
+	scriptPath := filepath.Join(scriptDir, "verify.ghci")
+	if err := os.WriteFile(scriptPath, []byte(script), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write verification script: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	args := append(fields[1:], "-ghci-script", scriptPath)
+	output, runErr := exec.CommandContext(ctx, fields[0], args...).CombinedOutput()
+
+	// The datagram is sent before GHCi exits; allow a moment for delivery
+	select {
+	case event := <-received:
+		return &event, nil
+	case <-time.After(time.Second):
+	}
+
+	if runErr != nil {
+		return nil, fmt.Errorf("GHCi failed: %w\n%s", runErr, strings.TrimSpace(string(output)))
+	}
+	return nil, fmt.Errorf("no test evaluation received on port %d; GHCi output:\n%s",
+		port, strings.TrimSpace(string(output)))
+}
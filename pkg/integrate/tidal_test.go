@@ -0,0 +1,152 @@
+package integrate
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+	"github.com/livecodegit/pkg/watchers/tidal"
+)
+
+func TestTidalBootFragment(t *testing.T) {
+	fragment := TidalBootFragment(7000, 3, "")
+
+	if !strings.HasPrefix(fragment, tidalBeginMarker) {
+		t.Errorf("Expected fragment to start with begin marker")
+	}
+	if !strings.HasSuffix(fragment, tidalEndMarker+"\n") {
+		t.Errorf("Expected fragment to end with end marker")
+	}
+	if !strings.Contains(fragment, `(Just "7000")`) {
+		t.Errorf("Expected fragment to send to port 7000")
+	}
+
+	for _, line := range []string{
+		"let lcgD1 = d1",
+		`let d1 = lcgTrack "d1" lcgD1`,
+		`let d3 = lcgTrack "d3" lcgD3`,
+	} {
+		if !strings.Contains(fragment, line) {
+			t.Errorf("Expected fragment to contain %q", line)
+		}
+	}
+
+	if strings.Contains(fragment, "d4") {
+		t.Errorf("Expected only 3 connections to be wrapped")
+	}
+	if strings.Contains(fragment, "author") {
+		t.Errorf("Expected no author without --author")
+	}
+}
+
+func TestTidalBootFragmentAuthor(t *testing.T) {
+	fragment := TidalBootFragment(6061, 1, `Zoë "Z"`)
+
+	// JSON inside a Haskell string literal, kept to ASCII
+	expected := `lcgEscape code ++ "\",\"author\":\"Zo\\u00eb \\\"Z\\\"\"}"`
+	if !strings.Contains(fragment, expected) {
+		t.Errorf("Expected the message to carry the author as %s, got:\n%s", expected, fragment)
+	}
+
+	// The message the hook sends is what the watcher reads back
+	event, err := tidal.ParseHookMessage([]byte(`{"buffer":"d1","content":"s \"bd\"","author":"Zo\u00eb \"Z\""}`))
+	if err != nil {
+		t.Fatalf("Failed to parse hook message: %v", err)
+	}
+	if event.Author != `Zoë "Z"` {
+		t.Errorf("Expected author Zoë \"Z\", got %q", event.Author)
+	}
+}
+
+func TestInstallTidalBootFragment(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "lcg-integrate-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	bootPath := filepath.Join(tempDir, "BootTidal.hs")
+	original := ":set -XOverloadedStrings\nd1 = p 1\n"
+	if err := os.WriteFile(bootPath, []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write boot file: %v", err)
+	}
+
+	replaced, err := InstallTidalBootFragment(bootPath, TidalBootFragment(6061, 2, ""))
+	if err != nil {
+		t.Fatalf("Failed to install fragment: %v", err)
+	}
+	if replaced {
+		t.Errorf("Expected first install to append, not replace")
+	}
+
+	replaced, err = InstallTidalBootFragment(bootPath, TidalBootFragment(7000, 2, ""))
+	if err != nil {
+		t.Fatalf("Failed to reinstall fragment: %v", err)
+	}
+	if !replaced {
+		t.Errorf("Expected second install to replace the existing fragment")
+	}
+
+	data, err := os.ReadFile(bootPath)
+	if err != nil {
+		t.Fatalf("Failed to read boot file: %v", err)
+	}
+	content := string(data)
+
+	if !strings.HasPrefix(content, original) {
+		t.Errorf("Expected original boot file content to be preserved")
+	}
+	if count := strings.Count(content, tidalBeginMarker); count != 1 {
+		t.Errorf("Expected 1 hook block, got %d", count)
+	}
+	if strings.Contains(content, `(Just "6061")`) || !strings.Contains(content, `(Just "7000")`) {
+		t.Errorf("Expected hook block to be updated to port 7000")
+	}
+}
+
+func TestHookMessageDelivery(t *testing.T) {
+	// Reserve a free port for the watcher
+	probe, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	port := probe.LocalAddr().(*net.UDPAddr).Port
+	probe.Close()
+
+	received := make(chan common.ExecutionEvent, 1)
+	watcher := tidal.NewHookWatcher(port)
+	if err := watcher.Start(func(event common.ExecutionEvent) { received <- event }); err != nil {
+		t.Fatalf("Failed to start hook watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		t.Fatalf("Failed to dial hook watcher: %v", err)
+	}
+	defer conn.Close()
+
+	// Same shape as the message built by lcgSendRaw
+	if _, err := conn.Write([]byte(`{"buffer":"d2","content":"(0>1)|s: \"bd\"\n"}`)); err != nil {
+		t.Fatalf("Failed to send hook message: %v", err)
+	}
+
+	select {
+	case event := <-received:
+		if event.Buffer != "d2" {
+			t.Errorf("Expected buffer d2, got %s", event.Buffer)
+		}
+		if event.Content != "(0>1)|s: \"bd\"\n" {
+			t.Errorf("Expected escaped content to round-trip, got %q", event.Content)
+		}
+		if event.Language != "tidal" || !event.Success {
+			t.Errorf("Expected successful tidal event, got %s success=%v", event.Language, event.Success)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected hook message to be received")
+	}
+}
@@ -0,0 +1,404 @@
+// Package journal keeps a size-rotated log of what the watcher service did,
+// under .livecodegit/logs/, so an unattended run can be diagnosed afterwards
+package journal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+const (
+	// LogsDir is the directory holding the journal inside the repository directory
+	LogsDir = "logs"
+	// FileName is the journal being written; rotated files get a .1, .2, ... suffix
+	FileName = "lcg.log"
+
+	// DefaultMaxSize is the size at which the journal is rotated
+	DefaultMaxSize = 1 << 20
+	// DefaultMaxFiles is the number of rotated files kept besides the current one
+	DefaultMaxFiles = 5
+	// FlushInterval is how long entries may wait in the buffer
+	FlushInterval = time.Second
+)
+
+// Levels of journal entries
+const (
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+// Kinds of journal entries
+const (
+	EventService     = "service"     // the service started or stopped
+	EventWatcher     = "watcher"     // a watcher started, stopped or failed
+	EventCommit      = "commit"      // an execution was committed
+	EventPending     = "pending"     // an execution was kept for review
+	EventRejected    = "rejected"    // the pre-commit hook refused an execution
+	EventControl     = "control"     // a control message was handled
+	EventMaintenance = "maintenance" // a scheduled maintenance task ran
+	EventError       = "error"       // anything else that went wrong
+)
+
+// Entry is one line of the journal
+type Entry struct {
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Event   string            `json:"event"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// Journal appends entries to the log file of a repository. Entries are
+// buffered and written at least every FlushInterval, and at once for errors.
+// A nil *Journal ignores every entry, so callers needn't check.
+type Journal struct {
+	MaxSize  int64
+	MaxFiles int
+
+	dir    string
+	file   *os.File
+	writer *bufio.Writer
+	size   int64
+	mutex  sync.Mutex
+	done   chan struct{}
+	now    func() time.Time
+}
+
+// Dir returns the directory holding a repository's journal
+func Dir(repoPath string) string {
+	return filepath.Join(repoPath, storage.RepoDir, LogsDir)
+}
+
+// Open opens the journal of the repository at repoPath for appending
+func Open(repoPath string) (*Journal, error) {
+	j := &Journal{
+		MaxSize:  DefaultMaxSize,
+		MaxFiles: DefaultMaxFiles,
+		dir:      Dir(repoPath),
+		done:     make(chan struct{}),
+		now:      time.Now,
+	}
+
+	if err := os.MkdirAll(j.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create logs directory: %w", err)
+	}
+	if err := j.openFile(); err != nil {
+		return nil, err
+	}
+
+	go j.flushPeriodically()
+	return j, nil
+}
+
+// openFile opens the current log file for appending
+func (j *Journal) openFile() error {
+	file, err := os.OpenFile(filepath.Join(j.dir, FileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	j.file = file
+	j.writer = bufio.NewWriter(file)
+	j.size = info.Size()
+	return nil
+}
+
+// flushPeriodically writes buffered entries until the journal is closed
+func (j *Journal) flushPeriodically() {
+	ticker := time.NewTicker(FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			j.Flush()
+		case <-j.done:
+			return
+		}
+	}
+}
+
+// Record appends an entry. Fields are given as key, value pairs.
+func (j *Journal) Record(level, event, message string, fields ...string) {
+	if j == nil {
+		return
+	}
+
+	entry := Entry{Level: level, Event: event, Message: message}
+	if len(fields) > 1 {
+		entry.Fields = make(map[string]string, len(fields)/2)
+		for i := 0; i+1 < len(fields); i += 2 {
+			entry.Fields[fields[i]] = fields[i+1]
+		}
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.writer == nil {
+		return
+	}
+	entry.Time = j.now()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+
+	if j.size > 0 && j.size+int64(len(data)) > j.MaxSize {
+		if err := j.rotate(); err != nil {
+			return
+		}
+	}
+
+	n, _ := j.writer.Write(data)
+	j.size += int64(n)
+
+	// Errors are what a reader looks for after a crash
+	if level == LevelError {
+		j.writer.Flush()
+	}
+}
+
+// Info records an informational entry
+func (j *Journal) Info(event, message string, fields ...string) {
+	j.Record(LevelInfo, event, message, fields...)
+}
+
+// Warn records a warning
+func (j *Journal) Warn(event, message string, fields ...string) {
+	j.Record(LevelWarn, event, message, fields...)
+}
+
+// Error records an error and writes it out at once
+func (j *Journal) Error(event, message string, fields ...string) {
+	j.Record(LevelError, event, message, fields...)
+}
+
+// rotate shifts lcg.log to lcg.log.1, lcg.log.1 to lcg.log.2 and so on,
+// dropping the oldest, and starts a new file
+func (j *Journal) rotate() error {
+	if err := j.writer.Flush(); err != nil {
+		return err
+	}
+	j.file.Close()
+
+	base := filepath.Join(j.dir, FileName)
+	os.Remove(fmt.Sprintf("%s.%d", base, j.MaxFiles))
+	for i := j.MaxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", base, i), fmt.Sprintf("%s.%d", base, i+1))
+	}
+	if j.MaxFiles > 0 {
+		os.Rename(base, base+".1")
+	} else {
+		os.Remove(base)
+	}
+
+	return j.openFile()
+}
+
+// Flush writes buffered entries to the file
+func (j *Journal) Flush() error {
+	if j == nil {
+		return nil
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.writer == nil {
+		return nil
+	}
+	return j.writer.Flush()
+}
+
+// Close flushes and closes the journal
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.writer == nil {
+		return nil
+	}
+	close(j.done)
+
+	err := j.writer.Flush()
+	if closeErr := j.file.Close(); err == nil {
+		err = closeErr
+	}
+	j.writer = nil
+	return err
+}
+
+// files returns the journal's files, oldest first
+func files(repoPath string) ([]string, error) {
+	dir := Dir(repoPath)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read logs directory: %w", err)
+	}
+
+	type rotated struct {
+		path  string
+		index int
+	}
+	var found []rotated
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == FileName {
+			found = append(found, rotated{filepath.Join(dir, name), 0})
+			continue
+		}
+		if suffix, ok := strings.CutPrefix(name, FileName+"."); ok {
+			if index, err := strconv.Atoi(suffix); err == nil && index > 0 {
+				found = append(found, rotated{filepath.Join(dir, name), index})
+			}
+		}
+	}
+
+	// Higher suffixes are older
+	sort.Slice(found, func(a, b int) bool {
+		return found[a].index > found[b].index
+	})
+
+	paths := make([]string, len(found))
+	for i, f := range found {
+		paths[i] = f.path
+	}
+	return paths, nil
+}
+
+// Read returns the last limit entries of a repository's journal, oldest
+// first, across rotated files; 0 returns every entry
+func Read(repoPath string, limit int) ([]Entry, error) {
+	paths, err := files(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read journal: %w", err)
+		}
+		read, _, err := readEntries(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		entries = append(entries, read...)
+	}
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, nil
+}
+
+// readEntries parses complete lines from r, returning the entries and the
+// number of bytes they took. A trailing partial line is left for later.
+// Lines that aren't entries, e.g. cut short by a crash, are skipped.
+func readEntries(r io.Reader) ([]Entry, int64, error) {
+	reader := bufio.NewReader(r)
+	var entries []Entry
+	var consumed int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return entries, consumed, nil
+		}
+		if err != nil {
+			return entries, consumed, err
+		}
+		consumed += int64(len(line))
+
+		var entry Entry
+		if json.Unmarshal(line, &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+}
+
+// Follow calls fn with each entry written to the journal from now on,
+// following it across rotation, until ctx is done
+func Follow(ctx context.Context, repoPath string, poll time.Duration, fn func(Entry)) error {
+	path := filepath.Join(Dir(repoPath), FileName)
+
+	var offset int64
+	if info, err := os.Stat(path); err == nil {
+		offset = info.Size()
+	}
+
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("failed to follow journal: %w", err)
+		}
+		// A smaller file is a new one after rotation
+		if info.Size() < offset {
+			offset = 0
+		}
+		if info.Size() == offset {
+			continue
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to follow journal: %w", err)
+		}
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to follow journal: %w", err)
+		}
+		entries, consumed, err := readEntries(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to follow journal: %w", err)
+		}
+
+		offset += consumed
+		for _, entry := range entries {
+			fn(entry)
+		}
+	}
+}
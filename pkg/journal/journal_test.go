@@ -0,0 +1,163 @@
+package journal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func createTempRepo(t *testing.T) string {
+	path, err := os.MkdirTemp("", "livecodegit-journal-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	return path
+}
+
+func TestRecordAndRead(t *testing.T) {
+	path := createTempRepo(t)
+	defer os.RemoveAll(path)
+
+	j, err := Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	j.Info(EventService, "service started", "pid", "42")
+	j.Info(EventCommit, "Kick", "hash", "abc", "buffer")
+
+	// Entries are buffered until flushed
+	if entries, _ := Read(path, 0); len(entries) != 0 {
+		t.Errorf("Expected buffered entries not to be written yet, got %d", len(entries))
+	}
+
+	// Errors are written at once, with what was buffered before them
+	j.Error(EventError, "disk full")
+	entries, err := Read(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to read journal: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries after an error, got %d", len(entries))
+	}
+	if entries[0].Fields["pid"] != "42" || entries[0].Time.IsZero() {
+		t.Errorf("Expected a timestamped entry with fields, got %+v", entries[0])
+	}
+	if len(entries[1].Fields) != 1 {
+		t.Errorf("Expected a key without a value to be dropped, got %v", entries[1].Fields)
+	}
+	if entries[2].Level != LevelError || entries[2].Message != "disk full" {
+		t.Errorf("Expected error entry, got %+v", entries[2])
+	}
+
+	if err := j.Close(); err != nil {
+		t.Fatalf("Failed to close journal: %v", err)
+	}
+	j.Info(EventService, "after close")
+
+	last, err := Read(path, 1)
+	if err != nil || len(last) != 1 || last[0].Message != "disk full" {
+		t.Errorf("Expected the last entry only, got %+v (%v)", last, err)
+	}
+}
+
+func TestNilJournal(t *testing.T) {
+	var j *Journal
+	j.Info(EventService, "ignored")
+	if err := j.Flush(); err != nil {
+		t.Errorf("Expected nil journal to flush, got %v", err)
+	}
+	if err := j.Close(); err != nil {
+		t.Errorf("Expected nil journal to close, got %v", err)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	path := createTempRepo(t)
+	defer os.RemoveAll(path)
+
+	j, err := Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	j.MaxSize = 300
+	j.MaxFiles = 2
+
+	for i := 0; i < 20; i++ {
+		j.Info(EventCommit, strings.Repeat("x", 50), "n", string(rune('a'+i)))
+	}
+	if err := j.Close(); err != nil {
+		t.Fatalf("Failed to close journal: %v", err)
+	}
+
+	paths, err := files(path)
+	if err != nil {
+		t.Fatalf("Failed to list journal files: %v", err)
+	}
+	if len(paths) != 3 {
+		t.Fatalf("Expected the current file and 2 rotated ones, got %v", paths)
+	}
+	if filepath.Base(paths[0]) != FileName+".2" || filepath.Base(paths[2]) != FileName {
+		t.Errorf("Expected files oldest first, got %v", paths)
+	}
+
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", p, err)
+		}
+		if info.Size() > 300 {
+			t.Errorf("Expected %s to stay within the size limit, got %d bytes", p, info.Size())
+		}
+	}
+
+	// The oldest entries were dropped; the rest read back in order
+	entries, err := Read(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to read journal: %v", err)
+	}
+	if len(entries) == 0 || len(entries) >= 20 || entries[len(entries)-1].Fields["n"] != "t" {
+		t.Errorf("Expected the newest entries ending with t, got %d entries", len(entries))
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i].Fields["n"] <= entries[i-1].Fields["n"] {
+			t.Errorf("Expected entries in order, got %s after %s", entries[i].Fields["n"], entries[i-1].Fields["n"])
+		}
+	}
+}
+
+func TestFollow(t *testing.T) {
+	path := createTempRepo(t)
+	defer os.RemoveAll(path)
+
+	j, err := Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	defer j.Close()
+
+	j.Info(EventService, "before following")
+	j.Flush()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	followed := make(chan Entry, 10)
+	go Follow(ctx, path, 10*time.Millisecond, func(entry Entry) {
+		followed <- entry
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	j.Error(EventError, "while following")
+
+	select {
+	case entry := <-followed:
+		if entry.Message != "while following" {
+			t.Errorf("Expected only new entries, got %q", entry.Message)
+		}
+	case <-ctx.Done():
+		t.Fatalf("Expected to follow the new entry")
+	}
+}
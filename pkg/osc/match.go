@@ -0,0 +1,88 @@
+package osc
+
+import "strings"
+
+// Match reports whether an address matches an OSC address pattern: ? matches
+// one character, * any run of characters, [a-z] and [!a-z] one character in
+// or out of a set, and {foo,bar} one of the strings. None of them match
+// across a /.
+func Match(pattern, address string) bool {
+	patternParts := strings.Split(pattern, "/")
+	addressParts := strings.Split(address, "/")
+	if len(patternParts) != len(addressParts) {
+		return false
+	}
+	for i := range patternParts {
+		if !matchPart(patternParts[i], addressParts[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPart matches one part of an address between slashes
+func matchPart(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			// Try every split of what's left, shortest first
+			for i := 0; i <= len(s); i++ {
+				if matchPart(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		case '[':
+			end := strings.IndexByte(pattern, ']')
+			if end < 0 || len(s) == 0 || !matchSet(pattern[1:end], s[0]) {
+				return false
+			}
+			pattern, s = pattern[end+1:], s[1:]
+		case '{':
+			end := strings.IndexByte(pattern, '}')
+			if end < 0 {
+				return false
+			}
+			for _, choice := range strings.Split(pattern[1:end], ",") {
+				if strings.HasPrefix(s, choice) && matchPart(pattern[end+1:], s[len(choice):]) {
+					return true
+				}
+			}
+			return false
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+// matchSet reports whether c is in a [] set, e.g. a-z or !0-9
+func matchSet(set string, c byte) bool {
+	negate := strings.HasPrefix(set, "!")
+	if negate {
+		set = set[1:]
+	}
+
+	found := false
+	for i := 0; i < len(set); i++ {
+		if i+2 < len(set) && set[i+1] == '-' {
+			if set[i] <= c && c <= set[i+2] {
+				found = true
+			}
+			i += 2
+			continue
+		}
+		if set[i] == c {
+			found = true
+		}
+	}
+	return found != negate
+}
@@ -0,0 +1,332 @@
+// Package osc encodes and decodes Open Sound Control 1.0 packets, as sent by
+// controllers like TouchOSC and by livecoding environments.
+package osc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// bundleTag starts every OSC bundle
+const bundleTag = "#bundle"
+
+// ntpEpochOffset is the number of seconds from 1900, where OSC time tags
+// count from, to 1970
+const ntpEpochOffset = 2208988800
+
+// Message is one OSC message: an address pattern and its arguments. Arguments
+// are int32, int64, float32, float64, string, []byte, bool or nil.
+type Message struct {
+	Address string
+	Args    []interface{}
+}
+
+// String renders the message the way OSC monitors do, e.g. /lcg/mark "drop"
+func (m Message) String() string {
+	parts := []string{m.Address}
+	for _, arg := range m.Args {
+		switch v := arg.(type) {
+		case string:
+			parts = append(parts, fmt.Sprintf("%q", v))
+		case []byte:
+			parts = append(parts, fmt.Sprintf("<%d bytes>", len(v)))
+		default:
+			parts = append(parts, fmt.Sprint(v))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Parse decodes a packet into its messages. A bundle, which may nest other
+// bundles, yields every message it contains in order.
+func Parse(packet []byte) ([]Message, error) {
+	if len(packet) == 0 {
+		return nil, fmt.Errorf("empty OSC packet")
+	}
+
+	if packet[0] == '#' {
+		return parseBundle(packet)
+	}
+
+	message, err := parseMessage(packet)
+	if err != nil {
+		return nil, err
+	}
+	return []Message{message}, nil
+}
+
+// BundleTime returns when a bundle is to be performed, or false for a
+// message or a bundle to perform immediately
+func BundleTime(packet []byte) (time.Time, bool) {
+	r := &reader{data: packet}
+	if tag, err := r.string(); err != nil || tag != bundleTag {
+		return time.Time{}, false
+	}
+	tag, err := r.bytes(8)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	seconds := binary.BigEndian.Uint32(tag[:4])
+	fraction := binary.BigEndian.Uint32(tag[4:])
+	if seconds == 0 && fraction <= 1 {
+		return time.Time{}, false
+	}
+	nanos := int64(fraction) * int64(time.Second) >> 32
+	return time.Unix(int64(seconds)-ntpEpochOffset, nanos), true
+}
+
+// Bundle encodes messages as a bundle to perform at the given time, or
+// immediately when it is zero
+func Bundle(at time.Time, messages ...Message) ([]byte, error) {
+	var packet bytes.Buffer
+	writeString(&packet, bundleTag)
+
+	if at.IsZero() {
+		binary.Write(&packet, binary.BigEndian, uint64(1))
+	} else {
+		seconds := uint32(at.Unix() + ntpEpochOffset)
+		fraction := uint32((int64(at.Nanosecond()) << 32) / int64(time.Second))
+		binary.Write(&packet, binary.BigEndian, seconds)
+		binary.Write(&packet, binary.BigEndian, fraction)
+	}
+
+	for _, message := range messages {
+		element, err := message.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		binary.Write(&packet, binary.BigEndian, int32(len(element)))
+		packet.Write(element)
+	}
+	return packet.Bytes(), nil
+}
+
+// parseBundle decodes "#bundle", a time tag and size-prefixed elements
+func parseBundle(packet []byte) ([]Message, error) {
+	r := &reader{data: packet}
+
+	tag, err := r.string()
+	if err != nil || tag != bundleTag {
+		return nil, fmt.Errorf("invalid OSC bundle header")
+	}
+	if _, err := r.bytes(8); err != nil { // time tag; messages are handled on arrival
+		return nil, fmt.Errorf("truncated OSC bundle time tag")
+	}
+
+	var messages []Message
+	for r.remaining() > 0 {
+		size, err := r.int32()
+		if err != nil {
+			return nil, fmt.Errorf("truncated OSC bundle element size")
+		}
+		if size < 0 || int(size) > r.remaining() {
+			return nil, fmt.Errorf("OSC bundle element size %d exceeds packet", size)
+		}
+
+		element, _ := r.bytes(int(size))
+		nested, err := Parse(element)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, nested...)
+	}
+
+	return messages, nil
+}
+
+// parseMessage decodes an address, a type tag string and the arguments
+func parseMessage(packet []byte) (Message, error) {
+	r := &reader{data: packet}
+
+	address, err := r.string()
+	if err != nil {
+		return Message{}, fmt.Errorf("invalid OSC address: %w", err)
+	}
+	if !strings.HasPrefix(address, "/") {
+		return Message{}, fmt.Errorf("invalid OSC address %q", address)
+	}
+
+	message := Message{Address: address}
+
+	// Very old senders omit the type tag string entirely
+	if r.remaining() == 0 {
+		return message, nil
+	}
+
+	tags, err := r.string()
+	if err != nil || !strings.HasPrefix(tags, ",") {
+		return Message{}, fmt.Errorf("invalid OSC type tags for %s", address)
+	}
+
+	for _, tag := range tags[1:] {
+		var arg interface{}
+		var err error
+
+		switch tag {
+		case 'i':
+			arg, err = r.int32()
+		case 'h':
+			arg, err = r.int64()
+		case 'f':
+			var bits int32
+			bits, err = r.int32()
+			arg = math.Float32frombits(uint32(bits))
+		case 'd':
+			var bits int64
+			bits, err = r.int64()
+			arg = math.Float64frombits(uint64(bits))
+		case 's', 'S':
+			arg, err = r.string()
+		case 'b':
+			arg, err = r.blob()
+		case 'T':
+			arg = true
+		case 'F':
+			arg = false
+		case 'N', 'I':
+			arg = nil
+		default:
+			return Message{}, fmt.Errorf("unsupported OSC type tag %q in %s", tag, address)
+		}
+
+		if err != nil {
+			return Message{}, fmt.Errorf("truncated OSC argument in %s: %w", address, err)
+		}
+		message.Args = append(message.Args, arg)
+	}
+
+	return message, nil
+}
+
+// MarshalBinary encodes the message as an OSC packet
+func (m Message) MarshalBinary() ([]byte, error) {
+	var tags strings.Builder
+	var args bytes.Buffer
+	tags.WriteByte(',')
+
+	for _, arg := range m.Args {
+		switch v := arg.(type) {
+		case int32:
+			tags.WriteByte('i')
+			binary.Write(&args, binary.BigEndian, v)
+		case int:
+			tags.WriteByte('i')
+			binary.Write(&args, binary.BigEndian, int32(v))
+		case int64:
+			tags.WriteByte('h')
+			binary.Write(&args, binary.BigEndian, v)
+		case float32:
+			tags.WriteByte('f')
+			binary.Write(&args, binary.BigEndian, math.Float32bits(v))
+		case float64:
+			tags.WriteByte('d')
+			binary.Write(&args, binary.BigEndian, math.Float64bits(v))
+		case string:
+			tags.WriteByte('s')
+			writeString(&args, v)
+		case []byte:
+			tags.WriteByte('b')
+			binary.Write(&args, binary.BigEndian, int32(len(v)))
+			args.Write(v)
+			args.Write(make([]byte, padding(len(v))))
+		case bool:
+			if v {
+				tags.WriteByte('T')
+			} else {
+				tags.WriteByte('F')
+			}
+		case nil:
+			tags.WriteByte('N')
+		default:
+			return nil, fmt.Errorf("unsupported OSC argument type %T", arg)
+		}
+	}
+
+	var packet bytes.Buffer
+	writeString(&packet, m.Address)
+	writeString(&packet, tags.String())
+	packet.Write(args.Bytes())
+
+	return packet.Bytes(), nil
+}
+
+// writeString writes a NUL-terminated string padded to a multiple of 4 bytes
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteString(s)
+	buf.Write(make([]byte, 4-len(s)%4))
+}
+
+// padding returns how many bytes pad n bytes to a multiple of 4
+func padding(n int) int {
+	return (4 - n%4) % 4
+}
+
+// reader reads the aligned fields of an OSC packet
+type reader struct {
+	data []byte
+	pos  int
+}
+
+func (r *reader) remaining() int {
+	return len(r.data) - r.pos
+}
+
+func (r *reader) bytes(n int) ([]byte, error) {
+	if n > r.remaining() {
+		return nil, fmt.Errorf("need %d bytes, have %d", n, r.remaining())
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *reader) int32() (int32, error) {
+	b, err := r.bytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.BigEndian.Uint32(b)), nil
+}
+
+func (r *reader) int64() (int64, error) {
+	b, err := r.bytes(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+func (r *reader) string() (string, error) {
+	end := bytes.IndexByte(r.data[r.pos:], 0)
+	if end < 0 {
+		return "", fmt.Errorf("unterminated string")
+	}
+	s := string(r.data[r.pos : r.pos+end])
+	if _, err := r.bytes(end + 1 + padding(end+1)); err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+func (r *reader) blob() ([]byte, error) {
+	size, err := r.int32()
+	if err != nil {
+		return nil, err
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("negative blob size")
+	}
+	b, err := r.bytes(int(size))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.bytes(padding(int(size))); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
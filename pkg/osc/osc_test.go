@@ -0,0 +1,137 @@
+package osc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	message := Message{
+		Address: "/lcg/mark",
+		Args:    []interface{}{"drop", int32(7), float32(1), int64(-3), 0.5, []byte{1, 2, 3}, true, false, nil},
+	}
+
+	packet, err := message.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed to encode message: %v", err)
+	}
+	if len(packet)%4 != 0 {
+		t.Errorf("Expected packet aligned to 4 bytes, got %d bytes", len(packet))
+	}
+
+	messages, err := Parse(packet)
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(messages))
+	}
+	if !reflect.DeepEqual(messages[0], message) {
+		t.Errorf("Expected %v, got %v", message, messages[0])
+	}
+}
+
+func TestParseKnownPacket(t *testing.T) {
+	// /lcg/snapshot with one float argument, as a TouchOSC button sends it
+	packet := []byte("/lcg/snapshot\x00\x00\x00,f\x00\x00\x3f\x80\x00\x00")
+
+	messages, err := Parse(packet)
+	if err != nil {
+		t.Fatalf("Failed to parse packet: %v", err)
+	}
+	if messages[0].Address != "/lcg/snapshot" || messages[0].Args[0] != float32(1) {
+		t.Errorf("Expected /lcg/snapshot 1, got %v", messages[0])
+	}
+}
+
+func TestParseBundle(t *testing.T) {
+	first, _ := Message{Address: "/lcg/mark", Args: []interface{}{"intro"}}.MarshalBinary()
+	second, _ := Message{Address: "/lcg/performance/end"}.MarshalBinary()
+
+	var bundle bytes.Buffer
+	bundle.WriteString("#bundle\x00")
+	bundle.Write([]byte{0, 0, 0, 0, 0, 0, 0, 1}) // immediately
+	for _, element := range [][]byte{first, second} {
+		binary.Write(&bundle, binary.BigEndian, int32(len(element)))
+		bundle.Write(element)
+	}
+
+	messages, err := Parse(bundle.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to parse bundle: %v", err)
+	}
+	if len(messages) != 2 || messages[0].Address != "/lcg/mark" || messages[1].Address != "/lcg/performance/end" {
+		t.Errorf("Expected both bundled messages, got %v", messages)
+	}
+}
+
+func TestParseRejectsMalformedPackets(t *testing.T) {
+	packets := map[string][]byte{
+		"empty":          {},
+		"no address":     []byte("lcg\x00"),
+		"unterminated":   []byte("/lcg/mark"),
+		"truncated arg":  []byte("/lcg\x00\x00\x00\x00,i\x00\x00\x00\x01"),
+		"unknown tag":    []byte("/lcg\x00\x00\x00\x00,x\x00\x00"),
+		"oversized elem": []byte("#bundle\x00\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x10"),
+	}
+
+	for name, packet := range packets {
+		if _, err := Parse(packet); err == nil {
+			t.Errorf("Expected error for %s packet", name)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		address string
+		matches bool
+	}{
+		{"/foxdot/eval", "/foxdot/eval", true},
+		{"/foxdot/eval", "/foxdot/evaluate", false},
+		{"/*/eval", "/foxdot/eval", true},
+		{"/*", "/foxdot/eval", false},
+		{"/eval/p?", "/eval/p1", true},
+		{"/eval/p?", "/eval/p10", false},
+		{"/eval/p[0-9]", "/eval/p7", true},
+		{"/eval/p[!0-9]", "/eval/p7", false},
+		{"/eval/{d1,d2,hh}", "/eval/hh", true},
+		{"/eval/{d1,d2}", "/eval/d3", false},
+		{"/*/{run,eval}*", "/orca/evaluate", true},
+	}
+
+	for _, test := range tests {
+		if matches := Match(test.pattern, test.address); matches != test.matches {
+			t.Errorf("Expected Match(%q, %q) to be %v", test.pattern, test.address, test.matches)
+		}
+	}
+}
+
+func TestBundleTime(t *testing.T) {
+	at := time.Date(2024, 5, 17, 22, 30, 0, 250_000_000, time.UTC)
+	packet, err := Bundle(at, Message{Address: "/dirt/play", Args: []interface{}{"s", "bd"}})
+	if err != nil {
+		t.Fatalf("Failed to encode bundle: %v", err)
+	}
+
+	when, ok := BundleTime(packet)
+	if !ok || when.Sub(at).Abs() > time.Microsecond {
+		t.Errorf("Expected the bundle at %s, got %s (%v)", at, when, ok)
+	}
+	if messages, err := Parse(packet); err != nil || len(messages) != 1 || messages[0].Args[1] != "bd" {
+		t.Errorf("Expected the bundled message back, got %v (%v)", messages, err)
+	}
+
+	immediate, _ := Bundle(time.Time{})
+	if _, ok := BundleTime(immediate); ok {
+		t.Error("Expected no time for an immediate bundle")
+	}
+	message, _ := Message{Address: "/dirt/play"}.MarshalBinary()
+	if _, ok := BundleTime(message); ok {
+		t.Error("Expected no time for a message")
+	}
+}
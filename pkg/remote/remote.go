@@ -0,0 +1,466 @@
+// Package remote synchronizes copies of a repository over HTTP, e.g. between
+// a laptop and a studio machine. One side runs 'lcg serve --sync'; the other
+// compares the hashes in its index with the remote's and only sends, or
+// asks for, the commits the other side is missing. Tags and performances
+// travel along with the commits.
+package remote
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/storage"
+)
+
+// Endpoints of the sync protocol
+const (
+	RefsPath  = "/sync/refs"  // GET: what the remote has
+	FetchPath = "/sync/fetch" // POST FetchRequest: commits the client wants
+	PushPath  = "/sync/push"  // POST Pack: commits the remote is missing
+)
+
+// TokenEnv holds the token both sides use when the server requires one
+const TokenEnv = "LCG_SYNC_TOKEN"
+
+// maxPackSize bounds the body of a push
+const maxPackSize = 256 << 20
+
+// Refs describes the contents of a repository: the hashes of its commits,
+// oldest first, its tags and its performances
+type Refs struct {
+	Head         string              `json:"head,omitempty"`
+	Commits      []string            `json:"commits"`
+	Tags         map[string]string   `json:"tags,omitempty"`
+	Performances []*core.Performance `json:"performances,omitempty"`
+}
+
+// FetchRequest names the commits a client wants
+type FetchRequest struct {
+	Want []string `json:"want"`
+}
+
+// Pack carries commits, oldest first, with tags and performances
+type Pack struct {
+	Commits      []*core.Commit      `json:"commits"`
+	Tags         map[string]string   `json:"tags,omitempty"`
+	Performances []*core.Performance `json:"performances,omitempty"`
+}
+
+// Result tells what a pack changed in a repository
+type Result struct {
+	Commits      int `json:"commits"`
+	Tags         int `json:"tags"`
+	Performances int `json:"performances"`
+
+	// Tags the pack named differently; the local tag is kept
+	TagConflicts []string `json:"tag_conflicts,omitempty"`
+}
+
+// UpToDate reports whether the pack changed nothing
+func (r *Result) UpToDate() bool {
+	return r.Commits == 0 && r.Tags == 0 && r.Performances == 0
+}
+
+// ReadRefs describes a repository for the other side of a sync
+func ReadRefs(repo *core.LiveCodeRepository) (*Refs, error) {
+	tags, err := repo.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tags: %w", err)
+	}
+	performances, err := repo.ListPerformances()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list performances: %w", err)
+	}
+
+	refs := &Refs{Commits: repo.CommitHashes(), Tags: tags, Performances: performances}
+	if len(refs.Commits) > 0 {
+		refs.Head = refs.Commits[len(refs.Commits)-1]
+	}
+	return refs, nil
+}
+
+// Missing returns the hashes in have that aren't in theirs, in order
+func Missing(have, theirs []string) []string {
+	known := make(map[string]bool, len(theirs))
+	for _, hash := range theirs {
+		known[hash] = true
+	}
+
+	var missing []string
+	for _, hash := range have {
+		if !known[hash] {
+			missing = append(missing, hash)
+		}
+	}
+	return missing
+}
+
+// ReadPack reads the commits named by want from a repository
+func ReadPack(repo *core.LiveCodeRepository, want []string) (*Pack, error) {
+	pack := &Pack{Commits: make([]*core.Commit, 0, len(want))}
+	for _, hash := range want {
+		commit, err := repo.GetCommit(hash)
+		if err != nil {
+			return nil, fmt.Errorf("commit %s not found", hash)
+		}
+		pack.Commits = append(pack.Commits, commit)
+	}
+	return pack, nil
+}
+
+// Apply stores a pack in a repository: commits first, then the tags and
+// performances that refer to them
+func Apply(repo *core.LiveCodeRepository, pack *Pack) (*Result, error) {
+	added, err := repo.ReceiveCommits(pack.Commits)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Commits: len(added)}
+
+	local, err := repo.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tags: %w", err)
+	}
+	names := make([]string, 0, len(pack.Tags))
+	for name := range pack.Tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		hash := pack.Tags[name]
+		switch current, exists := local[name]; {
+		case !exists:
+			if err := repo.Tag(name, hash); err != nil {
+				return nil, fmt.Errorf("failed to tag %s: %w", name, err)
+			}
+			result.Tags++
+		case current != hash:
+			result.TagConflicts = append(result.TagConflicts, name)
+		}
+	}
+
+	for _, performance := range pack.Performances {
+		written, err := repo.ReceivePerformance(performance)
+		if err != nil {
+			return nil, err
+		}
+		if written {
+			result.Performances++
+		}
+	}
+	return result, nil
+}
+
+// Handler serves the sync protocol for the repository at path. With a
+// token, requests must carry it as a bearer token.
+type Handler struct {
+	path  string
+	token string
+
+	// Pushes are applied one at a time
+	mu sync.Mutex
+}
+
+// NewHandler serves the repository at path to remotes
+func NewHandler(path, token string) *Handler {
+	return &Handler{path: path, token: token}
+}
+
+// ServeHTTP answers sync requests
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.token != "" {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(h.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid or missing sync token")
+			return
+		}
+	}
+
+	switch {
+	case r.URL.Path == RefsPath && r.Method == http.MethodGet:
+		h.handleRefs(w)
+	case r.URL.Path == FetchPath && r.Method == http.MethodPost:
+		h.handleFetch(w, r)
+	case r.URL.Path == PushPath && r.Method == http.MethodPost:
+		h.handlePush(w, r)
+	case r.URL.Path == RefsPath || r.URL.Path == FetchPath || r.URL.Path == PushPath:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (h *Handler) handleRefs(w http.ResponseWriter) {
+	repo, err := core.LoadRepository(h.path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	refs, err := ReadRefs(repo)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, refs)
+}
+
+func (h *Handler) handleFetch(w http.ResponseWriter, r *http.Request) {
+	var request FetchRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxPackSize)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid fetch request: %v", err))
+		return
+	}
+
+	repo, err := core.LoadRepository(h.path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	pack, err := ReadPack(repo, request.Want)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, pack)
+}
+
+func (h *Handler) handlePush(w http.ResponseWriter, r *http.Request) {
+	var pack Pack
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPackSize)).Decode(&pack); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid pack: %v", err))
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	repo, err := core.LoadRepository(h.path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	result, err := Apply(repo, &pack)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeJSON(w, result)
+}
+
+// writeJSON answers with a JSON document
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeError answers with a JSON error and status
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// Source is the other side of a pull: a remote reached over HTTP or another
+// repository on this machine
+type Source interface {
+	Refs() (*Refs, error)
+	Fetch(want []string) (*Pack, error)
+}
+
+// Local reads another repository on this machine as a Source
+type Local struct {
+	repo *core.LiveCodeRepository
+}
+
+// NewLocal opens the repository at path as a Source
+func NewLocal(path string) (*Local, error) {
+	repo, err := core.LoadRepository(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Local{repo: repo}, nil
+}
+
+// Refs describes the local repository
+func (l *Local) Refs() (*Refs, error) {
+	return ReadRefs(l.repo)
+}
+
+// Fetch reads commits from the local repository
+func (l *Local) Fetch(want []string) (*Pack, error) {
+	return ReadPack(l.repo, want)
+}
+
+// Client talks to a remote's sync endpoints
+type Client struct {
+	url   string
+	token string
+	http  *http.Client
+}
+
+// NewClient connects to the 'lcg serve --sync' at url
+func NewClient(url, token string) *Client {
+	return &Client{
+		url:   strings.TrimSuffix(url, "/"),
+		token: token,
+		http:  &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// Refs asks what the remote has
+func (c *Client) Refs() (*Refs, error) {
+	var refs Refs
+	if err := c.do(http.MethodGet, RefsPath, nil, &refs); err != nil {
+		return nil, err
+	}
+	return &refs, nil
+}
+
+// Fetch asks the remote for commits
+func (c *Client) Fetch(want []string) (*Pack, error) {
+	var pack Pack
+	if err := c.do(http.MethodPost, FetchPath, FetchRequest{Want: want}, &pack); err != nil {
+		return nil, err
+	}
+	return &pack, nil
+}
+
+// Push sends the remote a pack to apply
+func (c *Client) Push(pack *Pack) (*Result, error) {
+	var result Result
+	if err := c.do(http.MethodPost, PushPath, pack, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// do sends a request with an optional JSON body and decodes the answer
+func (c *Client) do(method, path string, body, answer interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequest(method, c.url+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(response.Body).Decode(&failure)
+		switch {
+		case response.StatusCode == http.StatusNotFound && path == RefsPath:
+			return fmt.Errorf("%s does not accept sync (start it with 'lcg serve --sync')", c.url)
+		case failure.Error != "":
+			return errors.New(failure.Error)
+		}
+		return fmt.Errorf("%s answered %s", c.url, response.Status)
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(answer); err != nil {
+		return fmt.Errorf("failed to read answer from %s: %w", c.url, err)
+	}
+	return nil
+}
+
+// Pull brings the commits, tags and performances of source into repo
+func Pull(repo *core.LiveCodeRepository, source Source) (*Result, error) {
+	refs, err := source.Refs()
+	if err != nil {
+		return nil, err
+	}
+
+	pack := &Pack{Tags: refs.Tags, Performances: refs.Performances}
+	if want := Missing(refs.Commits, repo.CommitHashes()); len(want) > 0 {
+		fetched, err := source.Fetch(want)
+		if err != nil {
+			return nil, err
+		}
+		pack.Commits = fetched.Commits
+	}
+	return Apply(repo, pack)
+}
+
+// Push sends the remote the commits it is missing from repo, with every tag
+// and performance; the remote keeps its own tags when they differ
+func Push(repo *core.LiveCodeRepository, client *Client) (*Result, error) {
+	refs, err := client.Refs()
+	if err != nil {
+		return nil, err
+	}
+
+	local, err := ReadRefs(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	pack := &Pack{Commits: []*core.Commit{}, Tags: local.Tags, Performances: local.Performances}
+	for _, hash := range Missing(local.Commits, refs.Commits) {
+		commit, err := repo.GetCommit(hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit %s: %w", hash, err)
+		}
+		pack.Commits = append(pack.Commits, commit)
+	}
+	return client.Push(pack)
+}
+
+// Clone creates a repository at path holding everything in source. Commits
+// are checked against their hashes on the way in; when anything fails, what
+// the clone created at path is removed again.
+func Clone(source Source, path string) (repo *core.LiveCodeRepository, result *Result, err error) {
+	created := path
+	if _, statErr := os.Stat(path); statErr == nil {
+		created = filepath.Join(path, storage.RepoDir)
+	}
+
+	repo = core.NewRepository(path)
+	if err := repo.Init(path); err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(created)
+		}
+	}()
+
+	result, err = Pull(repo, source)
+	if err != nil {
+		return nil, nil, err
+	}
+	return repo, result, nil
+}
@@ -0,0 +1,194 @@
+package remote
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+func createTestRepository(t *testing.T) (*core.LiveCodeRepository, string) {
+	path := t.TempDir()
+	repo := core.NewRepository(path)
+	if err := repo.Init(path); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	return repo, path
+}
+
+func commit(t *testing.T, repo *core.LiveCodeRepository, content string) *core.Commit {
+	c, err := repo.Commit(content, "Edit", core.ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	return c
+}
+
+func TestMissing(t *testing.T) {
+	missing := Missing([]string{"a", "b", "c", "d"}, []string{"c", "a", "x"})
+	if len(missing) != 2 || missing[0] != "b" || missing[1] != "d" {
+		t.Errorf("Expected [b d], got %v", missing)
+	}
+}
+
+func TestPushAndPull(t *testing.T) {
+	laptop, _ := createTestRepository(t)
+	studio, studioPath := createTestRepository(t)
+
+	server := httptest.NewServer(NewHandler(studioPath, ""))
+	defer server.Close()
+	client := NewClient(server.URL+"/", "")
+
+	first := commit(t, laptop, "d1 $ s \"bd\"")
+	commit(t, laptop, "d1 $ s \"bd sn\"")
+	if err := laptop.Tag("drop", first.Hash); err != nil {
+		t.Fatalf("Failed to tag: %v", err)
+	}
+	if _, err := laptop.StartPerformance("Algorave"); err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+	if err := laptop.EndPerformance(); err != nil {
+		t.Fatalf("Failed to end performance: %v", err)
+	}
+
+	result, err := Push(laptop, client)
+	if err != nil {
+		t.Fatalf("Failed to push: %v", err)
+	}
+	if result.Commits != 2 || result.Tags != 1 || result.Performances != 1 {
+		t.Errorf("Expected 2 commits, 1 tag and 1 performance pushed, got %+v", result)
+	}
+
+	// Pushing again only sends what's missing, which is nothing
+	if result, err := Push(laptop, client); err != nil || !result.UpToDate() {
+		t.Errorf("Expected nothing to push, got %+v (%v)", result, err)
+	}
+
+	// The studio repository is read fresh, as the server does
+	studio, err = core.LoadRepository(studioPath)
+	if err != nil {
+		t.Fatalf("Failed to load repository: %v", err)
+	}
+	if hashes := studio.CommitHashes(); len(hashes) != 2 || hashes[0] != first.Hash {
+		t.Errorf("Expected the studio to hold the laptop's commits, got %v", hashes)
+	}
+
+	// Work done in the studio comes back with a pull
+	studioCommit := commit(t, studio, "d2 $ s \"hh*8\"")
+	if err := studio.Tag("drop", studioCommit.Hash); err != nil {
+		t.Fatalf("Failed to retag: %v", err)
+	}
+
+	result, err = Pull(laptop, client)
+	if err != nil {
+		t.Fatalf("Failed to pull: %v", err)
+	}
+	if result.Commits != 1 {
+		t.Errorf("Expected 1 commit pulled, got %+v", result)
+	}
+	if len(result.TagConflicts) != 1 || result.TagConflicts[0] != "drop" {
+		t.Errorf("Expected a conflict on tag drop, got %v", result.TagConflicts)
+	}
+	if tags, _ := laptop.Tags(); tags["drop"] != first.Hash {
+		t.Errorf("Expected the local tag to be kept, got %s", tags["drop"])
+	}
+	if head, _ := laptop.Log(1); len(head) != 1 || head[0].Hash != studioCommit.Hash {
+		t.Errorf("Expected HEAD at the pulled commit")
+	}
+}
+
+func TestHandlerToken(t *testing.T) {
+	_, path := createTestRepository(t)
+	server := httptest.NewServer(NewHandler(path, "secret"))
+	defer server.Close()
+
+	if _, err := NewClient(server.URL, "").Refs(); err == nil {
+		t.Errorf("Expected a request without the token to be refused")
+	}
+	if _, err := NewClient(server.URL, "secret").Refs(); err != nil {
+		t.Errorf("Expected the token to be accepted: %v", err)
+	}
+
+	response, err := http.Post(server.URL+RefsPath, "application/json", nil)
+	if err != nil {
+		t.Fatalf("Failed to post: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 before anything else, got %d", response.StatusCode)
+	}
+}
+
+func TestClone(t *testing.T) {
+	original, originalPath := createTestRepository(t)
+	first := commit(t, original, "d1 $ s \"bd\"")
+	last := commit(t, original, "d1 $ s \"bd sn\"")
+	if err := original.Tag("drop", first.Hash); err != nil {
+		t.Fatalf("Failed to tag: %v", err)
+	}
+
+	local, err := NewLocal(originalPath)
+	if err != nil {
+		t.Fatalf("Failed to open repository: %v", err)
+	}
+	clonePath := filepath.Join(t.TempDir(), "copy")
+	clone, result, err := Clone(local, clonePath)
+	if err != nil {
+		t.Fatalf("Failed to clone: %v", err)
+	}
+	if result.Commits != 2 || result.Tags != 1 {
+		t.Errorf("Expected 2 commits and 1 tag cloned, got %+v", result)
+	}
+	if head, _ := clone.Log(1); len(head) != 1 || head[0].Hash != last.Hash {
+		t.Errorf("Expected HEAD at the last commit of the original")
+	}
+
+	// Over HTTP, the same
+	server := httptest.NewServer(NewHandler(originalPath, ""))
+	defer server.Close()
+	clone, _, err = Clone(NewClient(server.URL, ""), t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to clone over HTTP: %v", err)
+	}
+	if hashes := clone.CommitHashes(); len(hashes) != 2 || hashes[1] != last.Hash {
+		t.Errorf("Expected the original's commits, got %v", hashes)
+	}
+
+	// Cloning into a repository is refused, and leaves it alone
+	if _, _, err := Clone(local, clonePath); err == nil {
+		t.Errorf("Expected cloning over a repository to fail")
+	}
+	if _, err := core.LoadRepository(clonePath); err != nil {
+		t.Errorf("Expected the earlier clone to survive: %v", err)
+	}
+
+	// A failed clone leaves nothing behind
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	failedPath := filepath.Join(t.TempDir(), "failed")
+	if _, _, err := Clone(NewClient(closed.URL, ""), failedPath); err == nil {
+		t.Errorf("Expected cloning from an unreachable server to fail")
+	}
+	if _, err := os.Stat(failedPath); !os.IsNotExist(err) {
+		t.Errorf("Expected a failed clone to remove its directory")
+	}
+}
+
+func TestApplyRefusesTamperedCommit(t *testing.T) {
+	laptop, _ := createTestRepository(t)
+	studio, _ := createTestRepository(t)
+
+	c := commit(t, laptop, "d1 $ s \"bd\"")
+	tampered := *c
+	tampered.Content = "d1 $ s \"evil\""
+
+	if _, err := Apply(studio, &Pack{Commits: []*core.Commit{&tampered}}); err == nil {
+		t.Errorf("Expected a commit not matching its hash to be refused")
+	}
+	if len(studio.CommitHashes()) != 0 {
+		t.Errorf("Expected nothing stored")
+	}
+}
@@ -0,0 +1,122 @@
+// Package replay plays a performance's commits back with their original
+// timing, handing each one to sinks that reproduce the session on a terminal,
+// over OSC or into buffer files.
+package replay
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+// Event is one commit coming due during a replay
+type Event struct {
+	Commit *core.Commit
+	Index  int           // position in the replay, from 0
+	Total  int           // number of commits being replayed
+	Offset time.Duration // time since the first commit in the original session
+}
+
+// Sink receives each commit as it comes due; an error stops the replay
+type Sink func(event Event) error
+
+// Options controls replay timing
+type Options struct {
+	Speed  float64       // 1 replays in real time, 2 twice as fast; 0 means 1
+	MaxGap time.Duration // longest wait between commits after scaling; 0 waits as recorded
+}
+
+// Player replays commits in timestamp order
+type Player struct {
+	commits []*core.Commit
+	options Options
+	sinks   []Sink
+	sleep   func(ctx context.Context, d time.Duration) error
+}
+
+// NewPlayer creates a player for commits, which need not be in order
+func NewPlayer(commits []*core.Commit, options Options, sinks ...Sink) *Player {
+	sorted := make([]*core.Commit, len(commits))
+	copy(sorted, commits)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	if options.Speed <= 0 {
+		options.Speed = 1
+	}
+
+	return &Player{
+		commits: sorted,
+		options: options,
+		sinks:   sinks,
+		sleep:   sleepContext,
+	}
+}
+
+// Duration returns how long the replay takes at the configured speed
+func (p *Player) Duration() time.Duration {
+	var total time.Duration
+	for i := 1; i < len(p.commits); i++ {
+		total += p.wait(p.commits[i-1], p.commits[i])
+	}
+	return total
+}
+
+// Play sends every commit to the sinks as it comes due. It returns early
+// with the context's error when the context is cancelled.
+func (p *Player) Play(ctx context.Context) error {
+	for i, commit := range p.commits {
+		if i > 0 {
+			if err := p.sleep(ctx, p.wait(p.commits[i-1], commit)); err != nil {
+				return err
+			}
+		}
+
+		event := Event{
+			Commit: commit,
+			Index:  i,
+			Total:  len(p.commits),
+			Offset: commit.Timestamp.Sub(p.commits[0].Timestamp),
+		}
+		for _, sink := range p.sinks {
+			if err := sink(event); err != nil {
+				return fmt.Errorf("failed to replay commit %s: %w", commit.Hash[:8], err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// wait returns the scaled pause between two consecutive commits
+func (p *Player) wait(previous, next *core.Commit) time.Duration {
+	gap := time.Duration(float64(next.Timestamp.Sub(previous.Timestamp)) / p.options.Speed)
+	if gap < 0 {
+		gap = 0
+	}
+	if p.options.MaxGap > 0 && gap > p.options.MaxGap {
+		gap = p.options.MaxGap
+	}
+	return gap
+}
+
+// sleepContext waits for d or until ctx is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,181 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/osc"
+)
+
+// testCommits returns three commits recorded 10s and then 30s apart, out of order
+func testCommits() []*core.Commit {
+	start := time.Date(2026, 3, 14, 21, 0, 0, 0, time.UTC)
+	commit := func(hash, buffer, content string, offset time.Duration) *core.Commit {
+		return &core.Commit{
+			Hash:      hash,
+			Timestamp: start.Add(offset),
+			Content:   content,
+			Metadata:  core.ExecutionMetadata{Buffer: buffer, Language: "tidal", Success: true},
+		}
+	}
+
+	return []*core.Commit{
+		commit("cccccccccccccccccccccccccccccccccccccccc", "d1", "d1 $ s \"bd*4\"", 40*time.Second),
+		commit("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "d1", "d1 $ s \"bd\"", 0),
+		commit("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "d2", "d2 $ s \"hh*8\"", 10*time.Second),
+	}
+}
+
+func TestPlayerTiming(t *testing.T) {
+	var played []Event
+	player := NewPlayer(testCommits(), Options{Speed: 2}, func(event Event) error {
+		played = append(played, event)
+		return nil
+	})
+
+	var waits []time.Duration
+	player.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	if err := player.Play(context.Background()); err != nil {
+		t.Fatalf("Failed to play: %v", err)
+	}
+
+	if len(played) != 3 {
+		t.Fatalf("Expected 3 replayed commits, got %d", len(played))
+	}
+	for i, content := range []string{"d1 $ s \"bd\"", "d2 $ s \"hh*8\"", "d1 $ s \"bd*4\""} {
+		if played[i].Commit.Content != content || played[i].Index != i || played[i].Total != 3 {
+			t.Errorf("Expected commit %d to be '%s', got '%s' (%d/%d)", i, content, played[i].Commit.Content, played[i].Index, played[i].Total)
+		}
+	}
+	if played[2].Offset != 40*time.Second {
+		t.Errorf("Expected last offset 40s, got %v", played[2].Offset)
+	}
+
+	// Gaps of 10s and 30s at double speed
+	if len(waits) != 2 || waits[0] != 5*time.Second || waits[1] != 15*time.Second {
+		t.Errorf("Expected waits [5s 15s], got %v", waits)
+	}
+	if player.Duration() != 20*time.Second {
+		t.Errorf("Expected duration 20s, got %v", player.Duration())
+	}
+}
+
+func TestPlayerMaxGap(t *testing.T) {
+	player := NewPlayer(testCommits(), Options{MaxGap: 12 * time.Second})
+
+	if player.Duration() != 22*time.Second {
+		t.Errorf("Expected duration 22s with gaps capped at 12s, got %v", player.Duration())
+	}
+}
+
+func TestPlayerStops(t *testing.T) {
+	// A sink error stops the replay
+	count := 0
+	player := NewPlayer(testCommits(), Options{Speed: 1000}, func(event Event) error {
+		count++
+		if count == 2 {
+			return errors.New("sink failed")
+		}
+		return nil
+	})
+	player.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+
+	if err := player.Play(context.Background()); err == nil {
+		t.Errorf("Expected sink error to stop the replay")
+	}
+	if count != 2 {
+		t.Errorf("Expected replay to stop after 2 commits, got %d", count)
+	}
+
+	// So does cancelling the context while waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	count = 0
+	player = NewPlayer(testCommits(), Options{}, func(event Event) error {
+		count++
+		cancel()
+		return nil
+	})
+
+	if err := player.Play(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected replay to stop after 1 commit, got %d", count)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "lcg-replay-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	dir := filepath.Join(tempDir, "buffers")
+	sink, err := FileSink(dir)
+	if err != nil {
+		t.Fatalf("Failed to create file sink: %v", err)
+	}
+
+	player := NewPlayer(testCommits(), Options{Speed: 1000}, sink)
+	player.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+	if err := player.Play(context.Background()); err != nil {
+		t.Fatalf("Failed to play: %v", err)
+	}
+
+	// Each buffer file holds the last code replayed into it
+	for name, content := range map[string]string{"d1.tidal": "d1 $ s \"bd*4\"", "d2.tidal": "d2 $ s \"hh*8\""} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		if string(data) != content {
+			t.Errorf("Expected %s to contain '%s', got '%s'", name, content, data)
+		}
+	}
+}
+
+func TestOSCSink(t *testing.T) {
+	listener, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	sink, err := NewOSCSink(listener.LocalAddr().String(), "")
+	if err != nil {
+		t.Fatalf("Failed to create OSC sink: %v", err)
+	}
+	defer sink.Close()
+
+	commit := testCommits()[1]
+	if err := sink.Send(Event{Commit: commit}); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+
+	buffer := make([]byte, 1024)
+	listener.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := listener.Read(buffer)
+	if err != nil {
+		t.Fatalf("Failed to receive: %v", err)
+	}
+
+	messages, err := osc.Parse(buffer[:n])
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("Expected 1 OSC message, got %d (%v)", len(messages), err)
+	}
+	expected := osc.Message{Address: DefaultOSCAddress, Args: []interface{}{"d1", "tidal", commit.Content}}
+	if messages[0].String() != expected.String() {
+		t.Errorf("Expected %v, got %v", expected, messages[0])
+	}
+}
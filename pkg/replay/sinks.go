@@ -0,0 +1,82 @@
+package replay
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/osc"
+	"github.com/livecodegit/pkg/watchers/sonicpi"
+)
+
+// DefaultOSCAddress is the address replayed commits are sent to over OSC
+const DefaultOSCAddress = "/lcg/replay"
+
+// FileSink writes each commit's code into dir, to the file its buffer is
+// checked out to, so an editor watching the files follows the replay
+func FileSink(dir string) (Sink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create replay directory: %w", err)
+	}
+
+	return func(event Event) error {
+		path := filepath.Join(dir, core.CheckoutFileName(event.Commit))
+		return os.WriteFile(path, []byte(event.Commit.Content), 0644)
+	}, nil
+}
+
+// SonicPiSink runs each Sonic Pi commit's code in Sonic Pi, re-triggering
+// the performance live; commits in other languages are skipped
+func SonicPiSink(client *sonicpi.Client) Sink {
+	return func(event Event) error {
+		if !strings.EqualFold(event.Commit.Metadata.Language, "sonicpi") {
+			return nil
+		}
+		return client.RunCode(event.Commit.Content)
+	}
+}
+
+// OSCSink sends each commit as an OSC message carrying its buffer, language
+// and code, e.g. to a patch that re-evaluates the code
+type OSCSink struct {
+	conn    net.Conn
+	address string
+}
+
+// NewOSCSink sends replayed commits to target, a UDP host:port, at address
+func NewOSCSink(target, address string) (*OSCSink, error) {
+	conn, err := net.Dial("udp", target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach OSC target %s: %w", target, err)
+	}
+
+	if address == "" {
+		address = DefaultOSCAddress
+	}
+
+	return &OSCSink{conn: conn, address: address}, nil
+}
+
+// Send is the sink function for the OSC target
+func (s *OSCSink) Send(event Event) error {
+	message := osc.Message{
+		Address: s.address,
+		Args:    []interface{}{event.Commit.Metadata.Buffer, event.Commit.Metadata.Language, event.Commit.Content},
+	}
+
+	data, err := message.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.conn.Write(data)
+	return err
+}
+
+// Close releases the connection to the OSC target
+func (s *OSCSink) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrPartialWrite marks a file cut short by an interrupted write, left by a
+// version that wrote files in place or by a crash the filesystem didn't
+// order writes around
+var ErrPartialWrite = errors.New("cut short by an interrupted write")
+
+// tempSuffix ends the temporary files written files go through
+const tempSuffix = ".tmp"
+
+// partialWriteAge is how long a temporary file goes untouched before it's
+// taken for one left by an interrupted write rather than one being written
+const partialWriteAge = time.Minute
+
+// writeAtomically writes a file through a temporary file renamed into
+// place once complete, so readers never see it half written
+func writeAtomically(path string, write func(io.Writer) error) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*"+tempSuffix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	writer := bufio.NewWriter(temp)
+	if err := write(writer); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if err := temp.Chmod(0644); err != nil {
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return err
+	}
+
+	// Make the rename itself durable; not every platform can sync a directory
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// writeFileAtomically writes data to the file at path atomically
+func writeFileAtomically(path string, data []byte) error {
+	return writeAtomically(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// isTempFile reports whether name is a temporary file of writeAtomically
+func isTempFile(name string) bool {
+	return strings.HasSuffix(name, tempSuffix)
+}
+
+// truncated reports whether err, from decoding data, shows the data ends
+// before the encoding does
+func truncated(data []byte, err error) bool {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return syntaxErr.Offset >= int64(len(data))
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// PartialWrites returns the temporary files interrupted writes left in the
+// repository, relative to its directory. Files written to in the last
+// minute are left out, as a writer may still be at them.
+func (fs *FileSystemStorage) PartialWrites() ([]string, error) {
+	repoDir := filepath.Join(fs.repoPath, RepoDir)
+	cutoff := time.Now().Add(-partialWriteAge)
+
+	var partial []string
+	err := filepath.WalkDir(repoDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !isTempFile(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil
+		}
+		rel, err := filepath.Rel(repoDir, path)
+		if err != nil {
+			return err
+		}
+		partial = append(partial, filepath.ToSlash(rel))
+		return nil
+	})
+	return partial, err
+}
+
+// RemovePartialWrite deletes a temporary file PartialWrites returned
+func (fs *FileSystemStorage) RemovePartialWrite(name string) error {
+	if !isTempFile(name) {
+		return fmt.Errorf("%s isn't a temporary file", name)
+	}
+	return os.Remove(filepath.Join(fs.repoPath, RepoDir, filepath.FromSlash(name)))
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAtomicWrites(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	fs := NewFileSystemStorage(tempDir)
+	if err := fs.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	commit := createTestCommit()
+	if err := fs.WriteCommit(commit); err != nil {
+		t.Fatalf("Failed to write commit: %v", err)
+	}
+	if err := fs.WriteHead(commit.Hash); err != nil {
+		t.Fatalf("Failed to write HEAD: %v", err)
+	}
+	if err := NewIndex(fs).SaveIndex(); err != nil {
+		t.Fatalf("Failed to save index: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(tempDir, RepoDir, HeadFile))
+	if err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("Expected HEAD readable by all, got %v (%v)", info.Mode(), err)
+	}
+	partial, err := fs.PartialWrites()
+	if err != nil || len(partial) != 0 {
+		t.Errorf("Expected no temporary files left behind, got %v (%v)", partial, err)
+	}
+
+	// Temporary files left by a crash, one old enough to be abandoned
+	objectDir := filepath.Join(tempDir, RepoDir, ObjectsDir, commit.Hash[:2])
+	stale := filepath.Join(objectDir, commit.Hash[2:]+".123"+tempSuffix)
+	fresh := filepath.Join(tempDir, RepoDir, IndexFile+".456"+tempSuffix)
+	for _, path := range []string{stale, fresh} {
+		if err := os.WriteFile(path, []byte("{\"hash\": "), 0644); err != nil {
+			t.Fatalf("Failed to write temporary file: %v", err)
+		}
+	}
+	old := time.Now().Add(-2 * partialWriteAge)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatalf("Failed to age temporary file: %v", err)
+	}
+
+	hashes, err := fs.ListCommits()
+	if err != nil || len(hashes) != 1 {
+		t.Errorf("Expected temporary files not to be listed as objects, got %v (%v)", hashes, err)
+	}
+
+	partial, err = fs.PartialWrites()
+	if err != nil || len(partial) != 1 || !strings.HasPrefix(partial[0], ObjectsDir+"/") {
+		t.Fatalf("Expected only the abandoned temporary file, got %v (%v)", partial, err)
+	}
+	if err := fs.RemovePartialWrite(partial[0]); err != nil {
+		t.Fatalf("Failed to remove temporary file: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("Expected the temporary file to be removed, got %v", err)
+	}
+	if err := fs.RemovePartialWrite(HeadFile); err == nil {
+		t.Errorf("Expected a file other than a temporary one to be refused")
+	}
+}
+
+func TestPartialObjects(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	fs := NewFileSystemStorage(tempDir)
+	if err := fs.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	plain := createTestCommit()
+	plain.Hash = "aaaa1111"
+	compressed := createTestCommit()
+	compressed.Hash = "bbbb2222"
+	if err := fs.WriteCommit(plain); err != nil {
+		t.Fatalf("Failed to write commit: %v", err)
+	}
+	if err := fs.SetCompression(CompressionGzip); err != nil {
+		t.Fatalf("Failed to set compression: %v", err)
+	}
+	if err := fs.WriteCommit(compressed); err != nil {
+		t.Fatalf("Failed to write commit: %v", err)
+	}
+
+	// Cut both short, as a crash during an in-place write would have
+	for _, hash := range []string{plain.Hash, compressed.Hash} {
+		path := filepath.Join(tempDir, RepoDir, ObjectsDir, hash[:2], hash[2:])
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read object: %v", err)
+		}
+		if err := os.WriteFile(path, data[:len(data)/2], 0644); err != nil {
+			t.Fatalf("Failed to truncate object: %v", err)
+		}
+		if _, err := fs.ReadCommit(hash); !errors.Is(err, ErrPartialWrite) {
+			t.Errorf("Expected object %s to read as a partial write, got %v", hash, err)
+		}
+	}
+
+	// An object that's whole but wrong isn't one
+	path := filepath.Join(tempDir, RepoDir, ObjectsDir, "cc", "cc3333")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create object directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("{\"message\": 42}"), 0644); err != nil {
+		t.Fatalf("Failed to write object: %v", err)
+	}
+	if _, err := fs.ReadCommit("cccc3333"); err == nil || errors.Is(err, ErrPartialWrite) {
+		t.Errorf("Expected a corrupt object not to read as a partial write, got %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+)
+
+// BlobsDir holds the content of commits, stored once however many commits
+// share it. Re-evaluating a buffer without editing it is the most common
+// thing a performer does, so most commits repeat content already stored.
+const BlobsDir = "blobs"
+
+// blobCommit is how a commit whose content is a blob is stored: without the
+// content, and with the hash of the blob holding it, or of the blobs holding
+// it in turn when it was streamed
+type blobCommit struct {
+	*Commit
+	Blob   string   `json:"blob,omitempty"`
+	Chunks []string `json:"chunks,omitempty"`
+}
+
+// Blobs returns the hashes of the blobs holding the commit's content, in
+// order
+func (b *blobCommit) Blobs() []string {
+	if b.Blob != "" {
+		return []string{b.Blob}
+	}
+	return b.Chunks
+}
+
+// ObjectCount reports the objects of a repository and what storing content
+// as blobs saved
+type ObjectCount struct {
+	Commits      int
+	CommitBytes  int64
+	Blobs        int
+	BlobBytes    int64
+	BlobCommits  int   // commits whose content is a blob
+	ContentBytes int64 // content of those commits, as if each stored its own
+}
+
+// Saved returns the bytes blobs spared, compared to each commit storing its
+// own content
+func (c *ObjectCount) Saved() int64 {
+	return c.ContentBytes - c.BlobBytes
+}
+
+// BlobHash returns the hash of the blob holding content; an encrypted
+// repository names blobs by a keyed hash instead
+func BlobHash(content string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+}
+
+// writeBlob stores content as a blob, unless an identical one already is,
+// and returns its hash
+func (fs *FileSystemStorage) writeBlob(content string, compression string) (string, error) {
+	store, err := fs.blobs()
+	if err != nil {
+		return "", err
+	}
+
+	hash := BlobHash(content)
+	if sealed, ok := store.(*encryptedObjects); ok {
+		hash = sealed.blobName([]byte(content))
+	}
+	if store.Has(hash) {
+		return hash, nil
+	}
+	data, err := compressObject([]byte(content), compression)
+	if err != nil {
+		return "", fmt.Errorf("failed to compress blob: %w", err)
+	}
+	if err := store.Put(hash, data); err != nil {
+		return "", fmt.Errorf("failed to write blob %s: %w", hash, err)
+	}
+	return hash, nil
+}
+
+// ReadBlob returns the content held by a blob
+func (fs *FileSystemStorage) ReadBlob(hash string) (string, error) {
+	data, err := fs.readBlob(hash)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ListBlobs returns the hashes of all blobs in the repository
+func (fs *FileSystemStorage) ListBlobs() ([]string, error) {
+	store, err := fs.blobs()
+	if err != nil {
+		return nil, err
+	}
+	return store.List()
+}
+
+// BlobSize returns the size in bytes of a stored blob
+func (fs *FileSystemStorage) BlobSize(hash string) (int64, error) {
+	store, err := fs.blobs()
+	if err != nil {
+		return 0, err
+	}
+	return store.Size(hash)
+}
+
+// DeleteBlob removes a stored blob
+func (fs *FileSystemStorage) DeleteBlob(hash string) error {
+	store, err := fs.blobs()
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(hash); err != nil {
+		return fmt.Errorf("failed to delete blob %s: %w", hash, err)
+	}
+	return nil
+}
+
+// CommitBlob returns the hash of the blob holding a stored commit's content,
+// or an empty string when the commit holds its own or streamed it in chunks
+func (fs *FileSystemStorage) CommitBlob(hash string) (string, error) {
+	_, object, err := fs.readStoredCommit(hash)
+	if err != nil {
+		return "", err
+	}
+	return object.Blob, nil
+}
+
+// CommitBlobs returns the hashes of all the blobs holding a stored commit's
+// content, none when the commit holds its own
+func (fs *FileSystemStorage) CommitBlobs(hash string) ([]string, error) {
+	_, object, err := fs.readStoredCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+	return object.Blobs(), nil
+}
+
+// CountObjects counts the commits and blobs of the repository and their
+// sizes as stored
+func (fs *FileSystemStorage) CountObjects() (*ObjectCount, error) {
+	count := &ObjectCount{}
+
+	commits, err := fs.ListCommits()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+	blobSizes := make(map[string]int64)
+	for _, hash := range commits {
+		size, err := fs.ObjectSize(hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat object %s: %w", hash, err)
+		}
+		count.Commits++
+		count.CommitBytes += size
+
+		blobs, err := fs.CommitBlobs(hash)
+		if err != nil {
+			return nil, err
+		}
+		if len(blobs) == 0 {
+			continue
+		}
+		for _, blob := range blobs {
+			if _, ok := blobSizes[blob]; !ok {
+				if blobSizes[blob], err = fs.BlobSize(blob); err != nil {
+					return nil, fmt.Errorf("failed to stat blob %s: %w", blob, err)
+				}
+			}
+			count.ContentBytes += blobSizes[blob]
+		}
+		count.BlobCommits++
+	}
+
+	blobs, err := fs.ListBlobs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blobs: %w", err)
+	}
+	for _, hash := range blobs {
+		size, err := fs.BlobSize(hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat blob %s: %w", hash, err)
+		}
+		count.Blobs++
+		count.BlobBytes += size
+	}
+	return count, nil
+}
+
+// readStoredCommit reads a commit object as stored, its content left out
+// when blobs hold it
+func (fs *FileSystemStorage) readStoredCommit(hash string) (*Commit, *blobCommit, error) {
+	data, err := fs.readObject(hash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read commit %s: %w", hash, err)
+	}
+
+	var commit Commit
+	object := &blobCommit{Commit: &commit}
+	if err := json.Unmarshal(data, object); err != nil {
+		if truncated(data, err) {
+			err = ErrPartialWrite
+		}
+		return nil, nil, fmt.Errorf("failed to unmarshal commit %s: %w", hash, err)
+	}
+	return &commit, object, nil
+}
+
+// blobs returns the blob store of the repository's backend
+func (fs *FileSystemStorage) blobs() (ObjectStore, error) {
+	return fs.openStore(&fs.blobStore, BlobsDir)
+}
+
+// readBlob returns the content stored under hash, decompressed
+func (fs *FileSystemStorage) readBlob(hash string) ([]byte, error) {
+	store, err := fs.blobs()
+	if err != nil {
+		return nil, err
+	}
+	data, err := store.Get(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", hash, err)
+	}
+	if data, err = decompressObject(data); truncated(nil, err) {
+		return nil, fmt.Errorf("failed to read blob %s: %w", hash, ErrPartialWrite)
+	}
+	return data, err
+}
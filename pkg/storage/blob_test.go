@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"os"
+	"regexp"
+	"testing"
+)
+
+func TestBlobs(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	fs := NewFileSystemStorage(tempDir)
+	if err := fs.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	inline := createTestCommit()
+	inline.Hash = "aaaa1111"
+	if err := fs.WriteCommit(inline); err != nil {
+		t.Fatalf("Failed to write commit: %v", err)
+	}
+
+	if err := fs.SetBlobs(true); err != nil {
+		t.Fatalf("Failed to turn on blobs: %v", err)
+	}
+
+	// Re-evaluating the same code stores its content once
+	var commits []*Commit
+	for _, hash := range []string{"bbbb2222", "cccc3333", "dddd4444"} {
+		commit := createTestCommit()
+		commit.Hash = hash
+		commit.Content = "d1 $ sound \"bd*2 [~ bd] sn\""
+		if hash == "dddd4444" {
+			commit.Content = "d2 $ sound \"hh*8\""
+		}
+		if err := fs.WriteCommit(commit); err != nil {
+			t.Fatalf("Failed to write commit: %v", err)
+		}
+		commits = append(commits, commit)
+	}
+
+	blobs, err := fs.ListBlobs()
+	if err != nil || len(blobs) != 2 {
+		t.Fatalf("Expected 2 blobs, got %v (%v)", blobs, err)
+	}
+	if blob, err := fs.CommitBlob("bbbb2222"); err != nil || blob != BlobHash(commits[0].Content) {
+		t.Errorf("Expected commit to refer to its content's blob, got %q (%v)", blob, err)
+	}
+	if blob, err := fs.CommitBlob(inline.Hash); err != nil || blob != "" {
+		t.Errorf("Expected the earlier commit to hold its own content, got %q (%v)", blob, err)
+	}
+
+	// Commits read back the same either way
+	for _, commit := range append(commits, inline) {
+		read, err := fs.ReadCommit(commit.Hash)
+		if err != nil || read.Content != commit.Content {
+			t.Errorf("Expected commit %s back, got %v", commit.Hash, err)
+		}
+	}
+
+	var matched []string
+	err = fs.Grep([]string{inline.Hash, "bbbb2222", "dddd4444"}, regexp.MustCompile(`sound "bd`), func(match GrepMatch) bool {
+		matched = append(matched, match.Hash)
+		return true
+	})
+	if err != nil || len(matched) != 1 || matched[0] != "bbbb2222" {
+		t.Errorf("Expected grep to search blob content, got %v (%v)", matched, err)
+	}
+
+	count, err := fs.CountObjects()
+	if err != nil {
+		t.Fatalf("Failed to count objects: %v", err)
+	}
+	if count.Commits != 4 || count.Blobs != 2 || count.BlobCommits != 3 {
+		t.Errorf("Expected 4 commits, 2 blobs and 3 commits using them, got %+v", count)
+	}
+	if count.Saved() != count.ContentBytes-count.BlobBytes || count.Saved() <= 0 {
+		t.Errorf("Expected shared blobs to save space, got %+v", count)
+	}
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// Object compression, recorded in the format file. Objects are read the
+// same whatever the setting, so it can be changed at any time.
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// gzipMagic starts every gzip stream; an uncompressed object starts with
+// the "{" of its JSON
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// ValidCompression reports whether compression names an object
+// compression; an empty name is none
+func ValidCompression(compression string) bool {
+	return compression == "" || compression == CompressionNone || compression == CompressionGzip
+}
+
+// compressObject encodes an object for storage
+func compressObject(data []byte, compression string) ([]byte, error) {
+	if compression != CompressionGzip {
+		return data, nil
+	}
+
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// decompressObject decodes a stored object, compressed or not
+func decompressObject(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	fs := NewFileSystemStorage(tempDir)
+	if err := fs.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	plain := createTestCommit()
+	plain.Hash = "aaaa1111"
+	if err := fs.WriteCommit(plain); err != nil {
+		t.Fatalf("Failed to write commit: %v", err)
+	}
+
+	if err := fs.SetCompression("zstd"); err == nil {
+		t.Errorf("Expected an unknown compression to be rejected")
+	}
+	if err := fs.SetCompression(CompressionGzip); err != nil {
+		t.Fatalf("Failed to set compression: %v", err)
+	}
+
+	compressed := createTestCommit()
+	compressed.Hash = "bbbb2222"
+	compressed.Content = strings.Repeat("d1 $ sound \"bd*2 [~ bd] sn\" # gain 1.2\n", 50)
+	if err := fs.WriteCommit(compressed); err != nil {
+		t.Fatalf("Failed to write commit: %v", err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(tempDir, RepoDir, ObjectsDir, "bb", "bb2222"))
+	if err != nil {
+		t.Fatalf("Failed to read object file: %v", err)
+	}
+	if !bytes.HasPrefix(raw, gzipMagic) || len(raw) > len(compressed.Content)/4 {
+		t.Errorf("Expected a gzip-compressed object, got %d bytes", len(raw))
+	}
+
+	// Both kinds of objects read back, whatever the setting
+	if err := fs.SetCompression(CompressionNone); err != nil {
+		t.Fatalf("Failed to set compression: %v", err)
+	}
+	for _, commit := range []*Commit{plain, compressed} {
+		read, err := fs.ReadCommit(commit.Hash)
+		if err != nil || read.Content != commit.Content {
+			t.Errorf("Expected commit %s back, got %v", commit.Hash, err)
+		}
+	}
+
+	matched := 0
+	err = fs.Grep([]string{"bbbb2222"}, regexp.MustCompile(`sound "bd\*2`), func(GrepMatch) bool {
+		matched++
+		return true
+	})
+	if err != nil || matched != 50 {
+		t.Errorf("Expected grep to match every line of the compressed commit, got %d (%v)", matched, err)
+	}
+}
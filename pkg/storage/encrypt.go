@@ -0,0 +1,621 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Encryption at rest, for repositories of unreleased material kept on
+// shared machines or in a bucket. Once a repository is encrypted, every
+// object, commits and blobs alike, is sealed with AES-256-GCM under a key
+// read from a key file or derived from a passphrase, and so are the search
+// index and the files watchers keep executions in (see SealRepoData).
+// Blobs are named by a hash keyed with it, so a guessed snippet can't be
+// confirmed by its name. The index, with commit messages and buffer names,
+// refs, snapshots and performances stay readable.
+const CipherAESGCM = "aes-256-gcm"
+
+// Where keys come from. Like S3 credentials, the passphrase is never
+// written to the repository; a key file's path is, and may be overridden
+// e.g. on another machine.
+const (
+	PassphraseEnv = "LCG_PASSPHRASE"
+	KeyFileEnv    = "LCG_KEY_FILE"
+)
+
+const (
+	encryptionKeySize = 32 // AES-256
+	encryptionSalt    = 16
+
+	// PBKDF2Iterations stretches a passphrase into a key, as OWASP
+	// recommends for PBKDF2-HMAC-SHA256
+	PBKDF2Iterations = 600000
+)
+
+// encryptedMagic starts every encrypted object, followed by the GCM nonce
+// and the sealed object; like gzipMagic, it can't start an object's JSON
+var encryptedMagic = []byte("LCGE")
+
+// keyCheck is sealed with the key when a repository is encrypted, so a
+// wrong key is told apart from damaged objects
+var keyCheck = []byte("livecodegit")
+
+// blobNames is what the key naming blobs is derived from the repository's
+// key with
+var blobNames = []byte("livecodegit blob names")
+
+var (
+	// ErrNoKey is returned opening the objects of an encrypted repository
+	// without its passphrase
+	ErrNoKey = errors.New("repository is encrypted: set " + PassphraseEnv)
+
+	// ErrWrongKey is returned when the key or passphrase isn't the one the
+	// repository was encrypted with
+	ErrWrongKey = errors.New("wrong encryption key or passphrase")
+
+	// ErrNotSealed is returned reading something an encrypted repository
+	// holds unencrypted, as it would be swapped in by someone without the
+	// key
+	ErrNotSealed = errors.New("stored unencrypted in an encrypted repository; if 'lcg encrypt' was interrupted, run it again")
+)
+
+// EncryptionConfig says how the objects of an encrypted repository are
+// sealed, recorded in the format file
+type EncryptionConfig struct {
+	Cipher     string `json:"cipher"`
+	KeyFile    string `json:"key_file,omitempty"`   // the key is derived from a passphrase when empty
+	Salt       []byte `json:"salt,omitempty"`       // of the passphrase
+	Iterations int    `json:"iterations,omitempty"` // of PBKDF2 on the passphrase
+	Check      []byte `json:"check"`                // keyCheck sealed with the key
+}
+
+// GenerateKeyFile writes a new random key to path, readable only by its
+// owner. An existing file is left alone.
+func GenerateKeyFile(path string) error {
+	key := make([]byte, encryptionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create key file: %w", err)
+	}
+	if _, err := fmt.Fprintln(file, hex.EncodeToString(key)); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	return file.Close()
+}
+
+// readKeyFile reads a key written by GenerateKeyFile
+func readKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != encryptionKeySize {
+		return nil, fmt.Errorf("%s is not a key file", path)
+	}
+	return key, nil
+}
+
+// newEncryptionConfig sets up encryption with the key in keyFile, or with
+// a key derived from the passphrase in PassphraseEnv when keyFile is empty
+func newEncryptionConfig(keyFile string) (*EncryptionConfig, cipher.AEAD, error) {
+	config := &EncryptionConfig{Cipher: CipherAESGCM}
+	var key []byte
+	if keyFile != "" {
+		path, err := filepath.Abs(keyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		if key, err = readKeyFile(path); err != nil {
+			return nil, nil, err
+		}
+		config.KeyFile = path
+	} else {
+		passphrase := os.Getenv(PassphraseEnv)
+		if passphrase == "" {
+			return nil, nil, fmt.Errorf("encrypting needs a key file or a passphrase in %s", PassphraseEnv)
+		}
+		config.Salt = make([]byte, encryptionSalt)
+		if _, err := rand.Read(config.Salt); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+		config.Iterations = PBKDF2Iterations
+		key = pbkdf2([]byte(passphrase), config.Salt, config.Iterations)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if config.Check, err = sealObject(aead, "check", keyCheck); err != nil {
+		return nil, nil, err
+	}
+	return config, aead, nil
+}
+
+// open returns the cipher sealing the repository's objects and the key
+// naming its blobs, checking the key against the one the repository was
+// encrypted with
+func (c *EncryptionConfig) open() (cipher.AEAD, []byte, error) {
+	if c.Cipher != CipherAESGCM {
+		return nil, nil, fmt.Errorf("unknown cipher %q", c.Cipher)
+	}
+
+	var key []byte
+	if c.KeyFile != "" {
+		path := c.KeyFile
+		if override := os.Getenv(KeyFileEnv); override != "" {
+			path = override
+		}
+		var err error
+		if key, err = readKeyFile(path); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		passphrase := os.Getenv(PassphraseEnv)
+		if passphrase == "" {
+			return nil, nil, ErrNoKey
+		}
+		key = pbkdf2([]byte(passphrase), c.Salt, c.Iterations)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if check, err := openObject(aead, "check", c.Check); err != nil || !bytes.Equal(check, keyCheck) {
+		return nil, nil, ErrWrongKey
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(blobNames)
+	return aead, mac.Sum(nil), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2 derives a key from a passphrase with PBKDF2-HMAC-SHA256 (RFC
+// 8018); one block is a whole AES-256 key
+func pbkdf2(passphrase, salt []byte, iterations int) []byte {
+	mac := hmac.New(sha256.New, passphrase)
+	mac.Write(salt)
+	mac.Write(binary.BigEndian.AppendUint32(nil, 1))
+	u := mac.Sum(nil)
+	key := append([]byte(nil), u...)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
+
+// sealObject encrypts an object, authenticating the hash it's stored under
+// too so objects can't be swapped
+func sealObject(aead cipher.AEAD, hash string, data []byte) ([]byte, error) {
+	sealed := make([]byte, len(encryptedMagic)+aead.NonceSize(), len(encryptedMagic)+aead.NonceSize()+len(data)+aead.Overhead())
+	copy(sealed, encryptedMagic)
+	nonce := sealed[len(encryptedMagic):]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(sealed, nonce, data, []byte(hash)), nil
+}
+
+// openObject decrypts an object stored under hash, refusing one that
+// isn't sealed
+func openObject(aead cipher.AEAD, hash string, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		return nil, fmt.Errorf("object %s is %w", hash, ErrNotSealed)
+	}
+	data = data[len(encryptedMagic):]
+	if len(data) < aead.NonceSize() {
+		return nil, ErrPartialWrite
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(hash))
+	if err != nil {
+		return nil, fmt.Errorf("object %s failed to decrypt: %w", hash, err)
+	}
+	return plain, nil
+}
+
+// encryptedObjects seals the objects of another store. It's the outermost
+// store, so what reaches the disk, a packfile or a bucket is sealed.
+type encryptedObjects struct {
+	inner   ObjectStore
+	aead    cipher.AEAD
+	blobKey []byte
+}
+
+// blobName returns the name of the blob holding content, in place of
+// BlobHash
+func (eo *encryptedObjects) blobName(content []byte) string {
+	mac := hmac.New(sha256.New, eo.blobKey)
+	mac.Write(content)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (eo *encryptedObjects) Put(hash string, data []byte) error {
+	sealed, err := sealObject(eo.aead, hash, data)
+	if err != nil {
+		return err
+	}
+	return eo.inner.Put(hash, sealed)
+}
+
+func (eo *encryptedObjects) Get(hash string) ([]byte, error) {
+	data, err := eo.inner.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	return openObject(eo.aead, hash, data)
+}
+
+func (eo *encryptedObjects) Has(hash string) bool {
+	return eo.inner.Has(hash)
+}
+
+// Size returns the size of the sealed object
+func (eo *encryptedObjects) Size(hash string) (int64, error) {
+	return eo.inner.Size(hash)
+}
+
+func (eo *encryptedObjects) Delete(hash string) error {
+	return eo.inner.Delete(hash)
+}
+
+func (eo *encryptedObjects) List() ([]string, error) {
+	return eo.inner.List()
+}
+
+// sealStored encrypts the objects stored before the repository was
+// encrypted, returning how many there were
+func (eo *encryptedObjects) sealStored() (int, error) {
+	hashes, err := eo.inner.List()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	sealed := 0
+	for _, hash := range hashes {
+		data, err := eo.inner.Get(hash)
+		if err != nil {
+			return sealed, fmt.Errorf("failed to read object %s: %w", hash, err)
+		}
+		if bytes.HasPrefix(data, encryptedMagic) {
+			continue
+		}
+		if err := eo.Put(hash, data); err != nil {
+			return sealed, fmt.Errorf("failed to encrypt object %s: %w", hash, err)
+		}
+		sealed++
+	}
+	return sealed, nil
+}
+
+// compact drops what was replaced or deleted from packfiles and key-value
+// files, where it's still on disk: plaintext copies of sealed objects and
+// blobs under their former names
+func (eo *encryptedObjects) compact() error {
+	store := eo.inner
+	if mirrored, ok := store.(*s3Objects); ok {
+		// The bucket's copies were replaced as they were written
+		store = mirrored.local
+	}
+	switch store := store.(type) {
+	case *packedObjects:
+		if store.hasPacks() {
+			if _, err := store.Repack(); err != nil {
+				return err
+			}
+		}
+	case *kvObjects:
+		return store.Compact()
+	}
+	return nil
+}
+
+// renameBlobs stores the blobs named by BlobHash under their keyed names,
+// rewriting the commits holding them; commit hashes are of the content, so
+// they don't change. It returns how many commits were rewritten and the
+// former names, deleted once nothing refers to them.
+func (fs *FileSystemStorage) renameBlobs(blobs *encryptedObjects, compression string) (int, []string, error) {
+	commits, err := fs.ListCommits()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+	store, err := fs.objects()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	renamed := make(map[string]string)
+	rewritten := 0
+	for _, hash := range commits {
+		_, object, err := fs.readStoredCommit(hash)
+		if err != nil {
+			return rewritten, nil, err
+		}
+		names := object.Blobs()
+		changed := false
+		for i, name := range names {
+			keyed, ok := renamed[name]
+			if !ok {
+				if keyed, err = blobs.rename(name); err != nil {
+					return rewritten, nil, err
+				}
+				renamed[name] = keyed
+			}
+			if keyed != name {
+				names[i] = keyed
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		if object.Blob != "" {
+			object.Blob = names[0]
+		} else {
+			object.Chunks = names
+		}
+		data, err := json.MarshalIndent(object, "", "  ")
+		if err != nil {
+			return rewritten, nil, fmt.Errorf("failed to marshal commit: %w", err)
+		}
+		if data, err = compressObject(data, compression); err != nil {
+			return rewritten, nil, fmt.Errorf("failed to compress commit: %w", err)
+		}
+		if err := store.Put(hash, data); err != nil {
+			return rewritten, nil, fmt.Errorf("failed to rewrite commit %s: %w", hash, err)
+		}
+		rewritten++
+	}
+
+	var former []string
+	for name, keyed := range renamed {
+		if keyed != name {
+			former = append(former, name)
+		}
+	}
+	return rewritten, former, nil
+}
+
+// rename stores a blob under its keyed name, if it isn't already, and
+// returns that name
+func (eo *encryptedObjects) rename(name string) (string, error) {
+	data, err := eo.inner.Get(name)
+	if os.IsNotExist(err) {
+		// Missing, which fsck reports
+		return name, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read blob %s: %w", name, err)
+	}
+	if bytes.HasPrefix(data, encryptedMagic) {
+		if data, err = openObject(eo.aead, name, data); err != nil {
+			return "", err
+		}
+	}
+	content, err := decompressObject(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to read blob %s: %w", name, err)
+	}
+
+	keyed := eo.blobName(content)
+	if keyed != name && !eo.Has(keyed) {
+		if err := eo.Put(keyed, data); err != nil {
+			return "", fmt.Errorf("failed to write blob %s: %w", keyed, err)
+		}
+	}
+	return keyed, nil
+}
+
+// Encrypt encrypts the repository's objects from now on, with the key in
+// keyFile or, when keyFile is empty, a passphrase from PassphraseEnv, and
+// encrypts the objects already stored, renaming blobs to their keyed names.
+// It returns how many objects it encrypted. The search index is dropped, to
+// be rebuilt sealed by the next search, and files, of the repository
+// directory, written with SealRepoData are sealed in place. Encrypting an
+// encrypted repository finishes an interrupted run.
+func (fs *FileSystemStorage) Encrypt(keyFile string, files ...string) (int, error) {
+	format, err := ReadFormat(fs.repoPath)
+	if err != nil {
+		return 0, err
+	}
+	if format.Encryption == nil {
+		config, _, err := newEncryptionConfig(keyFile)
+		if err != nil {
+			return 0, err
+		}
+		if err := fs.updateFormat(func(format *Format) { format.Encryption = config }); err != nil {
+			return 0, err
+		}
+	} else if keyFile != "" {
+		path, err := filepath.Abs(keyFile)
+		if err != nil {
+			return 0, err
+		}
+		if path != format.Encryption.KeyFile {
+			return 0, fmt.Errorf("repository is already encrypted with another key")
+		}
+	}
+
+	objects, err := fs.objects()
+	if err != nil {
+		return 0, err
+	}
+	blobs, err := fs.blobs()
+	if err != nil {
+		return 0, err
+	}
+	commits, blobStore := objects.(*encryptedObjects), blobs.(*encryptedObjects)
+
+	// Commits are sealed first, to be read for the names of their blobs
+	total, err := commits.sealStored()
+	if err != nil {
+		return total, err
+	}
+	if total > 0 {
+		if err := commits.compact(); err != nil {
+			return total, err
+		}
+	}
+
+	format, err = ReadFormat(fs.repoPath)
+	if err != nil {
+		return total, err
+	}
+	rewritten, former, err := fs.renameBlobs(blobStore, format.Compression)
+	if err != nil {
+		return total, err
+	}
+	if rewritten > 0 {
+		if err := commits.compact(); err != nil {
+			return total, err
+		}
+	}
+	// Blobs are stored sealed under their keyed names
+	for _, name := range former {
+		if err := blobStore.Delete(name); err != nil && !os.IsNotExist(err) {
+			return total, fmt.Errorf("failed to delete blob %s: %w", name, err)
+		}
+		total++
+	}
+	sealed, err := blobStore.sealStored()
+	total += sealed
+	if err != nil {
+		return total, err
+	}
+	if sealed > 0 || len(former) > 0 {
+		if err := blobStore.compact(); err != nil {
+			return total, err
+		}
+	}
+
+	if err := os.Remove(filepath.Join(fs.repoPath, RepoDir, SearchIndexFile)); err != nil && !os.IsNotExist(err) {
+		return total, fmt.Errorf("failed to remove search index: %w", err)
+	}
+	for _, name := range files {
+		if err := fs.sealFile(name); err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// sealFile seals a file of the repository directory written unsealed
+// before the repository was encrypted
+func (fs *FileSystemStorage) sealFile(name string) error {
+	path := filepath.Join(fs.repoPath, RepoDir, name)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) || bytes.HasPrefix(data, encryptedMagic) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if data, err = fs.SealRepoData(name, data); err != nil {
+		return err
+	}
+	return writeAtomically(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// SealRepoData seals data kept in the named file of the repository
+// directory when the repository is encrypted, for files holding code
+// outside objects, and returns it as it is otherwise. The format is read
+// each time, so data is sealed as soon as another process encrypts the
+// repository.
+func (fs *FileSystemStorage) SealRepoData(name string, data []byte) ([]byte, error) {
+	aead, err := fs.fileCipher()
+	if err != nil || aead == nil {
+		return data, err
+	}
+	return sealObject(aead, name, data)
+}
+
+// OpenRepoData opens data sealed by SealRepoData, refusing data that isn't
+// sealed when the repository is encrypted
+func (fs *FileSystemStorage) OpenRepoData(name string, data []byte) ([]byte, error) {
+	aead, err := fs.fileCipher()
+	if err != nil || aead == nil {
+		return data, err
+	}
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		return nil, fmt.Errorf("%s is %w", name, ErrNotSealed)
+	}
+	return openObject(aead, name, data)
+}
+
+// fileCipher returns the cipher of an encrypted repository, or nil
+func (fs *FileSystemStorage) fileCipher() (cipher.AEAD, error) {
+	format, err := ReadFormat(fs.repoPath)
+	if err != nil || format.Encryption == nil {
+		return nil, err
+	}
+	fs.storeMutex.Lock()
+	defer fs.storeMutex.Unlock()
+	return fs.encryption(format.Encryption)
+}
+
+// writeFormat reads the format for a write, first dropping object stores
+// opened before another process encrypted the repository so nothing is
+// written unsealed
+func (fs *FileSystemStorage) writeFormat() (Format, error) {
+	format, err := ReadFormat(fs.repoPath)
+	if err != nil || format.Encryption == nil {
+		return format, err
+	}
+
+	fs.storeMutex.Lock()
+	defer fs.storeMutex.Unlock()
+	for _, store := range []ObjectStore{fs.store, fs.blobStore} {
+		if _, sealed := store.(*encryptedObjects); store != nil && !sealed {
+			fs.store, fs.blobStore = nil, nil
+			break
+		}
+	}
+	return format, nil
+}
+
+// encryption returns the cipher of an encrypted repository, opened once
+// and shared by its stores; called holding storeMutex
+func (fs *FileSystemStorage) encryption(config *EncryptionConfig) (cipher.AEAD, error) {
+	if fs.aead == nil {
+		aead, blobKey, err := config.open()
+		if err != nil {
+			return nil, err
+		}
+		fs.aead, fs.blobKey = aead, blobKey
+	}
+	return fs.aead, nil
+}
@@ -0,0 +1,263 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// storedPlaintext returns the files under dir holding needle as is
+func storedPlaintext(t *testing.T, dir, needle string) []string {
+	var found []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(data, []byte(needle)) {
+			found = append(found, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to walk %s: %v", dir, err)
+	}
+	return found
+}
+
+func TestEncryptWithKeyFile(t *testing.T) {
+	for _, backend := range []string{BackendFiles, BackendKV} {
+		t.Run(backend, func(t *testing.T) {
+			tempDir := createTempDir(t)
+			defer os.RemoveAll(tempDir)
+
+			fs := NewFileSystemStorage(tempDir)
+			if err := fs.InitializeRepository(); err != nil {
+				t.Fatalf("Failed to initialize repository: %v", err)
+			}
+			if err := fs.SetBackend(backend); err != nil {
+				t.Fatalf("Failed to set backend: %v", err)
+			}
+			if err := fs.SetBlobs(true); err != nil {
+				t.Fatalf("Failed to set blobs: %v", err)
+			}
+
+			// One object stored before encrypting, and packed
+			before := createTestCommit()
+			if err := fs.WriteCommit(before); err != nil {
+				t.Fatalf("Failed to write commit: %v", err)
+			}
+			if backend == BackendFiles {
+				if _, err := fs.Repack(); err != nil {
+					t.Fatalf("Failed to repack: %v", err)
+				}
+			}
+
+			keyFile := filepath.Join(tempDir, "lcg.key")
+			if err := GenerateKeyFile(keyFile); err != nil {
+				t.Fatalf("Failed to generate key: %v", err)
+			}
+			if err := GenerateKeyFile(keyFile); err == nil {
+				t.Errorf("Expected an existing key file to be kept")
+			}
+			encrypted, err := fs.Encrypt(keyFile)
+			if err != nil {
+				t.Fatalf("Failed to encrypt: %v", err)
+			}
+			if encrypted != 2 {
+				t.Errorf("Expected the commit and its blob encrypted, got %d", encrypted)
+			}
+
+			after := createTestCommit()
+			after.Hash = "def456abc789"
+			after.Content = "d1 $ s \"bd*2 [~ bd] sn\""
+			if err := fs.WriteCommit(after); err != nil {
+				t.Fatalf("Failed to write commit: %v", err)
+			}
+			if err := NewSearchIndex(fs).AddCommit(before); err != nil {
+				t.Fatalf("Failed to index commit: %v", err)
+			}
+
+			for _, needle := range []string{"sample :bd_haus", "bd*2 [~ bd] sn", "bd_haus", before.Message} {
+				if files := storedPlaintext(t, filepath.Join(tempDir, RepoDir), needle); len(files) > 0 {
+					t.Errorf("Expected %q encrypted, found in %v", needle, files)
+				}
+			}
+
+			// Another process reads them with the key
+			reopened := NewFileSystemStorage(tempDir)
+			for _, commit := range []*Commit{before, after} {
+				read, err := reopened.ReadCommit(commit.Hash)
+				if err != nil {
+					t.Fatalf("Failed to read commit: %v", err)
+				}
+				if read.Content != commit.Content {
+					t.Errorf("Expected %q, got %q", commit.Content, read.Content)
+				}
+			}
+
+			// Blob names don't give their content away
+			blobs, err := reopened.ListBlobs()
+			if err != nil || len(blobs) != 2 {
+				t.Fatalf("Expected 2 blobs, got %v (%v)", blobs, err)
+			}
+			for _, blob := range blobs {
+				if blob == BlobHash(before.Content) || blob == BlobHash(after.Content) {
+					t.Errorf("Expected blob %s named by a keyed hash", blob)
+				}
+			}
+
+			searchIndex := NewSearchIndex(reopened)
+			if err := searchIndex.LoadSearchIndex(); err != nil {
+				t.Fatalf("Failed to load search index: %v", err)
+			}
+			if !searchIndex.Candidates("bd_haus")[before.Hash] {
+				t.Errorf("Expected the sealed search index to find the commit")
+			}
+
+			// Running it again has nothing left to do
+			if encrypted, err := fs.Encrypt(""); err != nil || encrypted != 0 {
+				t.Errorf("Expected nothing left to encrypt, got %d (%v)", encrypted, err)
+			}
+
+			// Without the key, nothing is read
+			os.Remove(keyFile)
+			if _, err := NewFileSystemStorage(tempDir).ReadCommit(before.Hash); err == nil {
+				t.Errorf("Expected reading without the key file to fail")
+			}
+		})
+	}
+}
+
+func TestEncryptWithPassphrase(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	fs := NewFileSystemStorage(tempDir)
+	if err := fs.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	// A running watcher, its stores opened before encrypting
+	watcher := NewFileSystemStorage(tempDir)
+	if _, err := watcher.ListCommits(); err != nil {
+		t.Fatalf("Failed to list commits: %v", err)
+	}
+
+	t.Setenv(PassphraseEnv, "")
+	if _, err := fs.Encrypt(""); err == nil {
+		t.Fatalf("Expected encrypting without a key or passphrase to fail")
+	}
+
+	t.Setenv(PassphraseEnv, "friday night set")
+	if _, err := fs.Encrypt(""); err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	commit := createTestCommit()
+	if err := watcher.WriteCommit(commit); err != nil {
+		t.Fatalf("Failed to write commit: %v", err)
+	}
+	if files := storedPlaintext(t, filepath.Join(tempDir, RepoDir), "sample :bd_haus"); len(files) > 0 {
+		t.Errorf("Expected the commit encrypted, found in %v", files)
+	}
+	format, err := ReadFormat(tempDir)
+	if err != nil || format.Encryption == nil || format.Encryption.KeyFile != "" || len(format.Encryption.Salt) == 0 {
+		t.Errorf("Expected passphrase encryption recorded, got %+v (%v)", format.Encryption, err)
+	}
+
+	t.Setenv(PassphraseEnv, "saturday night set")
+	if _, err := NewFileSystemStorage(tempDir).ReadCommit(commit.Hash); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Expected a wrong passphrase error, got %v", err)
+	}
+	t.Setenv(PassphraseEnv, "")
+	if _, err := NewFileSystemStorage(tempDir).ReadCommit(commit.Hash); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Expected a missing passphrase error, got %v", err)
+	}
+
+	t.Setenv(PassphraseEnv, "friday night set")
+	read, err := NewFileSystemStorage(tempDir).ReadCommit(commit.Hash)
+	if err != nil || read.Content != commit.Content {
+		t.Errorf("Expected the commit back, got %+v (%v)", read, err)
+	}
+}
+
+func TestEncryptedObjectsAreBoundToTheirHash(t *testing.T) {
+	aead, err := newAEAD(make([]byte, encryptionKeySize))
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %v", err)
+	}
+	sealed, err := sealObject(aead, "aaaa1111", []byte("{}"))
+	if err != nil {
+		t.Fatalf("Failed to seal: %v", err)
+	}
+	if _, err := openObject(aead, "bbbb2222", sealed); err == nil {
+		t.Errorf("Expected an object moved to another hash to be refused")
+	}
+	if data, err := openObject(aead, "aaaa1111", sealed); err != nil || string(data) != "{}" {
+		t.Errorf("Expected the object back, got %q (%v)", data, err)
+	}
+	if _, err := openObject(aead, "aaaa1111", []byte("{}")); !errors.Is(err, ErrNotSealed) {
+		t.Errorf("Expected an unsealed object to be refused, got %v", err)
+	}
+}
+
+func TestEncryptRefusesUnsealed(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	fs := NewFileSystemStorage(tempDir)
+	if err := fs.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	// A file written before encrypting is sealed in place
+	if err := fs.WriteRepoFile("pending.json", []byte(`[{"content":"sample :bd_haus"}]`)); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	keyFile := filepath.Join(tempDir, "lcg.key")
+	if err := GenerateKeyFile(keyFile); err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if _, err := fs.Encrypt(keyFile, "pending.json"); err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	data, err := fs.ReadRepoFile("pending.json")
+	if err != nil || !bytes.HasPrefix(data, encryptedMagic) {
+		t.Fatalf("Expected the file sealed, got %q (%v)", data, err)
+	}
+	if data, err = fs.OpenRepoData("pending.json", data); err != nil || !bytes.Contains(data, []byte("bd_haus")) {
+		t.Errorf("Expected the file back, got %q (%v)", data, err)
+	}
+	if _, err := fs.OpenRepoData("pending.json", []byte("[]")); !errors.Is(err, ErrNotSealed) {
+		t.Errorf("Expected unsealed data refused, got %v", err)
+	}
+
+	// An object swapped in unsealed isn't read
+	commit := createTestCommit()
+	plain, err := openObjectStore(tempDir, Format{}, ObjectsDir)
+	if err != nil {
+		t.Fatalf("Failed to open objects: %v", err)
+	}
+	if err := plain.Put(commit.Hash, []byte(`{"hash":"`+commit.Hash+`","content":"swapped"}`)); err != nil {
+		t.Fatalf("Failed to write object: %v", err)
+	}
+	if _, err := NewFileSystemStorage(tempDir).ReadCommit(commit.Hash); !errors.Is(err, ErrNotSealed) {
+		t.Errorf("Expected an unsealed object refused, got %v", err)
+	}
+}
+
+func TestPBKDF2(t *testing.T) {
+	// RFC 7914 section 11, PBKDF2-HMAC-SHA-256 test vector
+	key := pbkdf2([]byte("passwd"), []byte("salt"), 1)
+	expected := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"
+	if got := hex.EncodeToString(key); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
@@ -1,12 +1,16 @@
 package storage
 
 import (
+	"crypto/cipher"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,17 +20,28 @@ const (
 	PerformanceDir = "performances"
 	IndexFile      = "index"
 	HeadFile       = "HEAD"
+	TagsDir        = "tags"
+
+	SearchIndexFile = "search-index"
+
+	// IndexSnapshotFile is a copy of the index saved by scheduled maintenance
+	IndexSnapshotFile = "index.snapshot"
+
+	// IndexCorruptFile keeps a damaged index once it has been rebuilt
+	IndexCorruptFile = "index.corrupt"
 )
 
 // Commit represents a single execution state in a livecoding performance
 type Commit struct {
-	Hash      string            `json:"hash"`
-	Parent    string            `json:"parent,omitempty"`
-	Timestamp time.Time         `json:"timestamp"`
-	Message   string            `json:"message"`
-	Author    string            `json:"author"`
-	Content   string            `json:"content"`
-	Metadata  ExecutionMetadata `json:"metadata"`
+	Hash         string            `json:"hash"`
+	Parent       string            `json:"parent,omitempty"`        // previous commit of the timeline it was made on
+	BufferParent string            `json:"buffer_parent,omitempty"` // previous commit of the same buffer
+	Timestamp    time.Time         `json:"timestamp"`
+	Message      string            `json:"message"`
+	Author       string            `json:"author"`
+	AuthorEmail  string            `json:"author_email,omitempty"`
+	Content      string            `json:"content"`
+	Metadata     ExecutionMetadata `json:"metadata"`
 }
 
 // ExecutionMetadata contains performance-specific information about code execution
@@ -35,9 +50,41 @@ type ExecutionMetadata struct {
 	Language       string  `json:"language"`
 	BPM            float64 `json:"bpm,omitempty"`
 	BeatsFromStart int64   `json:"beats_from_start,omitempty"`
+	Phase          float64 `json:"phase,omitempty"` // beats into the bar, from Ableton Link
 	Success        bool    `json:"success"`
 	ErrorMessage   string  `json:"error_message,omitempty"`
 	Environment    string  `json:"environment,omitempty"`
+
+	// Sound around the execution, from an external analyzer
+	Audio *AudioFeatures `json:"audio,omitempty"`
+
+	// What the synth was asked to play around the execution, from the
+	// messages a pattern library sent it
+	Sound *SoundActivity `json:"sound,omitempty"`
+
+	// Watchers that saw the execution; more than one when duplicates from
+	// several watchers were merged
+	Watchers []string `json:"watchers,omitempty"`
+
+	// Executions of the same buffer a rate limit collapsed into this one
+	Collapsed int `json:"collapsed,omitempty"`
+}
+
+// AudioFeatures describes one analysis window of the performance's sound
+type AudioFeatures struct {
+	Time             time.Time `json:"time"`              // when the window was received
+	RMS              float64   `json:"rms"`               // loudness, 0 to 1
+	OnsetDensity     float64   `json:"onset_density"`     // onsets per second
+	SpectralCentroid float64   `json:"spectral_centroid"` // brightness in Hz
+}
+
+// SoundActivity describes the events sent to a synth over the cycles
+// before an execution, e.g. by Tidal to SuperDirt
+type SoundActivity struct {
+	Cycle        float64  `json:"cycle"`         // cycle position at the execution
+	Orbits       []int    `json:"orbits"`        // orbits that played, in order
+	EventDensity float64  `json:"event_density"` // events per cycle
+	Sounds       []string `json:"sounds"`        // sound names that played, in order
 }
 
 // Performance represents a complete livecoding session
@@ -51,11 +98,98 @@ type Performance struct {
 	Branch      string    `json:"branch"`
 	Author      string    `json:"author"`
 	Description string    `json:"description,omitempty"`
+
+	// Template the performance was planned from, and when the plan ends
+	Template   string    `json:"template,omitempty"`
+	PlannedEnd time.Time `json:"planned_end,omitempty"`
+
+	// Per-buffer activity, maintained as commits are recorded
+	Buffers map[string]*BufferStats `json:"buffers,omitempty"`
+
+	// Moments labeled during the performance, in the order they were made
+	Markers []Marker `json:"markers,omitempty"`
+}
+
+// Marker labels a moment of a performance, e.g. a drop or a section change
+type Marker struct {
+	Label  string    `json:"label"`
+	Time   time.Time `json:"time"`
+	Commit string    `json:"commit,omitempty"` // HEAD when the marker was made
+
+	// Planned markers come from a template and mark when a section should start
+	Planned bool `json:"planned,omitempty"`
+
+	// Buffers maps each buffer to its latest commit for snapshot markers
+	Buffers map[string]string `json:"buffers,omitempty"`
+}
+
+// BufferStats summarizes the activity of a single buffer during a performance
+type BufferStats struct {
+	Language      string    `json:"language,omitempty"`
+	CommitCount   int       `json:"commit_count"`
+	ErrorCount    int       `json:"error_count"`
+	FirstActivity time.Time `json:"first_activity"`
+	LastActivity  time.Time `json:"last_activity"`
+	Planned       bool      `json:"planned,omitempty"` // expected by the performance's template
+}
+
+// RecordCommit updates the performance counters and buffer statistics for a new commit
+func (p *Performance) RecordCommit(commit *Commit) {
+	p.CommitCount++
+	p.HeadCommit = commit.Hash
+
+	if p.Buffers == nil {
+		p.Buffers = make(map[string]*BufferStats)
+	}
+
+	stats, exists := p.Buffers[commit.Metadata.Buffer]
+	if !exists {
+		stats = &BufferStats{}
+		p.Buffers[commit.Metadata.Buffer] = stats
+	}
+	if stats.FirstActivity.IsZero() {
+		stats.FirstActivity = commit.Timestamp
+	}
+
+	stats.CommitCount++
+	stats.LastActivity = commit.Timestamp
+	if commit.Metadata.Language != "" {
+		stats.Language = commit.Metadata.Language
+	}
+	if !commit.Metadata.Success {
+		stats.ErrorCount++
+	}
+}
+
+// ReplaceHead updates the performance for an amended head commit: the
+// replaced commit's buffer statistics are undone and the new one's recorded,
+// without counting another commit
+func (p *Performance) ReplaceHead(replaced, commit *Commit) {
+	if stats, exists := p.Buffers[replaced.Metadata.Buffer]; exists {
+		stats.CommitCount--
+		if !replaced.Metadata.Success {
+			stats.ErrorCount--
+		}
+		if stats.CommitCount <= 0 {
+			delete(p.Buffers, replaced.Metadata.Buffer)
+		}
+	}
+
+	p.RecordCommit(commit)
+	p.CommitCount--
 }
 
 // FileSystemStorage implements git-like object storage for livecoding commits
 type FileSystemStorage struct {
 	repoPath string
+
+	// Object store of the repository's backend, opened on first use
+	storeMutex sync.Mutex
+	store      ObjectStore
+	blobStore  ObjectStore
+	remote     *s3Remote   // of the s3 backend
+	aead       cipher.AEAD // of an encrypted repository, see encrypt.go
+	blobKey    []byte      // naming the blobs of an encrypted repository
 }
 
 // NewFileSystemStorage creates a new filesystem-based storage instance
@@ -67,46 +201,61 @@ func NewFileSystemStorage(repoPath string) *FileSystemStorage {
 
 // WriteCommit stores a commit object using content-addressable storage
 func (fs *FileSystemStorage) WriteCommit(commit *Commit) error {
-	objectsPath := filepath.Join(fs.repoPath, RepoDir, ObjectsDir)
-	if err := os.MkdirAll(objectsPath, 0755); err != nil {
-		return fmt.Errorf("failed to create objects directory: %w", err)
+	// Read each time, so switching compression, blobs or encryption
+	// reaches running watchers
+	format, err := fs.writeFormat()
+	if err != nil {
+		return err
 	}
-
-	// Create hash-based directory structure (first 2 chars as subdirectory)
-	hashPrefix := commit.Hash[:2]
-	hashSuffix := commit.Hash[2:]
-	objDir := filepath.Join(objectsPath, hashPrefix)
-
-	if err := os.MkdirAll(objDir, 0755); err != nil {
-		return fmt.Errorf("failed to create object subdirectory: %w", err)
+	store, err := fs.objects()
+	if err != nil {
+		return err
 	}
 
-	objPath := filepath.Join(objDir, hashSuffix)
-
-	// Serialize commit to JSON
-	data, err := json.MarshalIndent(commit, "", "  ")
+	// Serialize commit to JSON, with the content in a blob written first so
+	// no commit refers to a missing one
+	var object interface{} = commit
+	if format.Blobs {
+		stored := *commit
+		stored.Content = ""
+		blob, err := fs.writeBlob(commit.Content, format.Compression)
+		if err != nil {
+			return err
+		}
+		object = blobCommit{Commit: &stored, Blob: blob}
+	}
+	data, err := json.MarshalIndent(object, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal commit: %w", err)
 	}
 
-	return os.WriteFile(objPath, data, 0644)
+	if data, err = compressObject(data, format.Compression); err != nil {
+		return fmt.Errorf("failed to compress commit: %w", err)
+	}
+
+	return store.Put(commit.Hash, data)
 }
 
 // ReadCommit retrieves a commit object by its hash
 func (fs *FileSystemStorage) ReadCommit(hash string) (*Commit, error) {
-	objPath := fs.getObjectPath(hash)
-
-	data, err := os.ReadFile(objPath)
+	commit, object, err := fs.readStoredCommit(hash)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read commit %s: %w", hash, err)
+		return nil, err
 	}
 
-	var commit Commit
-	if err := json.Unmarshal(data, &commit); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal commit %s: %w", hash, err)
+	var content strings.Builder
+	for _, blob := range object.Blobs() {
+		data, err := fs.readBlob(blob)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit %s: %w", hash, err)
+		}
+		content.Write(data)
+	}
+	if len(object.Blobs()) > 0 {
+		commit.Content = content.String()
 	}
 
-	return &commit, nil
+	return commit, nil
 }
 
 // WritePerformance stores performance metadata
@@ -123,7 +272,17 @@ func (fs *FileSystemStorage) WritePerformance(performance *Performance) error {
 		return fmt.Errorf("failed to marshal performance: %w", err)
 	}
 
-	return os.WriteFile(perfPath, data, 0644)
+	if err := writeFileAtomically(perfPath, data); err != nil {
+		return err
+	}
+	remote, err := fs.s3()
+	if err != nil {
+		return err
+	}
+	if remote != nil {
+		return remote.upload(PerformanceDir + "/" + performance.ID + ".json")
+	}
+	return nil
 }
 
 // ReadPerformance retrieves performance metadata by ID
@@ -143,41 +302,374 @@ func (fs *FileSystemStorage) ReadPerformance(id string) (*Performance, error) {
 	return &performance, nil
 }
 
+// ListPerformances returns all stored performances ordered by start time
+func (fs *FileSystemStorage) ListPerformances() ([]*Performance, error) {
+	perfDir := filepath.Join(fs.repoPath, RepoDir, PerformanceDir)
+
+	entries, err := os.ReadDir(perfDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*Performance{}, nil
+		}
+		return nil, fmt.Errorf("failed to read performances directory: %w", err)
+	}
+
+	performances := make([]*Performance, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		performance, err := fs.ReadPerformance(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		performances = append(performances, performance)
+	}
+
+	sort.Slice(performances, func(i, j int) bool {
+		return performances[i].StartTime.Before(performances[j].StartTime)
+	})
+
+	return performances, nil
+}
+
 // ListCommits returns all commit hashes in the repository
 func (fs *FileSystemStorage) ListCommits() ([]string, error) {
-	objectsPath := filepath.Join(fs.repoPath, RepoDir, ObjectsDir)
-	var commits []string
+	store, err := fs.objects()
+	if err != nil {
+		return nil, err
+	}
+	return store.List()
+}
+
+// Exists checks if a commit object exists
+func (fs *FileSystemStorage) Exists(hash string) bool {
+	store, err := fs.objects()
+	if err != nil {
+		return false
+	}
+	return store.Has(hash)
+}
+
+// ObjectSize returns the size in bytes of a stored object
+func (fs *FileSystemStorage) ObjectSize(hash string) (int64, error) {
+	store, err := fs.objects()
+	if err != nil {
+		return 0, err
+	}
+	return store.Size(hash)
+}
+
+// DeleteObject removes a stored object
+func (fs *FileSystemStorage) DeleteObject(hash string) error {
+	store, err := fs.objects()
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(hash); err != nil {
+		return fmt.Errorf("failed to delete object %s: %w", hash, err)
+	}
+	return nil
+}
+
+// Backend returns the object storage backend of the repository
+func (fs *FileSystemStorage) Backend() (string, error) {
+	format, err := ReadFormat(fs.repoPath)
+	if err != nil {
+		return "", err
+	}
+	if format.Backend == "" {
+		return BackendFiles, nil
+	}
+	return format.Backend, nil
+}
+
+// SetBackend records the object storage backend of a new repository. Objects
+// already stored aren't moved.
+func (fs *FileSystemStorage) SetBackend(backend string) error {
+	if !ValidBackend(backend) {
+		return fmt.Errorf("unknown storage backend %q", backend)
+	}
+	return fs.updateFormat(func(format *Format) { format.Backend = backend })
+}
+
+// SetCompression sets how new objects are compressed; objects already
+// stored are read either way
+func (fs *FileSystemStorage) SetCompression(compression string) error {
+	if !ValidCompression(compression) {
+		return fmt.Errorf("unknown compression %q", compression)
+	}
+	if compression == CompressionNone {
+		compression = ""
+	}
+	return fs.updateFormat(func(format *Format) { format.Compression = compression })
+}
+
+// updateFormat changes the format file, and reopens the object store on
+// next use
+func (fs *FileSystemStorage) updateFormat(change func(*Format)) error {
+	format, err := ReadFormat(fs.repoPath)
+	if err != nil {
+		return err
+	}
+	change(&format)
+	if err := WriteFormat(fs.repoPath, format); err != nil {
+		return err
+	}
+
+	fs.storeMutex.Lock()
+	fs.store, fs.blobStore, fs.remote, fs.aead, fs.blobKey = nil, nil, nil, nil, nil
+	fs.storeMutex.Unlock()
+	return nil
+}
+
+// FormatVersion returns how the repository hashes new commits
+func (fs *FileSystemStorage) FormatVersion() (int, error) {
+	format, err := ReadFormat(fs.repoPath)
+	if err != nil {
+		return 0, err
+	}
+	return format.version(), nil
+}
+
+// SetBlobs sets whether the content of new commits is stored as blobs;
+// commits already stored are read either way
+func (fs *FileSystemStorage) SetBlobs(blobs bool) error {
+	return fs.updateFormat(func(format *Format) { format.Blobs = blobs })
+}
 
-	err := filepath.WalkDir(objectsPath, func(path string, d os.DirEntry, err error) error {
+// SetS3 sets where the s3 backend mirrors objects
+func (fs *FileSystemStorage) SetS3(config S3Config) error {
+	if config.Bucket == "" {
+		return fmt.Errorf("the %s backend needs a bucket", BackendS3)
+	}
+	return fs.updateFormat(func(format *Format) { format.S3 = &config })
+}
+
+// S3Pending returns the keys the s3 backend hasn't uploaded yet, e.g. for
+// lack of network
+func (fs *FileSystemStorage) S3Pending() ([]string, error) {
+	remote, err := fs.s3()
+	if err != nil || remote == nil {
+		return nil, err
+	}
+	return remote.Pending()
+}
+
+// S3Fetch downloads the objects and performances the bucket holds and the
+// repository doesn't, e.g. in a repository initialized on another machine
+// with the same bucket and prefix, and returns how many it downloaded
+func (fs *FileSystemStorage) S3Fetch() (int, error) {
+	remote, err := fs.s3()
+	if err != nil {
+		return 0, err
+	}
+	if remote == nil {
+		return 0, fmt.Errorf("repository doesn't use the %s backend", BackendS3)
+	}
+
+	fetched := 0
+	for _, open := range []func() (ObjectStore, error){fs.objects, fs.blobs} {
+		store, err := open()
 		if err != nil {
-			return err
+			return fetched, err
 		}
+		if sealed, ok := store.(*encryptedObjects); ok {
+			store = sealed.inner
+		}
+		mirrored := store.(*s3Objects)
+		keys, err := remote.list(mirrored.name + "/")
+		if err != nil {
+			return fetched, err
+		}
+		for _, key := range keys {
+			hash := strings.TrimPrefix(key, mirrored.name+"/")
+			if !fetchable(hash) || mirrored.local.Has(hash) {
+				continue
+			}
+			if _, err := mirrored.Get(hash); err != nil {
+				return fetched, fmt.Errorf("failed to download %s: %w", key, err)
+			}
+			fetched++
+		}
+	}
 
-		if !d.IsDir() {
-			// Reconstruct hash from directory structure
-			rel, err := filepath.Rel(objectsPath, path)
+	keys, err := remote.list(PerformanceDir + "/")
+	if err != nil {
+		return fetched, err
+	}
+	for _, key := range keys {
+		path := filepath.Join(fs.repoPath, RepoDir, key)
+		if !fetchable(strings.TrimPrefix(key, PerformanceDir+"/")) {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		data, err := remote.get(key)
+		if err != nil {
+			return fetched, fmt.Errorf("failed to download %s: %w", key, err)
+		}
+		if err := writeFileAtomically(path, data); err != nil {
+			return fetched, err
+		}
+		fetched++
+	}
+	return fetched, nil
+}
+
+// fetchable reports whether a name listed in the bucket can name a local
+// file, and not one outside where it belongs
+func fetchable(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ".") && !strings.ContainsAny(name, "/\\")
+}
+
+// SetFormatVersion records how the repository hashes new commits. Commits
+// already stored keep their hashes; see core's Migrate to rehash them.
+func (fs *FileSystemStorage) SetFormatVersion(version int) error {
+	if version < FormatSHA1 || version > CurrentFormatVersion {
+		return fmt.Errorf("unknown format version %d", version)
+	}
+	return fs.updateFormat(func(format *Format) { format.Version = version })
+}
+
+// Repack consolidates the loose objects and packfiles of a repository using
+// the files backend into a single packfile, and its blobs into another
+func (fs *FileSystemStorage) Repack() (*RepackResult, error) {
+	var result *RepackResult
+	for _, open := range []func() (ObjectStore, error){fs.objects, fs.blobs} {
+		store, err := open()
+		if err != nil {
+			return nil, err
+		}
+		if sealed, ok := store.(*encryptedObjects); ok {
+			// Objects are packed as they're stored, sealed
+			store = sealed.inner
+		}
+		if mirrored, ok := store.(*s3Objects); ok {
+			// Only the local copies are packed
+			store = mirrored.local
+		}
+		packed, ok := store.(*packedObjects)
+		if !ok {
+			return nil, fmt.Errorf("only the %s backend uses packfiles", BackendFiles)
+		}
+		repacked, err := packed.Repack()
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			result = repacked
+			continue
+		}
+		result.Objects += repacked.Objects
+		result.Loose += repacked.Loose
+		result.Packs += repacked.Packs
+		if result.Pack == "" {
+			result.Pack = repacked.Pack
+		}
+	}
+	return result, nil
+}
+
+// objects returns the commit store of the repository's backend
+func (fs *FileSystemStorage) objects() (ObjectStore, error) {
+	return fs.openStore(&fs.store, ObjectsDir)
+}
+
+// openStore returns the store cached in store, opening the one named name
+// first if needed
+func (fs *FileSystemStorage) openStore(store *ObjectStore, name string) (ObjectStore, error) {
+	fs.storeMutex.Lock()
+	defer fs.storeMutex.Unlock()
+
+	if *store == nil {
+		format, err := ReadFormat(fs.repoPath)
+		if err != nil {
+			return nil, err
+		}
+		opened, err := openObjectStore(fs.repoPath, format, name)
+		if err != nil {
+			return nil, err
+		}
+		if format.Backend == BackendS3 {
+			remote, err := fs.openS3(format)
 			if err != nil {
-				return err
+				return nil, err
 			}
-
-			parts := strings.Split(rel, string(filepath.Separator))
-			if len(parts) == 2 {
-				hash := parts[0] + parts[1]
-				commits = append(commits, hash)
+			opened = &s3Objects{local: opened, remote: remote, name: name}
+		}
+		if format.Encryption != nil {
+			aead, err := fs.encryption(format.Encryption)
+			if err != nil {
+				return nil, err
 			}
+			opened = &encryptedObjects{inner: opened, aead: aead, blobKey: fs.blobKey}
 		}
+		*store = opened
+	}
+	return *store, nil
+}
 
-		return nil
-	})
+// s3 returns the remote of the s3 backend, or nil for other backends
+func (fs *FileSystemStorage) s3() (*s3Remote, error) {
+	fs.storeMutex.Lock()
+	defer fs.storeMutex.Unlock()
 
-	return commits, err
+	if fs.remote != nil {
+		return fs.remote, nil
+	}
+	format, err := ReadFormat(fs.repoPath)
+	if err != nil || format.Backend != BackendS3 {
+		return nil, err
+	}
+	return fs.openS3(format)
 }
 
-// Exists checks if a commit object exists
-func (fs *FileSystemStorage) Exists(hash string) bool {
-	objPath := fs.getObjectPath(hash)
-	_, err := os.Stat(objPath)
-	return err == nil
+// openS3 opens the remote of the s3 backend, shared by its stores; called
+// holding storeMutex
+func (fs *FileSystemStorage) openS3(format Format) (*s3Remote, error) {
+	if fs.remote == nil {
+		remote, err := newS3Remote(fs.repoPath, format.S3, fs.localData)
+		if err != nil {
+			return nil, err
+		}
+		fs.remote = remote
+	}
+	return fs.remote, nil
+}
+
+// localData reads what the s3 backend uploads under key from the local copy
+func (fs *FileSystemStorage) localData(key string) ([]byte, error) {
+	dir, name, _ := strings.Cut(key, "/")
+	switch dir {
+	case ObjectsDir, BlobsDir:
+		store, err := openObjectStore(fs.repoPath, Format{}, dir)
+		if err != nil {
+			return nil, err
+		}
+		return store.Get(name)
+	case PerformanceDir:
+		return os.ReadFile(filepath.Join(fs.repoPath, RepoDir, PerformanceDir, name))
+	}
+	return nil, fmt.Errorf("unknown key %s", key)
+}
+
+// readObject returns the encoded object stored under hash, decompressed
+func (fs *FileSystemStorage) readObject(hash string) ([]byte, error) {
+	store, err := fs.objects()
+	if err != nil {
+		return nil, err
+	}
+	data, err := store.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = decompressObject(data); truncated(nil, err) {
+		return nil, ErrPartialWrite
+	}
+	return data, err
 }
 
 // GenerateHash creates a SHA-1 hash for commit content
@@ -186,10 +678,57 @@ func GenerateHash(content string) string {
 	return fmt.Sprintf("%x", hash)
 }
 
+// CommitHash returns the hash identifying a commit in a repository of format
+// version 1, computed from its content, message and time. The time is hashed
+// in UTC so the hash can be recomputed from the stored commit.
+func CommitHash(commit *Commit) string {
+	return GenerateHash(commit.Content + commit.Message + commit.Timestamp.UTC().Format(time.RFC3339Nano))
+}
+
+// CanonicalCommit serializes everything a commit records but its hash, the
+// same way wherever and whenever it's done: compact JSON, with the time in
+// UTC
+func CanonicalCommit(commit *Commit) []byte {
+	canonical := *commit
+	canonical.Hash = ""
+	canonical.Timestamp = commit.Timestamp.UTC()
+
+	// A struct of strings, numbers and slices always marshals
+	data, _ := json.Marshal(&canonical)
+	return data
+}
+
+// CanonicalHash returns the SHA-256 hash of a commit's canonical
+// serialization, identifying it in a repository of format version 2
+func CanonicalHash(commit *Commit) string {
+	return fmt.Sprintf("%x", sha256.Sum256(CanonicalCommit(commit)))
+}
+
+// HashCommit returns the hash identifying a commit in a repository of the
+// given format version
+func HashCommit(commit *Commit, version int) string {
+	if version >= FormatSHA256 {
+		return CanonicalHash(commit)
+	}
+	return CommitHash(commit)
+}
+
+// VerifyHash reports whether a commit's hash matches its content, whichever
+// format version made it. Commits made before CommitHash hashed the local
+// time as printed, which only matches for times without a monotonic clock
+// reading, e.g. imported ones.
+func VerifyHash(commit *Commit) bool {
+	if len(commit.Hash) == sha256.Size*2 {
+		return commit.Hash == CanonicalHash(commit)
+	}
+	return commit.Hash == CommitHash(commit) ||
+		commit.Hash == GenerateHash(commit.Content+commit.Message+commit.Timestamp.String())
+}
+
 // WriteHead updates the HEAD reference
 func (fs *FileSystemStorage) WriteHead(commitHash string) error {
 	headPath := filepath.Join(fs.repoPath, RepoDir, HeadFile)
-	return os.WriteFile(headPath, []byte(commitHash), 0644)
+	return writeFileAtomically(headPath, []byte(commitHash))
 }
 
 // ReadHead reads the current HEAD reference
@@ -202,6 +741,48 @@ func (fs *FileSystemStorage) ReadHead() (string, error) {
 	return strings.TrimSpace(string(data)), nil
 }
 
+// WriteTag points a named tag at a commit, replacing any existing tag of that name
+func (fs *FileSystemStorage) WriteTag(name, commitHash string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, "/\\ \t\n") {
+		return fmt.Errorf("invalid tag name %q", name)
+	}
+
+	tagsDir := filepath.Join(fs.repoPath, RepoDir, TagsDir)
+	if err := os.MkdirAll(tagsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create tags directory: %w", err)
+	}
+
+	return writeFileAtomically(filepath.Join(tagsDir, name), []byte(commitHash))
+}
+
+// ReadTags returns every tag name with the commit hash it points at
+func (fs *FileSystemStorage) ReadTags() (map[string]string, error) {
+	tagsDir := filepath.Join(fs.repoPath, RepoDir, TagsDir)
+	tags := make(map[string]string)
+
+	entries, err := os.ReadDir(tagsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return tags, nil
+		}
+		return nil, fmt.Errorf("failed to read tags directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || isTempFile(entry.Name()) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(tagsDir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tag %s: %w", entry.Name(), err)
+		}
+		tags[entry.Name()] = strings.TrimSpace(string(data))
+	}
+
+	return tags, nil
+}
+
 // InitializeRepository creates the basic repository structure
 func (fs *FileSystemStorage) InitializeRepository() error {
 	repoDir := filepath.Join(fs.repoPath, RepoDir)
@@ -221,17 +802,10 @@ func (fs *FileSystemStorage) InitializeRepository() error {
 	// Create empty index file
 	indexPath := filepath.Join(repoDir, IndexFile)
 	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
-		if err := os.WriteFile(indexPath, []byte("{}"), 0644); err != nil {
+		if err := os.WriteFile(indexPath, encodeIndexHeader(), 0644); err != nil {
 			return fmt.Errorf("failed to create index file: %w", err)
 		}
 	}
 
 	return nil
 }
-
-// getObjectPath constructs the file path for a commit object
-func (fs *FileSystemStorage) getObjectPath(hash string) string {
-	hashPrefix := hash[:2]
-	hashSuffix := hash[2:]
-	return filepath.Join(fs.repoPath, RepoDir, ObjectsDir, hashPrefix, hashSuffix)
-}
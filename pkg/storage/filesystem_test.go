@@ -165,6 +165,45 @@ func TestWriteAndReadPerformance(t *testing.T) {
 	}
 }
 
+func TestListPerformances(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	storage := NewFileSystemStorage(tempDir)
+	err := storage.InitializeRepository()
+	if err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	// Write performances out of chronological order
+	later := createTestPerformance()
+	later.ID = "perf-later"
+	later.StartTime = time.Now()
+
+	earlier := createTestPerformance()
+	earlier.ID = "perf-earlier"
+	earlier.StartTime = later.StartTime.Add(-time.Hour)
+
+	for _, performance := range []*Performance{later, earlier} {
+		if err := storage.WritePerformance(performance); err != nil {
+			t.Fatalf("Failed to write performance: %v", err)
+		}
+	}
+
+	performances, err := storage.ListPerformances()
+	if err != nil {
+		t.Fatalf("Failed to list performances: %v", err)
+	}
+
+	if len(performances) != 2 {
+		t.Fatalf("Expected 2 performances, got %d", len(performances))
+	}
+
+	if performances[0].ID != "perf-earlier" || performances[1].ID != "perf-later" {
+		t.Errorf("Expected performances ordered by start time, got '%s', '%s'", performances[0].ID, performances[1].ID)
+	}
+}
+
 func TestExists(t *testing.T) {
 	tempDir := createTempDir(t)
 	defer os.RemoveAll(tempDir)
@@ -265,6 +304,91 @@ func TestGenerateHash(t *testing.T) {
 	}
 }
 
+func TestCommitHashSurvivesStorage(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	storage := NewFileSystemStorage(tempDir)
+	if err := storage.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	// time.Now carries a monotonic reading and the local zone, neither stored
+	commit := &Commit{Timestamp: time.Now(), Message: "Kick", Content: "d1 $ s \"bd\""}
+	commit.Hash = CommitHash(commit)
+	if err := storage.WriteCommit(commit); err != nil {
+		t.Fatalf("Failed to write commit: %v", err)
+	}
+
+	stored, err := storage.ReadCommit(commit.Hash)
+	if err != nil {
+		t.Fatalf("Failed to read commit: %v", err)
+	}
+	if CommitHash(stored) != commit.Hash || !VerifyHash(stored) {
+		t.Errorf("Expected the stored commit to hash to %s, got %s", commit.Hash, CommitHash(stored))
+	}
+
+	stored.Content = "hush"
+	if VerifyHash(stored) {
+		t.Errorf("Expected changed content not to verify")
+	}
+}
+
+func TestCanonicalHash(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	storage := NewFileSystemStorage(tempDir)
+	if err := storage.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	commit := createTestCommit()
+	commit.Timestamp = time.Now()
+	commit.Hash = HashCommit(commit, FormatSHA256)
+	if len(commit.Hash) != 64 || commit.Hash != CanonicalHash(commit) {
+		t.Fatalf("Expected a SHA-256 hash, got %s", commit.Hash)
+	}
+	if HashCommit(commit, FormatSHA1) != CommitHash(commit) {
+		t.Errorf("Expected format version 1 to hash with SHA-1")
+	}
+
+	// The same commit in another time zone, read back from storage
+	elsewhere := *commit
+	elsewhere.Timestamp = commit.Timestamp.In(time.FixedZone("UTC+9", 9*3600))
+	if CanonicalHash(&elsewhere) != commit.Hash {
+		t.Errorf("Expected the hash not to depend on the time zone")
+	}
+	if err := storage.WriteCommit(commit); err != nil {
+		t.Fatalf("Failed to write commit: %v", err)
+	}
+	stored, err := storage.ReadCommit(commit.Hash)
+	if err != nil {
+		t.Fatalf("Failed to read commit: %v", err)
+	}
+	if !VerifyHash(stored) {
+		t.Errorf("Expected the stored commit to verify")
+	}
+
+	// Unlike SHA-1 hashes, everything the commit records is covered
+	stored.Metadata.Buffer = "other"
+	if VerifyHash(stored) {
+		t.Errorf("Expected changed metadata not to verify")
+	}
+	stored.Metadata.Buffer = commit.Metadata.Buffer
+	stored.Parent = "elsewhere"
+	if VerifyHash(stored) {
+		t.Errorf("Expected a changed parent not to verify")
+	}
+
+	if version, err := storage.FormatVersion(); err != nil || version != FormatSHA1 {
+		t.Errorf("Expected a repository without a format file at version 1, got %d (%v)", version, err)
+	}
+	if err := storage.SetFormatVersion(CurrentFormatVersion + 1); err == nil {
+		t.Errorf("Expected an unknown format version to be rejected")
+	}
+}
+
 func TestWriteAndReadHead(t *testing.T) {
 	tempDir := createTempDir(t)
 	defer os.RemoveAll(tempDir)
@@ -293,3 +417,42 @@ func TestWriteAndReadHead(t *testing.T) {
 		t.Errorf("Expected HEAD '%s', got '%s'", commitHash, readHash)
 	}
 }
+
+func TestWriteAndReadTags(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	storage := NewFileSystemStorage(tempDir)
+	if err := storage.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	tags, err := storage.ReadTags()
+	if err != nil {
+		t.Fatalf("Failed to read tags: %v", err)
+	}
+	if len(tags) != 0 {
+		t.Errorf("Expected no tags, got %d", len(tags))
+	}
+
+	if err := storage.WriteTag("drop", "abc123"); err != nil {
+		t.Fatalf("Failed to write tag: %v", err)
+	}
+	if err := storage.WriteTag("drop", "def456"); err != nil {
+		t.Fatalf("Failed to move tag: %v", err)
+	}
+
+	tags, err = storage.ReadTags()
+	if err != nil {
+		t.Fatalf("Failed to read tags: %v", err)
+	}
+	if len(tags) != 1 || tags["drop"] != "def456" {
+		t.Errorf("Expected tag drop -> def456, got %v", tags)
+	}
+
+	for _, name := range []string{"", ".hidden", "a/b", "two words"} {
+		if err := storage.WriteTag(name, "abc123"); err == nil {
+			t.Errorf("Expected error for tag name %q", name)
+		}
+	}
+}
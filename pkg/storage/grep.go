@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/livecodegit/pkg/diff"
+)
+
+// GrepMatch is a line of a stored commit's content that matched a pattern
+type GrepMatch struct {
+	Hash string `json:"hash"`
+	Line int    `json:"line"` // from 1
+	Text string `json:"text"`
+}
+
+// grepObject is the part of a commit object that grep looks at
+type grepObject struct {
+	Content string   `json:"content"`
+	Blob    string   `json:"blob"`
+	Chunks  []string `json:"chunks"`
+}
+
+// Grep matches the content of commits against pattern line by line, calling
+// fn for each matching line in the order of hashes. fn returns false to skip
+// the rest of a commit, e.g. when only the matching commits are wanted.
+//
+// Only the content of an object is decoded, and objects without the
+// pattern's literal prefix anywhere in their encoding aren't decoded at all.
+// Content stored as a blob is read raw, so the prefix is looked for there.
+func (fs *FileSystemStorage) Grep(hashes []string, pattern *regexp.Regexp, fn func(GrepMatch) bool) error {
+	prefix := grepPrefix(pattern)
+
+	for _, hash := range hashes {
+		data, err := fs.readObject(hash)
+		if err != nil {
+			return fmt.Errorf("failed to read commit %s: %w", hash, err)
+		}
+		if prefix != nil && !bytes.Contains(data, prefix) && !bytes.Contains(data, []byte(`"blob"`)) &&
+			!bytes.Contains(data, []byte(`"chunks"`)) {
+			continue
+		}
+
+		var object grepObject
+		if err := json.Unmarshal(data, &object); err != nil {
+			return fmt.Errorf("failed to unmarshal commit %s: %w", hash, err)
+		}
+		blobs := object.Chunks
+		if object.Blob != "" {
+			blobs = []string{object.Blob}
+		}
+		if len(blobs) > 0 {
+			var content []byte
+			for _, blob := range blobs {
+				data, err := fs.readBlob(blob)
+				if err != nil {
+					return fmt.Errorf("failed to read commit %s: %w", hash, err)
+				}
+				content = append(content, data...)
+			}
+			if prefix != nil && !bytes.Contains(content, prefix) {
+				continue
+			}
+			object.Content = string(content)
+		}
+
+		for i, line := range diff.Lines(object.Content) {
+			if pattern.MatchString(line) && !fn(GrepMatch{Hash: hash, Line: i + 1, Text: line}) {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// grepPrefix returns the literal every match of pattern starts with, when it
+// reads the same in an object's JSON encoding, or nil
+func grepPrefix(pattern *regexp.Regexp) []byte {
+	prefix, _ := pattern.LiteralPrefix()
+	if prefix == "" {
+		return nil
+	}
+
+	// The encoder escapes these, so the raw bytes can't be searched for them
+	for _, r := range prefix {
+		if r < 0x20 || r == 0x7f || r == 0x2028 || r == 0x2029 || strings.ContainsRune(`"\<>&`, r) {
+			return nil
+		}
+	}
+	return []byte(prefix)
+}
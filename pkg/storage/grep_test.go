@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"os"
+	"regexp"
+	"testing"
+)
+
+func TestGrep(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+	fs := NewFileSystemStorage(tempDir)
+
+	first := createTestCommit()
+	first.Hash = "aaaa1111"
+	first.Content = "live_loop :drums do\n  sample :bd_haus\nend"
+	second := createTestCommit()
+	second.Hash = "bbbb2222"
+	second.Content = "play 60 if x < 3 && \"y\"\nsample :bd_tek"
+	for _, commit := range []*Commit{first, second} {
+		if err := fs.WriteCommit(commit); err != nil {
+			t.Fatalf("Failed to write commit: %v", err)
+		}
+	}
+	hashes := []string{"bbbb2222", "aaaa1111"}
+
+	var matches []GrepMatch
+	collect := func(match GrepMatch) bool {
+		matches = append(matches, match)
+		return true
+	}
+
+	if err := fs.Grep(hashes, regexp.MustCompile(`sample :bd_\w+`), collect); err != nil {
+		t.Fatalf("Failed to grep: %v", err)
+	}
+	if len(matches) != 2 || matches[0].Hash != "bbbb2222" || matches[0].Line != 2 || matches[1].Text != "  sample :bd_haus" {
+		t.Errorf("Expected line 2 of bbbb2222 then line 2 of aaaa1111, got %+v", matches)
+	}
+
+	// Characters escaped in the stored JSON still match
+	matches = nil
+	if err := fs.Grep(hashes, regexp.MustCompile(`< 3 && "y"`), collect); err != nil {
+		t.Fatalf("Failed to grep: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Line != 1 {
+		t.Errorf("Expected one match on line 1, got %+v", matches)
+	}
+
+	// Returning false skips to the next commit
+	matches = nil
+	fs.Grep(hashes, regexp.MustCompile(`.`), func(match GrepMatch) bool {
+		matches = append(matches, match)
+		return false
+	})
+	if len(matches) != 2 {
+		t.Errorf("Expected one match per commit, got %d", len(matches))
+	}
+
+	if err := fs.Grep([]string{"cccc3333"}, regexp.MustCompile(`x`), collect); err == nil {
+		t.Errorf("Expected a missing object to fail")
+	}
+}
+
+func TestGrepPrefix(t *testing.T) {
+	tests := map[string]string{
+		`sample :bd`: "sample :bd",
+		`every \d+`:  "every ",
+		`(?i)sample`: "",
+		`x < 3`:      "",
+		`say "hi"`:   "",
+		`[ab]c`:      "",
+		"tab\there":  "",
+		`dé \w`:      "dé ",
+	}
+
+	for expression, expected := range tests {
+		if prefix := string(grepPrefix(regexp.MustCompile(expression))); prefix != expected {
+			t.Errorf("Expected prefix '%s' for %s, got '%s'", expected, expression, prefix)
+		}
+	}
+}
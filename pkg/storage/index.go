@@ -1,29 +1,104 @@
 package storage
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"os"
-	"path/filepath"
+	"strings"
 	"time"
 )
 
+// IndexVersion is the current index format. Version 2 added commit metadata
+// to entries so history can be filtered without reading commit objects.
+// Version 3 is a header line followed by an entry per line, so commits are
+// appended rather than the index rewritten, and recent entries are read from
+// the end of the file. Version 4 is the same in binary records with
+// checksums, see index_encoding.go.
+const IndexVersion = 4
+
+// indexPageSize is how much of the index is read at a time from its end
+const indexPageSize = 64 * 1024
+
+// indexHeader is the first line of a version 3 index
+type indexHeader struct {
+	Version int `json:"version"`
+}
+
 // IndexEntry represents a single entry in the repository index
 type IndexEntry struct {
-	Hash      string    `json:"hash"`
-	Timestamp time.Time `json:"timestamp"`
-	Message   string    `json:"message"`
-	Parent    string    `json:"parent,omitempty"`
+	Hash         string    `json:"hash"`
+	Timestamp    time.Time `json:"timestamp"`
+	Message      string    `json:"message"`
+	Parent       string    `json:"parent,omitempty"`
+	BufferParent string    `json:"buffer_parent,omitempty"` // previous commit of the same buffer
+	Author       string    `json:"author,omitempty"`
+	Language     string    `json:"language,omitempty"`
+	Buffer       string    `json:"buffer,omitempty"`
+	Success      bool      `json:"success"`
+	RMS          float64   `json:"rms,omitempty"`
+}
+
+// IndexFilter selects index entries by commit metadata. Empty fields match
+// every entry; Success, when set, matches only successful or failed executions.
+type IndexFilter struct {
+	Language string
+	Buffer   string
+	Author   string
+	Success  *bool
+	MinRMS   float64   // only commits whose sound was at least this loud
+	Since    time.Time // only commits made at or after this time
+}
+
+// Matches reports whether an entry satisfies the filter
+func (f IndexFilter) Matches(entry IndexEntry) bool {
+	if f.Language != "" && !strings.EqualFold(f.Language, entry.Language) {
+		return false
+	}
+	if f.Buffer != "" && f.Buffer != entry.Buffer {
+		return false
+	}
+	if f.Author != "" && !strings.EqualFold(f.Author, entry.Author) {
+		return false
+	}
+	if f.Success != nil && *f.Success != entry.Success {
+		return false
+	}
+	if f.MinRMS > 0 && entry.RMS < f.MinRMS {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Timestamp.Before(f.Since) {
+		return false
+	}
+	return true
+}
+
+// IndexStorage is what the index and the search index are kept in: named
+// files of the repository, and the commits they're rebuilt from
+type IndexStorage interface {
+	ListCommits() ([]string, error)
+	ReadCommit(hash string) (*Commit, error)
+	ReadRepoFile(name string) ([]byte, error) // an error satisfying os.IsNotExist when missing
+	ReadRepoFileAt(name string, p []byte, off int64) (int, error)
+	WriteRepoFile(name string, data []byte) error
+	AppendRepoFile(name string, data []byte) error
+	StatRepoFile(name string) (os.FileInfo, error)
 }
 
 // Index manages the repository index for fast commit lookups
 type Index struct {
-	Entries []IndexEntry `json:"entries"`
-	storage *FileSystemStorage
+	Entries    []IndexEntry `json:"entries"`
+	storage    IndexStorage
+	loaded     os.FileInfo // the index file as last loaded or saved here
+	lazy       bool        // only the header is read, see OpenIndex
+	appendable bool        // the file is in the current format, so entries are appended to it
 }
 
 // NewIndex creates a new index manager
-func NewIndex(storage *FileSystemStorage) *Index {
+func NewIndex(storage IndexStorage) *Index {
 	return &Index{
 		Entries: make([]IndexEntry, 0),
 		storage: storage,
@@ -32,9 +107,54 @@ func NewIndex(storage *FileSystemStorage) *Index {
 
 // LoadIndex reads the index from disk
 func (idx *Index) LoadIndex() error {
-	indexPath := filepath.Join(idx.storage.repoPath, RepoDir, IndexFile)
+	if err := idx.load(); err != nil {
+		return err
+	}
+	idx.lazy = false
+	return nil
+}
 
-	data, err := os.ReadFile(indexPath)
+// OpenIndex reads only the index's header, so opening a long history costs
+// the same as a new one. GetHead, GetOrderedCommits and FilterCommits then
+// read entries from the end of the file, as far back as they need, and new
+// entries are appended; everything else loads the whole index first. An
+// index in an earlier format is loaded whole.
+func (idx *Index) OpenIndex() error {
+	info, err := idx.storage.StatRepoFile(IndexFile)
+	if err != nil {
+		return idx.LoadIndex()
+	}
+
+	header := make([]byte, indexHeaderSize)
+	n, err := idx.storage.ReadRepoFileAt(IndexFile, header, 0)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+	if binaryIndexVersion(header[:n]) != IndexVersion {
+		return idx.LoadIndex()
+	}
+
+	idx.Entries = make([]IndexEntry, 0)
+	idx.loaded = info
+	idx.lazy = true
+	idx.appendable = true
+	return nil
+}
+
+// Load reads the whole of an index opened with OpenIndex; once loaded, it
+// does nothing
+func (idx *Index) Load() error {
+	if !idx.lazy {
+		return nil
+	}
+	return idx.LoadIndex()
+}
+
+// load reads every entry of the index file
+func (idx *Index) load() error {
+	idx.loaded, _ = idx.storage.StatRepoFile(IndexFile)
+	idx.appendable = false
+	data, err := idx.storage.ReadRepoFile(IndexFile)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// Index doesn't exist yet, start with empty index
@@ -49,69 +169,458 @@ func (idx *Index) LoadIndex() error {
 		return nil
 	}
 
-	var indexData struct {
-		Entries []IndexEntry `json:"entries"`
+	entries, version, err := parseIndex(data)
+	if err != nil {
+		return idx.recover(data, err)
+	}
+
+	idx.Entries = entries
+	idx.appendable = version >= IndexVersion
+
+	// Version 1 indexes lack commit metadata; recover it from the commits
+	if version < 2 && len(idx.Entries) > 0 {
+		return idx.RebuildIndex()
 	}
 
-	if err := json.Unmarshal(data, &indexData); err != nil {
-		return fmt.Errorf("failed to unmarshal index: %w", err)
+	return nil
+}
+
+// recover rebuilds a damaged index from the commits in storage, so a
+// command, or a performance, carries on rather than failing. The damaged
+// index is kept as IndexCorruptFile.
+func (idx *Index) recover(data []byte, damage error) error {
+	if err := idx.storage.WriteRepoFile(IndexCorruptFile, data); err != nil {
+		return fmt.Errorf("failed to unmarshal index: %w (keeping a copy to rebuild it failed: %v)", damage, err)
+	}
+	skipped, err := idx.rebuild(true)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal index: %w (rebuilding it failed: %v)", damage, err)
 	}
 
-	idx.Entries = indexData.Entries
+	log.Printf("Index damaged (%v): rebuilt it from %d commits, skipping %d unreadable, and kept the damaged one as %s",
+		damage, len(idx.Entries), skipped, IndexCorruptFile)
 	return nil
 }
 
+// parseIndex returns the entries of an index file and its version
+func parseIndex(data []byte) ([]IndexEntry, int, error) {
+	if version := binaryIndexVersion(data); version > 0 {
+		entries, err := parseBinaryIndex(data)
+		return entries, version, err
+	}
+
+	line, rest, _ := bytes.Cut(data, []byte("\n"))
+	var header indexHeader
+	if json.Unmarshal(line, &header) != nil || header.Version < 3 {
+		// Earlier versions are a single JSON document
+		var indexData struct {
+			Version int          `json:"version"`
+			Entries []IndexEntry `json:"entries"`
+		}
+		if err := json.Unmarshal(data, &indexData); err != nil {
+			return nil, 0, err
+		}
+		return indexData.Entries, indexData.Version, nil
+	}
+
+	entries := make([]IndexEntry, 0, bytes.Count(rest, []byte("\n")))
+	for len(rest) > 0 {
+		var complete bool
+		line, rest, complete = bytes.Cut(rest, []byte("\n"))
+		if !complete {
+			// An entry being appended when a process died is cut short;
+			// fsck finds its commit unindexed
+			break
+		}
+		var entry IndexEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, header.Version, nil
+}
+
 // SaveIndex writes the index to disk
 func (idx *Index) SaveIndex() error {
-	indexPath := filepath.Join(idx.storage.repoPath, RepoDir, IndexFile)
-
-	indexData := struct {
-		Entries []IndexEntry `json:"entries"`
-	}{
-		Entries: idx.Entries,
+	if err := idx.writeTo(IndexFile); err != nil {
+		return err
 	}
+	idx.loaded, _ = idx.storage.StatRepoFile(IndexFile)
+	return nil
+}
 
-	data, err := json.MarshalIndent(indexData, "", "  ")
+// Refresh reloads the index if another process saved it since it was last
+// loaded or saved here, and reports whether it did. Call it holding the
+// repository lock, before changing the index, so the other process's
+// changes aren't written over.
+func (idx *Index) Refresh() (bool, error) {
+	if idx.lazy {
+		return false, idx.LoadIndex()
+	}
+	info, err := idx.storage.StatRepoFile(IndexFile)
 	if err != nil {
-		return fmt.Errorf("failed to marshal index: %w", err)
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to stat index: %w", err)
+	}
+	if idx.loaded != nil && os.SameFile(info, idx.loaded) &&
+		info.Size() == idx.loaded.Size() && info.ModTime().Equal(idx.loaded.ModTime()) {
+		return false, nil
 	}
+	return true, idx.LoadIndex()
+}
 
-	return os.WriteFile(indexPath, data, 0644)
+// SaveSnapshot writes a copy of the index to IndexSnapshotFile, to fall back
+// on when the index itself is lost
+func (idx *Index) SaveSnapshot() error {
+	if err := idx.writeTo(IndexSnapshotFile); err != nil {
+		return fmt.Errorf("failed to save index snapshot: %w", err)
+	}
+	return nil
+}
+
+// writeTo writes the index to the named file of the repository directory.
+// The index is written aside and renamed, so a crash never leaves it half
+// written.
+func (idx *Index) writeTo(name string) error {
+	if err := idx.Load(); err != nil {
+		return err
+	}
+
+	data := encodeIndexHeader()
+	for _, entry := range idx.Entries {
+		data = append(data, encodeIndexEntry(entry)...)
+	}
+
+	if err := idx.storage.WriteRepoFile(name, data); err != nil {
+		return err
+	}
+	if name == IndexFile {
+		idx.appendable = true
+	}
+	return nil
 }
 
 // AddEntry adds a new commit to the index
 func (idx *Index) AddEntry(hash, message, parent string, timestamp time.Time) error {
-	entry := IndexEntry{
+	return idx.add(IndexEntry{
 		Hash:      hash,
 		Timestamp: timestamp,
 		Message:   message,
 		Parent:    parent,
+	})
+}
+
+// AddCommit adds a commit and its metadata to the index
+func (idx *Index) AddCommit(commit *Commit) error {
+	return idx.add(newIndexEntry(commit))
+}
+
+// add records a new entry, appended to the index file when it's in the
+// current format and not cut short, or else with the index rewritten
+func (idx *Index) add(entry IndexEntry) error {
+	if idx.appendable {
+		intact, err := idx.intact()
+		if err != nil {
+			return err
+		}
+		if intact {
+			if err := idx.storage.AppendRepoFile(IndexFile, encodeIndexEntry(entry)); err != nil {
+				return fmt.Errorf("failed to append to index: %w", err)
+			}
+			if !idx.lazy {
+				idx.Entries = append(idx.Entries, entry)
+			}
+			idx.loaded, _ = idx.storage.StatRepoFile(IndexFile)
+			return nil
+		}
 	}
 
+	if err := idx.Load(); err != nil {
+		return err
+	}
 	idx.Entries = append(idx.Entries, entry)
 	return idx.SaveIndex()
 }
 
-// GetOrderedCommits returns commits in chronological order
-func (idx *Index) GetOrderedCommits(limit int) []IndexEntry {
-	// Since entries are added chronologically, we can return them in reverse order
-	// for most recent first
+// intact reports whether the index file ends with a whole entry, so another
+// can be appended to it
+func (idx *Index) intact() (bool, error) {
+	info, err := idx.storage.StatRepoFile(IndexFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to stat index: %w", err)
+	}
+	if info.Size() == indexHeaderSize {
+		return true, nil
+	}
+	if info.Size() < indexHeaderSize+indexMinRecord {
+		return false, nil
+	}
+
+	trailer := make([]byte, indexTrailer)
+	if _, err := idx.storage.ReadRepoFileAt(IndexFile, trailer, info.Size()-indexTrailer); err != nil {
+		return false, fmt.Errorf("failed to read index: %w", err)
+	}
+	size := int64(binary.LittleEndian.Uint32(trailer))
+	if size < indexMinRecord || size > info.Size()-indexHeaderSize {
+		return false, nil
+	}
+	record := make([]byte, size)
+	if _, err := idx.storage.ReadRepoFileAt(IndexFile, record, info.Size()-size); err != nil {
+		return false, fmt.Errorf("failed to read index: %w", err)
+	}
+	_, err = decodeIndexEntry(record)
+	return err == nil, nil
+}
+
+// ReplaceHead replaces the most recent entry with a commit amending it and
+// saves the index
+func (idx *Index) ReplaceHead(commit *Commit) error {
+	if err := idx.Load(); err != nil {
+		return err
+	}
+	if len(idx.Entries) == 0 {
+		return fmt.Errorf("index is empty")
+	}
+
+	idx.Entries[len(idx.Entries)-1] = newIndexEntry(commit)
+	return idx.SaveIndex()
+}
+
+// newIndexEntry builds the index entry describing a commit
+func newIndexEntry(commit *Commit) IndexEntry {
+	entry := IndexEntry{
+		Hash:         commit.Hash,
+		Timestamp:    commit.Timestamp,
+		Message:      commit.Message,
+		Parent:       commit.Parent,
+		BufferParent: commit.BufferParent,
+		Author:       commit.Author,
+		Language:     commit.Metadata.Language,
+		Buffer:       commit.Metadata.Buffer,
+		Success:      commit.Metadata.Success,
+	}
+	if commit.Metadata.Audio != nil {
+		entry.RMS = commit.Metadata.Audio.RMS
+	}
+	return entry
+}
+
+// Describes reports whether the entry matches the commit it indexes
+func (e IndexEntry) Describes(commit *Commit) bool {
+	expected := newIndexEntry(commit)
+	return e.Hash == expected.Hash && e.Timestamp.Equal(expected.Timestamp) && e.Message == expected.Message &&
+		e.Parent == expected.Parent && e.BufferParent == expected.BufferParent && e.Author == expected.Author && e.Language == expected.Language &&
+		e.Buffer == expected.Buffer && e.Success == expected.Success && e.RMS == expected.RMS
+}
+
+// RestoreCommit puts a commit back into the index without saving it: its
+// entry is replaced, or inserted after the entries that are not later
+func (idx *Index) RestoreCommit(commit *Commit) {
+	idx.loadQuietly()
+	entry := newIndexEntry(commit)
+	for i := range idx.Entries {
+		if idx.Entries[i].Hash == commit.Hash {
+			idx.Entries[i] = entry
+			return
+		}
+	}
+
+	position := len(idx.Entries)
+	for position > 0 && idx.Entries[position-1].Timestamp.After(entry.Timestamp) {
+		position--
+	}
+	idx.Entries = append(idx.Entries, IndexEntry{})
+	copy(idx.Entries[position+1:], idx.Entries[position:])
+	idx.Entries[position] = entry
+}
+
+// GetOrderedCommits returns a page of up to limit entries, most recent
+// first, after skipping the offset most recent ones. Reading an index opened
+// with OpenIndex costs offset+limit entries, however long the history.
+func (idx *Index) GetOrderedCommits(offset, limit int) ([]IndexEntry, error) {
+	return idx.FilterCommits(IndexFilter{}, offset, limit)
+}
+
+// FilterCommits returns a page of up to limit entries matching the filter,
+// most recent first, after skipping the offset most recent matches
+func (idx *Index) FilterCommits(filter IndexFilter, offset, limit int) ([]IndexEntry, error) {
 	entries := make([]IndexEntry, 0)
+	if limit <= 0 {
+		return entries, nil
+	}
+
+	err := idx.eachRecent(func(entry IndexEntry) bool {
+		if !filter.Matches(entry) {
+			return true
+		}
+		if offset > 0 {
+			offset--
+			return true
+		}
+		entries = append(entries, entry)
+		return len(entries) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
 
-	start := len(idx.Entries) - limit
-	if start < 0 {
-		start = 0
+// eachRecent calls fn with each entry, most recent first, until it returns
+// false. An index opened with OpenIndex is read from the end of the file a
+// page at a time.
+func (idx *Index) eachRecent(fn func(IndexEntry) bool) error {
+	if !idx.lazy {
+		for i := len(idx.Entries) - 1; i >= 0; i-- {
+			if !fn(idx.Entries[i]) {
+				return nil
+			}
+		}
+		return nil
 	}
 
-	for i := len(idx.Entries) - 1; i >= start; i-- {
-		entries = append(entries, idx.Entries[i])
+	info, err := idx.storage.StatRepoFile(IndexFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat index: %w", err)
 	}
 
+	// data holds the file from start to where the entries read so far began
+	size := info.Size()
+	end, start := size, size
+	var data []byte
+	read := 0
+	for end > indexHeaderSize {
+		// Read back until data holds the trailer and then the whole record
+		var record int64
+		for {
+			if end-start >= indexTrailer {
+				record = int64(binary.LittleEndian.Uint32(data[len(data)-indexTrailer:]))
+				if record < indexMinRecord || record > end-indexHeaderSize {
+					break
+				}
+				if end-start >= record {
+					break
+				}
+			}
+			if start <= indexHeaderSize {
+				record = 0
+				break
+			}
+			from := start - indexPageSize
+			if record > 0 && end-record < from {
+				from = end - record
+			}
+			if from < indexHeaderSize {
+				from = indexHeaderSize
+			}
+			page := make([]byte, start-from)
+			if _, err := idx.storage.ReadRepoFileAt(IndexFile, page, from); err != nil {
+				return fmt.Errorf("failed to read index: %w", err)
+			}
+			data = append(page, data...)
+			start = from
+		}
+
+		var entry IndexEntry
+		err := fmt.Errorf("invalid index record")
+		if record >= indexMinRecord && end-start >= record {
+			entry, err = decodeIndexEntry(data[len(data)-int(record):])
+		}
+		if err != nil {
+			// The last record may be cut short by a crash, which only
+			// reading the index from its start tells, and a damaged index
+			// is rebuilt by loading it
+			if err := idx.LoadIndex(); err != nil {
+				return err
+			}
+			return idx.eachRecent(func(entry IndexEntry) bool {
+				if read > 0 {
+					read--
+					return true
+				}
+				return fn(entry)
+			})
+		}
+		data = data[:len(data)-int(record)]
+		end -= record
+		read++
+		if !fn(entry) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// loadQuietly loads an index opened with OpenIndex for the methods that
+// don't return errors; one that failed to load reads as empty, and the error
+// is returned by the next change to it
+func (idx *Index) loadQuietly() {
+	if err := idx.Load(); err != nil {
+		idx.Entries = make([]IndexEntry, 0)
+	}
+}
+
+// AllEntries returns every entry, oldest first, loading an index opened with
+// OpenIndex
+func (idx *Index) AllEntries() []IndexEntry {
+	idx.loadQuietly()
+	return idx.Entries
+}
+
+// BufferHead returns the latest commit of a buffer among the first n
+// entries, or "" when the buffer has none
+func (idx *Index) BufferHead(buffer string, n int) string {
+	idx.loadQuietly()
+	for i := n - 1; i >= 0; i-- {
+		if idx.Entries[i].Buffer == buffer {
+			return idx.Entries[i].Hash
+		}
+	}
+	return ""
+}
+
+// GetEntriesSince returns entries recorded at or after the given time, oldest first
+func (idx *Index) GetEntriesSince(since time.Time) []IndexEntry {
+	idx.loadQuietly()
+	entries := make([]IndexEntry, 0)
+	for _, entry := range idx.Entries {
+		if !entry.Timestamp.Before(since) {
+			entries = append(entries, entry)
+		}
+	}
 	return entries
 }
 
+// RecentEntriesSince returns the entries made at or after since, oldest
+// first, reading back from the most recent one to the first made before.
+// Unlike GetEntriesSince, an index opened with OpenIndex reads only those.
+func (idx *Index) RecentEntriesSince(since time.Time) ([]IndexEntry, error) {
+	var entries []IndexEntry
+	err := idx.eachRecent(func(entry IndexEntry) bool {
+		if entry.Timestamp.Before(since) {
+			return false
+		}
+		entries = append(entries, entry)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
 // GetEntry retrieves an index entry by hash
 func (idx *Index) GetEntry(hash string) *IndexEntry {
+	idx.loadQuietly()
 	for _, entry := range idx.Entries {
 		if entry.Hash == hash {
 			return &entry
@@ -120,38 +629,60 @@ func (idx *Index) GetEntry(hash string) *IndexEntry {
 	return nil
 }
 
+// FindEntry returns the position of a commit in the index
+func (idx *Index) FindEntry(hash string) (int, bool) {
+	idx.loadQuietly()
+	for i, entry := range idx.Entries {
+		if entry.Hash == hash {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
 // GetHead returns the most recent commit hash
 func (idx *Index) GetHead() string {
-	if len(idx.Entries) == 0 {
+	head := ""
+	if err := idx.eachRecent(func(entry IndexEntry) bool {
+		head = entry.Hash
+		return false
+	}); err != nil {
 		return ""
 	}
-	return idx.Entries[len(idx.Entries)-1].Hash
+	return head
 }
 
 // RebuildIndex reconstructs the index from all commits in storage
 func (idx *Index) RebuildIndex() error {
+	_, err := idx.rebuild(false)
+	return err
+}
+
+// rebuild reconstructs the index from all commits in storage. When lenient,
+// commits that can't be read are left out rather than failing it, and
+// counted; fsck reports them.
+func (idx *Index) rebuild(lenient bool) (int, error) {
 	hashes, err := idx.storage.ListCommits()
 	if err != nil {
-		return fmt.Errorf("failed to list commits: %w", err)
+		return 0, fmt.Errorf("failed to list commits: %w", err)
 	}
 
 	idx.Entries = make([]IndexEntry, 0, len(hashes))
+	idx.lazy = false
 
 	// Load all commits and build index entries
+	skipped := 0
 	for _, hash := range hashes {
 		commit, err := idx.storage.ReadCommit(hash)
 		if err != nil {
-			return fmt.Errorf("failed to read commit %s: %w", hash, err)
-		}
-
-		entry := IndexEntry{
-			Hash:      commit.Hash,
-			Timestamp: commit.Timestamp,
-			Message:   commit.Message,
-			Parent:    commit.Parent,
+			if lenient {
+				skipped++
+				continue
+			}
+			return 0, fmt.Errorf("failed to read commit %s: %w", hash, err)
 		}
 
-		idx.Entries = append(idx.Entries, entry)
+		idx.Entries = append(idx.Entries, newIndexEntry(commit))
 	}
 
 	// Sort entries by timestamp to maintain chronological order
@@ -163,5 +694,5 @@ func (idx *Index) RebuildIndex() error {
 		}
 	}
 
-	return idx.SaveIndex()
+	return skipped, idx.SaveIndex()
 }
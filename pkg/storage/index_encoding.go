@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"time"
+)
+
+// The index is binary since version 4: a header of indexMagic and the
+// version, then a record per entry. A record is its fixed-width fields, the
+// lengths of its strings and the strings themselves, followed by a trailer
+// of the record's length and a CRC-32 of everything before it, so records
+// are read backwards from the end of the file as easily as forwards and a
+// damaged one is told from a good one.
+const (
+	indexMagic      = "LCGI"
+	indexHeaderSize = int64(len(indexMagic) + 4)
+	indexTrailer    = 8 // length and CRC
+	indexStrings    = 7 // hash, message, parent, buffer parent, author, language, buffer
+	indexFixed      = 8 + 8 + 1 + 4*indexStrings
+	indexMinRecord  = indexFixed + indexTrailer
+
+	// zeroTime stands for an entry without a time, which UnixNano can't
+	// represent
+	zeroTime = math.MinInt64
+)
+
+// ErrIndexChecksum reports an index record whose CRC doesn't match it
+var ErrIndexChecksum = errors.New("index checksum mismatch")
+
+// encodeIndexHeader returns the header of a binary index
+func encodeIndexHeader() []byte {
+	return binary.LittleEndian.AppendUint32([]byte(indexMagic), IndexVersion)
+}
+
+// binaryIndexVersion returns the version of a binary index from its header,
+// or 0 when data doesn't start with one
+func binaryIndexVersion(data []byte) int {
+	if int64(len(data)) < indexHeaderSize || !bytes.HasPrefix(data, []byte(indexMagic)) {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint32(data[len(indexMagic):]))
+}
+
+// encodeIndexEntry returns the record of an entry
+func encodeIndexEntry(entry IndexEntry) []byte {
+	strs := [indexStrings]string{entry.Hash, entry.Message, entry.Parent, entry.BufferParent, entry.Author, entry.Language, entry.Buffer}
+	size := indexMinRecord
+	for _, s := range strs {
+		size += len(s)
+	}
+
+	record := make([]byte, 0, size)
+	timestamp := int64(zeroTime)
+	if !entry.Timestamp.IsZero() {
+		timestamp = entry.Timestamp.UnixNano()
+	}
+	record = binary.LittleEndian.AppendUint64(record, uint64(timestamp))
+	record = binary.LittleEndian.AppendUint64(record, math.Float64bits(entry.RMS))
+	if entry.Success {
+		record = append(record, 1)
+	} else {
+		record = append(record, 0)
+	}
+	for _, s := range strs {
+		record = binary.LittleEndian.AppendUint32(record, uint32(len(s)))
+	}
+	for _, s := range strs {
+		record = append(record, s...)
+	}
+	record = binary.LittleEndian.AppendUint32(record, uint32(size))
+	return binary.LittleEndian.AppendUint32(record, crc32.ChecksumIEEE(record))
+}
+
+// decodeIndexEntry decodes a whole record, checking its CRC
+func decodeIndexEntry(record []byte) (IndexEntry, error) {
+	if len(record) < indexMinRecord || int(binary.LittleEndian.Uint32(record[len(record)-indexTrailer:])) != len(record) {
+		return IndexEntry{}, fmt.Errorf("invalid index record")
+	}
+	if crc32.ChecksumIEEE(record[:len(record)-4]) != binary.LittleEndian.Uint32(record[len(record)-4:]) {
+		return IndexEntry{}, ErrIndexChecksum
+	}
+
+	var entry IndexEntry
+	if timestamp := int64(binary.LittleEndian.Uint64(record)); timestamp != zeroTime {
+		entry.Timestamp = time.Unix(0, timestamp)
+	}
+	entry.RMS = math.Float64frombits(binary.LittleEndian.Uint64(record[8:]))
+	entry.Success = record[16] == 1
+
+	var strs [indexStrings]string
+	offset := indexFixed
+	for i := range strs {
+		n := int(binary.LittleEndian.Uint32(record[17+4*i:]))
+		if n > len(record)-indexTrailer-offset {
+			return IndexEntry{}, fmt.Errorf("invalid index record")
+		}
+		strs[i] = string(record[offset : offset+n])
+		offset += n
+	}
+	entry.Hash, entry.Message, entry.Parent, entry.BufferParent = strs[0], strs[1], strs[2], strs[3]
+	entry.Author, entry.Language, entry.Buffer = strs[4], strs[5], strs[6]
+	return entry, nil
+}
+
+// indexRecordSize returns the size of the record starting data, from its
+// fixed-width fields, or 0 when data is too short to tell
+func indexRecordSize(data []byte) int {
+	if len(data) < indexFixed {
+		return 0
+	}
+	size := indexMinRecord
+	for i := 0; i < indexStrings; i++ {
+		size += int(binary.LittleEndian.Uint32(data[17+4*i:]))
+	}
+	return size
+}
+
+// trailerSize returns the record length in the trailer data ends with
+func trailerSize(data []byte) int {
+	return int(binary.LittleEndian.Uint32(data[len(data)-indexTrailer:]))
+}
+
+// endsWithRecord reports whether a binary index ends with a whole record
+func endsWithRecord(file []byte) bool {
+	if int64(len(file)) < indexHeaderSize+indexMinRecord {
+		return false
+	}
+	size := trailerSize(file)
+	if size < indexMinRecord || int64(size) > int64(len(file))-indexHeaderSize {
+		return false
+	}
+	_, err := decodeIndexEntry(file[len(file)-size:])
+	return err == nil
+}
+
+// parseBinaryIndex returns the entries of a binary index. A record being
+// appended when a process died is cut short at the end; it's left out, and
+// fsck finds its commit unindexed. A record whose lengths are damaged looks
+// cut short too, but is told apart by the file still ending with a whole
+// record, or with its own trailer.
+func parseBinaryIndex(file []byte) ([]IndexEntry, error) {
+	data := file[indexHeaderSize:]
+	entries := make([]IndexEntry, 0)
+	for len(data) > 0 {
+		size := indexRecordSize(data)
+		if size == 0 || size > len(data) {
+			if endsWithRecord(file) || (len(data) >= indexMinRecord && trailerSize(data) == len(data)) {
+				return nil, fmt.Errorf("invalid index record")
+			}
+			break
+		}
+		entry, err := decodeIndexEntry(data[:size])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+		data = data[size:]
+	}
+	return entries, nil
+}
@@ -1,7 +1,11 @@
 package storage
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -144,6 +148,40 @@ func TestSaveAndLoadIndex(t *testing.T) {
 	}
 }
 
+func TestSaveSnapshot(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	storage := NewFileSystemStorage(tempDir)
+	if err := storage.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	index := NewIndex(storage)
+	if err := index.AddEntry("abc123", "Test commit", "", time.Now()); err != nil {
+		t.Fatalf("Failed to add entry: %v", err)
+	}
+	if err := index.SaveSnapshot(); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+
+	// The snapshot reads back as an index
+	snapshot, err := os.ReadFile(filepath.Join(tempDir, RepoDir, IndexSnapshotFile))
+	if err != nil {
+		t.Fatalf("Failed to read snapshot: %v", err)
+	}
+	current, err := os.ReadFile(filepath.Join(tempDir, RepoDir, IndexFile))
+	if err != nil {
+		t.Fatalf("Failed to read index: %v", err)
+	}
+	if string(snapshot) != string(current) {
+		t.Errorf("Expected snapshot to match the index, got:\n%s", snapshot)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, RepoDir, IndexSnapshotFile+".tmp")); !os.IsNotExist(err) {
+		t.Errorf("Expected no temporary snapshot left behind")
+	}
+}
+
 func TestGetOrderedCommits(t *testing.T) {
 	tempDir := createTempDir(t)
 	defer os.RemoveAll(tempDir)
@@ -181,8 +219,8 @@ func TestGetOrderedCommits(t *testing.T) {
 	}
 
 	// Get ordered commits (should return most recent first)
-	ordered := index.GetOrderedCommits(10)
-	if len(ordered) != 3 {
+	ordered, err := index.GetOrderedCommits(0, 10)
+	if err != nil || len(ordered) != 3 {
 		t.Errorf("Expected 3 ordered commits, got %d", len(ordered))
 	}
 
@@ -195,8 +233,8 @@ func TestGetOrderedCommits(t *testing.T) {
 	}
 
 	// Test with limit
-	limited := index.GetOrderedCommits(2)
-	if len(limited) != 2 {
+	limited, err := index.GetOrderedCommits(0, 2)
+	if err != nil || len(limited) != 2 {
 		t.Errorf("Expected 2 limited commits, got %d", len(limited))
 	}
 
@@ -290,6 +328,38 @@ func TestGetHead(t *testing.T) {
 	}
 }
 
+func TestRestoreCommit(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	index := NewIndex(NewFileSystemStorage(tempDir))
+	baseTime := time.Now()
+	for i, hash := range []string{"abc123", "def456"} {
+		index.Entries = append(index.Entries, IndexEntry{Hash: hash, Timestamp: baseTime.Add(time.Duration(i*2) * time.Second)})
+	}
+
+	// Inserted by time, without moving the head
+	restored := &Commit{Hash: "fed789", Timestamp: baseTime.Add(time.Second), Message: "Restored",
+		Metadata: ExecutionMetadata{Buffer: "d1"}}
+	index.RestoreCommit(restored)
+	if len(index.Entries) != 3 || index.Entries[1].Hash != "fed789" || index.GetHead() != "def456" {
+		t.Fatalf("Expected the commit between the others, got %+v", index.Entries)
+	}
+	if !index.Entries[1].Describes(restored) {
+		t.Errorf("Expected the entry to describe the commit")
+	}
+
+	// A stale entry is replaced in place
+	restored.Message = "Renamed"
+	if index.Entries[1].Describes(restored) {
+		t.Errorf("Expected a changed message to make the entry stale")
+	}
+	index.RestoreCommit(restored)
+	if len(index.Entries) != 3 || index.Entries[1].Message != "Renamed" {
+		t.Errorf("Expected the entry to be replaced, got %+v", index.Entries)
+	}
+}
+
 func TestRebuildIndex(t *testing.T) {
 	tempDir := createTempDir(t)
 	defer os.RemoveAll(tempDir)
@@ -333,3 +403,398 @@ func TestRebuildIndex(t *testing.T) {
 		t.Errorf("Expected second entry to be 'def456', got '%s'", index.Entries[1].Hash)
 	}
 }
+
+func TestFilterCommits(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	storage := NewFileSystemStorage(tempDir)
+	if err := storage.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	index := NewIndex(storage)
+	baseTime := time.Now()
+	commits := []*Commit{
+		{Hash: "aaa111", Author: "alice", Timestamp: baseTime, Metadata: ExecutionMetadata{Language: "sonicpi", Buffer: "drums", Success: true}},
+		{Hash: "bbb222", Author: "bob", Timestamp: baseTime.Add(time.Second), Metadata: ExecutionMetadata{Language: "tidal", Buffer: "d1", Success: false}},
+		{Hash: "ccc333", Author: "alice", Timestamp: baseTime.Add(2 * time.Second), Metadata: ExecutionMetadata{Language: "tidal", Buffer: "d1", Success: true}},
+	}
+	for _, commit := range commits {
+		if err := index.AddCommit(commit); err != nil {
+			t.Fatalf("Failed to add commit %s: %v", commit.Hash, err)
+		}
+	}
+
+	failed := false
+	tests := []struct {
+		name     string
+		filter   IndexFilter
+		limit    int
+		expected []string
+	}{
+		{"no filter", IndexFilter{}, 10, []string{"ccc333", "bbb222", "aaa111"}},
+		{"language", IndexFilter{Language: "Tidal"}, 10, []string{"ccc333", "bbb222"}},
+		{"buffer", IndexFilter{Buffer: "drums"}, 10, []string{"aaa111"}},
+		{"author", IndexFilter{Author: "alice"}, 10, []string{"ccc333", "aaa111"}},
+		{"failed", IndexFilter{Success: &failed}, 10, []string{"bbb222"}},
+		{"limit applies after filtering", IndexFilter{Author: "alice"}, 1, []string{"ccc333"}},
+	}
+
+	for _, tt := range tests {
+		entries, err := index.FilterCommits(tt.filter, 0, tt.limit)
+		if err != nil || len(entries) != len(tt.expected) {
+			t.Errorf("%s: expected %d entries, got %d", tt.name, len(tt.expected), len(entries))
+			continue
+		}
+		for i, hash := range tt.expected {
+			if entries[i].Hash != hash {
+				t.Errorf("%s: expected entry %d to be %s, got %s", tt.name, i, hash, entries[i].Hash)
+			}
+		}
+	}
+}
+
+func TestLoadLegacyIndexRecoversMetadata(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	storage := NewFileSystemStorage(tempDir)
+	if err := storage.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	commit := &Commit{Hash: "abc123", Message: "Old commit", Author: "user", Timestamp: time.Now(), Metadata: ExecutionMetadata{Language: "tidal", Buffer: "d1", Success: true}}
+	if err := storage.WriteCommit(commit); err != nil {
+		t.Fatalf("Failed to write commit: %v", err)
+	}
+
+	// Index written before entries carried metadata
+	legacy := `{"entries": [{"hash": "abc123", "timestamp": "` + commit.Timestamp.Format(time.RFC3339Nano) + `", "message": "Old commit"}]}`
+	if err := os.WriteFile(filepath.Join(tempDir, RepoDir, IndexFile), []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write legacy index: %v", err)
+	}
+
+	index := NewIndex(storage)
+	if err := index.LoadIndex(); err != nil {
+		t.Fatalf("Failed to load legacy index: %v", err)
+	}
+
+	if len(index.Entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(index.Entries))
+	}
+
+	entry := index.Entries[0]
+	if entry.Language != "tidal" || entry.Buffer != "d1" || !entry.Success || entry.Author != "user" {
+		t.Errorf("Expected metadata to be recovered from the commit, got %+v", entry)
+	}
+}
+
+// tailStorage counts how much of the index is read, and refuses to read it
+// whole
+type tailStorage struct {
+	*FileSystemStorage
+	read int
+}
+
+func (s *tailStorage) ReadRepoFile(name string) ([]byte, error) {
+	return nil, fmt.Errorf("read the whole of %s", name)
+}
+
+func (s *tailStorage) ReadRepoFileAt(name string, p []byte, off int64) (int, error) {
+	s.read += len(p)
+	return s.FileSystemStorage.ReadRepoFileAt(name, p, off)
+}
+
+func TestOpenIndex(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	fs := NewFileSystemStorage(tempDir)
+	if err := fs.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	index := NewIndex(fs)
+	if err := index.LoadIndex(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	baseTime := time.Now()
+	var hashes []string
+	for i := 0; i < 1000; i++ {
+		commit := &Commit{Hash: fmt.Sprintf("%064d", i), Message: fmt.Sprintf("Commit %d", i), Timestamp: baseTime.Add(time.Duration(i) * time.Second),
+			Metadata: ExecutionMetadata{Language: "tidal", Buffer: fmt.Sprintf("d%d", i%4+1), Success: true}}
+		if err := index.AddCommit(commit); err != nil {
+			t.Fatalf("Failed to add commit: %v", err)
+		}
+		hashes = append(hashes, commit.Hash)
+	}
+
+	indexPath := filepath.Join(tempDir, RepoDir, IndexFile)
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatalf("Failed to read index: %v", err)
+	}
+	if binaryIndexVersion(data) != IndexVersion {
+		t.Errorf("Expected a binary index, got %q", data[:indexHeaderSize])
+	}
+	if entries, err := parseBinaryIndex(data); err != nil || len(entries) != 1000 {
+		t.Errorf("Expected 1000 records, got %d (%v)", len(entries), err)
+	}
+
+	// Recent pages are read from the end of the file
+	storage := &tailStorage{FileSystemStorage: fs}
+	opened := NewIndex(storage)
+	if err := opened.OpenIndex(); err != nil {
+		t.Fatalf("Failed to open index: %v", err)
+	}
+	if head := opened.GetHead(); head != hashes[999] {
+		t.Errorf("Expected HEAD %s, got %s", hashes[999], head)
+	}
+	storage.read = 0
+	page, err := opened.GetOrderedCommits(10, 5)
+	if err != nil || len(page) != 5 || page[0].Hash != hashes[989] || page[4].Hash != hashes[985] {
+		t.Errorf("Expected commits 989 to 985, got %+v (%v)", page, err)
+	}
+	if storage.read > indexPageSize+64 {
+		t.Errorf("Expected only the end of the index read, read %d of %d bytes", storage.read, len(data))
+	}
+	filtered, err := opened.FilterCommits(IndexFilter{Buffer: "d1"}, 1, 2)
+	if err != nil || len(filtered) != 2 || filtered[0].Hash != hashes[992] || filtered[1].Hash != hashes[988] {
+		t.Errorf("Expected commits 992 and 988, got %+v (%v)", filtered, err)
+	}
+
+	// Entries since a time are read back only as far as that time
+	storage.read = 0
+	since, err := opened.RecentEntriesSince(baseTime.Add(995 * time.Second))
+	if err != nil || len(since) != 5 || since[0].Hash != hashes[995] || since[4].Hash != hashes[999] {
+		t.Errorf("Expected commits 995 to 999, got %+v (%v)", since, err)
+	}
+	if storage.read > indexPageSize+64 {
+		t.Errorf("Expected only the end of the index read, read %d of %d bytes", storage.read, len(data))
+	}
+
+	// Further back takes more pages
+	storage.read = 0
+	page, err = opened.GetOrderedCommits(990, 5)
+	if err != nil || len(page) != 5 || page[4].Hash != hashes[5] {
+		t.Errorf("Expected commits 9 to 5, got %+v (%v)", page, err)
+	}
+	if storage.read < len(data)-indexPageSize {
+		t.Errorf("Expected most of the index read, read %d of %d bytes", storage.read, len(data))
+	}
+
+	// Commits are appended without loading the rest
+	commit := &Commit{Hash: fmt.Sprintf("%064d", 1000), Message: "Commit 1000", Timestamp: baseTime.Add(1000 * time.Second)}
+	if err := opened.AddCommit(commit); err != nil {
+		t.Fatalf("Failed to add commit: %v", err)
+	}
+	if head := opened.GetHead(); head != commit.Hash {
+		t.Errorf("Expected the appended commit as HEAD, got %s", head)
+	}
+
+	// A crash while appending leaves the last entry cut short; it's skipped,
+	// and the next commit rewrites the index
+	file, err := os.OpenFile(indexPath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open index: %v", err)
+	}
+	file.Write(encodeIndexEntry(IndexEntry{Hash: "torn", Message: "Cut short"})[:30])
+	file.Close()
+
+	torn := NewIndex(fs)
+	if err := torn.OpenIndex(); err != nil {
+		t.Fatalf("Failed to open index: %v", err)
+	}
+	if head := torn.GetHead(); head != commit.Hash {
+		t.Errorf("Expected the torn entry skipped, got HEAD %s", head)
+	}
+	if entries := torn.AllEntries(); len(entries) != 1001 {
+		t.Errorf("Expected 1001 entries loaded, got %d", len(entries))
+	}
+	next := &Commit{Hash: fmt.Sprintf("%064d", 1001), Message: "Commit 1001", Timestamp: baseTime.Add(1001 * time.Second)}
+	if err := torn.AddCommit(next); err != nil {
+		t.Fatalf("Failed to add commit: %v", err)
+	}
+	reloaded := NewIndex(fs)
+	if err := reloaded.LoadIndex(); err != nil || len(reloaded.Entries) != 1002 || reloaded.GetHead() != next.Hash {
+		t.Errorf("Expected 1002 entries after the rewrite, got %d (%v)", len(reloaded.Entries), err)
+	}
+}
+
+func TestOpenVersion2Index(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	fs := NewFileSystemStorage(tempDir)
+	if err := fs.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	legacy := `{
+  "version": 2,
+  "entries": [
+    {"hash": "abc123", "timestamp": "2024-05-01T21:00:00Z", "message": "Old commit", "buffer": "d1", "success": true}
+  ]
+}`
+	indexPath := filepath.Join(tempDir, RepoDir, IndexFile)
+	if err := os.WriteFile(indexPath, []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write index: %v", err)
+	}
+
+	index := NewIndex(fs)
+	if err := index.OpenIndex(); err != nil {
+		t.Fatalf("Failed to open index: %v", err)
+	}
+	if len(index.Entries) != 1 || index.GetHead() != "abc123" {
+		t.Fatalf("Expected the earlier format loaded whole, got %+v", index.Entries)
+	}
+
+	if err := index.AddCommit(&Commit{Hash: "def456", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Failed to add commit: %v", err)
+	}
+	data, err := os.ReadFile(indexPath)
+	if err != nil || binaryIndexVersion(data) != IndexVersion {
+		t.Errorf("Expected the index rewritten in the current format, got %q (%v)", data, err)
+	}
+}
+
+func TestUpgradeVersion3Index(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	fs := NewFileSystemStorage(tempDir)
+	if err := fs.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	lines := `{"version":3}
+{"hash":"abc123","timestamp":"2024-05-01T21:00:00Z","message":"First","buffer":"d1","success":true}
+{"hash":"def456","timestamp":"2024-05-01T21:00:05Z","message":"Second","parent":"abc123","buffer":"d2","success":false,"rms":0.5}
+`
+	indexPath := filepath.Join(tempDir, RepoDir, IndexFile)
+	if err := os.WriteFile(indexPath, []byte(lines), 0644); err != nil {
+		t.Fatalf("Failed to write index: %v", err)
+	}
+
+	index := NewIndex(fs)
+	if err := index.OpenIndex(); err != nil {
+		t.Fatalf("Failed to open index: %v", err)
+	}
+	if len(index.Entries) != 2 || index.GetHead() != "def456" {
+		t.Fatalf("Expected the line index loaded whole, got %+v", index.Entries)
+	}
+	if err := index.AddCommit(&Commit{Hash: "fed789", Timestamp: time.Now(), Metadata: ExecutionMetadata{Buffer: "d1"}}); err != nil {
+		t.Fatalf("Failed to add commit: %v", err)
+	}
+
+	reloaded := NewIndex(fs)
+	if err := reloaded.OpenIndex(); err != nil {
+		t.Fatalf("Failed to open index: %v", err)
+	}
+	entries, err := reloaded.GetOrderedCommits(0, 10)
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("Expected 3 entries in the binary index, got %+v (%v)", entries, err)
+	}
+	second := entries[1]
+	if second.Hash != "def456" || second.Parent != "abc123" || second.Buffer != "d2" || second.Success || second.RMS != 0.5 ||
+		!second.Timestamp.Equal(time.Date(2024, 5, 1, 21, 0, 5, 0, time.UTC)) {
+		t.Errorf("Expected the entry to survive the upgrade, got %+v", second)
+	}
+}
+
+func TestParseBinaryIndexDamagedLength(t *testing.T) {
+	file := encodeIndexHeader()
+	for _, hash := range []string{"abc123", "def456", "fed789"} {
+		file = append(file, encodeIndexEntry(IndexEntry{Hash: hash, Message: "Commit " + hash})...)
+	}
+	if entries, err := parseBinaryIndex(file); err != nil || len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d (%v)", len(entries), err)
+	}
+
+	// A cut short record at the end is left out
+	torn := append(append([]byte(nil), file...), encodeIndexEntry(IndexEntry{Hash: "torn"})[:30]...)
+	if entries, err := parseBinaryIndex(torn); err != nil || len(entries) != 3 {
+		t.Errorf("Expected the torn record left out, got %d (%v)", len(entries), err)
+	}
+
+	// A damaged message length makes a record look longer than what's left,
+	// of the first record as of the last
+	first := int(indexHeaderSize) + 17 + 4
+	last := len(file) - len(encodeIndexEntry(IndexEntry{Hash: "fed789", Message: "Commit fed789"})) + 17 + 4
+	for _, offset := range []int{first, last} {
+		damaged := append([]byte(nil), file...)
+		damaged[offset+1] ^= 0x40
+		if entries, err := parseBinaryIndex(damaged); err == nil {
+			t.Errorf("Expected a damaged length at %d reported, got %d entries", offset, len(entries))
+		}
+	}
+}
+
+func TestIndexRecovery(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	fs := NewFileSystemStorage(tempDir)
+	if err := fs.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	index := NewIndex(fs)
+	baseTime := time.Now()
+	for i, hash := range []string{"abc123", "def456", "fed789"} {
+		commit := &Commit{Hash: hash, Message: "Commit " + hash, Timestamp: baseTime.Add(time.Duration(i) * time.Second)}
+		if err := fs.WriteCommit(commit); err != nil {
+			t.Fatalf("Failed to write commit: %v", err)
+		}
+		if err := index.AddCommit(commit); err != nil {
+			t.Fatalf("Failed to add commit: %v", err)
+		}
+	}
+
+	// Damage the message of the middle record
+	indexPath := filepath.Join(tempDir, RepoDir, IndexFile)
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatalf("Failed to read index: %v", err)
+	}
+	data[bytes.Index(data, []byte("Commit def456"))] = 'c'
+	if err := os.WriteFile(indexPath, data, 0644); err != nil {
+		t.Fatalf("Failed to write index: %v", err)
+	}
+	if _, err := parseBinaryIndex(data); !errors.Is(err, ErrIndexChecksum) {
+		t.Errorf("Expected a checksum mismatch, got %v", err)
+	}
+
+	// Reading back from the end reaches the damage and rebuilds the index
+	opened := NewIndex(fs)
+	if err := opened.OpenIndex(); err != nil {
+		t.Fatalf("Failed to open index: %v", err)
+	}
+	entries, err := opened.GetOrderedCommits(0, 3)
+	if err != nil || len(entries) != 3 || entries[1].Message != "Commit def456" {
+		t.Fatalf("Expected the index rebuilt from the commits, got %+v (%v)", entries, err)
+	}
+	if kept, err := os.ReadFile(filepath.Join(tempDir, RepoDir, IndexCorruptFile)); err != nil || !bytes.Equal(kept, data) {
+		t.Errorf("Expected the damaged index kept, got %v", err)
+	}
+
+	// A JSON index cut short is rebuilt too, without the commits that can't
+	// be read
+	if err := os.MkdirAll(filepath.Join(tempDir, RepoDir, ObjectsDir, "ee"), 0755); err != nil {
+		t.Fatalf("Failed to create object directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, RepoDir, ObjectsDir, "ee", "0000"), []byte(`{"hash": "ee`), 0644); err != nil {
+		t.Fatalf("Failed to write object: %v", err)
+	}
+	if err := os.WriteFile(indexPath, []byte(`{"version": 2, "entries": [{"hash": "abc1`), 0644); err != nil {
+		t.Fatalf("Failed to write index: %v", err)
+	}
+	loaded := NewIndex(fs)
+	if err := loaded.LoadIndex(); err != nil {
+		t.Fatalf("Expected the index recovered, got %v", err)
+	}
+	if len(loaded.Entries) != 3 || loaded.GetHead() != "fed789" {
+		t.Errorf("Expected 3 readable commits indexed, got %+v", loaded.Entries)
+	}
+}
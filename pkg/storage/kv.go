@@ -0,0 +1,340 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// KVFile holds every object of a repository using the key-value backend.
+// A long performance makes tens of thousands of small objects; keeping them
+// in one file spares the filesystem an inode and a directory lookup each.
+const KVFile = "objects.kv"
+
+// kvMagic starts every key-value file
+const kvMagic = "LCGKV1\n"
+
+// Record operations. A record is the operation, the length of the hash, the
+// hash, the big-endian uint32 length of the data and the data; deletions
+// have no data.
+const (
+	kvPut    = '+'
+	kvDelete = '-'
+)
+
+// kvCompactBytes is how many bytes of replaced and deleted records a
+// key-value file may hold before it's compacted, once they also outweigh
+// the live records
+const kvCompactBytes = 1 << 20
+
+// kvObjects is an append-only key-value file of objects with an in-memory
+// map of where each one is. Other processes appending to the file are
+// picked up on the next access, and a file replaced by compaction is read
+// again from the start.
+type kvObjects struct {
+	path string
+
+	mutex   sync.Mutex
+	records map[string]kvRecord
+	scanned os.FileInfo // the file as last scanned
+	end     int64       // end of the last complete record
+	live    int64       // bytes of the records in the map
+	dead    int64       // bytes of replaced and deleted records
+}
+
+// kvRecord locates the data of an object in the file
+type kvRecord struct {
+	offset int64 // of the data
+	length int64 // of the data
+	size   int64 // of the whole record
+}
+
+// newKVObjects returns the key-value store in the file at path
+func newKVObjects(path string) *kvObjects {
+	return &kvObjects{path: path, records: make(map[string]kvRecord)}
+}
+
+func (kv *kvObjects) Put(hash string, data []byte) error {
+	if len(hash) == 0 || len(hash) > 255 || int64(len(data)) > int64(^uint32(0)) {
+		return fmt.Errorf("object %s can't be stored", hash)
+	}
+
+	kv.mutex.Lock()
+	defer kv.mutex.Unlock()
+	return kv.append(kvPut, hash, data)
+}
+
+func (kv *kvObjects) Get(hash string) ([]byte, error) {
+	kv.mutex.Lock()
+	defer kv.mutex.Unlock()
+
+	if err := kv.refresh(); err != nil {
+		return nil, err
+	}
+	record, ok := kv.records[hash]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	file, err := os.Open(kv.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data := make([]byte, record.length)
+	if _, err := file.ReadAt(data, record.offset); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (kv *kvObjects) Has(hash string) bool {
+	kv.mutex.Lock()
+	defer kv.mutex.Unlock()
+
+	if err := kv.refresh(); err != nil {
+		return false
+	}
+	_, ok := kv.records[hash]
+	return ok
+}
+
+func (kv *kvObjects) Size(hash string) (int64, error) {
+	kv.mutex.Lock()
+	defer kv.mutex.Unlock()
+
+	if err := kv.refresh(); err != nil {
+		return 0, err
+	}
+	record, ok := kv.records[hash]
+	if !ok {
+		return 0, os.ErrNotExist
+	}
+	return record.length, nil
+}
+
+func (kv *kvObjects) Delete(hash string) error {
+	kv.mutex.Lock()
+	defer kv.mutex.Unlock()
+
+	if err := kv.refresh(); err != nil {
+		return err
+	}
+	if _, ok := kv.records[hash]; !ok {
+		return os.ErrNotExist
+	}
+	if err := kv.append(kvDelete, hash, nil); err != nil {
+		return err
+	}
+
+	if kv.dead > kvCompactBytes && kv.dead > kv.live {
+		return kv.compact()
+	}
+	return nil
+}
+
+func (kv *kvObjects) List() ([]string, error) {
+	kv.mutex.Lock()
+	defer kv.mutex.Unlock()
+
+	if err := kv.refresh(); err != nil {
+		return nil, err
+	}
+	hashes := make([]string, 0, len(kv.records))
+	for hash := range kv.records {
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// append writes a record at the end of the file, dropping a partial record
+// left there by an interrupted write
+func (kv *kvObjects) append(op byte, hash string, data []byte) error {
+	if err := kv.refresh(); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(kv.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filepath.Base(kv.path), err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	switch {
+	case kv.end == 0:
+		// A new file, or one cut short while being created
+		if err := file.Truncate(0); err != nil {
+			return fmt.Errorf("failed to truncate %s: %w", filepath.Base(kv.path), err)
+		}
+		if _, err := file.Write([]byte(kvMagic)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", filepath.Base(kv.path), err)
+		}
+	case info.Size() > kv.end:
+		if err := file.Truncate(kv.end); err != nil {
+			return fmt.Errorf("failed to truncate %s: %w", filepath.Base(kv.path), err)
+		}
+	}
+
+	record := make([]byte, 0, 6+len(hash)+len(data))
+	record = append(record, op, byte(len(hash)))
+	record = append(record, hash...)
+	record = binary.BigEndian.AppendUint32(record, uint32(len(data)))
+	record = append(record, data...)
+
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	if _, err := file.Write(record); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(kv.path), err)
+	}
+	return kv.refresh()
+}
+
+// refresh brings the map up to date with the file: the records appended
+// since the last scan, or all of them when the file was replaced
+func (kv *kvObjects) refresh() error {
+	info, err := os.Stat(kv.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			kv.reset(nil)
+			return nil
+		}
+		return fmt.Errorf("failed to stat %s: %w", filepath.Base(kv.path), err)
+	}
+
+	if kv.scanned == nil || !os.SameFile(kv.scanned, info) || info.Size() < kv.end {
+		kv.reset(info)
+	} else if info.Size() == kv.end {
+		return nil
+	}
+	kv.scanned = info
+	return kv.scan()
+}
+
+// reset forgets every record
+func (kv *kvObjects) reset(info os.FileInfo) {
+	kv.records = make(map[string]kvRecord)
+	kv.scanned = info
+	kv.end, kv.live, kv.dead = 0, 0, 0
+}
+
+// scan reads the records from the end of the last complete one, stopping at
+// a partial record
+func (kv *kvObjects) scan() error {
+	file, err := os.Open(kv.path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filepath.Base(kv.path), err)
+	}
+	defer file.Close()
+
+	if kv.end == 0 {
+		magic := make([]byte, len(kvMagic))
+		if _, err := io.ReadFull(file, magic); err != nil {
+			// A file cut short while being created holds no records
+			if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read %s: %w", filepath.Base(kv.path), err)
+		}
+		if string(magic) != kvMagic {
+			return fmt.Errorf("%s is not a key-value object file", filepath.Base(kv.path))
+		}
+		kv.end = int64(len(kvMagic))
+	}
+	if _, err := file.Seek(kv.end, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(file)
+	header := make([]byte, 2)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return nil
+		}
+		op, hashLength := header[0], int(header[1])
+		if op != kvPut && op != kvDelete {
+			return fmt.Errorf("%s is corrupt at offset %d", filepath.Base(kv.path), kv.end)
+		}
+
+		rest := make([]byte, hashLength+4)
+		if _, err := io.ReadFull(reader, rest); err != nil {
+			return nil
+		}
+		hash := string(rest[:hashLength])
+		length := int64(binary.BigEndian.Uint32(rest[hashLength:]))
+		if _, err := reader.Discard(int(length)); err != nil {
+			return nil
+		}
+
+		size := int64(2+hashLength+4) + length
+		if previous, ok := kv.records[hash]; ok {
+			kv.live -= previous.size
+			kv.dead += previous.size
+		}
+		if op == kvPut {
+			kv.records[hash] = kvRecord{offset: kv.end + size - length, length: length, size: size}
+			kv.live += size
+		} else {
+			delete(kv.records, hash)
+			kv.dead += size
+		}
+		kv.end += size
+	}
+}
+
+// Compact rewrites the file without replaced and deleted records, whatever
+// their size
+func (kv *kvObjects) Compact() error {
+	kv.mutex.Lock()
+	defer kv.mutex.Unlock()
+
+	if err := kv.refresh(); err != nil {
+		return err
+	}
+	if kv.dead == 0 {
+		return nil
+	}
+	return kv.compact()
+}
+
+// compact rewrites the file with only the live records, replacing it once
+// complete so a crash leaves either file whole
+func (kv *kvObjects) compact() error {
+	source, err := os.Open(kv.path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filepath.Base(kv.path), err)
+	}
+	defer source.Close()
+
+	err = writeAtomically(kv.path, func(w io.Writer) error {
+		if _, err := io.WriteString(w, kvMagic); err != nil {
+			return err
+		}
+		for hash, record := range kv.records {
+			data := make([]byte, record.length)
+			if _, err := source.ReadAt(data, record.offset); err != nil {
+				return err
+			}
+			header := append([]byte{kvPut, byte(len(hash))}, hash...)
+			header = binary.BigEndian.AppendUint32(header, uint32(len(data)))
+			if _, err := w.Write(append(header, data...)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to compact %s: %w", filepath.Base(kv.path), err)
+	}
+	return kv.refresh()
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestKVBackend(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	fs := NewFileSystemStorage(tempDir)
+	if err := fs.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	if err := fs.SetBackend("bolt"); err == nil {
+		t.Errorf("Expected an unknown backend to be rejected")
+	}
+	if err := fs.SetBackend(BackendKV); err != nil {
+		t.Fatalf("Failed to set backend: %v", err)
+	}
+	if backend, err := fs.Backend(); err != nil || backend != BackendKV {
+		t.Errorf("Expected backend %s, got %s (%v)", BackendKV, backend, err)
+	}
+
+	commit := createTestCommit()
+	if err := fs.WriteCommit(commit); err != nil {
+		t.Fatalf("Failed to write commit: %v", err)
+	}
+	other := createTestCommit()
+	other.Hash = "def456abc789"
+	other.Content = "d1 $ s \"bd*2\""
+	if err := fs.WriteCommit(other); err != nil {
+		t.Fatalf("Failed to write commit: %v", err)
+	}
+
+	// Objects live in the key-value file rather than in files of their own
+	if entries, _ := os.ReadDir(filepath.Join(tempDir, RepoDir, ObjectsDir)); len(entries) != 0 {
+		t.Errorf("Expected no loose objects, got %d", len(entries))
+	}
+
+	// A separate storage, as another process would have, sees the objects
+	reopened := NewFileSystemStorage(tempDir)
+	read, err := reopened.ReadCommit(commit.Hash)
+	if err != nil {
+		t.Fatalf("Failed to read commit: %v", err)
+	}
+	if read.Content != commit.Content || read.Metadata.Buffer != commit.Metadata.Buffer {
+		t.Errorf("Expected the stored commit back, got %+v", read)
+	}
+	hashes, err := reopened.ListCommits()
+	if err != nil {
+		t.Fatalf("Failed to list commits: %v", err)
+	}
+	sort.Strings(hashes)
+	if strings.Join(hashes, ",") != commit.Hash+","+other.Hash {
+		t.Errorf("Expected both commits listed, got %v", hashes)
+	}
+
+	// Objects written by one storage show up in the other
+	third := createTestCommit()
+	third.Hash = "0123456789ab"
+	if err := fs.WriteCommit(third); err != nil {
+		t.Fatalf("Failed to write commit: %v", err)
+	}
+	if !reopened.Exists(third.Hash) {
+		t.Errorf("Expected a commit appended by another storage to exist")
+	}
+
+	if err := reopened.DeleteObject(other.Hash); err != nil {
+		t.Fatalf("Failed to delete object: %v", err)
+	}
+	if fs.Exists(other.Hash) || !fs.Exists(commit.Hash) {
+		t.Errorf("Expected only the deleted object to be gone")
+	}
+	if err := fs.DeleteObject(other.Hash); err == nil {
+		t.Errorf("Expected deleting a missing object to fail")
+	}
+}
+
+func TestKVRecoversPartialRecord(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, KVFile)
+	kv := newKVObjects(path)
+	if err := kv.Put("aaaa", []byte("first")); err != nil {
+		t.Fatalf("Failed to put object: %v", err)
+	}
+
+	// A write interrupted halfway leaves a partial record at the end
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	file.Write([]byte{kvPut, 4, 'b', 'b'})
+	file.Close()
+
+	reopened := newKVObjects(path)
+	if hashes, err := reopened.List(); err != nil || len(hashes) != 1 {
+		t.Fatalf("Expected the complete record only, got %v (%v)", hashes, err)
+	}
+	if err := reopened.Put("cccc", []byte("second")); err != nil {
+		t.Fatalf("Failed to put object: %v", err)
+	}
+
+	check := newKVObjects(path)
+	for hash, want := range map[string]string{"aaaa": "first", "cccc": "second"} {
+		data, err := check.Get(hash)
+		if err != nil || string(data) != want {
+			t.Errorf("Expected %s to be %q, got %q (%v)", hash, want, data, err)
+		}
+	}
+}
+
+func TestKVCompaction(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, KVFile)
+	kv := newKVObjects(path)
+	data := make([]byte, 64*1024)
+	for i := 0; i < 40; i++ {
+		if err := kv.Put(strings.Repeat(string(rune('a'+i%26)), 2)+string(rune('0'+i/26)), data); err != nil {
+			t.Fatalf("Failed to put object: %v", err)
+		}
+	}
+	if err := kv.Put("keep", []byte("kept")); err != nil {
+		t.Fatalf("Failed to put object: %v", err)
+	}
+
+	hashes, _ := kv.List()
+	for _, hash := range hashes {
+		if hash != "keep" {
+			if err := kv.Delete(hash); err != nil {
+				t.Fatalf("Failed to delete object: %v", err)
+			}
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if info.Size() > kvCompactBytes {
+		t.Errorf("Expected the file to be compacted, still %d bytes", info.Size())
+	}
+	if got, err := newKVObjects(path).Get("keep"); err != nil || string(got) != "kept" {
+		t.Errorf("Expected the live object to survive compaction, got %q (%v)", got, err)
+	}
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LockFile exists while a process changes the index, HEAD or objects, like
+// git's index.lock. It's created exclusively, so only one process at a time
+// holds it, and records which one does.
+const LockFile = "index.lock"
+
+// DefaultLockTimeout is how long Lock waits for another process to release
+// the lock
+const DefaultLockTimeout = 5 * time.Second
+
+// lockRetryInterval is how often Lock tries again while waiting
+const lockRetryInterval = 20 * time.Millisecond
+
+// ErrLocked is returned when the lock is still held once the timeout is up
+var ErrLocked = errors.New("repository is locked")
+
+// LockInfo describes the process holding the lock
+type LockInfo struct {
+	PID  int       `json:"pid"`
+	Host string    `json:"host,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// Lock takes the repository lock, waiting up to timeout for another process
+// to release it. A lock left by a process that died is only removed by
+// ForceUnlock, as there's no telling a dead holder from a slow one.
+func (fs *FileSystemStorage) Lock(timeout time.Duration) error {
+	host, _ := os.Hostname()
+	data, err := json.Marshal(LockInfo{PID: os.Getpid(), Host: host, Time: time.Now().UTC()})
+	if err != nil {
+		return fmt.Errorf("failed to marshal lock: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		file, err := os.OpenFile(fs.lockPath(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = file.Write(data)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(fs.lockPath())
+				return fmt.Errorf("failed to write lock: %w", err)
+			}
+			return nil
+		}
+		if !os.IsExist(err) {
+			return fmt.Errorf("failed to create lock: %w", err)
+		}
+
+		if time.Now().After(deadline) {
+			holder, _ := fs.ReadLock()
+			if holder == nil {
+				return fmt.Errorf("%w; if no other lcg process is running, run 'lcg unlock --force'", ErrLocked)
+			}
+			return fmt.Errorf("%w by process %d on %s since %s; if it's no longer running, run 'lcg unlock --force'",
+				ErrLocked, holder.PID, holder.Host, holder.Time.Local().Format("15:04:05"))
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// Unlock releases a lock taken with Lock
+func (fs *FileSystemStorage) Unlock() error {
+	if err := os.Remove(fs.lockPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove lock: %w", err)
+	}
+	return nil
+}
+
+// ReadLock returns the process holding the lock, or nil when it's free
+func (fs *FileSystemStorage) ReadLock() (*LockInfo, error) {
+	data, err := os.ReadFile(fs.lockPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read lock: %w", err)
+	}
+
+	// A holder cut short while writing it still holds the lock
+	var info LockInfo
+	json.Unmarshal(data, &info)
+	return &info, nil
+}
+
+// ForceUnlock removes the lock whoever holds it, returning the holder or
+// nil when it was free. Only for locks left behind by a process that died:
+// the holder, if still running, goes on as if it held it.
+func (fs *FileSystemStorage) ForceUnlock() (*LockInfo, error) {
+	info, err := fs.ReadLock()
+	if err != nil || info == nil {
+		return nil, err
+	}
+	if err := fs.Unlock(); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// lockPath returns the path of the lock file
+func (fs *FileSystemStorage) lockPath() string {
+	return filepath.Join(fs.repoPath, RepoDir, LockFile)
+}
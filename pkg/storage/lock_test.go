@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	fs := NewFileSystemStorage(tempDir)
+	if err := fs.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	other := NewFileSystemStorage(tempDir)
+
+	if err := fs.Lock(time.Second); err != nil {
+		t.Fatalf("Failed to lock: %v", err)
+	}
+	holder, err := other.ReadLock()
+	if err != nil || holder == nil || holder.PID != os.Getpid() {
+		t.Fatalf("Expected the lock to name this process, got %+v (%v)", holder, err)
+	}
+
+	start := time.Now()
+	if err := other.Lock(50 * time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Expected a held lock to time out, got %v", err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Errorf("Expected Lock to wait for the timeout")
+	}
+
+	// Waiting ends as soon as the holder lets go
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		fs.Unlock()
+	}()
+	if err := other.Lock(time.Second); err != nil {
+		t.Fatalf("Expected the lock once released, got %v", err)
+	}
+
+	// A lock left behind is only removed by force
+	holder, err = fs.ForceUnlock()
+	if err != nil || holder == nil {
+		t.Fatalf("Failed to force the lock: %+v (%v)", holder, err)
+	}
+	if holder, err := fs.ReadLock(); err != nil || holder != nil {
+		t.Errorf("Expected the lock to be free, got %+v (%v)", holder, err)
+	}
+	if holder, err := fs.ForceUnlock(); err != nil || holder != nil {
+		t.Errorf("Expected nothing to force on a free lock, got %+v (%v)", holder, err)
+	}
+}
+
+func TestIndexRefresh(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	fs := NewFileSystemStorage(tempDir)
+	if err := fs.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	mine, theirs := NewIndex(fs), NewIndex(NewFileSystemStorage(tempDir))
+	for _, idx := range []*Index{mine, theirs} {
+		if err := idx.LoadIndex(); err != nil {
+			t.Fatalf("Failed to load index: %v", err)
+		}
+	}
+
+	commit := createTestCommit()
+	if err := theirs.AddCommit(commit); err != nil {
+		t.Fatalf("Failed to add commit: %v", err)
+	}
+
+	if refreshed, err := mine.Refresh(); err != nil || !refreshed {
+		t.Fatalf("Failed to refresh index: %v", err)
+	}
+	if mine.GetHead() != commit.Hash {
+		t.Errorf("Expected the other process's commit after a refresh, got %q", mine.GetHead())
+	}
+
+	// Unchanged on disk, the index in memory stays as it is
+	mine.Entries = nil
+	if refreshed, err := mine.Refresh(); err != nil || refreshed || mine.Entries != nil {
+		t.Errorf("Expected no reload of an unchanged index, got %d entries (%v)", len(mine.Entries), err)
+	}
+}
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FormatFile records how a repository hashes and stores its objects.
+// Repositories without one hash with SHA-1 and keep loose object files.
+const FormatFile = "format"
+
+// Object storage backends, chosen when a repository is initialized
+const (
+	BackendFiles = "files" // one file per object under objects/, until repacked, see pack.go
+	BackendKV    = "kv"    // every object in a single key-value file, see kv.go
+	BackendS3    = "s3"    // like files, mirrored to an S3 bucket as written, see s3.go
+)
+
+// Format versions, saying how commits are hashed
+const (
+	FormatSHA1   = 1 // SHA-1 of content, message and time
+	FormatSHA256 = 2 // SHA-256 of the canonical serialization of the commit
+
+	// CurrentFormatVersion is the version of new repositories
+	CurrentFormatVersion = FormatSHA256
+)
+
+// Format is the contents of the format file
+type Format struct {
+	Version     int    `json:"version,omitempty"` // FormatSHA1 when missing
+	Backend     string `json:"backend,omitempty"`
+	Compression string `json:"compression,omitempty"` // of new objects, see compress.go
+	Blobs       bool   `json:"blobs,omitempty"`       // store new content as blobs, see blob.go
+
+	S3         *S3Config         `json:"s3,omitempty"`         // where the s3 backend mirrors objects
+	Encryption *EncryptionConfig `json:"encryption,omitempty"` // of objects, see encrypt.go
+}
+
+// version returns the format version, defaulting to the first one
+func (f Format) version() int {
+	if f.Version == 0 {
+		return FormatSHA1
+	}
+	return f.Version
+}
+
+// ObjectStore keeps the encoded objects of a repository by hash
+type ObjectStore interface {
+	Put(hash string, data []byte) error
+	Get(hash string) ([]byte, error)
+	Has(hash string) bool
+	Size(hash string) (int64, error)
+	Delete(hash string) error
+	List() ([]string, error)
+}
+
+// ValidBackend reports whether backend names an object storage backend; an
+// empty name is the default
+func ValidBackend(backend string) bool {
+	return backend == "" || backend == BackendFiles || backend == BackendKV || backend == BackendS3
+}
+
+// ReadFormat reads the format file of the repository at repoPath, returning
+// the zero format when there is none
+func ReadFormat(repoPath string) (Format, error) {
+	var format Format
+	data, err := os.ReadFile(filepath.Join(repoPath, RepoDir, FormatFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return format, nil
+		}
+		return format, fmt.Errorf("failed to read format: %w", err)
+	}
+	if err := json.Unmarshal(data, &format); err != nil {
+		return format, fmt.Errorf("failed to parse format: %w", err)
+	}
+	return format, nil
+}
+
+// WriteFormat replaces the format file of the repository at repoPath
+func WriteFormat(repoPath string, format Format) error {
+	data, err := json.MarshalIndent(format, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal format: %w", err)
+	}
+	if err := writeFileAtomically(filepath.Join(repoPath, RepoDir, FormatFile), data); err != nil {
+		return fmt.Errorf("failed to write format: %w", err)
+	}
+	return nil
+}
+
+// openObjectStore opens the object store a format names, for commits when
+// name is ObjectsDir and for blobs when it's BlobsDir
+func openObjectStore(repoPath string, format Format, name string) (ObjectStore, error) {
+	switch format.Backend {
+	case "", BackendFiles, BackendS3:
+		packs := filepath.Join(repoPath, RepoDir, PacksDir)
+		if name != ObjectsDir {
+			packs = filepath.Join(packs, name)
+		}
+		return &packedObjects{
+			loose: &looseObjects{dir: filepath.Join(repoPath, RepoDir, name)},
+			dir:   packs,
+		}, nil
+	case BackendKV:
+		return newKVObjects(filepath.Join(repoPath, RepoDir, name+".kv")), nil
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", format.Backend)
+}
+
+// looseObjects stores each object in its own file, in a subdirectory named by
+// the first two characters of its hash
+type looseObjects struct {
+	dir string
+}
+
+func (lo *looseObjects) Put(hash string, data []byte) error {
+	objDir := filepath.Join(lo.dir, hash[:2])
+	if err := os.MkdirAll(objDir, 0755); err != nil {
+		return fmt.Errorf("failed to create object subdirectory: %w", err)
+	}
+	return writeFileAtomically(filepath.Join(objDir, hash[2:]), data)
+}
+
+func (lo *looseObjects) Get(hash string) ([]byte, error) {
+	return os.ReadFile(lo.path(hash))
+}
+
+func (lo *looseObjects) Has(hash string) bool {
+	_, err := os.Stat(lo.path(hash))
+	return err == nil
+}
+
+func (lo *looseObjects) Size(hash string) (int64, error) {
+	info, err := os.Stat(lo.path(hash))
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (lo *looseObjects) Delete(hash string) error {
+	objPath := lo.path(hash)
+	if err := os.Remove(objPath); err != nil {
+		return err
+	}
+
+	// Fails harmlessly while other objects share the subdirectory
+	os.Remove(filepath.Dir(objPath))
+	return nil
+}
+
+func (lo *looseObjects) List() ([]string, error) {
+	var hashes []string
+	err := filepath.WalkDir(lo.dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() && !isTempFile(d.Name()) {
+			// Reconstruct hash from directory structure
+			rel, err := filepath.Rel(lo.dir, path)
+			if err != nil {
+				return err
+			}
+
+			parts := strings.Split(rel, string(filepath.Separator))
+			if len(parts) == 2 {
+				hashes = append(hashes, parts[0]+parts[1])
+			}
+		}
+
+		return nil
+	})
+	if os.IsNotExist(err) {
+		// Nothing has been stored yet, e.g. blobs in an older repository
+		return nil, nil
+	}
+	return hashes, err
+}
+
+// path constructs the file path for an object
+func (lo *looseObjects) path(hash string) string {
+	return filepath.Join(lo.dir, hash[:2], hash[2:])
+}
@@ -0,0 +1,377 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PacksDir holds the packfiles of a repository using the files backend.
+// A packfile is the encoded objects one after the other; its .idx file
+// maps each hash to where its object is, and is written last so a pack
+// without one is ignored.
+const PacksDir = "packs"
+
+// packMagic starts every packfile
+const packMagic = "LCGPACK1\n"
+
+// RepackResult reports what a repack consolidated
+type RepackResult struct {
+	Objects int    // objects in the new packfile
+	Loose   int    // loose objects moved into it
+	Packs   int    // packfiles merged into it
+	Pack    string // name of the new packfile, empty when nothing was repacked
+}
+
+// packEntry locates an object in a packfile
+type packEntry struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+}
+
+// pack is a packfile with its index
+type pack struct {
+	path    string // without extension
+	objects map[string]packEntry
+}
+
+// packedObjects reads objects from packfiles first and from loose object
+// files after, and writes new objects as loose files until the next repack.
+// Packs written or removed by another process are picked up when an object
+// can't be found.
+type packedObjects struct {
+	loose *looseObjects
+	dir   string
+
+	mutex  sync.Mutex
+	packs  []*pack
+	loaded bool
+}
+
+func (po *packedObjects) Put(hash string, data []byte) error {
+	return po.loose.Put(hash, data)
+}
+
+func (po *packedObjects) Get(hash string) ([]byte, error) {
+	po.mutex.Lock()
+	defer po.mutex.Unlock()
+
+	if data, ok, err := po.readPacked(hash); ok || err != nil {
+		return data, err
+	}
+	data, err := po.loose.Get(hash)
+	if err == nil || !os.IsNotExist(err) {
+		return data, err
+	}
+
+	// Repacked by another process since the packs were loaded
+	if err := po.load(); err != nil {
+		return nil, err
+	}
+	if data, ok, err := po.readPacked(hash); ok || err != nil {
+		return data, err
+	}
+	return nil, err
+}
+
+func (po *packedObjects) Has(hash string) bool {
+	po.mutex.Lock()
+	defer po.mutex.Unlock()
+
+	if po.ensureLoaded() != nil {
+		return false
+	}
+	if po.find(hash) != nil || po.loose.Has(hash) {
+		return true
+	}
+	return po.load() == nil && po.find(hash) != nil
+}
+
+func (po *packedObjects) Size(hash string) (int64, error) {
+	po.mutex.Lock()
+	defer po.mutex.Unlock()
+
+	if err := po.ensureLoaded(); err != nil {
+		return 0, err
+	}
+	if p := po.find(hash); p != nil {
+		return p.objects[hash].Length, nil
+	}
+	return po.loose.Size(hash)
+}
+
+// Delete removes a loose object, and drops a packed one from its pack's
+// index; its bytes stay in the packfile until the next repack
+func (po *packedObjects) Delete(hash string) error {
+	po.mutex.Lock()
+	defer po.mutex.Unlock()
+
+	if err := po.ensureLoaded(); err != nil {
+		return err
+	}
+
+	deleted := false
+	if po.loose.Has(hash) {
+		if err := po.loose.Delete(hash); err != nil {
+			return err
+		}
+		deleted = true
+	}
+	for _, p := range po.packs {
+		if _, ok := p.objects[hash]; !ok {
+			continue
+		}
+		delete(p.objects, hash)
+		if err := writePackIndex(p); err != nil {
+			return err
+		}
+		deleted = true
+	}
+
+	if !deleted {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+func (po *packedObjects) List() ([]string, error) {
+	po.mutex.Lock()
+	defer po.mutex.Unlock()
+
+	if err := po.load(); err != nil {
+		return nil, err
+	}
+	hashes, err := po.loose.List()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		seen[hash] = true
+	}
+	for _, p := range po.packs {
+		for hash := range p.objects {
+			if !seen[hash] {
+				seen[hash] = true
+				hashes = append(hashes, hash)
+			}
+		}
+	}
+	return hashes, nil
+}
+
+// Repack writes every object, packed or loose, into a single new packfile,
+// then removes the packs and loose files it replaces
+func (po *packedObjects) Repack() (*RepackResult, error) {
+	po.mutex.Lock()
+	defer po.mutex.Unlock()
+
+	if err := po.load(); err != nil {
+		return nil, err
+	}
+	loose, err := po.loose.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list loose objects: %w", err)
+	}
+
+	result := &RepackResult{Loose: len(loose), Packs: len(po.packs)}
+	if len(loose) == 0 && len(po.packs) <= 1 {
+		result.Loose, result.Packs = 0, 0
+		return result, nil
+	}
+
+	// Loose objects win over packed ones, as they were written since
+	sources := make(map[string]*pack)
+	for _, p := range po.packs {
+		for hash := range p.objects {
+			sources[hash] = p
+		}
+	}
+	for _, hash := range loose {
+		sources[hash] = nil
+	}
+	hashes := make([]string, 0, len(sources))
+	for hash := range sources {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	if err := os.MkdirAll(po.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create packs directory: %w", err)
+	}
+	packed := &pack{
+		path:    filepath.Join(po.dir, "pack-"+GenerateHash(strings.Join(hashes, "\n"))),
+		objects: make(map[string]packEntry, len(hashes)),
+	}
+
+	err = writeAtomically(packed.path+".pack", func(w io.Writer) error {
+		offset := int64(len(packMagic))
+		if _, err := io.WriteString(w, packMagic); err != nil {
+			return err
+		}
+		for _, hash := range hashes {
+			var data []byte
+			var err error
+			if source := sources[hash]; source != nil {
+				data, err = readPackEntry(source, hash)
+			} else {
+				data, err = po.loose.Get(hash)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read object %s: %w", hash, err)
+			}
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+			packed.objects[hash] = packEntry{Offset: offset, Length: int64(len(data))}
+			offset += int64(len(data))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write packfile: %w", err)
+	}
+	if err := writePackIndex(packed); err != nil {
+		return nil, err
+	}
+
+	// The new pack holds everything now; a pack of the same objects is the
+	// one just written
+	for _, p := range po.packs {
+		if p.path != packed.path {
+			os.Remove(p.path + ".idx")
+			os.Remove(p.path + ".pack")
+		}
+	}
+	for _, hash := range loose {
+		if err := po.loose.Delete(hash); err != nil {
+			return nil, fmt.Errorf("failed to delete loose object %s: %w", hash, err)
+		}
+	}
+
+	result.Objects = len(hashes)
+	result.Pack = filepath.Base(packed.path)
+	return result, po.load()
+}
+
+// hasPacks reports whether any objects are packed
+func (po *packedObjects) hasPacks() bool {
+	po.mutex.Lock()
+	defer po.mutex.Unlock()
+
+	return po.ensureLoaded() == nil && len(po.packs) > 0
+}
+
+// readPacked reads an object from the packs, reloading them once when a
+// pack has gone, e.g. replaced by a repack in another process
+func (po *packedObjects) readPacked(hash string) ([]byte, bool, error) {
+	if err := po.ensureLoaded(); err != nil {
+		return nil, false, err
+	}
+	p := po.find(hash)
+	if p == nil {
+		return nil, false, nil
+	}
+
+	data, err := readPackEntry(p, hash)
+	if err != nil && os.IsNotExist(err) {
+		if err := po.load(); err != nil {
+			return nil, false, err
+		}
+		if p = po.find(hash); p == nil {
+			return nil, false, nil
+		}
+		data, err = readPackEntry(p, hash)
+	}
+	return data, true, err
+}
+
+// find returns the pack holding hash, or nil
+func (po *packedObjects) find(hash string) *pack {
+	for _, p := range po.packs {
+		if _, ok := p.objects[hash]; ok {
+			return p
+		}
+	}
+	return nil
+}
+
+// ensureLoaded loads the pack indexes unless they already are
+func (po *packedObjects) ensureLoaded() error {
+	if po.loaded {
+		return nil
+	}
+	return po.load()
+}
+
+// load reads the index of every complete pack
+func (po *packedObjects) load() error {
+	po.packs = nil
+	po.loaded = true
+
+	files, err := os.ReadDir(po.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read packs directory: %w", err)
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || filepath.Ext(name) != ".idx" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(po.dir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("failed to read pack index %s: %w", name, err)
+		}
+		p := &pack{path: filepath.Join(po.dir, strings.TrimSuffix(name, ".idx"))}
+		if err := json.Unmarshal(data, &p.objects); err != nil {
+			return fmt.Errorf("failed to parse pack index %s: %w", name, err)
+		}
+		po.packs = append(po.packs, p)
+	}
+	return nil
+}
+
+// readPackEntry reads an object from a packfile
+func readPackEntry(p *pack, hash string) ([]byte, error) {
+	file, err := os.Open(p.path + ".pack")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entry := p.objects[hash]
+	data := make([]byte, entry.Length)
+	if _, err := file.ReadAt(data, entry.Offset); err != nil {
+		return nil, fmt.Errorf("failed to read %s from %s: %w", hash, filepath.Base(p.path), err)
+	}
+	return data, nil
+}
+
+// writePackIndex replaces the index of a pack
+func writePackIndex(p *pack) error {
+	data, err := json.Marshal(p.objects)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pack index: %w", err)
+	}
+	err = writeAtomically(p.path+".idx", func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write pack index: %w", err)
+	}
+	return nil
+}
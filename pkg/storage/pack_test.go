@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRepack(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	fs := NewFileSystemStorage(tempDir)
+	if err := fs.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	if result, err := fs.Repack(); err != nil || result.Pack != "" {
+		t.Errorf("Expected nothing to repack in an empty repository, got %+v (%v)", result, err)
+	}
+
+	var hashes []string
+	for _, hash := range []string{"aa11", "bb22", "cc33"} {
+		commit := createTestCommit()
+		commit.Hash = hash
+		commit.Content = "content of " + hash
+		if err := fs.WriteCommit(commit); err != nil {
+			t.Fatalf("Failed to write commit: %v", err)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	// Another process reading the repository keeps its packs loaded
+	reader := NewFileSystemStorage(tempDir)
+	if !reader.Exists("aa11") {
+		t.Fatalf("Expected a loose object to exist")
+	}
+
+	result, err := fs.Repack()
+	if err != nil {
+		t.Fatalf("Failed to repack: %v", err)
+	}
+	if result.Objects != 3 || result.Loose != 3 || result.Packs != 0 || result.Pack == "" {
+		t.Errorf("Expected 3 loose objects packed, got %+v", result)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(tempDir, RepoDir, ObjectsDir)); len(entries) != 0 {
+		t.Errorf("Expected no loose objects left, got %d", len(entries))
+	}
+
+	for _, hash := range hashes {
+		commit, err := reader.ReadCommit(hash)
+		if err != nil || commit.Content != "content of "+hash {
+			t.Errorf("Expected %s to be read from the pack, got %+v (%v)", hash, commit, err)
+		}
+	}
+
+	// New objects are loose until the next repack, which merges the pack
+	commit := createTestCommit()
+	commit.Hash = "dd44"
+	if err := fs.WriteCommit(commit); err != nil {
+		t.Fatalf("Failed to write commit: %v", err)
+	}
+	if err := fs.DeleteObject("bb22"); err != nil {
+		t.Fatalf("Failed to delete packed object: %v", err)
+	}
+	if fs.Exists("bb22") {
+		t.Errorf("Expected the deleted object to be gone from the pack")
+	}
+
+	listed, err := fs.ListCommits()
+	if err != nil {
+		t.Fatalf("Failed to list commits: %v", err)
+	}
+	sort.Strings(listed)
+	if strings.Join(listed, ",") != "aa11,cc33,dd44" {
+		t.Errorf("Expected packed and loose objects listed, got %v", listed)
+	}
+
+	result, err = fs.Repack()
+	if err != nil {
+		t.Fatalf("Failed to repack: %v", err)
+	}
+	if result.Objects != 3 || result.Loose != 1 || result.Packs != 1 {
+		t.Errorf("Expected the pack and a loose object merged, got %+v", result)
+	}
+	packs, _ := filepath.Glob(filepath.Join(tempDir, RepoDir, PacksDir, "*.pack"))
+	if len(packs) != 1 {
+		t.Errorf("Expected a single packfile, got %v", packs)
+	}
+	if _, err := reader.ReadCommit("dd44"); err != nil {
+		t.Errorf("Expected a reader with the old pack loaded to find the new one: %v", err)
+	}
+
+	fs.SetBackend(BackendKV)
+	if _, err := fs.Repack(); err == nil {
+		t.Errorf("Expected repacking the kv backend to fail")
+	}
+}
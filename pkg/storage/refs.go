@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RefStore keeps the references into the history: HEAD and the tags
+type RefStore interface {
+	ReadHead() (string, error)
+	WriteHead(commitHash string) error
+	ReadTags() (map[string]string, error)
+	WriteTag(name, commitHash string) error
+}
+
+// Locker is implemented by storage other processes may change at the same
+// time, see lock.go
+type Locker interface {
+	Lock(timeout time.Duration) error
+	Unlock() error
+}
+
+// ReadRepoFile reads a file of the repository directory, e.g. IndexFile
+func (fs *FileSystemStorage) ReadRepoFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(fs.repoPath, RepoDir, name))
+}
+
+// ReadRepoFileAt reads len(p) bytes of a file of the repository directory
+// from offset off, like io.ReaderAt
+func (fs *FileSystemStorage) ReadRepoFileAt(name string, p []byte, off int64) (int, error) {
+	file, err := os.Open(filepath.Join(fs.repoPath, RepoDir, name))
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return file.ReadAt(p, off)
+}
+
+// AppendRepoFile adds data to the end of a file of the repository directory,
+// creating it if needed. The data is synced before it returns; a crash while
+// appending can leave it cut short, which readers of the file allow for.
+func (fs *FileSystemStorage) AppendRepoFile(name string, data []byte) error {
+	file, err := os.OpenFile(filepath.Join(fs.repoPath, RepoDir, name), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WriteRepoFile replaces a file of the repository directory atomically
+func (fs *FileSystemStorage) WriteRepoFile(name string, data []byte) error {
+	return writeFileAtomically(filepath.Join(fs.repoPath, RepoDir, name), data)
+}
+
+// StatRepoFile describes a file of the repository directory, telling when
+// another process replaced it
+func (fs *FileSystemStorage) StatRepoFile(name string) (os.FileInfo, error) {
+	return os.Stat(filepath.Join(fs.repoPath, RepoDir, name))
+}
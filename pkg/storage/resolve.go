@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MinHashPrefix is the shortest abbreviated hash ResolveHash accepts, as
+// with git
+const MinHashPrefix = 4
+
+// CommitSet is what abbreviated hashes are resolved against
+type CommitSet interface {
+	ListCommits() ([]string, error)
+	Exists(hash string) bool
+}
+
+// AmbiguousHashError reports an abbreviated hash matching several commits
+type AmbiguousHashError struct {
+	Prefix  string
+	Matches []string // sorted
+}
+
+func (e *AmbiguousHashError) Error() string {
+	shown := make([]string, 0, 5)
+	for _, hash := range e.Matches {
+		if len(shown) == cap(shown) {
+			shown = append(shown, "...")
+			break
+		}
+		if len(hash) > len(e.Prefix)+4 {
+			hash = hash[:len(e.Prefix)+4]
+		}
+		shown = append(shown, hash)
+	}
+	return fmt.Sprintf("short hash %s is ambiguous, it matches %d commits: %s",
+		e.Prefix, len(e.Matches), strings.Join(shown, ", "))
+}
+
+// ResolveHash returns the full hash of the one commit starting with prefix,
+// like git's abbreviated hashes; a full hash is returned when the commit
+// exists. Prefixes are hex, case-insensitive and at least MinHashPrefix
+// long, so a reference never reaches a path outside the object store. A
+// prefix matching several commits returns an *AmbiguousHashError.
+func ResolveHash(commits CommitSet, prefix string) (string, error) {
+	prefix = strings.ToLower(prefix)
+	if len(prefix) < MinHashPrefix || strings.Trim(prefix, "0123456789abcdef") != "" {
+		return "", fmt.Errorf("no commit matches %s", prefix)
+	}
+	if (len(prefix) == 40 || len(prefix) == 64) && commits.Exists(prefix) {
+		return prefix, nil
+	}
+
+	hashes, err := commits.ListCommits()
+	if err != nil {
+		return "", fmt.Errorf("failed to list commits: %w", err)
+	}
+	var matches []string
+	for _, hash := range hashes {
+		if strings.HasPrefix(hash, prefix) {
+			matches = append(matches, hash)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no commit matches %s", prefix)
+	case 1:
+		return matches[0], nil
+	default:
+		sort.Strings(matches)
+		return "", &AmbiguousHashError{Prefix: prefix, Matches: matches}
+	}
+}
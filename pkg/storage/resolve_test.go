@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type hashList []string
+
+func (h hashList) ListCommits() ([]string, error) { return h, nil }
+
+func (h hashList) Exists(hash string) bool {
+	for _, existing := range h {
+		if existing == hash {
+			return true
+		}
+	}
+	return false
+}
+
+func TestResolveHash(t *testing.T) {
+	commits := hashList{
+		"a1b2c3d4e5f60718293a4b5c6d7e8f9012345678",
+		"a1b2ffffe5f60718293a4b5c6d7e8f9012345678",
+		"0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f",
+	}
+
+	tests := []struct {
+		ref      string
+		expected string
+	}{
+		{commits[0], commits[0]},
+		{"a1b2c3", commits[0]},
+		{"A1B2FF", commits[1]},
+		{"0f0f", commits[2]},
+	}
+	for _, tt := range tests {
+		hash, err := ResolveHash(commits, tt.ref)
+		if err != nil {
+			t.Fatalf("Failed to resolve %s: %v", tt.ref, err)
+		}
+		if hash != tt.expected {
+			t.Errorf("Expected %s for %s, got %s", tt.expected, tt.ref, hash)
+		}
+	}
+
+	for _, ref := range []string{"abc", "ffff", "../HEAD", "a1b2c3d4e5f60718293a4b5c6d7e8f9012345679"} {
+		if _, err := ResolveHash(commits, ref); err == nil {
+			t.Errorf("Expected no match for %s", ref)
+		}
+	}
+
+	_, err := ResolveHash(commits, "a1b2")
+	var ambiguous *AmbiguousHashError
+	if !errors.As(err, &ambiguous) {
+		t.Fatalf("Expected an ambiguous hash error, got %v", err)
+	}
+	if len(ambiguous.Matches) != 2 {
+		t.Errorf("Expected 2 candidates, got %v", ambiguous.Matches)
+	}
+	if !strings.Contains(err.Error(), "a1b2c3d4") || !strings.Contains(err.Error(), "a1b2ffff") {
+		t.Errorf("Expected the candidates in the error, got %q", err)
+	}
+}
@@ -0,0 +1,579 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// S3PendingFile lists the keys a repository using the s3 backend couldn't
+// upload yet, one per line, relative to the configured prefix
+const S3PendingFile = "s3-pending"
+
+// s3Timeout bounds each request, so a commit on a stage without network
+// isn't held up for long
+const s3Timeout = 5 * time.Second
+
+// s3RetryDelay is how long the bucket is left alone after a request failed
+// to reach it; meanwhile uploads are only added to the pending ones, so
+// commits made while the network is down don't each wait for the timeout
+const s3RetryDelay = 30 * time.Second
+
+// S3Config says where a repository using the s3 backend mirrors its
+// objects. Credentials aren't kept here but read from the standard
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN variables.
+type S3Config struct {
+	Bucket   string `json:"bucket"`
+	Prefix   string `json:"prefix,omitempty"`   // keys start with it, e.g. "sets/friday"
+	Region   string `json:"region,omitempty"`   // us-east-1 when empty
+	Endpoint string `json:"endpoint,omitempty"` // of an S3-compatible service; AWS when empty
+}
+
+// s3Credentials sign requests
+type s3Credentials struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// s3Remote uploads to and downloads from the configured bucket. Uploads
+// are listed in S3PendingFile and sent in the background, in order, so
+// neither a slow nor a dropped connection holds up a commit; what fails is
+// tried again once the bucket is no longer left alone.
+type s3Remote struct {
+	config      S3Config
+	credentials s3Credentials
+	client      *http.Client
+	pendingPath string
+	read        func(key string) ([]byte, error) // the local copy of what's uploaded under key
+
+	mutex     sync.Mutex      // of the pending file and what follows
+	uploading map[string]bool // keys being sent
+	requeued  map[string]bool // keys written again while being sent
+
+	wake  chan struct{}
+	start sync.Once
+
+	retryMutex sync.Mutex
+	retryAfter time.Time // requests fail without being sent until then
+	retrying   bool      // pending uploads are to be sent again
+}
+
+// uploads counts the background uploads of the process asked for and not
+// done yet, see WaitForUploads
+var uploads sync.WaitGroup
+
+// newS3Remote returns the remote of the repository at repoPath, reading
+// what it uploads with read. Uploads left pending by an earlier process
+// start in the background.
+func newS3Remote(repoPath string, config *S3Config, read func(key string) ([]byte, error)) (*s3Remote, error) {
+	if config == nil || config.Bucket == "" {
+		return nil, fmt.Errorf("the %s backend needs a bucket", BackendS3)
+	}
+	remote := &s3Remote{
+		config: *config,
+		credentials: s3Credentials{
+			AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		},
+		client:      &http.Client{Timeout: s3Timeout},
+		pendingPath: filepath.Join(repoPath, RepoDir, S3PendingFile),
+		read:        read,
+		uploading:   make(map[string]bool),
+		requeued:    make(map[string]bool),
+		wake:        make(chan struct{}, 1),
+	}
+	if remote.config.Region == "" {
+		remote.config.Region = "us-east-1"
+	}
+	if remote.config.Endpoint == "" {
+		remote.config.Endpoint = "https://s3." + remote.config.Region + ".amazonaws.com"
+	}
+	if pending, err := remote.Pending(); err == nil && len(pending) > 0 {
+		remote.kick()
+	}
+	return remote, nil
+}
+
+// WaitForUploads waits up to timeout for the uploads of the s3 backend to
+// be sent, e.g. before a command exits; those that aren't stay pending for
+// the next process
+func WaitForUploads(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		uploads.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+// upload lists key as pending and has it sent in the background; only
+// failing to list it is returned
+func (r *s3Remote) upload(key string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.uploading[key] {
+		r.requeued[key] = true
+	}
+	pending, err := r.readPending()
+	if err != nil {
+		return err
+	}
+	for _, pendingKey := range pending {
+		if pendingKey == key {
+			r.kick()
+			return nil
+		}
+	}
+	if err := r.writePending(append(pending, key)); err != nil {
+		return err
+	}
+	r.kick()
+	return nil
+}
+
+// kick has the background uploader go through the pending uploads
+func (r *s3Remote) kick() {
+	r.start.Do(func() { go r.uploader() })
+	uploads.Add(1)
+	select {
+	case r.wake <- struct{}{}:
+	default:
+		// Already asked to, and it will see this upload
+		uploads.Done()
+	}
+}
+
+// uploader sends pending uploads whenever kicked, for the life of the
+// process
+func (r *s3Remote) uploader() {
+	for range r.wake {
+		if !r.sendPending() {
+			r.retryLater()
+		}
+		uploads.Done()
+	}
+}
+
+// retryLater kicks the uploader again once the bucket is no longer left
+// alone
+func (r *s3Remote) retryLater() {
+	r.retryMutex.Lock()
+	defer r.retryMutex.Unlock()
+	if r.retrying {
+		return
+	}
+	r.retrying = true
+	time.AfterFunc(s3RetryDelay, func() {
+		r.retryMutex.Lock()
+		r.retrying = false
+		r.retryMutex.Unlock()
+		r.kick()
+	})
+}
+
+// sendPending uploads the pending keys in order until one fails, and
+// reports whether they all went
+func (r *s3Remote) sendPending() bool {
+	r.mutex.Lock()
+	pending, err := r.readPending()
+	for _, key := range pending {
+		r.uploading[key] = true
+	}
+	r.mutex.Unlock()
+	if err != nil {
+		return false
+	}
+
+	sent := make(map[string]bool, len(pending))
+	for _, key := range pending {
+		data, err := r.read(key)
+		if err == nil {
+			err = r.put(key, data)
+		} else if os.IsNotExist(err) {
+			// Deleted since, e.g. by gc
+			err = nil
+		}
+		if err != nil {
+			break
+		}
+		sent[key] = true
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for key := range r.requeued {
+		delete(sent, key)
+	}
+	r.uploading, r.requeued = make(map[string]bool), make(map[string]bool)
+	if len(sent) == 0 {
+		return len(pending) == 0
+	}
+	current, err := r.readPending()
+	if err != nil {
+		return false
+	}
+	var left []string
+	for _, key := range current {
+		if !sent[key] {
+			left = append(left, key)
+		}
+	}
+	if err := r.writePending(left); err != nil {
+		return false
+	}
+	return len(sent) == len(pending)
+}
+
+// waiting reports whether the bucket is left alone after a failure to reach
+// it
+func (r *s3Remote) waiting() bool {
+	r.retryMutex.Lock()
+	defer r.retryMutex.Unlock()
+	return time.Now().Before(r.retryAfter)
+}
+
+// backOff leaves the bucket alone for s3RetryDelay
+func (r *s3Remote) backOff() {
+	r.retryMutex.Lock()
+	defer r.retryMutex.Unlock()
+	r.retryAfter = time.Now().Add(s3RetryDelay)
+}
+
+// Pending returns the keys waiting to be uploaded
+func (r *s3Remote) Pending() ([]string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.readPending()
+}
+
+func (r *s3Remote) readPending() ([]string, error) {
+	file, err := os.Open(r.pendingPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", S3PendingFile, err)
+	}
+	defer file.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if key := strings.TrimSpace(scanner.Text()); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys, scanner.Err()
+}
+
+func (r *s3Remote) writePending(keys []string) error {
+	if len(keys) == 0 {
+		if err := os.Remove(r.pendingPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to write %s: %w", S3PendingFile, err)
+		}
+		return nil
+	}
+	if err := writeFileAtomically(r.pendingPath, []byte(strings.Join(keys, "\n")+"\n")); err != nil {
+		return fmt.Errorf("failed to write %s: %w", S3PendingFile, err)
+	}
+	return nil
+}
+
+// put uploads an object
+func (r *s3Remote) put(key string, data []byte) error {
+	_, err := r.do(http.MethodPut, key, nil, data)
+	return err
+}
+
+// get downloads an object, returning an error satisfying os.IsNotExist when
+// there's none
+func (r *s3Remote) get(key string) ([]byte, error) {
+	return r.do(http.MethodGet, key, nil, nil)
+}
+
+// has reports whether an object exists
+func (r *s3Remote) has(key string) bool {
+	_, err := r.do(http.MethodHead, key, nil, nil)
+	return err == nil
+}
+
+// delete removes an object; removing one that doesn't exist succeeds
+func (r *s3Remote) delete(key string) error {
+	_, err := r.do(http.MethodDelete, key, nil, nil)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// list returns the keys starting with prefix, relative to the configured
+// prefix
+func (r *s3Remote) list(prefix string) ([]string, error) {
+	var result struct {
+		Contents []struct {
+			Key string
+		}
+		IsTruncated           bool
+		NextContinuationToken string
+	}
+
+	var keys []string
+	query := url.Values{"list-type": {"2"}, "prefix": {r.key(prefix)}}
+	for {
+		body, err := r.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		result.Contents, result.IsTruncated = nil, false
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse bucket listing: %w", err)
+		}
+		for _, object := range result.Contents {
+			keys = append(keys, strings.TrimPrefix(object.Key, r.key("")))
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// key returns the full key of a key relative to the configured prefix
+func (r *s3Remote) key(key string) string {
+	prefix := strings.Trim(r.config.Prefix, "/")
+	if prefix == "" {
+		return key
+	}
+	return prefix + "/" + key
+}
+
+// do sends a signed request for an object, or for the bucket when key is
+// empty, and returns the response body
+func (r *s3Remote) do(method, key string, query url.Values, body []byte) ([]byte, error) {
+	if r.waiting() {
+		return nil, fmt.Errorf("s3 %s %s: unreachable, trying again shortly", method, r.config.Bucket)
+	}
+
+	target := strings.TrimSuffix(r.config.Endpoint, "/") + "/" + r.config.Bucket
+	if key != "" {
+		target += "/" + r.key(key)
+	}
+	request, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.URL.RawQuery = query.Encode()
+	if body == nil {
+		request.Body, request.ContentLength = nil, 0
+	}
+	signS3Request(request, r.credentials, r.config.Region, body, time.Now())
+
+	response, err := r.client.Do(request)
+	if err != nil {
+		r.backOff()
+		return nil, fmt.Errorf("s3 %s %s: %w", method, r.config.Bucket, err)
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("s3 %s %s: %w", method, r.config.Bucket, err)
+	}
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("s3 %s %s/%s: %w", method, r.config.Bucket, r.key(key), os.ErrNotExist)
+	case response.StatusCode >= 300:
+		if response.StatusCode >= 500 {
+			r.backOff()
+		}
+		var s3Err struct {
+			Code    string
+			Message string
+		}
+		xml.Unmarshal(data, &s3Err)
+		if s3Err.Code == "" {
+			s3Err.Code = response.Status
+		}
+		return nil, fmt.Errorf("s3 %s %s/%s: %s %s", method, r.config.Bucket, r.key(key), s3Err.Code, s3Err.Message)
+	}
+	return data, nil
+}
+
+// signS3Request signs a request with AWS Signature Version 4, covering the
+// host and every header already set
+func signS3Request(request *http.Request, credentials s3Credentials, region string, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if credentials.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		s3EscapePath(request.URL.Path),
+		s3CanonicalQuery(request.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+credentials.SecretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		credentials.AccessKey, scope, signedHeaders, signature))
+}
+
+// s3EscapePath encodes a path the way signatures expect: every byte but the
+// unreserved characters and slashes
+func s3EscapePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	var escaped strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == '/' || c == '-' || c == '_' || c == '.' || c == '~' ||
+			'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+			escaped.WriteByte(c)
+		} else {
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+// s3CanonicalQuery encodes a query sorted by name, with values encoded like
+// path segments
+func s3CanonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, s3EscapeValue(name)+"="+s3EscapeValue(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func s3EscapeValue(value string) string {
+	return strings.ReplaceAll(s3EscapePath(value), "/", "%2F")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Objects keeps objects in a local store, like the files backend, and
+// mirrors every one to the bucket as it's written. Objects missing locally,
+// e.g. on another machine, are downloaded on first read; what exists and
+// listings are of the local store, so they don't need the network.
+type s3Objects struct {
+	local  ObjectStore
+	remote *s3Remote
+	name   string // ObjectsDir or BlobsDir, the first part of each key
+}
+
+func (so *s3Objects) Put(hash string, data []byte) error {
+	if err := so.local.Put(hash, data); err != nil {
+		return err
+	}
+	return so.remote.upload(so.name + "/" + hash)
+}
+
+func (so *s3Objects) Get(hash string) ([]byte, error) {
+	data, err := so.local.Get(hash)
+	if err == nil || !os.IsNotExist(err) {
+		return data, err
+	}
+	if data, err = so.remote.get(so.name + "/" + hash); err != nil {
+		return nil, err
+	}
+	if err := so.local.Put(hash, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Has reports whether the object is stored locally; every object written
+// here is, uploaded or still pending
+func (so *s3Objects) Has(hash string) bool {
+	return so.local.Has(hash)
+}
+
+func (so *s3Objects) Size(hash string) (int64, error) {
+	if !so.local.Has(hash) {
+		if _, err := so.Get(hash); err != nil {
+			return 0, err
+		}
+	}
+	return so.local.Size(hash)
+}
+
+func (so *s3Objects) Delete(hash string) error {
+	if err := so.remote.delete(so.name + "/" + hash); err != nil {
+		return err
+	}
+	if err := so.local.Delete(hash); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List returns the objects stored locally, see S3Fetch for those only in
+// the bucket
+func (so *s3Objects) List() ([]string, error) {
+	return so.local.List()
+}
@@ -0,0 +1,371 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// The search index file is a log: a header of searchIndexMagic and the
+// version, then records of a little-endian uint32 length and a JSON
+// searchRecord, sealed in an encrypted repository. Commits append their
+// tokens without reading the file, so committing costs the same however
+// long the history; loading replays the records and compacts the file once
+// removals outweigh what's left.
+// Earlier search indexes are a single JSON document, upgraded when next
+// written.
+const (
+	searchIndexMagic   = "LCGS"
+	SearchIndexVersion = 2
+	searchHeaderSize   = len(searchIndexMagic) + 4
+)
+
+// searchRecord adds a commit's tokens, or removes commits
+type searchRecord struct {
+	Hash    string   `json:"h,omitempty"`
+	Tokens  []string `json:"t,omitempty"`
+	Removed []string `json:"r,omitempty"`
+}
+
+// SearchIndex is an inverted index from content and message tokens to commit
+// hashes. One that hasn't been loaded can still add and remove commits,
+// appending them to the file.
+type SearchIndex struct {
+	Tokens  map[string][]string `json:"tokens"`
+	Commits map[string]bool     `json:"commits"`
+	storage IndexStorage
+}
+
+// NewSearchIndex creates a new search index manager
+func NewSearchIndex(storage IndexStorage) *SearchIndex {
+	return &SearchIndex{
+		Tokens:  make(map[string][]string),
+		Commits: make(map[string]bool),
+		storage: storage,
+	}
+}
+
+// LoadSearchIndex reads the search index from disk. A damaged one loads
+// as far as it can be read, and covering fewer commits it's rebuilt by
+// those checking it with Covers.
+func (si *SearchIndex) LoadSearchIndex() error {
+	si.Tokens = make(map[string][]string)
+	si.Commits = make(map[string]bool)
+
+	data, err := si.storage.ReadRepoFile(SearchIndexFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read search index: %w", err)
+	}
+	aead, err := si.cipher()
+	if err != nil {
+		return err
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+	if !bytes.HasPrefix(data, []byte(searchIndexMagic)) {
+		if aead != nil {
+			// Left unsealed, so rebuilt
+			return nil
+		}
+		if err := json.Unmarshal(data, si); err != nil {
+			return fmt.Errorf("failed to unmarshal search index: %w", err)
+		}
+		if si.Tokens == nil {
+			si.Tokens = make(map[string][]string)
+		}
+		if si.Commits == nil {
+			si.Commits = make(map[string]bool)
+		}
+		return nil
+	}
+
+	records := 0
+	for rest := data[min(searchHeaderSize, len(data)):]; len(rest) >= 4; records++ {
+		size := int(binary.LittleEndian.Uint32(rest))
+		if size > len(rest)-4 {
+			// Cut short by a crash while appending
+			break
+		}
+		payload := rest[4 : 4+size]
+		if aead != nil {
+			if payload, err = openObject(aead, SearchIndexFile, payload); err != nil {
+				break
+			}
+		}
+		var record searchRecord
+		if err := json.Unmarshal(payload, &record); err != nil {
+			break
+		}
+		si.apply(record)
+		rest = rest[4+size:]
+	}
+
+	if records > 2*len(si.Commits)+64 {
+		return si.SaveSearchIndex()
+	}
+	return nil
+}
+
+// SaveSearchIndex writes the whole search index to disk, a record per
+// commit
+func (si *SearchIndex) SaveSearchIndex() error {
+	byCommit := make(map[string][]string, len(si.Commits))
+	for token, hashes := range si.Tokens {
+		for _, hash := range hashes {
+			byCommit[hash] = append(byCommit[hash], token)
+		}
+	}
+	hashes := make([]string, 0, len(si.Commits))
+	for hash := range si.Commits {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	aead, err := si.cipher()
+	if err != nil {
+		return err
+	}
+	data := searchIndexHeader()
+	for _, hash := range hashes {
+		tokens := byCommit[hash]
+		sort.Strings(tokens)
+		record, err := encodeSearchRecord(searchRecord{Hash: hash, Tokens: tokens}, aead)
+		if err != nil {
+			return err
+		}
+		data = append(data, record...)
+	}
+
+	return si.storage.WriteRepoFile(SearchIndexFile, data)
+}
+
+// searchIndexHeader returns the header of a search index file
+func searchIndexHeader() []byte {
+	return binary.LittleEndian.AppendUint32([]byte(searchIndexMagic), SearchIndexVersion)
+}
+
+// cipher returns the cipher sealing the search index of an encrypted
+// repository, or nil
+func (si *SearchIndex) cipher() (cipher.AEAD, error) {
+	if fs, ok := si.storage.(*FileSystemStorage); ok {
+		return fs.fileCipher()
+	}
+	return nil, nil
+}
+
+// encodeSearchRecord returns a record of the search index file, sealed
+// with aead unless it's nil
+func encodeSearchRecord(record searchRecord, aead cipher.AEAD) ([]byte, error) {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal search index: %w", err)
+	}
+	if aead != nil {
+		if payload, err = sealObject(aead, SearchIndexFile, payload); err != nil {
+			return nil, err
+		}
+	}
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(payload))), payload...), nil
+}
+
+// appendRecord adds a record to the end of the search index file. An
+// earlier, single-document index is loaded and rewritten instead.
+func (si *SearchIndex) appendRecord(record searchRecord) error {
+	header := make([]byte, searchHeaderSize)
+	n, err := si.storage.ReadRepoFileAt(SearchIndexFile, header, 0)
+	switch {
+	case os.IsNotExist(err) || (n == 0 && err == io.EOF):
+		header = searchIndexHeader()
+	case err != nil && err != io.EOF:
+		return fmt.Errorf("failed to read search index: %w", err)
+	case bytes.HasPrefix(header[:n], []byte(searchIndexMagic)):
+		header = nil
+	default:
+		// An index that can't be read is rewritten with just this record,
+		// and rebuilt by the next search since it covers too little
+		upgraded := NewSearchIndex(si.storage)
+		if err := upgraded.LoadSearchIndex(); err != nil {
+			upgraded = NewSearchIndex(si.storage)
+		}
+		upgraded.apply(record)
+		return upgraded.SaveSearchIndex()
+	}
+
+	aead, err := si.cipher()
+	if err != nil {
+		return err
+	}
+	data, err := encodeSearchRecord(record, aead)
+	if err != nil {
+		return err
+	}
+	return si.storage.AppendRepoFile(SearchIndexFile, append(header, data...))
+}
+
+// AddCommit indexes the tokens of a commit and appends them to the file
+func (si *SearchIndex) AddCommit(commit *Commit) error {
+	if si.Commits[commit.Hash] {
+		return nil
+	}
+	record := searchRecord{Hash: commit.Hash, Tokens: Tokenize(commit.Content + "\n" + commit.Message)}
+	si.apply(record)
+	return si.appendRecord(record)
+}
+
+// index adds a commit's tokens without saving
+func (si *SearchIndex) index(commit *Commit) {
+	if si.Commits[commit.Hash] {
+		return
+	}
+	si.apply(searchRecord{Hash: commit.Hash, Tokens: Tokenize(commit.Content + "\n" + commit.Message)})
+}
+
+// apply replays a record on the index in memory
+func (si *SearchIndex) apply(record searchRecord) {
+	if len(record.Removed) > 0 {
+		removed := make(map[string]bool, len(record.Removed))
+		for _, hash := range record.Removed {
+			removed[hash] = true
+		}
+		si.remove(removed)
+	}
+	if record.Hash == "" || si.Commits[record.Hash] {
+		return
+	}
+	for _, token := range record.Tokens {
+		si.Tokens[token] = append(si.Tokens[token], record.Hash)
+	}
+	si.Commits[record.Hash] = true
+}
+
+// RemoveCommits drops the given commits from the index and appends their
+// removal to the file
+func (si *SearchIndex) RemoveCommits(hashes map[string]bool) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+	si.remove(hashes)
+
+	removed := make([]string, 0, len(hashes))
+	for hash := range hashes {
+		removed = append(removed, hash)
+	}
+	sort.Strings(removed)
+	return si.appendRecord(searchRecord{Removed: removed})
+}
+
+// remove drops commits from the index in memory
+func (si *SearchIndex) remove(hashes map[string]bool) {
+	for token, indexed := range si.Tokens {
+		kept := indexed[:0]
+		for _, hash := range indexed {
+			if !hashes[hash] {
+				kept = append(kept, hash)
+			}
+		}
+		if len(kept) == 0 {
+			delete(si.Tokens, token)
+		} else {
+			si.Tokens[token] = kept
+		}
+	}
+	for hash := range hashes {
+		delete(si.Commits, hash)
+	}
+}
+
+// Covers reports whether every given commit hash has been indexed
+func (si *SearchIndex) Covers(hashes []string) bool {
+	if len(si.Commits) < len(hashes) {
+		return false
+	}
+	for _, hash := range hashes {
+		if !si.Commits[hash] {
+			return false
+		}
+	}
+	return true
+}
+
+// Candidates returns the commits that may contain the query as a case-insensitive
+// substring. Every query token must appear inside some token of a candidate.
+func (si *SearchIndex) Candidates(query string) map[string]bool {
+	var candidates map[string]bool
+
+	for _, queryToken := range Tokenize(query) {
+		matches := make(map[string]bool)
+		for token, hashes := range si.Tokens {
+			if !strings.Contains(token, queryToken) {
+				continue
+			}
+			for _, hash := range hashes {
+				if candidates == nil || candidates[hash] {
+					matches[hash] = true
+				}
+			}
+		}
+		candidates = matches
+	}
+
+	// A query without word characters cannot be narrowed down
+	if candidates == nil {
+		candidates = make(map[string]bool, len(si.Commits))
+		for hash := range si.Commits {
+			candidates[hash] = true
+		}
+	}
+
+	return candidates
+}
+
+// RebuildSearchIndex reindexes all commits in storage
+func (si *SearchIndex) RebuildSearchIndex() error {
+	hashes, err := si.storage.ListCommits()
+	if err != nil {
+		return fmt.Errorf("failed to list commits: %w", err)
+	}
+
+	si.Tokens = make(map[string][]string)
+	si.Commits = make(map[string]bool, len(hashes))
+
+	for _, hash := range hashes {
+		commit, err := si.storage.ReadCommit(hash)
+		if err != nil {
+			return fmt.Errorf("failed to read commit %s: %w", hash, err)
+		}
+		si.index(commit)
+	}
+
+	return si.SaveSearchIndex()
+}
+
+// Tokenize splits text into unique lowercase word tokens
+func Tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+
+	seen := make(map[string]bool, len(words))
+	tokens := make([]string, 0, len(words))
+	for _, word := range words {
+		if !seen[word] {
+			seen[word] = true
+			tokens = append(tokens, word)
+		}
+	}
+
+	sort.Strings(tokens)
+	return tokens
+}
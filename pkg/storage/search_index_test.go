@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens := Tokenize("live_loop :Drums do\n  sample :bd_haus, amp: 2\nend drums")
+
+	expected := []string{"2", "amp", "bd_haus", "do", "drums", "end", "live_loop", "sample"}
+	if len(tokens) != len(expected) {
+		t.Fatalf("Expected %d tokens, got %d: %v", len(expected), len(tokens), tokens)
+	}
+
+	for i, token := range expected {
+		if tokens[i] != token {
+			t.Errorf("Expected token %d to be '%s', got '%s'", i, token, tokens[i])
+		}
+	}
+}
+
+func TestSearchIndexCandidates(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	storage := NewFileSystemStorage(tempDir)
+	err := storage.InitializeRepository()
+	if err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	index := NewSearchIndex(storage)
+
+	bass := createTestCommit()
+	bass.Hash = "aa11"
+	bass.Content = "use_synth :tb303\nplay :e2"
+
+	drums := createTestCommit()
+	drums.Hash = "bb22"
+	drums.Content = "sample :bd_haus"
+
+	for _, commit := range []*Commit{bass, drums} {
+		if err := index.AddCommit(commit); err != nil {
+			t.Fatalf("Failed to index commit: %v", err)
+		}
+	}
+
+	// Partial tokens still find candidates
+	candidates := index.Candidates("TB30")
+	if !candidates["aa11"] || candidates["bb22"] {
+		t.Errorf("Expected only the bass commit as candidate, got %v", candidates)
+	}
+
+	// All query tokens must be present
+	candidates = index.Candidates("tb303 bd_haus")
+	if len(candidates) != 0 {
+		t.Errorf("Expected no candidates, got %v", candidates)
+	}
+
+	// The index survives a reload
+	reloaded := NewSearchIndex(storage)
+	if err := reloaded.LoadSearchIndex(); err != nil {
+		t.Fatalf("Failed to load search index: %v", err)
+	}
+
+	if !reloaded.Covers([]string{"aa11", "bb22"}) {
+		t.Errorf("Expected reloaded index to cover both commits")
+	}
+
+	if reloaded.Covers([]string{"aa11", "cc33"}) {
+		t.Errorf("Expected reloaded index not to cover unknown commit")
+	}
+}
+
+func TestSearchIndexAppends(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	storage := NewFileSystemStorage(tempDir)
+	if err := storage.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	// An index that was never loaded adds commits to the end of the file
+	bass := createTestCommit()
+	bass.Hash = "aa11"
+	bass.Content = "use_synth :tb303"
+	if err := NewSearchIndex(storage).AddCommit(bass); err != nil {
+		t.Fatalf("Failed to index commit: %v", err)
+	}
+	before, _ := storage.ReadRepoFile(SearchIndexFile)
+
+	drums := createTestCommit()
+	drums.Hash = "bb22"
+	drums.Content = "sample :bd_haus"
+	if err := NewSearchIndex(storage).AddCommit(drums); err != nil {
+		t.Fatalf("Failed to index commit: %v", err)
+	}
+	if err := NewSearchIndex(storage).RemoveCommits(map[string]bool{"aa11": true}); err != nil {
+		t.Fatalf("Failed to remove commit: %v", err)
+	}
+	after, _ := storage.ReadRepoFile(SearchIndexFile)
+	if !bytes.HasPrefix(after, before) {
+		t.Errorf("Expected the search index appended to, not rewritten")
+	}
+
+	reloaded := NewSearchIndex(storage)
+	if err := reloaded.LoadSearchIndex(); err != nil {
+		t.Fatalf("Failed to load search index: %v", err)
+	}
+	if reloaded.Covers([]string{"aa11"}) || !reloaded.Covers([]string{"bb22"}) {
+		t.Errorf("Expected only the drums commit indexed, got %v", reloaded.Commits)
+	}
+	if len(reloaded.Candidates("tb303")) != 0 {
+		t.Errorf("Expected the removed commit's tokens dropped")
+	}
+
+	// A record cut short by a crash is left out
+	torn := append(append([]byte(nil), after...), 0x40, 0, 0, 0, '{')
+	if err := storage.WriteRepoFile(SearchIndexFile, torn); err != nil {
+		t.Fatalf("Failed to write search index: %v", err)
+	}
+	reloaded = NewSearchIndex(storage)
+	if err := reloaded.LoadSearchIndex(); err != nil {
+		t.Fatalf("Failed to load torn search index: %v", err)
+	}
+	if !reloaded.Covers([]string{"bb22"}) {
+		t.Errorf("Expected the records before the torn one kept")
+	}
+}
+
+func TestSearchIndexUpgradesJSON(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	storage := NewFileSystemStorage(tempDir)
+	if err := storage.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+
+	legacy := `{"tokens":{"tb303":["aa11"]},"commits":{"aa11":true}}`
+	if err := storage.WriteRepoFile(SearchIndexFile, []byte(legacy)); err != nil {
+		t.Fatalf("Failed to write search index: %v", err)
+	}
+
+	drums := createTestCommit()
+	drums.Hash = "bb22"
+	drums.Content = "sample :bd_haus"
+	if err := NewSearchIndex(storage).AddCommit(drums); err != nil {
+		t.Fatalf("Failed to index commit: %v", err)
+	}
+
+	data, _ := storage.ReadRepoFile(SearchIndexFile)
+	if !bytes.HasPrefix(data, []byte(searchIndexMagic)) {
+		t.Errorf("Expected the JSON search index rewritten as a log")
+	}
+
+	reloaded := NewSearchIndex(storage)
+	if err := reloaded.LoadSearchIndex(); err != nil {
+		t.Fatalf("Failed to load search index: %v", err)
+	}
+	if !reloaded.Candidates("tb303")["aa11"] || !reloaded.Candidates("bd_haus")["bb22"] {
+		t.Errorf("Expected both commits searchable after the upgrade")
+	}
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+// ChunkSize is how much of a streamed commit's content each of its blobs
+// holds, so neither writing nor reading it needs more in memory
+const ChunkSize = 1 << 20
+
+// WriteCommitStream stores a commit whose content is read from content, for
+// buffers too large to hold in memory, e.g. embedding long sample lists.
+// The content is stored in blobs of ChunkSize bytes, whatever the format's
+// blobs setting, and hashed as it's read: commit.Content is ignored and
+// commit.Hash set to the hash the content gives in the repository's format
+// version. ReadCommit reads such commits whole; ReadCommitStream doesn't.
+func (fs *FileSystemStorage) WriteCommitStream(commit *Commit, content io.Reader) error {
+	format, err := fs.writeFormat()
+	if err != nil {
+		return err
+	}
+	store, err := fs.objects()
+	if err != nil {
+		return err
+	}
+
+	stored := *commit
+	stored.Content = ""
+	hasher := newStreamHasher(&stored, format.version())
+
+	// Chunks are written first so no commit refers to a missing one
+	var chunks []string
+	buf := make([]byte, ChunkSize)
+	for {
+		n, err := io.ReadFull(content, buf)
+		if n > 0 {
+			hasher.write(buf[:n])
+			blob, err := fs.writeBlob(string(buf[:n]), format.Compression)
+			if err != nil {
+				return err
+			}
+			chunks = append(chunks, blob)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read content: %w", err)
+		}
+	}
+
+	stored.Hash = hasher.sum()
+	data, err := json.MarshalIndent(blobCommit{Commit: &stored, Chunks: chunks}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal commit: %w", err)
+	}
+	if data, err = compressObject(data, format.Compression); err != nil {
+		return fmt.Errorf("failed to compress commit: %w", err)
+	}
+	if err := store.Put(stored.Hash, data); err != nil {
+		return err
+	}
+
+	commit.Hash = stored.Hash
+	return nil
+}
+
+// ReadCommitStream retrieves a commit by its hash, writing its content to
+// content rather than keeping it in the returned commit. Content stored in
+// chunks is read one chunk at a time.
+func (fs *FileSystemStorage) ReadCommitStream(hash string, content io.Writer) (*Commit, error) {
+	commit, object, err := fs.readStoredCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(object.Blobs()) == 0 {
+		if _, err := io.WriteString(content, commit.Content); err != nil {
+			return nil, fmt.Errorf("failed to write content of commit %s: %w", hash, err)
+		}
+	}
+	for _, blob := range object.Blobs() {
+		data, err := fs.readBlob(blob)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit %s: %w", hash, err)
+		}
+		if _, err := content.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to write content of commit %s: %w", hash, err)
+		}
+	}
+
+	commit.Content = ""
+	return commit, nil
+}
+
+// streamHasher computes HashCommit for a commit whose content arrives in
+// pieces
+type streamHasher struct {
+	hash    hash.Hash
+	suffix  []byte
+	escape  bool   // content is hashed as in CanonicalCommit's JSON
+	partial []byte // start of a character cut off by the last piece
+}
+
+func newStreamHasher(commit *Commit, version int) *streamHasher {
+	if version < FormatSHA256 {
+		// CommitHash's content comes first, then message and time
+		return &streamHasher{
+			hash:   sha1.New(),
+			suffix: []byte(commit.Message + commit.Timestamp.UTC().Format(time.RFC3339Nano)),
+		}
+	}
+
+	// The content goes between the quotes of the canonical serialization's
+	// empty content. Quotes inside the other strings are escaped, so the
+	// first match is the content.
+	canonical := CanonicalCommit(commit)
+	field := []byte(`"content":"`)
+	at := bytes.Index(canonical, append(field, '"')) + len(field)
+
+	hasher := &streamHasher{hash: sha256.New(), suffix: canonical[at:], escape: true}
+	hasher.hash.Write(canonical[:at])
+	return hasher
+}
+
+// write hashes the next piece of content
+func (h *streamHasher) write(data []byte) {
+	if !h.escape {
+		h.hash.Write(data)
+		return
+	}
+
+	if h.partial != nil {
+		data = append(h.partial, data...)
+		h.partial = nil
+	}
+	// Escaping works character by character, so one cut off is kept for the
+	// next piece
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax+1; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				h.partial = append([]byte(nil), data[i:]...)
+				data = data[:i]
+			}
+			break
+		}
+	}
+	h.writeEscaped(data)
+}
+
+// writeEscaped hashes content the way encoding/json writes it in a string
+func (h *streamHasher) writeEscaped(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	// A string always marshals
+	escaped, _ := json.Marshal(string(data))
+	h.hash.Write(escaped[1 : len(escaped)-1])
+}
+
+// sum returns the commit's hash once all of its content is written
+func (h *streamHasher) sum() string {
+	h.writeEscaped(h.partial)
+	h.partial = nil
+	h.hash.Write(h.suffix)
+	return fmt.Sprintf("%x", h.hash.Sum(nil))
+}
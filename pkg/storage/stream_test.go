@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// streamContent returns content of more than two chunks, with characters
+// JSON escapes and ones cut across chunk boundaries
+func streamContent() string {
+	line := "d1 $ s \"bd*2 <sn cp>\" # pan \"0 & 1\" -- ünïcödé ♪ \\ \t\n"
+	content := strings.Repeat(line, 2*ChunkSize/len(line)+100)
+	return content + "\xff\nlast line"
+}
+
+func TestStreamHasher(t *testing.T) {
+	commit := createTestCommit()
+	commit.Content = streamContent()[:10000] + "\xe2\x82"
+
+	for _, version := range []int{FormatSHA1, FormatSHA256} {
+		for _, size := range []int{1, 2, 3, 7, 4096} {
+			stored := *commit
+			stored.Content = ""
+			hasher := newStreamHasher(&stored, version)
+			data := []byte(commit.Content)
+			for len(data) > 0 {
+				n := size
+				if n > len(data) {
+					n = len(data)
+				}
+				hasher.write(data[:n])
+				data = data[n:]
+			}
+			if hash := hasher.sum(); hash != HashCommit(commit, version) {
+				t.Errorf("Version %d in pieces of %d: expected %s, got %s", version, size, HashCommit(commit, version), hash)
+			}
+		}
+	}
+}
+
+func TestCommitStream(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	fs := NewFileSystemStorage(tempDir)
+	if err := fs.InitializeRepository(); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	if err := fs.SetFormatVersion(CurrentFormatVersion); err != nil {
+		t.Fatalf("Failed to set format version: %v", err)
+	}
+
+	content := streamContent()
+	commit := createTestCommit()
+	commit.Hash = ""
+	commit.Content = ""
+	if err := fs.WriteCommitStream(commit, strings.NewReader(content)); err != nil {
+		t.Fatalf("Failed to write commit: %v", err)
+	}
+	whole := *commit
+	whole.Content = content
+	if commit.Hash != CanonicalHash(&whole) {
+		t.Errorf("Expected the hash of the whole commit, got %s", commit.Hash)
+	}
+
+	blobs, err := fs.CommitBlobs(commit.Hash)
+	if err != nil || len(blobs) != 3 {
+		t.Errorf("Expected the content in 3 chunks, got %v (%v)", blobs, err)
+	}
+	if blob, err := fs.CommitBlob(commit.Hash); err != nil || blob != "" {
+		t.Errorf("Expected no single blob, got %q (%v)", blob, err)
+	}
+
+	var buf bytes.Buffer
+	read, err := fs.ReadCommitStream(commit.Hash, &buf)
+	if err != nil {
+		t.Fatalf("Failed to read commit: %v", err)
+	}
+	if buf.String() != content || read.Content != "" || read.Message != commit.Message {
+		t.Errorf("Expected the content streamed out, got %d bytes and %q", buf.Len(), read.Message)
+	}
+
+	read, err = fs.ReadCommit(commit.Hash)
+	if err != nil || read.Content != content || !VerifyHash(read) {
+		t.Errorf("Expected the whole commit back, got %v", err)
+	}
+
+	// Commits written whole stream out too
+	small := createTestCommit()
+	if err := fs.WriteCommit(small); err != nil {
+		t.Fatalf("Failed to write commit: %v", err)
+	}
+	buf.Reset()
+	if _, err := fs.ReadCommitStream(small.Hash, &buf); err != nil || buf.String() != small.Content {
+		t.Errorf("Expected %q, got %q (%v)", small.Content, buf.String(), err)
+	}
+
+	var matched []int
+	err = fs.Grep([]string{commit.Hash}, regexp.MustCompile(`^last line`), func(match GrepMatch) bool {
+		matched = append(matched, match.Line)
+		return true
+	})
+	if err != nil || len(matched) != 1 {
+		t.Errorf("Expected grep to search chunked content, got %v (%v)", matched, err)
+	}
+
+	count, err := fs.CountObjects()
+	if err != nil {
+		t.Fatalf("Failed to count objects: %v", err)
+	}
+	if count.BlobCommits != 1 || count.ContentBytes != count.BlobBytes {
+		t.Errorf("Expected the chunks counted as the commit's content, got %+v", count)
+	}
+}
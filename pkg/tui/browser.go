@@ -0,0 +1,357 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+// DefaultLimit is how many commits the browser loads when no limit is given
+const DefaultLimit = 500
+
+// maxReplayGap caps the pause between replayed commits so long silences
+// in a performance don't stall the replay
+const maxReplayGap = 2 * time.Second
+
+// Repository is the part of the core repository the browser uses
+type Repository interface {
+	Log(limit int) ([]*core.Commit, error)
+	Tag(name string, hash string) error
+	Tags() (map[string]string, error)
+}
+
+// Options configures a history browser
+type Options struct {
+	// Limit is the number of most recent commits to load
+	Limit int
+	// Buffer only shows commits from one buffer initially
+	Buffer string
+	// CheckoutDir is where checked out commits are written
+	CheckoutDir string
+}
+
+type inputMode int
+
+const (
+	modeBrowse inputMode = iota
+	modeTag
+)
+
+// Browser holds the state of the history browser. It is driven by key names
+// and renders to plain lines, so it can be used without a terminal.
+type Browser struct {
+	repo    Repository
+	options Options
+
+	commits []*core.Commit // newest first
+	visible []*core.Commit // commits matching the buffer filter
+	buffers []string
+	buffer  string
+	tags    map[string][]string // commit hash to tag names
+
+	cursor int
+	offset int
+	width  int
+	height int
+
+	mode   inputMode
+	input  string
+	status string
+
+	replayFrames []*core.Commit
+	replayFrame  int
+}
+
+// NewBrowser loads the commit history and tags from repo
+func NewBrowser(repo Repository, options Options) (*Browser, error) {
+	if options.Limit <= 0 {
+		options.Limit = DefaultLimit
+	}
+
+	commits, err := repo.Log(options.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history: %w", err)
+	}
+
+	browser := &Browser{
+		repo:    repo,
+		options: options,
+		commits: commits,
+		buffer:  options.Buffer,
+		width:   80,
+		height:  24,
+	}
+
+	seen := make(map[string]bool)
+	for _, commit := range commits {
+		if !seen[commit.Metadata.Buffer] {
+			seen[commit.Metadata.Buffer] = true
+			browser.buffers = append(browser.buffers, commit.Metadata.Buffer)
+		}
+	}
+	sort.Strings(browser.buffers)
+
+	if err := browser.loadTags(); err != nil {
+		return nil, err
+	}
+
+	browser.applyFilter()
+	return browser, nil
+}
+
+// Resize sets the screen size used by Render
+func (b *Browser) Resize(width, height int) {
+	b.width = max(width, 20)
+	b.height = max(height, 5)
+	b.scrollToCursor()
+}
+
+// Selected returns the commit under the cursor, or nil when none is visible
+func (b *Browser) Selected() *core.Commit {
+	if b.cursor < 0 || b.cursor >= len(b.visible) {
+		return nil
+	}
+	return b.visible[b.cursor]
+}
+
+// Replaying reports whether a replay is in progress
+func (b *Browser) Replaying() bool {
+	return b.replayFrames != nil
+}
+
+// HandleKey applies a key press and reports whether the browser should quit.
+// Keys are single characters or one of up, down, pgup, pgdown, home, end,
+// enter, esc, backspace and ctrl-c.
+func (b *Browser) HandleKey(key string) bool {
+	if key == "ctrl-c" {
+		return true
+	}
+
+	// Any key interrupts a replay
+	if b.Replaying() {
+		b.replayFrames = nil
+		b.status = "Replay stopped"
+		return false
+	}
+
+	if b.mode == modeTag {
+		b.handleTagInput(key)
+		return false
+	}
+
+	b.status = ""
+	page := max(b.listHeight()-1, 1)
+
+	switch key {
+	case "q", "esc":
+		return true
+	case "j", "down":
+		b.moveCursor(1)
+	case "k", "up":
+		b.moveCursor(-1)
+	case "pgdown", " ":
+		b.moveCursor(page)
+	case "pgup":
+		b.moveCursor(-page)
+	case "g", "home":
+		b.moveCursor(-len(b.visible))
+	case "G", "end":
+		b.moveCursor(len(b.visible))
+	case "b":
+		b.cycleBuffer()
+	case "c":
+		b.checkout()
+	case "t":
+		if b.Selected() != nil {
+			b.mode = modeTag
+			b.input = ""
+		}
+	case "r":
+		b.startReplay()
+	}
+
+	return false
+}
+
+// handleTagInput edits the tag name prompt
+func (b *Browser) handleTagInput(key string) {
+	switch key {
+	case "esc":
+		b.mode = modeBrowse
+	case "enter":
+		b.mode = modeBrowse
+		name := strings.TrimSpace(b.input)
+		if name == "" {
+			return
+		}
+
+		commit := b.Selected()
+		if err := b.repo.Tag(name, commit.Hash); err != nil {
+			b.status = fmt.Sprintf("Error: %v", err)
+			return
+		}
+		if err := b.loadTags(); err != nil {
+			b.status = fmt.Sprintf("Error: %v", err)
+			return
+		}
+		b.status = fmt.Sprintf("Tagged %s as %s", shortHash(commit.Hash), name)
+	case "backspace":
+		if runes := []rune(b.input); len(runes) > 0 {
+			b.input = string(runes[:len(runes)-1])
+		}
+	default:
+		if len([]rune(key)) == 1 {
+			b.input += key
+		}
+	}
+}
+
+// moveCursor moves the selection by delta commits, keeping it on screen
+func (b *Browser) moveCursor(delta int) {
+	b.cursor = min(max(b.cursor+delta, 0), max(len(b.visible)-1, 0))
+	b.scrollToCursor()
+}
+
+// scrollToCursor adjusts the list offset so the cursor is visible
+func (b *Browser) scrollToCursor() {
+	height := b.listHeight()
+	if b.cursor < b.offset {
+		b.offset = b.cursor
+	}
+	if b.cursor >= b.offset+height {
+		b.offset = b.cursor - height + 1
+	}
+}
+
+// cycleBuffer steps the buffer filter through all buffers and back to none
+func (b *Browser) cycleBuffer() {
+	next := ""
+	if b.buffer == "" && len(b.buffers) > 0 {
+		next = b.buffers[0]
+	} else {
+		for i, buffer := range b.buffers {
+			if buffer == b.buffer && i+1 < len(b.buffers) {
+				next = b.buffers[i+1]
+			}
+		}
+	}
+
+	b.buffer = next
+	b.applyFilter()
+}
+
+// applyFilter rebuilds the visible commits for the buffer filter, keeping
+// the selected commit when it is still visible
+func (b *Browser) applyFilter() {
+	selected := b.Selected()
+
+	b.visible = b.visible[:0]
+	for _, commit := range b.commits {
+		if b.buffer == "" || commit.Metadata.Buffer == b.buffer {
+			b.visible = append(b.visible, commit)
+		}
+	}
+
+	b.cursor = 0
+	for i, commit := range b.visible {
+		if commit == selected {
+			b.cursor = i
+		}
+	}
+	b.offset = 0
+	b.scrollToCursor()
+}
+
+// loadTags indexes the repository's tags by commit hash
+func (b *Browser) loadTags() error {
+	tags, err := b.repo.Tags()
+	if err != nil {
+		return fmt.Errorf("failed to load tags: %w", err)
+	}
+
+	b.tags = make(map[string][]string)
+	for name, hash := range tags {
+		b.tags[hash] = append(b.tags[hash], name)
+	}
+	for _, names := range b.tags {
+		sort.Strings(names)
+	}
+
+	return nil
+}
+
+// checkout writes the selected commit's code to a file named after its buffer
+func (b *Browser) checkout() {
+	commit := b.Selected()
+	if commit == nil {
+		return
+	}
+
+	name := core.CheckoutFileName(commit)
+	path := filepath.Join(b.options.CheckoutDir, name)
+	if err := os.WriteFile(path, []byte(commit.Content), 0644); err != nil {
+		b.status = fmt.Sprintf("Error: %v", err)
+		return
+	}
+
+	b.status = fmt.Sprintf("Checked out %s to %s", shortHash(commit.Hash), name)
+}
+
+// startReplay plays back the selected buffer's history up to the selected commit
+func (b *Browser) startReplay() {
+	selected := b.Selected()
+	if selected == nil {
+		return
+	}
+
+	var frames []*core.Commit
+	for i := len(b.visible) - 1; i >= b.cursor; i-- {
+		if b.visible[i].Metadata.Buffer == selected.Metadata.Buffer {
+			frames = append(frames, b.visible[i])
+		}
+	}
+
+	b.replayFrames = frames
+	b.replayFrame = 0
+}
+
+// ReplayDelay returns how long to show the current replay frame, using the
+// time between the original executions
+func (b *Browser) ReplayDelay() (time.Duration, bool) {
+	if !b.Replaying() {
+		return 0, false
+	}
+
+	if b.replayFrame+1 >= len(b.replayFrames) {
+		return maxReplayGap, true
+	}
+
+	current := b.replayFrames[b.replayFrame]
+	next := b.replayFrames[b.replayFrame+1]
+	return min(max(next.Timestamp.Sub(current.Timestamp), 0), maxReplayGap), true
+}
+
+// AdvanceReplay moves the replay to its next frame, finishing after the last
+func (b *Browser) AdvanceReplay() {
+	if !b.Replaying() {
+		return
+	}
+
+	b.replayFrame++
+	if b.replayFrame >= len(b.replayFrames) {
+		b.replayFrames = nil
+		b.status = "Replay finished"
+	}
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8]
+	}
+	return hash
+}
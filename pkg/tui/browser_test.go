@@ -0,0 +1,201 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+// fakeRepository serves a fixed history, newest first
+type fakeRepository struct {
+	commits []*core.Commit
+	tags    map[string]string
+}
+
+func (r *fakeRepository) Log(limit int) ([]*core.Commit, error) {
+	return r.commits[:min(limit, len(r.commits))], nil
+}
+
+func (r *fakeRepository) Tag(name string, hash string) error {
+	r.tags[name] = hash
+	return nil
+}
+
+func (r *fakeRepository) Tags() (map[string]string, error) {
+	return r.tags, nil
+}
+
+func createTestBrowser(t *testing.T, options Options) (*Browser, *fakeRepository) {
+	start := time.Date(2024, 5, 1, 21, 0, 0, 0, time.UTC)
+	buffers := []string{"d1", "d2", "d1", "d2", "d1"}
+
+	repo := &fakeRepository{tags: make(map[string]string)}
+	for i := len(buffers) - 1; i >= 0; i-- {
+		repo.commits = append(repo.commits, &core.Commit{
+			Hash:      fmt.Sprintf("%d%039d", i, 0),
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+			Message:   fmt.Sprintf("Commit %d", i),
+			Content:   fmt.Sprintf("%s $ s \"bd*%d\"", buffers[i], i),
+			Metadata:  core.ExecutionMetadata{Buffer: buffers[i], Language: "tidal", Success: true},
+		})
+	}
+
+	browser, err := NewBrowser(repo, options)
+	if err != nil {
+		t.Fatalf("Failed to create browser: %v", err)
+	}
+	browser.Resize(100, 12)
+
+	return browser, repo
+}
+
+func TestBrowserNavigation(t *testing.T) {
+	browser, _ := createTestBrowser(t, Options{})
+
+	if browser.Selected().Message != "Commit 4" {
+		t.Errorf("Expected newest commit selected, got '%s'", browser.Selected().Message)
+	}
+
+	browser.HandleKey("j")
+	browser.HandleKey("down")
+	if browser.Selected().Message != "Commit 2" {
+		t.Errorf("Expected 'Commit 2' after moving down twice, got '%s'", browser.Selected().Message)
+	}
+
+	browser.HandleKey("G")
+	if browser.Selected().Message != "Commit 0" {
+		t.Errorf("Expected oldest commit at end, got '%s'", browser.Selected().Message)
+	}
+
+	browser.HandleKey("j")
+	if browser.Selected().Message != "Commit 0" {
+		t.Errorf("Expected cursor to stop at the last commit, got '%s'", browser.Selected().Message)
+	}
+
+	if !browser.HandleKey("q") {
+		t.Errorf("Expected q to quit")
+	}
+}
+
+func TestBrowserBufferFilter(t *testing.T) {
+	browser, _ := createTestBrowser(t, Options{Buffer: "d2"})
+
+	if len(browser.visible) != 2 {
+		t.Fatalf("Expected 2 d2 commits, got %d", len(browser.visible))
+	}
+
+	// Cycling goes d2 -> all buffers -> d1
+	browser.HandleKey("b")
+	if browser.buffer != "" || len(browser.visible) != 5 {
+		t.Errorf("Expected all 5 commits after cycling, got buffer '%s' with %d", browser.buffer, len(browser.visible))
+	}
+
+	browser.HandleKey("b")
+	if browser.buffer != "d1" || len(browser.visible) != 3 {
+		t.Errorf("Expected 3 d1 commits, got buffer '%s' with %d", browser.buffer, len(browser.visible))
+	}
+}
+
+func TestBrowserTag(t *testing.T) {
+	browser, repo := createTestBrowser(t, Options{})
+	browser.HandleKey("j")
+
+	for _, key := range []string{"t", "d", "r", "o", "x", "backspace", "p", "enter"} {
+		browser.HandleKey(key)
+	}
+
+	if repo.tags["drop"] != browser.Selected().Hash {
+		t.Errorf("Expected tag drop on the selected commit, got %v", repo.tags)
+	}
+
+	browser.Resize(140, 12)
+	screen := strings.Join(browser.Render(), "\n")
+	if !strings.Contains(screen, "Commit 3 (drop)") {
+		t.Errorf("Expected tag to be shown in the list, got:\n%s", screen)
+	}
+}
+
+func TestBrowserCheckout(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "livecodegit-tui-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	browser, _ := createTestBrowser(t, Options{CheckoutDir: tempDir})
+	browser.HandleKey("c")
+
+	data, err := os.ReadFile(filepath.Join(tempDir, "d1.tidal"))
+	if err != nil {
+		t.Fatalf("Expected checked out file: %v", err)
+	}
+	if string(data) != browser.Selected().Content {
+		t.Errorf("Expected checked out content '%s', got '%s'", browser.Selected().Content, string(data))
+	}
+}
+
+func TestBrowserReplay(t *testing.T) {
+	browser, _ := createTestBrowser(t, Options{})
+	browser.HandleKey("j")
+	browser.HandleKey("j")
+	browser.HandleKey("r")
+
+	// Replaying Commit 2 walks d1 from Commit 0, two seconds apart
+	if len(browser.replayFrames) != 2 || browser.replayFrames[0].Message != "Commit 0" {
+		t.Fatalf("Expected replay of Commit 0 and Commit 2, got %d frames", len(browser.replayFrames))
+	}
+
+	delay, ok := browser.ReplayDelay()
+	if !ok || delay != 2*time.Second {
+		t.Errorf("Expected 2s delay between frames, got %v", delay)
+	}
+
+	browser.AdvanceReplay()
+	browser.AdvanceReplay()
+	if browser.Replaying() || browser.status != "Replay finished" {
+		t.Errorf("Expected replay to finish, got status '%s'", browser.status)
+	}
+
+	// A key press stops a replay without acting on the key
+	browser.HandleKey("r")
+	if browser.HandleKey("q") {
+		t.Errorf("Expected q to stop the replay rather than quit")
+	}
+	if browser.Replaying() {
+		t.Errorf("Expected replay to be stopped")
+	}
+}
+
+func TestBrowserRenderSize(t *testing.T) {
+	browser, _ := createTestBrowser(t, Options{})
+
+	for _, size := range [][2]int{{100, 12}, {30, 6}} {
+		browser.Resize(size[0], size[1])
+		lines := browser.Render()
+
+		if len(lines) != size[1] {
+			t.Errorf("Expected %d lines, got %d", size[1], len(lines))
+		}
+		for _, line := range lines {
+			plain := strings.NewReplacer(reverseVideo, "", resetStyle, "").Replace(line)
+			if utf8.RuneCountInString(plain) != size[0] {
+				t.Errorf("Expected lines of width %d, got %d: %q", size[0], utf8.RuneCountInString(plain), plain)
+			}
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys := ParseKeys([]byte("j\x1b[A\x1b[6~\x1b[Cé\r\x7f\x03\x1b"))
+	expected := []string{"j", "up", "pgdown", "é", "enter", "backspace", "ctrl-c", "esc"}
+
+	if strings.Join(keys, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected keys %v, got %v", expected, keys)
+	}
+}
@@ -0,0 +1,173 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+const (
+	reverseVideo = "\x1b[7m"
+	resetStyle   = "\x1b[0m"
+	paneDivider  = " │ "
+)
+
+const helpLine = "j/k move  b buffer  c checkout  t tag  r replay  q quit"
+
+// Render draws the browser as exactly height lines of at most width columns
+func (b *Browser) Render() []string {
+	lines := make([]string, 0, b.height)
+
+	filter := "all buffers"
+	if b.buffer != "" {
+		filter = "buffer " + b.buffer
+	}
+	lines = append(lines, fit(fmt.Sprintf("lcg tui  %d commits  %s", len(b.visible), filter), b.width))
+
+	listWidth := max(b.width*2/5, 20)
+	previewWidth := b.width - listWidth - utf8.RuneCountInString(paneDivider)
+	if previewWidth < 10 {
+		listWidth, previewWidth = b.width, 0
+	}
+
+	list := b.renderList(listWidth)
+	var preview []string
+	if previewWidth > 0 {
+		preview = b.renderPreview(previewWidth)
+	}
+
+	for i := 0; i < b.listHeight(); i++ {
+		line := list[i]
+		if previewWidth > 0 {
+			line += paneDivider + preview[i]
+		}
+		lines = append(lines, line)
+	}
+
+	lines = append(lines, fit(b.footer(), b.width))
+	return lines
+}
+
+// listHeight is the number of rows between the header and the footer
+func (b *Browser) listHeight() int {
+	return max(b.height-2, 1)
+}
+
+// renderList draws one row per visible commit, highlighting the selection
+func (b *Browser) renderList(width int) []string {
+	rows := make([]string, b.listHeight())
+
+	for i := range rows {
+		index := b.offset + i
+		if index >= len(b.visible) {
+			rows[i] = fit("", width)
+			continue
+		}
+
+		commit := b.visible[index]
+		marker := " "
+		if !commit.Metadata.Success {
+			marker = "!"
+		}
+
+		row := fmt.Sprintf("%s %s %s %-6s %s", marker, shortHash(commit.Hash),
+			commit.Timestamp.Format("15:04:05"), commit.Metadata.Buffer, commit.Message)
+		if names := b.tags[commit.Hash]; len(names) > 0 {
+			row += " (" + strings.Join(names, ", ") + ")"
+		}
+
+		rows[i] = fit(row, width)
+		if index == b.cursor {
+			rows[i] = reverseVideo + rows[i] + resetStyle
+		}
+	}
+
+	if len(b.visible) == 0 {
+		rows[0] = fit("  No commits", width)
+	}
+
+	return rows
+}
+
+// renderPreview draws the selected commit, or the current frame of a replay
+func (b *Browser) renderPreview(width int) []string {
+	var text []string
+
+	commit := b.Selected()
+	if b.Replaying() {
+		commit = b.replayFrames[b.replayFrame]
+		text = append(text, fmt.Sprintf("Replay %d/%d (any key stops)", b.replayFrame+1, len(b.replayFrames)))
+	}
+
+	if commit != nil {
+		text = append(text, previewHeader(commit, b.tags[commit.Hash])...)
+		text = append(text, "")
+		for _, line := range strings.Split(commit.Content, "\n") {
+			text = append(text, strings.ReplaceAll(line, "\t", "    "))
+		}
+	}
+
+	rows := make([]string, b.listHeight())
+	for i := range rows {
+		line := ""
+		if i < len(text) {
+			line = text[i]
+		}
+		rows[i] = fit(line, width)
+	}
+
+	return rows
+}
+
+// previewHeader describes a commit above its code
+func previewHeader(commit *core.Commit, tags []string) []string {
+	result := "ok"
+	if !commit.Metadata.Success {
+		result = "error " + commit.Metadata.ErrorMessage
+	}
+
+	header := []string{
+		"commit " + commit.Hash,
+		"Date:   " + commit.Timestamp.Format("2006-01-02 15:04:05"),
+		fmt.Sprintf("Buffer: %s (%s)", commit.Metadata.Buffer, commit.Metadata.Language),
+		"Result: " + result,
+		"        " + commit.Message,
+	}
+	if len(tags) > 0 {
+		header = append(header, "Tags:   "+strings.Join(tags, ", "))
+	}
+
+	return header
+}
+
+// footer shows the tag prompt, the last status message or the key help
+func (b *Browser) footer() string {
+	switch {
+	case b.mode == modeTag:
+		return "Tag name: " + b.input
+	case b.status != "":
+		return b.status
+	default:
+		return helpLine
+	}
+}
+
+// fit truncates or pads text to exactly width characters, blanking control
+// characters so stored code can't move the cursor
+func fit(text string, width int) string {
+	text = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, text)
+
+	count := utf8.RuneCountInString(text)
+	if count > width {
+		runes := []rune(text)
+		return string(runes[:width])
+	}
+	return text + strings.Repeat(" ", width-count)
+}
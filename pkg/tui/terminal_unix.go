@@ -0,0 +1,67 @@
+//go:build !windows
+
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// terminal switches a tty into raw mode with stty, so no terminal library is needed
+type terminal struct {
+	tty   *os.File
+	saved string
+}
+
+// openTerminal puts tty into raw mode, remembering the previous settings
+func openTerminal(tty *os.File) (*terminal, error) {
+	saved, err := stty(tty, "-g")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read terminal settings: %w", err)
+	}
+
+	if _, err := stty(tty, "raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("failed to enter raw mode: %w", err)
+	}
+
+	return &terminal{tty: tty, saved: saved}, nil
+}
+
+// restore returns the terminal to the settings it had when opened
+func (t *terminal) restore() error {
+	_, err := stty(t.tty, t.saved)
+	return err
+}
+
+// size returns the terminal width and height
+func (t *terminal) size() (int, int, error) {
+	output, err := stty(t.tty, "size")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var rows, columns int
+	if _, err := fmt.Sscanf(output, "%d %d", &rows, &columns); err != nil {
+		return 0, 0, fmt.Errorf("unexpected stty size output %q", output)
+	}
+	return columns, rows, nil
+}
+
+// resizeSignals delivers a value whenever the terminal is resized
+func resizeSignals() <-chan os.Signal {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+	return signals
+}
+
+// stty runs stty against tty and returns its trimmed output
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	output, err := cmd.Output()
+	return strings.TrimSpace(string(output)), err
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package tui
+
+import (
+	"fmt"
+	"os"
+)
+
+type terminal struct{}
+
+// openTerminal is not supported on Windows, which has no stty
+func openTerminal(tty *os.File) (*terminal, error) {
+	return nil, fmt.Errorf("the history browser is not supported on Windows")
+}
+
+func (t *terminal) restore() error {
+	return nil
+}
+
+func (t *terminal) size() (int, int, error) {
+	return 80, 24, nil
+}
+
+func resizeSignals() <-chan os.Signal {
+	return nil
+}
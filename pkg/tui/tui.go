@@ -0,0 +1,176 @@
+// Package tui implements 'lcg tui', an interactive terminal browser for the
+// commit history with a scrollable commit list, a code preview, buffer
+// filtering and checkout, tag and replay actions.
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	enterAltScreen = "\x1b[?1049h"
+	leaveAltScreen = "\x1b[?1049l"
+	hideCursor     = "\x1b[?25l"
+	showCursor     = "\x1b[?25h"
+	cursorHome     = "\x1b[H"
+	clearLine      = "\x1b[K"
+)
+
+// Run opens the history browser on tty until the user quits
+func Run(repo Repository, options Options, tty *os.File, out io.Writer) error {
+	browser, err := NewBrowser(repo, options)
+	if err != nil {
+		return err
+	}
+
+	term, err := openTerminal(tty)
+	if err != nil {
+		return err
+	}
+	defer term.restore()
+
+	fmt.Fprint(out, enterAltScreen+hideCursor)
+	defer fmt.Fprint(out, showCursor+leaveAltScreen)
+
+	resize := func() {
+		if width, height, err := term.size(); err == nil {
+			browser.Resize(width, height)
+		}
+	}
+	resize()
+
+	keys := make(chan string)
+	go readKeys(tty, keys)
+	resized := resizeSignals()
+
+	for {
+		draw(out, browser.Render())
+
+		var tick <-chan time.Time
+		var timer *time.Timer
+		if delay, ok := browser.ReplayDelay(); ok {
+			timer = time.NewTimer(delay)
+			tick = timer.C
+		}
+
+		select {
+		case key, ok := <-keys:
+			if !ok || browser.HandleKey(key) {
+				return nil
+			}
+		case <-tick:
+			browser.AdvanceReplay()
+		case <-resized:
+			resize()
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// draw repaints the screen from the top left corner
+func draw(out io.Writer, lines []string) {
+	var screen strings.Builder
+	screen.WriteString(cursorHome)
+	for i, line := range lines {
+		if i > 0 {
+			screen.WriteString("\r\n")
+		}
+		screen.WriteString(line)
+		screen.WriteString(clearLine)
+	}
+	fmt.Fprint(out, screen.String())
+}
+
+// readKeys sends key names read from in until it fails
+func readKeys(in io.Reader, keys chan<- string) {
+	buf := make([]byte, 64)
+	for {
+		n, err := in.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		for _, key := range ParseKeys(buf[:n]) {
+			keys <- key
+		}
+	}
+}
+
+// ParseKeys converts raw terminal input into the key names used by HandleKey
+func ParseKeys(data []byte) []string {
+	var keys []string
+
+	for len(data) > 0 {
+		switch {
+		case data[0] == 0x1b && len(data) >= 3 && (data[1] == '[' || data[1] == 'O'):
+			key, size := parseEscape(data)
+			if key != "" {
+				keys = append(keys, key)
+			}
+			data = data[size:]
+			continue
+		case data[0] == 0x1b:
+			keys = append(keys, "esc")
+		case data[0] == '\r' || data[0] == '\n':
+			keys = append(keys, "enter")
+		case data[0] == 0x7f || data[0] == 0x08:
+			keys = append(keys, "backspace")
+		case data[0] == 0x03:
+			keys = append(keys, "ctrl-c")
+		case data[0] < ' ':
+			// Other control keys are ignored
+		default:
+			r, size := utf8.DecodeRune(data)
+			keys = append(keys, string(r))
+			data = data[size:]
+			continue
+		}
+		data = data[1:]
+	}
+
+	return keys
+}
+
+// parseEscape decodes an escape sequence at the start of data, returning its
+// key name (empty when unknown) and length
+func parseEscape(data []byte) (string, int) {
+	switch data[2] {
+	case 'A':
+		return "up", 3
+	case 'B':
+		return "down", 3
+	case 'H':
+		return "home", 3
+	case 'F':
+		return "end", 3
+	}
+
+	// Sequences like ESC [ 5 ~ end with a tilde
+	end := 2
+	for end < len(data) && data[end] >= '0' && data[end] <= '9' {
+		end++
+	}
+	if end >= len(data) || data[end] != '~' {
+		return "", min(end+1, len(data))
+	}
+
+	switch string(data[2:end]) {
+	case "1", "7":
+		return "home", end + 1
+	case "4", "8":
+		return "end", end + 1
+	case "5":
+		return "pgup", end + 1
+	case "6":
+		return "pgdown", end + 1
+	}
+	return "", end + 1
+}
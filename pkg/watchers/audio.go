@@ -0,0 +1,123 @@
+package watchers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/osc"
+)
+
+// An external analyzer listening to the performance can send its features to
+// the control port, one message per analysis window:
+//
+//	/lcg/audio <rms> [onset density] [spectral centroid]
+//
+// Each commit is tagged with the window received nearest to its execution.
+const ControlAudio = "/lcg/audio"
+
+const (
+	// audioRetention is how long windows are kept for executions to match
+	audioRetention = 30 * time.Second
+
+	// audioMaxDistance is how far from an execution a window may be; further
+	// away the analyzer has stopped and the features say nothing about it
+	audioMaxDistance = 2 * time.Second
+)
+
+// audioWindows holds the recent windows of an analyzer, oldest first
+type audioWindows struct {
+	mutex   sync.Mutex
+	windows []core.AudioFeatures
+}
+
+// add records a window and forgets those past retention
+func (a *audioWindows) add(features core.AudioFeatures) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.windows = append(a.windows, features)
+	expired := 0
+	for expired < len(a.windows) && features.Time.Sub(a.windows[expired].Time) > audioRetention {
+		expired++
+	}
+	a.windows = a.windows[expired:]
+}
+
+// nearest returns the window closest to t, or nil when none is close enough
+func (a *audioWindows) nearest(t time.Time) *core.AudioFeatures {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var best *core.AudioFeatures
+	var bestDistance time.Duration
+	for i := range a.windows {
+		distance := a.windows[i].Time.Sub(t)
+		if distance < 0 {
+			distance = -distance
+		}
+		if distance <= audioMaxDistance && (best == nil || distance < bestDistance) {
+			features := a.windows[i]
+			best, bestDistance = &features, distance
+		}
+	}
+	return best
+}
+
+// RecordAudio keeps the features of an analysis window for tagging commits
+func (ws *WatcherService) RecordAudio(message osc.Message) (*core.AudioFeatures, error) {
+	values, err := audioValues(message)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 || len(values) > 3 {
+		return nil, fmt.Errorf("%s takes rms [onset density] [spectral centroid], got %d values", ControlAudio, len(values))
+	}
+	for _, value := range values {
+		if value < 0 {
+			return nil, fmt.Errorf("%s values can't be negative", ControlAudio)
+		}
+	}
+
+	features := core.AudioFeatures{Time: time.Now(), RMS: values[0]}
+	if len(values) > 1 {
+		features.OnsetDensity = values[1]
+	}
+	if len(values) > 2 {
+		features.SpectralCentroid = values[2]
+	}
+	ws.audio.add(features)
+	return &features, nil
+}
+
+// audioValues reads the numbers of an audio message, sent as OSC numbers or
+// as one line of text
+func audioValues(message osc.Message) ([]float64, error) {
+	var values []float64
+	for _, arg := range message.Args {
+		switch v := arg.(type) {
+		case float32:
+			values = append(values, float64(v))
+		case float64:
+			values = append(values, v)
+		case int32:
+			values = append(values, float64(v))
+		case int64:
+			values = append(values, float64(v))
+		case string:
+			for _, field := range strings.Fields(v) {
+				value, err := strconv.ParseFloat(field, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid %s value %q", ControlAudio, field)
+				}
+				values = append(values, value)
+			}
+		default:
+			return nil, fmt.Errorf("invalid %s argument %v", ControlAudio, arg)
+		}
+	}
+	return values, nil
+}
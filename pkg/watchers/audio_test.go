@@ -0,0 +1,87 @@
+package watchers
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/osc"
+)
+
+func TestAudioWindowsNearest(t *testing.T) {
+	start := time.Now()
+	var audio audioWindows
+	audio.add(core.AudioFeatures{Time: start, RMS: 0.1})
+	audio.add(core.AudioFeatures{Time: start.Add(time.Second), RMS: 0.5})
+	audio.add(core.AudioFeatures{Time: start.Add(2 * time.Second), RMS: 0.9})
+
+	if features := audio.nearest(start.Add(1200 * time.Millisecond)); features == nil || features.RMS != 0.5 {
+		t.Errorf("Expected the window at 1s, got %+v", features)
+	}
+	if features := audio.nearest(start.Add(10 * time.Second)); features != nil {
+		t.Errorf("Expected no window 8s after the analyzer stopped, got %+v", features)
+	}
+
+	// Old windows are forgotten
+	audio.add(core.AudioFeatures{Time: start.Add(time.Minute), RMS: 0.2})
+	if len(audio.windows) != 1 {
+		t.Errorf("Expected 1 window after retention, got %d", len(audio.windows))
+	}
+}
+
+func TestRecordAudio(t *testing.T) {
+	service, configDir := createTestWatcherService(t)
+	defer os.RemoveAll(configDir)
+	defer os.RemoveAll(service.repository.GetPath())
+
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+
+	// Sent from a shell, the values arrive as one line of text
+	messages, _, err := parseControlPacket([]byte("/lcg/audio 0.8 4 2200\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse audio message: %v", err)
+	}
+	if _, err := service.HandleControl(messages[0]); err != nil {
+		t.Fatalf("Failed to record audio: %v", err)
+	}
+
+	features, err := service.RecordAudio(osc.Message{Address: ControlAudio, Args: []interface{}{float32(0)}})
+	if err != nil || features.RMS != 0 {
+		t.Errorf("Expected a silent window, got %+v (%v)", features, err)
+	}
+
+	for _, args := range [][]interface{}{nil, {"loud"}, {float32(-1)}, {float32(1), float32(2), float32(3), float32(4)}} {
+		if _, err := service.RecordAudio(osc.Message{Address: ControlAudio, Args: args}); err == nil {
+			t.Errorf("Expected %v to be rejected", args)
+		}
+	}
+
+	// Drop the silent window so the commit is tagged with the loud one
+	service.audio.windows = service.audio.windows[:1]
+	service.handleExecutionEvent(ExecutionEvent{
+		Timestamp: time.Now(),
+		Content:   "d1 $ s \"bd*4\"",
+		Buffer:    "d1",
+		Language:  "tidal",
+		Success:   true,
+	})
+
+	commits, err := service.repository.LogWithFilter(core.LogFilter{MinRMS: 0.5}, 10)
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	if len(commits) != 1 {
+		t.Fatalf("Expected 1 loud commit, got %d", len(commits))
+	}
+	audio := commits[0].Metadata.Audio
+	if audio == nil || audio.RMS != 0.8 || audio.OnsetDensity != 4 || audio.SpectralCentroid != 2200 {
+		t.Errorf("Expected the analyzer's features, got %+v", audio)
+	}
+
+	if commits, _ := service.repository.LogWithFilter(core.LogFilter{MinRMS: 0.9}, 10); len(commits) != 0 {
+		t.Errorf("Expected no commits louder than 0.9, got %d", len(commits))
+	}
+}
@@ -0,0 +1,134 @@
+package watchers
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// BatchConfig makes the service commit executions in batches instead of one
+// by one, for very dense sets: each buffer's latest execution is committed
+// every Interval, or when 'lcg flush' asks for it
+type BatchConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Interval string `json:"interval,omitempty"` // Go duration, e.g. 30s; empty or 0 waits for a flush
+}
+
+// parseBatchInterval returns the interval between batches, 0 when batches
+// are only committed on a flush
+func parseBatchInterval(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < 0 {
+		return 0, fmt.Errorf("invalid interval %q", value)
+	}
+	return interval, nil
+}
+
+// batchedBuffer is a buffer's latest execution in the batch and how many
+// executions it stands for
+type batchedBuffer struct {
+	event ExecutionEvent
+	count int
+}
+
+// batch holds executions until they are committed; it is used with
+// ws.dedup locked
+type batch struct {
+	enabled  bool
+	interval time.Duration
+	buffers  map[string]*batchedBuffer
+	order    []string // buffers in the order of their first execution
+}
+
+// add puts an execution in the batch, replacing its buffer's previous one
+func (b *batch) add(event ExecutionEvent) {
+	if b.buffers == nil {
+		b.buffers = make(map[string]*batchedBuffer)
+	}
+	batched := b.buffers[event.Buffer]
+	if batched == nil {
+		batched = &batchedBuffer{}
+		b.buffers[event.Buffer] = batched
+		b.order = append(b.order, event.Buffer)
+	}
+	batched.event = event
+	batched.count++
+}
+
+// replace updates a batched execution with its merged duplicates
+func (b *batch) replace(event ExecutionEvent) {
+	batched := b.buffers[event.Buffer]
+	if batched != nil && fingerprintOf(batched.event) == fingerprintOf(event) {
+		batched.event = event
+	}
+}
+
+// take empties the batch and returns each buffer's latest execution, in
+// the order of their first execution, with the number of executions it
+// collapsed
+func (b *batch) take() []ExecutionEvent {
+	events := make([]ExecutionEvent, 0, len(b.order))
+	for _, buffer := range b.order {
+		batched := b.buffers[buffer]
+		event := batched.event
+		event.Collapsed += batched.count - 1
+		events = append(events, event)
+	}
+	b.buffers = nil
+	b.order = nil
+	return events
+}
+
+// Flush commits the executions waiting in the batch, one commit per buffer,
+// and returns how many buffers it committed
+func (ws *WatcherService) Flush() int {
+	ws.dedup.Lock()
+	defer ws.dedup.Unlock()
+
+	events := ws.batch.take()
+	for _, event := range events {
+		ws.commitExecution(event)
+	}
+	if len(events) > 0 {
+		log.Printf("Batch of %d buffers committed", len(events))
+	}
+	return len(events)
+}
+
+// startBatch commits the batch every interval while the service runs;
+// called with ws.mutex held
+func (ws *WatcherService) startBatch() {
+	if !ws.batch.enabled || ws.batch.interval <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	ws.batchStop = stop
+	go ws.batchLoop(ws.batch.interval, stop)
+}
+
+// stopBatch stops committing the batch on a timer; called with ws.mutex
+// held
+func (ws *WatcherService) stopBatch() {
+	if ws.batchStop != nil {
+		close(ws.batchStop)
+		ws.batchStop = nil
+	}
+}
+
+// batchLoop commits the batch every interval until stop is closed
+func (ws *WatcherService) batchLoop(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ws.Flush()
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package watchers
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/osc"
+)
+
+func TestWatcherServiceBatch(t *testing.T) {
+	service, tempDir := createTestWatcherService(t)
+	defer os.RemoveAll(tempDir)
+
+	if err := service.configManager.LoadConfig(); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	config := service.configManager.GetConfig()
+	config.Batch = BatchConfig{Enabled: true}
+	service.configManager.UpdateConfig(config)
+	if err := service.configManager.SaveConfig(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+
+	for _, execution := range []struct{ buffer, content, watcher string }{
+		{"d1", `d1 $ s "bd"`, "tidal-ghci"},
+		{"d2", `d2 $ s "hh*8"`, "tidal-ghci"},
+		{"d1", `d1 $ s "bd*2"`, "tidal-ghci"},
+		{"d1", `d1 $ s "bd*2"`, "emacs"}, // a duplicate isn't another execution
+		{"d1", `d1 $ s "bd*4"`, "tidal-ghci"},
+	} {
+		service.handleExecutionEvent(ExecutionEvent{Timestamp: time.Now(), Content: execution.content,
+			Buffer: execution.buffer, Language: "tidal", Success: true, Watchers: []string{execution.watcher}})
+	}
+
+	if commits, _ := service.repository.History(); len(commits) != 0 {
+		t.Fatalf("Expected executions to wait for the batch, got %d commits", len(commits))
+	}
+
+	reply, err := service.HandleControl(osc.Message{Address: ControlFlush})
+	if err != nil || reply != "flushed 2 buffers" {
+		t.Fatalf("Expected 2 buffers flushed, got %q (%v)", reply, err)
+	}
+
+	commits, err := service.repository.History()
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("Expected one commit per buffer, got %d", len(commits))
+	}
+	if commits[0].Content != `d1 $ s "bd*4"` || commits[0].Metadata.Collapsed != 2 {
+		t.Errorf("Expected d1's latest of 3 executions first, got %q (%d collapsed)", commits[0].Content, commits[0].Metadata.Collapsed)
+	}
+	if commits[1].Metadata.Buffer != "d2" || commits[1].Metadata.Collapsed != 0 {
+		t.Errorf("Expected d2 alone, got %s (%d collapsed)", commits[1].Metadata.Buffer, commits[1].Metadata.Collapsed)
+	}
+
+	if flushed := service.Flush(); flushed != 0 {
+		t.Errorf("Expected an empty batch after a flush, got %d buffers", flushed)
+	}
+}
@@ -0,0 +1,77 @@
+package browser
+
+import "fmt"
+
+// DefaultBridgePort is the TCP port of the generic browser bridge
+const DefaultBridgePort = 6064
+
+// BridgeSnippetPath is where the bridge serves its snippet
+const BridgeSnippetPath = "/bridge.js"
+
+// bridgeSnippet suits editors without a dedicated watcher, e.g. Gibber: it
+// POSTs the selection or, without one, the current line on Ctrl+Enter and
+// the whole editor on Ctrl+Shift+Enter. Code is read from a CodeMirror 5 or
+// 6 editor, or the focused textarea. window.lcg(code, buffer) sends code
+// from anywhere else, such as the tool's own evaluation function.
+const bridgeSnippet = `// livecodegit: sends evaluations to 'lcg watch'
+(() => {
+  const url = "http://127.0.0.1:%d%s";
+  const send = (content, buffer) => {
+    if (!content || !content.trim()) return;
+    fetch(url, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ content: content, buffer: buffer || location.hostname || "browser" }),
+    }).catch((error) => console.warn("lcg: " + error));
+  };
+  window.lcg = send;
+
+  const editor = () => {
+    const cm5 = document.querySelector(".CodeMirror");
+    if (cm5 && cm5.CodeMirror) {
+      const cm = cm5.CodeMirror;
+      return { all: cm.getValue(), selection: cm.getSelection(), line: cm.getLine(cm.getCursor().line) };
+    }
+    const cm6 = document.querySelector(".cm-content");
+    if (cm6 && cm6.cmView) {
+      const state = cm6.cmView.view.state;
+      const range = state.selection.main;
+      return { all: state.doc.toString(), selection: state.sliceDoc(range.from, range.to), line: state.doc.lineAt(range.head).text };
+    }
+    const area = document.activeElement;
+    if (area && area.tagName === "TEXTAREA") {
+      const before = area.value.slice(0, area.selectionStart);
+      const line = area.value.split("\n")[before.split("\n").length - 1];
+      return { all: area.value, selection: area.value.slice(area.selectionStart, area.selectionEnd), line: line };
+    }
+    return null;
+  };
+
+  document.addEventListener("keydown", (event) => {
+    if (event.key !== "Enter" || !(event.ctrlKey || event.metaKey)) return;
+    const current = editor();
+    if (!current) return;
+    send(event.shiftKey ? current.all : current.selection || current.line);
+  }, true);
+  console.log("lcg: sending evaluations to " + url);
+})();
+`
+
+// BridgeSnippet returns the generic snippet posting to the given port
+func BridgeSnippet(port int) string {
+	return fmt.Sprintf(bridgeSnippet, port, EvalPath)
+}
+
+// NewBridge creates a watcher for any browser tool, committing its
+// evaluations with the given language and environment, from pages on this
+// machine or the given origins
+func NewBridge(language, environment string, port int, origins []string) *Watcher {
+	return NewWatcher(Editor{
+		Name:        "Browser bridge",
+		Language:    language,
+		Environment: environment,
+		PortOption:  "http_port",
+		SnippetPath: BridgeSnippetPath,
+		Snippet:     BridgeSnippet,
+	}, port, origins)
+}
@@ -0,0 +1,41 @@
+package browser
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// allowOrigin reports whether a request with the given Origin header may
+// send evaluations: requests without one don't come from a web page, pages
+// served from this machine are trusted, and the editor's sites and the
+// configured origins are listed
+func (w *Watcher) allowOrigin(origin string) bool {
+	if origin == "" || w.origins[origin] {
+		return true
+	}
+
+	parsed, err := url.Parse(origin)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return false
+	}
+	host := parsed.Hostname()
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// originSet normalizes a list of origins, as in the origins option
+func originSet(lists ...[]string) map[string]bool {
+	set := make(map[string]bool)
+	for _, origins := range lists {
+		for _, origin := range origins {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				set[strings.TrimSuffix(origin, "/")] = true
+			}
+		}
+	}
+	return set
+}
@@ -0,0 +1,251 @@
+// Package browser receives evaluations from live coding editors running in
+// a web browser: a snippet loaded into the editor sends each evaluated block
+// over a WebSocket, or POSTs it, to the watcher, which also serves the
+// snippet. Only pages from this machine, the editor's own sites and the
+// origins configured for the watcher may send evaluations.
+package browser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/livecodegit/pkg/watchers/common"
+	"github.com/livecodegit/pkg/watchers/external"
+)
+
+// EvalPath is where snippets that can't keep a WebSocket open POST each
+// evaluation
+const EvalPath = "/eval"
+
+// Editor describes a browser editor the watcher serves
+type Editor struct {
+	Name        string // for messages, e.g. "Hydra"
+	Language    string
+	Environment string
+	PortOption  string // the watcher option holding the port
+
+	// Sites serving the editor, allowed to send evaluations besides pages
+	// from this machine
+	Origins []string
+
+	// The snippet served at SnippetPath, connecting back to the port
+	SnippetPath string
+	Snippet     func(port int) string
+
+	// Parse reads one message from the snippet; nil reads an execution
+	// event as exec watchers print them
+	Parse func(message []byte, config common.WatcherConfig) (common.ExecutionEvent, error)
+}
+
+// Watcher receives the evaluations of an editor's browser tabs
+type Watcher struct {
+	editor   Editor
+	config   common.WatcherConfig
+	port     int
+	origins  map[string]bool
+	server   *http.Server
+	conns    map[*wsConn]bool
+	running  bool
+	mutex    sync.RWMutex
+	callback func(common.ExecutionEvent)
+}
+
+// NewWatcher creates a watcher for the editor serving on the given port,
+// accepting evaluations from the editor's origins and the given ones too
+func NewWatcher(editor Editor, port int, origins []string) *Watcher {
+	if editor.Parse == nil {
+		editor.Parse = external.ParseEvent
+	}
+	return &Watcher{
+		editor: editor,
+		config: common.WatcherConfig{
+			Language:    editor.Language,
+			Environment: editor.Environment,
+			Enabled:     true,
+			Options: map[string]string{
+				editor.PortOption: strconv.Itoa(port),
+			},
+		},
+		port:    port,
+		origins: originSet(editor.Origins, origins),
+	}
+}
+
+// Start serves the snippet and the WebSocket on localhost
+func (w *Watcher) Start(callback func(common.ExecutionEvent)) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.running {
+		return fmt.Errorf("%s watcher is already running", w.editor.Name)
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", w.port))
+	if err != nil {
+		return fmt.Errorf("failed to listen on TCP port %d: %w", w.port, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(w.editor.SnippetPath, w.serveSnippet)
+	mux.HandleFunc(EvalPath, w.serveEval)
+	mux.HandleFunc("/", w.serveWebSocket)
+
+	w.server = &http.Server{Handler: mux}
+	w.conns = make(map[*wsConn]bool)
+	w.callback = callback
+	w.running = true
+
+	go w.server.Serve(listener)
+
+	return nil
+}
+
+// Stop closes the server and the browsers' connections
+func (w *Watcher) Stop() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.running {
+		return nil
+	}
+
+	w.running = false
+	for conn := range w.conns {
+		conn.Close()
+	}
+	return w.server.Close()
+}
+
+// IsRunning returns true if the watcher is active
+func (w *Watcher) IsRunning() bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.running
+}
+
+// GetConfig returns the watcher configuration
+func (w *Watcher) GetConfig() common.WatcherConfig {
+	return w.config
+}
+
+// GetLanguage returns the editor's language
+func (w *Watcher) GetLanguage() string {
+	return w.editor.Language
+}
+
+// GetEnvironment returns the editor's environment
+func (w *Watcher) GetEnvironment() string {
+	return w.editor.Environment
+}
+
+// serveSnippet serves the snippet for the editor to load
+func (w *Watcher) serveSnippet(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	rw.Header().Set("Access-Control-Allow-Origin", "*")
+	fmt.Fprint(rw, w.editor.Snippet(w.port))
+}
+
+// serveEval receives one evaluation per request. Allowed pages served from
+// the web may POST to localhost once the preflight request allows it, which
+// for Chrome includes private network access; others are refused.
+func (w *Watcher) serveEval(rw http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if !w.allowOrigin(origin) {
+		http.Error(rw, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	if origin != "" {
+		rw.Header().Set("Access-Control-Allow-Origin", origin)
+		rw.Header().Set("Vary", "Origin")
+	}
+	switch r.Method {
+	case http.MethodOptions:
+		rw.Header().Set("Access-Control-Allow-Methods", "POST")
+		rw.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		rw.Header().Set("Access-Control-Allow-Private-Network", "true")
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	case http.MethodPost:
+	default:
+		http.Error(rw, "POST an execution event", http.StatusMethodNotAllowed)
+		return
+	}
+
+	message, err := io.ReadAll(io.LimitReader(r.Body, maxMessageSize+1))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(message) > maxMessageSize {
+		http.Error(rw, "evaluation too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	event, err := w.editor.Parse(message, w.config)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if w.callback != nil {
+		w.callback(event)
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// serveWebSocket reads the evaluations of one browser tab until it closes.
+// Browsers don't apply CORS to WebSockets, so the origin is checked here.
+func (w *Watcher) serveWebSocket(rw http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(rw, r)
+		return
+	}
+	if !w.allowOrigin(r.Header.Get("Origin")) {
+		http.Error(rw, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	conn, err := upgrade(rw, r)
+	if err != nil {
+		return
+	}
+
+	w.mutex.Lock()
+	if !w.running {
+		w.mutex.Unlock()
+		conn.Close()
+		return
+	}
+	w.conns[conn] = true
+	w.mutex.Unlock()
+
+	defer func() {
+		w.mutex.Lock()
+		delete(w.conns, conn)
+		w.mutex.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		message, err := conn.ReadMessage()
+		if err != nil {
+			if !errors.Is(err, errClosed) && w.IsRunning() {
+				fmt.Printf("%s connection closed: %v\n", w.editor.Name, err)
+			}
+			return
+		}
+
+		event, err := w.editor.Parse(message, w.config)
+		if err != nil {
+			fmt.Printf("Ignoring %s message: %v\n", w.editor.Name, err)
+			continue
+		}
+		if w.callback != nil {
+			w.callback(event)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package browser
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+// testWatcher serves an editor like Hydra's
+func testWatcher(port int) *Watcher {
+	return NewWatcher(Editor{
+		Name:        "Test",
+		Language:    "hydra",
+		Environment: "hydra",
+		PortOption:  "ws_port",
+		SnippetPath: "/test.js",
+		Snippet:     func(port int) string { return fmt.Sprintf("new WebSocket(\"ws://127.0.0.1:%d/\")", port) },
+	}, port, nil)
+}
+
+// freePort returns a TCP port nothing listens on
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// dial opens a WebSocket connection the way a browser does
+func dial(t *testing.T, port int) (net.Conn, *bufio.Reader) {
+	conn, reader, response := handshake(t, port, "http://localhost:8000")
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", response.StatusCode)
+	}
+	// The example of RFC 6455
+	if accept := response.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Expected the RFC's accept key, got %s", accept)
+	}
+	return conn, reader
+}
+
+// handshake sends a WebSocket handshake from a page of the given origin
+func handshake(t *testing.T, port int, origin string) (net.Conn, *bufio.Reader, *http.Response) {
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: localhost\r\nOrigin: %s\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n", origin, key)
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("Failed to read handshake: %v", err)
+	}
+	return conn, reader, response
+}
+
+// send writes a masked frame, as browsers must
+func send(t *testing.T, conn net.Conn, fin bool, opcode byte, payload []byte) {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	if len(payload) < 126 {
+		frame = append(frame, 0x80|byte(len(payload)))
+	} else {
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatalf("Failed to send frame: %v", err)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	port := freePort(t)
+	watcher := testWatcher(port)
+
+	events := make(chan common.ExecutionEvent, 10)
+	if err := watcher.Start(func(event common.ExecutionEvent) { events <- event }); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	// The snippet is served for loadScript, pointing back at the watcher
+	response, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/test.js", port))
+	if err != nil {
+		t.Fatalf("Failed to get snippet: %v", err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if !strings.Contains(string(body), fmt.Sprintf("ws://127.0.0.1:%d/", port)) {
+		t.Errorf("Expected the snippet to connect to port %d", port)
+	}
+
+	conn, reader := dial(t, port)
+	defer conn.Close()
+
+	send(t, conn, true, opText, []byte(`{"content":"osc(10).out()","buffer":"hydra"}`))
+	select {
+	case event := <-events:
+		if event.Content != "osc(10).out()" || event.Language != "hydra" || event.Environment != "hydra" || !event.Success {
+			t.Errorf("Expected a Hydra execution, got %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected an execution")
+	}
+
+	// A ping is answered, and a message may come in fragments
+	send(t, conn, true, opPing, []byte("hi"))
+	block := `{"content":"` + strings.Repeat("noise(3)", 20) + `.out()"}`
+	send(t, conn, false, opText, []byte(block[:100]))
+	send(t, conn, true, opContinuation, []byte(block[100:]))
+
+	pong := make([]byte, 4)
+	if _, err := io.ReadFull(reader, pong); err != nil || pong[0] != 0x80|opPong || string(pong[2:]) != "hi" {
+		t.Errorf("Expected a pong, got %v (%v)", pong, err)
+	}
+	select {
+	case event := <-events:
+		if !strings.HasPrefix(event.Content, "noise(3)") || event.Buffer != "unknown" {
+			t.Errorf("Expected the fragmented block, got %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the fragmented execution")
+	}
+
+	// Stopping closes the browser's connection
+	watcher.Stop()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := reader.ReadByte(); err == nil {
+		t.Errorf("Expected the connection to be closed")
+	}
+}
+
+func TestUpgradeRefusesForeignOrigins(t *testing.T) {
+	port := freePort(t)
+	watcher := NewWatcher(Editor{
+		Name:        "Test",
+		Language:    "hydra",
+		Environment: "hydra",
+		PortOption:  "ws_port",
+		Origins:     []string{"https://hydra.example"},
+		SnippetPath: "/test.js",
+		Snippet:     func(port int) string { return "" },
+	}, port, nil)
+	if err := watcher.Start(func(common.ExecutionEvent) {}); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	for origin, status := range map[string]int{
+		"https://evil.example":  http.StatusForbidden,
+		"null":                  http.StatusForbidden,
+		"https://hydra.example": http.StatusSwitchingProtocols,
+		"http://[::1]:3000":     http.StatusSwitchingProtocols,
+	} {
+		conn, _, response := handshake(t, port, origin)
+		conn.Close()
+		if response.StatusCode != status {
+			t.Errorf("Expected %d for a page from %s, got %d", status, origin, response.StatusCode)
+		}
+	}
+}
+
+func TestUpgradeRefusesPlainRequests(t *testing.T) {
+	port := freePort(t)
+	watcher := testWatcher(port)
+	if err := watcher.Start(func(common.ExecutionEvent) {}); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	response, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/", port))
+	if err != nil {
+		t.Fatalf("Failed to get: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a handshake, got %d", response.StatusCode)
+	}
+}
+
+func TestBridgeEval(t *testing.T) {
+	port := freePort(t)
+	watcher := NewBridge("gibber", "browser", port, []string{"https://gibber.cc/"})
+
+	events := make(chan common.ExecutionEvent, 10)
+	if err := watcher.Start(func(event common.ExecutionEvent) { events <- event }); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	url := fmt.Sprintf("http://127.0.0.1:%d%s", port, EvalPath)
+
+	// The preflight of an allowed page on the web allows the POST
+	request, _ := http.NewRequest(http.MethodOptions, url, nil)
+	request.Header.Set("Origin", "https://gibber.cc")
+	request.Header.Set("Access-Control-Request-Private-Network", "true")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Failed to send preflight: %v", err)
+	}
+	response.Body.Close()
+	if response.Header.Get("Access-Control-Allow-Private-Network") != "true" || response.Header.Get("Access-Control-Allow-Origin") != "https://gibber.cc" {
+		t.Errorf("Expected the preflight to allow the page, got %v", response.Header)
+	}
+
+	// Any other site is refused, before and instead of the POST
+	for _, origin := range []string{"https://evil.example", "null", "http://127.0.0.1.evil.example"} {
+		for _, method := range []string{http.MethodOptions, http.MethodPost} {
+			request, _ := http.NewRequest(method, url, strings.NewReader(`{"content":"hush"}`))
+			request.Header.Set("Origin", origin)
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			response.Body.Close()
+			if response.StatusCode != http.StatusForbidden || response.Header.Get("Access-Control-Allow-Origin") != "" {
+				t.Errorf("Expected %s from %s refused, got %d", method, origin, response.StatusCode)
+			}
+		}
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("Expected no execution from a refused origin, got %+v", event)
+	default:
+	}
+
+	// A page served from this machine may post
+	request, _ = http.NewRequest(http.MethodPost, url, strings.NewReader(`{"content":"Synth('bleep').note(0)"}`))
+	request.Header.Set("Origin", "http://localhost:8080")
+	response, err = http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Failed to post: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected a local page allowed, got %d", response.StatusCode)
+	}
+	<-events
+
+	response, err = http.Post(url, "application/json", strings.NewReader(`{"content":"Kick('kick').trigger.seq(1, 1/4)","buffer":"gibber.cc"}`))
+	if err != nil {
+		t.Fatalf("Failed to post: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", response.StatusCode)
+	}
+	select {
+	case event := <-events:
+		if event.Language != "gibber" || event.Environment != "browser" || event.Buffer != "gibber.cc" {
+			t.Errorf("Expected a gibber execution, got %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected an execution")
+	}
+
+	response, err = http.Post(url, "application/json", strings.NewReader(`{"buffer":"x"}`))
+	if err != nil {
+		t.Fatalf("Failed to post: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an event without content, got %d", response.StatusCode)
+	}
+
+	// The bridge serves its snippet, posting back to it
+	response, err = http.Get(fmt.Sprintf("http://127.0.0.1:%d%s", port, BridgeSnippetPath))
+	if err != nil {
+		t.Fatalf("Failed to get snippet: %v", err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if !strings.Contains(string(body), url) {
+		t.Errorf("Expected the snippet to post to %s", url)
+	}
+}
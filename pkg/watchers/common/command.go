@@ -0,0 +1,39 @@
+package common
+
+import "strings"
+
+// CommandFields splits a command line into its program and arguments.
+// Arguments holding spaces are quoted with single or double quotes.
+func CommandFields(command string) []string {
+	var fields []string
+	var field strings.Builder
+	inField := false
+	var quote rune
+
+	for _, r := range command {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				field.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inField = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
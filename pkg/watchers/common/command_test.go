@@ -0,0 +1,23 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommandFields(t *testing.T) {
+	tests := map[string][]string{
+		"ghci":            {"ghci"},
+		"  stack   ghci ": {"stack", "ghci"},
+		`stack ghci --ghci-options "-XOverloadedStrings -v0"`: {"stack", "ghci", "--ghci-options", "-XOverloadedStrings -v0"},
+		`'/Applications/My GHC/ghci' ''`:                      {"/Applications/My GHC/ghci", ""},
+		"":                                                    nil,
+	}
+
+	for command, expected := range tests {
+		fields := CommandFields(command)
+		if strings.Join(fields, "|") != strings.Join(expected, "|") || len(fields) != len(expected) {
+			t.Errorf("Expected %q for %s, got %q", expected, command, fields)
+		}
+	}
+}
@@ -19,11 +19,30 @@ type ExecutionEvent struct {
 	// Music-specific metadata
 	BPM            float64 `json:"bpm,omitempty"`
 	BeatsFromStart int64   `json:"beats_from_start,omitempty"`
+	Phase          float64 `json:"phase,omitempty"`
 
 	// File-specific metadata
 	FilePath   string `json:"file_path,omitempty"`
 	LineNumber int    `json:"line_number,omitempty"`
 
+	// Who the execution is attributed to, from the watcher's configuration;
+	// empty means the repository's user
+	Author      string `json:"author,omitempty"`
+	AuthorEmail string `json:"author_email,omitempty"`
+
+	// Nearest window of an external audio analyzer, attached by the service
+	Audio *storage.AudioFeatures `json:"audio,omitempty"`
+
+	// What the synth played before the execution, attached by the service
+	Sound *storage.SoundActivity `json:"sound,omitempty"`
+
+	// Watchers that saw the execution, by name, attached by the service
+	Watchers []string `json:"watchers,omitempty"`
+
+	// Executions of the same buffer the service's rate limit collapsed
+	// into this one
+	Collapsed int `json:"collapsed,omitempty"`
+
 	// Environment-specific metadata
 	ProcessID int               `json:"process_id,omitempty"`
 	ExtraData map[string]string `json:"extra_data,omitempty"`
@@ -35,6 +54,16 @@ type WatcherConfig struct {
 	Environment string            `json:"environment"`
 	Enabled     bool              `json:"enabled"`
 	Options     map[string]string `json:"options"`
+
+	// Type "exec" runs Command as an external watcher program; empty means
+	// the built-in watcher of the same name
+	Type    string `json:"type,omitempty"`
+	Command string `json:"command,omitempty"`
+
+	// Author overrides the repository's user.name for this watcher's commits,
+	// e.g. when performers share a machine
+	Author      string `json:"author,omitempty"`
+	AuthorEmail string `json:"author_email,omitempty"`
 }
 
 // ExecutionWatcher defines the interface for detecting code executions
@@ -58,6 +87,66 @@ type ExecutionWatcher interface {
 	GetEnvironment() string
 }
 
+// Lifecycle event kinds
+const (
+	LifecycleBoot    = "boot"    // the watched environment finished booting, or failed to
+	LifecycleRestart = "restart" // an external watcher program exited and is started again
+)
+
+// LifecycleEvent reports a change in a watcher that isn't an execution
+type LifecycleEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	Kind      string    `json:"kind"`
+	Success   bool      `json:"success"`
+	Message   string    `json:"message"`
+}
+
+// LifecycleReporter is implemented by watchers that report lifecycle events,
+// e.g. whether the environment they start booted
+type LifecycleReporter interface {
+	// SetLifecycleCallback sets the function called for each lifecycle event;
+	// it is set before Start
+	SetLifecycleCallback(callback func(event LifecycleEvent))
+}
+
+// Tempo is where a clock stands at some instant
+type Tempo struct {
+	BPM   float64
+	Beats int64   // whole beats since the clock started
+	Phase float64 // beats into the bar, for clocks that know bars
+}
+
+// TempoSource is implemented by watchers that follow a clock, e.g. MIDI
+// clock or Ableton Link; while one is running, the service stamps every execution with its
+// tempo instead of the watcher's own estimate
+type TempoSource interface {
+	// Tempo returns the tempo at the given time, or false when the clock
+	// isn't running
+	Tempo(at time.Time) (Tempo, bool)
+}
+
+// SoundSource is implemented by watchers that see the events sent to a
+// synth, e.g. Tidal's messages to SuperDirt; while one is running, the
+// service stamps every execution with what was playing
+type SoundSource interface {
+	// Sound returns the activity over the cycles before the given time, or
+	// false when nothing played
+	Sound(at time.Time) (*storage.SoundActivity, bool)
+}
+
+// History gives watchers read access to the commits already made
+type History interface {
+	// GetCommitsByBuffer returns every commit of a buffer, oldest first
+	GetCommitsByBuffer(buffer string) ([]*storage.Commit, error)
+}
+
+// HistoryReader is implemented by watchers that answer questions from the
+// repository's history, e.g. which Tidal patterns are still playing
+type HistoryReader interface {
+	// SetHistory sets the history the watcher reads; it is set before Start
+	SetHistory(history History)
+}
+
 // ToExecutionMetadata converts an ExecutionEvent to storage.ExecutionMetadata
 func (event ExecutionEvent) ToExecutionMetadata() storage.ExecutionMetadata {
 	return storage.ExecutionMetadata{
@@ -65,8 +154,13 @@ func (event ExecutionEvent) ToExecutionMetadata() storage.ExecutionMetadata {
 		Language:       event.Language,
 		BPM:            event.BPM,
 		BeatsFromStart: event.BeatsFromStart,
+		Phase:          event.Phase,
 		Success:        event.Success,
 		ErrorMessage:   event.ErrorMessage,
 		Environment:    event.Environment,
+		Audio:          event.Audio,
+		Sound:          event.Sound,
+		Watchers:       event.Watchers,
+		Collapsed:      event.Collapsed,
 	}
 }
@@ -3,10 +3,34 @@ package watchers
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/storage"
+	"github.com/livecodegit/pkg/watchers/common"
+	"github.com/livecodegit/pkg/watchers/oscgeneric"
+	"github.com/livecodegit/pkg/watchers/tidal"
 )
 
+// RepoConfigFile is the name of a repository's own watcher configuration
+const RepoConfigFile = "watchers.json"
+
+// Watcher types run as external programs: an exec watcher prints events
+// as JSON lines, a plugin talks the plugin protocol on stdin and stdout
+const (
+	WatcherTypeExec   = "exec"
+	WatcherTypePlugin = "plugin"
+)
+
+// runsCommand returns true for watchers run as external programs
+func runsCommand(config WatcherConfig) bool {
+	return config.Type == WatcherTypeExec || config.Type == WatcherTypePlugin
+}
+
 // GlobalConfig holds configuration for all watchers
 type GlobalConfig struct {
 	Watchers        map[string]WatcherConfig `json:"watchers"`
@@ -15,6 +39,50 @@ type GlobalConfig struct {
 	CommitMessage   string                   `json:"commit_message"`
 	WorkspacePath   string                   `json:"workspace_path"`
 	LogLevel        string                   `json:"log_level"`
+
+	// ControlPort is the UDP port of the OSC control surface; 0 disables it
+	ControlPort int `json:"control_port,omitempty"`
+
+	// DedupWindow is how long after an execution the same content in the
+	// same buffer, from any watcher, is merged into its commit, e.g. 2s;
+	// empty means DefaultDedupWindow and 0 disables merging
+	DedupWindow string `json:"dedup_window,omitempty"`
+
+	// OnlyOnChange skips executions whose code is byte for byte the last
+	// code committed in their buffer
+	OnlyOnChange bool `json:"only_on_change,omitempty"`
+
+	// RateLimit throttles auto-commits per buffer
+	RateLimit RateLimitConfig `json:"rate_limit"`
+
+	// Batch commits executions in batches, one commit per buffer
+	Batch BatchConfig `json:"batch"`
+
+	// Ignore keeps executions out of auto-commits, with the rules of the
+	// repository's .livecodegitignore
+	Ignore IgnoreConfig `json:"ignore"`
+
+	// Maintenance schedules housekeeping while the service is idle
+	Maintenance MaintenanceConfig `json:"maintenance"`
+}
+
+// MaintenanceConfig schedules maintenance tasks of the watched repository.
+// Durations are Go durations, e.g. 24h.
+type MaintenanceConfig struct {
+	Enabled  bool     `json:"enabled"`
+	Tasks    []string `json:"tasks"`    // run in this order
+	Interval string   `json:"interval"` // between two runs of a task
+	Idle     string   `json:"idle"`     // without executions before tasks start
+
+	// Window limits when tasks start, e.g. 02:00-06:00; empty means any time
+	Window string `json:"window,omitempty"`
+
+	// Backups are written to BackupDir, keeping the KeepBackups newest
+	BackupDir   string `json:"backup_dir,omitempty"`
+	KeepBackups int    `json:"keep_backups,omitempty"`
+
+	// PruneAge is how long executions may wait in the inbox
+	PruneAge string `json:"prune_age,omitempty"`
 }
 
 // DefaultGlobalConfig returns a default configuration
@@ -35,8 +103,11 @@ func DefaultGlobalConfig() GlobalConfig {
 				Environment: "sonic-pi-files",
 				Enabled:     false,
 				Options: map[string]string{
-					"workspace_path": "",
-					"poll_interval":  "1s",
+					"workspace_path":   "",
+					"poll_interval":    "1s",
+					"debounce":         "200ms",
+					"include_patterns": "",
+					"exclude_patterns": "",
 				},
 			},
 			"tidal-ghci": {
@@ -44,8 +115,119 @@ func DefaultGlobalConfig() GlobalConfig {
 				Environment: "tidal-cycles",
 				Enabled:     false,
 				Options: map[string]string{
-					"ghci_command": "ghci",
-					"boot_file":    "BootTidal.hs",
+					"ghci_command":  "ghci",
+					"boot_file":     tidal.DefaultBootFile,
+					"boot_commands": "",
+					"tidal_version": "auto",
+					"boot_timeout":  tidal.DefaultBootTimeout.String(),
+					"attach":        "",
+				},
+			},
+			"tidal-hook": {
+				Language:    "tidal",
+				Environment: "tidal-hook",
+				Enabled:     false,
+				Options: map[string]string{
+					"hook_port": "6061",
+				},
+			},
+			"midi-clock": {
+				Language:    "midi",
+				Environment: "midi-clock",
+				Enabled:     false,
+				Options: map[string]string{
+					"device": "",
+				},
+			},
+			"hydra": {
+				Language:    "hydra",
+				Environment: "hydra",
+				Enabled:     false,
+				Options: map[string]string{
+					"ws_port": "6062",
+					"origins": "",
+				},
+			},
+			"strudel": {
+				Language:    "strudel",
+				Environment: "strudel",
+				Enabled:     false,
+				Options: map[string]string{
+					"ws_port": "6063",
+					"origins": "",
+				},
+			},
+			"browser": {
+				Language:    "gibber",
+				Environment: "browser",
+				Enabled:     false,
+				Options: map[string]string{
+					"http_port": "6064",
+					"origins":   "",
+				},
+			},
+			"osc-generic": {
+				Language:    "osc",
+				Environment: "osc-generic",
+				Enabled:     false,
+				Options: map[string]string{
+					"osc_port": "6065",
+					"address":  oscgeneric.DefaultAddress,
+					"args":     oscgeneric.DefaultArgs,
+					"buffer":   oscgeneric.DefaultBuffer,
+				},
+			},
+			"editor-jsonl": {
+				Language:    "tidal",
+				Environment: "neovim",
+				Enabled:     false,
+				Options: map[string]string{
+					"tcp_port": "6066",
+					"pipe":     "",
+				},
+			},
+			"editor-http": {
+				Language:    "tidal",
+				Environment: "vscode",
+				Enabled:     false,
+				Options: map[string]string{
+					"http_port": "6067",
+					"token":     "",
+					"origins":   "",
+				},
+			},
+			"emacs": {
+				Language:    "tidal",
+				Environment: "emacs",
+				Enabled:     false,
+				Options: map[string]string{
+					"tcp_port":   "6068",
+					"transcript": "",
+				},
+			},
+			"pulsar": {
+				Language:    "tidal",
+				Environment: "pulsar",
+				Enabled:     false,
+				Options: map[string]string{
+					"log_path": "",
+				},
+			},
+			"ableton-link": {
+				Language:    "link",
+				Environment: "ableton-link",
+				Enabled:     false,
+				Options: map[string]string{
+					"quantum": "4",
+				},
+			},
+			"tidal-superdirt": {
+				Language:    "tidal",
+				Environment: "superdirt",
+				Enabled:     false,
+				Options: map[string]string{
+					"osc_port": "57130",
+					"forward":  "",
 				},
 			},
 		},
@@ -54,6 +236,19 @@ func DefaultGlobalConfig() GlobalConfig {
 		CommitMessage:   "Auto-commit: {{.Language}} execution in {{.Buffer}}",
 		WorkspacePath:   "",
 		LogLevel:        "info",
+		Batch: BatchConfig{
+			Enabled:  false,
+			Interval: "30s",
+		},
+		Maintenance: MaintenanceConfig{
+			Enabled:     false,
+			Tasks:       []string{MaintenancePrune, MaintenanceGC, MaintenanceIndexSnapshot, MaintenanceFsck},
+			Interval:    "24h",
+			Idle:        "30m",
+			Window:      "02:00-06:00",
+			KeepBackups: 7,
+			PruneAge:    "720h",
+		},
 	}
 }
 
@@ -167,11 +362,28 @@ func (cm *ConfigManager) SetWatcherOption(watcherName, optionName, optionValue s
 		return fmt.Errorf("watcher '%s' not found", watcherName)
 	}
 
-	if config.Options == nil {
-		config.Options = make(map[string]string)
+	// The author and other top-level fields are set like options so
+	// 'lcg watch --set' covers them
+	switch optionName {
+	case "language":
+		config.Language = optionValue
+	case "environment":
+		config.Environment = optionValue
+	case "author":
+		config.Author = optionValue
+	case "author_email":
+		config.AuthorEmail = optionValue
+	case "type":
+		config.Type = optionValue
+	case "command":
+		config.Command = optionValue
+	default:
+		if config.Options == nil {
+			config.Options = make(map[string]string)
+		}
+		config.Options[optionName] = optionValue
 	}
 
-	config.Options[optionName] = optionValue
 	cm.config.Watchers[watcherName] = config
 
 	return nil
@@ -214,6 +426,32 @@ func (cm *ConfigManager) ValidateConfig() error {
 		return fmt.Errorf("invalid log level: %s", config.LogLevel)
 	}
 
+	if config.ControlPort < 0 || config.ControlPort > 65535 {
+		return fmt.Errorf("invalid control_port: %d", config.ControlPort)
+	}
+
+	if config.DedupWindow != "" {
+		if window, err := time.ParseDuration(config.DedupWindow); err != nil || window < 0 {
+			return fmt.Errorf("invalid dedup_window: %s", config.DedupWindow)
+		}
+	}
+
+	if _, err := parseRateLimitConfig(config.RateLimit); err != nil {
+		return fmt.Errorf("invalid rate_limit: %w", err)
+	}
+
+	if err := validateIgnoreConfig(config.Ignore); err != nil {
+		return fmt.Errorf("invalid ignore config: %w", err)
+	}
+
+	if _, err := parseBatchInterval(config.Batch.Interval); err != nil {
+		return fmt.Errorf("invalid batch config: %w", err)
+	}
+
+	if err := validateMaintenanceConfig(config.Maintenance); err != nil {
+		return fmt.Errorf("invalid maintenance config: %w", err)
+	}
+
 	// Validate watcher configurations
 	for name, watcherConfig := range config.Watchers {
 		if err := cm.validateWatcherConfig(name, watcherConfig); err != nil {
@@ -235,6 +473,17 @@ func (cm *ConfigManager) validateWatcherConfig(name string, config WatcherConfig
 		return fmt.Errorf("environment is required")
 	}
 
+	switch config.Type {
+	case "":
+	case WatcherTypeExec, WatcherTypePlugin:
+		if strings.TrimSpace(config.Command) == "" {
+			return fmt.Errorf("command is required for an %s watcher", config.Type)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown type: %s (%s or %s)", config.Type, WatcherTypeExec, WatcherTypePlugin)
+	}
+
 	// Validate specific watcher types
 	switch name {
 	case "sonicpi-osc":
@@ -243,6 +492,18 @@ func (cm *ConfigManager) validateWatcherConfig(name string, config WatcherConfig
 		return cm.validateSonicPiFilesConfig(config)
 	case "tidal-ghci":
 		return cm.validateTidalGHCiConfig(config)
+	case "tidal-hook":
+		return cm.validateTidalHookConfig(config)
+	case "ableton-link":
+		return cm.validateLinkConfig(config)
+	case "tidal-superdirt":
+		return cm.validateSuperDirtConfig(config)
+	case "osc-generic":
+		return cm.validateGenericOSCConfig(config)
+	case "hydra", "strudel", "browser", "editor-http":
+		return cm.validateBrowserConfig(config)
+	case "editor-jsonl", "emacs":
+		return cm.validateJSONLinesConfig(config)
 	}
 
 	return nil
@@ -268,17 +529,152 @@ func (cm *ConfigManager) validateSonicPiFilesConfig(config WatcherConfig) error
 		}
 	}
 
+	if intervalStr := config.Options["poll_interval"]; intervalStr != "" {
+		if interval, err := time.ParseDuration(intervalStr); err != nil || interval <= 0 {
+			return fmt.Errorf("invalid poll_interval: %s", intervalStr)
+		}
+	}
+
+	if debounceStr := config.Options["debounce"]; debounceStr != "" {
+		if debounce, err := time.ParseDuration(debounceStr); err != nil || debounce < 0 {
+			return fmt.Errorf("invalid debounce: %s", debounceStr)
+		}
+	}
+
+	for _, option := range []string{"include_patterns", "exclude_patterns"} {
+		for _, pattern := range patternList(config.Options[option]) {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid %s pattern: %s", option, pattern)
+			}
+		}
+	}
+
 	return nil
 }
 
 // validateTidalGHCiConfig validates Tidal GHCi watcher configuration
 func (cm *ConfigManager) validateTidalGHCiConfig(config WatcherConfig) error {
+	// An attached session's directory has to exist; the session file is
+	// created if missing
+	if attach := config.Options["attach"]; attach != "" {
+		if info, err := os.Stat(filepath.Dir(attach)); err != nil || !info.IsDir() {
+			return fmt.Errorf("attach directory does not exist: %s", filepath.Dir(attach))
+		}
+	}
+
 	if ghciCmd, exists := config.Options["ghci_command"]; exists {
-		if ghciCmd == "" {
+		if len(common.CommandFields(ghciCmd)) == 0 {
 			return fmt.Errorf("ghci_command cannot be empty")
 		}
 	}
 
+	// The default boot file is looked up in the working directory and
+	// Tidal's install, and is optional; one chosen by the user has to exist
+	if bootFile := config.Options["boot_file"]; bootFile != "" && bootFile != tidal.DefaultBootFile {
+		if tidal.ResolveBootFile(bootFile, "") == "" {
+			return fmt.Errorf("boot_file does not exist: %s", bootFile)
+		}
+	}
+
+	if version := config.Options["tidal_version"]; version != "" && version != "auto" {
+		if !tidal.ValidVersion(version) {
+			return fmt.Errorf("invalid tidal_version: %s (a version like 1.9 or auto)", version)
+		}
+	}
+
+	if timeout, exists := config.Options["boot_timeout"]; exists && timeout != "" {
+		if d, err := time.ParseDuration(timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid boot_timeout: %s", timeout)
+		}
+	}
+
+	return nil
+}
+
+// validateTidalHookConfig validates Tidal hook watcher configuration
+func (cm *ConfigManager) validateTidalHookConfig(config WatcherConfig) error {
+	if portStr, exists := config.Options["hook_port"]; exists {
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid hook_port: %s", portStr)
+		}
+	}
+
+	return nil
+}
+
+// validateBrowserConfig validates the configuration of watchers for browser
+// editors: Hydra, Strudel and the generic bridge
+func (cm *ConfigManager) validateBrowserConfig(config WatcherConfig) error {
+	for _, option := range []string{"ws_port", "http_port"} {
+		if portStr, exists := config.Options[option]; exists {
+			port, err := strconv.Atoi(portStr)
+			if err != nil || port <= 0 || port > 65535 {
+				return fmt.Errorf("invalid %s: %s", option, portStr)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateGenericOSCConfig validates generic OSC watcher configuration
+func (cm *ConfigManager) validateGenericOSCConfig(config WatcherConfig) error {
+	if portStr, exists := config.Options["osc_port"]; exists {
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid osc_port: %s", portStr)
+		}
+	}
+
+	_, err := oscgeneric.ParseMapping(config.Options)
+	return err
+}
+
+// validateJSONLinesConfig validates the TCP port of watchers reading JSON
+// lines from editors
+func (cm *ConfigManager) validateJSONLinesConfig(config WatcherConfig) error {
+	if config.Options["pipe"] != "" {
+		return nil
+	}
+	if portStr, exists := config.Options["tcp_port"]; exists {
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid tcp_port: %s", portStr)
+		}
+	}
+
+	return nil
+}
+
+// validateLinkConfig validates Ableton Link watcher configuration
+func (cm *ConfigManager) validateLinkConfig(config WatcherConfig) error {
+	if value, exists := config.Options["quantum"]; exists && value != "" {
+		quantum, err := strconv.ParseFloat(value, 64)
+		if err != nil || quantum <= 0 {
+			return fmt.Errorf("invalid quantum: %s", value)
+		}
+	}
+
+	return nil
+}
+
+// validateSuperDirtConfig validates the port and the SuperDirt address of
+// the SuperDirt watcher
+func (cm *ConfigManager) validateSuperDirtConfig(config WatcherConfig) error {
+	if portStr, exists := config.Options["osc_port"]; exists && portStr != "" {
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid osc_port: %s", portStr)
+		}
+	}
+
+	if forward := config.Options["forward"]; forward != "" {
+		if _, err := net.ResolveUDPAddr("udp", forward); err != nil {
+			return fmt.Errorf("invalid forward address: %s", forward)
+		}
+	}
+
 	return nil
 }
 
@@ -291,3 +687,41 @@ func GetDefaultConfigPath() string {
 
 	return filepath.Join(homeDir, ".livecodegit", "watchers.json")
 }
+
+// GetRepoConfigPath returns the path of a repository's own watcher configuration
+func GetRepoConfigPath(repoPath string) string {
+	return filepath.Join(repoPath, storage.RepoDir, RepoConfigFile)
+}
+
+// ResolveConfigPath returns the repository's own watcher configuration if it
+// has one, otherwise the global configuration
+func ResolveConfigPath(repoPath string) string {
+	repoConfigPath := GetRepoConfigPath(repoPath)
+	if _, err := os.Stat(repoConfigPath); err == nil {
+		return repoConfigPath
+	}
+	return GetDefaultConfigPath()
+}
+
+// InitRepoConfig creates a repository's own watcher configuration, starting
+// from the global configuration, and returns its path. An existing
+// repository configuration is left untouched.
+func InitRepoConfig(repoPath string) (string, error) {
+	repoConfigPath := GetRepoConfigPath(repoPath)
+	if _, err := os.Stat(repoConfigPath); err == nil {
+		return repoConfigPath, nil
+	}
+
+	global := NewConfigManager(GetDefaultConfigPath())
+	if err := global.LoadConfig(); err != nil {
+		return "", err
+	}
+
+	local := NewConfigManager(repoConfigPath)
+	local.UpdateConfig(global.GetConfig())
+	if err := local.SaveConfig(); err != nil {
+		return "", err
+	}
+
+	return repoConfigPath, nil
+}
@@ -31,7 +31,7 @@ func TestDefaultGlobalConfig(t *testing.T) {
 	}
 
 	// Check that default watchers are configured
-	expectedWatchers := []string{"sonicpi-osc", "sonicpi-files", "tidal-ghci"}
+	expectedWatchers := []string{"sonicpi-osc", "sonicpi-files", "tidal-ghci", "tidal-hook", "hydra", "strudel", "browser", "osc-generic", "editor-jsonl", "editor-http", "emacs", "pulsar", "midi-clock", "ableton-link", "tidal-superdirt"}
 	for _, watcherName := range expectedWatchers {
 		if _, exists := config.Watchers[watcherName]; !exists {
 			t.Errorf("Expected default watcher '%s' to be configured", watcherName)
@@ -228,6 +228,22 @@ func TestConfigManagerSetWatcherOption(t *testing.T) {
 		t.Errorf("Expected osc_port '4560', got '%s'", config.Options["osc_port"])
 	}
 
+	// The author keys set the watcher's author rather than an option
+	if err := manager.SetWatcherOption("sonicpi-osc", "author", "Sam"); err != nil {
+		t.Fatalf("Failed to set watcher author: %v", err)
+	}
+	if err := manager.SetWatcherOption("sonicpi-osc", "author_email", "sam@example.com"); err != nil {
+		t.Fatalf("Failed to set watcher author email: %v", err)
+	}
+
+	config, _ = manager.GetWatcherConfig("sonicpi-osc")
+	if config.Author != "Sam" || config.AuthorEmail != "sam@example.com" {
+		t.Errorf("Expected author 'Sam' <sam@example.com>, got '%s' <%s>", config.Author, config.AuthorEmail)
+	}
+	if _, exists := config.Options["author"]; exists {
+		t.Errorf("Expected author not to be stored as an option")
+	}
+
 	// Test setting option for non-existent watcher
 	err = manager.SetWatcherOption("non-existent", "option", "value")
 	if err == nil {
@@ -247,7 +263,7 @@ func TestConfigManagerListOperations(t *testing.T) {
 
 	// Test ListWatchers
 	watchers := manager.ListWatchers()
-	expectedWatchers := []string{"sonicpi-osc", "sonicpi-files", "tidal-ghci"}
+	expectedWatchers := []string{"sonicpi-osc", "sonicpi-files", "tidal-ghci", "tidal-hook", "hydra", "strudel", "browser", "osc-generic", "editor-jsonl", "editor-http", "emacs", "pulsar", "midi-clock", "ableton-link", "tidal-superdirt"}
 
 	if len(watchers) != len(expectedWatchers) {
 		t.Errorf("Expected %d watchers, got %d", len(expectedWatchers), len(watchers))
@@ -326,6 +342,51 @@ func TestConfigManagerValidation(t *testing.T) {
 	if err == nil {
 		t.Errorf("Expected validation to fail for watcher with empty language")
 	}
+
+	// External watchers need a command, and only the exec and plugin types exist
+	manager.SetWatcherConfig("invalid-watcher", WatcherConfig{Language: "foxdot", Environment: "foxdot", Type: WatcherTypeExec})
+	if err := manager.ValidateConfig(); err == nil {
+		t.Errorf("Expected validation to fail for an exec watcher without command")
+	}
+	manager.SetWatcherConfig("invalid-watcher", WatcherConfig{Language: "foxdot", Environment: "foxdot", Type: "grpc", Command: "x"})
+	if err := manager.ValidateConfig(); err == nil {
+		t.Errorf("Expected validation to fail for an unknown watcher type")
+	}
+	manager.SetWatcherConfig("invalid-watcher", WatcherConfig{Language: "foxdot", Environment: "foxdot", Type: WatcherTypeExec, Command: "foxdot-watcher"})
+	if err := manager.ValidateConfig(); err != nil {
+		t.Errorf("Expected an exec watcher with a command to be valid: %v", err)
+	}
+	manager.SetWatcherConfig("invalid-watcher", WatcherConfig{Language: "foxdot", Environment: "foxdot", Type: WatcherTypePlugin, Command: "foxdot-plugin"})
+	if err := manager.ValidateConfig(); err != nil {
+		t.Errorf("Expected a plugin watcher with a command to be valid: %v", err)
+	}
+}
+
+func TestConfigManagerValidateTidalGHCi(t *testing.T) {
+	manager := NewConfigManager("")
+	config := DefaultGlobalConfig().Watchers["tidal-ghci"]
+
+	if err := manager.validateWatcherConfig("tidal-ghci", config); err != nil {
+		t.Fatalf("Expected the default config to be valid, even without BootTidal.hs: %v", err)
+	}
+
+	invalid := map[string]string{
+		"boot_file":     filepath.Join(t.TempDir(), "missing.hs"),
+		"tidal_version": "latest",
+		"boot_timeout":  "soon",
+	}
+	for option, value := range invalid {
+		config := DefaultGlobalConfig().Watchers["tidal-ghci"]
+		config.Options[option] = value
+		if err := manager.validateWatcherConfig("tidal-ghci", config); err == nil {
+			t.Errorf("Expected %s=%s to be invalid", option, value)
+		}
+	}
+
+	config.Options["tidal_version"] = "1.9.10"
+	if err := manager.validateWatcherConfig("tidal-ghci", config); err != nil {
+		t.Errorf("Expected tidal_version 1.9.10 to be valid: %v", err)
+	}
 }
 
 func TestGetDefaultConfigPath(t *testing.T) {
@@ -0,0 +1,275 @@
+package watchers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/journal"
+	"github.com/livecodegit/pkg/osc"
+)
+
+// The control surface lets an OSC controller such as TouchOSC drive the
+// repository during a performance. Each message is answered to its sender
+// with /lcg/ok or /lcg/error, carrying the handled address and a description,
+// so a controller can show the result on a label.
+const (
+	ControlSnapshot          = "/lcg/snapshot"           // [label] mark the latest commit of every buffer
+	ControlMark              = "/lcg/mark"               // [label] mark the current moment
+	ControlCheckout          = "/lcg/checkout"           // <hash|tag> write a commit's code to its buffer file
+	ControlCheckpointSave    = "/lcg/checkpoint/save"    // <name> save the latest commit of every buffer
+	ControlCheckpointRestore = "/lcg/checkpoint/restore" // [name] write a checkpoint's buffers to their files
+	ControlPerformanceStart  = "/lcg/performance/start"  // [name] start a performance
+	ControlPerformanceEnd    = "/lcg/performance/end"    // end the active performance
+	ControlFlush             = "/lcg/flush"              // commit the executions waiting in the batch
+
+	ControlReplyOK    = "/lcg/ok"
+	ControlReplyError = "/lcg/error"
+)
+
+// SetControlPort overrides the configured control surface port; 0 disables it
+func (ws *WatcherService) SetControlPort(port int) {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+	ws.controlPort = port
+}
+
+// ControlPort returns the UDP port of the control surface, or 0 when it is off
+func (ws *WatcherService) ControlPort() int {
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+	return ws.controlPort
+}
+
+// HandleControl performs one control message and describes what it did
+func (ws *WatcherService) HandleControl(message osc.Message) (string, error) {
+	// Analyzer windows don't touch the repository
+	if message.Address == ControlAudio {
+		features, err := ws.RecordAudio(message)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("audio rms %.2f", features.RMS), nil
+	}
+
+	// Flushing commits, which takes the repository's lock itself
+	if message.Address == ControlFlush {
+		return fmt.Sprintf("flushed %d buffers", ws.Flush()), nil
+	}
+
+	ws.repoMutex.Lock()
+	defer ws.repoMutex.Unlock()
+
+	repo := ws.repository
+	label := controlLabel(message)
+
+	switch message.Address {
+	case ControlSnapshot:
+		marker, err := repo.MarkSnapshot(label)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("snapshot '%s' of %d buffers", marker.Label, len(marker.Buffers)), nil
+
+	case ControlMark:
+		marker, err := repo.Mark(label)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("marker '%s'", marker.Label), nil
+
+	case ControlCheckout:
+		if label == "" {
+			return "", fmt.Errorf("%s needs a commit hash or tag", ControlCheckout)
+		}
+		commit, err := repo.ResolveCommit(label)
+		if err != nil {
+			return "", err
+		}
+		path, err := repo.Checkout(commit, repo.GetPath())
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("checked out %s to %s", commit.Hash[:8], path), nil
+
+	case ControlCheckpointSave:
+		if label == "" {
+			return "", fmt.Errorf("%s needs a checkpoint name", ControlCheckpointSave)
+		}
+		checkpoint, err := repo.SaveCheckpoint(label)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("checkpoint '%s' of %d buffers", checkpoint.Name, len(checkpoint.Buffers)), nil
+
+	case ControlCheckpointRestore:
+		// Without a name the latest checkpoint is restored, so a single
+		// button gets back to safety
+		checkpoint, err := repo.GetCheckpoint(label)
+		if err != nil {
+			return "", err
+		}
+		commits, err := repo.CheckpointCommits(checkpoint)
+		if err != nil {
+			return "", err
+		}
+		for _, commit := range commits {
+			if _, err := repo.Checkout(commit, repo.GetPath()); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("restored checkpoint '%s' to %d buffers", checkpoint.Name, len(commits)), nil
+
+	case ControlPerformanceStart:
+		if label == "" {
+			label = "Performance " + time.Now().Format("2006-01-02 15:04")
+		}
+		performance, err := repo.StartPerformance(label)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("started performance '%s'", performance.Name), nil
+
+	case ControlPerformanceEnd:
+		performance, _ := repo.GetCurrentPerformance()
+		if err := repo.EndPerformance(); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("ended performance '%s' after %d commits", performance.Name, performance.CommitCount), nil
+
+	default:
+		return "", fmt.Errorf("unknown control address %s", message.Address)
+	}
+}
+
+// controlLabel returns the first string argument of a control message
+func controlLabel(message osc.Message) string {
+	for _, arg := range message.Args {
+		if s, ok := arg.(string); ok {
+			return strings.TrimSpace(s)
+		}
+	}
+	return ""
+}
+
+// isButtonRelease reports whether a message is the release of a controller
+// button, which sends its first argument as 0 when let go
+func isButtonRelease(message osc.Message) bool {
+	if len(message.Args) == 0 {
+		return false
+	}
+
+	switch v := message.Args[0].(type) {
+	case float32:
+		return v == 0
+	case float64:
+		return v == 0
+	case int32:
+		return v == 0
+	case bool:
+		return !v
+	}
+	return false
+}
+
+// parseControlPacket decodes a binary OSC packet, or a text line such as
+// "/lcg/mark drop" so the surface can be driven from a shell
+func parseControlPacket(packet []byte) ([]osc.Message, bool, error) {
+	if len(packet) > 0 && packet[0] == '/' && bytes.IndexByte(packet, 0) < 0 {
+		address, rest, _ := strings.Cut(strings.TrimSpace(string(packet)), " ")
+		message := osc.Message{Address: address}
+		if rest = strings.TrimSpace(rest); rest != "" {
+			message.Args = []interface{}{rest}
+		}
+		return []osc.Message{message}, true, nil
+	}
+
+	messages, err := osc.Parse(packet)
+	return messages, false, err
+}
+
+// startControl listens for control messages on the control port
+func (ws *WatcherService) startControl(port int) error {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port})
+	if err != nil {
+		return fmt.Errorf("failed to listen for control messages on UDP port %d: %w", port, err)
+	}
+
+	ws.controlConn = conn
+	go ws.serveControl(conn)
+
+	log.Printf("OSC control surface listening on UDP port %d", port)
+	return nil
+}
+
+// serveControl handles control packets until the connection is closed
+func (ws *WatcherService) serveControl(conn *net.UDPConn) {
+	buffer := make([]byte, 65536)
+
+	for {
+		n, sender, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Error reading control message: %v", err)
+			continue
+		}
+
+		messages, text, err := parseControlPacket(buffer[:n])
+		if err != nil {
+			log.Printf("Ignoring control packet from %s: %v", sender, err)
+			continue
+		}
+
+		for _, message := range messages {
+			// A silent window is a 0, not a button release
+			audio := message.Address == ControlAudio
+			if !audio && isButtonRelease(message) {
+				continue
+			}
+
+			reply := osc.Message{Address: ControlReplyOK}
+			description, err := ws.HandleControl(message)
+			if audio && err == nil {
+				// Analyzers send many windows a second; only failures are answered
+				continue
+			}
+			if err != nil {
+				reply.Address = ControlReplyError
+				description = err.Error()
+			}
+			reply.Args = []interface{}{message.Address, description}
+			log.Printf("Control %s: %s", message, description)
+			if err != nil {
+				ws.eventJournal().Warn(journal.EventControl, description, "address", message.Address, "from", sender.String())
+			} else {
+				ws.eventJournal().Info(journal.EventControl, description, "address", message.Address, "from", sender.String())
+			}
+
+			ws.sendControlReply(conn, sender, reply, text)
+		}
+	}
+}
+
+// sendControlReply answers a controller in the form its message was sent in
+func (ws *WatcherService) sendControlReply(conn *net.UDPConn, sender *net.UDPAddr, reply osc.Message, text bool) {
+	var data []byte
+	if text {
+		data = []byte(reply.String() + "\n")
+	} else {
+		var err error
+		if data, err = reply.MarshalBinary(); err != nil {
+			log.Printf("Failed to encode control reply: %v", err)
+			return
+		}
+	}
+
+	if _, err := conn.WriteToUDP(data, sender); err != nil {
+		log.Printf("Failed to reply to %s: %v", sender, err)
+	}
+}
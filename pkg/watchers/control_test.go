@@ -0,0 +1,206 @@
+package watchers
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/osc"
+)
+
+func TestHandleControl(t *testing.T) {
+	service, configDir := createTestWatcherService(t)
+	defer os.RemoveAll(configDir)
+	defer os.RemoveAll(service.repository.GetPath())
+
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+
+	repo := service.repository
+
+	// Markers need an active performance
+	if _, err := service.HandleControl(osc.Message{Address: ControlMark}); err == nil {
+		t.Errorf("Expected marking without a performance to fail")
+	}
+
+	if _, err := service.HandleControl(osc.Message{Address: ControlPerformanceStart, Args: []interface{}{"Algorave"}}); err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+
+	commit, err := repo.Commit("d1 $ s \"bd*4\"", "Kick", core.ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	if _, err := repo.Commit("d2 $ s \"hh*8\"", "Hats", core.ExecutionMetadata{Buffer: "d2", Language: "tidal", Success: true}); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	description, err := service.HandleControl(osc.Message{Address: ControlMark, Args: []interface{}{float32(1), "drop"}})
+	if err != nil || !strings.Contains(description, "'drop'") {
+		t.Errorf("Expected marker drop, got '%s' (%v)", description, err)
+	}
+
+	description, err = service.HandleControl(osc.Message{Address: ControlSnapshot})
+	if err != nil || !strings.Contains(description, "2 buffers") {
+		t.Errorf("Expected snapshot of 2 buffers, got '%s' (%v)", description, err)
+	}
+
+	performance, _ := repo.GetCurrentPerformance()
+	if len(performance.Markers) != 2 {
+		t.Fatalf("Expected 2 markers, got %d", len(performance.Markers))
+	}
+	if performance.Markers[1].Buffers["d1"] != commit.Hash {
+		t.Errorf("Expected snapshot to record d1 at %s, got %v", commit.Hash, performance.Markers[1].Buffers)
+	}
+
+	if _, err := service.HandleControl(osc.Message{Address: ControlCheckout, Args: []interface{}{commit.Hash}}); err != nil {
+		t.Fatalf("Failed to check out commit: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(repo.GetPath(), "d1.tidal"))
+	if err != nil || string(data) != commit.Content {
+		t.Errorf("Expected checked out d1.tidal, got '%s' (%v)", string(data), err)
+	}
+
+	if _, err := service.HandleControl(osc.Message{Address: ControlPerformanceEnd}); err != nil {
+		t.Fatalf("Failed to end performance: %v", err)
+	}
+
+	// Markers are written with the performance
+	stored, err := repo.GetPerformance("Algorave")
+	if err != nil {
+		t.Fatalf("Failed to read performance: %v", err)
+	}
+	if len(stored.Markers) != 2 || stored.Markers[0].Label != "drop" {
+		t.Errorf("Expected stored markers, got %+v", stored.Markers)
+	}
+
+	if _, err := service.HandleControl(osc.Message{Address: "/lcg/rewind"}); err == nil {
+		t.Errorf("Expected error for unknown address")
+	}
+}
+
+func TestHandleControlCheckpoint(t *testing.T) {
+	service, configDir := createTestWatcherService(t)
+	defer os.RemoveAll(configDir)
+	defer os.RemoveAll(service.repository.GetPath())
+
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+
+	repo := service.repository
+
+	if _, err := service.HandleControl(osc.Message{Address: ControlCheckpointRestore, Args: []interface{}{float32(1)}}); err == nil {
+		t.Errorf("Expected restoring without checkpoints to fail")
+	}
+
+	safe, err := repo.Commit("d1 $ s \"bd*4\"", "Kick", core.ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	if _, err := service.HandleControl(osc.Message{Address: ControlCheckpointSave}); err == nil {
+		t.Errorf("Expected saving a checkpoint without a name to fail")
+	}
+	description, err := service.HandleControl(osc.Message{Address: ControlCheckpointSave, Args: []interface{}{"safe"}})
+	if err != nil || !strings.Contains(description, "'safe' of 1 buffers") {
+		t.Errorf("Expected checkpoint safe, got '%s' (%v)", description, err)
+	}
+
+	if _, err := repo.Commit("d1 $ s \"bd*16\" # crush 2", "Risky", core.ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	// A bare button press restores the latest checkpoint
+	description, err = service.HandleControl(osc.Message{Address: ControlCheckpointRestore, Args: []interface{}{float32(1)}})
+	if err != nil || !strings.Contains(description, "'safe'") {
+		t.Errorf("Expected restored checkpoint safe, got '%s' (%v)", description, err)
+	}
+	data, err := os.ReadFile(filepath.Join(repo.GetPath(), "d1.tidal"))
+	if err != nil || string(data) != safe.Content {
+		t.Errorf("Expected d1.tidal to be restored to '%s', got '%s' (%v)", safe.Content, string(data), err)
+	}
+
+	if _, err := service.HandleControl(osc.Message{Address: ControlCheckpointRestore, Args: []interface{}{"missing"}}); err == nil {
+		t.Errorf("Expected restoring an unknown checkpoint to fail")
+	}
+}
+
+func TestControlSurfaceOverUDP(t *testing.T) {
+	service, configDir := createTestWatcherService(t)
+	defer os.RemoveAll(configDir)
+	defer os.RemoveAll(service.repository.GetPath())
+
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+
+	service.SetControlPort(freeUDPPort(t))
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer service.Stop()
+
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: service.ControlPort()})
+	if err != nil {
+		t.Fatalf("Failed to connect to control port: %v", err)
+	}
+	defer conn.Close()
+
+	exchange := func(packet []byte) []byte {
+		if _, err := conn.Write(packet); err != nil {
+			t.Fatalf("Failed to send control message: %v", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		reply := make([]byte, 1024)
+		n, err := conn.Read(reply)
+		if err != nil {
+			t.Fatalf("Expected a control reply: %v", err)
+		}
+		return reply[:n]
+	}
+
+	// A button press is answered in binary OSC
+	start, _ := osc.Message{Address: ControlPerformanceStart, Args: []interface{}{float32(1)}}.MarshalBinary()
+	replies, err := osc.Parse(exchange(start))
+	if err != nil {
+		t.Fatalf("Failed to parse reply: %v", err)
+	}
+	if replies[0].Address != ControlReplyOK || replies[0].Args[0] != ControlPerformanceStart {
+		t.Errorf("Expected /lcg/ok for performance start, got %v", replies[0])
+	}
+
+	// A text message is answered in text
+	reply := string(exchange([]byte("/lcg/checkout nowhere\n")))
+	if !strings.HasPrefix(reply, ControlReplyError+` "/lcg/checkout"`) {
+		t.Errorf("Expected text error reply for unknown checkout, got %q", reply)
+	}
+
+	if performance, _ := service.repository.GetCurrentPerformance(); performance == nil {
+		t.Errorf("Expected an active performance after the control message")
+	}
+}
+
+func TestIsButtonRelease(t *testing.T) {
+	tests := []struct {
+		args     []interface{}
+		expected bool
+	}{
+		{nil, false},
+		{[]interface{}{float32(1)}, false},
+		{[]interface{}{float32(0)}, true},
+		{[]interface{}{int32(0)}, true},
+		{[]interface{}{"intro"}, false},
+	}
+
+	for _, test := range tests {
+		if released := isButtonRelease(osc.Message{Address: ControlMark, Args: test.args}); released != test.expected {
+			t.Errorf("Expected release %t for %v, got %t", test.expected, test.args, released)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package watchers
+
+import (
+	"crypto/sha256"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDedupWindow is how long after an execution the same content in the
+// same buffer counts as the same execution
+const DefaultDedupWindow = 2 * time.Second
+
+// dedupWindow parses the configured window, which ValidateConfig checked
+func dedupWindow(value string) time.Duration {
+	if value == "" {
+		return DefaultDedupWindow
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil {
+		return DefaultDedupWindow
+	}
+	return window
+}
+
+// fingerprint identifies an execution whichever watcher saw it
+type fingerprint struct {
+	buffer  string
+	content [sha256.Size]byte
+}
+
+// fingerprintOf fingerprints an event; surrounding whitespace is ignored, as
+// watchers reading files and editors differ in trailing newlines
+func fingerprintOf(event ExecutionEvent) fingerprint {
+	return fingerprint{
+		buffer:  event.Buffer,
+		content: sha256.Sum256([]byte(strings.TrimSpace(event.Content))),
+	}
+}
+
+// recentExecution is an execution handled within the window
+type recentExecution struct {
+	event  ExecutionEvent // merged with its duplicates
+	commit string         // hash of its commit, empty when it wasn't committed
+	seen   time.Time
+}
+
+// dedup remembers recent executions so that duplicates, e.g. one Sonic Pi
+// run seen both over OSC and in its workspace file, make one commit. It is
+// locked while an event is handled, so a duplicate waits for the commit of
+// the execution it duplicates.
+type dedup struct {
+	sync.Mutex
+	window time.Duration // 0 disables deduplication
+	recent map[fingerprint]*recentExecution
+}
+
+// find returns the recent execution an event duplicates, or nil, forgetting
+// executions older than the window
+func (d *dedup) find(event ExecutionEvent) *recentExecution {
+	if d.window <= 0 {
+		return nil
+	}
+	now := time.Now()
+	for key, recent := range d.recent {
+		if now.Sub(recent.seen) > d.window {
+			delete(d.recent, key)
+		}
+	}
+	return d.recent[fingerprintOf(event)]
+}
+
+// add remembers a handled execution and the hash of its commit
+func (d *dedup) add(event ExecutionEvent, commit string) {
+	if d.window <= 0 {
+		return
+	}
+	if d.recent == nil {
+		d.recent = make(map[fingerprint]*recentExecution)
+	}
+	d.recent[fingerprintOf(event)] = &recentExecution{event: event, commit: commit, seen: time.Now()}
+}
+
+// mergeEvents combines two events of the same execution: fields one watcher
+// left empty come from the other, a failure reported by either wins, and
+// both watchers are recorded
+func mergeEvents(first, second ExecutionEvent) ExecutionEvent {
+	merged := first
+	if !second.Success {
+		merged.Success = false
+		if merged.ErrorMessage == "" {
+			merged.ErrorMessage = second.ErrorMessage
+		}
+	}
+	if merged.Environment == "" {
+		merged.Environment = second.Environment
+	}
+	if merged.BPM == 0 {
+		merged.BPM = second.BPM
+		merged.BeatsFromStart = second.BeatsFromStart
+		merged.Phase = second.Phase
+	}
+	if merged.FilePath == "" {
+		merged.FilePath = second.FilePath
+		merged.LineNumber = second.LineNumber
+	}
+	if merged.Audio == nil {
+		merged.Audio = second.Audio
+	}
+	if merged.Sound == nil {
+		merged.Sound = second.Sound
+	}
+	if merged.ProcessID == 0 {
+		merged.ProcessID = second.ProcessID
+	}
+
+	if len(second.ExtraData) > 0 {
+		extra := make(map[string]string, len(first.ExtraData)+len(second.ExtraData))
+		for key, value := range second.ExtraData {
+			extra[key] = value
+		}
+		for key, value := range first.ExtraData {
+			extra[key] = value
+		}
+		merged.ExtraData = extra
+	}
+
+	merged.Watchers = append([]string(nil), first.Watchers...)
+	for _, name := range second.Watchers {
+		if !slices.Contains(merged.Watchers, name) {
+			merged.Watchers = append(merged.Watchers, name)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,236 @@
+// Package editorhttp receives executions from editor extensions, such as the
+// VS Code TidalCycles and Sonic Pi ones, which POST an execution event to
+// localhost on each evaluation.
+//
+// Requests carry a token as "Authorization: Bearer <token>". Browsers are
+// kept out: a request with an Origin header is refused unless the origin is
+// allowed, and so is one addressed to another host name than localhost,
+// which a page could otherwise reach by rebinding its DNS name.
+package editorhttp
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/livecodegit/pkg/watchers/common"
+	"github.com/livecodegit/pkg/watchers/external"
+)
+
+// DefaultPort is the TCP port the watcher listens on
+const DefaultPort = 6067
+
+// EventPath is where extensions POST execution events
+const EventPath = "/event"
+
+// TokenFile is where the generated token is kept, in the repository's
+// .livecodegit directory, for extensions to read
+const TokenFile = "editor-token"
+
+// maxEventSize bounds one event, the size of a large buffer evaluated whole
+const maxEventSize = 1 << 20
+
+// Watcher receives the execution events editor extensions POST
+type Watcher struct {
+	config    common.WatcherConfig
+	port      int
+	token     string
+	tokenPath string
+	origins   map[string]bool
+	server    *http.Server
+	running   bool
+	mutex     sync.RWMutex
+	callback  func(common.ExecutionEvent)
+}
+
+// NewWatcher creates a watcher on the given port accepting events with the
+// token, from extensions or the allowed browser origins. Without a token,
+// the one kept at tokenPath is used, generated on first start.
+func NewWatcher(port int, token, tokenPath string, origins []string, config common.WatcherConfig) *Watcher {
+	allowed := make(map[string]bool)
+	for _, origin := range origins {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			allowed[strings.TrimSuffix(origin, "/")] = true
+		}
+	}
+	return &Watcher{config: config, port: port, token: token, tokenPath: tokenPath, origins: allowed}
+}
+
+// LoadToken reads the token kept at path, generating and saving a random
+// one, readable by the user only, the first time
+func LoadToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(random)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Token returns the token requests must carry, once started
+func (w *Watcher) Token() string {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.token
+}
+
+// Start serves the event endpoint on localhost
+func (w *Watcher) Start(callback func(common.ExecutionEvent)) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.running {
+		return fmt.Errorf("editor HTTP watcher is already running")
+	}
+	if w.token == "" {
+		if w.tokenPath == "" {
+			return fmt.Errorf("editor HTTP watcher needs a token")
+		}
+		token, err := LoadToken(w.tokenPath)
+		if err != nil {
+			return fmt.Errorf("failed to load token: %w", err)
+		}
+		w.token = token
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", w.port))
+	if err != nil {
+		return fmt.Errorf("failed to listen on TCP port %d: %w", w.port, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(EventPath, w.serveEvent)
+
+	w.server = &http.Server{Handler: mux}
+	w.callback = callback
+	w.running = true
+
+	go w.server.Serve(listener)
+
+	return nil
+}
+
+// Stop closes the server
+func (w *Watcher) Stop() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.running {
+		return nil
+	}
+
+	w.running = false
+	return w.server.Close()
+}
+
+// IsRunning returns true if the watcher is active
+func (w *Watcher) IsRunning() bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.running
+}
+
+// GetConfig returns the watcher configuration
+func (w *Watcher) GetConfig() common.WatcherConfig {
+	return w.config
+}
+
+// GetLanguage returns the language events default to
+func (w *Watcher) GetLanguage() string {
+	return w.config.Language
+}
+
+// GetEnvironment returns the environment events default to
+func (w *Watcher) GetEnvironment() string {
+	return w.config.Environment
+}
+
+// serveEvent receives one execution event per request
+func (w *Watcher) serveEvent(rw http.ResponseWriter, r *http.Request) {
+	if !localHost(r.Host) {
+		http.Error(rw, "requests must be addressed to localhost", http.StatusForbidden)
+		return
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if !w.origins[origin] {
+			http.Error(rw, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		rw.Header().Set("Access-Control-Allow-Origin", origin)
+		rw.Header().Set("Vary", "Origin")
+	}
+
+	switch r.Method {
+	case http.MethodOptions:
+		rw.Header().Set("Access-Control-Allow-Methods", "POST")
+		rw.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	case http.MethodPost:
+	default:
+		http.Error(rw, "POST an execution event", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(w.token)) != 1 {
+		http.Error(rw, "missing or wrong token", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxEventSize+1))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxEventSize {
+		http.Error(rw, "event too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	event, err := external.ParseEvent(body, w.config)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if w.callback != nil {
+		w.callback(event)
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// localHost reports whether a Host header names this machine
+func localHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	switch strings.Trim(host, "[]") {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
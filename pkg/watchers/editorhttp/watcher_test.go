@@ -0,0 +1,121 @@
+package editorhttp
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+var config = common.WatcherConfig{Language: "tidal", Environment: "vscode"}
+
+// freePort returns a TCP port nothing listens on
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestWatcher(t *testing.T) {
+	port := freePort(t)
+	watcher := NewWatcher(port, "", filepath.Join(t.TempDir(), TokenFile), []string{"vscode-webview://extension/"}, config)
+
+	events := make(chan common.ExecutionEvent, 10)
+	if err := watcher.Start(func(event common.ExecutionEvent) { events <- event }); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	token := watcher.Token()
+	if len(token) != 32 {
+		t.Fatalf("Expected a generated token, got '%s'", token)
+	}
+
+	url := fmt.Sprintf("http://127.0.0.1:%d%s", port, EventPath)
+	post := func(host, origin, token, body string) int {
+		request, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		if host != "" {
+			request.Host = host
+		}
+		if origin != "" {
+			request.Header.Set("Origin", origin)
+		}
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Failed to post: %v", err)
+		}
+		response.Body.Close()
+		return response.StatusCode
+	}
+
+	event := `{"content": "d1 $ s \"bd\"", "buffer": "main.tidal"}`
+	cases := []struct {
+		name, host, origin, token, body string
+		status                          int
+	}{
+		{"an extension", "", "", token, event, http.StatusNoContent},
+		{"an allowed origin", "", "vscode-webview://extension", token, event, http.StatusNoContent},
+		{"without token", "", "", "", event, http.StatusUnauthorized},
+		{"with a wrong token", "", "", "guess", event, http.StatusUnauthorized},
+		{"from a web page", "", "https://example.com", token, event, http.StatusForbidden},
+		{"to another host name", "attacker.example:6067", "", token, event, http.StatusForbidden},
+		{"without content", "", "", token, `{"buffer": "main.tidal"}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		if status := post(c.host, c.origin, c.token, c.body); status != c.status {
+			t.Errorf("Expected %d for %s, got %d", c.status, c.name, status)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case received := <-events:
+			if received.Content != `d1 $ s "bd"` || received.Buffer != "main.tidal" || received.Environment != "vscode" {
+				t.Errorf("Expected the posted event, got %+v", received)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected an execution")
+		}
+	}
+	select {
+	case received := <-events:
+		t.Errorf("Expected refused requests not to commit, got %+v", received)
+	default:
+	}
+}
+
+func TestLoadToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".livecodegit", TokenFile)
+
+	token, err := LoadToken(path)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected the token to be saved: %v", err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		t.Errorf("Expected the token to be readable by the user only, got %v", info.Mode())
+	}
+
+	if again, _ := LoadToken(path); again != token {
+		t.Errorf("Expected the saved token, got '%s' then '%s'", token, again)
+	}
+
+	if err := NewWatcher(freePort(t), "", "", nil, config).Start(func(common.ExecutionEvent) {}); err == nil {
+		t.Errorf("Expected a watcher without token to be refused")
+	}
+}
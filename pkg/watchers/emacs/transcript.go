@@ -0,0 +1,50 @@
+// Package emacs captures evaluations from Emacs modes such as tidal.el.
+// The hook 'lcg integrate emacs' generates either appends what tidal.el
+// sends to GHCi to a transcript file the watcher tails, or sends each
+// evaluation to the watcher over TCP as a JSON line.
+package emacs
+
+import (
+	"strings"
+
+	"github.com/livecodegit/pkg/watchers/tidal"
+)
+
+// BufferMarker starts the transcript line naming the Emacs buffer the
+// following evaluation came from; GHCi never sees it
+const BufferMarker = "-- lcg: "
+
+// DefaultBuffer names evaluations without a buffer marker
+const DefaultBuffer = "emacs"
+
+// Transcript reads the lines Emacs sent to GHCi back into evaluations of
+// the buffers they came from
+type Transcript struct {
+	buffer string
+	input  tidal.Input
+}
+
+// Evaluation is one block or line sent to GHCi
+type Evaluation struct {
+	Buffer  string
+	Content string
+}
+
+// Feed reads one line of the transcript, returning the evaluation it ends
+func (t *Transcript) Feed(line string) (Evaluation, bool) {
+	// The hook names the buffer before everything it sends, inside blocks too
+	if strings.HasPrefix(line, BufferMarker) {
+		t.buffer = strings.TrimSpace(strings.TrimPrefix(line, BufferMarker))
+		return Evaluation{}, false
+	}
+
+	content, ok := t.input.Feed(line)
+	if !ok {
+		return Evaluation{}, false
+	}
+	buffer := t.buffer
+	if buffer == "" {
+		buffer = DefaultBuffer
+	}
+	return Evaluation{Buffer: buffer, Content: content}, true
+}
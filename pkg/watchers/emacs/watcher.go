@@ -0,0 +1,123 @@
+package emacs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+	"github.com/livecodegit/pkg/watchers/jsonlines"
+	"github.com/livecodegit/pkg/watchers/tail"
+)
+
+// DefaultPort is the TCP port the watcher listens on without a transcript
+const DefaultPort = 6068
+
+// pollInterval is how often the transcript is checked for new evaluations
+const pollInterval = 200 * time.Millisecond
+
+// NewWatcher creates a watcher tailing the transcript file, or, when it is
+// empty, reading JSON lines on the TCP port
+func NewWatcher(port int, transcript string, config common.WatcherConfig) common.ExecutionWatcher {
+	if transcript == "" {
+		return jsonlines.NewWatcher(port, "", config)
+	}
+	return NewTranscriptWatcher(transcript, config)
+}
+
+// TranscriptWatcher tails the transcript of what Emacs sent to GHCi
+type TranscriptWatcher struct {
+	config   common.WatcherConfig
+	path     string
+	running  bool
+	mutex    sync.RWMutex
+	stop     chan struct{}
+	callback func(common.ExecutionEvent)
+}
+
+// NewTranscriptWatcher creates a watcher tailing the transcript at path
+func NewTranscriptWatcher(path string, config common.WatcherConfig) *TranscriptWatcher {
+	return &TranscriptWatcher{config: config, path: path}
+}
+
+// Start tails the transcript from its current end, creating it if missing
+func (w *TranscriptWatcher) Start(callback func(common.ExecutionEvent)) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.running {
+		return fmt.Errorf("Emacs watcher is already running")
+	}
+
+	follower, err := tail.Open(w.path)
+	if err != nil {
+		return fmt.Errorf("failed to open transcript: %w", err)
+	}
+
+	w.callback = callback
+	w.stop = make(chan struct{})
+	w.running = true
+
+	var transcript Transcript
+	line := func(line string) {
+		if evaluation, ok := transcript.Feed(line); ok {
+			w.emit(evaluation)
+		}
+	}
+	// A new Emacs session starts a new transcript
+	reset := func() { transcript = Transcript{} }
+	go follower.Run(w.stop, pollInterval, line, nil, reset)
+
+	return nil
+}
+
+// Stop stops tailing the transcript
+func (w *TranscriptWatcher) Stop() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.running {
+		return nil
+	}
+
+	w.running = false
+	close(w.stop)
+	return nil
+}
+
+// IsRunning returns true if the watcher is active
+func (w *TranscriptWatcher) IsRunning() bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.running
+}
+
+// GetConfig returns the watcher configuration
+func (w *TranscriptWatcher) GetConfig() common.WatcherConfig {
+	return w.config
+}
+
+// GetLanguage returns the configured language
+func (w *TranscriptWatcher) GetLanguage() string {
+	return w.config.Language
+}
+
+// GetEnvironment returns the configured environment
+func (w *TranscriptWatcher) GetEnvironment() string {
+	return w.config.Environment
+}
+
+// emit passes an evaluation on as an execution
+func (w *TranscriptWatcher) emit(evaluation Evaluation) {
+	event := common.ExecutionEvent{
+		Timestamp:   time.Now(),
+		Content:     evaluation.Content,
+		Buffer:      evaluation.Buffer,
+		Language:    w.config.Language,
+		Environment: w.config.Environment,
+		Success:     true,
+	}
+	if w.callback != nil {
+		w.callback(event)
+	}
+}
@@ -0,0 +1,93 @@
+package emacs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+func TestTranscript(t *testing.T) {
+	lines := []string{
+		":set -XOverloadedStrings",
+		"d1 $ s \"bd*2\"",
+		BufferMarker + "main.tidal",
+		":{",
+		BufferMarker + "main.tidal",
+		"d2 $ n \"0 .. 7\"",
+		"  # s \"superpiano\"",
+		BufferMarker + "main.tidal",
+		":}",
+		"",
+		BufferMarker + "drums.tidal",
+		"hush",
+	}
+
+	var transcript Transcript
+	var evaluations []Evaluation
+	for _, line := range lines {
+		if evaluation, ok := transcript.Feed(line); ok {
+			evaluations = append(evaluations, evaluation)
+		}
+	}
+
+	expected := []Evaluation{
+		{Buffer: DefaultBuffer, Content: "d1 $ s \"bd*2\""},
+		{Buffer: "main.tidal", Content: "d2 $ n \"0 .. 7\"\n  # s \"superpiano\""},
+		{Buffer: "drums.tidal", Content: "hush"},
+	}
+	if len(evaluations) != len(expected) {
+		t.Fatalf("Expected %d evaluations, got %+v", len(expected), evaluations)
+	}
+	for i := range expected {
+		if evaluations[i] != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], evaluations[i])
+		}
+	}
+}
+
+func TestTranscriptWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tidal.transcript")
+	os.WriteFile(path, []byte("d1 $ s \"old\"\n"), 0600)
+
+	config := common.WatcherConfig{Language: "tidal", Environment: "emacs"}
+	watcher := NewWatcher(DefaultPort, path, config)
+
+	events := make(chan common.ExecutionEvent, 10)
+	if err := watcher.Start(func(event common.ExecutionEvent) { events <- event }); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	appendText := func(text string) {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			t.Fatalf("Failed to open transcript: %v", err)
+		}
+		file.WriteString(text)
+		file.Close()
+	}
+	expect := func(content string) {
+		select {
+		case event := <-events:
+			if event.Content != content || event.Buffer != "main.tidal" || event.Environment != "emacs" {
+				t.Errorf("Expected %q from main.tidal, got %+v", content, event)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected %q", content)
+		}
+	}
+
+	// Evaluations from before the start are not replayed, and a line is
+	// only read once complete
+	appendText(BufferMarker + "main.tidal\n:{\nd1 $ s \"bd\"\n")
+	time.Sleep(3 * pollInterval)
+	appendText(":}\n")
+	expect("d1 $ s \"bd\"")
+
+	// A new Emacs session truncating the transcript is read from its start
+	os.WriteFile(path, []byte(BufferMarker+"main.tidal\nhush\n"), 0600)
+	expect("hush")
+}
@@ -0,0 +1,507 @@
+package external
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+// PluginProtocol is the version of the plugin protocol this host speaks
+const PluginProtocol = 1
+
+// Plugin protocol methods. The host calls configure, start and stop; the
+// plugin sends hello once, then event, lifecycle and log notifications.
+const (
+	MethodHello     = "hello"
+	MethodConfigure = "configure"
+	MethodStart     = "start"
+	MethodStop      = "stop"
+	MethodEvent     = "event"
+	MethodLifecycle = "lifecycle"
+	MethodLog       = "log"
+)
+
+// Timeouts of the plugin protocol
+const (
+	helloTimeout = 10 * time.Second
+	callTimeout  = 10 * time.Second
+)
+
+// errPluginExited fails the calls a plugin can no longer answer
+var errPluginExited = errors.New("plugin exited")
+
+// Message is one line of the plugin protocol, in the manner of JSON-RPC: a
+// request has an ID and a method, its response the same ID and a result or
+// an error, and a notification a method and no ID
+type Message struct {
+	ID     int64           `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Hello is the first message of a plugin
+type Hello struct {
+	Protocol int    `json:"protocol"`
+	Name     string `json:"name,omitempty"`
+	Version  string `json:"version,omitempty"`
+}
+
+// PluginConfig is sent to a plugin with configure
+type PluginConfig struct {
+	Name        string            `json:"name"`
+	Language    string            `json:"language"`
+	Environment string            `json:"environment"`
+	Options     map[string]string `json:"options,omitempty"`
+}
+
+// Plugin supervises a watcher plugin: a program that talks the plugin
+// protocol on its stdin and stdout, so the host can configure, start and
+// stop it while it streams executions back
+type Plugin struct {
+	name     string
+	config   common.WatcherConfig
+	defaults common.WatcherConfig // what events default to, never changed
+	command  []string
+
+	mutex    sync.Mutex
+	running  bool
+	session  *session
+	hello    Hello
+	stop     chan struct{}
+	done     chan struct{}
+	callback func(common.ExecutionEvent)
+
+	// The plugin may report its lifecycle while the mutex is held waiting
+	// for it to answer, so the callback has its own
+	lifecycleMutex sync.Mutex
+	lifecycle      func(common.LifecycleEvent)
+}
+
+// session is one run of a plugin program
+type session struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	hello  chan Hello
+	exited chan struct{} // closed when the plugin's stdout closes
+
+	writeMutex sync.Mutex
+	mutex      sync.Mutex
+	nextID     int64
+	pending    map[int64]chan Message
+}
+
+// NewPlugin creates the watcher plugin called name, running its configured
+// command
+func NewPlugin(name string, config common.WatcherConfig) (*Plugin, error) {
+	command := common.CommandFields(config.Command)
+	if len(command) == 0 {
+		return nil, fmt.Errorf("command is required for plugin watcher %s", name)
+	}
+
+	defaults := common.WatcherConfig{Language: config.Language, Environment: config.Environment}
+	return &Plugin{name: name, config: config, defaults: defaults, command: command}, nil
+}
+
+// SetLifecycleCallback sets the function told about the plugin's lifecycle
+// notifications and restarts
+func (p *Plugin) SetLifecycleCallback(callback func(event common.LifecycleEvent)) {
+	p.lifecycleMutex.Lock()
+	defer p.lifecycleMutex.Unlock()
+	p.lifecycle = callback
+}
+
+// Start runs the plugin, configures and starts it. An error doing so the
+// first time is returned; later restarts are reported as lifecycle events.
+func (p *Plugin) Start(callback func(common.ExecutionEvent)) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.running {
+		return fmt.Errorf("%s is already running", p.name)
+	}
+
+	p.callback = callback
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+
+	s, err := p.open()
+	if err != nil {
+		return err
+	}
+	p.session = s
+	p.running = true
+
+	go p.supervise(s)
+	return nil
+}
+
+// Stop asks the plugin to stop and exit, killing it if it doesn't in time
+func (p *Plugin) Stop() error {
+	p.mutex.Lock()
+	if !p.running {
+		p.mutex.Unlock()
+		return nil
+	}
+	p.running = false
+	close(p.stop)
+	s := p.session
+	p.mutex.Unlock()
+
+	if s != nil {
+		s.shutdown()
+	}
+	<-p.done
+	return nil
+}
+
+// Configure sends the plugin new options while it runs
+func (p *Plugin) Configure(options map[string]string) error {
+	p.mutex.Lock()
+	s := p.session
+	if !p.running || s == nil {
+		p.mutex.Unlock()
+		return fmt.Errorf("%s is not running", p.name)
+	}
+	p.config.Options = options
+	config := p.pluginConfig()
+	p.mutex.Unlock()
+
+	return s.call(MethodConfigure, config, nil, callTimeout)
+}
+
+// Hello returns what the running plugin said about itself
+func (p *Plugin) Hello() Hello {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.hello
+}
+
+// IsRunning returns true while the watcher supervises its plugin
+func (p *Plugin) IsRunning() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.running
+}
+
+// GetConfig returns the watcher configuration
+func (p *Plugin) GetConfig() common.WatcherConfig {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.config
+}
+
+// GetLanguage returns the configured language
+func (p *Plugin) GetLanguage() string {
+	return p.defaults.Language
+}
+
+// GetEnvironment returns the configured environment
+func (p *Plugin) GetEnvironment() string {
+	return p.defaults.Environment
+}
+
+// pluginConfig describes the watcher to the plugin. Called with the mutex held.
+func (p *Plugin) pluginConfig() PluginConfig {
+	return PluginConfig{
+		Name:        p.name,
+		Language:    p.config.Language,
+		Environment: p.config.Environment,
+		Options:     p.config.Options,
+	}
+}
+
+// open runs the plugin, waits for its hello, then configures and starts it.
+// Called with the mutex held.
+func (p *Plugin) open() (*session, error) {
+	cmd := exec.Command(p.command[0], p.command[1:]...)
+	cmd.Stderr = os.Stderr
+	cmd.SysProcAttr = processGroup()
+	cmd.Env = append(os.Environ(), "LCG_WATCHER="+p.name, fmt.Sprintf("LCG_PLUGIN_PROTOCOL=%d", PluginProtocol))
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", p.command[0], err)
+	}
+
+	s := &session{
+		cmd:     cmd,
+		stdin:   stdin,
+		hello:   make(chan Hello, 1),
+		exited:  make(chan struct{}),
+		pending: make(map[int64]chan Message),
+	}
+	go p.read(s, stdout)
+
+	fail := func(err error) (*session, error) {
+		s.kill()
+		return nil, err
+	}
+
+	select {
+	case hello := <-s.hello:
+		if hello.Protocol != PluginProtocol {
+			return fail(fmt.Errorf("%s speaks plugin protocol %d, expected %d", p.command[0], hello.Protocol, PluginProtocol))
+		}
+		p.hello = hello
+	case <-s.exited:
+		return fail(fmt.Errorf("%s exited before saying hello", p.command[0]))
+	case <-time.After(helloTimeout):
+		return fail(fmt.Errorf("%s did not say hello within %s", p.command[0], helloTimeout))
+	}
+
+	if err := s.call(MethodConfigure, p.pluginConfig(), nil, callTimeout); err != nil {
+		return fail(fmt.Errorf("failed to configure %s: %w", p.name, err))
+	}
+	if err := s.call(MethodStart, nil, nil, callTimeout); err != nil {
+		return fail(fmt.Errorf("failed to start %s: %w", p.name, err))
+	}
+	return s, nil
+}
+
+// read dispatches the plugin's messages until its stdout closes
+func (p *Plugin) read(s *session, stdout io.Reader) {
+	defer s.close()
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var message Message
+		if err := json.Unmarshal([]byte(line), &message); err != nil {
+			fmt.Printf("%s: ignoring line: %v\n", p.name, err)
+			continue
+		}
+
+		if message.Method == "" {
+			s.answer(message)
+			continue
+		}
+		p.notify(s, message)
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Printf("%s: stopped reading output: %v\n", p.name, err)
+	}
+}
+
+// notify handles a notification from the plugin
+func (p *Plugin) notify(s *session, message Message) {
+	switch message.Method {
+	case MethodHello:
+		var hello Hello
+		if err := json.Unmarshal(message.Params, &hello); err != nil {
+			fmt.Printf("%s: invalid hello: %v\n", p.name, err)
+			return
+		}
+		select {
+		case s.hello <- hello:
+		default:
+		}
+	case MethodEvent:
+		event, err := ParseEvent(message.Params, p.defaults)
+		if err != nil {
+			fmt.Printf("%s: ignoring event: %v\n", p.name, err)
+			return
+		}
+		if p.callback != nil {
+			p.callback(event)
+		}
+	case MethodLifecycle:
+		var event common.LifecycleEvent
+		if err := json.Unmarshal(message.Params, &event); err != nil {
+			fmt.Printf("%s: invalid lifecycle event: %v\n", p.name, err)
+			return
+		}
+		if event.Timestamp.IsZero() {
+			event.Timestamp = time.Now()
+		}
+		p.report(event)
+	case MethodLog:
+		var entry struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(message.Params, &entry)
+		fmt.Printf("%s: %s\n", p.name, entry.Message)
+	default:
+		fmt.Printf("%s: ignoring unknown method %s\n", p.name, message.Method)
+	}
+}
+
+// supervise restarts the plugin whenever it exits, until the watcher stops
+func (p *Plugin) supervise(s *session) {
+	defer close(p.done)
+
+	delay := minRestartDelay
+	for {
+		started := time.Now()
+		if s != nil {
+			<-s.exited
+			err := s.cmd.Wait()
+
+			select {
+			case <-p.stop:
+				return
+			default:
+			}
+			if time.Since(started) >= stableRunTime {
+				delay = minRestartDelay
+			}
+			reason := "exited"
+			if err != nil {
+				reason = fmt.Sprintf("exited (%v)", err)
+			}
+			p.restarting(false, fmt.Sprintf("%s %s, restarting in %s", p.command[0], reason, delay))
+		}
+
+		select {
+		case <-p.stop:
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRestartDelay)
+
+		p.mutex.Lock()
+		if !p.running {
+			p.mutex.Unlock()
+			return
+		}
+		var err error
+		s, err = p.open()
+		p.session = s
+		p.mutex.Unlock()
+		if err != nil {
+			p.restarting(false, fmt.Sprintf("%v, retrying in %s", err, delay))
+			continue
+		}
+		p.restarting(true, fmt.Sprintf("%s restarted", p.command[0]))
+	}
+}
+
+// restarting reports a restart of the plugin
+func (p *Plugin) restarting(success bool, message string) {
+	p.report(common.LifecycleEvent{
+		Timestamp: time.Now(),
+		Kind:      common.LifecycleRestart,
+		Success:   success,
+		Message:   message,
+	})
+}
+
+// report passes a lifecycle event to the service
+func (p *Plugin) report(event common.LifecycleEvent) {
+	p.lifecycleMutex.Lock()
+	lifecycle := p.lifecycle
+	p.lifecycleMutex.Unlock()
+
+	if lifecycle != nil {
+		lifecycle(event)
+	}
+}
+
+// call sends a request and waits for its response, decoding the result
+// into result when given
+func (s *session) call(method string, params, result interface{}, timeout time.Duration) error {
+	request := Message{Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		request.Params = data
+	}
+
+	response := make(chan Message, 1)
+	s.mutex.Lock()
+	s.nextID++
+	request.ID = s.nextID
+	s.pending[request.ID] = response
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.pending, request.ID)
+		s.mutex.Unlock()
+	}()
+
+	line, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	s.writeMutex.Lock()
+	_, err = s.stdin.Write(append(line, '\n'))
+	s.writeMutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to send %s: %w", method, err)
+	}
+
+	select {
+	case message := <-response:
+		if message.Error != "" {
+			return errors.New(message.Error)
+		}
+		if result != nil && len(message.Result) > 0 {
+			return json.Unmarshal(message.Result, result)
+		}
+		return nil
+	case <-s.exited:
+		return errPluginExited
+	case <-time.After(timeout):
+		return fmt.Errorf("no answer to %s within %s", method, timeout)
+	}
+}
+
+// answer passes a response to the call waiting for it
+func (s *session) answer(message Message) {
+	s.mutex.Lock()
+	response, exists := s.pending[message.ID]
+	s.mutex.Unlock()
+
+	if exists {
+		response <- message
+	}
+}
+
+// shutdown asks the plugin to stop, then closes its stdin so it exits,
+// killing it when it doesn't in time
+func (s *session) shutdown() {
+	s.call(MethodStop, nil, nil, stopTimeout)
+	s.stdin.Close()
+
+	select {
+	case <-s.exited:
+	case <-time.After(stopTimeout):
+		s.kill()
+	}
+}
+
+// kill stops the plugin and what it started
+func (s *session) kill() {
+	s.stdin.Close()
+	killProcess(s.cmd.Process)
+}
+
+// close marks the session as over once the plugin's stdout closes
+func (s *session) close() {
+	close(s.exited)
+}
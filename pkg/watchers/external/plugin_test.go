@@ -0,0 +1,152 @@
+package external
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+// TestHelperPlugin is not a test: the plugin tests run the test binary with
+// LCG_TEST_PLUGIN set, and it then acts as a plugin on stdin and stdout
+func TestHelperPlugin(t *testing.T) {
+	mode := os.Getenv("LCG_TEST_PLUGIN")
+	if mode == "" {
+		return
+	}
+
+	send := func(message Message) {
+		line, _ := json.Marshal(message)
+		fmt.Println(string(line))
+	}
+	notify := func(method string, params interface{}) {
+		data, _ := json.Marshal(params)
+		send(Message{Method: method, Params: data})
+	}
+
+	protocol := PluginProtocol
+	if mode == "old" {
+		protocol = 0
+	}
+	notify(MethodHello, Hello{Protocol: protocol, Name: "helper", Version: "1.0"})
+
+	var config PluginConfig
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var request Message
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			continue
+		}
+		switch request.Method {
+		case MethodConfigure:
+			json.Unmarshal(request.Params, &config)
+			send(Message{ID: request.ID, Result: json.RawMessage(`{}`)})
+			if config.Options["greeting"] == "again" {
+				notify(MethodEvent, map[string]string{"content": "again", "buffer": config.Name})
+			}
+		case MethodStart:
+			send(Message{ID: request.ID, Result: json.RawMessage(`{}`)})
+			notify(MethodLog, map[string]string{"message": "started"})
+			notify(MethodEvent, map[string]string{"content": config.Options["greeting"], "buffer": config.Name})
+		case MethodStop:
+			send(Message{ID: request.ID, Result: json.RawMessage(`{}`)})
+			os.Exit(0)
+		default:
+			send(Message{ID: request.ID, Error: "unknown method " + request.Method})
+		}
+	}
+	os.Exit(0)
+}
+
+// helperPlugin returns a plugin watcher running the test binary as a plugin
+func helperPlugin(t *testing.T, mode string) *Plugin {
+	t.Setenv("LCG_TEST_PLUGIN", mode)
+	if strings.Contains(os.Args[0], `"`) {
+		t.Skip("the test binary's path contains quotes")
+	}
+
+	config := common.WatcherConfig{
+		Language:    "foxdot",
+		Environment: "foxdot-plugin",
+		Command:     `"` + os.Args[0] + `" -test.run=TestHelperPlugin`,
+		Options:     map[string]string{"greeting": "hello"},
+	}
+	plugin, err := NewPlugin("mine", config)
+	if err != nil {
+		t.Fatalf("Failed to create plugin: %v", err)
+	}
+	return plugin
+}
+
+func TestPlugin(t *testing.T) {
+	plugin := helperPlugin(t, "current")
+
+	events := make(chan common.ExecutionEvent, 10)
+	if err := plugin.Start(func(event common.ExecutionEvent) { events <- event }); err != nil {
+		t.Fatalf("Failed to start plugin: %v", err)
+	}
+
+	if hello := plugin.Hello(); hello.Name != "helper" || hello.Version != "1.0" {
+		t.Errorf("Expected the plugin's hello, got %+v", hello)
+	}
+
+	select {
+	case event := <-events:
+		if event.Content != "hello" || event.Buffer != "mine" {
+			t.Errorf("Expected the configured greeting from buffer mine, got %+v", event)
+		}
+		if event.Language != "foxdot" || event.Environment != "foxdot-plugin" {
+			t.Errorf("Expected defaults from the watcher, got %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected an event after start")
+	}
+
+	// The plugin can be reconfigured while it runs
+	if err := plugin.Configure(map[string]string{"greeting": "again"}); err != nil {
+		t.Fatalf("Failed to configure plugin: %v", err)
+	}
+	select {
+	case event := <-events:
+		if event.Content != "again" {
+			t.Errorf("Expected an event for the new options, got %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected an event after configure")
+	}
+
+	start := time.Now()
+	if err := plugin.Stop(); err != nil {
+		t.Errorf("Failed to stop plugin: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= stopTimeout {
+		t.Errorf("Expected the plugin to stop when asked, took %s", elapsed)
+	}
+	if plugin.IsRunning() {
+		t.Errorf("Expected the plugin to be stopped")
+	}
+	if err := plugin.Configure(nil); err == nil {
+		t.Errorf("Expected configuring a stopped plugin to fail")
+	}
+}
+
+func TestPluginProtocolMismatch(t *testing.T) {
+	plugin := helperPlugin(t, "old")
+
+	err := plugin.Start(func(common.ExecutionEvent) {})
+	if err == nil {
+		plugin.Stop()
+		t.Fatalf("Expected a plugin speaking another protocol to be refused")
+	}
+	if !strings.Contains(err.Error(), "protocol 0") {
+		t.Errorf("Expected the protocol in the error, got '%v'", err)
+	}
+	if plugin.IsRunning() {
+		t.Errorf("Expected the plugin not to be running")
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package external
+
+import (
+	"os"
+	"syscall"
+)
+
+// processGroup starts a program in its own process group, so stopping it
+// also stops what it started
+func processGroup() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true}
+}
+
+// interruptProcess asks a program and its children to shut down
+func interruptProcess(process *os.Process) error {
+	return syscall.Kill(-process.Pid, syscall.SIGINT)
+}
+
+// killProcess stops a program and its children
+func killProcess(process *os.Process) error {
+	return syscall.Kill(-process.Pid, syscall.SIGKILL)
+}
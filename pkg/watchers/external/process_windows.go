@@ -0,0 +1,24 @@
+//go:build windows
+
+package external
+
+import (
+	"os"
+	"syscall"
+)
+
+// processGroup starts a program in its own process group
+func processGroup() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// interruptProcess can't deliver an interrupt on Windows, so the program
+// is killed
+func interruptProcess(process *os.Process) error {
+	return process.Kill()
+}
+
+// killProcess stops a program
+func killProcess(process *os.Process) error {
+	return process.Kill()
+}
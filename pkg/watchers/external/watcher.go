@@ -0,0 +1,310 @@
+// Package external runs watchers written as separate programs. An exec
+// program reports each execution as one JSON ExecutionEvent per line on
+// stdout; a plugin talks the plugin protocol on stdin and stdout instead, so
+// it can also be configured, started and stopped. Stderr is passed through.
+// When a program exits, it is started again after a growing delay until the
+// watcher stops.
+//
+// The plugin protocol is JSON-RPC style messages, one per line, over the
+// plugin's stdin and stdout, rather than gRPC in the manner of go-plugin.
+// It gives the same bidirectional control (configure, start, stop) and
+// streamed events, but lcg stays free of dependencies and generated code, a
+// plugin needs nothing beyond a JSON library in whatever language it is
+// written in, and plugins are supervised just like exec programs.
+package external
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+// Restart delays: the first restart waits minRestartDelay, doubling up to
+// maxRestartDelay while the program keeps exiting within stableRunTime
+const (
+	minRestartDelay = 1 * time.Second
+	maxRestartDelay = 30 * time.Second
+	stableRunTime   = time.Minute
+)
+
+// stopTimeout is how long a program has to exit after an interrupt before
+// it is killed
+const stopTimeout = 3 * time.Second
+
+// maxLineSize bounds one event line
+const maxLineSize = 16 << 20
+
+// Watcher supervises an external watcher program
+type Watcher struct {
+	name    string
+	config  common.WatcherConfig
+	command []string
+
+	mutex     sync.Mutex
+	running   bool
+	process   *exec.Cmd
+	stop      chan struct{}
+	done      chan struct{}
+	callback  func(common.ExecutionEvent)
+	lifecycle func(common.LifecycleEvent)
+}
+
+// NewWatcher creates the external watcher called name, running its
+// configured command
+func NewWatcher(name string, config common.WatcherConfig) (*Watcher, error) {
+	command := common.CommandFields(config.Command)
+	if len(command) == 0 {
+		return nil, fmt.Errorf("command is required for exec watcher %s", name)
+	}
+
+	return &Watcher{name: name, config: config, command: command}, nil
+}
+
+// SetLifecycleCallback sets the function told when the program exits or
+// fails to start again
+func (w *Watcher) SetLifecycleCallback(callback func(event common.LifecycleEvent)) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.lifecycle = callback
+}
+
+// Start runs the program. An error starting it the first time is returned;
+// later restarts are reported through the lifecycle callback.
+func (w *Watcher) Start(callback func(common.ExecutionEvent)) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.running {
+		return fmt.Errorf("%s is already running", w.name)
+	}
+
+	w.callback = callback
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+
+	stdout, err := w.spawn()
+	if err != nil {
+		return err
+	}
+	w.running = true
+
+	go w.supervise(stdout)
+	return nil
+}
+
+// Stop interrupts the program, killing it if it doesn't exit in time
+func (w *Watcher) Stop() error {
+	w.mutex.Lock()
+	if !w.running {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.running = false
+	close(w.stop)
+	process := w.process
+	w.mutex.Unlock()
+
+	if process != nil {
+		if err := interruptProcess(process.Process); err != nil {
+			killProcess(process.Process)
+		}
+	}
+
+	select {
+	case <-w.done:
+	case <-time.After(stopTimeout):
+		w.mutex.Lock()
+		if w.process != nil {
+			killProcess(w.process.Process)
+		}
+		w.mutex.Unlock()
+		<-w.done
+	}
+	return nil
+}
+
+// IsRunning returns true while the watcher supervises its program
+func (w *Watcher) IsRunning() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.running
+}
+
+// GetConfig returns the watcher configuration
+func (w *Watcher) GetConfig() common.WatcherConfig {
+	return w.config
+}
+
+// GetLanguage returns the configured language
+func (w *Watcher) GetLanguage() string {
+	return w.config.Language
+}
+
+// GetEnvironment returns the configured environment
+func (w *Watcher) GetEnvironment() string {
+	return w.config.Environment
+}
+
+// spawn starts the program and returns its stdout. Called with the mutex held.
+func (w *Watcher) spawn() (io.ReadCloser, error) {
+	cmd := exec.Command(w.command[0], w.command[1:]...)
+	cmd.Stderr = os.Stderr
+	cmd.SysProcAttr = processGroup()
+	cmd.Env = append(os.Environ(), "LCG_WATCHER="+w.name)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", w.command[0], err)
+	}
+
+	w.process = cmd
+	return stdout, nil
+}
+
+// supervise reads events until the program exits, then starts it again
+// until the watcher stops
+func (w *Watcher) supervise(stdout io.ReadCloser) {
+	defer close(w.done)
+
+	delay := minRestartDelay
+	for {
+		started := time.Now()
+		if stdout != nil {
+			w.readEvents(stdout)
+
+			w.mutex.Lock()
+			process := w.process
+			w.mutex.Unlock()
+			err := process.Wait()
+
+			if w.stopped() {
+				return
+			}
+			if time.Since(started) >= stableRunTime {
+				delay = minRestartDelay
+			}
+			reason := "exited"
+			if err != nil {
+				reason = fmt.Sprintf("exited (%v)", err)
+			}
+			w.report(false, fmt.Sprintf("%s %s, restarting in %s", w.command[0], reason, delay))
+		}
+
+		select {
+		case <-w.stop:
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRestartDelay)
+
+		w.mutex.Lock()
+		if !w.running {
+			w.mutex.Unlock()
+			return
+		}
+		var err error
+		stdout, err = w.spawn()
+		w.mutex.Unlock()
+		if err != nil {
+			w.report(false, fmt.Sprintf("%v, retrying in %s", err, delay))
+			continue
+		}
+		w.report(true, fmt.Sprintf("%s restarted", w.command[0]))
+	}
+}
+
+// readEvents passes on each event line until stdout closes
+func (w *Watcher) readEvents(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		event, err := ParseEvent([]byte(line), w.config)
+		if err != nil {
+			fmt.Printf("%s: ignoring line: %v\n", w.name, err)
+			continue
+		}
+		if w.callback != nil {
+			w.callback(event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Printf("%s: stopped reading output: %v\n", w.name, err)
+		io.Copy(io.Discard, stdout)
+	}
+}
+
+// ParseEvent reads one line of a program's output. Language and environment
+// default to the watcher's, the buffer to "unknown", the time to now and
+// success to true.
+func ParseEvent(line []byte, config common.WatcherConfig) (common.ExecutionEvent, error) {
+	var event common.ExecutionEvent
+	if err := json.Unmarshal(line, &event); err != nil {
+		return event, fmt.Errorf("not an execution event: %w", err)
+	}
+	if event.Content == "" {
+		return event, fmt.Errorf("execution event without content")
+	}
+
+	var success struct {
+		Success *bool `json:"success"`
+	}
+	json.Unmarshal(line, &success)
+	event.Success = success.Success == nil || *success.Success
+
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	if event.Language == "" {
+		event.Language = config.Language
+	}
+	if event.Environment == "" {
+		event.Environment = config.Environment
+	}
+	if event.Buffer == "" {
+		event.Buffer = "unknown"
+	}
+	return event, nil
+}
+
+// stopped reports whether Stop was called
+func (w *Watcher) stopped() bool {
+	select {
+	case <-w.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// report passes a lifecycle event to the service
+func (w *Watcher) report(success bool, message string) {
+	w.mutex.Lock()
+	lifecycle := w.lifecycle
+	w.mutex.Unlock()
+
+	if lifecycle != nil {
+		lifecycle(common.LifecycleEvent{
+			Timestamp: time.Now(),
+			Kind:      common.LifecycleRestart,
+			Success:   success,
+			Message:   message,
+		})
+	}
+}
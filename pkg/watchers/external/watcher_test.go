@@ -0,0 +1,133 @@
+//go:build !windows
+
+package external
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+func TestParseEvent(t *testing.T) {
+	config := common.WatcherConfig{Language: "foxdot", Environment: "foxdot-exec"}
+
+	event, err := ParseEvent([]byte(`{"content":"p1 >> pluck()"}`), config)
+	if err != nil {
+		t.Fatalf("Failed to parse event: %v", err)
+	}
+	if event.Language != "foxdot" || event.Environment != "foxdot-exec" || event.Buffer != "unknown" {
+		t.Errorf("Expected defaults from the watcher, got %+v", event)
+	}
+	if !event.Success || event.Timestamp.IsZero() {
+		t.Errorf("Expected a successful event stamped now, got %+v", event)
+	}
+
+	event, _ = ParseEvent([]byte(`{"content":"x","buffer":"p1","language":"python","success":false}`), config)
+	if event.Success || event.Language != "python" || event.Buffer != "p1" {
+		t.Errorf("Expected the program's fields to win, got %+v", event)
+	}
+
+	for _, line := range []string{`not json`, `{"buffer":"p1"}`} {
+		if _, err := ParseEvent([]byte(line), config); err == nil {
+			t.Errorf("Expected %q to be refused", line)
+		}
+	}
+}
+
+func TestWatcherRunsAndRestarts(t *testing.T) {
+	// The program reports one execution and exits, so it is restarted
+	script := filepath.Join(t.TempDir(), "watcher.sh")
+	program := "#!/bin/sh\necho 'starting' >&2\necho '{\"content\":\"run '$LCG_WATCHER'\",\"buffer\":\"b1\"}'\n"
+	if err := os.WriteFile(script, []byte(program), 0755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	watcher, err := NewWatcher("mine", common.WatcherConfig{Language: "x", Environment: "mine", Command: script})
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+
+	restarts := make(chan common.LifecycleEvent, 10)
+	watcher.SetLifecycleCallback(func(event common.LifecycleEvent) { restarts <- event })
+
+	events := make(chan common.ExecutionEvent, 10)
+	if err := watcher.Start(func(event common.ExecutionEvent) { events <- event }); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-events:
+			if event.Content != "run mine" || event.Buffer != "b1" {
+				t.Errorf("Expected the program's event, got %+v", event)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected execution %d from the program", i+1)
+		}
+	}
+
+	select {
+	case event := <-restarts:
+		if event.Kind != common.LifecycleRestart || event.Success {
+			t.Errorf("Expected the exit to be reported, got %+v", event)
+		}
+	default:
+		t.Errorf("Expected the exit to be reported before the restart")
+	}
+
+	if err := watcher.Stop(); err != nil {
+		t.Errorf("Failed to stop watcher: %v", err)
+	}
+	if watcher.IsRunning() {
+		t.Errorf("Expected the watcher to stop")
+	}
+}
+
+func TestWatcherStartFailure(t *testing.T) {
+	if _, err := NewWatcher("empty", common.WatcherConfig{}); err == nil {
+		t.Errorf("Expected a watcher without command to be refused")
+	}
+
+	watcher, _ := NewWatcher("missing", common.WatcherConfig{Command: "/nonexistent/watcher --flag"})
+	if err := watcher.Start(func(common.ExecutionEvent) {}); err == nil {
+		t.Errorf("Expected a missing program to fail to start")
+	}
+	if watcher.IsRunning() {
+		t.Errorf("Expected the watcher not to run")
+	}
+}
+
+func TestWatcherQuotedCommand(t *testing.T) {
+	// The program's path and an argument both hold spaces
+	dir := filepath.Join(t.TempDir(), "my watchers")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	script := filepath.Join(dir, "watcher.sh")
+	program := "#!/bin/sh\necho '{\"content\":\"'\"$1\"'\"}'\nsleep 5\n"
+	if err := os.WriteFile(script, []byte(program), 0755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	watcher, err := NewWatcher("quoted", common.WatcherConfig{Command: `"` + script + `" 'two words'`})
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	events := make(chan common.ExecutionEvent, 1)
+	if err := watcher.Start(func(event common.ExecutionEvent) { events <- event }); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	select {
+	case event := <-events:
+		if event.Content != "two words" {
+			t.Errorf("Expected the quoted argument passed whole, got %q", event.Content)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected an execution from the program")
+	}
+}
@@ -0,0 +1,93 @@
+// Package hydra versions livecoded visuals: a snippet loaded into the Hydra
+// editor sends each evaluated block to a browser watcher.
+package hydra
+
+import (
+	"fmt"
+
+	"github.com/livecodegit/pkg/watchers/browser"
+)
+
+// DefaultPort is the TCP port the watcher serves the snippet and WebSocket on
+const DefaultPort = 6062
+
+// SnippetPath is where the watcher serves the browser snippet
+const SnippetPath = "/hydra.js"
+
+// Origins are the sites serving the Hydra editor, allowed to send
+// evaluations without configuration
+var Origins = []string{"https://hydra.ojack.xyz"}
+
+// snippet hooks the Hydra editor's evaluation keys: Ctrl+Enter runs a line,
+// Alt+Enter a block, Ctrl+Shift+Enter everything. It reads the code from the
+// CodeMirror editor, 5 or 6, and queues evaluations while disconnected.
+// window.lcg(code) sends code from anywhere else.
+const snippet = `// livecodegit: sends each Hydra evaluation to 'lcg watch'
+(() => {
+  const url = "ws://127.0.0.1:%d/";
+  let socket = null;
+  const queue = [];
+  const connect = () => {
+    socket = new WebSocket(url);
+    socket.onopen = () => { console.log("lcg: connected to " + url); queue.splice(0).forEach((m) => socket.send(m)); };
+    socket.onclose = () => { socket = null; setTimeout(connect, 2000); };
+  };
+  connect();
+
+  const send = (content, buffer) => {
+    if (!content || !content.trim()) return;
+    const message = JSON.stringify({ content: content, buffer: buffer || "hydra" });
+    if (socket && socket.readyState === WebSocket.OPEN) socket.send(message); else queue.push(message);
+  };
+  window.lcg = send;
+
+  const editor = () => {
+    const cm5 = document.querySelector(".CodeMirror");
+    if (cm5 && cm5.CodeMirror) {
+      const cm = cm5.CodeMirror;
+      return { lines: cm.getValue().split("\n"), cursor: cm.getCursor().line };
+    }
+    const cm6 = document.querySelector(".cm-content");
+    if (cm6 && cm6.cmView) {
+      const state = cm6.cmView.view.state;
+      return { lines: state.doc.toString().split("\n"), cursor: state.doc.lineAt(state.selection.main.head).number - 1 };
+    }
+    return null;
+  };
+  const block = (lines, at) => {
+    let start = at, end = at;
+    while (start > 0 && lines[start - 1].trim() !== "") start--;
+    while (end < lines.length - 1 && lines[end + 1].trim() !== "") end++;
+    return lines.slice(start, end + 1).join("\n");
+  };
+
+  document.addEventListener("keydown", (event) => {
+    if (event.key !== "Enter") return;
+    const current = editor();
+    if (!current) return;
+    const ctrl = event.ctrlKey || event.metaKey;
+    if (ctrl && event.shiftKey) send(current.lines.join("\n"));
+    else if (ctrl) send(current.lines[current.cursor]);
+    else if (event.altKey) send(block(current.lines, current.cursor));
+  }, true);
+})();
+`
+
+// Snippet returns the browser snippet connecting to the given port
+func Snippet(port int) string {
+	return fmt.Sprintf(snippet, port)
+}
+
+// NewWatcher creates a Hydra watcher serving on the given port, accepting
+// evaluations from the given origins besides pages on this machine
+func NewWatcher(port int, origins []string) *browser.Watcher {
+	return browser.NewWatcher(browser.Editor{
+		Name:        "Hydra",
+		Language:    "hydra",
+		Environment: "hydra",
+		PortOption:  "ws_port",
+		SnippetPath: SnippetPath,
+		Snippet:     Snippet,
+		Origins:     Origins,
+	}, port, origins)
+}
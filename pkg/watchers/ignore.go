@@ -0,0 +1,252 @@
+package watchers
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/livecodegit/pkg/journal"
+)
+
+// IgnoreFile lists rules, one per line, for executions kept out of
+// auto-commits; it lives at the top of the repository's working directory
+const IgnoreFile = ".livecodegitignore"
+
+// Ignore actions
+const (
+	IgnoreSkip = "skip" // drop ignored executions
+	IgnoreMark = "mark" // mark the moment in the active performance instead of committing
+)
+
+// IgnoreConfig keeps executions out of auto-commits. Rules use the syntax of
+// IgnoreFile lines:
+//
+//	hush           the whole code, trimmed, matches a glob (* and ?)
+//	re:\bpanic\b   a regular expression matches the code
+//	buffer:test*   the buffer's name matches a glob
+//	@empty         the code is blank
+//	@comments      the code is only comments
+//	!rule          executions matching rule are committed after all
+//
+// The last matching rule wins, as in .gitignore.
+type IgnoreConfig struct {
+	Rules  []string `json:"rules,omitempty"`
+	Action string   `json:"action,omitempty"` // IgnoreSkip (default) or IgnoreMark
+}
+
+// ignoreRule is one parsed rule
+type ignoreRule struct {
+	source string
+	negate bool
+	match  func(event ExecutionEvent) bool
+}
+
+// ignoreRules are the rules of the configuration and the ignore file
+type ignoreRules struct {
+	rules  []ignoreRule
+	action string
+}
+
+// parseIgnoreRule parses one rule
+func parseIgnoreRule(line string) (ignoreRule, error) {
+	rule := ignoreRule{source: line}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+
+	switch {
+	case line == "@empty":
+		rule.match = func(event ExecutionEvent) bool {
+			return strings.TrimSpace(event.Content) == ""
+		}
+	case line == "@comments":
+		rule.match = func(event ExecutionEvent) bool {
+			return commentsOnly(event.Content, event.Language)
+		}
+	case strings.HasPrefix(line, "@"):
+		return rule, fmt.Errorf("unknown rule %s, expected @empty or @comments", line)
+	case strings.HasPrefix(line, "re:"):
+		pattern, err := regexp.Compile(line[len("re:"):])
+		if err != nil {
+			return rule, fmt.Errorf("invalid regular expression in %q: %w", rule.source, err)
+		}
+		rule.match = func(event ExecutionEvent) bool {
+			return pattern.MatchString(event.Content)
+		}
+	case strings.HasPrefix(line, "buffer:"):
+		pattern := globPattern(line[len("buffer:"):])
+		rule.match = func(event ExecutionEvent) bool {
+			return pattern.MatchString(event.Buffer)
+		}
+	case line == "":
+		return rule, fmt.Errorf("empty rule %q", rule.source)
+	default:
+		pattern := globPattern(line)
+		rule.match = func(event ExecutionEvent) bool {
+			return pattern.MatchString(strings.TrimSpace(event.Content))
+		}
+	}
+	return rule, nil
+}
+
+// globPattern compiles a glob where * matches anything, newlines included,
+// and ? one character
+func globPattern(glob string) *regexp.Regexp {
+	var pattern strings.Builder
+	pattern.WriteString(`(?s)^`)
+	for _, r := range glob {
+		switch r {
+		case '*':
+			pattern.WriteString(`.*`)
+		case '?':
+			pattern.WriteString(`.`)
+		default:
+			pattern.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	pattern.WriteString(`$`)
+	return regexp.MustCompile(pattern.String())
+}
+
+// lineComments are the line comment markers of each language; languages
+// not listed accept any of them
+var lineComments = map[string][]string{
+	"sonicpi":       {"#"},
+	"ruby":          {"#"},
+	"tidal":         {"--"},
+	"haskell":       {"--"},
+	"strudel":       {"//"},
+	"hydra":         {"//"},
+	"javascript":    {"//"},
+	"supercollider": {"//"},
+}
+
+// commentsOnly reports whether code holds comments and blank lines only
+func commentsOnly(content, language string) bool {
+	markers, ok := lineComments[strings.ToLower(language)]
+	if !ok {
+		markers = []string{"#", "--", "//"}
+	}
+
+	hasComment := false
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		comment := false
+		for _, marker := range markers {
+			if strings.HasPrefix(line, marker) {
+				comment = true
+				break
+			}
+		}
+		if !comment {
+			return false
+		}
+		hasComment = true
+	}
+	return hasComment
+}
+
+// loadIgnoreRules parses the configured rules followed by those of the
+// repository's ignore file, if it has one
+func loadIgnoreRules(config IgnoreConfig, repoPath string) (*ignoreRules, error) {
+	rules := &ignoreRules{action: config.Action}
+	if rules.action == "" {
+		rules.action = IgnoreSkip
+	}
+
+	for _, line := range config.Rules {
+		rule, err := parseIgnoreRule(line)
+		if err != nil {
+			return nil, err
+		}
+		rules.rules = append(rules.rules, rule)
+	}
+
+	path := filepath.Join(repoPath, IgnoreFile)
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return rules, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := parseIgnoreRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", IgnoreFile, number, err)
+		}
+		rules.rules = append(rules.rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// match returns the rule that ignores an execution, or "" when it is
+// committed
+func (r *ignoreRules) match(event ExecutionEvent) string {
+	if r == nil {
+		return ""
+	}
+	ignoredBy := ""
+	for _, rule := range r.rules {
+		if rule.match(event) {
+			ignoredBy = rule.source
+			if rule.negate {
+				ignoredBy = ""
+			}
+		}
+	}
+	return ignoredBy
+}
+
+// validateIgnoreConfig checks the configured rules and action
+func validateIgnoreConfig(config IgnoreConfig) error {
+	if config.Action != "" && config.Action != IgnoreSkip && config.Action != IgnoreMark {
+		return fmt.Errorf("unknown action %q, expected %s or %s", config.Action, IgnoreSkip, IgnoreMark)
+	}
+	for _, line := range config.Rules {
+		if _, err := parseIgnoreRule(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ignoreExecution applies the ignore rules to an execution and reports
+// whether it was kept out of the history
+func (ws *WatcherService) ignoreExecution(event ExecutionEvent) bool {
+	rule := ws.ignore.match(event)
+	if rule == "" {
+		return false
+	}
+
+	log.Printf("Execution ignored by %q: %s/%s", rule, event.Language, event.Buffer)
+	if ws.ignore.action != IgnoreMark {
+		return true
+	}
+
+	ws.repoMutex.Lock()
+	marker, err := ws.repository.Mark(truncateString(strings.TrimSpace(event.Content), 40))
+	ws.repoMutex.Unlock()
+	if err != nil {
+		log.Printf("Failed to mark ignored execution: %v", err)
+		return true
+	}
+	ws.eventJournal().Info(journal.EventControl, fmt.Sprintf("marker '%s' for an ignored execution", marker.Label),
+		"buffer", event.Buffer, "rule", rule)
+	return true
+}
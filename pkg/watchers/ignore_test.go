@@ -0,0 +1,91 @@
+package watchers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	dir := t.TempDir()
+	file := "# performance noise\nhush\nre:\\bpanic\\b\n\n@comments\nbuffer:test*\n!buffer:test-keep\n"
+	if err := os.WriteFile(filepath.Join(dir, IgnoreFile), []byte(file), 0644); err != nil {
+		t.Fatalf("Failed to write ignore file: %v", err)
+	}
+
+	rules, err := loadIgnoreRules(IgnoreConfig{Rules: []string{"@empty", "d? $ silence"}}, dir)
+	if err != nil {
+		t.Fatalf("Failed to load ignore rules: %v", err)
+	}
+
+	tests := []struct {
+		event ExecutionEvent
+		rule  string
+	}{
+		{ExecutionEvent{Content: "hush\n", Language: "tidal"}, "hush"},
+		{ExecutionEvent{Content: "  \n", Language: "tidal"}, "@empty"},
+		{ExecutionEvent{Content: "d3 $ silence", Language: "tidal"}, "d? $ silence"},
+		{ExecutionEvent{Content: "d12 $ silence", Language: "tidal"}, ""},
+		{ExecutionEvent{Content: "once $ panic", Language: "tidal"}, `re:\bpanic\b`},
+		{ExecutionEvent{Content: "-- a note\n\n-- another", Language: "tidal"}, "@comments"},
+		{ExecutionEvent{Content: "# a note\nplay 60", Language: "sonicpi"}, ""},
+		{ExecutionEvent{Content: "-- a note", Language: "sonicpi"}, ""},
+		{ExecutionEvent{Content: "play 60", Buffer: "test-1", Language: "sonicpi"}, "buffer:test*"},
+		{ExecutionEvent{Content: "play 60", Buffer: "test-keep", Language: "sonicpi"}, ""},
+		{ExecutionEvent{Content: "d1 $ s \"bd\"", Buffer: "d1", Language: "tidal"}, ""},
+	}
+	for _, test := range tests {
+		if rule := rules.match(test.event); rule != test.rule {
+			t.Errorf("match(%q in %q) = %q, want %q", test.event.Content, test.event.Buffer, rule, test.rule)
+		}
+	}
+
+	if err := validateIgnoreConfig(IgnoreConfig{Rules: []string{"re:("}}); err == nil {
+		t.Error("Expected an invalid regular expression to be rejected")
+	}
+	if err := validateIgnoreConfig(IgnoreConfig{Action: "tag"}); err == nil {
+		t.Error("Expected an unknown action to be rejected")
+	}
+}
+
+func TestWatcherServiceIgnoresExecutions(t *testing.T) {
+	service, tempDir := createTestWatcherService(t)
+	defer os.RemoveAll(tempDir)
+
+	if err := os.WriteFile(filepath.Join(service.repository.GetPath(), IgnoreFile), []byte("hush\n"), 0644); err != nil {
+		t.Fatalf("Failed to write ignore file: %v", err)
+	}
+	if err := service.configManager.LoadConfig(); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	config := service.configManager.GetConfig()
+	config.Ignore.Action = IgnoreMark
+	service.configManager.UpdateConfig(config)
+	if err := service.configManager.SaveConfig(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+	if _, err := service.repository.StartPerformance("Algorave"); err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+
+	for _, content := range []string{`d1 $ s "bd"`, "hush"} {
+		service.handleExecutionEvent(ExecutionEvent{Timestamp: time.Now(), Content: content, Buffer: "d1",
+			Language: "tidal", Success: true})
+	}
+
+	commits, err := service.repository.History()
+	if err != nil || len(commits) != 1 {
+		t.Fatalf("Expected hush to stay out of the history, got %d commits (%v)", len(commits), err)
+	}
+	performance, err := service.repository.GetCurrentPerformance()
+	if err != nil {
+		t.Fatalf("Failed to get performance: %v", err)
+	}
+	if len(performance.Markers) != 1 || performance.Markers[0].Label != "hush" {
+		t.Errorf("Expected hush marked instead, got %+v", performance.Markers)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package jsonlines
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// OpenPipe opens the named pipe at path for reading, creating it if
+// missing. It is opened for writing too, so it neither blocks until an
+// editor opens it nor ends each time one closes it.
+func OpenPipe(path string) (*os.File, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		if err := syscall.Mkfifo(path, 0600); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	} else if info.Mode()&os.ModeNamedPipe == 0 {
+		return nil, fmt.Errorf("not a named pipe")
+	}
+
+	return os.OpenFile(path, os.O_RDWR, 0)
+}
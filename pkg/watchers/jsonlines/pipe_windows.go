@@ -0,0 +1,13 @@
+//go:build windows
+
+package jsonlines
+
+import (
+	"fmt"
+	"os"
+)
+
+// OpenPipe can't open a named pipe on Windows; use the TCP port instead
+func OpenPipe(path string) (*os.File, error) {
+	return nil, fmt.Errorf("named pipes are not supported on Windows; unset the pipe option to use TCP")
+}
@@ -0,0 +1,179 @@
+// Package jsonlines receives executions from editor plugins, such as
+// Neovim or Kakoune ones, as newline-delimited JSON execution events over
+// TCP or a named pipe. An event per line carries the exact code that was
+// evaluated:
+//
+//	{"content": "d1 $ s \"bd*2\"", "buffer": "main.tidal", "language": "tidal", "line_number": 12}
+//
+// The fields are those of common.ExecutionEvent, read as exec watchers'
+// lines are: only content is required, the language and environment
+// default to the watcher's, the buffer to "unknown", success to true and
+// the time to now.
+package jsonlines
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/livecodegit/pkg/watchers/common"
+	"github.com/livecodegit/pkg/watchers/external"
+)
+
+// DefaultPort is the TCP port the watcher listens on without a pipe
+const DefaultPort = 6066
+
+// maxLineSize bounds one event, the size of a large buffer evaluated whole
+const maxLineSize = 1 << 20
+
+// Watcher reads execution events from a TCP port or a named pipe
+type Watcher struct {
+	config   common.WatcherConfig
+	port     int
+	pipe     string
+	listener net.Listener
+	fifo     *os.File
+	conns    map[net.Conn]bool
+	running  bool
+	mutex    sync.RWMutex
+	callback func(common.ExecutionEvent)
+}
+
+// NewWatcher creates a watcher reading the named pipe at pipe, created if
+// missing, or listening on the TCP port when pipe is empty
+func NewWatcher(port int, pipe string, config common.WatcherConfig) *Watcher {
+	return &Watcher{config: config, port: port, pipe: pipe}
+}
+
+// Start begins reading events
+func (w *Watcher) Start(callback func(common.ExecutionEvent)) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.running {
+		return fmt.Errorf("JSON lines watcher is already running")
+	}
+
+	w.callback = callback
+	if w.pipe != "" {
+		fifo, err := OpenPipe(w.pipe)
+		if err != nil {
+			return fmt.Errorf("failed to open pipe %s: %w", w.pipe, err)
+		}
+		w.fifo = fifo
+		go w.read(fifo, "pipe "+w.pipe)
+	} else {
+		listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", w.port))
+		if err != nil {
+			return fmt.Errorf("failed to listen on TCP port %d: %w", w.port, err)
+		}
+		w.listener = listener
+		w.conns = make(map[net.Conn]bool)
+		go w.accept(listener)
+	}
+
+	w.running = true
+	return nil
+}
+
+// Stop closes the pipe, or the port and the editors' connections
+func (w *Watcher) Stop() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.running {
+		return nil
+	}
+
+	w.running = false
+	if w.fifo != nil {
+		err := w.fifo.Close()
+		w.fifo = nil
+		return err
+	}
+	for conn := range w.conns {
+		conn.Close()
+	}
+	return w.listener.Close()
+}
+
+// IsRunning returns true if the watcher is active
+func (w *Watcher) IsRunning() bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.running
+}
+
+// GetConfig returns the watcher configuration
+func (w *Watcher) GetConfig() common.WatcherConfig {
+	return w.config
+}
+
+// GetLanguage returns the language events default to
+func (w *Watcher) GetLanguage() string {
+	return w.config.Language
+}
+
+// GetEnvironment returns the environment events default to
+func (w *Watcher) GetEnvironment() string {
+	return w.config.Environment
+}
+
+// accept reads the events of each editor connecting until the watcher stops
+func (w *Watcher) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if w.IsRunning() {
+				fmt.Printf("Error accepting editor connection: %v\n", err)
+			}
+			return
+		}
+
+		w.mutex.Lock()
+		if !w.running {
+			w.mutex.Unlock()
+			conn.Close()
+			return
+		}
+		w.conns[conn] = true
+		w.mutex.Unlock()
+
+		go func() {
+			defer func() {
+				w.mutex.Lock()
+				delete(w.conns, conn)
+				w.mutex.Unlock()
+				conn.Close()
+			}()
+			w.read(conn, conn.RemoteAddr().String())
+		}()
+	}
+}
+
+// read passes on the events of each line until the reader ends
+func (w *Watcher) read(reader io.Reader, source string) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		event, err := external.ParseEvent(line, w.config)
+		if err != nil {
+			fmt.Printf("Ignoring line from %s: %v\n", source, err)
+			continue
+		}
+		if w.callback != nil {
+			w.callback(event)
+		}
+	}
+	if err := scanner.Err(); err != nil && w.IsRunning() {
+		fmt.Printf("Stopped reading %s: %v\n", source, err)
+	}
+}
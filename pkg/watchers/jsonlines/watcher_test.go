@@ -0,0 +1,112 @@
+package jsonlines
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+var config = common.WatcherConfig{Language: "tidal", Environment: "neovim"}
+
+// startWatcher starts a watcher passing its events to a channel
+func startWatcher(t *testing.T, watcher *Watcher) chan common.ExecutionEvent {
+	events := make(chan common.ExecutionEvent, 10)
+	if err := watcher.Start(func(event common.ExecutionEvent) { events <- event }); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	t.Cleanup(func() { watcher.Stop() })
+	return events
+}
+
+// expectEvent waits for the next event
+func expectEvent(t *testing.T, events chan common.ExecutionEvent) common.ExecutionEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected an execution")
+		return common.ExecutionEvent{}
+	}
+}
+
+func TestWatcherTCP(t *testing.T) {
+	// Find a free port
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	watcher := NewWatcher(port, "", config)
+	events := startWatcher(t, watcher)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("not json\n\n{\"content\": \"d1 $ s \\\"bd\\\"\", \"buffer\": \"main.tidal\", \"line_number\": 12}\n"))
+	event := expectEvent(t, events)
+	if event.Content != `d1 $ s "bd"` || event.Buffer != "main.tidal" || event.LineNumber != 12 {
+		t.Errorf("Expected the evaluated selection, got %+v", event)
+	}
+	if event.Language != "tidal" || event.Environment != "neovim" || !event.Success {
+		t.Errorf("Expected defaults from the watcher, got %+v", event)
+	}
+
+	conn.Write([]byte(`{"content": "hush", "language": "haskell", "success": false, "error_message": "oops"}` + "\n"))
+	event = expectEvent(t, events)
+	if event.Language != "haskell" || event.Success || event.ErrorMessage != "oops" {
+		t.Errorf("Expected the event's own fields, got %+v", event)
+	}
+
+	if err := watcher.Stop(); err != nil {
+		t.Errorf("Failed to stop watcher: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Errorf("Expected the connection to be closed on stop")
+	}
+}
+
+func TestWatcherPipe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("named pipes are not supported on Windows")
+	}
+
+	pipe := filepath.Join(t.TempDir(), "lcg.pipe")
+	watcher := NewWatcher(DefaultPort, pipe, config)
+	events := startWatcher(t, watcher)
+
+	// Each editor write opens and closes the pipe
+	for _, content := range []string{"d1 $ silence", "hush"} {
+		file, err := os.OpenFile(pipe, os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatalf("Failed to open pipe: %v", err)
+		}
+		file.Write([]byte(`{"content": "` + content + `"}` + "\n"))
+		file.Close()
+
+		if event := expectEvent(t, events); event.Content != content {
+			t.Errorf("Expected %s, got %+v", content, event)
+		}
+	}
+
+	if err := watcher.Stop(); err != nil {
+		t.Errorf("Failed to stop watcher: %v", err)
+	}
+
+	// A file that isn't a pipe is refused
+	path := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(path, nil, 0600)
+	if err := NewWatcher(DefaultPort, path, config).Start(func(common.ExecutionEvent) {}); err == nil {
+		t.Errorf("Expected a regular file to be refused")
+	}
+}
@@ -0,0 +1,71 @@
+package watchers
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyCapacity is how many of the most recent commits latency stats
+// cover, so they follow the current passage rather than the whole session
+const latencyCapacity = 1000
+
+// LatencyStats summarize the delay from an execution being detected to its
+// commit being written
+type LatencyStats struct {
+	Samples int           `json:"samples"`
+	P50     time.Duration `json:"p50"`
+	P95     time.Duration `json:"p95"`
+	Max     time.Duration `json:"max"`
+}
+
+// latencies holds the latency of the most recent commits
+type latencies struct {
+	mutex   sync.Mutex
+	samples []time.Duration
+	next    int // where the next sample goes once full
+}
+
+// add records the latency of one commit
+func (l *latencies) add(latency time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if len(l.samples) < latencyCapacity {
+		l.samples = append(l.samples, latency)
+		return
+	}
+	l.samples[l.next] = latency
+	l.next = (l.next + 1) % latencyCapacity
+}
+
+// snapshot returns a copy of the recorded latencies
+func (l *latencies) snapshot() []time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]time.Duration(nil), l.samples...)
+}
+
+// summarizeLatencies computes latency stats; it sorts samples in place
+func summarizeLatencies(samples []time.Duration) LatencyStats {
+	if len(samples) == 0 {
+		return LatencyStats{}
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return LatencyStats{
+		Samples: len(samples),
+		P50:     percentile(samples, 50),
+		P95:     percentile(samples, 95),
+		Max:     samples[len(samples)-1],
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted samples
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,42 @@
+package watchers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarizeLatencies(t *testing.T) {
+	var samples []time.Duration
+	for i := 100; i >= 1; i-- {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+
+	stats := summarizeLatencies(samples)
+	if stats.Samples != 100 || stats.P50 != 50*time.Millisecond || stats.P95 != 95*time.Millisecond || stats.Max != 100*time.Millisecond {
+		t.Errorf("Expected p50 50ms, p95 95ms and max 100ms of 100, got %+v", stats)
+	}
+
+	single := summarizeLatencies([]time.Duration{3 * time.Millisecond})
+	if single.P50 != 3*time.Millisecond || single.P95 != 3*time.Millisecond {
+		t.Errorf("Expected a single sample to be every percentile, got %+v", single)
+	}
+
+	if empty := summarizeLatencies(nil); empty.Samples != 0 {
+		t.Errorf("Expected no samples, got %+v", empty)
+	}
+}
+
+func TestLatenciesKeepMostRecent(t *testing.T) {
+	var l latencies
+	for i := 0; i < latencyCapacity+10; i++ {
+		l.add(time.Duration(i))
+	}
+
+	samples := l.snapshot()
+	if len(samples) != latencyCapacity {
+		t.Fatalf("Expected %d samples, got %d", latencyCapacity, len(samples))
+	}
+	if samples[0] != time.Duration(latencyCapacity) || samples[9] != time.Duration(latencyCapacity+9) {
+		t.Errorf("Expected the oldest samples to be replaced, got %v and %v", samples[0], samples[9])
+	}
+}
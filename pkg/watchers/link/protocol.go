@@ -0,0 +1,203 @@
+// Package link follows an Ableton Link session, so commits carry the tempo,
+// beat and phase that Tidal, Sonic Pi and the other peers are synced to.
+//
+// The watcher doesn't join the session: it listens to the peers' discovery
+// messages for the session timeline, and pings one peer to learn the
+// session's clock ("ghost time"), as Link peers do among themselves.
+package link
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+)
+
+// MulticastAddress is where Link peers announce themselves
+const MulticastAddress = "224.76.78.75:20808"
+
+// Protocol headers of discovery and measurement messages, version 1
+var (
+	discoveryHeader   = []byte("_asdp_v\x01")
+	measurementHeader = []byte("_link_v\x01")
+)
+
+// Discovery message types
+const (
+	messageAlive    = 1
+	messageResponse = 2
+	messageByeBye   = 3
+)
+
+// Measurement message types
+const (
+	messagePing = 1
+	messagePong = 2
+)
+
+// Payload entry keys, four characters as a big-endian integer
+const (
+	keyTimeline   = 0x746d6c6e // tmln
+	keySession    = 0x73657373 // sess
+	keyEndpointV4 = 0x6d657034 // mep4
+	keyGhostTime  = 0x5f5f6774 // __gt
+	keyHostTime   = 0x5f5f6874 // __ht
+)
+
+// NodeID identifies a peer or, as a session ID, the peer that founded it
+type NodeID [8]byte
+
+// String returns the ID in hex
+func (id NodeID) String() string {
+	return fmt.Sprintf("%x", id[:])
+}
+
+// Timeline maps the session's ghost time to beats
+type Timeline struct {
+	MicrosPerBeat int64 // tempo
+	BeatOrigin    int64 // in millionths of a beat
+	TimeOrigin    int64 // ghost time of the origin, in microseconds
+}
+
+// BPM returns the tempo in beats per minute
+func (t Timeline) BPM() float64 {
+	if t.MicrosPerBeat <= 0 {
+		return 0
+	}
+	return 60e6 / float64(t.MicrosPerBeat)
+}
+
+// BeatsAt returns the beat at the given ghost time
+func (t Timeline) BeatsAt(ghost int64) float64 {
+	if t.MicrosPerBeat <= 0 {
+		return 0
+	}
+	return float64(t.BeatOrigin)/1e6 + float64(ghost-t.TimeOrigin)/float64(t.MicrosPerBeat)
+}
+
+// Phase returns a beat's position within a bar of quantum beats, the way
+// Link aligns peers
+func Phase(beats, quantum float64) float64 {
+	if quantum <= 0 {
+		return 0
+	}
+	return math.Mod(math.Mod(beats, quantum)+quantum, quantum)
+}
+
+// PeerState is what a peer announces about itself
+type PeerState struct {
+	Type     byte
+	TTL      byte // seconds the announcement holds
+	NodeID   NodeID
+	Session  NodeID
+	Timeline Timeline
+	Endpoint *net.UDPAddr // where it answers pings
+}
+
+// ParseDiscovery reads a peer's discovery message
+func ParseDiscovery(data []byte) (PeerState, error) {
+	var state PeerState
+	if !bytes.HasPrefix(data, discoveryHeader) {
+		return state, fmt.Errorf("not a Link discovery message")
+	}
+	data = data[len(discoveryHeader):]
+	if len(data) < 12 {
+		return state, fmt.Errorf("truncated Link discovery header")
+	}
+	state.Type = data[0]
+	state.TTL = data[1]
+	copy(state.NodeID[:], data[4:12])
+
+	entries, err := parseEntries(data[12:])
+	if err != nil {
+		return state, err
+	}
+	if value, ok := entries[keySession]; ok && len(value) == 8 {
+		copy(state.Session[:], value)
+	}
+	if value, ok := entries[keyTimeline]; ok && len(value) == 24 {
+		state.Timeline = Timeline{
+			MicrosPerBeat: int64(binary.BigEndian.Uint64(value[0:8])),
+			BeatOrigin:    int64(binary.BigEndian.Uint64(value[8:16])),
+			TimeOrigin:    int64(binary.BigEndian.Uint64(value[16:24])),
+		}
+	}
+	if value, ok := entries[keyEndpointV4]; ok && len(value) == 6 {
+		state.Endpoint = &net.UDPAddr{
+			IP:   net.IPv4(value[0], value[1], value[2], value[3]),
+			Port: int(binary.BigEndian.Uint16(value[4:6])),
+		}
+	}
+	return state, nil
+}
+
+// parseEntries reads a payload of key, size, value entries. Unknown keys
+// are kept too, and ignored by the caller.
+func parseEntries(data []byte) (map[uint32][]byte, error) {
+	entries := make(map[uint32][]byte)
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, fmt.Errorf("truncated Link payload entry")
+		}
+		key := binary.BigEndian.Uint32(data[0:4])
+		size := binary.BigEndian.Uint32(data[4:8])
+		if uint64(size) > uint64(len(data)-8) {
+			return nil, fmt.Errorf("Link payload entry larger than the message")
+		}
+		entries[key] = data[8 : 8+size]
+		data = data[8+size:]
+	}
+	return entries, nil
+}
+
+// appendEntry appends one payload entry
+func appendEntry(data []byte, key uint32, value []byte) []byte {
+	data = binary.BigEndian.AppendUint32(data, key)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(value)))
+	return append(data, value...)
+}
+
+// encodeInt64 encodes a time or beat value
+func encodeInt64(value int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(value))
+}
+
+// encodePing builds a ping carrying the host time it was sent at
+func encodePing(hostTime int64) []byte {
+	data := append(append([]byte{}, measurementHeader...), messagePing)
+	return appendEntry(data, keyHostTime, encodeInt64(hostTime))
+}
+
+// Pong is a peer's answer to a ping
+type Pong struct {
+	Session   NodeID
+	GhostTime int64 // the peer's ghost time when it answered
+	HostTime  int64 // echoed from the ping
+}
+
+// parsePong reads a peer's answer to a ping
+func parsePong(data []byte) (Pong, error) {
+	var pong Pong
+	if !bytes.HasPrefix(data, measurementHeader) || len(data) <= len(measurementHeader) {
+		return pong, fmt.Errorf("not a Link measurement message")
+	}
+	if data[len(measurementHeader)] != messagePong {
+		return pong, fmt.Errorf("not a Link pong")
+	}
+
+	entries, err := parseEntries(data[len(measurementHeader)+1:])
+	if err != nil {
+		return pong, err
+	}
+	session, hasSession := entries[keySession]
+	ghost, hasGhost := entries[keyGhostTime]
+	host, hasHost := entries[keyHostTime]
+	if !hasSession || len(session) != 8 || !hasGhost || len(ghost) != 8 || !hasHost || len(host) != 8 {
+		return pong, fmt.Errorf("incomplete Link pong")
+	}
+	copy(pong.Session[:], session)
+	pong.GhostTime = int64(binary.BigEndian.Uint64(ghost))
+	pong.HostTime = int64(binary.BigEndian.Uint64(host))
+	return pong, nil
+}
@@ -0,0 +1,304 @@
+package link
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+// DefaultQuantum is the bar length, in beats, phase is measured against
+const DefaultQuantum = 4
+
+// Measurement timing: a few pings per measurement, repeated so the offset
+// follows the drift between this machine's clock and the session's
+const (
+	pingsPerMeasurement = 5
+	pongTimeout         = 100 * time.Millisecond
+	measureInterval     = 30 * time.Second
+)
+
+// epoch anchors host time, so it follows the monotonic clock
+var epoch = time.Now()
+
+// hostTime returns t in microseconds of host time
+func hostTime(t time.Time) int64 {
+	return t.Sub(epoch).Microseconds()
+}
+
+// peer is the last announcement of a peer
+type peer struct {
+	state PeerState
+	seen  time.Time
+}
+
+// Watcher follows the Link session on the local network. It commits nothing
+// itself: the service asks it for the tempo of every other execution.
+type Watcher struct {
+	config  common.WatcherConfig
+	quantum float64
+	conn    *net.UDPConn
+	running bool
+	mutex   sync.RWMutex
+	stop    chan struct{}
+
+	peers map[NodeID]peer
+
+	// Offset from host time to the ghost time of a session
+	session    NodeID
+	offset     int64
+	measured   bool
+	measuredAt time.Time
+}
+
+// NewWatcher creates a Link watcher measuring phase in bars of quantum beats
+func NewWatcher(quantum float64) *Watcher {
+	if quantum <= 0 {
+		quantum = DefaultQuantum
+	}
+	return &Watcher{
+		config: common.WatcherConfig{
+			Language:    "link",
+			Environment: "ableton-link",
+			Enabled:     true,
+			Options: map[string]string{
+				"quantum": strconv.FormatFloat(quantum, 'f', -1, 64),
+			},
+		},
+		quantum: quantum,
+		peers:   make(map[NodeID]peer),
+	}
+}
+
+// Start listens for the announcements of Link peers
+func (w *Watcher) Start(callback func(common.ExecutionEvent)) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.running {
+		return fmt.Errorf("Link watcher is already running")
+	}
+
+	addr, err := net.ResolveUDPAddr("udp4", MulticastAddress)
+	if err != nil {
+		return fmt.Errorf("failed to resolve Link multicast address: %w", err)
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, addr)
+	if err != nil {
+		return fmt.Errorf("failed to join Link multicast group: %w", err)
+	}
+
+	w.conn = conn
+	w.stop = make(chan struct{})
+	w.peers = make(map[NodeID]peer)
+	w.measured = false
+	w.running = true
+
+	go w.listen(conn)
+	go w.measureLoop(w.stop)
+
+	return nil
+}
+
+// Stop leaves the multicast group
+func (w *Watcher) Stop() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.running {
+		return nil
+	}
+
+	w.running = false
+	close(w.stop)
+	return w.conn.Close()
+}
+
+// IsRunning returns true if the watcher is active
+func (w *Watcher) IsRunning() bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.running
+}
+
+// GetConfig returns the watcher configuration
+func (w *Watcher) GetConfig() common.WatcherConfig {
+	return w.config
+}
+
+// GetLanguage returns "link"
+func (w *Watcher) GetLanguage() string {
+	return "link"
+}
+
+// GetEnvironment returns "ableton-link"
+func (w *Watcher) GetEnvironment() string {
+	return "ableton-link"
+}
+
+// Tempo returns the tempo of the Link session at the given time, and its
+// beat and phase once the session's clock has been measured
+func (w *Watcher) Tempo(at time.Time) (common.Tempo, bool) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	current, ok := w.currentPeer(at)
+	if !ok {
+		return common.Tempo{}, false
+	}
+
+	timeline := current.state.Timeline
+	tempo := common.Tempo{BPM: timeline.BPM()}
+	if w.measured && w.session == current.state.Session {
+		beats := timeline.BeatsAt(hostTime(at) + w.offset)
+		tempo.Beats = int64(beats)
+		tempo.Phase = Phase(beats, w.quantum)
+	}
+	return tempo, true
+}
+
+// Peers returns how many peers are announcing themselves
+func (w *Watcher) Peers() int {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	count := 0
+	now := time.Now()
+	for _, p := range w.peers {
+		if p.live(now) {
+			count++
+		}
+	}
+	return count
+}
+
+// live reports whether the peer's announcement still holds
+func (p peer) live(at time.Time) bool {
+	return at.Sub(p.seen) < time.Duration(p.state.TTL)*time.Second
+}
+
+// currentPeer returns the live peer with a timeline heard from last. Called
+// with the mutex held.
+func (w *Watcher) currentPeer(at time.Time) (peer, bool) {
+	var current peer
+	found := false
+	for _, p := range w.peers {
+		if !p.live(at) || p.state.Timeline.MicrosPerBeat <= 0 {
+			continue
+		}
+		if !found || p.seen.After(current.seen) {
+			current, found = p, true
+		}
+	}
+	return current, found
+}
+
+// listen reads discovery messages until the watcher stops
+func (w *Watcher) listen(conn *net.UDPConn) {
+	buffer := make([]byte, 65536)
+
+	for w.IsRunning() {
+		conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+		n, err := conn.Read(buffer)
+		if err != nil {
+			if netError, ok := err.(net.Error); ok && netError.Timeout() {
+				continue
+			}
+			if w.IsRunning() {
+				fmt.Printf("Error reading Link message: %v\n", err)
+			}
+			continue
+		}
+
+		state, err := ParseDiscovery(buffer[:n])
+		if err != nil {
+			continue
+		}
+		w.announce(state, time.Now())
+	}
+}
+
+// announce records a peer's discovery message
+func (w *Watcher) announce(state PeerState, at time.Time) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if state.Type == messageByeBye {
+		delete(w.peers, state.NodeID)
+		return
+	}
+	w.peers[state.NodeID] = peer{state: state, seen: at}
+}
+
+// measureLoop keeps the offset to the current session's ghost time fresh
+func (w *Watcher) measureLoop(stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		w.mutex.RLock()
+		current, ok := w.currentPeer(time.Now())
+		stale := !w.measured || w.session != current.state.Session || time.Since(w.measuredAt) >= measureInterval
+		w.mutex.RUnlock()
+
+		if ok && stale && current.state.Endpoint != nil {
+			if err := w.measure(current.state.Endpoint, current.state.Session); err != nil {
+				fmt.Printf("Link clock measurement failed: %v\n", err)
+			}
+		}
+	}
+}
+
+// measure pings a peer of the session for its ghost time, and keeps the
+// median offset between host and ghost time
+func (w *Watcher) measure(endpoint *net.UDPAddr, session NodeID) error {
+	conn, err := net.DialUDP("udp4", nil, endpoint)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var offsets []int64
+	buffer := make([]byte, 512)
+	for i := 0; i < pingsPerMeasurement; i++ {
+		if _, err := conn.Write(encodePing(hostTime(time.Now()))); err != nil {
+			return err
+		}
+
+		conn.SetReadDeadline(time.Now().Add(pongTimeout))
+		n, err := conn.Read(buffer)
+		received := hostTime(time.Now())
+		if err != nil {
+			continue
+		}
+		pong, err := parsePong(buffer[:n])
+		if err != nil || pong.Session != session {
+			continue
+		}
+		// The peer answered halfway between sending and receiving
+		offsets = append(offsets, pong.GhostTime-(pong.HostTime+received)/2)
+	}
+	if len(offsets) == 0 {
+		return fmt.Errorf("no answer from %s", endpoint)
+	}
+
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.session = session
+	w.offset = offsets[len(offsets)/2]
+	w.measured = true
+	w.measuredAt = time.Now()
+	return nil
+}
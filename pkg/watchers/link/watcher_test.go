@@ -0,0 +1,145 @@
+package link
+
+import (
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+// discovery builds the discovery message of a peer
+func discovery(messageType byte, node, session NodeID, timeline Timeline, endpoint *net.UDPAddr) []byte {
+	data := append(append([]byte{}, discoveryHeader...), messageType, 5, 0, 0)
+	data = append(data, node[:]...)
+
+	var value []byte
+	value = append(value, encodeInt64(timeline.MicrosPerBeat)...)
+	value = append(value, encodeInt64(timeline.BeatOrigin)...)
+	value = append(value, encodeInt64(timeline.TimeOrigin)...)
+	data = appendEntry(data, keyTimeline, value)
+	data = appendEntry(data, keySession, session[:])
+
+	if endpoint != nil {
+		ip := endpoint.IP.To4()
+		data = appendEntry(data, keyEndpointV4, append(ip, byte(endpoint.Port>>8), byte(endpoint.Port)))
+	}
+	return data
+}
+
+// respond answers pings like a peer whose ghost time is host time plus offset
+func respond(t *testing.T, session NodeID, offset int64) *net.UDPConn {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	go func() {
+		buffer := make([]byte, 512)
+		for {
+			n, from, err := conn.ReadFromUDP(buffer)
+			if err != nil {
+				return
+			}
+			if n <= len(measurementHeader) || buffer[len(measurementHeader)] != messagePing {
+				continue
+			}
+			pong := append(append([]byte{}, measurementHeader...), messagePong)
+			pong = appendEntry(pong, keySession, session[:])
+			pong = appendEntry(pong, keyGhostTime, encodeInt64(hostTime(time.Now())+offset))
+			pong = append(pong, buffer[len(measurementHeader)+1:n]...)
+			conn.WriteToUDP(pong, from)
+		}
+	}()
+	return conn
+}
+
+func TestParseDiscovery(t *testing.T) {
+	node, session := NodeID{1}, NodeID{2}
+	endpoint := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 20), Port: 35000}
+	timeline := Timeline{MicrosPerBeat: 500000, BeatOrigin: 8000000, TimeOrigin: 1000}
+
+	state, err := ParseDiscovery(discovery(messageAlive, node, session, timeline, endpoint))
+	if err != nil {
+		t.Fatalf("Failed to parse discovery message: %v", err)
+	}
+	if state.NodeID != node || state.Session != session || state.TTL != 5 || state.Timeline != timeline {
+		t.Errorf("Expected the peer's state, got %+v", state)
+	}
+	if state.Endpoint == nil || state.Endpoint.String() != endpoint.String() {
+		t.Errorf("Expected endpoint %s, got %v", endpoint, state.Endpoint)
+	}
+	if bpm := state.Timeline.BPM(); bpm != 120 {
+		t.Errorf("Expected 120 BPM, got %v", bpm)
+	}
+	// Beat 8 at the origin, a beat every half second
+	if beats := timeline.BeatsAt(1000 + 1500000); beats != 11 {
+		t.Errorf("Expected beat 11, got %v", beats)
+	}
+
+	for _, data := range [][]byte{[]byte("hello"), discoveryHeader, append(discovery(messageAlive, node, session, timeline, nil), 1, 2)} {
+		if _, err := ParseDiscovery(data); err == nil {
+			t.Errorf("Expected %q to be refused", data)
+		}
+	}
+}
+
+func TestPhase(t *testing.T) {
+	for _, test := range []struct{ beats, quantum, phase float64 }{
+		{5.5, 4, 1.5}, {-0.5, 4, 3.5}, {3, 3, 0}, {1, 0, 0},
+	} {
+		if phase := Phase(test.beats, test.quantum); phase != test.phase {
+			t.Errorf("Expected phase %v of beat %v in %v, got %v", test.phase, test.beats, test.quantum, phase)
+		}
+	}
+}
+
+func TestWatcherTempo(t *testing.T) {
+	watcher := NewWatcher(4)
+	session := NodeID{7}
+	offset := int64(42 * time.Second / time.Microsecond)
+
+	responder := respond(t, session, offset)
+	defer responder.Close()
+
+	if _, ok := watcher.Tempo(time.Now()); ok {
+		t.Errorf("Expected no tempo without peers")
+	}
+
+	// Beat 0 at ghost time 0, at 120 BPM
+	timeline := Timeline{MicrosPerBeat: 500000}
+	endpoint := responder.LocalAddr().(*net.UDPAddr)
+	watcher.announce(mustParse(t, discovery(messageAlive, NodeID{1}, session, timeline, endpoint)), time.Now())
+
+	tempo, ok := watcher.Tempo(time.Now())
+	if !ok || tempo.BPM != 120 || tempo.Beats != 0 || tempo.Phase != 0 {
+		t.Errorf("Expected 120 BPM and no position before measuring, got %+v", tempo)
+	}
+
+	if err := watcher.measure(endpoint, session); err != nil {
+		t.Fatalf("Failed to measure: %v", err)
+	}
+
+	at := time.Now()
+	tempo, _ = watcher.Tempo(at)
+	expected := float64(hostTime(at)+offset) / 500000
+	if tempo.Beats != int64(expected) {
+		t.Errorf("Expected beat %d, got %d", int64(expected), tempo.Beats)
+	}
+	if math.Abs(tempo.Phase-Phase(expected, 4)) > 0.01 {
+		t.Errorf("Expected phase %.3f, got %.3f", Phase(expected, 4), tempo.Phase)
+	}
+
+	// The peer leaving takes the tempo with it
+	watcher.announce(mustParse(t, discovery(messageByeBye, NodeID{1}, session, timeline, nil)), time.Now())
+	if _, ok := watcher.Tempo(time.Now()); ok {
+		t.Errorf("Expected no tempo after the peer left")
+	}
+}
+
+func mustParse(t *testing.T, data []byte) PeerState {
+	state, err := ParseDiscovery(data)
+	if err != nil {
+		t.Fatalf("Failed to parse discovery message: %v", err)
+	}
+	return state
+}
@@ -0,0 +1,509 @@
+package watchers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/export"
+	"github.com/livecodegit/pkg/journal"
+	"github.com/livecodegit/pkg/storage"
+)
+
+// Maintenance tasks the service can run while idle
+const (
+	MaintenanceGC            = "gc"             // report unreachable objects without deleting them
+	MaintenancePrune         = "prune"          // discard executions left in the inbox longer than prune_age
+	MaintenanceBackup        = "backup"         // archive the repository into backup_dir
+	MaintenanceIndexSnapshot = "index-snapshot" // save a copy of the index
+	MaintenanceFsck          = "fsck"           // check the integrity of the repository
+	MaintenanceRepack        = "repack"         // move loose objects into a packfile
+)
+
+// MaintenanceTasks lists every maintenance task
+var MaintenanceTasks = []string{MaintenanceGC, MaintenancePrune, MaintenanceBackup, MaintenanceIndexSnapshot, MaintenanceFsck, MaintenanceRepack}
+
+// MaintenanceConfigName is the name 'lcg watch --set' takes for the
+// maintenance settings, as in maintenance.enabled=true
+const MaintenanceConfigName = "maintenance"
+
+// MaintenanceFile records the last result of each maintenance task
+const MaintenanceFile = "maintenance.json"
+
+// maintenanceCheckInterval is how often the scheduler looks for due tasks
+const maintenanceCheckInterval = time.Minute
+
+// MaintenanceResult is the outcome of the last run of a maintenance task
+type MaintenanceResult struct {
+	Task     string        `json:"task"`
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+	Success  bool          `json:"success"`
+	Message  string        `json:"message"`
+}
+
+// maintenanceSchedule is a parsed MaintenanceConfig
+type maintenanceSchedule struct {
+	tasks       []string
+	interval    time.Duration
+	idle        time.Duration
+	window      *timeWindow // nil when tasks may start any time
+	backupDir   string
+	keepBackups int
+	pruneAge    time.Duration
+}
+
+// timeWindow is a daily span of time, as offsets from midnight; a window
+// ending before it starts goes past midnight
+type timeWindow struct {
+	start, end time.Duration
+}
+
+// parseTimeWindow parses a window such as 02:00-06:00
+func parseTimeWindow(s string) (*timeWindow, error) {
+	from, to, found := strings.Cut(s, "-")
+	if !found {
+		return nil, fmt.Errorf("invalid window %q (expected e.g. 02:00-06:00)", s)
+	}
+
+	var offsets [2]time.Duration
+	for i, part := range []string{from, to} {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q (expected e.g. 02:00-06:00)", s)
+		}
+		offsets[i] = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	if offsets[0] == offsets[1] {
+		return nil, fmt.Errorf("invalid window %q: it is empty", s)
+	}
+
+	return &timeWindow{start: offsets[0], end: offsets[1]}, nil
+}
+
+// contains reports whether t falls within the window
+func (w *timeWindow) contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.start < w.end {
+		return offset >= w.start && offset < w.end
+	}
+	return offset >= w.start || offset < w.end
+}
+
+// parseMaintenanceConfig checks a maintenance configuration and parses its
+// durations and window
+func parseMaintenanceConfig(config MaintenanceConfig) (*maintenanceSchedule, error) {
+	schedule := &maintenanceSchedule{
+		backupDir:   config.BackupDir,
+		keepBackups: config.KeepBackups,
+	}
+
+	seen := make(map[string]bool)
+	for _, task := range config.Tasks {
+		if !isMaintenanceTask(task) {
+			return nil, fmt.Errorf("unknown task %q (expected %s)", task, strings.Join(MaintenanceTasks, ", "))
+		}
+		if seen[task] {
+			return nil, fmt.Errorf("task %s is listed twice", task)
+		}
+		seen[task] = true
+		schedule.tasks = append(schedule.tasks, task)
+	}
+
+	durations := []struct {
+		name  string
+		value string
+		into  *time.Duration
+	}{
+		{"interval", config.Interval, &schedule.interval},
+		{"idle", config.Idle, &schedule.idle},
+		{"prune_age", config.PruneAge, &schedule.pruneAge},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid %s: %s", d.name, d.value)
+		}
+		*d.into = parsed
+	}
+	if schedule.interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive")
+	}
+	if seen[MaintenancePrune] && schedule.pruneAge <= 0 {
+		return nil, fmt.Errorf("prune needs a positive prune_age")
+	}
+	if seen[MaintenanceBackup] && schedule.backupDir == "" {
+		return nil, fmt.Errorf("backup needs a backup_dir")
+	}
+	if schedule.keepBackups < 0 {
+		return nil, fmt.Errorf("invalid keep_backups: %d", schedule.keepBackups)
+	}
+
+	if config.Window != "" {
+		window, err := parseTimeWindow(config.Window)
+		if err != nil {
+			return nil, err
+		}
+		schedule.window = window
+	}
+
+	return schedule, nil
+}
+
+// validateMaintenanceConfig validates the maintenance configuration
+func validateMaintenanceConfig(config MaintenanceConfig) error {
+	_, err := parseMaintenanceConfig(config)
+	return err
+}
+
+// isMaintenanceTask reports whether task names a maintenance task
+func isMaintenanceTask(task string) bool {
+	for _, known := range MaintenanceTasks {
+		if task == known {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMaintenanceOption sets one maintenance setting from its JSON name
+func (cm *ConfigManager) SetMaintenanceOption(option, value string) error {
+	config := &cm.config.Maintenance
+
+	switch option {
+	case "enabled":
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid enabled: %s", value)
+		}
+		config.Enabled = enabled
+	case "tasks":
+		config.Tasks = nil
+		for _, task := range strings.Split(value, ",") {
+			if task = strings.TrimSpace(task); task != "" {
+				config.Tasks = append(config.Tasks, task)
+			}
+		}
+	case "interval":
+		config.Interval = value
+	case "idle":
+		config.Idle = value
+	case "window":
+		config.Window = value
+	case "backup_dir":
+		config.BackupDir = value
+	case "keep_backups":
+		keep, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid keep_backups: %s", value)
+		}
+		config.KeepBackups = keep
+	case "prune_age":
+		config.PruneAge = value
+	default:
+		return fmt.Errorf("unknown maintenance option '%s'", option)
+	}
+
+	return nil
+}
+
+// GetMaintenancePath returns the file recording maintenance results
+func GetMaintenancePath(repoPath string) string {
+	return filepath.Join(repoPath, storage.RepoDir, MaintenanceFile)
+}
+
+// ReadMaintenanceResults returns the last result of each maintenance task
+// that has run in a repository
+func ReadMaintenanceResults(repoPath string) (map[string]MaintenanceResult, error) {
+	results := make(map[string]MaintenanceResult)
+
+	data, err := os.ReadFile(GetMaintenancePath(repoPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return results, nil
+		}
+		return nil, fmt.Errorf("failed to read maintenance results: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse maintenance results: %w", err)
+	}
+	return results, nil
+}
+
+// writeMaintenanceResults saves the last result of each maintenance task
+func writeMaintenanceResults(repoPath string, results map[string]MaintenanceResult) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal maintenance results: %w", err)
+	}
+
+	if err := os.WriteFile(GetMaintenancePath(repoPath), data, 0644); err != nil {
+		return fmt.Errorf("failed to write maintenance results: %w", err)
+	}
+	return nil
+}
+
+// startMaintenance schedules the configured maintenance while the service
+// runs; called with ws.mutex held
+func (ws *WatcherService) startMaintenance() {
+	config := ws.configManager.GetConfig().Maintenance
+	if !config.Enabled || len(config.Tasks) == 0 {
+		return
+	}
+
+	schedule, err := parseMaintenanceConfig(config)
+	if err != nil {
+		log.Printf("Maintenance not scheduled: %v", err)
+		ws.journal.Error(journal.EventMaintenance, fmt.Sprintf("maintenance not scheduled: %v", err))
+		return
+	}
+
+	stop := make(chan struct{})
+	ws.maintenanceStop = stop
+	go ws.maintenanceLoop(schedule, stop)
+
+	description := fmt.Sprintf("maintenance scheduled: %s every %s after %s idle",
+		strings.Join(schedule.tasks, ", "), schedule.interval, schedule.idle)
+	if config.Window != "" {
+		description += " within " + config.Window
+	}
+	ws.journal.Info(journal.EventMaintenance, description)
+}
+
+// stopMaintenance stops scheduling maintenance; a task already running
+// finishes. Called with ws.mutex held.
+func (ws *WatcherService) stopMaintenance() {
+	if ws.maintenanceStop != nil {
+		close(ws.maintenanceStop)
+		ws.maintenanceStop = nil
+	}
+}
+
+// maintenanceLoop looks for due tasks until stop is closed
+func (ws *WatcherService) maintenanceLoop(schedule *maintenanceSchedule, stop <-chan struct{}) {
+	ticker := time.NewTicker(maintenanceCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			ws.runDueMaintenance(schedule, now, stop)
+		}
+	}
+}
+
+// runDueMaintenance runs, in order, the tasks whose interval has passed since
+// their last run, as long as the service stays idle and within the window
+func (ws *WatcherService) runDueMaintenance(schedule *maintenanceSchedule, now time.Time, stop <-chan struct{}) []MaintenanceResult {
+	if schedule.window != nil && !schedule.window.contains(now) {
+		return nil
+	}
+
+	repoPath := ws.repository.GetPath()
+	results, err := ReadMaintenanceResults(repoPath)
+	if err != nil {
+		log.Printf("Skipping maintenance: %v", err)
+		ws.eventJournal().Error(journal.EventMaintenance, fmt.Sprintf("skipping maintenance: %v", err))
+		return nil
+	}
+
+	var ran []MaintenanceResult
+	for _, task := range schedule.tasks {
+		if last, ok := results[task]; ok && now.Sub(last.Time) < schedule.interval {
+			continue
+		}
+		// An execution arriving meanwhile postpones the remaining tasks
+		if !ws.idleSince(time.Now(), schedule.idle) {
+			break
+		}
+		select {
+		case <-stop:
+			return ran
+		default:
+		}
+
+		result := ws.runMaintenanceTask(schedule, task)
+		ran = append(ran, result)
+		results[task] = result
+		if err := writeMaintenanceResults(repoPath, results); err != nil {
+			log.Printf("Failed to record maintenance: %v", err)
+		}
+
+		log.Printf("Maintenance %s: %s", task, result.Message)
+		fields := []string{"task", task, "duration", result.Duration.Round(time.Millisecond).String()}
+		if result.Success {
+			ws.eventJournal().Info(journal.EventMaintenance, result.Message, fields...)
+		} else {
+			ws.eventJournal().Error(journal.EventMaintenance, result.Message, fields...)
+		}
+	}
+	return ran
+}
+
+// idleSince reports whether no execution arrived for idle before now
+func (ws *WatcherService) idleSince(now time.Time, idle time.Duration) bool {
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+
+	last := ws.lastExecution
+	if last.Before(ws.startedAt) {
+		last = ws.startedAt
+	}
+	return now.Sub(last) >= idle
+}
+
+// runMaintenanceTask runs one task, holding off commits meanwhile
+func (ws *WatcherService) runMaintenanceTask(schedule *maintenanceSchedule, task string) MaintenanceResult {
+	start := time.Now()
+
+	ws.repoMutex.Lock()
+	message, err := ws.maintain(schedule, task, start)
+	ws.repoMutex.Unlock()
+
+	result := MaintenanceResult{Task: task, Time: start, Duration: time.Since(start), Success: err == nil, Message: message}
+	if err != nil {
+		result.Message = err.Error()
+	}
+	return result
+}
+
+// maintain performs a maintenance task and describes what it did
+func (ws *WatcherService) maintain(schedule *maintenanceSchedule, task string, now time.Time) (string, error) {
+	repo := ws.repository
+
+	switch task {
+	case MaintenanceGC:
+		// Only a dry run: nobody is there to notice history going missing
+		result, err := repo.GC(true)
+		if err != nil {
+			return "", err
+		}
+		summary := fmt.Sprintf("found %d unreachable objects and %d unused blobs (%d bytes) for 'lcg gc'", len(result.Unreachable), len(result.Blobs), result.Bytes)
+		if len(result.Unreadable) > 0 {
+			summary += fmt.Sprintf("; kept every blob, %d reachable objects can't be read", len(result.Unreadable))
+		}
+		return summary, nil
+
+	case MaintenancePrune:
+		count, err := ws.pending.Prune(now.Add(-schedule.pruneAge))
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("discarded %d pending executions older than %s", count, schedule.pruneAge), nil
+
+	case MaintenanceBackup:
+		path, err := ws.writeBackup(schedule.backupDir, schedule.keepBackups, now)
+		if err != nil {
+			return "", err
+		}
+		return "backed up to " + path, nil
+
+	case MaintenanceIndexSnapshot:
+		if err := repo.SnapshotIndex(); err != nil {
+			return "", err
+		}
+		return "saved index snapshot", nil
+
+	case MaintenanceFsck:
+		result, err := repo.Fsck(false)
+		if err != nil {
+			return "", err
+		}
+		if len(result.Problems) > 0 {
+			return "", fmt.Errorf("%d problems in %d objects; run 'lcg fsck' for details", len(result.Problems), result.Objects)
+		}
+		return fmt.Sprintf("checked %d objects", result.Objects), nil
+
+	case MaintenanceRepack:
+		result, err := repo.Repack()
+		if err != nil {
+			return "", err
+		}
+		if result.Pack == "" {
+			return "nothing to repack", nil
+		}
+		return fmt.Sprintf("packed %d objects into %s", result.Objects, result.Pack), nil
+	}
+
+	return "", fmt.Errorf("unknown maintenance task %s", task)
+}
+
+// writeBackup archives the repository into dir as <repository>-<time>.tar.gz
+// and deletes all but the keep newest backups; keep 0 keeps every backup
+func (ws *WatcherService) writeBackup(dir string, keep int, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	archive, err := export.RepositoryArchive(ws.repository)
+	if err != nil {
+		return "", err
+	}
+
+	// Written aside first, so a failed backup isn't taken for a good one
+	prefix := filepath.Base(ws.repository.GetPath()) + "-"
+	path := filepath.Join(dir, prefix+now.Format("20060102-150405")+".tar.gz")
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create backup: %w", err)
+	}
+	err = export.WriteArchive(file, export.ArchiveTarGz, archive)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		os.Remove(path + ".tmp")
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	if keep > 0 {
+		if err := removeOldBackups(dir, prefix, keep); err != nil {
+			return "", err
+		}
+	}
+	return path, nil
+}
+
+// removeOldBackups deletes all but the keep newest backups named with prefix
+func removeOldBackups(dir, prefix string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		stamp, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok {
+			continue
+		}
+		if _, err := time.Parse("20060102-150405.tar.gz", stamp); err == nil {
+			backups = append(backups, entry.Name())
+		}
+	}
+
+	// Names end in their time, so they sort oldest first
+	sort.Strings(backups)
+	for len(backups) > keep {
+		if err := os.Remove(filepath.Join(dir, backups[0])); err != nil {
+			return fmt.Errorf("failed to remove old backup: %w", err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package watchers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/storage"
+)
+
+func TestTimeWindow(t *testing.T) {
+	at := func(clock string) time.Time {
+		parsed, _ := time.Parse("15:04", clock)
+		return time.Date(2026, 10, 15, parsed.Hour(), parsed.Minute(), 0, 0, time.Local)
+	}
+
+	night, err := parseTimeWindow("02:00-06:00")
+	if err != nil {
+		t.Fatalf("Failed to parse window: %v", err)
+	}
+	if !night.contains(at("03:30")) || night.contains(at("06:00")) || night.contains(at("23:00")) {
+		t.Errorf("Expected 02:00-06:00 to contain only 03:30")
+	}
+
+	// A window may run past midnight
+	late, err := parseTimeWindow("22:00-04:00")
+	if err != nil {
+		t.Fatalf("Failed to parse window: %v", err)
+	}
+	if !late.contains(at("23:30")) || !late.contains(at("01:00")) || late.contains(at("12:00")) {
+		t.Errorf("Expected 22:00-04:00 to contain 23:30 and 01:00 but not 12:00")
+	}
+
+	for _, invalid := range []string{"2-6", "02:00", "03:00-03:00", "25:00-02:00"} {
+		if _, err := parseTimeWindow(invalid); err == nil {
+			t.Errorf("Expected window %q to be invalid", invalid)
+		}
+	}
+}
+
+func TestParseMaintenanceConfig(t *testing.T) {
+	if _, err := parseMaintenanceConfig(DefaultGlobalConfig().Maintenance); err != nil {
+		t.Errorf("Expected the default maintenance config to be valid: %v", err)
+	}
+
+	invalid := map[string]func(*MaintenanceConfig){
+		"unknown task":       func(c *MaintenanceConfig) { c.Tasks = []string{"vacuum"} },
+		"duplicate task":     func(c *MaintenanceConfig) { c.Tasks = []string{MaintenanceGC, MaintenanceGC} },
+		"backup without dir": func(c *MaintenanceConfig) { c.Tasks = []string{MaintenanceBackup} },
+		"bad interval":       func(c *MaintenanceConfig) { c.Interval = "nightly" },
+		"no interval":        func(c *MaintenanceConfig) { c.Interval = "" },
+		"negative keep":      func(c *MaintenanceConfig) { c.KeepBackups = -1 },
+		"bad window":         func(c *MaintenanceConfig) { c.Window = "night" },
+	}
+	for name, modify := range invalid {
+		config := DefaultGlobalConfig().Maintenance
+		modify(&config)
+		if _, err := parseMaintenanceConfig(config); err == nil {
+			t.Errorf("Expected %s to be invalid", name)
+		}
+	}
+}
+
+func TestSetMaintenanceOption(t *testing.T) {
+	service, configDir := createTestWatcherService(t)
+	defer os.RemoveAll(configDir)
+	defer os.RemoveAll(service.repository.GetPath())
+
+	if err := service.SetWatcherOption(MaintenanceConfigName, "tasks", "gc, fsck"); err != nil {
+		t.Fatalf("Failed to set maintenance tasks: %v", err)
+	}
+	if err := service.SetWatcherOption(MaintenanceConfigName, "enabled", "true"); err != nil {
+		t.Fatalf("Failed to enable maintenance: %v", err)
+	}
+
+	config := service.configManager.GetConfig().Maintenance
+	if !config.Enabled || len(config.Tasks) != 2 || config.Tasks[1] != MaintenanceFsck {
+		t.Errorf("Expected gc and fsck enabled, got %+v", config)
+	}
+
+	if err := service.SetWatcherOption(MaintenanceConfigName, "tasks", "gc,backup"); err == nil {
+		t.Errorf("Expected backup without backup_dir to be refused")
+	}
+	if err := service.SetWatcherOption(MaintenanceConfigName, "cron", "0 3 * * *"); err == nil {
+		t.Errorf("Expected unknown maintenance option to be refused")
+	}
+}
+
+func TestRunDueMaintenance(t *testing.T) {
+	service, configDir := createTestWatcherService(t)
+	defer os.RemoveAll(configDir)
+	defer os.RemoveAll(service.repository.GetPath())
+
+	repo := service.repository
+	repoPath := repo.GetPath()
+	for _, buffer := range []string{"d1", "d2"} {
+		if _, err := repo.Commit(buffer+" $ s \"bd\"", "Pattern", core.ExecutionMetadata{Buffer: buffer, Language: "tidal", Success: true}); err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+	}
+	if _, err := service.pending.Add(ExecutionEvent{Content: "hush", Buffer: "d1", Language: "tidal"}, "Stale"); err != nil {
+		t.Fatalf("Failed to add pending event: %v", err)
+	}
+	store := storage.NewFileSystemStorage(repoPath)
+	orphan := &storage.Commit{Timestamp: time.Now(), Message: "Orphan", Content: "hush"}
+	orphan.Hash = storage.CanonicalHash(orphan)
+	if err := store.WriteCommit(orphan); err != nil {
+		t.Fatalf("Failed to write orphan: %v", err)
+	}
+
+	backupDir, err := os.MkdirTemp("", "livecodegit-backup-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(backupDir)
+
+	schedule, err := parseMaintenanceConfig(MaintenanceConfig{
+		Tasks:     MaintenanceTasks,
+		Interval:  "24h",
+		Idle:      "30m",
+		BackupDir: backupDir,
+		PruneAge:  "1ns",
+	})
+	if err != nil {
+		t.Fatalf("Failed to parse maintenance config: %v", err)
+	}
+
+	// Nothing runs right after an execution
+	now := time.Now()
+	service.lastExecution = now
+	if ran := service.runDueMaintenance(schedule, now, nil); len(ran) != 0 {
+		t.Errorf("Expected no maintenance while busy, got %d tasks", len(ran))
+	}
+
+	service.lastExecution = now.Add(-time.Hour)
+	ran := service.runDueMaintenance(schedule, now, nil)
+	if len(ran) != len(MaintenanceTasks) {
+		t.Fatalf("Expected every task to run, got %d", len(ran))
+	}
+	for _, result := range ran {
+		if !result.Success {
+			t.Errorf("Expected %s to succeed, got: %s", result.Task, result.Message)
+		}
+	}
+
+	if count, _ := service.pending.Count(); count != 0 {
+		t.Errorf("Expected the stale pending execution to be pruned, got %d", count)
+	}
+	if _, err := os.Stat(filepath.Join(repoPath, storage.RepoDir, storage.IndexSnapshotFile)); err != nil {
+		t.Errorf("Expected an index snapshot: %v", err)
+	}
+	if backups, _ := os.ReadDir(backupDir); len(backups) != 1 {
+		t.Errorf("Expected one backup, got %d", len(backups))
+	}
+	// gc only reports while unattended
+	if !store.Exists(orphan.Hash) {
+		t.Errorf("Expected gc to keep the orphan")
+	}
+
+	results, err := ReadMaintenanceResults(repoPath)
+	if err != nil {
+		t.Fatalf("Failed to read maintenance results: %v", err)
+	}
+	if len(results) != len(MaintenanceTasks) || !results[MaintenanceFsck].Success {
+		t.Errorf("Expected a recorded result for every task, got %+v", results)
+	}
+	if message := results[MaintenanceGC].Message; !strings.HasPrefix(message, "found 1 unreachable objects") {
+		t.Errorf("Expected gc to report the orphan, got %q", message)
+	}
+
+	// Tasks wait for their interval, which outlives the service
+	if ran := service.runDueMaintenance(schedule, now.Add(time.Hour), nil); len(ran) != 0 {
+		t.Errorf("Expected no task due an hour later, got %d", len(ran))
+	}
+	if ran := service.runDueMaintenance(schedule, now.Add(25*time.Hour), nil); len(ran) != len(MaintenanceTasks) {
+		t.Errorf("Expected every task due a day later, got %d", len(ran))
+	}
+
+	// Outside the window nothing starts, even when due
+	if schedule.window, err = parseTimeWindow("02:00-06:00"); err != nil {
+		t.Fatalf("Failed to parse window: %v", err)
+	}
+	noon := time.Date(now.Year(), now.Month(), now.Day()+3, 12, 0, 0, 0, time.Local)
+	if ran := service.runDueMaintenance(schedule, noon, nil); len(ran) != 0 {
+		t.Errorf("Expected no maintenance outside the window, got %d tasks", len(ran))
+	}
+}
+
+func TestWriteBackupKeepsNewest(t *testing.T) {
+	service, configDir := createTestWatcherService(t)
+	defer os.RemoveAll(configDir)
+	defer os.RemoveAll(service.repository.GetPath())
+
+	if _, err := service.repository.Commit("d1 $ s \"bd\"", "Kick", core.ExecutionMetadata{Buffer: "d1", Language: "tidal", Success: true}); err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+
+	backupDir, err := os.MkdirTemp("", "livecodegit-backup-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(backupDir)
+
+	// Files that aren't this repository's backups are left alone
+	other := filepath.Join(backupDir, "notes.txt")
+	if err := os.WriteFile(other, []byte("setlist"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	start := time.Date(2026, 10, 15, 3, 0, 0, 0, time.Local)
+	var paths []string
+	for i := 0; i < 3; i++ {
+		path, err := service.writeBackup(backupDir, 2, start.Add(time.Duration(i)*24*time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to write backup: %v", err)
+		}
+		paths = append(paths, path)
+	}
+
+	if _, err := os.Stat(paths[0]); !os.IsNotExist(err) {
+		t.Errorf("Expected the oldest backup to be removed")
+	}
+	for _, path := range append(paths[1:], other) {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be kept: %v", filepath.Base(path), err)
+		}
+	}
+}
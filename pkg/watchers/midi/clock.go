@@ -0,0 +1,133 @@
+// Package midi follows an incoming MIDI clock, so commits from the other
+// watchers carry the tempo and beat position the music is actually at.
+package midi
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+// ClocksPerBeat is the resolution of MIDI clock: 24 pulses per quarter note
+const ClocksPerBeat = 24
+
+// MIDI system real-time and common messages the clock follows
+const (
+	statusSongPosition = 0xF2
+	statusClock        = 0xF8
+	statusStart        = 0xFA
+	statusContinue     = 0xFB
+	statusStop         = 0xFC
+)
+
+// staleAfter is how long without a clock pulse before the tempo is no
+// longer trusted
+const staleAfter = 2 * time.Second
+
+// minIntervals is how many pulse intervals are needed before estimating a
+// tempo, a quarter of a beat
+const minIntervals = ClocksPerBeat / 4
+
+// Clock estimates tempo and position from a stream of MIDI bytes. The tempo
+// is averaged over the last beat's worth of pulses.
+type Clock struct {
+	mutex sync.Mutex
+
+	intervals [ClocksPerBeat]time.Duration
+	count     int // intervals recorded, up to ClocksPerBeat
+	next      int
+	lastPulse time.Time
+
+	// Pulses since start or the last song position. Without start, stop
+	// or continue ever received, every pulse counts.
+	pulses    int64
+	playing   bool
+	transport bool
+
+	// Song position pointer being read
+	positionBytes []byte
+	readingData   bool
+}
+
+// NewClock creates a clock that hasn't seen any pulse
+func NewClock() *Clock {
+	return &Clock{}
+}
+
+// Feed reads one byte of MIDI received at the given time. Real-time
+// messages may arrive between the bytes of other messages, as MIDI allows.
+func (c *Clock) Feed(b byte, at time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	switch {
+	case b >= statusClock:
+		c.realTime(b, at)
+	case b == statusSongPosition:
+		c.positionBytes = c.positionBytes[:0]
+		c.readingData = true
+	case b >= 0x80:
+		// Any other message; its data bytes are skipped
+		c.readingData = false
+	case c.readingData:
+		c.positionBytes = append(c.positionBytes, b)
+		if len(c.positionBytes) == 2 {
+			// Counted in sixteenth notes, six pulses each
+			sixteenths := int64(c.positionBytes[0]) | int64(c.positionBytes[1])<<7
+			c.pulses = sixteenths * ClocksPerBeat / 4
+			c.readingData = false
+		}
+	}
+}
+
+// realTime handles a system real-time message. Called with the mutex held.
+func (c *Clock) realTime(b byte, at time.Time) {
+	switch b {
+	case statusClock:
+		if !c.lastPulse.IsZero() {
+			interval := at.Sub(c.lastPulse)
+			if interval <= 0 || interval >= staleAfter {
+				c.count, c.next = 0, 0
+			} else {
+				c.intervals[c.next] = interval
+				c.next = (c.next + 1) % ClocksPerBeat
+				if c.count < ClocksPerBeat {
+					c.count++
+				}
+			}
+		}
+		c.lastPulse = at
+		if c.playing || !c.transport {
+			c.pulses++
+		}
+	case statusStart:
+		c.pulses = 0
+		c.playing, c.transport = true, true
+	case statusContinue:
+		c.playing, c.transport = true, true
+	case statusStop:
+		c.playing, c.transport = false, true
+	}
+}
+
+// Tempo returns the tempo and beat position at the given time, or false
+// when the clock isn't running
+func (c *Clock) Tempo(at time.Time) (common.Tempo, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.count < minIntervals || at.Sub(c.lastPulse) >= staleAfter {
+		return common.Tempo{}, false
+	}
+
+	var total time.Duration
+	for _, interval := range c.intervals[:c.count] {
+		total += interval
+	}
+	beat := total * ClocksPerBeat / time.Duration(c.count)
+	bpm := math.Round(float64(time.Minute)/float64(beat)*10) / 10
+
+	return common.Tempo{BPM: bpm, Beats: c.pulses / ClocksPerBeat}, true
+}
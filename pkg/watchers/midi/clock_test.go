@@ -0,0 +1,86 @@
+package midi
+
+import (
+	"testing"
+	"time"
+)
+
+// pulses feeds n clock pulses at the given tempo, returning the time after
+func pulses(clock *Clock, start time.Time, n int, bpm float64) time.Time {
+	interval := time.Duration(float64(time.Minute) / bpm / ClocksPerBeat)
+	at := start
+	for i := 0; i < n; i++ {
+		clock.Feed(statusClock, at)
+		at = at.Add(interval)
+	}
+	return at
+}
+
+func TestClockTempo(t *testing.T) {
+	clock := NewClock()
+	start := time.Now()
+
+	if _, ok := clock.Tempo(start); ok {
+		t.Errorf("Expected no tempo before any pulse")
+	}
+
+	// Without transport messages, every pulse counts
+	at := pulses(clock, start, 2*ClocksPerBeat+1, 120)
+	tempo, ok := clock.Tempo(at)
+	if !ok {
+		t.Fatalf("Expected a tempo after two beats of clock")
+	}
+	if tempo.BPM != 120 || tempo.Beats != 2 {
+		t.Errorf("Expected 120 BPM at beat 2, got %+v", tempo)
+	}
+
+	// The estimate follows tempo changes within a beat
+	at = pulses(clock, at, ClocksPerBeat+1, 90)
+	if tempo, _ := clock.Tempo(at); tempo.BPM != 90 {
+		t.Errorf("Expected 90 BPM after a beat at 90, got %v", tempo.BPM)
+	}
+
+	// The clock stopping makes the tempo stale
+	if _, ok := clock.Tempo(at.Add(staleAfter)); ok {
+		t.Errorf("Expected no tempo once the clock stopped")
+	}
+}
+
+func TestClockTransport(t *testing.T) {
+	clock := NewClock()
+	start := time.Now()
+
+	clock.Feed(statusStart, start)
+	at := pulses(clock, start, 4*ClocksPerBeat, 120)
+	if tempo, _ := clock.Tempo(at); tempo.Beats != 4 {
+		t.Errorf("Expected beat 4 after start, got %d", tempo.Beats)
+	}
+
+	// Stopped, pulses keep the tempo but not the position
+	clock.Feed(statusStop, at)
+	at = pulses(clock, at, ClocksPerBeat, 120)
+	if tempo, ok := clock.Tempo(at); !ok || tempo.Beats != 4 {
+		t.Errorf("Expected to stay at beat 4 while stopped, got %+v", tempo)
+	}
+
+	// Song position 32 sixteenths is beat 8, with a pulse in between
+	clock.Feed(statusSongPosition, at)
+	clock.Feed(32, at)
+	clock.Feed(statusClock, at)
+	clock.Feed(0, at)
+	clock.Feed(statusContinue, at)
+	at = pulses(clock, at, ClocksPerBeat, 120)
+	if tempo, _ := clock.Tempo(at); tempo.Beats != 9 {
+		t.Errorf("Expected beat 9 a beat after song position 8, got %d", tempo.Beats)
+	}
+
+	// Start goes back to the beginning; other messages' data is ignored
+	clock.Feed(statusStart, at)
+	clock.Feed(0x90, at)
+	clock.Feed(60, at)
+	clock.Feed(100, at)
+	at = pulses(clock, at, ClocksPerBeat, 120)
+	if tempo, _ := clock.Tempo(at); tempo.Beats != 1 {
+		t.Errorf("Expected beat 1 after restarting, got %d", tempo.Beats)
+	}
+}
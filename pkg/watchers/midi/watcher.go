@@ -0,0 +1,153 @@
+package midi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+// DevicePattern matches the raw MIDI devices of ALSA
+const DevicePattern = "/dev/snd/midiC*D*"
+
+// ClockWatcher reads MIDI clock from a raw MIDI device. It commits nothing
+// itself: the service asks it for the tempo of every other execution.
+type ClockWatcher struct {
+	config  common.WatcherConfig
+	device  string
+	clock   *Clock
+	file    *os.File
+	running bool
+	mutex   sync.RWMutex
+}
+
+// NewClockWatcher creates a MIDI clock watcher reading the given device;
+// an empty device means the first one found
+func NewClockWatcher(device string) *ClockWatcher {
+	return &ClockWatcher{
+		config: common.WatcherConfig{
+			Language:    "midi",
+			Environment: "midi-clock",
+			Enabled:     true,
+			Options: map[string]string{
+				"device": device,
+			},
+		},
+		device: device,
+		clock:  NewClock(),
+	}
+}
+
+// FindDevice returns the first raw MIDI device
+func FindDevice() (string, error) {
+	devices, err := filepath.Glob(DevicePattern)
+	if err != nil {
+		return "", err
+	}
+	if len(devices) == 0 {
+		return "", fmt.Errorf("no MIDI device matches %s; set the device option", DevicePattern)
+	}
+	return devices[0], nil
+}
+
+// Start opens the device and follows its clock
+func (w *ClockWatcher) Start(callback func(common.ExecutionEvent)) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.running {
+		return fmt.Errorf("MIDI clock watcher is already running")
+	}
+
+	device := w.device
+	if device == "" {
+		found, err := FindDevice()
+		if err != nil {
+			return err
+		}
+		device = found
+	}
+
+	file, err := os.Open(device)
+	if err != nil {
+		return fmt.Errorf("failed to open MIDI device: %w", err)
+	}
+
+	w.file = file
+	w.clock = NewClock()
+	w.running = true
+
+	go w.readClock(file, w.clock)
+
+	return nil
+}
+
+// Stop closes the device
+func (w *ClockWatcher) Stop() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.running {
+		return nil
+	}
+
+	w.running = false
+	return w.file.Close()
+}
+
+// IsRunning returns true if the watcher is active
+func (w *ClockWatcher) IsRunning() bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.running
+}
+
+// GetConfig returns the watcher configuration
+func (w *ClockWatcher) GetConfig() common.WatcherConfig {
+	return w.config
+}
+
+// GetLanguage returns "midi"
+func (w *ClockWatcher) GetLanguage() string {
+	return "midi"
+}
+
+// GetEnvironment returns "midi-clock"
+func (w *ClockWatcher) GetEnvironment() string {
+	return "midi-clock"
+}
+
+// Tempo returns the tempo of the incoming clock at the given time
+func (w *ClockWatcher) Tempo(at time.Time) (common.Tempo, bool) {
+	w.mutex.RLock()
+	running, clock := w.running, w.clock
+	w.mutex.RUnlock()
+
+	if !running {
+		return common.Tempo{}, false
+	}
+	return clock.Tempo(at)
+}
+
+// readClock feeds the device's bytes to the clock until it is closed
+func (w *ClockWatcher) readClock(file *os.File, clock *Clock) {
+	buffer := make([]byte, 256)
+
+	for {
+		n, err := file.Read(buffer)
+		now := time.Now()
+		for _, b := range buffer[:n] {
+			clock.Feed(b, now)
+		}
+
+		if err != nil {
+			if w.IsRunning() {
+				fmt.Printf("Error reading MIDI clock: %v\n", err)
+			}
+			return
+		}
+	}
+}
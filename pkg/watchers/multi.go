@@ -0,0 +1,269 @@
+package watchers
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+// RepoService pairs a repository path with the watcher service committing to it
+type RepoService struct {
+	Path    string
+	Service *WatcherService
+}
+
+// MultiRepoService runs the watchers of several repositories in one process.
+// Each repository gets its own WatcherService, so an event is committed to the
+// repository whose watcher received it, i.e. by port or workspace path.
+type MultiRepoService struct {
+	repositories []RepoService
+	endpoints    map[string]string // endpoint -> repository path
+	running      bool
+	mutex        sync.RWMutex
+}
+
+// NewMultiRepoService creates an empty multi-repository service
+func NewMultiRepoService() *MultiRepoService {
+	return &MultiRepoService{
+		repositories: make([]RepoService, 0),
+		endpoints:    make(map[string]string),
+	}
+}
+
+// AddRepository initializes a watcher service for a repository. It fails if one
+// of the repository's enabled watchers would listen on the same port or
+// workspace path as a repository added before.
+func (m *MultiRepoService) AddRepository(path string, repo *core.LiveCodeRepository, configPath string) (*WatcherService, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.running {
+		return nil, fmt.Errorf("cannot add a repository while the service is running")
+	}
+
+	for _, existing := range m.repositories {
+		if existing.Path == path {
+			return nil, fmt.Errorf("repository %s is already being watched", path)
+		}
+	}
+
+	service := NewWatcherService(repo, configPath)
+	if err := service.Initialize(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	endpoints := service.endpoints()
+	for _, endpoint := range endpoints {
+		if owner, exists := m.endpoints[endpoint]; exists {
+			return nil, fmt.Errorf("%s and %s both watch %s; give each repository its own ports and workspace paths (lcg watch --local)",
+				owner, path, endpoint)
+		}
+	}
+
+	for _, endpoint := range endpoints {
+		m.endpoints[endpoint] = path
+	}
+	m.repositories = append(m.repositories, RepoService{Path: path, Service: service})
+
+	return service, nil
+}
+
+// Repositories returns the watched repositories in the order they were added
+func (m *MultiRepoService) Repositories() []RepoService {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	repositories := make([]RepoService, len(m.repositories))
+	copy(repositories, m.repositories)
+	return repositories
+}
+
+// Start starts the watcher service of every repository. If one fails, the
+// services already started are stopped again.
+func (m *MultiRepoService) Start() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.running {
+		return fmt.Errorf("watcher service is already running")
+	}
+
+	for i, r := range m.repositories {
+		if err := r.Service.Start(); err != nil {
+			for _, started := range m.repositories[:i] {
+				started.Service.Stop()
+			}
+			return fmt.Errorf("%s: %w", r.Path, err)
+		}
+	}
+
+	m.running = true
+	return nil
+}
+
+// Stop stops every repository's watcher service, returning the first error
+func (m *MultiRepoService) Stop() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.running {
+		return nil
+	}
+
+	var firstErr error
+	for _, r := range m.repositories {
+		if err := r.Service.Stop(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", r.Path, err)
+		}
+	}
+
+	m.running = false
+	return firstErr
+}
+
+// GetStats returns the statistics of all repositories combined
+func (m *MultiRepoService) GetStats() ServiceStats {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	total := ServiceStats{Running: m.running}
+	var latencies []time.Duration
+	for _, r := range m.repositories {
+		latencies = append(latencies, r.Service.latency.snapshot()...)
+		stats := r.Service.GetStats()
+		total.TotalExecutions += stats.TotalExecutions
+		total.TotalCommits += stats.TotalCommits
+		total.PendingEvents += stats.PendingEvents
+		total.QueuedEvents += stats.QueuedEvents
+		total.ActiveWatchers += stats.ActiveWatchers
+		if stats.LastExecution.After(total.LastExecution) {
+			total.LastExecution = stats.LastExecution
+		}
+	}
+	total.Latency = summarizeLatencies(latencies)
+
+	return total
+}
+
+// endpoints lists the ports and paths the enabled watchers listen on
+func (ws *WatcherService) endpoints() []string {
+	endpoints := make([]string, 0)
+
+	for _, name := range ws.GetEnabledWatchers() {
+		config, exists := ws.GetWatcherConfig(name)
+		if !exists {
+			continue
+		}
+
+		if endpoint := watcherEndpoint(name, config); endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+
+	if port := ws.ControlPort(); port > 0 {
+		endpoints = append(endpoints, fmt.Sprintf("UDP port %d", port))
+	}
+
+	return endpoints
+}
+
+// watcherEndpoint describes the port or path a watcher listens on, or returns
+// an empty string for watchers that don't listen on anything shared
+func watcherEndpoint(name string, config WatcherConfig) string {
+	if runsCommand(config) {
+		return "command " + config.Command
+	}
+
+	switch name {
+	case "sonicpi-osc":
+		port := config.Options["osc_port"]
+		if port == "" {
+			port = "4559"
+		}
+		return "UDP port " + port
+	case "tidal-hook":
+		port := config.Options["hook_port"]
+		if port == "" {
+			port = "6061"
+		}
+		return "UDP port " + port
+	case "hydra":
+		port := config.Options["ws_port"]
+		if port == "" {
+			port = "6062"
+		}
+		return "TCP port " + port
+	case "strudel":
+		port := config.Options["ws_port"]
+		if port == "" {
+			port = "6063"
+		}
+		return "TCP port " + port
+	case "browser":
+		port := config.Options["http_port"]
+		if port == "" {
+			port = "6064"
+		}
+		return "TCP port " + port
+	case "osc-generic":
+		port := config.Options["osc_port"]
+		if port == "" {
+			port = "6065"
+		}
+		return "UDP port " + port
+	case "editor-http":
+		port := config.Options["http_port"]
+		if port == "" {
+			port = "6067"
+		}
+		return "TCP port " + port
+	case "pulsar":
+		return "log " + pulsarLogPath(config)
+	case "emacs":
+		if transcript := config.Options["transcript"]; transcript != "" {
+			return "transcript " + transcript
+		}
+		port := config.Options["tcp_port"]
+		if port == "" {
+			port = "6068"
+		}
+		return "TCP port " + port
+	case "editor-jsonl":
+		if pipe := config.Options["pipe"]; pipe != "" {
+			return "pipe " + pipe
+		}
+		port := config.Options["tcp_port"]
+		if port == "" {
+			port = "6066"
+		}
+		return "TCP port " + port
+	case "tidal-superdirt":
+		port := config.Options["osc_port"]
+		if port == "" {
+			port = "57130"
+		}
+		if forward := config.Options["forward"]; forward != "" {
+			return "UDP port " + port + ", forwarding to " + forward
+		}
+		return "UDP port " + port
+	case "midi-clock":
+		if device := config.Options["device"]; device != "" {
+			return "MIDI device " + device
+		}
+		return ""
+	case "sonicpi-files":
+		workspace := config.Options["workspace_path"]
+		if workspace == "" {
+			return ""
+		}
+		if abs, err := filepath.Abs(workspace); err == nil {
+			workspace = abs
+		}
+		return "workspace " + workspace
+	}
+
+	return ""
+}
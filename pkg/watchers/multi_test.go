@@ -0,0 +1,131 @@
+package watchers
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+// freeUDPPort returns a local UDP port that is currently unused
+func freeUDPPort(t *testing.T) int {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// createHookRepository creates a repository whose own configuration enables
+// the tidal-hook watcher on port
+func createHookRepository(t *testing.T, port int) (*core.LiveCodeRepository, string) {
+	path, err := os.MkdirTemp("", "livecodegit-multi-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+
+	repo := core.NewRepository(path)
+	if err := repo.Init(path); err != nil {
+		t.Fatalf("Failed to initialize test repository: %v", err)
+	}
+
+	configPath := GetRepoConfigPath(path)
+	configManager := NewConfigManager(configPath)
+	if err := configManager.EnableWatcher("tidal-hook"); err != nil {
+		t.Fatalf("Failed to enable watcher: %v", err)
+	}
+	if err := configManager.SetWatcherOption("tidal-hook", "hook_port", strconv.Itoa(port)); err != nil {
+		t.Fatalf("Failed to set hook port: %v", err)
+	}
+	if err := configManager.SaveConfig(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	return repo, path
+}
+
+func TestMultiRepoServiceRoutesByPort(t *testing.T) {
+	portA, portB := freeUDPPort(t), freeUDPPort(t)
+	repoA, pathA := createHookRepository(t, portA)
+	defer os.RemoveAll(pathA)
+	repoB, pathB := createHookRepository(t, portB)
+	defer os.RemoveAll(pathB)
+
+	multi := NewMultiRepoService()
+	if _, err := multi.AddRepository(pathA, repoA, ResolveConfigPath(pathA)); err != nil {
+		t.Fatalf("Failed to add repository A: %v", err)
+	}
+	if _, err := multi.AddRepository(pathB, repoB, ResolveConfigPath(pathB)); err != nil {
+		t.Fatalf("Failed to add repository B: %v", err)
+	}
+
+	if err := multi.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer multi.Stop()
+
+	send := func(port int, message string) {
+		conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+		if err != nil {
+			t.Fatalf("Failed to dial port %d: %v", port, err)
+		}
+		defer conn.Close()
+		if _, err := conn.Write([]byte(message)); err != nil {
+			t.Fatalf("Failed to send to port %d: %v", port, err)
+		}
+	}
+
+	send(portA, `{"buffer":"d1","content":"d1 $ s \"bd\""}`)
+	send(portB, `{"buffer":"d2","content":"d2 $ s \"hh\""}`)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for multi.GetStats().TotalCommits < 2 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	logA, err := repoA.Log(10)
+	if err != nil {
+		t.Fatalf("Failed to read log A: %v", err)
+	}
+	logB, err := repoB.Log(10)
+	if err != nil {
+		t.Fatalf("Failed to read log B: %v", err)
+	}
+
+	if len(logA) != 1 || logA[0].Metadata.Buffer != "d1" {
+		t.Errorf("Expected repository A to hold only the d1 evaluation, got %d commits", len(logA))
+	}
+	if len(logB) != 1 || logB[0].Metadata.Buffer != "d2" {
+		t.Errorf("Expected repository B to hold only the d2 evaluation, got %d commits", len(logB))
+	}
+}
+
+func TestMultiRepoServiceRejectsSharedPort(t *testing.T) {
+	port := freeUDPPort(t)
+	repoA, pathA := createHookRepository(t, port)
+	defer os.RemoveAll(pathA)
+	repoB, pathB := createHookRepository(t, port)
+	defer os.RemoveAll(pathB)
+
+	multi := NewMultiRepoService()
+	if _, err := multi.AddRepository(pathA, repoA, ResolveConfigPath(pathA)); err != nil {
+		t.Fatalf("Failed to add repository A: %v", err)
+	}
+
+	_, err := multi.AddRepository(pathB, repoB, ResolveConfigPath(pathB))
+	if err == nil {
+		t.Fatalf("Expected error when two repositories share a port")
+	}
+	if !strings.Contains(err.Error(), "UDP port "+strconv.Itoa(port)) {
+		t.Errorf("Expected error to name the shared port, got: %v", err)
+	}
+
+	if len(multi.Repositories()) != 1 {
+		t.Errorf("Expected only repository A to be added, got %d", len(multi.Repositories()))
+	}
+}
@@ -0,0 +1,320 @@
+// Package oscgeneric turns OSC messages from any environment into
+// executions, mapping the arguments of messages matching an address pattern
+// to the fields of an execution event.
+package oscgeneric
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livecodegit/pkg/osc"
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+// DefaultPort is the UDP port the watcher listens on
+const DefaultPort = 6065
+
+// Defaults of the mapping options
+const (
+	DefaultAddress = "/lcg/eval"
+	DefaultArgs    = RoleContent
+	DefaultBuffer  = "osc"
+)
+
+// Roles of OSC arguments, listed in order in the args option
+const (
+	RoleContent = "content"
+	RoleBuffer  = "buffer"
+	RoleSuccess = "success"
+	RoleError   = "error"
+	RoleBPM     = "bpm"
+	RoleAuthor  = "author"
+	RoleSkip    = "-"
+)
+
+// BufferFromAddress as the buffer option names the buffer after the last
+// part of the message's address, e.g. p1 for /foxdot/eval/p1
+const BufferFromAddress = "address"
+
+// Mapping turns the messages matching Address into executions
+type Mapping struct {
+	Address string   // OSC address pattern
+	Roles   []string // role of each argument, in order
+	Buffer  string   // buffer when no argument gives one
+}
+
+// ParseMapping reads a mapping from the address, args and buffer options
+func ParseMapping(options map[string]string) (Mapping, error) {
+	mapping := Mapping{Address: DefaultAddress, Roles: []string{DefaultArgs}, Buffer: DefaultBuffer}
+	if address := options["address"]; address != "" {
+		if !strings.HasPrefix(address, "/") {
+			return mapping, fmt.Errorf("invalid address: %s (must start with /)", address)
+		}
+		mapping.Address = address
+	}
+	if buffer := options["buffer"]; buffer != "" {
+		mapping.Buffer = buffer
+	}
+
+	if args := options["args"]; args != "" {
+		mapping.Roles = nil
+		hasContent := false
+		for _, role := range strings.Split(args, ",") {
+			role = strings.TrimSpace(role)
+			switch role {
+			case RoleContent:
+				hasContent = true
+			case RoleBuffer, RoleSuccess, RoleError, RoleBPM, RoleAuthor, RoleSkip:
+			default:
+				return mapping, fmt.Errorf("unknown argument role: %s (content, buffer, success, error, bpm, author or -)", role)
+			}
+			mapping.Roles = append(mapping.Roles, role)
+		}
+		if !hasContent {
+			return mapping, fmt.Errorf("args must map one argument to content")
+		}
+	}
+	return mapping, nil
+}
+
+// Event converts a message into an execution, returning false for messages
+// the address pattern doesn't match
+func (m Mapping) Event(message osc.Message, config common.WatcherConfig) (common.ExecutionEvent, bool, error) {
+	event := common.ExecutionEvent{
+		Timestamp:   time.Now(),
+		Language:    config.Language,
+		Environment: config.Environment,
+		Success:     true,
+	}
+	if !osc.Match(m.Address, message.Address) {
+		return event, false, nil
+	}
+
+	event.Buffer = m.Buffer
+	if m.Buffer == BufferFromAddress {
+		event.Buffer = message.Address[strings.LastIndex(message.Address, "/")+1:]
+	}
+
+	for i, role := range m.Roles {
+		if i >= len(message.Args) {
+			break
+		}
+		arg := message.Args[i]
+		switch role {
+		case RoleContent:
+			event.Content = text(arg)
+		case RoleBuffer:
+			event.Buffer = text(arg)
+		case RoleSuccess:
+			event.Success = truthy(arg)
+		case RoleError:
+			event.ErrorMessage = text(arg)
+		case RoleBPM:
+			bpm, err := strconv.ParseFloat(text(arg), 64)
+			if err != nil {
+				return event, true, fmt.Errorf("invalid bpm argument %v", arg)
+			}
+			event.BPM = bpm
+		case RoleAuthor:
+			event.Author = text(arg)
+		}
+	}
+
+	if strings.TrimSpace(event.Content) == "" {
+		return event, true, fmt.Errorf("%s carries no content", message.Address)
+	}
+	if event.ErrorMessage != "" && !containsRole(m.Roles, RoleSuccess) {
+		event.Success = false
+	}
+	return event, true, nil
+}
+
+// TestMessage returns a message the mapping turns into a harmless
+// execution, for 'lcg watch --test'; a pattern matching more than one
+// address can't be addressed
+func (m Mapping) TestMessage() (osc.Message, error) {
+	if strings.ContainsAny(m.Address, "*?[]{}") {
+		return osc.Message{}, fmt.Errorf("address %s is a pattern; run some code instead", m.Address)
+	}
+
+	message := osc.Message{Address: m.Address}
+	for _, role := range m.Roles {
+		switch role {
+		case RoleContent:
+			message.Args = append(message.Args, "lcg watch --test")
+		case RoleBuffer:
+			message.Args = append(message.Args, "lcg-test")
+		case RoleSuccess:
+			message.Args = append(message.Args, true)
+		case RoleBPM:
+			message.Args = append(message.Args, float32(120))
+		default:
+			message.Args = append(message.Args, "")
+		}
+	}
+	return message, nil
+}
+
+// containsRole reports whether an argument has the role
+func containsRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// text renders an argument as a string; blobs are taken as UTF-8
+func text(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// truthy reads a success argument: true, a non-zero number, or a string
+// such as "true", "ok" or "1"
+func truthy(arg interface{}) bool {
+	switch v := arg.(type) {
+	case bool:
+		return v
+	case int32:
+		return v != 0
+	case int64:
+		return v != 0
+	case float32:
+		return v != 0
+	case float64:
+		return v != 0
+	case string:
+		switch strings.ToLower(v) {
+		case "true", "ok", "1", "yes", "success":
+			return true
+		}
+	}
+	return false
+}
+
+// Watcher listens for OSC messages from any environment
+type Watcher struct {
+	config   common.WatcherConfig
+	mapping  Mapping
+	port     int
+	conn     *net.UDPConn
+	running  bool
+	mutex    sync.RWMutex
+	callback func(common.ExecutionEvent)
+}
+
+// NewWatcher creates a watcher listening on the given port, committing the
+// messages the mapping matches with the configured language and environment
+func NewWatcher(port int, mapping Mapping, config common.WatcherConfig) *Watcher {
+	return &Watcher{config: config, mapping: mapping, port: port}
+}
+
+// Start begins listening for OSC messages
+func (w *Watcher) Start(callback func(common.ExecutionEvent)) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.running {
+		return fmt.Errorf("OSC watcher is already running")
+	}
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: w.port})
+	if err != nil {
+		return fmt.Errorf("failed to listen on UDP port %d: %w", w.port, err)
+	}
+
+	w.conn = conn
+	w.callback = callback
+	w.running = true
+
+	go w.listen(conn)
+
+	return nil
+}
+
+// Stop stops listening
+func (w *Watcher) Stop() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.running {
+		return nil
+	}
+
+	w.running = false
+	return w.conn.Close()
+}
+
+// IsRunning returns true if the watcher is active
+func (w *Watcher) IsRunning() bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.running
+}
+
+// GetConfig returns the watcher configuration
+func (w *Watcher) GetConfig() common.WatcherConfig {
+	return w.config
+}
+
+// GetLanguage returns the configured language
+func (w *Watcher) GetLanguage() string {
+	return w.config.Language
+}
+
+// GetEnvironment returns the configured environment
+func (w *Watcher) GetEnvironment() string {
+	return w.config.Environment
+}
+
+// listen reads packets until the watcher stops
+func (w *Watcher) listen(conn *net.UDPConn) {
+	buffer := make([]byte, 65536)
+
+	for w.IsRunning() {
+		conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+		n, err := conn.Read(buffer)
+		if err != nil {
+			if netError, ok := err.(net.Error); ok && netError.Timeout() {
+				continue
+			}
+			if w.IsRunning() {
+				fmt.Printf("Error reading OSC message: %v\n", err)
+			}
+			continue
+		}
+
+		messages, err := osc.Parse(buffer[:n])
+		if err != nil {
+			fmt.Printf("Ignoring OSC packet: %v\n", err)
+			continue
+		}
+		for _, message := range messages {
+			event, matched, err := w.mapping.Event(message, w.config)
+			if !matched {
+				continue
+			}
+			if err != nil {
+				fmt.Printf("Ignoring %s: %v\n", message.Address, err)
+				continue
+			}
+			if w.callback != nil {
+				w.callback(event)
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package oscgeneric
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/osc"
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+var config = common.WatcherConfig{Language: "orca", Environment: "osc-generic"}
+
+func TestMapping(t *testing.T) {
+	mapping, err := ParseMapping(map[string]string{"address": "/orca/*", "args": "buffer,-,content,success,error", "buffer": "address"})
+	if err != nil {
+		t.Fatalf("Failed to parse mapping: %v", err)
+	}
+
+	event, matched, err := mapping.Event(osc.Message{
+		Address: "/orca/eval",
+		Args:    []interface{}{"grid1", int32(7), "D8.C4", "nope", "bad operator"},
+	}, config)
+	if !matched || err != nil {
+		t.Fatalf("Expected the message to map, got %v (%v)", matched, err)
+	}
+	if event.Buffer != "grid1" || event.Content != "D8.C4" || event.Success || event.ErrorMessage != "bad operator" {
+		t.Errorf("Expected the arguments mapped by role, got %+v", event)
+	}
+	if event.Language != "orca" || event.Environment != "osc-generic" {
+		t.Errorf("Expected the watcher's language and environment, got %+v", event)
+	}
+
+	// Other addresses are left alone
+	if _, matched, _ := mapping.Event(osc.Message{Address: "/other/eval", Args: []interface{}{"x"}}, config); matched {
+		t.Errorf("Expected /other/eval not to match")
+	}
+
+	// By default the first argument is the content, the buffer may come from
+	// the address, and an error alone marks a failure
+	mapping, _ = ParseMapping(map[string]string{"address": "/foxdot/eval/*", "args": "content,error", "buffer": "address"})
+	event, _, _ = mapping.Event(osc.Message{Address: "/foxdot/eval/p1", Args: []interface{}{[]byte("p1 >> pluck()"), "oops"}}, config)
+	if event.Buffer != "p1" || event.Content != "p1 >> pluck()" || event.Success {
+		t.Errorf("Expected a failed execution in p1, got %+v", event)
+	}
+
+	if _, _, err := mapping.Event(osc.Message{Address: "/foxdot/eval/p1"}, config); err == nil {
+		t.Errorf("Expected a message without content to be refused")
+	}
+
+	for _, options := range []map[string]string{{"args": "buffer"}, {"args": "content,colour"}, {"address": "eval"}} {
+		if _, err := ParseMapping(options); err == nil {
+			t.Errorf("Expected %v to be refused", options)
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	// Find a free port
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+
+	mapping, _ := ParseMapping(map[string]string{"args": "content,buffer,bpm"})
+	watcher := NewWatcher(port, mapping, config)
+
+	events := make(chan common.ExecutionEvent, 10)
+	if err := watcher.Start(func(event common.ExecutionEvent) { events <- event }); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	message, err := mapping.TestMessage()
+	if err != nil {
+		t.Fatalf("Failed to build test message: %v", err)
+	}
+	packet, _ := message.MarshalBinary()
+	sender, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer sender.Close()
+	sender.Write(packet)
+
+	select {
+	case event := <-events:
+		if event.Content != "lcg watch --test" || event.Buffer != "lcg-test" || event.BPM != 120 {
+			t.Errorf("Expected the test execution, got %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected an execution")
+	}
+}
@@ -0,0 +1,209 @@
+package watchers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+// PendingFile is the name of the file holding executions waiting for review
+const PendingFile = "pending.json"
+
+// PendingEvent is a detected execution that was not committed, kept for review
+type PendingEvent struct {
+	ID         string         `json:"id"`
+	ReceivedAt time.Time      `json:"received_at"`
+	Message    string         `json:"message"`
+	Event      ExecutionEvent `json:"event"`
+}
+
+// PendingStore persists pending events in a repository so they can be
+// accepted or rejected later with 'lcg pending'. They're sealed once the
+// repository is encrypted.
+type PendingStore struct {
+	path    string
+	storage *storage.FileSystemStorage
+	mutex   sync.Mutex
+}
+
+// NewPendingStore creates a pending store for a repository
+func NewPendingStore(repoPath string) *PendingStore {
+	return &PendingStore{
+		path:    filepath.Join(repoPath, storage.RepoDir, PendingFile),
+		storage: storage.NewFileSystemStorage(repoPath),
+	}
+}
+
+// Add records an event with the commit message it would have been given
+func (ps *PendingStore) Add(event ExecutionEvent, message string) (*PendingEvent, error) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	events, err := ps.load()
+	if err != nil {
+		return nil, err
+	}
+
+	receivedAt := time.Now()
+	pending := PendingEvent{
+		ID:         storage.GenerateHash(event.Content + receivedAt.String())[:8],
+		ReceivedAt: receivedAt,
+		Message:    message,
+		Event:      event,
+	}
+
+	events = append(events, pending)
+	if err := ps.save(events); err != nil {
+		return nil, err
+	}
+
+	return &pending, nil
+}
+
+// List returns pending events, oldest first
+func (ps *PendingStore) List() ([]PendingEvent, error) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	return ps.load()
+}
+
+// Get finds a pending event by ID or unique ID prefix
+func (ps *PendingStore) Get(id string) (*PendingEvent, error) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	events, err := ps.load()
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := findPending(events, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &events[index], nil
+}
+
+// Remove deletes a pending event by ID or unique ID prefix and returns it
+func (ps *PendingStore) Remove(id string) (*PendingEvent, error) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	events, err := ps.load()
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := findPending(events, id)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := events[index]
+	events = append(events[:index], events[index+1:]...)
+	if err := ps.save(events); err != nil {
+		return nil, err
+	}
+
+	return &removed, nil
+}
+
+// Prune discards the pending events received before a time and returns how
+// many there were
+func (ps *PendingStore) Prune(before time.Time) (int, error) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	events, err := ps.load()
+	if err != nil {
+		return 0, err
+	}
+
+	kept := make([]PendingEvent, 0, len(events))
+	for _, event := range events {
+		if !event.ReceivedAt.Before(before) {
+			kept = append(kept, event)
+		}
+	}
+	if len(kept) == len(events) {
+		return 0, nil
+	}
+
+	if err := ps.save(kept); err != nil {
+		return 0, err
+	}
+	return len(events) - len(kept), nil
+}
+
+// Count returns the number of pending events
+func (ps *PendingStore) Count() (int, error) {
+	events, err := ps.List()
+	if err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
+
+// findPending returns the index of the event matching an ID or unique ID prefix
+func findPending(events []PendingEvent, id string) (int, error) {
+	found := -1
+	for i, event := range events {
+		if strings.HasPrefix(event.ID, id) {
+			if found >= 0 {
+				return -1, fmt.Errorf("pending event ID %s is ambiguous", id)
+			}
+			found = i
+		}
+	}
+
+	if found < 0 {
+		return -1, fmt.Errorf("pending event %s not found", id)
+	}
+	return found, nil
+}
+
+// load reads pending events from disk; a missing file means no events
+func (ps *PendingStore) load() ([]PendingEvent, error) {
+	data, err := os.ReadFile(ps.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make([]PendingEvent, 0), nil
+		}
+		return nil, fmt.Errorf("failed to read pending events: %w", err)
+	}
+	if data, err = ps.storage.OpenRepoData(PendingFile, data); err != nil {
+		return nil, fmt.Errorf("failed to read pending events: %w", err)
+	}
+
+	var events []PendingEvent
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, fmt.Errorf("failed to parse pending events: %w", err)
+	}
+
+	return events, nil
+}
+
+// save writes pending events to disk
+func (ps *PendingStore) save(events []PendingEvent) error {
+	data, err := json.MarshalIndent(events, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending events: %w", err)
+	}
+	if data, err = ps.storage.SealRepoData(PendingFile, data); err != nil {
+		return fmt.Errorf("failed to write pending events: %w", err)
+	}
+
+	if err := os.WriteFile(ps.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write pending events: %w", err)
+	}
+
+	return nil
+}
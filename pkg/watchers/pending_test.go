@@ -0,0 +1,151 @@
+package watchers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+func TestPendingStoreAddAndRemove(t *testing.T) {
+	repo := createTestRepository(t)
+	defer os.RemoveAll(repo.GetPath())
+
+	store := NewPendingStore(repo.GetPath())
+
+	count, err := store.Count()
+	if err != nil {
+		t.Fatalf("Failed to count pending events: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected empty pending store, got %d events", count)
+	}
+
+	first, err := store.Add(ExecutionEvent{Content: "d1 $ s \"bd\"", Buffer: "d1", Language: "tidal", Success: true}, "Tidal execution in d1")
+	if err != nil {
+		t.Fatalf("Failed to add pending event: %v", err)
+	}
+	if _, err := store.Add(ExecutionEvent{Content: "d2 $ s \"hh\"", Buffer: "d2", Language: "tidal", Success: true}, "Tidal execution in d2"); err != nil {
+		t.Fatalf("Failed to add pending event: %v", err)
+	}
+
+	// A new store reads the same events from disk
+	events, err := NewPendingStore(repo.GetPath()).List()
+	if err != nil {
+		t.Fatalf("Failed to list pending events: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 pending events, got %d", len(events))
+	}
+	if events[0].ID != first.ID || events[0].Message != "Tidal execution in d1" {
+		t.Errorf("Expected oldest event first, got %s (%s)", events[0].ID, events[0].Message)
+	}
+
+	// Events can be addressed by an ID prefix
+	found, err := store.Get(first.ID[:4])
+	if err != nil {
+		t.Fatalf("Failed to get pending event by prefix: %v", err)
+	}
+	if found.Event.Buffer != "d1" {
+		t.Errorf("Expected buffer d1, got %s", found.Event.Buffer)
+	}
+
+	removed, err := store.Remove(first.ID)
+	if err != nil {
+		t.Fatalf("Failed to remove pending event: %v", err)
+	}
+	if removed.ID != first.ID {
+		t.Errorf("Expected removed ID %s, got %s", first.ID, removed.ID)
+	}
+
+	count, err = store.Count()
+	if err != nil {
+		t.Fatalf("Failed to count pending events: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 pending event after removal, got %d", count)
+	}
+
+	if _, err := store.Get(first.ID); err == nil {
+		t.Errorf("Expected error getting a removed event")
+	}
+}
+
+func TestPendingStorePrune(t *testing.T) {
+	repo := createTestRepository(t)
+	defer os.RemoveAll(repo.GetPath())
+
+	store := NewPendingStore(repo.GetPath())
+	before := time.Now()
+	for _, buffer := range []string{"d1", "d2"} {
+		if _, err := store.Add(ExecutionEvent{Content: buffer + " $ s \"bd\"", Buffer: buffer, Language: "tidal"}, "Tidal execution"); err != nil {
+			t.Fatalf("Failed to add pending event: %v", err)
+		}
+	}
+
+	if count, err := store.Prune(before); err != nil || count != 0 {
+		t.Errorf("Expected nothing pruned before the events arrived, got %d (%v)", count, err)
+	}
+	if count, err := store.Prune(time.Now().Add(time.Second)); err != nil || count != 2 {
+		t.Errorf("Expected 2 events pruned, got %d (%v)", count, err)
+	}
+	if count, _ := store.Count(); count != 0 {
+		t.Errorf("Expected empty pending store after pruning, got %d events", count)
+	}
+}
+
+func TestPendingStoreAmbiguousID(t *testing.T) {
+	repo := createTestRepository(t)
+	defer os.RemoveAll(repo.GetPath())
+
+	store := NewPendingStore(repo.GetPath())
+	for _, content := range []string{"a", "b"} {
+		if _, err := store.Add(ExecutionEvent{Content: content}, content); err != nil {
+			t.Fatalf("Failed to add pending event: %v", err)
+		}
+	}
+
+	// The empty prefix matches every event
+	_, err := store.Get("")
+	if err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("Expected ambiguous ID error, got: %v", err)
+	}
+}
+
+func TestPendingStoreEncrypted(t *testing.T) {
+	repo := createTestRepository(t)
+	defer os.RemoveAll(repo.GetPath())
+
+	store := NewPendingStore(repo.GetPath())
+	if _, err := store.Add(ExecutionEvent{Content: "d1 $ s \"bd\"", Buffer: "d1", Language: "tidal", Success: true}, "Tidal execution in d1"); err != nil {
+		t.Fatalf("Failed to add pending event: %v", err)
+	}
+
+	keyFile := filepath.Join(repo.GetPath(), "lcg.key")
+	if err := storage.GenerateKeyFile(keyFile); err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if _, err := repo.Encrypt(keyFile, PendingFile); err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	// Events waiting before and after encrypting are sealed on disk
+	if _, err := store.Add(ExecutionEvent{Content: "d2 $ s \"hh\"", Buffer: "d2", Language: "tidal", Success: true}, "Tidal execution in d2"); err != nil {
+		t.Fatalf("Failed to add pending event: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(repo.GetPath(), storage.RepoDir, PendingFile))
+	if err != nil {
+		t.Fatalf("Failed to read pending events: %v", err)
+	}
+	if strings.Contains(string(data), "d1 $ s") || strings.Contains(string(data), "d2 $ s") {
+		t.Errorf("Expected pending events sealed, got %q", data)
+	}
+
+	events, err := NewPendingStore(repo.GetPath()).List()
+	if err != nil || len(events) != 2 {
+		t.Fatalf("Expected 2 pending events, got %d (%v)", len(events), err)
+	}
+}
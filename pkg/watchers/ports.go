@@ -0,0 +1,129 @@
+package watchers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// sonicPiPorts are the UDP ports Sonic Pi itself listens on, used to guess
+// the owner of a port when the process can't be looked up
+var sonicPiPorts = map[int]bool{4557: true, 4558: true, 4560: true}
+
+// PortConflict reports a UDP port a watcher can't listen on
+type PortConflict struct {
+	Port    int
+	InUse   bool   // another socket is bound to the port
+	Owner   string // process holding the port, when it could be found
+	PID     int
+	SonicPi bool // the port is held, or most likely held, by Sonic Pi
+	Err     error
+}
+
+// Error describes who holds the port
+func (c *PortConflict) Error() string {
+	switch {
+	case !c.InUse:
+		return fmt.Sprintf("cannot listen on UDP port %d: %v", c.Port, c.Err)
+	case c.SonicPi:
+		return fmt.Sprintf("UDP port %d is occupied by Sonic Pi", c.Port)
+	case c.Owner != "":
+		return fmt.Sprintf("UDP port %d is in use by %s (pid %d)", c.Port, c.Owner, c.PID)
+	default:
+		return fmt.Sprintf("UDP port %d is already in use", c.Port)
+	}
+}
+
+// Unwrap returns the error listening on the port failed with
+func (c *PortConflict) Unwrap() error {
+	return c.Err
+}
+
+// CheckUDPPort reports whether a watcher could listen on a UDP port, returning
+// a *PortConflict naming the process holding it when it can't
+func CheckUDPPort(port int) error {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port})
+	if err == nil {
+		conn.Close()
+		return nil
+	}
+
+	conflict := &PortConflict{Port: port, Err: err}
+	if !errors.Is(err, syscall.EADDRINUSE) {
+		return conflict
+	}
+
+	conflict.InUse = true
+	if pid, name, command := udpPortOwner(port); pid > 0 {
+		conflict.PID = pid
+		conflict.Owner = name
+		command = strings.ToLower(command)
+		conflict.SonicPi = strings.Contains(command, "sonic-pi") || strings.Contains(command, "sonic_pi") ||
+			strings.Contains(command, "sonicpi")
+	} else {
+		conflict.SonicPi = sonicPiPorts[port]
+	}
+
+	return conflict
+}
+
+// udpPortOwner finds the process holding a UDP port through /proc, returning
+// a zero pid where that isn't available, e.g. off Linux or for other users
+func udpPortOwner(port int) (pid int, name, command string) {
+	sockets := make(map[string]bool)
+	for _, table := range []string{"/proc/net/udp", "/proc/net/udp6"} {
+		data, err := os.ReadFile(table)
+		if err != nil {
+			continue
+		}
+
+		lines := strings.Split(string(data), "\n")
+		for _, line := range lines[1:] {
+			// sl local_address rem_address st tx:rx tr:when retrnsmt uid timeout inode
+			fields := strings.Fields(line)
+			if len(fields) < 10 {
+				continue
+			}
+			_, hexPort, found := strings.Cut(fields[1], ":")
+			if local, err := strconv.ParseInt(hexPort, 16, 32); found && err == nil && int(local) == port && fields[9] != "0" {
+				sockets["socket:["+fields[9]+"]"] = true
+			}
+		}
+	}
+	if len(sockets) == 0 {
+		return 0, "", ""
+	}
+
+	processes, err := os.ReadDir("/proc")
+	if err != nil {
+		return 0, "", ""
+	}
+	for _, process := range processes {
+		id, err := strconv.Atoi(process.Name())
+		if err != nil {
+			continue
+		}
+
+		fds, err := os.ReadDir(filepath.Join("/proc", process.Name(), "fd"))
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join("/proc", process.Name(), "fd", fd.Name()))
+			if err != nil || !sockets[link] {
+				continue
+			}
+
+			comm, _ := os.ReadFile(filepath.Join("/proc", process.Name(), "comm"))
+			cmdline, _ := os.ReadFile(filepath.Join("/proc", process.Name(), "cmdline"))
+			return id, strings.TrimSpace(string(comm)), strings.ReplaceAll(string(cmdline), "\x00", " ")
+		}
+	}
+
+	return 0, "", ""
+}
@@ -0,0 +1,99 @@
+package watchers
+
+import (
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// occupyUDPPort binds a free UDP port on all interfaces, as a watcher would
+func occupyUDPPort(t *testing.T) (*net.UDPConn, int) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{})
+	if err != nil {
+		t.Fatalf("Failed to occupy a port: %v", err)
+	}
+	return conn, conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestCheckUDPPort(t *testing.T) {
+	if err := CheckUDPPort(freeUDPPort(t)); err != nil {
+		t.Errorf("Expected free port to pass, got %v", err)
+	}
+
+	conn, port := occupyUDPPort(t)
+	defer conn.Close()
+
+	err := CheckUDPPort(port)
+	var conflict *PortConflict
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Expected a port conflict, got %v", err)
+	}
+	if !conflict.InUse || conflict.Port != port || conflict.SonicPi {
+		t.Errorf("Expected port %d in use by something other than Sonic Pi, got %+v", port, conflict)
+	}
+
+	if runtime.GOOS == "linux" {
+		if conflict.PID != os.Getpid() {
+			t.Errorf("Expected the test process %d to own the port, got %d", os.Getpid(), conflict.PID)
+		}
+		if !strings.Contains(err.Error(), "pid") {
+			t.Errorf("Expected the owner in the error, got %v", err)
+		}
+	}
+}
+
+func TestPortHintSonicPi(t *testing.T) {
+	err := portHint(&PortConflict{Port: 4560, InUse: true, SonicPi: true}, "choose another port")
+	if !strings.Contains(err.Error(), "occupied by Sonic Pi") || !strings.Contains(err.Error(), "choose another port") {
+		t.Errorf("Expected Sonic Pi advice, got %v", err)
+	}
+
+	err = portHint(&PortConflict{Port: 6061, InUse: true, Owner: "lcg", PID: 42}, "choose another port")
+	if !strings.Contains(err.Error(), "already running") {
+		t.Errorf("Expected a hint about another lcg watch, got %v", err)
+	}
+}
+
+func TestWatcherServiceStartReportsPortConflict(t *testing.T) {
+	conn, port := occupyUDPPort(t)
+	defer conn.Close()
+
+	repo, path := createHookRepository(t, port)
+	defer os.RemoveAll(path)
+
+	service := NewWatcherService(repo, ResolveConfigPath(path))
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+
+	err := service.Start()
+	var startErr *StartError
+	if !errors.As(err, &startErr) {
+		t.Fatalf("Expected a start error, got %v", err)
+	}
+	if service.IsRunning() {
+		t.Errorf("Expected service not to run after a port conflict")
+	}
+
+	results := service.StartResults()
+	if len(results) != 1 || results[0].Name != "tidal-hook" || results[0].Started {
+		t.Fatalf("Expected one failed tidal-hook result, got %+v", results)
+	}
+	if !strings.Contains(results[0].Err.Error(), "tidal-hook.hook_port") {
+		t.Errorf("Expected advice to change hook_port, got %v", results[0].Err)
+	}
+
+	// The port is checked before anything listens, so it's free again
+	conn.Close()
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start once the port is free: %v", err)
+	}
+	defer service.Stop()
+
+	if results := service.StartResults(); !results[0].Started || results[0].Err != nil {
+		t.Errorf("Expected tidal-hook to start, got %+v", results[0])
+	}
+}
@@ -0,0 +1,414 @@
+package watchers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+	"github.com/livecodegit/pkg/watchers/editorhttp"
+	"github.com/livecodegit/pkg/watchers/emacs"
+	"github.com/livecodegit/pkg/watchers/jsonlines"
+	"github.com/livecodegit/pkg/watchers/midi"
+	"github.com/livecodegit/pkg/watchers/oscgeneric"
+	"github.com/livecodegit/pkg/watchers/pulsar"
+	"github.com/livecodegit/pkg/watchers/sonicpi"
+	"github.com/livecodegit/pkg/watchers/tidal"
+)
+
+// ProbeResult reports what a short test run of a single watcher saw
+type ProbeResult struct {
+	Name     string
+	Enabled  bool
+	Endpoint string
+	Injected bool
+	Started  bool
+	Elapsed  time.Duration
+
+	// Event is the first execution received, nil if none arrived in time
+	Event *ExecutionEvent
+	// Message is the commit message Event would be committed with
+	Message string
+
+	Diagnostics []string
+}
+
+// ProbeWatcher starts the watcher called name on its own, even when it is
+// disabled, and waits up to timeout for one execution. With inject set, a
+// test execution is sent to the watcher where it can accept one, checking
+// the watcher end to end without the livecoding environment. Nothing is
+// committed.
+func (ws *WatcherService) ProbeWatcher(name string, inject bool, timeout time.Duration) (*ProbeResult, error) {
+	config, exists := ws.GetWatcherConfig(name)
+	if !exists {
+		return nil, fmt.Errorf("watcher %s not found", name)
+	}
+
+	if ws.IsRunning() {
+		return nil, fmt.Errorf("cannot probe a watcher while the service is running")
+	}
+
+	result := &ProbeResult{
+		Name:     name,
+		Enabled:  config.Enabled,
+		Endpoint: watcherEndpoint(name, config),
+	}
+	if !config.Enabled {
+		result.diagnose("%s is disabled; enable it with 'lcg watch --enable %s'", name, name)
+	}
+
+	watcher, err := ws.createWatcher(name, config)
+	if err != nil {
+		result.diagnose("Invalid configuration: %v", err)
+		return result, nil
+	}
+
+	ws.checkEnvironment(name, config, result)
+
+	if err := checkWatcherPort(name, config); err != nil {
+		result.diagnose("Cannot listen: %v", err)
+		return result, nil
+	}
+
+	events := make(chan ExecutionEvent, 1)
+	callback := func(event ExecutionEvent) {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+
+	start := time.Now()
+	if err := watcher.Start(callback); err != nil {
+		result.diagnose("Failed to start: %v", err)
+		if result.Endpoint != "" && name != "sonicpi-files" {
+			result.diagnose("Another process may be using %s (is 'lcg watch' already running?)", result.Endpoint)
+		}
+		return result, nil
+	}
+	defer watcher.Stop()
+	result.Started = true
+
+	if inject {
+		if err := injectTestExecution(name, config, watcher); err != nil {
+			result.diagnose("Could not inject a test execution: %v", err)
+		} else {
+			result.Injected = true
+		}
+	}
+
+	select {
+	case event := <-events:
+		result.Elapsed = time.Since(start)
+		event = attributeEvent(config, event)
+		result.Event = &event
+
+		message, err := ws.generateCommitMessage(event)
+		if err != nil {
+			result.diagnose("Commit message template failed: %v", err)
+		}
+		result.Message = message
+	case <-time.After(timeout):
+		result.Elapsed = time.Since(start)
+		result.diagnose("No execution arrived within %s", timeout)
+		result.diagnose("%s", waitingHint(name, config, result.Injected))
+	}
+
+	return result, nil
+}
+
+// diagnose adds a diagnostic line to the result
+func (r *ProbeResult) diagnose(format string, args ...interface{}) {
+	r.Diagnostics = append(r.Diagnostics, fmt.Sprintf(format, args...))
+}
+
+// checkEnvironment reports problems that would stop a watcher seeing executions
+func (ws *WatcherService) checkEnvironment(name string, config WatcherConfig, result *ProbeResult) {
+	if runsCommand(config) {
+		if fields := common.CommandFields(config.Command); len(fields) > 0 {
+			if _, err := exec.LookPath(fields[0]); err != nil {
+				result.diagnose("Command %s not found in PATH", fields[0])
+			}
+		}
+		return
+	}
+
+	switch name {
+	case "sonicpi-files":
+		workspace := config.Options["workspace_path"]
+		if info, err := os.Stat(workspace); err != nil || !info.IsDir() {
+			result.diagnose("Workspace %s is not a directory", workspace)
+		}
+	case "midi-clock":
+		if device := config.Options["device"]; device != "" {
+			if _, err := os.Stat(device); err != nil {
+				result.diagnose("MIDI device %s not found", device)
+			}
+		} else if _, err := midi.FindDevice(); err != nil {
+			result.diagnose("%v", err)
+		}
+	case "pulsar":
+		if _, err := os.Stat(pulsarLogPath(config)); err != nil {
+			result.diagnose("Log %s not found; point the tidalcycles package's GHCi path at the wrapper from 'lcg integrate pulsar'", pulsarLogPath(config))
+		}
+	case "tidal-ghci":
+		if attach := config.Options["attach"]; attach != "" {
+			if info, err := os.Stat(attach); err != nil || info.Size() == 0 && info.Mode().IsRegular() {
+				result.diagnose("Session %s is missing or empty; tee GHCi's input into it or pipe its terminal there with tmux pipe-pane", attach)
+			}
+			break
+		}
+		command := "ghci"
+		if fields := common.CommandFields(config.Options["ghci_command"]); len(fields) > 0 {
+			command = fields[0]
+		}
+		if _, err := exec.LookPath(command); err != nil {
+			result.diagnose("GHCi command %s not found in PATH", command)
+		}
+	}
+}
+
+// injectTestExecution sends a harmless execution to a running watcher
+func injectTestExecution(name string, config WatcherConfig, watcher ExecutionWatcher) error {
+	switch name {
+	case "tidal-hook":
+		port, err := optionPort(config, "hook_port", tidal.DefaultHookPort)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(tidal.HookMessage{Buffer: "lcg-test", Content: "d1 $ silence"})
+		if err != nil {
+			return err
+		}
+		return sendUDP(port, data)
+	case "sonicpi-osc":
+		port, err := optionPort(config, "osc_port", 4559)
+		if err != nil {
+			return err
+		}
+		return sendUDP(port, []byte(sonicpi.HookMessagePrefix+" buffer: lcg_test\n# lcg watch --test"))
+	case "osc-generic":
+		port, err := optionPort(config, "osc_port", oscgeneric.DefaultPort)
+		if err != nil {
+			return err
+		}
+		mapping, err := oscgeneric.ParseMapping(config.Options)
+		if err != nil {
+			return err
+		}
+		message, err := mapping.TestMessage()
+		if err != nil {
+			return err
+		}
+		data, err := message.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return sendUDP(port, data)
+	case "editor-jsonl":
+		data, err := json.Marshal(ExecutionEvent{Content: "-- lcg watch --test", Buffer: "lcg-test", Success: true})
+		if err != nil {
+			return err
+		}
+		return sendLine(config, jsonlines.DefaultPort, append(data, '\n'))
+	case "pulsar":
+		return appendFile(pulsarLogPath(config), pulsar.InputPrefix+"hush\n")
+	case "emacs":
+		if transcript := config.Options["transcript"]; transcript != "" {
+			return appendFile(transcript, emacs.BufferMarker+"lcg-test\nhush\n")
+		}
+		data, err := json.Marshal(ExecutionEvent{Content: "hush", Buffer: "lcg-test", Success: true})
+		if err != nil {
+			return err
+		}
+		return sendLine(config, emacs.DefaultPort, append(data, '\n'))
+	case "editor-http":
+		editor, ok := watcher.(*editorhttp.Watcher)
+		if !ok {
+			return fmt.Errorf("unexpected watcher type")
+		}
+		port, err := optionPort(config, "http_port", editorhttp.DefaultPort)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(ExecutionEvent{Content: "-- lcg watch --test", Buffer: "lcg-test", Success: true})
+		if err != nil {
+			return err
+		}
+		return postEvent(port, editor.Token(), data)
+	case "tidal-ghci":
+		if attach := config.Options["attach"]; attach != "" {
+			prompt := config.Options["attach_prompt"]
+			return appendFile(attach, prompt+"d1 $ silence\n"+prompt+"\n")
+		}
+		ghci, ok := watcher.(*tidal.GHCiWatcher)
+		if !ok {
+			return fmt.Errorf("unexpected watcher type")
+		}
+		return ghci.ExecutePattern("d1 $ silence")
+	default:
+		return fmt.Errorf("%s only sees real executions; run some code now", name)
+	}
+}
+
+// waitingHint suggests what to check when no execution arrived
+func waitingHint(name string, config WatcherConfig, injected bool) string {
+	switch name {
+	case "tidal-hook":
+		if injected {
+			return "The test datagram was sent but not received; check firewall rules for localhost UDP"
+		}
+		return "Evaluate a pattern in your editor; check the BootTidal.hs hook with 'lcg integrate tidal --verify'"
+	case "sonicpi-osc":
+		if injected {
+			return "The test message was sent but not received; check firewall rules for localhost UDP"
+		}
+		return "Press Run in Sonic Pi; check the init.rb hook with 'lcg integrate sonicpi --verify'"
+	case "sonicpi-files":
+		return "Save a buffer in the Sonic Pi workspace while the test runs"
+	case "tidal-ghci":
+		if config.Options["attach"] != "" {
+			return "The test evaluation was appended to " + config.Options["attach"] + " but not read back; check attach_prompt"
+		}
+		return "GHCi started but did not evaluate the pattern; check that Tidal is installed for this GHCi"
+	case "hydra":
+		return "Load the snippet in Hydra with 'await loadScript(\"http://127.0.0.1:<port>/hydra.js\")', then evaluate"
+	case "strudel":
+		return "Load the snippet in Strudel with 'await import(\"http://127.0.0.1:<port>/strudel.js\")', then evaluate"
+	case "browser":
+		return "Paste the snippet from http://127.0.0.1:<port>/bridge.js into the tool's page, then evaluate"
+	case "osc-generic":
+		if injected {
+			return "The test message was sent but not received; check firewall rules for localhost UDP"
+		}
+		return "Send an OSC message matching the address option to the osc_port"
+	case "editor-http":
+		if injected {
+			return "The test event was posted but not received"
+		}
+		return "Evaluate code in your editor; its extension should POST each evaluation to /event with the token from .livecodegit/editor-token"
+	case "pulsar":
+		if injected {
+			return "The test evaluation was logged but not read; check the log_path option"
+		}
+		return "Evaluate code in Pulsar; its tidalcycles package must run GHCi through the wrapper from 'lcg integrate pulsar'"
+	case "emacs":
+		if injected {
+			return "The test evaluation was sent but not received; check the transcript or tcp_port option"
+		}
+		return "Evaluate code in Emacs; install the hook with 'lcg integrate emacs'"
+	case "editor-jsonl":
+		if injected {
+			return "The test event was sent but not received; check that nothing else reads the pipe or port"
+		}
+		return "Evaluate code in your editor; its plugin should write one JSON event per line to the pipe or tcp_port"
+	case "midi-clock":
+		return "The MIDI clock watcher commits nothing; it sets the tempo of the other watchers' commits"
+	case "ableton-link":
+		return "The Link watcher commits nothing; it sets the tempo, beat and phase of the other watchers' commits"
+	case "tidal-superdirt":
+		return "The SuperDirt watcher commits nothing; it sets the tempo, cycle and sound of the other watchers' commits"
+	default:
+		return "Run some code while the test runs"
+	}
+}
+
+// sendLine writes a line to the pipe or TCP port of a watcher reading JSON
+// lines
+func sendLine(config WatcherConfig, defaultPort int, data []byte) error {
+	if pipe := config.Options["pipe"]; pipe != "" {
+		file, err := os.OpenFile(pipe, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = file.Write(data)
+		return err
+	}
+
+	port, err := optionPort(config, "tcp_port", defaultPort)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write(data)
+	return err
+}
+
+// postEvent POSTs an execution event to an editor HTTP watcher
+func postEvent(port int, token string, data []byte) error {
+	request, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://127.0.0.1:%d%s", port, editorhttp.EventPath), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	request.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("watcher answered %s", response.Status)
+	}
+	return nil
+}
+
+// appendFile appends text to the file at path
+func appendFile(path, text string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.WriteString(text)
+	return err
+}
+
+// optionPort reads a port option, falling back to a default when unset
+func optionPort(config WatcherConfig, option string, defaultPort int) (int, error) {
+	value := config.Options[option]
+	if value == "" {
+		return defaultPort, nil
+	}
+
+	port, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", option, value)
+	}
+	return port, nil
+}
+
+// optionList reads a comma separated option, nil when unset
+func optionList(config WatcherConfig, option string) []string {
+	value := config.Options[option]
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// sendUDP sends one datagram to a local port
+func sendUDP(port int, data []byte) error {
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(data)
+	return err
+}
@@ -0,0 +1,77 @@
+package watchers
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProbeWatcherInjectsTestExecution(t *testing.T) {
+	port := freeUDPPort(t)
+	repo, path := createHookRepository(t, port)
+	defer os.RemoveAll(path)
+
+	service := NewWatcherService(repo, ResolveConfigPath(path))
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+
+	result, err := service.ProbeWatcher("tidal-hook", true, 3*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to probe watcher: %v", err)
+	}
+
+	if !result.Started || !result.Injected {
+		t.Fatalf("Expected watcher to start and receive an injected execution, got %+v", result)
+	}
+
+	if result.Event == nil {
+		t.Fatalf("Expected an execution, got diagnostics: %v", result.Diagnostics)
+	}
+
+	if result.Event.Buffer != "lcg-test" {
+		t.Errorf("Expected buffer lcg-test, got %s", result.Event.Buffer)
+	}
+
+	if result.Message != "Auto-commit: tidal execution in lcg-test" {
+		t.Errorf("Expected generated commit message, got '%s'", result.Message)
+	}
+
+	// Probing never commits
+	commits, err := repo.Log(10)
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	if len(commits) != 0 {
+		t.Errorf("Expected no commits after probing, got %d", len(commits))
+	}
+}
+
+func TestProbeWatcherTimeout(t *testing.T) {
+	port := freeUDPPort(t)
+	repo, path := createHookRepository(t, port)
+	defer os.RemoveAll(path)
+
+	service := NewWatcherService(repo, ResolveConfigPath(path))
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+
+	result, err := service.ProbeWatcher("tidal-hook", false, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to probe watcher: %v", err)
+	}
+
+	if result.Event != nil {
+		t.Errorf("Expected no execution without injection, got %+v", result.Event)
+	}
+
+	if !strings.Contains(strings.Join(result.Diagnostics, "\n"), "No execution arrived") {
+		t.Errorf("Expected a timeout diagnostic, got %v", result.Diagnostics)
+	}
+
+	if _, err := service.ProbeWatcher("no-such-watcher", true, time.Second); err == nil {
+		t.Errorf("Expected error probing an unknown watcher")
+	}
+}
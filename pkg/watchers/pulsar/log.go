@@ -0,0 +1,89 @@
+// Package pulsar records the evaluations of Pulsar's (or Atom's)
+// tidalcycles package. The package runs GHCi from its "ghciPath" setting;
+// pointing that at the wrapper 'lcg integrate pulsar' writes logs what the
+// package sends to GHCi and what GHCi reports on stderr, and the watcher
+// tails the log to reconstruct each evaluated block and its errors.
+package pulsar
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/tidal"
+)
+
+// Prefixes of the log lines the wrapper writes
+const (
+	InputPrefix = "< " // sent to GHCi
+	ErrorPrefix = "! " // GHCi's stderr
+)
+
+// DefaultLogPath returns where the wrapper logs unless configured otherwise,
+// next to the watcher configuration
+func DefaultLogPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "pulsar-ghci.log"
+	}
+	return filepath.Join(homeDir, ".livecodegit", "pulsar-ghci.log")
+}
+
+// ghciError matches the first line of a GHCi error, such as
+// "<interactive>:3:1: error:"
+var ghciError = regexp.MustCompile(`^<interactive>:\d+:\d+(-\d+)?: error`)
+
+// Evaluation is one block sent to GHCi and what it answered on stderr
+type Evaluation struct {
+	Time    time.Time // when the block was read
+	Content string
+	Error   string // empty when GHCi reported no error
+}
+
+// Log reads the wrapper's log back into evaluations. An evaluation is
+// complete once the next one starts, or once the log goes quiet, since
+// GHCi's errors follow the block they are about.
+type Log struct {
+	input   tidal.Input
+	pending *Evaluation
+	stderr  []string
+	failed  bool
+}
+
+// Feed reads one line of the log, returning the evaluation it completes
+func (l *Log) Feed(line string) (Evaluation, bool) {
+	switch {
+	case strings.HasPrefix(line, InputPrefix):
+		content, ok := l.input.Feed(strings.TrimPrefix(line, InputPrefix))
+		if !ok {
+			return Evaluation{}, false
+		}
+		previous, complete := l.Flush()
+		l.pending = &Evaluation{Time: time.Now(), Content: content}
+		return previous, complete
+	case strings.HasPrefix(line, ErrorPrefix):
+		if l.pending != nil {
+			text := strings.TrimPrefix(line, ErrorPrefix)
+			l.stderr = append(l.stderr, text)
+			if ghciError.MatchString(text) {
+				l.failed = true
+			}
+		}
+	}
+	return Evaluation{}, false
+}
+
+// Flush completes the pending evaluation, if any
+func (l *Log) Flush() (Evaluation, bool) {
+	if l.pending == nil {
+		return Evaluation{}, false
+	}
+	evaluation := *l.pending
+	if l.failed {
+		evaluation.Error = strings.TrimSpace(strings.Join(l.stderr, "\n"))
+	}
+	l.pending, l.stderr, l.failed = nil, nil, false
+	return evaluation, true
+}
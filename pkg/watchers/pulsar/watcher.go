@@ -0,0 +1,124 @@
+package pulsar
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+	"github.com/livecodegit/pkg/watchers/tail"
+)
+
+// DefaultBuffer names evaluations, since the log doesn't say which editor
+// they came from
+const DefaultBuffer = "pulsar"
+
+// pollInterval is how often the log is checked; an evaluation is committed
+// once a check finds nothing new, so its errors have arrived
+const pollInterval = 300 * time.Millisecond
+
+// Watcher tails the log of the GHCi wrapper
+type Watcher struct {
+	config   common.WatcherConfig
+	path     string
+	running  bool
+	mutex    sync.RWMutex
+	stop     chan struct{}
+	callback func(common.ExecutionEvent)
+}
+
+// NewWatcher creates a watcher tailing the log at path
+func NewWatcher(path string, config common.WatcherConfig) *Watcher {
+	return &Watcher{config: config, path: path}
+}
+
+// Start tails the log from its current end, creating it if missing
+func (w *Watcher) Start(callback func(common.ExecutionEvent)) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.running {
+		return fmt.Errorf("Pulsar watcher is already running")
+	}
+
+	follower, err := tail.Open(w.path)
+	if err != nil {
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+
+	w.callback = callback
+	w.stop = make(chan struct{})
+	w.running = true
+
+	var log Log
+	line := func(line string) {
+		if evaluation, ok := log.Feed(line); ok {
+			w.emit(evaluation)
+		}
+	}
+	idle := func() {
+		if evaluation, ok := log.Flush(); ok {
+			w.emit(evaluation)
+		}
+	}
+	// A new GHCi starts a new log
+	reset := func() {
+		idle()
+		log = Log{}
+	}
+	go follower.Run(w.stop, pollInterval, line, idle, reset)
+
+	return nil
+}
+
+// Stop stops tailing the log
+func (w *Watcher) Stop() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.running {
+		return nil
+	}
+
+	w.running = false
+	close(w.stop)
+	return nil
+}
+
+// IsRunning returns true if the watcher is active
+func (w *Watcher) IsRunning() bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.running
+}
+
+// GetConfig returns the watcher configuration
+func (w *Watcher) GetConfig() common.WatcherConfig {
+	return w.config
+}
+
+// GetLanguage returns the configured language
+func (w *Watcher) GetLanguage() string {
+	return w.config.Language
+}
+
+// GetEnvironment returns the configured environment
+func (w *Watcher) GetEnvironment() string {
+	return w.config.Environment
+}
+
+// emit passes an evaluation on as an execution
+func (w *Watcher) emit(evaluation Evaluation) {
+	event := common.ExecutionEvent{
+		Timestamp:    evaluation.Time,
+		Content:      evaluation.Content,
+		Buffer:       DefaultBuffer,
+		Language:     w.config.Language,
+		Environment:  w.config.Environment,
+		Success:      evaluation.Error == "",
+		ErrorMessage: evaluation.Error,
+	}
+	if w.callback != nil {
+		w.callback(event)
+	}
+}
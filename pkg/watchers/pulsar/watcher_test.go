@@ -0,0 +1,86 @@
+package pulsar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+func TestLog(t *testing.T) {
+	lines := []string{
+		InputPrefix + ":set prompt \"tidal> \"",
+		InputPrefix + "d1 $ s \"bd*2\"",
+		InputPrefix + ":{",
+		InputPrefix + "d2 $ s \"hh*4\"",
+		InputPrefix + "  # gain 1.2",
+		InputPrefix + ":}",
+		ErrorPrefix + "<interactive>:5:6: error:",
+		ErrorPrefix + "    Variable not in scope: gaine",
+		InputPrefix + "hush",
+	}
+
+	var log Log
+	var evaluations []Evaluation
+	for _, line := range lines {
+		if evaluation, ok := log.Feed(line); ok {
+			evaluations = append(evaluations, evaluation)
+		}
+	}
+	if evaluation, ok := log.Flush(); ok {
+		evaluations = append(evaluations, evaluation)
+	}
+
+	expected := []Evaluation{
+		{Content: "d1 $ s \"bd*2\""},
+		{Content: "d2 $ s \"hh*4\"\n  # gain 1.2", Error: "<interactive>:5:6: error:\n    Variable not in scope: gaine"},
+		{Content: "hush"},
+	}
+	if len(evaluations) != len(expected) {
+		t.Fatalf("Expected %d evaluations, got %+v", len(expected), evaluations)
+	}
+	for i := range expected {
+		if evaluations[i].Content != expected[i].Content || evaluations[i].Error != expected[i].Error {
+			t.Errorf("Expected %+v, got %+v", expected[i], evaluations[i])
+		}
+	}
+
+	// Warnings alone don't fail an evaluation
+	log.Feed(InputPrefix + "let x = 1")
+	log.Feed(ErrorPrefix + "<interactive>:1:5: warning: [-Wunused-top-binds]")
+	if evaluation, _ := log.Flush(); evaluation.Error != "" {
+		t.Errorf("Expected a warning not to be an error, got %+v", evaluation)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pulsar-ghci.log")
+
+	watcher := NewWatcher(path, common.WatcherConfig{Language: "tidal", Environment: "pulsar"})
+	events := make(chan common.ExecutionEvent, 10)
+	if err := watcher.Start(func(event common.ExecutionEvent) { events <- event }); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("Expected the log to be created: %v", err)
+	}
+	file.WriteString(InputPrefix + "d1 $ sund \"bd\"\n" + ErrorPrefix + "<interactive>:1:6: error:\n")
+	file.Close()
+
+	select {
+	case event := <-events:
+		if event.Content != "d1 $ sund \"bd\"" || event.Success || event.ErrorMessage != "<interactive>:1:6: error:" {
+			t.Errorf("Expected the failed evaluation, got %+v", event)
+		}
+		if event.Buffer != DefaultBuffer || event.Environment != "pulsar" {
+			t.Errorf("Expected the watcher's buffer and environment, got %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected an execution once the log went quiet")
+	}
+}
@@ -0,0 +1,215 @@
+package watchers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/journal"
+	"github.com/livecodegit/pkg/storage"
+)
+
+// RetryFile is the name of the file holding executions whose commit failed
+const RetryFile = "retry.json"
+
+// Retry queue defaults
+const (
+	DefaultRetryLimit    = 1000            // executions kept; the oldest are dropped beyond
+	DefaultRetryInterval = 5 * time.Second // between attempts to commit the queue
+)
+
+// RetryQueue keeps executions whose commit failed, e.g. on a full disk, in
+// order until the repository accepts them again. It is held in memory and
+// saved whenever the disk allows, so a queue survives a restart, sealed
+// once the repository is encrypted.
+type RetryQueue struct {
+	path    string
+	storage *storage.FileSystemStorage
+	limit   int
+	mutex   sync.Mutex
+	events  []PendingEvent
+}
+
+// NewRetryQueue creates a retry queue for a repository holding at most limit
+// executions, loading the executions a previous run left
+func NewRetryQueue(repoPath string, limit int) *RetryQueue {
+	queue := &RetryQueue{
+		path:    filepath.Join(repoPath, storage.RepoDir, RetryFile),
+		storage: storage.NewFileSystemStorage(repoPath),
+		limit:   limit,
+	}
+	if data, err := os.ReadFile(queue.path); err == nil {
+		if data, err = queue.storage.OpenRepoData(RetryFile, data); err != nil {
+			log.Printf("Ignoring unreadable retry queue %s: %v", queue.path, err)
+		} else if err := json.Unmarshal(data, &queue.events); err != nil {
+			log.Printf("Ignoring unreadable retry queue %s: %v", queue.path, err)
+		}
+	}
+	return queue
+}
+
+// Push queues an execution and returns how many of the oldest were dropped
+// to keep within the limit. The execution is queued even when the queue
+// can't be saved, which is reported as the error.
+func (q *RetryQueue) Push(event ExecutionEvent, message string) (int, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	receivedAt := time.Now()
+	q.events = append(q.events, PendingEvent{
+		ID:         storage.GenerateHash(event.Content + receivedAt.String())[:8],
+		ReceivedAt: receivedAt,
+		Message:    message,
+		Event:      event,
+	})
+
+	dropped := 0
+	if q.limit > 0 && len(q.events) > q.limit {
+		dropped = len(q.events) - q.limit
+		q.events = append([]PendingEvent(nil), q.events[dropped:]...)
+	}
+	return dropped, q.save()
+}
+
+// Len returns the number of queued executions
+func (q *RetryQueue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.events)
+}
+
+// Flush hands queued executions to commit, oldest first, until it fails.
+// Executions commit accepts leave the queue; it returns how many did and
+// commit's error.
+func (q *RetryQueue) Flush(commit func(PendingEvent) error) (int, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	done := 0
+	var err error
+	for _, event := range q.events {
+		if err = commit(event); err != nil {
+			break
+		}
+		done++
+	}
+	if done == 0 {
+		return 0, err
+	}
+
+	q.events = append([]PendingEvent(nil), q.events[done:]...)
+	if saveErr := q.save(); saveErr != nil {
+		log.Printf("Failed to save retry queue: %v", saveErr)
+	}
+	return done, err
+}
+
+// save writes the queue to disk, removing the file once the queue is empty
+func (q *RetryQueue) save() error {
+	if len(q.events) == 0 {
+		if err := os.Remove(q.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove retry queue: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(q.events, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal retry queue: %w", err)
+	}
+	if data, err = q.storage.SealRepoData(RetryFile, data); err != nil {
+		return fmt.Errorf("failed to write retry queue: %w", err)
+	}
+	if err := os.WriteFile(q.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write retry queue: %w", err)
+	}
+	return nil
+}
+
+// queueRetry queues an execution whose commit failed; called with ws.dedup
+// locked
+func (ws *WatcherService) queueRetry(event ExecutionEvent) {
+	message, err := ws.generateCommitMessage(event)
+	if err != nil {
+		log.Printf("Failed to generate commit message: %v", err)
+	}
+
+	dropped, err := ws.retry.Push(event, message)
+	if err != nil {
+		log.Printf("Retry queue kept in memory only: %v", err)
+	}
+	if dropped > 0 {
+		log.Printf("Retry queue full, dropped the %d oldest executions", dropped)
+		ws.eventJournal().Warn(journal.EventError, fmt.Sprintf("retry queue full, dropped the %d oldest executions", dropped))
+	}
+}
+
+// flushRetries commits the queued executions, oldest first, and reports
+// whether the queue is empty; called with ws.dedup locked
+func (ws *WatcherService) flushRetries() bool {
+	if ws.retry.Len() == 0 {
+		return true
+	}
+
+	committed, err := ws.retry.Flush(func(pending PendingEvent) error {
+		commit, err := ws.createAutoCommit(pending.Event)
+		if errors.Is(err, core.ErrCommitRejected) {
+			ws.eventJournal().Warn(journal.EventRejected, err.Error(), "buffer", pending.Event.Buffer,
+				"language", pending.Event.Language)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ws.recordCommit(commit, pending.Event)
+		return nil
+	})
+	if committed > 0 {
+		log.Printf("Committed %d queued executions", committed)
+	}
+	if err != nil {
+		log.Printf("Queued executions still failing: %v", err)
+		return false
+	}
+	return true
+}
+
+// startRetries retries the queue while the service runs; called with
+// ws.mutex held
+func (ws *WatcherService) startRetries() {
+	stop := make(chan struct{})
+	ws.retryStop = stop
+	go ws.retryLoop(stop)
+}
+
+// stopRetries stops retrying the queue; called with ws.mutex held
+func (ws *WatcherService) stopRetries() {
+	if ws.retryStop != nil {
+		close(ws.retryStop)
+		ws.retryStop = nil
+	}
+}
+
+// retryLoop commits the queue every DefaultRetryInterval until stop is
+// closed
+func (ws *WatcherService) retryLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(DefaultRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ws.dedup.Lock()
+			ws.flushRetries()
+			ws.dedup.Unlock()
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package watchers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/storage"
+)
+
+func TestRetryQueuePushAndFlush(t *testing.T) {
+	repo := createTestRepository(t)
+	defer os.RemoveAll(repo.GetPath())
+
+	queue := NewRetryQueue(repo.GetPath(), 2)
+	for _, buffer := range []string{"d1", "d2", "d3"} {
+		if _, err := queue.Push(ExecutionEvent{Content: buffer + " $ s \"bd\"", Buffer: buffer}, "execution in "+buffer); err != nil {
+			t.Fatalf("Failed to queue execution: %v", err)
+		}
+	}
+
+	// The oldest execution makes room, and the queue survives a restart
+	queue = NewRetryQueue(repo.GetPath(), 2)
+	if queue.Len() != 2 {
+		t.Fatalf("Expected 2 queued executions, got %d", queue.Len())
+	}
+
+	var buffers []string
+	failure := errors.New("disk full")
+	done, err := queue.Flush(func(pending PendingEvent) error {
+		if pending.Event.Buffer == "d3" {
+			return failure
+		}
+		buffers = append(buffers, pending.Event.Buffer)
+		return nil
+	})
+	if done != 1 || err != failure || len(buffers) != 1 || buffers[0] != "d2" {
+		t.Errorf("Expected d2 committed before d3 failed, got %d %v %v", done, buffers, err)
+	}
+	if queue.Len() != 1 {
+		t.Errorf("Expected d3 to stay queued, got %d executions", queue.Len())
+	}
+
+	if done, err := queue.Flush(func(PendingEvent) error { return nil }); done != 1 || err != nil {
+		t.Errorf("Expected the last execution committed, got %d (%v)", done, err)
+	}
+	if _, err := os.Stat(filepath.Join(repo.GetPath(), storage.RepoDir, RetryFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected the empty queue's file removed, got %v", err)
+	}
+}
+
+func TestWatcherServiceRetriesFailedCommits(t *testing.T) {
+	service, tempDir := createTestWatcherService(t)
+	defer os.RemoveAll(tempDir)
+
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+
+	// A timeline is read-only, so commits fail until it goes
+	timeline := filepath.Join(service.repository.GetPath(), storage.RepoDir, core.TimelineFile)
+	if err := os.WriteFile(timeline, []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write timeline: %v", err)
+	}
+
+	event := func(buffer string) ExecutionEvent {
+		return ExecutionEvent{Timestamp: time.Now(), Content: buffer + " $ s \"bd\"", Buffer: buffer,
+			Language: "tidal", Success: true}
+	}
+	service.handleExecutionEvent(event("d1"))
+	service.handleExecutionEvent(event("d2"))
+	if stats := service.GetStats(); stats.TotalCommits != 0 || stats.QueuedEvents != 2 {
+		t.Fatalf("Expected 2 queued executions and no commits, got %+v", stats)
+	}
+
+	if err := os.Remove(timeline); err != nil {
+		t.Fatalf("Failed to remove timeline: %v", err)
+	}
+	service.handleExecutionEvent(event("d3"))
+
+	commits, err := service.repository.History()
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	if len(commits) != 3 {
+		t.Fatalf("Expected the queue flushed before the new commit, got %d commits", len(commits))
+	}
+	for i, buffer := range []string{"d1", "d2", "d3"} {
+		if commits[i].Metadata.Buffer != buffer {
+			t.Errorf("Expected commit %d in %s, got %s", i, buffer, commits[i].Metadata.Buffer)
+		}
+	}
+	if stats := service.GetStats(); stats.TotalCommits != 3 || stats.QueuedEvents != 0 {
+		t.Errorf("Expected 3 commits and an empty queue, got %+v", stats)
+	}
+}
@@ -1,15 +1,35 @@
 package watchers
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"text/template"
 	"time"
 
 	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/journal"
+	"github.com/livecodegit/pkg/storage"
+	"github.com/livecodegit/pkg/watchers/browser"
+	"github.com/livecodegit/pkg/watchers/editorhttp"
+	"github.com/livecodegit/pkg/watchers/emacs"
+	"github.com/livecodegit/pkg/watchers/external"
+	"github.com/livecodegit/pkg/watchers/hydra"
+	"github.com/livecodegit/pkg/watchers/jsonlines"
+	"github.com/livecodegit/pkg/watchers/link"
+	"github.com/livecodegit/pkg/watchers/midi"
+	"github.com/livecodegit/pkg/watchers/oscgeneric"
+	"github.com/livecodegit/pkg/watchers/pulsar"
 	"github.com/livecodegit/pkg/watchers/sonicpi"
+	"github.com/livecodegit/pkg/watchers/strudel"
+	"github.com/livecodegit/pkg/watchers/superdirt"
 	"github.com/livecodegit/pkg/watchers/tidal"
 )
 
@@ -18,9 +38,37 @@ type WatcherService struct {
 	manager       *WatcherManager
 	configManager *ConfigManager
 	repository    *core.LiveCodeRepository
+	pending       *PendingStore
+	retry         *RetryQueue
 	running       bool
 	mutex         sync.RWMutex
 
+	// Commits and control messages arrive concurrently; the repository
+	// isn't safe for concurrent use
+	repoMutex sync.Mutex
+
+	// OSC control surface
+	controlPort int
+	controlConn *net.UDPConn
+
+	// Recent windows of an external audio analyzer
+	audio audioWindows
+
+	// Recent executions, to merge the same execution seen by several
+	// watchers, and the rate limit of each buffer
+	dedup    dedup
+	throttle throttle
+
+	// Rules keeping executions out of auto-commits
+	ignore *ignoreRules
+
+	// Each buffer's last committed code, with only_on_change
+	lastContents lastContents
+
+	// Executions waiting for the next batch commit, in batch mode
+	batch     batch
+	batchStop chan struct{}
+
 	// Auto-commit configuration
 	autoCommit        bool
 	commitMessageTmpl *template.Template
@@ -28,7 +76,24 @@ type WatcherService struct {
 	// Statistics
 	totalExecutions int64
 	totalCommits    int64
+	pendingEvents   int64
 	lastExecution   time.Time
+	startedAt       time.Time
+
+	// Delay from detection to commit of recent executions
+	latency latencies
+
+	// Closed to stop scheduling maintenance, retrying failed commits and
+	// flushing performance metadata
+	maintenanceStop chan struct{}
+	retryStop       chan struct{}
+	flushStop       chan struct{}
+
+	// Per-watcher outcome of the last Start
+	startResults []WatcherStartResult
+
+	// Journal of the running service under .livecodegit/logs; nil when stopped
+	journal *journal.Journal
 }
 
 // NewWatcherService creates a new watcher service
@@ -40,6 +105,8 @@ func NewWatcherService(repo *core.LiveCodeRepository, configPath string) *Watche
 		manager:       manager,
 		configManager: configManager,
 		repository:    repo,
+		pending:       NewPendingStore(repo.GetPath()),
+		retry:         NewRetryQueue(repo.GetPath(), DefaultRetryLimit),
 		running:       false,
 		autoCommit:    true,
 	}
@@ -65,6 +132,13 @@ func (ws *WatcherService) Initialize() error {
 	// Set up commit message template
 	config := ws.configManager.GetConfig()
 	ws.autoCommit = config.AutoCommit
+	ws.controlPort = config.ControlPort
+	ws.dedup.window = dedupWindow(config.DedupWindow)
+	ws.throttle.mode = config.RateLimit.Mode
+	ws.throttle.window, _ = parseRateLimitConfig(config.RateLimit)
+	ws.lastContents.enabled = config.OnlyOnChange
+	ws.batch.enabled = config.Batch.Enabled
+	ws.batch.interval, _ = parseBatchInterval(config.Batch.Interval)
 
 	tmpl, err := template.New("commit-message").Parse(config.CommitMessage)
 	if err != nil {
@@ -72,6 +146,12 @@ func (ws *WatcherService) Initialize() error {
 	}
 	ws.commitMessageTmpl = tmpl
 
+	ignore, err := loadIgnoreRules(config.Ignore, ws.repository.GetPath())
+	if err != nil {
+		return fmt.Errorf("invalid ignore rules: %w", err)
+	}
+	ws.ignore = ignore
+
 	// Register available watchers
 	ws.registerWatchers()
 
@@ -83,21 +163,7 @@ func (ws *WatcherService) registerWatchers() {
 	config := ws.configManager.GetConfig()
 
 	for name, watcherConfig := range config.Watchers {
-		var watcher ExecutionWatcher
-		var err error
-
-		switch name {
-		case "sonicpi-osc":
-			watcher, err = ws.createSonicPiOSCWatcher(watcherConfig)
-		case "sonicpi-files":
-			watcher, err = ws.createSonicPiFileWatcher(watcherConfig)
-		case "tidal-ghci":
-			watcher, err = ws.createTidalGHCiWatcher(watcherConfig)
-		default:
-			log.Printf("Unknown watcher type: %s", name)
-			continue
-		}
-
+		watcher, err := ws.createWatcher(name, watcherConfig)
 		if err != nil {
 			log.Printf("Failed to create watcher %s: %v", name, err)
 			continue
@@ -108,17 +174,60 @@ func (ws *WatcherService) registerWatchers() {
 	}
 }
 
+// createWatcher creates the watcher called name from its configuration
+func (ws *WatcherService) createWatcher(name string, config WatcherConfig) (ExecutionWatcher, error) {
+	switch config.Type {
+	case WatcherTypeExec:
+		return external.NewWatcher(name, config)
+	case WatcherTypePlugin:
+		return external.NewPlugin(name, config)
+	}
+
+	switch name {
+	case "sonicpi-osc":
+		return ws.createSonicPiOSCWatcher(config)
+	case "sonicpi-files":
+		return ws.createSonicPiFileWatcher(config)
+	case "tidal-ghci":
+		return ws.createTidalGHCiWatcher(config)
+	case "tidal-hook":
+		return ws.createTidalHookWatcher(config)
+	case "midi-clock":
+		return midi.NewClockWatcher(config.Options["device"]), nil
+	case "ableton-link":
+		return ws.createLinkWatcher(config)
+	case "tidal-superdirt":
+		return ws.createSuperDirtWatcher(config)
+	case "hydra":
+		return ws.createHydraWatcher(config)
+	case "strudel":
+		return ws.createStrudelWatcher(config)
+	case "browser":
+		return ws.createBrowserBridge(config)
+	case "osc-generic":
+		return ws.createGenericOSCWatcher(config)
+	case "editor-jsonl":
+		return ws.createJSONLinesWatcher(config)
+	case "editor-http":
+		return ws.createEditorHTTPWatcher(config)
+	case "emacs":
+		return ws.createEmacsWatcher(config)
+	case "pulsar":
+		return pulsar.NewWatcher(pulsarLogPath(config), config), nil
+	default:
+		return nil, fmt.Errorf("unknown watcher type: %s", name)
+	}
+}
+
 // createSonicPiOSCWatcher creates a Sonic Pi OSC watcher
 func (ws *WatcherService) createSonicPiOSCWatcher(config WatcherConfig) (ExecutionWatcher, error) {
 	port := 4559 // Default Sonic Pi OSC port
 	if portStr, exists := config.Options["osc_port"]; exists {
-		// Parse port from string (simplified for now)
-		if portStr == "4559" {
-			port = 4559
-		} else if portStr == "4560" {
-			port = 4560
+		parsed, err := strconv.Atoi(portStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid osc_port: %s", portStr)
 		}
-		// In a real implementation, use strconv.Atoi
+		port = parsed
 	}
 
 	workspacePath := config.Options["workspace_path"]
@@ -133,15 +242,210 @@ func (ws *WatcherService) createSonicPiFileWatcher(config WatcherConfig) (Execut
 		return nil, fmt.Errorf("workspace_path is required for sonicpi-files watcher")
 	}
 
-	return sonicpi.NewFileWatcher(workspacePath), nil
+	watcher := sonicpi.NewFileWatcher(workspacePath)
+	if intervalStr := config.Options["poll_interval"]; intervalStr != "" {
+		interval, err := time.ParseDuration(intervalStr)
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("invalid poll_interval: %s", intervalStr)
+		}
+		watcher.SetPollInterval(interval)
+	}
+	if debounceStr := config.Options["debounce"]; debounceStr != "" {
+		debounce, err := time.ParseDuration(debounceStr)
+		if err != nil || debounce < 0 {
+			return nil, fmt.Errorf("invalid debounce: %s", debounceStr)
+		}
+		watcher.SetDebounce(debounce)
+	}
+
+	exclude := sonicpi.DefaultExcludePatterns
+	if value := config.Options["exclude_patterns"]; value != "" {
+		exclude = patternList(value)
+	}
+	if err := watcher.SetPatterns(patternList(config.Options["include_patterns"]), exclude); err != nil {
+		return nil, err
+	}
+	return watcher, nil
 }
 
-// createTidalGHCiWatcher creates a TidalCycles GHCi watcher
+// patternList splits a comma-separated option into its patterns
+func patternList(value string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// createTidalGHCiWatcher creates a TidalCycles GHCi watcher, or one
+// following the editor's GHCi session when attach is set
 func (ws *WatcherService) createTidalGHCiWatcher(config WatcherConfig) (ExecutionWatcher, error) {
-	return tidal.NewGHCiWatcher(), nil
+	if path := config.Options["attach"]; path != "" {
+		return tidal.NewAttachWatcher(path, config.Options), nil
+	}
+	return tidal.NewGHCiWatcher(config.Options), nil
+}
+
+// createTidalHookWatcher creates a watcher for evaluations forwarded by the BootTidal hook
+func (ws *WatcherService) createTidalHookWatcher(config WatcherConfig) (ExecutionWatcher, error) {
+	port := tidal.DefaultHookPort
+	if portStr, exists := config.Options["hook_port"]; exists {
+		parsed, err := strconv.Atoi(portStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hook_port: %s", portStr)
+		}
+		port = parsed
+	}
+
+	return tidal.NewHookWatcher(port), nil
+}
+
+// createHydraWatcher creates a watcher for evaluations sent by the Hydra snippet
+func (ws *WatcherService) createHydraWatcher(config WatcherConfig) (ExecutionWatcher, error) {
+	port, err := optionPort(config, "ws_port", hydra.DefaultPort)
+	if err != nil {
+		return nil, err
+	}
+
+	return hydra.NewWatcher(port, optionList(config, "origins")), nil
+}
+
+// createStrudelWatcher creates a watcher for evaluations sent by the Strudel snippet
+func (ws *WatcherService) createStrudelWatcher(config WatcherConfig) (ExecutionWatcher, error) {
+	port, err := optionPort(config, "ws_port", strudel.DefaultPort)
+	if err != nil {
+		return nil, err
+	}
+
+	return strudel.NewWatcher(port, optionList(config, "origins")), nil
+}
+
+// createBrowserBridge creates a watcher for evaluations posted by the generic
+// browser snippet, committed with the configured language
+func (ws *WatcherService) createBrowserBridge(config WatcherConfig) (ExecutionWatcher, error) {
+	port, err := optionPort(config, "http_port", browser.DefaultBridgePort)
+	if err != nil {
+		return nil, err
+	}
+
+	return browser.NewBridge(config.Language, config.Environment, port, optionList(config, "origins")), nil
+}
+
+// createGenericOSCWatcher creates a watcher for OSC from any environment
+func (ws *WatcherService) createGenericOSCWatcher(config WatcherConfig) (ExecutionWatcher, error) {
+	port, err := optionPort(config, "osc_port", oscgeneric.DefaultPort)
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := oscgeneric.ParseMapping(config.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	return oscgeneric.NewWatcher(port, mapping, config), nil
+}
+
+// createJSONLinesWatcher creates a watcher reading editor plugins' events
+func (ws *WatcherService) createJSONLinesWatcher(config WatcherConfig) (ExecutionWatcher, error) {
+	port, err := optionPort(config, "tcp_port", jsonlines.DefaultPort)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonlines.NewWatcher(port, config.Options["pipe"], config), nil
+}
+
+// createEditorHTTPWatcher creates a watcher for editor extensions, with the
+// configured token or the one kept in the repository
+func (ws *WatcherService) createEditorHTTPWatcher(config WatcherConfig) (ExecutionWatcher, error) {
+	port, err := optionPort(config, "http_port", editorhttp.DefaultPort)
+	if err != nil {
+		return nil, err
+	}
+	tokenPath := filepath.Join(ws.repository.GetPath(), storage.RepoDir, editorhttp.TokenFile)
+
+	return editorhttp.NewWatcher(port, config.Options["token"], tokenPath, optionList(config, "origins"), config), nil
+}
+
+// createEmacsWatcher creates a watcher tailing Emacs' GHCi transcript, or
+// reading its evaluations over TCP
+func (ws *WatcherService) createEmacsWatcher(config WatcherConfig) (ExecutionWatcher, error) {
+	port, err := optionPort(config, "tcp_port", emacs.DefaultPort)
+	if err != nil {
+		return nil, err
+	}
+
+	return emacs.NewWatcher(port, config.Options["transcript"], config), nil
+}
+
+// pulsarLogPath returns the log the Pulsar GHCi wrapper writes
+func pulsarLogPath(config WatcherConfig) string {
+	if path := config.Options["log_path"]; path != "" {
+		return path
+	}
+	return pulsar.DefaultLogPath()
+}
+
+// createLinkWatcher creates a watcher following the Ableton Link session
+func (ws *WatcherService) createLinkWatcher(config WatcherConfig) (ExecutionWatcher, error) {
+	quantum := float64(link.DefaultQuantum)
+	if value, exists := config.Options["quantum"]; exists && value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid quantum: %s", value)
+		}
+		quantum = parsed
+	}
+
+	return link.NewWatcher(quantum), nil
+}
+
+// createSuperDirtWatcher creates a watcher listening to Tidal's messages
+// to SuperDirt
+func (ws *WatcherService) createSuperDirtWatcher(config WatcherConfig) (ExecutionWatcher, error) {
+	port, err := optionPort(config, "osc_port", superdirt.DefaultPort)
+	if err != nil {
+		return nil, err
+	}
+
+	return superdirt.NewWatcher(port, config.Options["forward"]), nil
+}
+
+// WatcherStartResult reports how starting one watcher, or the control
+// surface, went
+type WatcherStartResult struct {
+	Name     string
+	Endpoint string
+	Started  bool
+	Err      error
+}
+
+// StartError reports the watchers a service could not start. No watcher is
+// left running when it is returned.
+type StartError struct {
+	Results []WatcherStartResult
+}
+
+// Error lists the watchers that failed
+func (e *StartError) Error() string {
+	var failures []string
+	for _, result := range e.Results {
+		if result.Err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", result.Name, result.Err))
+		}
+	}
+	return strings.Join(failures, "; ")
 }
 
-// Start starts all enabled watchers
+// controlResultName names the control surface in start results
+const controlResultName = "control"
+
+// Start starts all enabled watchers and the control surface. Every port is
+// checked before anything listens, so a port held by another process, e.g.
+// Sonic Pi, is reported for each watcher instead of failing halfway through;
+// a *StartError then holds the result of each.
 func (ws *WatcherService) Start() error {
 	ws.mutex.Lock()
 	defer ws.mutex.Unlock()
@@ -150,38 +454,304 @@ func (ws *WatcherService) Start() error {
 		return fmt.Errorf("watcher service is already running")
 	}
 
-	// Start only enabled watchers
+	var results []WatcherStartResult
+	var watchers []ExecutionWatcher
+	failed := false
+
 	for _, name := range ws.configManager.GetEnabledWatchers() {
-		if watcher, exists := ws.manager.GetWatcher(name); exists {
-			if err := watcher.Start(ws.manager.callback); err != nil {
-				return fmt.Errorf("failed to start watcher %s: %w", name, err)
+		watcher, exists := ws.manager.GetWatcher(name)
+		if !exists {
+			continue
+		}
+		config, _ := ws.configManager.GetWatcherConfig(name)
+
+		result := WatcherStartResult{Name: name, Endpoint: watcherEndpoint(name, config)}
+		if err := checkWatcherPort(name, config); err != nil {
+			result.Err = err
+			failed = true
+		}
+		results = append(results, result)
+		watchers = append(watchers, watcher)
+	}
+
+	if ws.controlPort > 0 {
+		result := WatcherStartResult{Name: controlResultName, Endpoint: fmt.Sprintf("UDP port %d", ws.controlPort)}
+		if err := CheckUDPPort(ws.controlPort); err != nil {
+			result.Err = portHint(err, "choose another port with 'lcg watch --control <port>'")
+			failed = true
+		}
+		results = append(results, result)
+	}
+
+	if !failed {
+		for i, watcher := range watchers {
+			name := results[i].Name
+			config, _ := ws.configManager.GetWatcherConfig(name)
+			callback := func(event ExecutionEvent) {
+				event.Watchers = []string{name}
+				ws.manager.callback(attributeEvent(config, event))
+			}
+			if reporter, ok := watcher.(LifecycleReporter); ok {
+				reporter.SetLifecycleCallback(ws.lifecycleCallback(name))
+			}
+			if reader, ok := watcher.(HistoryReader); ok && ws.repository != nil {
+				reader.SetHistory(repositoryHistory{ws})
+			}
+			if err := watcher.Start(callback); err != nil {
+				results[i].Err = err
+				failed = true
+				break
 			}
+			results[i].Started = true
+		}
+	}
+
+	if !failed && ws.controlPort > 0 {
+		control := &results[len(results)-1]
+		if err := ws.startControl(ws.controlPort); err != nil {
+			control.Err = err
+			failed = true
+		} else {
+			control.Started = true
 		}
 	}
 
+	ws.startResults = results
+	ws.openJournal()
+	for _, result := range results {
+		if result.Err != nil {
+			ws.journal.Error(journal.EventWatcher, fmt.Sprintf("%s failed to start: %v", result.Name, result.Err),
+				"watcher", result.Name, "endpoint", result.Endpoint)
+		} else if !failed {
+			ws.journal.Info(journal.EventWatcher, result.Name+" started", "watcher", result.Name, "endpoint", result.Endpoint)
+		}
+	}
+
+	if failed {
+		ws.manager.StopAll()
+		for i := range results {
+			results[i].Started = false
+		}
+		ws.journal.Error(journal.EventService, "service failed to start")
+		ws.closeJournal()
+		return &StartError{Results: results}
+	}
+
 	ws.running = true
-	log.Printf("Watcher service started with %d active watchers", len(ws.configManager.GetEnabledWatchers()))
+	ws.startedAt = time.Now()
+	log.Printf("Watcher service started with %d active watchers", len(watchers))
+	ws.journal.Info(journal.EventService, fmt.Sprintf("service started with %d active watchers", len(watchers)),
+		"pid", strconv.Itoa(os.Getpid()))
+	ws.startMaintenance()
+	ws.startRetries()
+	ws.startBatch()
+	ws.startPerformanceFlush()
+
+	return nil
+}
+
+// repositoryHistory lets watchers read the repository between commits
+type repositoryHistory struct {
+	ws *WatcherService
+}
+
+// GetCommitsByBuffer returns every commit of a buffer, oldest first
+func (h repositoryHistory) GetCommitsByBuffer(buffer string) ([]*storage.Commit, error) {
+	h.ws.repoMutex.Lock()
+	defer h.ws.repoMutex.Unlock()
+	return h.ws.repository.GetCommitsByBuffer(buffer)
+}
+
+// lifecycleCallback logs and journals the lifecycle events of a watcher
+func (ws *WatcherService) lifecycleCallback(name string) func(LifecycleEvent) {
+	return func(event LifecycleEvent) {
+		log.Printf("%s: %s", name, event.Message)
+		j := ws.eventJournal()
+		if event.Success {
+			j.Info(journal.EventWatcher, event.Message, "watcher", name, "lifecycle", event.Kind)
+		} else {
+			j.Error(journal.EventWatcher, event.Message, "watcher", name, "lifecycle", event.Kind)
+		}
+	}
+}
+
+// openJournal opens the repository's journal; without one the service still
+// runs, only unrecorded
+func (ws *WatcherService) openJournal() {
+	if ws.journal != nil {
+		return
+	}
+	j, err := journal.Open(ws.repository.GetPath())
+	if err != nil {
+		log.Printf("Failed to open journal: %v", err)
+		return
+	}
+	ws.journal = j
+}
+
+// eventJournal returns the journal of the running service, for goroutines
+// outside Start and Stop
+func (ws *WatcherService) eventJournal() *journal.Journal {
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+	return ws.journal
+}
 
+// closeJournal flushes and closes the journal
+func (ws *WatcherService) closeJournal() {
+	if err := ws.journal.Close(); err != nil {
+		log.Printf("Failed to close journal: %v", err)
+	}
+	ws.journal = nil
+}
+
+// StartResults returns the result of each watcher of the last Start
+func (ws *WatcherService) StartResults() []WatcherStartResult {
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+
+	results := make([]WatcherStartResult, len(ws.startResults))
+	copy(results, ws.startResults)
+	return results
+}
+
+// checkWatcherPort checks that the UDP port a watcher listens on is free
+func checkWatcherPort(name string, config WatcherConfig) error {
+	var option string
+	var defaultPort int
+	switch name {
+	case "sonicpi-osc":
+		option, defaultPort = "osc_port", 4559
+	case "tidal-hook":
+		option, defaultPort = "hook_port", tidal.DefaultHookPort
+	case "osc-generic":
+		option, defaultPort = "osc_port", oscgeneric.DefaultPort
+	default:
+		return nil
+	}
+
+	port, err := optionPort(config, option, defaultPort)
+	if err != nil {
+		return err
+	}
+	if err := CheckUDPPort(port); err != nil {
+		hint := fmt.Sprintf("choose another port with 'lcg watch --set %s.%s=<port>'", name, option)
+		if name == "sonicpi-osc" {
+			hint += " and point the init.rb hook at it with 'lcg integrate sonicpi --port <port>'"
+		}
+		return portHint(err, hint)
+	}
 	return nil
 }
 
+// portHint adds advice to a port conflict: Sonic Pi keeps the ports it
+// owns, and another lcg watch is most likely a forgotten one
+func portHint(err error, hint string) error {
+	var conflict *PortConflict
+	if !errors.As(err, &conflict) || !conflict.InUse {
+		return err
+	}
+	if conflict.SonicPi {
+		return fmt.Errorf("%w; Sonic Pi needs this port, so %s", err, hint)
+	}
+	if conflict.Owner == "lcg" {
+		return fmt.Errorf("%w; is 'lcg watch' already running?", err)
+	}
+	return fmt.Errorf("%w; %s", err, hint)
+}
+
 // Stop stops all running watchers
 func (ws *WatcherService) Stop() error {
 	ws.mutex.Lock()
-	defer ws.mutex.Unlock()
-
 	if !ws.running {
+		ws.mutex.Unlock()
 		return nil
 	}
 
+	if ws.controlConn != nil {
+		ws.controlConn.Close()
+		ws.controlConn = nil
+	}
+	ws.stopMaintenance()
+	ws.stopRetries()
+	ws.stopBatch()
+	ws.stopPerformanceFlush()
+
+	// Whatever fails, the batch and the performance are still written and
+	// the journal closed, so nothing already executed is lost
+	var errs []error
 	if err := ws.manager.StopAll(); err != nil {
-		return fmt.Errorf("failed to stop watchers: %w", err)
+		ws.journal.Error(journal.EventService, err.Error())
+		errs = append(errs, fmt.Errorf("failed to stop watchers: %w", err))
+	}
+	ws.mutex.Unlock()
+
+	// No more executions arrive; the batch is committed, which counts
+	// commits under ws.mutex
+	ws.Flush()
+
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	ws.repoMutex.Lock()
+	defer ws.repoMutex.Unlock()
+
+	if err := ws.repository.FlushPerformance(); err != nil {
+		ws.journal.Error(journal.EventService, fmt.Sprintf("failed to flush performance: %v", err))
+		errs = append(errs, fmt.Errorf("failed to flush performance: %w", err))
 	}
 
 	ws.running = false
 	log.Printf("Watcher service stopped")
+	ws.journal.Info(journal.EventService, fmt.Sprintf("service stopped after %s: %d executions, %d commits",
+		time.Since(ws.startedAt).Round(time.Second), ws.totalExecutions, ws.totalCommits))
+	ws.closeJournal()
 
-	return nil
+	return errors.Join(errs...)
+}
+
+// startPerformanceFlush writes the performance metadata held back between
+// commits once its flush interval has passed, so a quiet performance is not
+// left stale until the next commit. A performance ended by another process
+// meanwhile is left ended. Called with ws.mutex held.
+func (ws *WatcherService) startPerformanceFlush() {
+	interval := ws.repository.PerformanceFlushInterval()
+	if interval <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	ws.flushStop = stop
+	go ws.performanceFlushLoop(interval, stop)
+}
+
+// stopPerformanceFlush stops flushing performance metadata on a timer;
+// called with ws.mutex held
+func (ws *WatcherService) stopPerformanceFlush() {
+	if ws.flushStop != nil {
+		close(ws.flushStop)
+		ws.flushStop = nil
+	}
+}
+
+// performanceFlushLoop flushes the performance every interval until stop is
+// closed
+func (ws *WatcherService) performanceFlushLoop(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ws.repoMutex.Lock()
+			err := ws.repository.FlushPerformance()
+			ws.repoMutex.Unlock()
+			if err != nil {
+				log.Printf("Failed to flush performance: %v", err)
+			}
+		}
+	}
 }
 
 // IsRunning returns true if the service is running
@@ -191,6 +761,20 @@ func (ws *WatcherService) IsRunning() bool {
 	return ws.running
 }
 
+// attributeEvent attributes an event to the author configured for its watcher
+func attributeEvent(config WatcherConfig, event ExecutionEvent) ExecutionEvent {
+	if event.Author == "" && config.Author != "" {
+		event.Author = config.Author
+		event.AuthorEmail = config.AuthorEmail
+	}
+	return event
+}
+
+// eventAuthor returns the identity an event is committed as
+func eventAuthor(event ExecutionEvent) core.UserConfig {
+	return core.UserConfig{Name: event.Author, Email: event.AuthorEmail}
+}
+
 // handleExecutionEvent processes execution events from watchers
 func (ws *WatcherService) handleExecutionEvent(event ExecutionEvent) {
 	ws.mutex.Lock()
@@ -198,39 +782,217 @@ func (ws *WatcherService) handleExecutionEvent(event ExecutionEvent) {
 	ws.lastExecution = event.Timestamp
 	ws.mutex.Unlock()
 
+	if event.Audio == nil {
+		event.Audio = ws.audio.nearest(event.Timestamp)
+	}
+	if tempo, ok := ws.tempo(event.Timestamp); ok {
+		event.BPM = tempo.BPM
+		event.BeatsFromStart = tempo.Beats
+		event.Phase = tempo.Phase
+	}
+	if event.Sound == nil {
+		event.Sound = ws.sound(event.Timestamp)
+	}
+
+	ws.dedup.Lock()
+	defer ws.dedup.Unlock()
+	if recent := ws.dedup.find(event); recent != nil {
+		ws.mergeDuplicate(recent, event)
+		return
+	}
+
 	log.Printf("Execution detected: %s/%s - %s", event.Language, event.Buffer,
 		truncateString(event.Content, 50))
 
-	// Create auto-commit if enabled
-	if ws.autoCommit {
-		if err := ws.createAutoCommit(event); err != nil {
-			log.Printf("Failed to create auto-commit: %v", err)
-		} else {
-			ws.mutex.Lock()
-			ws.totalCommits++
-			ws.mutex.Unlock()
+	if ws.ignoreExecution(event) {
+		ws.dedup.add(event, "")
+		return
+	}
+
+	// Events that are not committed stay pending for review with 'lcg pending'
+	if !ws.autoCommit {
+		ws.dedup.add(event, "")
+		ws.addPendingEvent(event)
+		return
+	}
+
+	// Executions of dense sets wait for the batch's commit
+	if ws.batch.enabled {
+		ws.dedup.add(event, "")
+		ws.batch.add(event)
+		return
+	}
+
+	ws.commitExecution(event)
+}
+
+// commitExecution commits an execution after the executions queued for
+// retry; called with ws.dedup locked
+func (ws *WatcherService) commitExecution(event ExecutionEvent) {
+	// Executions queued after a failed commit go first, keeping the order
+	if !ws.flushRetries() {
+		ws.dedup.add(event, "")
+		ws.queueRetry(event)
+		return
+	}
+
+	if ws.unchanged(event) {
+		ws.dedup.add(event, "")
+		return
+	}
+
+	if ws.throttleExecution(&event) {
+		return
+	}
+
+	commit, err := ws.createAutoCommit(event)
+	if errors.Is(err, core.ErrCommitRejected) {
+		log.Printf("Execution not committed: %v", err)
+		ws.eventJournal().Warn(journal.EventRejected, err.Error(), "buffer", event.Buffer, "language", event.Language)
+		ws.dedup.add(event, "")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to create auto-commit, queued for retry: %v", err)
+		ws.eventJournal().Error(journal.EventError, fmt.Sprintf("failed to commit execution in %s: %v", event.Buffer, err),
+			"buffer", event.Buffer, "language", event.Language)
+		ws.dedup.add(event, "")
+		ws.queueRetry(event)
+		return
+	}
+	ws.dedup.add(event, commit.Hash)
+	ws.throttle.committed(event, commit.Hash)
+	ws.recordCommit(commit, event)
+}
+
+// recordCommit counts and journals the commit of an execution
+func (ws *WatcherService) recordCommit(commit *core.Commit, event ExecutionEvent) {
+	ws.lastContents.committed(event.Buffer, event.Content)
+
+	// Timestamps come from the watchers, so this covers parsing, queueing
+	// and writing the commit
+	latency := time.Since(event.Timestamp)
+	ws.latency.add(latency)
+
+	ws.mutex.Lock()
+	ws.totalCommits++
+	ws.mutex.Unlock()
+
+	fields := []string{"hash", commit.Hash, "buffer", event.Buffer, "language", event.Language,
+		"latency", latency.String()}
+	if !event.Success {
+		fields = append(fields, "error", event.ErrorMessage)
+	}
+	ws.eventJournal().Info(journal.EventCommit, commit.Message, fields...)
+}
+
+// mergeDuplicate merges an event into the recent execution it duplicates.
+// When that execution's commit is still HEAD it is amended with the combined
+// metadata; otherwise the duplicate is dropped.
+func (ws *WatcherService) mergeDuplicate(recent *recentExecution, event ExecutionEvent) {
+	recent.event = mergeEvents(recent.event, event)
+	log.Printf("Duplicate execution merged: %s/%s seen by %s", event.Language, event.Buffer,
+		strings.Join(recent.event.Watchers, ", "))
+	if recent.commit == "" {
+		ws.batch.replace(recent.event)
+		return
+	}
+
+	message, err := ws.generateCommitMessage(recent.event)
+	if err != nil {
+		log.Printf("Failed to generate commit message: %v", err)
+		return
+	}
+
+	ws.repoMutex.Lock()
+	defer ws.repoMutex.Unlock()
+	head, err := ws.repository.Log(1)
+	if err != nil || len(head) == 0 || head[0].Hash != recent.commit {
+		return
+	}
+	commit, err := ws.repository.Amend(head[0].Content, message, recent.event.ToExecutionMetadata())
+	if err != nil {
+		log.Printf("Failed to merge duplicate execution: %v", err)
+		return
+	}
+	recent.commit = commit.Hash
+	ws.eventJournal().Info(journal.EventCommit, commit.Message, "hash", commit.Hash, "buffer", event.Buffer,
+		"language", event.Language, "merged", strings.Join(recent.event.Watchers, ","))
+}
+
+// tempo returns the tempo of the first running watcher following a clock,
+// by name
+func (ws *WatcherService) tempo(at time.Time) (Tempo, bool) {
+	names := ws.manager.ListWatchers()
+	sort.Strings(names)
+	for _, name := range names {
+		watcher, _ := ws.manager.GetWatcher(name)
+		if source, ok := watcher.(TempoSource); ok && watcher.IsRunning() {
+			if tempo, ok := source.Tempo(at); ok {
+				return tempo, true
+			}
+		}
+	}
+	return Tempo{}, false
+}
+
+// sound returns what the first running watcher seeing a synth's events,
+// by name, says was playing, or nil
+func (ws *WatcherService) sound(at time.Time) *core.SoundActivity {
+	names := ws.manager.ListWatchers()
+	sort.Strings(names)
+	for _, name := range names {
+		watcher, _ := ws.manager.GetWatcher(name)
+		if source, ok := watcher.(SoundSource); ok && watcher.IsRunning() {
+			if activity, ok := source.Sound(at); ok {
+				return activity
+			}
 		}
 	}
+	return nil
+}
+
+// addPendingEvent keeps an uncommitted event in the repository's pending list
+func (ws *WatcherService) addPendingEvent(event ExecutionEvent) {
+	ws.mutex.Lock()
+	ws.pendingEvents++
+	ws.mutex.Unlock()
+
+	message, err := ws.generateCommitMessage(event)
+	if err != nil {
+		log.Printf("Failed to generate commit message: %v", err)
+	}
+
+	pending, err := ws.pending.Add(event, message)
+	if err != nil {
+		log.Printf("Failed to save pending event: %v", err)
+		ws.eventJournal().Error(journal.EventError, fmt.Sprintf("failed to save pending execution in %s: %v", event.Buffer, err),
+			"buffer", event.Buffer)
+		return
+	}
+	ws.eventJournal().Info(journal.EventPending, message, "id", pending.ID, "buffer", event.Buffer, "language", event.Language)
 }
 
 // createAutoCommit creates a commit from an execution event
-func (ws *WatcherService) createAutoCommit(event ExecutionEvent) error {
+func (ws *WatcherService) createAutoCommit(event ExecutionEvent) (*core.Commit, error) {
 	// Generate commit message from template
 	commitMessage, err := ws.generateCommitMessage(event)
 	if err != nil {
-		return fmt.Errorf("failed to generate commit message: %w", err)
+		return nil, fmt.Errorf("failed to generate commit message: %w", err)
 	}
 
 	// Convert event to metadata
 	metadata := event.ToExecutionMetadata()
 
 	// Create commit
-	_, err = ws.repository.Commit(event.Content, commitMessage, metadata)
+	ws.repoMutex.Lock()
+	commit, err := ws.repository.CommitAs(event.Content, commitMessage, metadata, eventAuthor(event))
+	ws.repoMutex.Unlock()
 	if err != nil {
-		return fmt.Errorf("failed to create commit: %w", err)
+		return nil, fmt.Errorf("failed to create commit: %w", err)
 	}
 
-	return nil
+	return commit, nil
 }
 
 // generateCommitMessage generates a commit message from template and event
@@ -244,11 +1006,13 @@ func (ws *WatcherService) generateCommitMessage(event ExecutionEvent) (string, e
 		Buffer      string
 		Timestamp   string
 		Success     string
+		Author      string
 	}{
 		Language:    event.Language,
 		Environment: event.Environment,
 		Buffer:      event.Buffer,
 		Timestamp:   event.Timestamp.Format("15:04:05"),
+		Author:      event.Author,
 		Success: func() string {
 			if event.Success {
 				return "success"
@@ -266,15 +1030,37 @@ func (ws *WatcherService) generateCommitMessage(event ExecutionEvent) (string, e
 
 // GetStats returns service statistics
 func (ws *WatcherService) GetStats() ServiceStats {
+	// The queue is locked while it commits, which takes ws.mutex
+	queued := ws.retry.Len()
+
 	ws.mutex.RLock()
 	defer ws.mutex.RUnlock()
 
 	return ServiceStats{
 		TotalExecutions: ws.totalExecutions,
 		TotalCommits:    ws.totalCommits,
+		PendingEvents:   ws.pendingEvents,
+		QueuedEvents:    queued,
 		LastExecution:   ws.lastExecution,
 		ActiveWatchers:  len(ws.configManager.GetEnabledWatchers()),
 		Running:         ws.running,
+		Latency:         summarizeLatencies(ws.latency.snapshot()),
+	}
+}
+
+// GetState returns a snapshot of the service suitable for persisting with WriteServiceState
+func (ws *WatcherService) GetState() ServiceState {
+	ws.mutex.RLock()
+	startedAt := ws.startedAt
+	ws.mutex.RUnlock()
+
+	return ServiceState{
+		PID:         os.Getpid(),
+		StartedAt:   startedAt,
+		UpdatedAt:   time.Now(),
+		Watchers:    ws.GetEnabledWatchers(),
+		Stats:       ws.GetStats(),
+		ControlPort: ws.ControlPort(),
 	}
 }
 
@@ -312,6 +1098,57 @@ func (ws *WatcherService) DisableWatcher(name string) error {
 	return ws.configManager.SaveConfig()
 }
 
+// SetWatcherOption sets one option of a watcher, or of maintenance, and
+// saves the configuration
+func (ws *WatcherService) SetWatcherOption(name, option, value string) error {
+	if name == MaintenanceConfigName {
+		if err := ws.configManager.SetMaintenanceOption(option, value); err != nil {
+			return err
+		}
+	} else if err := ws.configManager.SetWatcherOption(name, option, value); err != nil {
+		return err
+	}
+
+	if err := ws.configManager.ValidateConfig(); err != nil {
+		return err
+	}
+
+	return ws.configManager.SaveConfig()
+}
+
+// AddExecWatcher adds an enabled watcher running command as an external
+// program, for executions in language
+func (ws *WatcherService) AddExecWatcher(name, command, language string) error {
+	if _, exists := ws.configManager.GetWatcherConfig(name); exists {
+		return fmt.Errorf("watcher '%s' already exists", name)
+	}
+	if language == "" {
+		language = "unknown"
+	}
+
+	ws.configManager.SetWatcherConfig(name, WatcherConfig{
+		Type:        WatcherTypeExec,
+		Command:     command,
+		Language:    language,
+		Environment: name,
+		Enabled:     true,
+		Options:     map[string]string{},
+	})
+	if err := ws.configManager.ValidateConfig(); err != nil {
+		delete(ws.configManager.config.Watchers, name)
+		return err
+	}
+
+	return ws.configManager.SaveConfig()
+}
+
+// ListWatchers returns the names of all configured watchers, sorted
+func (ws *WatcherService) ListWatchers() []string {
+	names := ws.configManager.ListWatchers()
+	sort.Strings(names)
+	return names
+}
+
 // UpdateWatcherConfig updates configuration for a specific watcher
 func (ws *WatcherService) UpdateWatcherConfig(name string, config WatcherConfig) error {
 	ws.configManager.SetWatcherConfig(name, config)
@@ -327,9 +1164,14 @@ func (ws *WatcherService) GetWatcherConfig(name string) (WatcherConfig, bool) {
 type ServiceStats struct {
 	TotalExecutions int64     `json:"total_executions"`
 	TotalCommits    int64     `json:"total_commits"`
+	PendingEvents   int64     `json:"pending_events"`
+	QueuedEvents    int       `json:"queued_events,omitempty"` // waiting to be retried after a failed commit
 	LastExecution   time.Time `json:"last_execution"`
 	ActiveWatchers  int       `json:"active_watchers"`
 	Running         bool      `json:"running"`
+
+	// Of the most recent commits
+	Latency LatencyStats `json:"latency"`
 }
 
 // truncateString truncates a string to a maximum length
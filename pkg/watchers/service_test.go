@@ -3,11 +3,15 @@ package watchers
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/journal"
+	"github.com/livecodegit/pkg/storage"
 )
 
 func createTestRepository(t *testing.T) *core.LiveCodeRepository {
@@ -137,6 +141,174 @@ func TestWatcherServiceStartStop(t *testing.T) {
 	}
 }
 
+func TestWatcherServiceJournal(t *testing.T) {
+	service, tempDir := createTestWatcherService(t)
+	defer os.RemoveAll(tempDir)
+	defer os.RemoveAll(service.repository.GetPath())
+
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+
+	config := service.configManager.GetConfig()
+	for name, watcherConfig := range config.Watchers {
+		watcherConfig.Enabled = false
+		service.configManager.SetWatcherConfig(name, watcherConfig)
+	}
+	mockWatcher := &MockWatcher{config: WatcherConfig{Language: "tidal", Environment: "test-env", Enabled: true}}
+	service.manager.RegisterWatcher("mock-watcher", mockWatcher)
+	service.configManager.SetWatcherConfig("mock-watcher", mockWatcher.config)
+
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	mockWatcher.TriggerEvent(ExecutionEvent{
+		Timestamp: time.Now(),
+		Content:   "d1 $ s \"bd\"",
+		Buffer:    "d1",
+		Language:  "tidal",
+		Success:   true,
+	})
+	if err := service.Stop(); err != nil {
+		t.Fatalf("Failed to stop service: %v", err)
+	}
+
+	entries, err := journal.Read(service.repository.GetPath(), 0)
+	if err != nil {
+		t.Fatalf("Failed to read journal: %v", err)
+	}
+
+	var events []string
+	for _, entry := range entries {
+		events = append(events, entry.Event)
+	}
+	expected := []string{journal.EventWatcher, journal.EventService, journal.EventCommit, journal.EventService}
+	if strings.Join(events, " ") != strings.Join(expected, " ") {
+		t.Fatalf("Expected journal events %v, got %v", expected, events)
+	}
+
+	commit := entries[2]
+	if commit.Fields["buffer"] != "d1" || len(commit.Fields["hash"]) != 64 {
+		t.Errorf("Expected commit entry with hash and buffer, got %+v", commit)
+	}
+	if !strings.Contains(entries[3].Message, "1 commits") {
+		t.Errorf("Expected stop entry with stats, got %s", entries[3].Message)
+	}
+}
+
+func TestWatcherServiceFlushesQuietPerformance(t *testing.T) {
+	service, tempDir := createTestWatcherService(t)
+	defer os.RemoveAll(tempDir)
+
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+	config := service.configManager.GetConfig()
+	for name, watcherConfig := range config.Watchers {
+		watcherConfig.Enabled = false
+		service.configManager.SetWatcherConfig(name, watcherConfig)
+	}
+
+	performance, err := service.repository.StartPerformance("Quiet Set")
+	if err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+	service.repository.SetPerformanceFlushInterval(50 * time.Millisecond)
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer service.Stop()
+
+	service.handleExecutionEvent(ExecutionEvent{
+		Timestamp:   time.Now(),
+		Content:     "test code",
+		Buffer:      "test-buffer",
+		Language:    "sonicpi",
+		Environment: "sonic-pi",
+		Success:     true,
+	})
+
+	// No further commit comes; the timer writes the count anyway
+	store := storage.NewFileSystemStorage(service.repository.GetPath())
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stored, err := store.ReadPerformance(performance.ID)
+		if err != nil {
+			t.Fatalf("Failed to read performance: %v", err)
+		}
+		if stored.CommitCount == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the performance flushed without another commit, got %d commits", stored.CommitCount)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestWatcherServiceKeepsPerformanceEndedElsewhere(t *testing.T) {
+	service, tempDir := createTestWatcherService(t)
+	defer os.RemoveAll(tempDir)
+
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+	config := service.configManager.GetConfig()
+	for name, watcherConfig := range config.Watchers {
+		watcherConfig.Enabled = false
+		service.configManager.SetWatcherConfig(name, watcherConfig)
+	}
+
+	performance, err := service.repository.StartPerformance("Long Set")
+	if err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+	service.repository.SetPerformanceFlushInterval(50 * time.Millisecond)
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer service.Stop()
+
+	// The commit leaves the performance to the timer
+	service.repository.SetPerformanceFlushInterval(time.Hour)
+	service.handleExecutionEvent(ExecutionEvent{
+		Timestamp:   time.Now(),
+		Content:     "test code",
+		Buffer:      "test-buffer",
+		Language:    "sonicpi",
+		Environment: "sonic-pi",
+		Success:     true,
+	})
+
+	// 'lcg performance end' from another process, before the timer flushes
+	cli, err := core.LoadRepository(service.repository.GetPath())
+	if err != nil {
+		t.Fatalf("Failed to load repository: %v", err)
+	}
+	if err := cli.EndPerformance(); err != nil {
+		t.Fatalf("Failed to end performance: %v", err)
+	}
+
+	store := storage.NewFileSystemStorage(service.repository.GetPath())
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		service.repoMutex.Lock()
+		current, _ := service.repository.GetCurrentPerformance()
+		service.repoMutex.Unlock()
+		if current == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the timed flush to drop the ended performance")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	stored, err := store.ReadPerformance(performance.ID)
+	if err != nil || stored.EndTime.IsZero() {
+		t.Errorf("Expected the performance to stay ended, got %+v (%v)", stored, err)
+	}
+}
+
 func TestWatcherServiceHandleExecutionEvent(t *testing.T) {
 	service, tempDir := createTestWatcherService(t)
 	defer os.RemoveAll(tempDir)
@@ -178,6 +350,160 @@ func TestWatcherServiceHandleExecutionEvent(t *testing.T) {
 	if stats.LastExecution.IsZero() {
 		t.Errorf("Expected last execution time to be set")
 	}
+
+	if stats.Latency.Samples != 1 || stats.Latency.Max <= 0 {
+		t.Errorf("Expected the commit's latency to be measured, got %+v", stats.Latency)
+	}
+}
+
+func TestWatcherServiceCommitsAsWatcherAuthor(t *testing.T) {
+	service, tempDir := createTestWatcherService(t)
+	defer os.RemoveAll(tempDir)
+
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+
+	config := WatcherConfig{Author: "Alex", AuthorEmail: "alex@example.com"}
+	event := ExecutionEvent{
+		Timestamp:   time.Now(),
+		Content:     "d1 $ s \"bd*2\"",
+		Buffer:      "d1",
+		Language:    "tidal",
+		Environment: "tidal",
+		Success:     true,
+	}
+
+	service.handleExecutionEvent(attributeEvent(config, event))
+
+	commits, err := service.repository.Log(1)
+	if err != nil || len(commits) != 1 {
+		t.Fatalf("Expected 1 commit, got %d (%v)", len(commits), err)
+	}
+	if commits[0].Author != "Alex" || commits[0].AuthorEmail != "alex@example.com" {
+		t.Errorf("Expected author 'Alex' <alex@example.com>, got '%s' <%s>", commits[0].Author, commits[0].AuthorEmail)
+	}
+
+	// An author already reported by the event wins over the watcher's
+	event.Author = "Kate"
+	if attributed := attributeEvent(config, event); attributed.Author != "Kate" || attributed.AuthorEmail != "" {
+		t.Errorf("Expected event author 'Kate' to be kept, got '%s' <%s>", attributed.Author, attributed.AuthorEmail)
+	}
+}
+
+func TestWatcherServiceExecWatcher(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test program is a shell script")
+	}
+
+	service, tempDir := createTestWatcherService(t)
+	defer os.RemoveAll(tempDir)
+
+	script := filepath.Join(tempDir, "watcher.sh")
+	program := "#!/bin/sh\necho '{\"content\":\"p1 >> pluck()\",\"buffer\":\"p1\"}'\nsleep 60\n"
+	if err := os.WriteFile(script, []byte(program), 0755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	if err := service.AddExecWatcher("foxdot", script, "foxdot"); err != nil {
+		t.Fatalf("Failed to add watcher: %v", err)
+	}
+	if err := service.AddExecWatcher("foxdot", script, "foxdot"); err == nil {
+		t.Errorf("Expected adding the same watcher twice to fail")
+	}
+
+	// Added watchers are saved and started like the built-in ones
+	service = NewWatcherService(service.repository, service.configManager.configPath)
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer service.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for service.GetStats().TotalCommits == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	commits, err := service.repository.Log(1)
+	if err != nil || len(commits) != 1 {
+		t.Fatalf("Expected the program's execution to be committed, got %d (%v)", len(commits), err)
+	}
+	if commits[0].Metadata.Language != "foxdot" || commits[0].Metadata.Environment != "foxdot" {
+		t.Errorf("Expected a foxdot commit from the foxdot watcher, got %+v", commits[0].Metadata)
+	}
+}
+
+// clockWatcher is a running watcher following a clock at a fixed tempo
+type clockWatcher struct {
+	ExecutionWatcher
+	tempo Tempo
+}
+
+func (w clockWatcher) IsRunning() bool { return true }
+
+func (w clockWatcher) Tempo(time.Time) (Tempo, bool) { return w.tempo, true }
+
+func TestWatcherServiceTempo(t *testing.T) {
+	service, tempDir := createTestWatcherService(t)
+	defer os.RemoveAll(tempDir)
+
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+	service.manager.RegisterWatcher("midi-clock", clockWatcher{tempo: Tempo{BPM: 128, Beats: 64, Phase: 1.5}})
+
+	// The clock's tempo wins over the watcher's guess
+	service.handleExecutionEvent(ExecutionEvent{
+		Timestamp: time.Now(),
+		Content:   "d1 $ s \"bd*4\"",
+		Buffer:    "d1",
+		Language:  "tidal",
+		Success:   true,
+		BPM:       120,
+	})
+
+	commits, err := service.repository.Log(1)
+	if err != nil || len(commits) != 1 {
+		t.Fatalf("Expected 1 commit, got %d (%v)", len(commits), err)
+	}
+	if metadata := commits[0].Metadata; metadata.BPM != 128 || metadata.BeatsFromStart != 64 || metadata.Phase != 1.5 {
+		t.Errorf("Expected 128 BPM at beat 64, phase 1.5, got %+v", metadata)
+	}
+}
+
+// dirtWatcher is a running watcher seeing a synth's events
+type dirtWatcher struct {
+	ExecutionWatcher
+	sound core.SoundActivity
+}
+
+func (w dirtWatcher) IsRunning() bool { return true }
+
+func (w dirtWatcher) Sound(time.Time) (*core.SoundActivity, bool) { return &w.sound, true }
+
+func TestWatcherServiceSound(t *testing.T) {
+	service, tempDir := createTestWatcherService(t)
+	defer os.RemoveAll(tempDir)
+
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+	service.manager.RegisterWatcher("tidal-superdirt", dirtWatcher{sound: core.SoundActivity{
+		Cycle: 12.5, Orbits: []int{0, 1}, EventDensity: 6, Sounds: []string{"bd", "hh"},
+	}})
+
+	service.handleExecutionEvent(ExecutionEvent{Timestamp: time.Now(), Content: "d2 $ s \"hh*4\"", Buffer: "d2", Language: "tidal", Success: true})
+
+	commits, err := service.repository.Log(1)
+	if err != nil || len(commits) != 1 {
+		t.Fatalf("Expected 1 commit, got %d (%v)", len(commits), err)
+	}
+	if sound := commits[0].Metadata.Sound; sound == nil || sound.Cycle != 12.5 || len(sound.Orbits) != 2 || sound.Sounds[1] != "hh" {
+		t.Errorf("Expected the commit to carry what was playing, got %+v", sound)
+	}
 }
 
 func TestWatcherServiceAutoCommitDisabled(t *testing.T) {
@@ -214,6 +540,24 @@ func TestWatcherServiceAutoCommitDisabled(t *testing.T) {
 	if stats.TotalCommits != 0 {
 		t.Errorf("Expected 0 commits with auto-commit disabled, got %d", stats.TotalCommits)
 	}
+
+	// The event should wait in the pending inbox instead
+	pending, err := NewPendingStore(service.repository.GetPath()).List()
+	if err != nil {
+		t.Fatalf("Failed to list pending events: %v", err)
+	}
+
+	if len(pending) != 1 {
+		t.Fatalf("Expected 1 pending event, got %d", len(pending))
+	}
+
+	if pending[0].Event.Content != "test code" {
+		t.Errorf("Expected pending content 'test code', got '%s'", pending[0].Event.Content)
+	}
+
+	if pending[0].Message == "" {
+		t.Errorf("Expected pending event to keep its generated commit message")
+	}
 }
 
 func TestWatcherServiceGenerateCommitMessage(t *testing.T) {
@@ -399,3 +743,60 @@ func TestWatcherServiceStats(t *testing.T) {
 		t.Errorf("Expected 1 active watcher after enabling, got %d", stats.ActiveWatchers)
 	}
 }
+
+func TestWatcherServiceMergesDuplicates(t *testing.T) {
+	service, tempDir := createTestWatcherService(t)
+	defer os.RemoveAll(tempDir)
+
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+
+	now := time.Now()
+	service.handleExecutionEvent(ExecutionEvent{
+		Timestamp:   now,
+		Content:     "play 60\n",
+		Buffer:      "workspace_zero",
+		Language:    "sonicpi",
+		Environment: "sonic-pi",
+		Success:     true,
+		Watchers:    []string{"sonicpi-files"},
+	})
+	service.handleExecutionEvent(ExecutionEvent{
+		Timestamp:    now.Add(100 * time.Millisecond),
+		Content:      "play 60",
+		Buffer:       "workspace_zero",
+		Language:     "sonicpi",
+		Environment:  "sonic-pi",
+		Success:      false,
+		ErrorMessage: "Runtime Error",
+		Watchers:     []string{"sonicpi-osc"},
+	})
+
+	commits, err := service.repository.Log(10)
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	if len(commits) != 1 {
+		t.Fatalf("Expected the duplicate to be merged into 1 commit, got %d", len(commits))
+	}
+	metadata := commits[0].Metadata
+	if metadata.Success || metadata.ErrorMessage != "Runtime Error" {
+		t.Errorf("Expected the merged commit to carry the failure, got %+v", metadata)
+	}
+	if !reflect.DeepEqual(metadata.Watchers, []string{"sonicpi-files", "sonicpi-osc"}) {
+		t.Errorf("Expected both watchers, got %v", metadata.Watchers)
+	}
+
+	// The same content in another buffer is another execution
+	service.handleExecutionEvent(ExecutionEvent{
+		Timestamp: now.Add(200 * time.Millisecond),
+		Content:   "play 60",
+		Buffer:    "workspace_one",
+		Language:  "sonicpi",
+		Success:   true,
+	})
+	if commits, _ := service.repository.Log(10); len(commits) != 2 {
+		t.Errorf("Expected another buffer to make its own commit, got %d commits", len(commits))
+	}
+}
@@ -0,0 +1,103 @@
+package sonicpi
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/livecodegit/pkg/osc"
+)
+
+// DefaultAPIPort is where Sonic Pi 3 servers listen to the GUI. Sonic Pi 4
+// picks its ports when it boots and logs them with the GUI's token.
+const DefaultAPIPort = 4557
+
+// guiID identifies lcg to Sonic Pi 3 servers, which take any GUI ID where
+// Sonic Pi 4 expects the token
+const guiID = "livecodegit"
+
+// Lines of Sonic Pi 4's daemon log naming the token and the server's port
+var (
+	daemonTokenPattern = regexp.MustCompile(`[Tt]oken[:=>\s]+(-?\d+)`)
+	daemonPortPattern  = regexp.MustCompile(`server_listen_to_gui[\s:=>"]+(\d+)`)
+)
+
+// Client runs code in Sonic Pi the way its GUI does, through the server's
+// /run-code message
+type Client struct {
+	conn  net.Conn
+	token int32
+}
+
+// APIConfig is where and how to reach a Sonic Pi server
+type APIConfig struct {
+	Port  int
+	Token int32 // zero for Sonic Pi 3, which has none
+}
+
+// DiscoverAPI reads the port and token of the running Sonic Pi from its
+// daemon log, falling back to Sonic Pi 3's fixed port without a token
+func DiscoverAPI() APIConfig {
+	config := APIConfig{Port: DefaultAPIPort}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return config
+	}
+	data, err := os.ReadFile(filepath.Join(homeDir, ".sonic-pi", "log", "daemon.log"))
+	if err != nil {
+		return config
+	}
+	return parseDaemonLog(string(data), config)
+}
+
+// parseDaemonLog takes the last token and port the daemon log names, which
+// belong to the Sonic Pi running now
+func parseDaemonLog(log string, config APIConfig) APIConfig {
+	if matches := daemonTokenPattern.FindAllStringSubmatch(log, -1); len(matches) > 0 {
+		if token, err := strconv.ParseInt(matches[len(matches)-1][1], 10, 32); err == nil {
+			config.Token = int32(token)
+		}
+	}
+	if matches := daemonPortPattern.FindAllStringSubmatch(log, -1); len(matches) > 0 {
+		if port, err := strconv.Atoi(matches[len(matches)-1][1]); err == nil {
+			config.Port = port
+		}
+	}
+	return config
+}
+
+// NewClient creates a client for the Sonic Pi server on localhost
+func NewClient(config APIConfig) (*Client, error) {
+	if config.Port == 0 {
+		config.Port = DefaultAPIPort
+	}
+	conn, err := net.Dial("udp", fmt.Sprintf("127.0.0.1:%d", config.Port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach Sonic Pi on port %d: %w", config.Port, err)
+	}
+	return &Client{conn: conn, token: config.Token}, nil
+}
+
+// RunCode runs code as if Run was pressed in the GUI
+func (c *Client) RunCode(code string) error {
+	var id interface{} = guiID
+	if c.token != 0 {
+		id = c.token
+	}
+
+	data, err := osc.Message{Address: "/run-code", Args: []interface{}{id, code}}.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Write(data)
+	return err
+}
+
+// Close releases the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,68 @@
+package sonicpi
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/osc"
+)
+
+func TestParseDaemonLog(t *testing.T) {
+	log := `[daemon] - Token: 12345
+[daemon] - Ports: {:server_listen_to_gui=>31000, :gui_listen_to_server=>31001}
+[daemon] - Token: -987654
+[daemon] - Ports: {:server_listen_to_gui=>32000, :gui_listen_to_server=>32001}
+`
+	config := parseDaemonLog(log, APIConfig{Port: DefaultAPIPort})
+	if config.Token != -987654 || config.Port != 32000 {
+		t.Errorf("Expected the last Sonic Pi's token and port, got %+v", config)
+	}
+
+	if config := parseDaemonLog("nothing useful", APIConfig{Port: DefaultAPIPort}); config.Port != DefaultAPIPort || config.Token != 0 {
+		t.Errorf("Expected Sonic Pi 3's defaults, got %+v", config)
+	}
+}
+
+func TestClientRunCode(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer server.Close()
+	port := server.LocalAddr().(*net.UDPAddr).Port
+
+	receive := func() osc.Message {
+		buffer := make([]byte, 65536)
+		server.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := server.Read(buffer)
+		if err != nil {
+			t.Fatalf("Expected a message: %v", err)
+		}
+		messages, err := osc.Parse(buffer[:n])
+		if err != nil || len(messages) != 1 {
+			t.Fatalf("Expected one OSC message, got %v (%v)", messages, err)
+		}
+		return messages[0]
+	}
+
+	// Sonic Pi 4 takes the token first
+	client, err := NewClient(APIConfig{Port: port, Token: 4242})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+	client.RunCode("play 60")
+	message := receive()
+	if message.Address != "/run-code" || len(message.Args) != 2 || message.Args[0] != int32(4242) || message.Args[1] != "play 60" {
+		t.Errorf("Expected /run-code with the token, got %v", message)
+	}
+
+	// Sonic Pi 3 takes a GUI ID
+	client3, _ := NewClient(APIConfig{Port: port})
+	defer client3.Close()
+	client3.RunCode("play 62")
+	if message := receive(); message.Args[0] != guiID || message.Args[1] != "play 62" {
+		t.Errorf("Expected /run-code with a GUI ID, got %v", message)
+	}
+}
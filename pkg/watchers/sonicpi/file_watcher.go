@@ -6,13 +6,25 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/livecodegit/pkg/core"
 	"github.com/livecodegit/pkg/watchers/common"
 )
 
-// FileWatcher monitors Sonic Pi workspace files for changes
+// DefaultDebounce is how long a file has to stay unwritten before its
+// changes are reported, so the writes of a single save make one event
+const DefaultDebounce = 200 * time.Millisecond
+
+// DefaultExcludePatterns skip the backup, swap and lock files editors
+// leave next to the code
+var DefaultExcludePatterns = []string{"*~", ".#*", "#*#", "*.swp", "*.swo", "*.bak", "*.tmp"}
+
+// FileWatcher monitors Sonic Pi workspace files for changes. It is told
+// of writes through inotify on Linux; on macOS, Windows and other systems,
+// or when inotify can't watch the workspace, it polls instead.
 type FileWatcher struct {
 	config        common.WatcherConfig
 	workspacePath string
@@ -21,9 +33,20 @@ type FileWatcher struct {
 	callback      func(common.ExecutionEvent)
 	lastModified  map[string]time.Time
 	stopChan      chan struct{}
+	notifier      *notifier
 
-	// Polling interval for file changes
+	// Polling interval for file changes, when not notified of them
 	pollInterval time.Duration
+
+	// Quiet time before a change is reported, and the reports waiting
+	debounce     time.Duration
+	pending      map[string]*time.Timer
+	pendingMutex sync.Mutex
+
+	// Glob patterns choosing the files watched instead of Sonic Pi's names,
+	// and the ones skipped
+	include []string
+	exclude []string
 }
 
 // NewFileWatcher creates a new file system watcher for Sonic Pi
@@ -36,12 +59,16 @@ func NewFileWatcher(workspacePath string) *FileWatcher {
 			Options: map[string]string{
 				"workspace_path": workspacePath,
 				"poll_interval":  "1s",
+				"debounce":       DefaultDebounce.String(),
 			},
 		},
 		workspacePath: workspacePath,
 		running:       false,
 		lastModified:  make(map[string]time.Time),
 		pollInterval:  1 * time.Second,
+		debounce:      DefaultDebounce,
+		pending:       make(map[string]*time.Timer),
+		exclude:       DefaultExcludePatterns,
 	}
 }
 
@@ -63,11 +90,18 @@ func (w *FileWatcher) Start(callback func(common.ExecutionEvent)) error {
 	w.running = true
 	w.stopChan = make(chan struct{})
 
+	// Watch before the first scan, so no write falls between them
+	notifier, err := openNotifier(w.workspacePath)
+	if err != nil {
+		notifier = nil
+	}
+	w.notifier = notifier
+
 	// Initialize file modification times
 	w.scanWorkspaceFiles()
 
 	// Start monitoring in a goroutine
-	go w.monitorFiles()
+	go w.monitorFiles(w.notifier, w.stopChan, w.pollInterval)
 
 	return nil
 }
@@ -84,6 +118,14 @@ func (w *FileWatcher) Stop() error {
 	w.running = false
 	close(w.stopChan)
 
+	// Changes still settling are dropped with the watcher
+	w.pendingMutex.Lock()
+	for path, timer := range w.pending {
+		timer.Stop()
+		delete(w.pending, path)
+	}
+	w.pendingMutex.Unlock()
+
 	return nil
 }
 
@@ -109,14 +151,27 @@ func (w *FileWatcher) GetEnvironment() string {
 	return "sonic-pi-files"
 }
 
+// Notified reports whether the watcher is told of changes rather than
+// polling for them
+func (w *FileWatcher) Notified() bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.notifier != nil
+}
+
 // monitorFiles continuously monitors workspace files for changes
-func (w *FileWatcher) monitorFiles() {
-	ticker := time.NewTicker(w.pollInterval)
+func (w *FileWatcher) monitorFiles(notifier *notifier, stop chan struct{}, interval time.Duration) {
+	if notifier != nil {
+		notifier.run(stop, w.checkFile, w.checkForChanges)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-w.stopChan:
+		case <-stop:
 			return
 		case <-ticker.C:
 			w.checkForChanges()
@@ -130,6 +185,9 @@ func (w *FileWatcher) scanWorkspaceFiles() {
 		if err != nil {
 			return nil // Continue on errors
 		}
+		if d.IsDir() && path != w.workspacePath && w.excluded(path) {
+			return filepath.SkipDir
+		}
 
 		if w.isSonicPiFile(path) {
 			if info, err := d.Info(); err == nil {
@@ -147,38 +205,105 @@ func (w *FileWatcher) checkForChanges() {
 		if err != nil {
 			return nil // Continue on errors
 		}
+		if d.IsDir() && path != w.workspacePath && w.excluded(path) {
+			return filepath.SkipDir
+		}
 
 		if !w.isSonicPiFile(path) {
 			return nil
 		}
 
-		info, err := d.Info()
-		if err != nil {
-			return nil
+		if info, err := d.Info(); err == nil {
+			w.fileModified(path, info.ModTime())
 		}
+		return nil
+	})
+}
 
-		currentModTime := info.ModTime()
-		lastModTime, exists := w.lastModified[path]
+// checkFile looks at a single file reported as written
+func (w *FileWatcher) checkFile(path string) {
+	if !w.isSonicPiFile(path) {
+		return
+	}
+
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		w.fileModified(path, info.ModTime())
+	}
+}
 
-		// Check if file was modified
-		if !exists || currentModTime.After(lastModTime) {
-			w.lastModified[path] = currentModTime
+// fileModified triggers an event when path was modified since last seen
+func (w *FileWatcher) fileModified(path string, currentModTime time.Time) {
+	lastModTime, exists := w.lastModified[path]
+	modified := !exists || currentModTime.After(lastModTime)
+	if modified {
+		w.lastModified[path] = currentModTime
+	}
 
-			// Only trigger event if file existed before (not for new files on first scan)
-			if exists {
-				event := w.createExecutionEvent(path, currentModTime)
-				if w.callback != nil {
-					w.callback(event)
-				}
-			}
+	// Only trigger event if file existed before (not for new files on first scan)
+	if modified && exists {
+		w.settle(path)
+	}
+}
+
+// settle reports a change to path once it has gone unwritten for the
+// debounce time, so a burst of writes makes a single event with the
+// file's final content
+func (w *FileWatcher) settle(path string) {
+	w.mutex.RLock()
+	debounce := w.debounce
+	w.mutex.RUnlock()
+
+	if debounce <= 0 {
+		w.report(path)
+		return
+	}
+
+	w.pendingMutex.Lock()
+	defer w.pendingMutex.Unlock()
+
+	if timer, exists := w.pending[path]; exists {
+		timer.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(debounce, func() {
+		w.pendingMutex.Lock()
+		current := w.pending[path] == timer
+		if current {
+			delete(w.pending, path)
 		}
+		w.pendingMutex.Unlock()
 
-		return nil
+		if current && w.IsRunning() {
+			w.report(path)
+		}
 	})
+	w.pending[path] = timer
+}
+
+// report triggers an event for the current content of path
+func (w *FileWatcher) report(path string) {
+	modTime := time.Now()
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+
+	event := w.createExecutionEvent(path, modTime)
+	if w.callback != nil {
+		w.callback(event)
+	}
 }
 
-// isSonicPiFile checks if a file is a Sonic Pi workspace file
+// isSonicPiFile checks if a file is a Sonic Pi workspace file, or one
+// matching the include patterns when there are any
 func (w *FileWatcher) isSonicPiFile(path string) bool {
+	if w.excluded(path) {
+		return false
+	}
+	if len(w.include) > 0 {
+		return w.matchAny(w.include, path)
+	}
+
 	// Sonic Pi workspace files are typically named like:
 	// - workspace_0, workspace_1, etc.
 	// - *.rb files
@@ -187,6 +312,7 @@ func (w *FileWatcher) isSonicPiFile(path string) bool {
 	name := filepath.Base(path)
 
 	patterns := []string{
+		`^workspace_\w+\.spi$`,
 		`^workspace_\d+$`,
 		`^buffer_\d+$`,
 		`\.rb$`,
@@ -202,6 +328,47 @@ func (w *FileWatcher) isSonicPiFile(path string) bool {
 	return false
 }
 
+// excluded reports whether path, or a directory it is in, matches an
+// exclude pattern
+func (w *FileWatcher) excluded(path string) bool {
+	if len(w.exclude) == 0 {
+		return false
+	}
+
+	rel, err := filepath.Rel(w.workspacePath, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+	for dir := rel; dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		if w.matchAny(w.exclude, filepath.Join(w.workspacePath, dir)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAny reports whether path matches one of patterns. Patterns with a
+// slash match the path relative to the workspace, others the file name.
+func (w *FileWatcher) matchAny(patterns []string, path string) bool {
+	name := filepath.Base(path)
+	rel, err := filepath.Rel(w.workspacePath, path)
+	if err != nil {
+		rel = name
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, pattern := range patterns {
+		target := name
+		if strings.Contains(pattern, "/") {
+			target = rel
+		}
+		if matched, _ := filepath.Match(pattern, target); matched {
+			return true
+		}
+	}
+	return false
+}
+
 // createExecutionEvent creates an execution event from a file change
 func (w *FileWatcher) createExecutionEvent(filePath string, modTime time.Time) common.ExecutionEvent {
 	content, err := os.ReadFile(filePath)
@@ -221,11 +388,17 @@ func (w *FileWatcher) createExecutionEvent(filePath string, modTime time.Time) c
 	fileName := filepath.Base(filePath)
 	buffer := w.extractBufferName(fileName)
 
+	// Directories of other languages' code are committed in their language
+	language := core.LanguageFromExtension(fileName)
+	if language == "unknown" {
+		language = "sonicpi"
+	}
+
 	return common.ExecutionEvent{
 		Timestamp:    modTime,
 		Content:      contentStr,
 		Buffer:       buffer,
-		Language:     "sonicpi",
+		Language:     language,
 		Environment:  "sonic-pi-files",
 		Success:      success,
 		ErrorMessage: errorMessage,
@@ -239,6 +412,11 @@ func (w *FileWatcher) createExecutionEvent(filePath string, modTime time.Time) c
 
 // extractBufferName extracts a buffer name from a file name
 func (w *FileWatcher) extractBufferName(fileName string) string {
+	// Sonic Pi 3 and 4 save buffers as workspace_zero.spi and so on
+	if filepath.Ext(fileName) == ".spi" {
+		return strings.TrimSuffix(fileName, ".spi")
+	}
+
 	// Extract buffer number from workspace files
 	if matched, _ := regexp.MatchString(`^workspace_(\d+)$`, fileName); matched {
 		return fileName
@@ -266,3 +444,33 @@ func (w *FileWatcher) SetPollInterval(interval time.Duration) {
 	w.pollInterval = interval
 	w.config.Options["poll_interval"] = interval.String()
 }
+
+// SetPatterns chooses the files watched with glob patterns, instead of
+// Sonic Pi's file names, and the files skipped, instead of editors'
+// backups. It is called before Start.
+func (w *FileWatcher) SetPatterns(include, exclude []string) error {
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.include = include
+	w.exclude = exclude
+	w.config.Options["include_patterns"] = strings.Join(include, ",")
+	w.config.Options["exclude_patterns"] = strings.Join(exclude, ",")
+	return nil
+}
+
+// SetDebounce changes how long a file has to stay unwritten before its
+// changes are reported; zero reports every write
+func (w *FileWatcher) SetDebounce(debounce time.Duration) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.debounce = debounce
+	w.config.Options["debounce"] = debounce.String()
+}
@@ -0,0 +1,157 @@
+package sonicpi
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+func TestFileWatcherNotified(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("change notifications are only implemented on Linux")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "workspace_zero.spi")
+	os.WriteFile(path, []byte("play 60"), 0644)
+
+	// Polling once an hour, only a notification can report the write in time
+	watcher := NewFileWatcher(dir)
+	watcher.SetPollInterval(time.Hour)
+
+	events := make(chan common.ExecutionEvent, 4)
+	if err := watcher.Start(func(event common.ExecutionEvent) { events <- event }); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	defer watcher.Stop()
+
+	if !watcher.Notified() {
+		t.Fatal("Expected inotify to watch the workspace")
+	}
+
+	if err := os.WriteFile(path, []byte("play 72"), 0644); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+
+	select {
+	case event := <-events:
+		if event.Content != "play 72" || event.Buffer != "workspace_zero" {
+			t.Errorf("Expected the new code in workspace_zero, got %q in %s", event.Content, event.Buffer)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected an event for the write")
+	}
+}
+
+func TestFileWatcherPolling(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "drums.rb")
+	os.WriteFile(path, []byte("sample :bd_haus"), 0644)
+
+	events := make(chan common.ExecutionEvent, 4)
+	watcher := NewFileWatcher(dir)
+	watcher.running = true
+	watcher.callback = func(event common.ExecutionEvent) { events <- event }
+	watcher.scanWorkspaceFiles()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go watcher.monitorFiles(nil, stop, 10*time.Millisecond)
+
+	later := time.Now().Add(time.Second)
+	os.WriteFile(path, []byte("sample :sn_dub"), 0644)
+	os.Chtimes(path, later, later)
+
+	select {
+	case event := <-events:
+		if event.Content != "sample :sn_dub" || event.Buffer != "drums" {
+			t.Errorf("Expected the new code in drums, got %q in %s", event.Content, event.Buffer)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected polling to find the write")
+	}
+}
+
+func TestFileWatcherDebounce(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "workspace_one.spi")
+	os.WriteFile(path, []byte("play 60"), 0644)
+
+	events := make(chan common.ExecutionEvent, 4)
+	watcher := NewFileWatcher(dir)
+	watcher.SetDebounce(100 * time.Millisecond)
+	watcher.running = true
+	watcher.callback = func(event common.ExecutionEvent) { events <- event }
+	watcher.scanWorkspaceFiles()
+
+	// A save written in several steps, each seen on its own
+	for i, content := range []string{"", "play", "play 72"} {
+		os.WriteFile(path, []byte(content), 0644)
+		modTime := time.Now().Add(time.Duration(i+1) * time.Second)
+		os.Chtimes(path, modTime, modTime)
+		watcher.checkFile(path)
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	select {
+	case event := <-events:
+		if event.Content != "play 72" {
+			t.Errorf("Expected the save's final content, got %q", event.Content)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected an event once the writes settled")
+	}
+
+	select {
+	case event := <-events:
+		t.Errorf("Expected a single event for the save, also got %q", event.Content)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestFileWatcherPatterns(t *testing.T) {
+	watcher := NewFileWatcher("set")
+
+	for path, want := range map[string]bool{
+		"set/workspace_zero.spi": true,
+		"set/drums.rb":           true,
+		"set/.#drums.rb":         false,
+		"set/drums.rb~":          false,
+		"set/main.tidal":         false,
+	} {
+		if got := watcher.isSonicPiFile(path); got != want {
+			t.Errorf("Expected %s watched by default to be %v", path, want)
+		}
+	}
+
+	if err := watcher.SetPatterns([]string{"*.tidal", "*.scd", "visuals/*.js"}, []string{"*.bak", "old"}); err != nil {
+		t.Fatalf("Failed to set patterns: %v", err)
+	}
+	for path, want := range map[string]bool{
+		"set/main.tidal":         true,
+		"set/synths/pad.scd":     true,
+		"set/visuals/hydra.js":   true,
+		"set/node_modules/x.js":  false,
+		"set/drums.rb":           false,
+		"set/main.tidal.bak":     false,
+		"set/old/main.tidal":     false,
+		"set/workspace_zero.spi": false,
+	} {
+		if got := watcher.isSonicPiFile(path); got != want {
+			t.Errorf("Expected %s watched with patterns to be %v", path, want)
+		}
+	}
+
+	event := watcher.createExecutionEvent("set/main.tidal", time.Now())
+	if event.Language != "tidal" || event.Buffer != "main.tidal" {
+		t.Errorf("Expected a tidal event for main.tidal, got %s in %s", event.Language, event.Buffer)
+	}
+
+	if err := watcher.SetPatterns([]string{"[.tidal"}, nil); err == nil {
+		t.Error("Expected an error for a malformed pattern")
+	}
+}
@@ -0,0 +1,121 @@
+//go:build linux
+
+package sonicpi
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// notifyMask asks inotify for files written and closed or moved in, which
+// is when a save is complete, and for new directories to watch
+const notifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE
+
+// notifier reports changes in a directory tree through inotify
+type notifier struct {
+	file *os.File
+	fd   int
+	dirs map[int32]string
+}
+
+// openNotifier watches every directory under root
+func openNotifier(root string) (*notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	// A non-blocking descriptor goes through the runtime's poller, so
+	// closing the file wakes up a pending Read
+	n := &notifier{
+		file: os.NewFile(uintptr(fd), "inotify"),
+		fd:   fd,
+		dirs: make(map[int32]string),
+	}
+	if err := n.addTree(root, nil); err != nil {
+		n.file.Close()
+		return nil, err
+	}
+	return n, nil
+}
+
+// addTree watches dir and the directories below it, passing any file
+// already in them to found, as it may have been written before the watch
+func (n *notifier) addTree(dir string, found func(string)) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			if found != nil {
+				found(path)
+			}
+			return nil
+		}
+
+		wd, err := syscall.InotifyAddWatch(n.fd, path, notifyMask)
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		n.dirs[int32(wd)] = path
+		return nil
+	})
+}
+
+// run passes each file written to changed until stop is closed, and calls
+// rescan when the kernel dropped events
+func (n *notifier) run(stop <-chan struct{}, changed func(string), rescan func()) {
+	go func() {
+		<-stop
+		n.file.Close()
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		count, err := n.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= count; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			offset = nameStart + int(event.Len)
+			if offset > count {
+				break
+			}
+
+			if event.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				rescan()
+				continue
+			}
+			if event.Mask&syscall.IN_IGNORED != 0 {
+				delete(n.dirs, event.Wd)
+				continue
+			}
+
+			dir, ok := n.dirs[event.Wd]
+			if !ok || event.Len == 0 {
+				continue
+			}
+			name := string(bytes.TrimRight(buf[nameStart:offset], "\x00"))
+			path := filepath.Join(dir, name)
+
+			if event.Mask&syscall.IN_ISDIR != 0 {
+				n.addTree(path, changed)
+			} else if event.Mask&(syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO) != 0 {
+				changed(path)
+			}
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package sonicpi
+
+import "errors"
+
+// notifier is only implemented with inotify. macOS (FSEvents or kqueue),
+// Windows (ReadDirectoryChangesW) and the BSDs have no implementation yet,
+// since the standard library offers no portable way to them, so the file
+// watcher polls the workspace every poll interval there.
+type notifier struct{}
+
+// openNotifier reports that change notifications are unavailable
+func openNotifier(root string) (*notifier, error) {
+	return nil, errors.New("file change notifications are not supported on this platform")
+}
+
+func (n *notifier) run(stop <-chan struct{}, changed func(string), rescan func()) {}
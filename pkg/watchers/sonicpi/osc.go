@@ -3,15 +3,23 @@ package sonicpi
 import (
 	"fmt"
 	"net"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/livecodegit/pkg/osc"
 	"github.com/livecodegit/pkg/watchers/common"
 )
 
+// HookMessagePrefix starts the messages sent by the init.rb hook generated by
+// 'lcg integrate sonicpi'. The header line names the buffer, and the author
+// when the hook was generated with one; the rest of the message is the code
+// that was run.
+const HookMessagePrefix = "/lcg/run"
+
 // OSCWatcher monitors Sonic Pi's OSC messages for code execution events
 type OSCWatcher struct {
 	config   common.WatcherConfig
@@ -25,6 +33,10 @@ type OSCWatcher struct {
 	workspacePath string
 	currentBPM    float64
 	startTime     time.Time
+
+	// Last code run, which Sonic Pi's errors are about
+	lastBuffer string
+	lastCode   string
 }
 
 // NewOSCWatcher creates a new Sonic Pi OSC watcher
@@ -120,7 +132,7 @@ func (w *OSCWatcher) GetEnvironment() string {
 
 // listenForMessages continuously listens for OSC messages
 func (w *OSCWatcher) listenForMessages() {
-	buffer := make([]byte, 4096)
+	buffer := make([]byte, 65536)
 
 	for w.IsRunning() {
 		w.conn.SetReadDeadline(time.Now().Add(1 * time.Second))
@@ -136,18 +148,176 @@ func (w *OSCWatcher) listenForMessages() {
 			continue
 		}
 
-		message := string(buffer[:n])
-		w.processOSCMessage(message)
+		w.processPacket(buffer[:n])
+	}
+}
+
+// processPacket handles one datagram: the init.rb hook's text messages, or
+// binary OSC packets such as the GUI's messages to the Sonic Pi server
+func (w *OSCWatcher) processPacket(packet []byte) {
+	if strings.HasPrefix(string(packet), HookMessagePrefix+" ") {
+		w.processHookMessage(string(packet))
+		return
+	}
+
+	if len(packet) > 0 && (packet[0] == '/' || packet[0] == '#') {
+		if messages, err := osc.Parse(packet); err == nil {
+			for _, message := range messages {
+				w.processBinaryMessage(message)
+			}
+			return
+		}
+	}
+
+	w.processOSCMessage(string(packet))
+}
+
+// processBinaryMessage handles a decoded OSC message. The GUI runs code with
+// /run-code (token, code) or /save-and-run-buffer (token, buffer, code,
+// workspace); the server reports /error and /syntax_error (job, message,
+// backtrace, line) about the code last run.
+func (w *OSCWatcher) processBinaryMessage(message osc.Message) {
+	args := message.Args
+	switch message.Address {
+	case HookMessagePrefix:
+		// The hook's fields as OSC arguments: buffer, code and maybe author
+		if len(args) >= 2 {
+			author := ""
+			if len(args) >= 3 {
+				author = oscString(args[2])
+			}
+			w.run(oscString(args[0]), oscString(args[1]), author, message.Address)
+		}
+	case "/run-code":
+		if len(args) >= 1 {
+			w.run("workspace-0", oscString(args[len(args)-1]), "", message.Address)
+		}
+	case "/save-and-run-buffer":
+		if len(args) >= 3 {
+			w.run(oscString(args[len(args)-3]), oscString(args[len(args)-2]), "", message.Address)
+		}
+	case "/error", "/syntax_error":
+		errorMessage := "Unknown error"
+		if len(args) >= 2 {
+			errorMessage = oscString(args[1])
+		}
+		if len(args) >= 4 {
+			if line, ok := args[3].(int32); ok && line > 0 {
+				errorMessage = fmt.Sprintf("line %d: %s", line, errorMessage)
+			}
+		}
+		w.fail(errorMessage, message.Address)
+	default:
+		if strings.Contains(message.Address, "bpm") && len(args) > 0 {
+			if bpm, ok := oscNumber(args[0]); ok && bpm > 0 {
+				w.currentBPM = bpm
+			}
+		}
 	}
 }
 
-// processOSCMessage parses and handles incoming OSC messages
+// run passes on code run in a buffer
+func (w *OSCWatcher) run(buffer, code, author, address string) {
+	if code == "" {
+		return
+	}
+	if buffer == "" {
+		buffer = "workspace-0"
+	}
+	if w.isBPMMessage(code) {
+		w.updateBPM(code)
+	}
+	w.lastBuffer, w.lastCode = buffer, code
+
+	now := time.Now()
+	event := common.ExecutionEvent{
+		Timestamp:      now,
+		Content:        code,
+		Buffer:         buffer,
+		Language:       "sonicpi",
+		Environment:    "sonic-pi",
+		Success:        true,
+		BPM:            w.currentBPM,
+		BeatsFromStart: w.calculateBeatsFromStart(now),
+		Author:         author,
+		ExtraData: map[string]string{
+			"trigger_type": "osc",
+			"osc_address":  address,
+		},
+	}
+	if w.callback != nil {
+		w.callback(event)
+	}
+}
+
+// fail reports an error about the code last run
+func (w *OSCWatcher) fail(errorMessage, address string) {
+	if w.lastCode == "" {
+		return
+	}
+
+	now := time.Now()
+	event := common.ExecutionEvent{
+		Timestamp:      now,
+		Content:        w.lastCode,
+		Buffer:         w.lastBuffer,
+		Language:       "sonicpi",
+		Environment:    "sonic-pi",
+		Success:        false,
+		ErrorMessage:   errorMessage,
+		BPM:            w.currentBPM,
+		BeatsFromStart: w.calculateBeatsFromStart(now),
+		ExtraData: map[string]string{
+			"trigger_type": "osc",
+			"osc_address":  address,
+		},
+	}
+	if w.callback != nil {
+		w.callback(event)
+	}
+}
+
+// oscString renders an argument as text; blobs are taken as UTF-8
+func oscString(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// oscNumber reads a numeric argument
+func oscNumber(arg interface{}) (float64, bool) {
+	switch v := arg.(type) {
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// processOSCMessage handles messages sent as plain text
 func (w *OSCWatcher) processOSCMessage(message string) {
 	// Sonic Pi OSC messages for execution events typically look like:
 	// "/run-code" followed by parameters
 	// "/error" for errors
 	// "/info" for info messages
 
+	if strings.HasPrefix(message, HookMessagePrefix) {
+		w.processHookMessage(message)
+		return
+	}
+
 	lines := strings.Split(message, "\n")
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
@@ -166,6 +336,47 @@ func (w *OSCWatcher) processOSCMessage(message string) {
 	}
 }
 
+// processHookMessage handles a run reported by the init.rb hook, which carries
+// the full buffer content
+func (w *OSCWatcher) processHookMessage(message string) {
+	header, content, _ := strings.Cut(message, "\n")
+
+	buffer := "workspace-0"
+	bufferRegex := regexp.MustCompile(`buffer[:\s]+(\w+)`)
+	if matches := bufferRegex.FindStringSubmatch(header); len(matches) > 1 {
+		buffer = matches[1]
+	}
+
+	author := ""
+	if _, after, found := strings.Cut(header, " author: "); found {
+		author = strings.TrimSpace(after)
+	}
+
+	if w.isBPMMessage(content) {
+		w.updateBPM(content)
+	}
+
+	now := time.Now()
+	event := common.ExecutionEvent{
+		Timestamp:      now,
+		Content:        content,
+		Buffer:         buffer,
+		Language:       "sonicpi",
+		Environment:    "sonic-pi",
+		Success:        true,
+		BPM:            w.currentBPM,
+		BeatsFromStart: w.calculateBeatsFromStart(now),
+		Author:         author,
+		ExtraData: map[string]string{
+			"trigger_type": "init_hook",
+		},
+	}
+
+	if w.callback != nil {
+		w.callback(event)
+	}
+}
+
 // isExecutionMessage checks if the message indicates code execution
 func (w *OSCWatcher) isExecutionMessage(message string) bool {
 	executionPatterns := []string{
@@ -262,16 +473,21 @@ func (w *OSCWatcher) calculateBeatsFromStart(timestamp time.Time) int64 {
 	return int64(totalBeats)
 }
 
-// readBufferContent attempts to read the current content of a Sonic Pi buffer
+// readBufferContent reads the code of a Sonic Pi buffer from the workspace
+// file Sonic Pi saves it to when it runs, falling back to a note naming the
+// buffer when there is none
 func (w *OSCWatcher) readBufferContent(bufferName string) string {
-	// In a real implementation, this would read from Sonic Pi's workspace files
-	// For now, return a placeholder that indicates we detected execution
-	if w.workspacePath == "" {
-		return fmt.Sprintf("# Code executed in buffer: %s\n# (content not available without workspace path)", bufferName)
+	workspacePath := w.workspacePath
+	if workspacePath == "" {
+		workspacePath = DefaultWorkspacePath()
 	}
 
-	// For now, return a simple placeholder
-	// TODO: Implement actual file reading when workspace path is available
-	// Sonic Pi typically saves workspace content in files named like "workspace_0", etc.
-	return fmt.Sprintf("# Executed at %s\n# Buffer: %s", time.Now().Format("15:04:05"), bufferName)
+	path, err := WorkspaceFile(workspacePath, bufferName)
+	if err == nil {
+		var content []byte
+		if content, err = os.ReadFile(path); err == nil {
+			return string(content)
+		}
+	}
+	return fmt.Sprintf("# Code executed in buffer: %s\n# (content not available: %v)", bufferName, err)
 }
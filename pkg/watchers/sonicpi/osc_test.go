@@ -0,0 +1,94 @@
+package sonicpi
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/livecodegit/pkg/osc"
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+// recordingWatcher returns a watcher collecting the events of the packets
+// it is given
+func recordingWatcher() (*OSCWatcher, *[]common.ExecutionEvent) {
+	var events []common.ExecutionEvent
+	watcher := NewOSCWatcher(4559, "")
+	watcher.callback = func(event common.ExecutionEvent) { events = append(events, event) }
+	return watcher, &events
+}
+
+// packet encodes a message
+func packet(t *testing.T, address string, args ...interface{}) []byte {
+	data, err := osc.Message{Address: address, Args: args}.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed to encode %s: %v", address, err)
+	}
+	return data
+}
+
+func TestBinaryMessages(t *testing.T) {
+	watcher, events := recordingWatcher()
+
+	code := "use_bpm 90\nlive_loop :drums do\n  sample :bd_haus\n  sleep 1\nend"
+	watcher.processPacket(packet(t, "/save-and-run-buffer", int32(12345), "workspace_two", code, "workspace_two"))
+	watcher.processPacket(packet(t, "/run-code", "gui-uuid", "play 60"))
+	watcher.processPacket(packet(t, "/error", int32(3), "undefined method `sampl'", "backtrace", int32(3)))
+	watcher.processPacket(packet(t, "/lcg/run", "workspace_three", "play 72", "Sam Aaron"))
+
+	if len(*events) != 4 {
+		t.Fatalf("Expected 4 executions, got %+v", *events)
+	}
+
+	run := (*events)[0]
+	if run.Buffer != "workspace_two" || run.Content != code || !run.Success {
+		t.Errorf("Expected the buffer run with its code, got %+v", run)
+	}
+	if run.BPM != 90 {
+		t.Errorf("Expected use_bpm to set the tempo, got %v", run.BPM)
+	}
+
+	if (*events)[1].Content != "play 60" || (*events)[1].Buffer != "workspace-0" {
+		t.Errorf("Expected /run-code's code, got %+v", (*events)[1])
+	}
+
+	failed := (*events)[2]
+	if failed.Success || failed.Content != "play 60" || failed.ErrorMessage != "line 3: undefined method `sampl'" {
+		t.Errorf("Expected the error about the last run, got %+v", failed)
+	}
+
+	if hook := (*events)[3]; hook.Buffer != "workspace_three" || hook.Author != "Sam Aaron" {
+		t.Errorf("Expected the hook's fields as arguments, got %+v", hook)
+	}
+}
+
+func TestBundlesAndText(t *testing.T) {
+	watcher, events := recordingWatcher()
+
+	// A bundle holding a run
+	message := packet(t, "/run-code", int32(1), "sample :ambi_choir")
+	bundle := append([]byte("#bundle\x00"), make([]byte, 8)...)
+	bundle = binary.BigEndian.AppendUint32(bundle, uint32(len(message)))
+	bundle = append(bundle, message...)
+	watcher.processPacket(bundle)
+
+	// The init.rb hook's text message
+	watcher.processPacket([]byte(HookMessagePrefix + " buffer: workspace_one\nplay 50"))
+
+	if len(*events) != 2 {
+		t.Fatalf("Expected 2 executions, got %+v", *events)
+	}
+	if (*events)[0].Content != "sample :ambi_choir" {
+		t.Errorf("Expected the bundled run, got %+v", (*events)[0])
+	}
+	if (*events)[1].Content != "play 50" || (*events)[1].Buffer != "workspace_one" {
+		t.Errorf("Expected the hook's run, got %+v", (*events)[1])
+	}
+
+	// Errors before any run, and messages without code, commit nothing
+	watcher, events = recordingWatcher()
+	watcher.processPacket(packet(t, "/syntax_error", int32(1), "unexpected end"))
+	watcher.processPacket(packet(t, "/run-code", int32(1), ""))
+	if len(*events) != 0 {
+		t.Errorf("Expected no executions, got %+v", *events)
+	}
+}
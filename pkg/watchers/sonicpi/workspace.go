@@ -0,0 +1,65 @@
+package sonicpi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// workspaceWords name Sonic Pi's ten buffers in its workspace files
+var workspaceWords = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine"}
+
+// DefaultWorkspacePath returns where Sonic Pi saves its buffers:
+// store/default in its home directory, for Sonic Pi 3 and 4 alike
+func DefaultWorkspacePath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".sonic-pi", "store", "default")
+}
+
+// workspaceIndex reads the buffer number out of the names buffers go by:
+// workspace_zero in Sonic Pi 3 and 4, workspace_0 or workspace-0 in hooks
+// and older versions, or just the number
+func workspaceIndex(buffer string) (int, bool) {
+	name := strings.TrimSuffix(strings.ToLower(buffer), ".spi")
+	name = strings.TrimPrefix(strings.TrimPrefix(name, "workspace"), "_")
+	name = strings.TrimPrefix(name, "-")
+
+	for i, word := range workspaceWords {
+		if name == word {
+			return i, true
+		}
+	}
+	if i, err := strconv.Atoi(name); err == nil && i >= 0 && i < len(workspaceWords) {
+		return i, true
+	}
+	return 0, false
+}
+
+// WorkspaceFile returns the file in dir Sonic Pi saves the named buffer to.
+// Sonic Pi 3 and 4 write workspace_zero.spi; older versions wrote
+// workspace_0, with or without the extension.
+func WorkspaceFile(dir, buffer string) (string, error) {
+	index, ok := workspaceIndex(buffer)
+	if !ok {
+		return "", fmt.Errorf("%s is not a Sonic Pi buffer", buffer)
+	}
+
+	number := strconv.Itoa(index)
+	for _, name := range []string{
+		"workspace_" + workspaceWords[index] + ".spi",
+		"workspace_" + number + ".spi",
+		"workspace_" + workspaceWords[index],
+		"workspace_" + number,
+	} {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no workspace file for %s in %s", buffer, dir)
+}
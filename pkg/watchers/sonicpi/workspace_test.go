@@ -0,0 +1,47 @@
+package sonicpi
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWorkspaceFile(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "workspace_three.spi"), []byte("play 60"), 0644)
+	os.WriteFile(filepath.Join(dir, "workspace_5"), []byte("play 72"), 0644)
+
+	for buffer, want := range map[string]string{
+		"workspace_three": "workspace_three.spi",
+		"workspace_3":     "workspace_three.spi",
+		"workspace-3":     "workspace_three.spi",
+		"3":               "workspace_three.spi",
+		"workspace_five":  "workspace_5",
+	} {
+		path, err := WorkspaceFile(dir, buffer)
+		if err != nil || filepath.Base(path) != want {
+			t.Errorf("Expected %s for %s, got %s (%v)", want, buffer, path, err)
+		}
+	}
+
+	if _, err := WorkspaceFile(dir, "workspace_zero"); err == nil {
+		t.Error("Expected an error for a buffer without a file")
+	}
+	if _, err := WorkspaceFile(dir, "drums"); err == nil {
+		t.Error("Expected an error for a name that isn't a buffer")
+	}
+}
+
+func TestReadBufferContent(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "workspace_zero.spi"), []byte("live_loop :drums do\n  sample :bd_haus\nend\n"), 0644)
+
+	watcher := NewOSCWatcher(0, dir)
+	if content := watcher.readBufferContent("workspace-0"); !strings.Contains(content, "sample :bd_haus") {
+		t.Errorf("Expected the workspace's code, got %q", content)
+	}
+	if content := watcher.readBufferContent("workspace_1"); !strings.Contains(content, "not available") {
+		t.Errorf("Expected a note for a missing workspace, got %q", content)
+	}
+}
@@ -0,0 +1,120 @@
+package watchers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/storage"
+)
+
+// StateFile is the name of the file a running watcher service reports to
+const StateFile = "watcher.json"
+
+// PIDFile holds the process ID of a watcher service running detached, started
+// with 'lcg watch --detach'
+const PIDFile = "watch.pid"
+
+// ServiceState is a snapshot of a running watcher service, persisted so other
+// lcg processes can report on it
+type ServiceState struct {
+	PID       int          `json:"pid"`
+	StartedAt time.Time    `json:"started_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	Watchers  []string     `json:"watchers"`
+	Stats     ServiceStats `json:"stats"`
+
+	// ControlPort is the UDP port of the OSC control surface, if enabled
+	ControlPort int `json:"control_port,omitempty"`
+}
+
+// GetStatePath returns the watcher state file path for a repository
+func GetStatePath(repoPath string) string {
+	return filepath.Join(repoPath, storage.RepoDir, StateFile)
+}
+
+// WriteServiceState saves a service state snapshot to disk
+func WriteServiceState(path string, state ServiceState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal service state: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write service state: %w", err)
+	}
+
+	return nil
+}
+
+// ReadServiceState loads a service state snapshot, returning nil if none exists
+func ReadServiceState(path string) (*ServiceState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read service state: %w", err)
+	}
+
+	var state ServiceState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse service state: %w", err)
+	}
+
+	return &state, nil
+}
+
+// RemoveServiceState deletes the service state file if present
+func RemoveServiceState(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove service state: %w", err)
+	}
+	return nil
+}
+
+// GetPIDPath returns the detached watcher pidfile path for a repository
+func GetPIDPath(repoPath string) string {
+	return filepath.Join(repoPath, storage.RepoDir, PIDFile)
+}
+
+// WritePIDFile records the process ID of a detached watcher service
+func WritePIDFile(path string, pid int) error {
+	if err := os.WriteFile(path, []byte(strconv.Itoa(pid)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write pidfile: %w", err)
+	}
+	return nil
+}
+
+// ReadPIDFile returns the process ID in a pidfile, or 0 if none exists
+func ReadPIDFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read pidfile: %w", err)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid pidfile %s", path)
+	}
+	return pid, nil
+}
+
+// RemovePIDFile deletes a pidfile if it still holds pid, so a process never
+// removes the pidfile of another one started since
+func RemovePIDFile(path string, pid int) error {
+	if current, err := ReadPIDFile(path); err != nil || current != pid {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove pidfile: %w", err)
+	}
+	return nil
+}
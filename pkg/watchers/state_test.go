@@ -0,0 +1,44 @@
+package watchers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPIDFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, PIDFile)
+
+	if pid, err := ReadPIDFile(path); err != nil || pid != 0 {
+		t.Errorf("Expected no pid without a pidfile, got %d (%v)", pid, err)
+	}
+
+	if err := WritePIDFile(path, 4242); err != nil {
+		t.Fatalf("Failed to write pidfile: %v", err)
+	}
+	if pid, err := ReadPIDFile(path); err != nil || pid != 4242 {
+		t.Errorf("Expected pid 4242, got %d (%v)", pid, err)
+	}
+
+	// Only the process the pidfile names removes it
+	if err := RemovePIDFile(path, 1234); err != nil {
+		t.Errorf("Failed to skip another process's pidfile: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected another process's pidfile to be kept: %v", err)
+	}
+	if err := RemovePIDFile(path, 4242); err != nil {
+		t.Errorf("Failed to remove pidfile: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the pidfile to be removed")
+	}
+
+	if err := os.WriteFile(path, []byte("watching\n"), 0644); err != nil {
+		t.Fatalf("Failed to write pidfile: %v", err)
+	}
+	if _, err := ReadPIDFile(path); err == nil {
+		t.Errorf("Expected an invalid pidfile to be an error")
+	}
+}
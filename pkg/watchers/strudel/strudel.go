@@ -0,0 +1,116 @@
+// Package strudel versions Strudel sessions: a snippet loaded into the
+// Strudel REPL sends each evaluation, with the scheduler's tempo and cycle,
+// to a browser watcher.
+package strudel
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/livecodegit/pkg/watchers/browser"
+	"github.com/livecodegit/pkg/watchers/common"
+	"github.com/livecodegit/pkg/watchers/external"
+)
+
+// DefaultPort is the TCP port the watcher serves the snippet and WebSocket on
+const DefaultPort = 6063
+
+// SnippetPath is where the watcher serves the browser snippet
+const SnippetPath = "/strudel.js"
+
+// Origins are the sites serving the Strudel REPL, allowed to send
+// evaluations without configuration
+var Origins = []string{"https://strudel.cc", "https://strudel.tidalcycles.org"}
+
+// BeatsPerCycle converts cycles to beats, as the Tidal watchers do
+const BeatsPerCycle = 4
+
+// snippet sends the whole document on Ctrl+Enter or Alt+Enter, the REPL's
+// evaluation keys, once the evaluation had time to set the tempo. The
+// scheduler is read from the REPL's globals when they are there.
+const snippet = `// livecodegit: sends each Strudel evaluation to 'lcg watch'
+(() => {
+  if (window.lcgStrudel) return;
+  window.lcgStrudel = true;
+  const url = "ws://127.0.0.1:%d/";
+  let socket = null;
+  const queue = [];
+  const connect = () => {
+    socket = new WebSocket(url);
+    socket.onopen = () => { console.log("lcg: connected to " + url); queue.splice(0).forEach((m) => socket.send(m)); };
+    socket.onclose = () => { socket = null; setTimeout(connect, 2000); };
+  };
+  connect();
+
+  const scheduler = () => {
+    const mirror = window.strudelMirror;
+    return mirror && mirror.repl && mirror.repl.scheduler;
+  };
+  const send = (content) => {
+    if (!content || !content.trim()) return;
+    const message = { content: content, buffer: "strudel" };
+    const s = scheduler();
+    if (s) {
+      if (typeof s.cps === "number") message.cps = s.cps;
+      if (typeof s.now === "function") message.cycle = s.now();
+    }
+    const data = JSON.stringify(message);
+    if (socket && socket.readyState === WebSocket.OPEN) socket.send(data); else queue.push(data);
+  };
+  window.lcg = send;
+
+  const documentText = () => {
+    const content = document.querySelector(".cm-content");
+    if (content && content.cmView) return content.cmView.view.state.doc.toString();
+    const mirror = window.strudelMirror;
+    return mirror && mirror.code;
+  };
+
+  document.addEventListener("keydown", (event) => {
+    if (event.key !== "Enter" || !(event.ctrlKey || event.altKey || event.metaKey)) return;
+    setTimeout(() => send(documentText()), 100);
+  }, true);
+})();
+`
+
+// Snippet returns the browser snippet connecting to the given port
+func Snippet(port int) string {
+	return fmt.Sprintf(snippet, port)
+}
+
+// ParseMessage reads an evaluation from the snippet, mapping the
+// scheduler's cycles per second and cycle to BPM and beats
+func ParseMessage(message []byte, config common.WatcherConfig) (common.ExecutionEvent, error) {
+	event, err := external.ParseEvent(message, config)
+	if err != nil {
+		return event, err
+	}
+
+	var tempo struct {
+		CPS   float64 `json:"cps"`
+		Cycle float64 `json:"cycle"`
+	}
+	if err := json.Unmarshal(message, &tempo); err != nil {
+		return event, err
+	}
+	if tempo.CPS > 0 {
+		event.BPM = tempo.CPS * 60 * BeatsPerCycle
+		event.BeatsFromStart = int64(tempo.Cycle * BeatsPerCycle)
+	}
+	return event, nil
+}
+
+// NewWatcher creates a Strudel watcher serving on the given port, accepting
+// evaluations from the given origins besides pages on this machine
+func NewWatcher(port int, origins []string) *browser.Watcher {
+	return browser.NewWatcher(browser.Editor{
+		Name:        "Strudel",
+		Language:    "strudel",
+		Environment: "strudel",
+		PortOption:  "ws_port",
+		SnippetPath: SnippetPath,
+		Snippet:     Snippet,
+		Parse:       ParseMessage,
+		Origins:     Origins,
+	}, port, origins)
+}
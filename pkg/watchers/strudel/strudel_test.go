@@ -0,0 +1,75 @@
+package strudel
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/livecodegit/pkg/watchers/browser"
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+func TestParseMessage(t *testing.T) {
+	config := common.WatcherConfig{Language: "strudel", Environment: "strudel"}
+
+	event, err := ParseMessage([]byte(`{"content":"s(\"bd sd\")","buffer":"strudel","cps":0.5625,"cycle":12.6}`), config)
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	if event.Language != "strudel" || event.Buffer != "strudel" {
+		t.Errorf("Expected a Strudel execution, got %+v", event)
+	}
+	if event.BPM != 135 || event.BeatsFromStart != 50 {
+		t.Errorf("Expected 135 BPM at beat 50, got %v at %d", event.BPM, event.BeatsFromStart)
+	}
+
+	// Without the scheduler, no tempo is guessed
+	event, err = ParseMessage([]byte(`{"content":"s(\"hh*8\")"}`), config)
+	if err != nil || event.BPM != 0 || event.BeatsFromStart != 0 {
+		t.Errorf("Expected no tempo, got %+v (%v)", event, err)
+	}
+
+	if _, err := ParseMessage([]byte(`{"cps":1}`), config); err == nil {
+		t.Errorf("Expected a message without content to be refused")
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	if !strings.Contains(Snippet(7000), "ws://127.0.0.1:7000/") {
+		t.Errorf("Expected the snippet to connect to port 7000")
+	}
+}
+
+func TestWatcherAllowsTheREPL(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	watcher := NewWatcher(port, nil)
+	if err := watcher.Start(func(common.ExecutionEvent) {}); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	url := fmt.Sprintf("http://127.0.0.1:%d%s", port, browser.EvalPath)
+	for origin, status := range map[string]int{
+		"https://strudel.cc":   http.StatusNoContent,
+		"https://evil.example": http.StatusForbidden,
+	} {
+		request, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(`{"content":"s(\"bd\")"}`))
+		request.Header.Set("Origin", origin)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Failed to post: %v", err)
+		}
+		response.Body.Close()
+		if response.StatusCode != status {
+			t.Errorf("Expected %d for a page from %s, got %d", status, origin, response.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Package superdirt listens to the OSC messages Tidal sends SuperDirt, so
+// commits from the other watchers carry the cycle Tidal is at and what it
+// was playing: which orbits, which sounds and how many events per cycle.
+package superdirt
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/livecodegit/pkg/osc"
+	"github.com/livecodegit/pkg/storage"
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+// BeatsPerCycle is how commits count Tidal's cycles in beats, as the
+// GHCi watcher does
+const BeatsPerCycle = 4
+
+const (
+	// windowCycles is how many cycles before an execution describe what
+	// was playing
+	windowCycles = 4
+
+	// retention is how long events are kept, enough for windowCycles at
+	// slow tempos
+	retention = 30 * time.Second
+
+	// staleAfter is how long after the last event the cycle position is
+	// no longer trusted; a pattern may rest, but not for this long
+	staleAfter = 8 * time.Second
+
+	// maxSounds bounds the sound names kept per commit
+	maxSounds = 16
+)
+
+// event is one sound Tidal asked SuperDirt to play
+type event struct {
+	at    time.Time // when it plays
+	cycle float64
+	cps   float64
+	orbit int
+	sound string
+}
+
+// Activity follows the events Tidal sends SuperDirt
+type Activity struct {
+	mutex  sync.Mutex
+	events []event // by arrival, which is nearly by play time
+}
+
+// NewActivity creates an activity that hasn't seen any event
+func NewActivity() *Activity {
+	return &Activity{}
+}
+
+// Feed records a message to play at the given time. It reads /dirt/play,
+// sent by Tidal 1.0 and later, and /play2 from older Tidal; both carry
+// their parameters as name and value pairs.
+func (a *Activity) Feed(message osc.Message, at time.Time) bool {
+	if message.Address != "/dirt/play" && message.Address != "/play2" {
+		return false
+	}
+
+	e := event{at: at, cycle: math.NaN()}
+	for i := 0; i+1 < len(message.Args); i += 2 {
+		name, ok := message.Args[i].(string)
+		if !ok {
+			continue
+		}
+		value := message.Args[i+1]
+		switch name {
+		case "cycle":
+			if number, ok := number(value); ok {
+				e.cycle = number
+			}
+		case "cps":
+			if number, ok := number(value); ok {
+				e.cps = number
+			}
+		case "orbit":
+			if number, ok := number(value); ok {
+				e.orbit = int(number)
+			}
+		case "s":
+			if sound, ok := value.(string); ok {
+				e.sound = sound
+			}
+		}
+	}
+	if math.IsNaN(e.cycle) || e.cps <= 0 {
+		return false
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.events = append(a.events, e)
+	expired := 0
+	for expired < len(a.events) && at.Sub(a.events[expired].at) > retention {
+		expired++
+	}
+	a.events = a.events[expired:]
+	return true
+}
+
+// Tempo returns Tidal's tempo and position at the given time, from the
+// latest event that played by then
+func (a *Activity) Tempo(at time.Time) (common.Tempo, bool) {
+	cycle, cps, ok := a.position(at)
+	if !ok {
+		return common.Tempo{}, false
+	}
+	return common.Tempo{
+		BPM:   cps * 60 * BeatsPerCycle,
+		Beats: int64(math.Floor(cycle * BeatsPerCycle)),
+		Phase: (cycle - math.Floor(cycle)) * BeatsPerCycle,
+	}, true
+}
+
+// Sound returns what played over the cycles before the given time
+func (a *Activity) Sound(at time.Time) (*storage.SoundActivity, bool) {
+	cycle, cps, ok := a.position(at)
+	if !ok {
+		return nil, false
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	since := at.Add(-time.Duration(windowCycles / cps * float64(time.Second)))
+	orbits := map[int]bool{}
+	sounds := map[string]bool{}
+	count := 0
+	for _, e := range a.events {
+		if e.at.Before(since) || e.at.After(at) {
+			continue
+		}
+		count++
+		orbits[e.orbit] = true
+		if e.sound != "" {
+			sounds[e.sound] = true
+		}
+	}
+	if count == 0 {
+		return nil, false
+	}
+
+	activity := &storage.SoundActivity{
+		Cycle:        cycle,
+		EventDensity: float64(count) / windowCycles,
+	}
+	for orbit := range orbits {
+		activity.Orbits = append(activity.Orbits, orbit)
+	}
+	sort.Ints(activity.Orbits)
+	for sound := range sounds {
+		activity.Sounds = append(activity.Sounds, sound)
+	}
+	sort.Strings(activity.Sounds)
+	if len(activity.Sounds) > maxSounds {
+		activity.Sounds = activity.Sounds[:maxSounds]
+	}
+	return activity, true
+}
+
+// position extrapolates the cycle at the given time from the latest event
+// played by then
+func (a *Activity) position(at time.Time) (float64, float64, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for i := len(a.events) - 1; i >= 0; i-- {
+		e := a.events[i]
+		if e.at.After(at) {
+			continue
+		}
+		if at.Sub(e.at) > staleAfter {
+			return 0, 0, false
+		}
+		return e.cycle + at.Sub(e.at).Seconds()*e.cps, e.cps, true
+	}
+	return 0, 0, false
+}
+
+// number reads a numeric OSC argument
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,177 @@
+package superdirt
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/livecodegit/pkg/osc"
+	"github.com/livecodegit/pkg/storage"
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+// DefaultPort is where the watcher listens for Tidal's messages, next to
+// SuperDirt's 57120
+const DefaultPort = 57130
+
+// SuperDirtAddress is where SuperDirt listens unless configured otherwise
+const SuperDirtAddress = "127.0.0.1:57120"
+
+// Watcher listens to the messages Tidal sends SuperDirt, either as a
+// second target of Tidal's or in front of SuperDirt, forwarding them. It
+// commits nothing itself: the service asks it for the tempo and the sound
+// of every other execution.
+type Watcher struct {
+	config   common.WatcherConfig
+	port     int
+	forward  string
+	activity *Activity
+	conn     *net.UDPConn
+	target   *net.UDPConn
+	running  bool
+	mutex    sync.RWMutex
+}
+
+// NewWatcher creates a watcher listening on port and forwarding every
+// packet to the forward address, unless it is empty
+func NewWatcher(port int, forward string) *Watcher {
+	return &Watcher{
+		config: common.WatcherConfig{
+			Language:    "tidal",
+			Environment: "superdirt",
+			Enabled:     true,
+			Options: map[string]string{
+				"osc_port": strconv.Itoa(port),
+				"forward":  forward,
+			},
+		},
+		port:     port,
+		forward:  forward,
+		activity: NewActivity(),
+	}
+}
+
+// Start listens for Tidal's messages
+func (w *Watcher) Start(callback func(common.ExecutionEvent)) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.running {
+		return fmt.Errorf("SuperDirt watcher is already running")
+	}
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: w.port})
+	if err != nil {
+		return fmt.Errorf("failed to listen on UDP port %d: %w", w.port, err)
+	}
+
+	var target *net.UDPConn
+	if w.forward != "" {
+		addr, err := net.ResolveUDPAddr("udp", w.forward)
+		if err == nil {
+			target, err = net.DialUDP("udp", nil, addr)
+		}
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("failed to forward to SuperDirt at %s: %w", w.forward, err)
+		}
+	}
+
+	w.conn = conn
+	w.target = target
+	w.activity = NewActivity()
+	w.running = true
+
+	go w.listen(conn, target)
+	return nil
+}
+
+// listen reads packets until the connection is closed
+func (w *Watcher) listen(conn, target *net.UDPConn) {
+	buffer := make([]byte, 65536)
+	for {
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return
+		}
+		packet := buffer[:n]
+		if target != nil {
+			target.Write(packet)
+		}
+		w.process(packet, time.Now())
+	}
+}
+
+// process records the messages of a packet received at the given time,
+// to play at the bundle's time when it has one
+func (w *Watcher) process(packet []byte, received time.Time) {
+	messages, err := osc.Parse(packet)
+	if err != nil {
+		return
+	}
+	at := received
+	if when, ok := osc.BundleTime(packet); ok {
+		at = when
+	}
+	for _, message := range messages {
+		w.activity.Feed(message, at)
+	}
+}
+
+// Stop closes the port and the connection to SuperDirt
+func (w *Watcher) Stop() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.running {
+		return nil
+	}
+
+	w.running = false
+	if w.target != nil {
+		w.target.Close()
+		w.target = nil
+	}
+	return w.conn.Close()
+}
+
+// IsRunning returns true if the watcher is active
+func (w *Watcher) IsRunning() bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.running
+}
+
+// GetConfig returns the watcher configuration
+func (w *Watcher) GetConfig() common.WatcherConfig {
+	return w.config
+}
+
+// GetLanguage returns "tidal"
+func (w *Watcher) GetLanguage() string {
+	return "tidal"
+}
+
+// GetEnvironment returns "superdirt"
+func (w *Watcher) GetEnvironment() string {
+	return "superdirt"
+}
+
+// Tempo returns Tidal's tempo and cycle position at the given time
+func (w *Watcher) Tempo(at time.Time) (common.Tempo, bool) {
+	return w.currentActivity().Tempo(at)
+}
+
+// Sound returns what Tidal played over the cycles before the given time
+func (w *Watcher) Sound(at time.Time) (*storage.SoundActivity, bool) {
+	return w.currentActivity().Sound(at)
+}
+
+// currentActivity returns the activity of the current run
+func (w *Watcher) currentActivity() *Activity {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.activity
+}
@@ -0,0 +1,102 @@
+package superdirt
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/osc"
+)
+
+func play(cycle float64, orbit int32, sound string) osc.Message {
+	return osc.Message{Address: "/dirt/play", Args: []interface{}{
+		"_id_", "1", "cps", float32(0.5), "cycle", float32(cycle), "delta", float32(0.5),
+		"orbit", orbit, "s", sound,
+	}}
+}
+
+func TestActivity(t *testing.T) {
+	activity := NewActivity()
+	start := time.Now()
+
+	// Two cycles of bd and hh on orbit 0 and a pad on orbit 2, at 0.5 cps
+	for i := 0; i < 4; i++ {
+		at := start.Add(time.Duration(i) * time.Second)
+		activity.Feed(play(float64(i)/2, 0, "bd"), at)
+		activity.Feed(play(float64(i)/2, 0, "hh"), at)
+	}
+	activity.Feed(play(1, 2, "superpiano"), start.Add(2*time.Second))
+	if activity.Feed(osc.Message{Address: "/dirt/handshake"}, start) {
+		t.Error("Expected messages other than plays to be ignored")
+	}
+
+	tempo, ok := activity.Tempo(start.Add(3500 * time.Millisecond))
+	if !ok || tempo.BPM != 120 || tempo.Beats != 7 || tempo.Phase != 3 {
+		t.Errorf("Expected 120 BPM at beat 7 of cycle 1.75, got %+v (%v)", tempo, ok)
+	}
+
+	sound, ok := activity.Sound(start.Add(3500 * time.Millisecond))
+	if !ok {
+		t.Fatal("Expected the sound played")
+	}
+	if sound.Cycle != 1.75 || sound.EventDensity != 9.0/windowCycles {
+		t.Errorf("Expected 9 events over the window at cycle 1.75, got %+v", sound)
+	}
+	if !reflect.DeepEqual(sound.Orbits, []int{0, 2}) || !reflect.DeepEqual(sound.Sounds, []string{"bd", "hh", "superpiano"}) {
+		t.Errorf("Expected orbits 0 and 2 playing bd, hh and superpiano, got %+v", sound)
+	}
+
+	if _, ok := activity.Tempo(start.Add(time.Minute)); ok {
+		t.Error("Expected no tempo long after the last event")
+	}
+	if _, ok := activity.Tempo(start.Add(-time.Second)); ok {
+		t.Error("Expected no tempo before the first event played")
+	}
+}
+
+func TestWatcherForwards(t *testing.T) {
+	superDirt, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer superDirt.Close()
+
+	listener, _ := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	port := listener.LocalAddr().(*net.UDPAddr).Port
+	listener.Close()
+
+	watcher := NewWatcher(port, superDirt.LocalAddr().String())
+	if err := watcher.Start(nil); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	defer watcher.Stop()
+
+	// Tidal schedules its bundles a little ahead
+	packet, _ := osc.Bundle(time.Now().Add(50*time.Millisecond), play(12, 1, "arpy"))
+	conn, err := net.Dial("udp", listener.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	conn.Write(packet)
+
+	superDirt.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buffer := make([]byte, 1024)
+	n, err := superDirt.Read(buffer)
+	if err != nil || string(buffer[:n]) != string(packet) {
+		t.Fatalf("Expected the packet forwarded to SuperDirt, got %d bytes (%v)", n, err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if sound, ok := watcher.Sound(time.Now()); ok {
+			if sound.Cycle < 12 || !reflect.DeepEqual(sound.Sounds, []string{"arpy"}) {
+				t.Errorf("Expected arpy from cycle 12, got %+v", sound)
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("Expected the forwarded event to be recorded")
+}
@@ -0,0 +1,95 @@
+// Package tail follows the lines appended to a file, the way 'tail -F'
+// does, for watchers reading logs and transcripts editors write.
+package tail
+
+import (
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Follower reads the lines appended to a file since it was opened
+type Follower struct {
+	path    string
+	file    *os.File
+	offset  int64
+	partial string
+}
+
+// Open starts following the file at path from its current end, creating
+// it if missing
+func Open(path string) (*Follower, error) {
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &Follower{path: path, file: file, offset: offset}, nil
+}
+
+// Run checks the file every interval until stop is closed, calling line
+// for each complete line appended and idle after each check that found
+// none. A file truncated or replaced is read again from its start, after
+// a call to reset, which may be nil. The file is closed when Run returns.
+func (f *Follower) Run(stop <-chan struct{}, interval time.Duration, line func(string), idle func(), reset func()) {
+	defer func() { f.file.Close() }()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	buffer := make([]byte, 32*1024)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if f.replaced() {
+			if reopened, err := os.Open(f.path); err == nil {
+				f.file.Close()
+				f.file, f.offset, f.partial = reopened, 0, ""
+				if reset != nil {
+					reset()
+				}
+			}
+		}
+
+		for {
+			n, err := f.file.ReadAt(buffer, f.offset)
+			f.offset += int64(n)
+			f.partial += string(buffer[:n])
+			if err != nil || n == 0 {
+				break
+			}
+		}
+
+		lines := strings.Split(f.partial, "\n")
+		f.partial = lines[len(lines)-1]
+		for _, l := range lines[:len(lines)-1] {
+			line(strings.TrimRight(l, "\r"))
+		}
+		if len(lines) == 1 && idle != nil {
+			idle()
+		}
+	}
+}
+
+// replaced reports whether the file at the path was truncated or is
+// another file
+func (f *Follower) replaced() bool {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false
+	}
+	current, err := f.file.Stat()
+	if err != nil {
+		return false
+	}
+	return !os.SameFile(info, current) || info.Size() < f.offset
+}
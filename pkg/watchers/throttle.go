@@ -0,0 +1,119 @@
+package watchers
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Rate limit modes
+const (
+	RateLimitInterval = "interval" // skip executions within the window of a buffer's last commit
+	RateLimitLatest   = "latest"   // amend a buffer's last commit with executions within its window
+)
+
+// RateLimitConfig throttles auto-commits per buffer, so that nudging a
+// parameter twenty times doesn't make twenty commits
+type RateLimitConfig struct {
+	Mode   string `json:"mode,omitempty"`   // RateLimitInterval or RateLimitLatest; empty disables
+	Window string `json:"window,omitempty"` // Go duration, e.g. 5s
+}
+
+// parseRateLimitConfig returns the window of a rate limit, 0 when disabled
+func parseRateLimitConfig(config RateLimitConfig) (time.Duration, error) {
+	switch config.Mode {
+	case "":
+		return 0, nil
+	case RateLimitInterval, RateLimitLatest:
+	default:
+		return 0, fmt.Errorf("unknown mode %q, expected %s or %s", config.Mode, RateLimitInterval, RateLimitLatest)
+	}
+
+	window, err := time.ParseDuration(config.Window)
+	if err != nil || window <= 0 {
+		return 0, fmt.Errorf("invalid window %q", config.Window)
+	}
+	return window, nil
+}
+
+// bufferWindow is a buffer's last commit and the executions collapsed since
+type bufferWindow struct {
+	start   time.Time      // when the last commit's execution happened
+	event   ExecutionEvent // the last commit's execution
+	commit  string
+	skipped int // executions skipped since, in interval mode
+}
+
+// throttle tracks the rate limit of each buffer; it is used with ws.dedup
+// locked
+type throttle struct {
+	mode    string
+	window  time.Duration // 0 disables rate limiting
+	buffers map[string]*bufferWindow
+}
+
+// committed starts a new window for the buffer of a committed execution
+func (t *throttle) committed(event ExecutionEvent, commit string) {
+	if t.window <= 0 {
+		return
+	}
+	if t.buffers == nil {
+		t.buffers = make(map[string]*bufferWindow)
+	}
+	t.buffers[event.Buffer] = &bufferWindow{start: event.Timestamp, event: event, commit: commit}
+}
+
+// throttleExecution applies the rate limit to an execution and reports
+// whether it was collapsed into the buffer's last commit. An execution that
+// is committed carries the count of the executions skipped before it.
+func (ws *WatcherService) throttleExecution(event *ExecutionEvent) bool {
+	if ws.throttle.window <= 0 {
+		return false
+	}
+
+	last := ws.throttle.buffers[event.Buffer]
+	if last == nil {
+		return false
+	}
+	if event.Timestamp.Sub(last.start) >= ws.throttle.window {
+		event.Collapsed = last.skipped
+		delete(ws.throttle.buffers, event.Buffer)
+		return false
+	}
+
+	if ws.throttle.mode == RateLimitInterval {
+		last.skipped++
+		log.Printf("Execution skipped by the rate limit: %s/%s", event.Language, event.Buffer)
+		return true
+	}
+
+	// Keep the latest: the new execution replaces the last commit while
+	// it's still HEAD
+	event.Collapsed = last.event.Collapsed + 1
+	message, err := ws.generateCommitMessage(*event)
+	if err != nil {
+		log.Printf("Failed to generate commit message: %v", err)
+		return false
+	}
+
+	ws.repoMutex.Lock()
+	defer ws.repoMutex.Unlock()
+	head, err := ws.repository.Log(1)
+	if err != nil || len(head) == 0 || head[0].Hash != last.commit {
+		event.Collapsed = 0
+		return false
+	}
+	commit, err := ws.repository.Amend(event.Content, message, event.ToExecutionMetadata())
+	if err != nil {
+		log.Printf("Failed to collapse execution into %s: %v", last.commit, err)
+		event.Collapsed = 0
+		return false
+	}
+
+	last.event = *event
+	last.commit = commit.Hash
+	ws.dedup.add(*event, commit.Hash)
+	ws.lastContents.committed(event.Buffer, event.Content)
+	log.Printf("Execution collapsed by the rate limit: %s/%s (%d collapsed)", event.Language, event.Buffer, event.Collapsed)
+	return true
+}
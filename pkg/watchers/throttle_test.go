@@ -0,0 +1,91 @@
+package watchers
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// rateLimitedService returns a service whose auto-commits are rate limited
+func rateLimitedService(t *testing.T, mode string) (*WatcherService, string) {
+	t.Helper()
+	service, tempDir := createTestWatcherService(t)
+
+	if err := service.configManager.LoadConfig(); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	config := service.configManager.GetConfig()
+	config.RateLimit = RateLimitConfig{Mode: mode, Window: "5s"}
+	service.configManager.UpdateConfig(config)
+	if err := service.configManager.SaveConfig(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+	return service, tempDir
+}
+
+// nudges sends executions of d1 at the given seconds after start, each with
+// different content
+func nudges(service *WatcherService, start time.Time, seconds ...int) {
+	for _, second := range seconds {
+		service.handleExecutionEvent(ExecutionEvent{
+			Timestamp: start.Add(time.Duration(second) * time.Second),
+			Content:   "d1 $ s \"bd\" # nudge " + strconv.Itoa(second),
+			Buffer:    "d1",
+			Language:  "tidal",
+			Success:   true,
+		})
+	}
+}
+
+func TestRateLimitInterval(t *testing.T) {
+	service, tempDir := rateLimitedService(t, RateLimitInterval)
+	defer os.RemoveAll(tempDir)
+
+	start := time.Now()
+	nudges(service, start, 0, 1, 2, 3, 6)
+
+	commits, err := service.repository.History()
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("Expected 2 commits, got %d", len(commits))
+	}
+	if commits[0].Metadata.Collapsed != 0 || commits[1].Metadata.Collapsed != 3 {
+		t.Errorf("Expected the second commit to count 3 skipped executions, got %d and %d",
+			commits[0].Metadata.Collapsed, commits[1].Metadata.Collapsed)
+	}
+}
+
+func TestRateLimitLatest(t *testing.T) {
+	service, tempDir := rateLimitedService(t, RateLimitLatest)
+	defer os.RemoveAll(tempDir)
+
+	start := time.Now()
+	nudges(service, start, 0, 1, 2)
+
+	// Another buffer ends the window of d1 early: its commit isn't HEAD
+	service.handleExecutionEvent(ExecutionEvent{Timestamp: start.Add(3 * time.Second), Content: "d2 $ s \"hh\"",
+		Buffer: "d2", Language: "tidal", Success: true})
+	nudges(service, start, 4)
+
+	commits, err := service.repository.History()
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	if len(commits) != 3 {
+		t.Fatalf("Expected 3 commits, got %d", len(commits))
+	}
+	first := commits[0]
+	if first.Content != "d1 $ s \"bd\" # nudge 2" || first.Metadata.Collapsed != 2 {
+		t.Errorf("Expected the first commit to hold the latest of 3 executions, got %q (%d collapsed)",
+			first.Content, first.Metadata.Collapsed)
+	}
+	if commits[2].Metadata.Buffer != "d1" || commits[2].Metadata.Collapsed != 0 {
+		t.Errorf("Expected a new d1 commit after d2, got %s (%d collapsed)", commits[2].Metadata.Buffer, commits[2].Metadata.Collapsed)
+	}
+}
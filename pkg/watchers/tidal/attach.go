@@ -0,0 +1,269 @@
+package tidal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+	"github.com/livecodegit/pkg/watchers/jsonlines"
+	"github.com/livecodegit/pkg/watchers/tail"
+)
+
+// DefaultAttachBuffer names evaluations that don't play on a connection
+// like d1
+const DefaultAttachBuffer = "ghci"
+
+// attachInterval is how often an attached session is checked; a session
+// going quiet completes its last evaluation
+const attachInterval = 200 * time.Millisecond
+
+// ghciErrorLine matches the first line of a GHCi error, such as
+// "<interactive>:3:1: error:"
+var ghciErrorLine = regexp.MustCompile(`^<interactive>:\d+:\d+(-\d+)?: error`)
+
+// terminalEscape matches the escape sequences of a terminal capture:
+// colors, cursor moves and window titles
+var terminalEscape = regexp.MustCompile("\x1b(\\[[0-9;?]*[ -/]*[@-~]|\\][^\x07]*\x07|[()][A-Za-z0-9])")
+
+// SessionEvaluation is one evaluation read from an attached session
+type SessionEvaluation struct {
+	Time    time.Time
+	Content string
+	Error   string // empty when GHCi reported no error, or wasn't seen
+}
+
+// Session reads a GHCi session run by an editor back into evaluations.
+// Without a prompt its lines are what the editor sent GHCi, e.g. tee'd
+// into a file. With one they are the session's terminal, e.g. captured by
+// tmux pipe-pane: input follows the prompt, or the continuation prompt
+// inside :{ :} blocks, and the errors GHCi printed until the next prompt
+// belong to the evaluation before it.
+type Session struct {
+	Prompt     string
+	ContPrompt string
+
+	input   Input
+	pending *SessionEvaluation
+	errors  []string
+}
+
+// Feed reads one line of the session, returning the evaluation it completes
+func (s *Session) Feed(line string) (SessionEvaluation, bool) {
+	line = terminalEscape.ReplaceAllString(strings.TrimRight(line, "\r"), "")
+
+	if s.Prompt == "" {
+		content, ok := s.input.Feed(line)
+		if !ok {
+			return SessionEvaluation{}, false
+		}
+		return SessionEvaluation{Time: time.Now(), Content: content}, true
+	}
+
+	if s.input.inBlock {
+		if content, ok := s.input.Feed(strings.TrimPrefix(line, s.ContPrompt)); ok {
+			s.pending = &SessionEvaluation{Time: time.Now(), Content: content}
+		}
+		return SessionEvaluation{}, false
+	}
+
+	if strings.HasPrefix(line, s.Prompt) {
+		previous, complete := s.Flush()
+		if content, ok := s.input.Feed(strings.TrimPrefix(line, s.Prompt)); ok {
+			s.pending = &SessionEvaluation{Time: time.Now(), Content: content}
+		}
+		return previous, complete
+	}
+
+	if trimmed := strings.TrimSpace(line); s.pending != nil && (len(s.errors) > 0 || ghciErrorLine.MatchString(trimmed)) {
+		s.errors = append(s.errors, trimmed)
+	}
+	return SessionEvaluation{}, false
+}
+
+// Flush completes the pending evaluation, if any
+func (s *Session) Flush() (SessionEvaluation, bool) {
+	if s.pending == nil {
+		return SessionEvaluation{}, false
+	}
+	evaluation := *s.pending
+	evaluation.Error = strings.TrimSpace(strings.Join(s.errors, "\n"))
+	s.pending, s.errors = nil, nil
+	return evaluation, true
+}
+
+// AttachWatcher follows a GHCi session an editor runs instead of starting
+// one. The session is a file its input is tee'd into, or its terminal is
+// piped into, or a named pipe either is written to.
+type AttachWatcher struct {
+	config   common.WatcherConfig
+	path     string
+	running  bool
+	mutex    sync.RWMutex
+	stop     chan struct{}
+	pipe     *os.File
+	callback func(common.ExecutionEvent)
+}
+
+// NewAttachWatcher creates a watcher following the session at path.
+// Options: attach_prompt, set when the session is a terminal capture, and
+// attach_prompt_cont, the continuation prompt inside :{ :} blocks.
+func NewAttachWatcher(path string, options map[string]string) *AttachWatcher {
+	config := common.WatcherConfig{
+		Language:    "tidal",
+		Environment: "tidal-cycles",
+		Enabled:     true,
+		Options:     map[string]string{"attach": path},
+	}
+	for key, value := range options {
+		config.Options[key] = value
+	}
+	return &AttachWatcher{config: config, path: path}
+}
+
+// Start follows the session from its current end. A missing path is
+// created as a file.
+func (w *AttachWatcher) Start(callback func(common.ExecutionEvent)) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.running {
+		return fmt.Errorf("GHCi attach watcher is already running")
+	}
+
+	session := &Session{Prompt: w.config.Options["attach_prompt"], ContPrompt: w.config.Options["attach_prompt_cont"]}
+	line := func(line string) {
+		if evaluation, ok := session.Feed(line); ok {
+			w.emit(evaluation)
+		}
+	}
+	idle := func() {
+		if evaluation, ok := session.Flush(); ok {
+			w.emit(evaluation)
+		}
+	}
+
+	w.callback = callback
+	w.stop = make(chan struct{})
+
+	if info, err := os.Stat(w.path); err == nil && info.Mode()&os.ModeNamedPipe != 0 {
+		pipe, err := jsonlines.OpenPipe(w.path)
+		if err != nil {
+			return fmt.Errorf("failed to open pipe %s: %w", w.path, err)
+		}
+		w.pipe = pipe
+		go w.readPipe(pipe, w.stop, line, idle)
+	} else {
+		follower, err := tail.Open(w.path)
+		if err != nil {
+			return fmt.Errorf("failed to open session %s: %w", w.path, err)
+		}
+		// A new session starts over
+		reset := func() {
+			idle()
+			*session = Session{Prompt: session.Prompt, ContPrompt: session.ContPrompt}
+		}
+		go follower.Run(w.stop, attachInterval, line, idle, reset)
+	}
+
+	w.running = true
+	return nil
+}
+
+// readPipe passes the pipe's lines on until stop is closed, calling idle
+// when no line came for a while
+func (w *AttachWatcher) readPipe(pipe *os.File, stop chan struct{}, line func(string), idle func()) {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(pipe)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case text, ok := <-lines:
+			if !ok {
+				return
+			}
+			line(text)
+		case <-time.After(attachInterval):
+			idle()
+		}
+	}
+}
+
+// Stop stops following the session
+func (w *AttachWatcher) Stop() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.running {
+		return nil
+	}
+
+	w.running = false
+	close(w.stop)
+	if w.pipe != nil {
+		err := w.pipe.Close()
+		w.pipe = nil
+		return err
+	}
+	return nil
+}
+
+// IsRunning returns true if the watcher is active
+func (w *AttachWatcher) IsRunning() bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.running
+}
+
+// GetConfig returns the watcher configuration
+func (w *AttachWatcher) GetConfig() common.WatcherConfig {
+	return w.config
+}
+
+// GetLanguage returns "tidal"
+func (w *AttachWatcher) GetLanguage() string {
+	return "tidal"
+}
+
+// GetEnvironment returns "tidal-cycles"
+func (w *AttachWatcher) GetEnvironment() string {
+	return "tidal-cycles"
+}
+
+// emit passes an evaluation on as an execution
+func (w *AttachWatcher) emit(evaluation SessionEvaluation) {
+	buffer := DefaultAttachBuffer
+	if match := connectionPattern.FindStringSubmatch(evaluation.Content); match != nil {
+		buffer = match[1]
+	}
+
+	event := common.ExecutionEvent{
+		Timestamp:    evaluation.Time,
+		Content:      evaluation.Content,
+		Buffer:       buffer,
+		Language:     "tidal",
+		Environment:  "tidal-cycles",
+		Success:      evaluation.Error == "",
+		ErrorMessage: evaluation.Error,
+	}
+	if w.callback != nil {
+		w.callback(event)
+	}
+}
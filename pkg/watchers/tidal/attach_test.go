@@ -0,0 +1,103 @@
+package tidal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+func TestSessionInput(t *testing.T) {
+	var session Session
+	var evaluations []string
+	for _, line := range []string{
+		":set -XOverloadedStrings",
+		`d1 $ s "bd*2"`,
+		":{",
+		"do",
+		`  d2 $ s "hh*4"`,
+		":}",
+	} {
+		if evaluation, ok := session.Feed(line); ok {
+			evaluations = append(evaluations, evaluation.Content)
+		}
+	}
+
+	expected := []string{`d1 $ s "bd*2"`, "do\n  d2 $ s \"hh*4\""}
+	if len(evaluations) != len(expected) || evaluations[0] != expected[0] || evaluations[1] != expected[1] {
+		t.Errorf("Expected %q, got %q", expected, evaluations)
+	}
+}
+
+func TestSessionTerminal(t *testing.T) {
+	session := Session{Prompt: "tidal> ", ContPrompt: "tidal| "}
+	var evaluations []SessionEvaluation
+	feed := func(line string) {
+		if evaluation, ok := session.Feed(line); ok {
+			evaluations = append(evaluations, evaluation)
+		}
+	}
+
+	for _, line := range []string{
+		"\x1b[1mtidal> \x1b[0md1 $ s \"bd*2\"",
+		"tidal> :{",
+		"tidal| d2 $ s \"hh*4\"",
+		"tidal|   # gain 1.2",
+		"tidal| :}",
+		"tidal> d3 $ brokn \"sn\"",
+		"<interactive>:5:6: error:",
+		"    Variable not in scope: brokn",
+		"tidal> ",
+		"Loaded GHCi configuration",
+		"tidal> d4 $ silence",
+	} {
+		feed(line)
+	}
+	if evaluation, ok := session.Flush(); ok {
+		evaluations = append(evaluations, evaluation)
+	}
+
+	expected := []SessionEvaluation{
+		{Content: `d1 $ s "bd*2"`},
+		{Content: "d2 $ s \"hh*4\"\n  # gain 1.2"},
+		{Content: `d3 $ brokn "sn"`, Error: "<interactive>:5:6: error:\nVariable not in scope: brokn"},
+		{Content: "d4 $ silence"},
+	}
+	if len(evaluations) != len(expected) {
+		t.Fatalf("Expected %d evaluations, got %+v", len(expected), evaluations)
+	}
+	for i, evaluation := range evaluations {
+		if evaluation.Content != expected[i].Content || evaluation.Error != expected[i].Error {
+			t.Errorf("Expected %+v, got %+v", expected[i], evaluation)
+		}
+	}
+}
+
+func TestAttachWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.log")
+	watcher := NewAttachWatcher(path, map[string]string{"attach_prompt": "tidal> "})
+
+	events := make(chan common.ExecutionEvent, 4)
+	if err := watcher.Start(func(event common.ExecutionEvent) { events <- event }); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	defer watcher.Stop()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("Failed to open session: %v", err)
+	}
+	defer file.Close()
+	file.WriteString("tidal> d2 $ s \"arpy\"\n<interactive>:1:1: error: oops\ntidal> \n")
+
+	select {
+	case event := <-events:
+		if event.Content != `d2 $ s "arpy"` || event.Buffer != "d2" || event.Success || event.ErrorMessage != "<interactive>:1:1: error: oops" {
+			t.Errorf("Expected the failed evaluation on d2, got %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected an execution from the session")
+	}
+}
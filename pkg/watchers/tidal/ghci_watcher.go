@@ -3,22 +3,42 @@ package tidal
 import (
 	"bufio"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/livecodegit/pkg/storage"
 	"github.com/livecodegit/pkg/watchers/common"
 )
 
+// Default GHCi boot options
+const (
+	DefaultBootFile    = "BootTidal.hs"
+	DefaultBootTimeout = 30 * time.Second
+
+	// bootSentinel is printed by GHCi once every boot command has run
+	bootSentinel = "lcg: boot done"
+
+	// evaluatedSentinel is printed on stderr after each pattern, so the
+	// errors GHCi printed before it belong to that pattern
+	evaluatedSentinel = "lcg: evaluated"
+)
+
+// Connections is how many connections, d1 to d16, the boot commands define
+const Connections = 16
+
 // GHCiWatcher monitors TidalCycles through GHCi interaction
 type GHCiWatcher struct {
-	config   common.WatcherConfig
-	running  bool
-	mutex    sync.RWMutex
-	callback func(common.ExecutionEvent)
+	config    common.WatcherConfig
+	running   bool
+	mutex     sync.RWMutex
+	callback  func(common.ExecutionEvent)
+	lifecycle func(common.LifecycleEvent)
 
 	// GHCi process management
 	cmd    *exec.Cmd
@@ -26,27 +46,57 @@ type GHCiWatcher struct {
 	stdout *bufio.Reader
 	stderr *bufio.Reader
 
+	// Boot state; errors on stderr while booting fail the boot instead of
+	// becoming failed executions
+	booting    bool
+	bootErrors []string
+	bootDone   chan struct{}
+	bootOnce   sync.Once
+
 	// Tidal-specific state
 	currentCPS  float64
 	startTime   time.Time
 	connections map[string]string // Track active connections (d1, d2, etc.)
 
-	// Pattern tracking
+	// Pattern tracking; with a history, active patterns come from commits
 	lastPatterns map[string]string
+	history      common.History
+
+	// Patterns sent by ExecutePattern that GHCi is still evaluating, in
+	// order, and the blocks assembled from GHCi's output
+	evaluations []*pendingPattern
+	output      Input
+	sendMutex   sync.Mutex
 }
 
-// NewGHCiWatcher creates a new TidalCycles GHCi watcher
-func NewGHCiWatcher() *GHCiWatcher {
-	return &GHCiWatcher{
-		config: common.WatcherConfig{
-			Language:    "tidal",
-			Environment: "tidal-cycles",
-			Enabled:     true,
-			Options: map[string]string{
-				"ghci_command": "ghci",
-				"boot_file":    "BootTidal.hs",
-			},
+// pendingPattern is a pattern sent to GHCi and the errors it printed for it
+type pendingPattern struct {
+	content string
+	errors  []string
+}
+
+// NewGHCiWatcher creates a new TidalCycles GHCi watcher. Options override the
+// defaults: ghci_command (ghci, "stack ghci" or "cabal repl", with quoted
+// arguments), boot_file, boot_commands (one GHCi line each, used instead of
+// the boot file), tidal_version ("auto" asks ghc-pkg) and boot_timeout.
+func NewGHCiWatcher(options map[string]string) *GHCiWatcher {
+	config := common.WatcherConfig{
+		Language:    "tidal",
+		Environment: "tidal-cycles",
+		Enabled:     true,
+		Options: map[string]string{
+			"ghci_command":  "ghci",
+			"boot_file":     DefaultBootFile,
+			"tidal_version": "auto",
+			"boot_timeout":  DefaultBootTimeout.String(),
 		},
+	}
+	for key, value := range options {
+		config.Options[key] = value
+	}
+
+	return &GHCiWatcher{
+		config:       config,
 		running:      false,
 		currentCPS:   0.5625, // Default Tidal CPS
 		connections:  make(map[string]string),
@@ -54,6 +104,13 @@ func NewGHCiWatcher() *GHCiWatcher {
 	}
 }
 
+// SetLifecycleCallback sets the function told whether Tidal booted
+func (w *GHCiWatcher) SetLifecycleCallback(callback func(common.LifecycleEvent)) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.lifecycle = callback
+}
+
 // Start begins monitoring TidalCycles through GHCi
 func (w *GHCiWatcher) Start(callback func(common.ExecutionEvent)) error {
 	w.mutex.Lock()
@@ -66,9 +123,12 @@ func (w *GHCiWatcher) Start(callback func(common.ExecutionEvent)) error {
 	w.callback = callback
 	w.startTime = time.Now()
 
-	// Start GHCi process
-	ghciCmd := w.config.Options["ghci_command"]
-	w.cmd = exec.Command(ghciCmd)
+	// Start GHCi process; the command may carry arguments, e.g. "stack ghci"
+	fields := common.CommandFields(w.config.Options["ghci_command"])
+	if len(fields) == 0 {
+		return fmt.Errorf("GHCi command cannot be empty")
+	}
+	w.cmd = exec.Command(fields[0], fields[1:]...)
 
 	// Set up pipes for communication
 	stdin, err := w.cmd.StdinPipe()
@@ -96,6 +156,12 @@ func (w *GHCiWatcher) Start(callback func(common.ExecutionEvent)) error {
 	}
 
 	w.running = true
+	w.booting = true
+	w.bootErrors = nil
+	w.evaluations = nil
+	w.output = Input{}
+	w.bootDone = make(chan struct{})
+	w.bootOnce = sync.Once{}
 
 	// Initialize Tidal in separate goroutine
 	go w.initializeTidal()
@@ -155,24 +221,256 @@ func (w *GHCiWatcher) GetEnvironment() string {
 	return "tidal-cycles"
 }
 
-// initializeTidal sends initialization commands to set up TidalCycles
+// initializeTidal sends the boot commands and reports whether Tidal booted
 func (w *GHCiWatcher) initializeTidal() {
 	// Wait a bit for GHCi to start
 	time.Sleep(1 * time.Second)
 
-	initCommands := []string{
+	commands, description := w.bootCommands()
+	commands = append(commands, fmt.Sprintf("putStrLn %q", bootSentinel))
+
+	for _, cmd := range commands {
+		if err := w.sendCommand(cmd); err != nil {
+			w.finishBoot(fmt.Errorf("failed to send boot commands: %w", err), description)
+			return
+		}
+		time.Sleep(100 * time.Millisecond) // Small delay between commands
+	}
+
+	timeout, err := time.ParseDuration(w.config.Options["boot_timeout"])
+	if err != nil || timeout <= 0 {
+		timeout = DefaultBootTimeout
+	}
+
+	select {
+	case <-w.bootDone:
+		w.finishBoot(nil, description)
+	case <-time.After(timeout):
+		w.finishBoot(fmt.Errorf("GHCi did not finish booting within %s", timeout), description)
+	}
+}
+
+// bootCommands returns the GHCi lines that boot Tidal and a description of
+// where they came from: boot_commands if set, else the boot file if it
+// exists, else the built-in boot for the installed Tidal version
+func (w *GHCiWatcher) bootCommands() ([]string, string) {
+	if custom := strings.TrimSpace(w.config.Options["boot_commands"]); custom != "" {
+		return strings.Split(custom, "\n"), "boot_commands"
+	}
+
+	prompt := `:set prompt "tidal> "`
+	if path := ResolveBootFile(w.config.Options["boot_file"], w.config.Options["ghci_command"]); path != "" {
+		// The boot file may set its own prompt; ours is what the output
+		// monitor skips
+		return []string{":script " + path, prompt}, path
+	}
+
+	version := w.config.Options["tidal_version"]
+	if version == "" || version == "auto" {
+		detected, err := DetectTidalVersion(w.config.Options["ghci_command"])
+		if err != nil {
+			version = ""
+		} else {
+			version = detected
+		}
+	}
+	if version == "" {
+		return DefaultBootCommands(""), "built-in boot (Tidal version unknown)"
+	}
+	return DefaultBootCommands(version), "built-in boot for Tidal " + version
+}
+
+// finishBoot ends the boot and reports it as a lifecycle event
+func (w *GHCiWatcher) finishBoot(err error, description string) {
+	w.mutex.Lock()
+	w.booting = false
+	if err == nil && len(w.bootErrors) > 0 {
+		err = fmt.Errorf("%s", strings.Join(w.bootErrors, " "))
+	}
+	lifecycle := w.lifecycle
+	running := w.running
+	w.mutex.Unlock()
+
+	if lifecycle == nil || !running {
+		return
+	}
+
+	event := common.LifecycleEvent{Timestamp: time.Now(), Kind: common.LifecycleBoot, Success: err == nil}
+	if err != nil {
+		event.Message = fmt.Sprintf("Tidal failed to boot from %s: %v", description, err)
+	} else {
+		event.Message = "Tidal booted from " + description
+	}
+	lifecycle(event)
+}
+
+// ResolveBootFile returns the absolute path of the boot file to load, or ""
+// when there is none. A leading ~ is the home directory. The default
+// BootTidal.hs is looked up in the working directory, then in the data
+// directory of the installed Tidal, which ships one.
+func ResolveBootFile(bootFile, ghciCommand string) string {
+	if bootFile == "" {
+		return ""
+	}
+	if bootFile == "~" || strings.HasPrefix(bootFile, "~/") {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			bootFile = filepath.Join(homeDir, bootFile[1:])
+		}
+	}
+
+	if path, err := filepath.Abs(bootFile); err == nil {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	if bootFile != DefaultBootFile {
+		return ""
+	}
+
+	fields := ghcPkgQuery(ghciCommand, "data-dir")
+	if fields == nil {
+		return ""
+	}
+	output, err := exec.Command(fields[0], fields[1:]...).Output()
+	if err != nil {
+		return ""
+	}
+
+	// Several installed versions list several directories; later package
+	// databases shadow earlier ones
+	dirs := strings.Split(strings.TrimSpace(string(output)), "\n")
+	for i := len(dirs) - 1; i >= 0; i-- {
+		path := filepath.Join(strings.TrimSpace(dirs[i]), DefaultBootFile)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// DefaultBootCommands returns the GHCi lines that boot a Tidal version
+// without a boot file. Tidal before 1.0 connects with dirtStream; later
+// versions start a stream to SuperDirt, like the BootTidal.hs they ship.
+// An empty version boots the current Tidal.
+func DefaultBootCommands(version string) []string {
+	commands := []string{
 		":set -XOverloadedStrings",
-		":set prompt \"tidal> \"",
+		`:set prompt "tidal> "`,
+		`:set prompt-cont ""`,
 		"import Sound.Tidal.Context",
-		"(cps, nudger, d1, d2, d3, d4, d5, d6, d7, d8, d9) <- dirtStream",
-		"let bps x = cps (x/4)",
-		"let hush = mapM_ ($ silence) [d1,d2,d3,d4,d5,d6,d7,d8,d9]",
 	}
 
-	for _, cmd := range initCommands {
-		w.sendCommand(cmd)
-		time.Sleep(100 * time.Millisecond) // Small delay between commands
+	if major, _, ok := parseMajorMinor(version); ok && major < 1 {
+		return append(commands,
+			"(cps, nudger, d1, d2, d3, d4, d5, d6, d7, d8, d9) <- dirtStream",
+			"let bps x = cps (x/4)",
+			"let hush = mapM_ ($ silence) [d1,d2,d3,d4,d5,d6,d7,d8,d9]",
+		)
+	}
+
+	commands = append(commands,
+		`tidal <- startTidal (superdirtTarget {oLatency = 0.05, oAddress = "127.0.0.1", oPort = 57120}) (defaultConfig {cFrameTimespan = 1/20})`,
+		":{",
+		"let p = streamReplace tidal",
+		"    hush = streamHush tidal",
+		"    list = streamList tidal",
+		"    mute = streamMute tidal",
+		"    unmute = streamUnmute tidal",
+		"    solo = streamSolo tidal",
+		"    unsolo = streamUnsolo tidal",
+		"    once = streamOnce tidal",
+		"    asap = once",
+		"    setcps = asap . cps",
+	)
+	for i := 1; i <= Connections; i++ {
+		commands = append(commands, fmt.Sprintf("    d%d = p %d . (|< orbit %d)", i, i, i-1))
+	}
+	return append(commands, ":}")
+}
+
+// DetectTidalVersion asks ghc-pkg for the installed Tidal version, using the
+// ghc-pkg that belongs to the GHCi command
+func DetectTidalVersion(ghciCommand string) (string, error) {
+	fields := ghcPkgCommand(ghciCommand)
+	if fields == nil {
+		return "", fmt.Errorf("cannot find ghc-pkg for %q", ghciCommand)
 	}
+
+	output, err := exec.Command(fields[0], fields[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("ghc-pkg failed: %w", err)
+	}
+	return parseTidalVersion(string(output))
+}
+
+// ghcPkgCommand returns the ghc-pkg command that asks the package database
+// of a GHCi command for the Tidal version, or nil for commands it doesn't know
+func ghcPkgCommand(ghciCommand string) []string {
+	return ghcPkgQuery(ghciCommand, "version")
+}
+
+// ghcPkgQuery returns the ghc-pkg command that reads a field of the Tidal
+// package from the package database a GHCi command uses
+func ghcPkgQuery(ghciCommand, field string) []string {
+	query := []string{"field", "tidal", field, "--simple-output"}
+
+	fields := common.CommandFields(ghciCommand)
+	switch {
+	case len(fields) == 0:
+		return nil
+	case len(fields) >= 2 && filepath.Base(fields[0]) == "stack" && (fields[1] == "ghci" || fields[1] == "repl"):
+		return append([]string{fields[0], "exec", "--", "ghc-pkg"}, query...)
+	case len(fields) >= 2 && filepath.Base(fields[0]) == "cabal" && strings.HasSuffix(fields[1], "repl"):
+		// cabal repl, v2-repl and new-repl
+		return append([]string{fields[0], "exec", "--", "ghc-pkg"}, query...)
+	case strings.HasPrefix(filepath.Base(fields[0]), "ghci"):
+		// ghci, ghci-9.4.7 and /opt/ghc/bin/ghci sit next to their ghc-pkg
+		i := strings.LastIndex(fields[0], "ghci")
+		return append([]string{fields[0][:i] + "ghc-pkg" + fields[0][i+len("ghci"):]}, query...)
+	}
+	return nil
+}
+
+// parseTidalVersion picks the newest version from ghc-pkg output, which lists
+// one per line when several are installed
+func parseTidalVersion(output string) (string, error) {
+	newest := ""
+	newestMajor, newestMinor := -1, -1
+	for _, version := range strings.Fields(output) {
+		major, minor, ok := parseMajorMinor(version)
+		if !ok {
+			continue
+		}
+		if major > newestMajor || (major == newestMajor && minor > newestMinor) {
+			newest, newestMajor, newestMinor = version, major, minor
+		}
+	}
+	if newest == "" {
+		return "", fmt.Errorf("tidal is not installed")
+	}
+	return newest, nil
+}
+
+// ValidVersion reports whether version names a Tidal version, like 1.9
+func ValidVersion(version string) bool {
+	_, _, ok := parseMajorMinor(version)
+	return ok
+}
+
+// parseMajorMinor reads the first two components of a version like 1.9.10
+func parseMajorMinor(version string) (int, int, bool) {
+	parts := strings.Split(version, ".")
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	minor := 0
+	if len(parts) > 1 {
+		if minor, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, 0, false
+		}
+	}
+	return major, minor, true
 }
 
 // sendCommand sends a command to GHCi
@@ -195,8 +493,23 @@ func (w *GHCiWatcher) monitorOutput() {
 
 	for scanner.Scan() && w.IsRunning() {
 		line := scanner.Text()
+		if strings.Contains(line, bootSentinel) {
+			w.bootOnce.Do(func() { close(w.bootDone) })
+			continue
+		}
+		if w.isBooting() {
+			continue
+		}
 		w.processOutputLine(line)
 	}
+
+	// GHCi exited, or was stopped, before running every boot command
+	w.bootOnce.Do(func() {
+		w.mutex.Lock()
+		w.bootErrors = append(w.bootErrors, "GHCi exited while booting")
+		w.mutex.Unlock()
+		close(w.bootDone)
+	})
 }
 
 // monitorErrors monitors GHCi stderr for error messages
@@ -205,10 +518,78 @@ func (w *GHCiWatcher) monitorErrors() {
 
 	for scanner.Scan() && w.IsRunning() {
 		line := scanner.Text()
+		if strings.Contains(line, evaluatedSentinel) {
+			w.finishEvaluation()
+			continue
+		}
+		if w.recordBootError(line) {
+			continue
+		}
+		if w.recordEvaluationError(line) {
+			continue
+		}
 		w.processErrorLine(line)
 	}
 }
 
+// recordEvaluationError keeps a stderr line as an error of the pattern
+// GHCi is evaluating, and reports whether there was one
+func (w *GHCiWatcher) recordEvaluationError(line string) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if len(w.evaluations) == 0 {
+		return false
+	}
+	if line = strings.TrimSpace(line); line != "" {
+		current := w.evaluations[0]
+		current.errors = append(current.errors, line)
+	}
+	return true
+}
+
+// finishEvaluation reports the pattern GHCi finished evaluating, failed
+// if GHCi printed errors for it
+func (w *GHCiWatcher) finishEvaluation() {
+	w.mutex.Lock()
+	if len(w.evaluations) == 0 {
+		w.mutex.Unlock()
+		return
+	}
+	current := w.evaluations[0]
+	w.evaluations = w.evaluations[1:]
+	w.mutex.Unlock()
+
+	errorMessage := strings.Join(current.errors, "\n")
+	event := w.createPatternExecutionEvent(current.content, errorMessage == "", errorMessage)
+	if w.callback != nil {
+		w.callback(event)
+	}
+}
+
+// isBooting reports whether the boot commands are still running
+func (w *GHCiWatcher) isBooting() bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.booting
+}
+
+// recordBootError keeps a stderr line while booting and reports whether it
+// was kept. Only lines from GHCi errors are kept, not warnings.
+func (w *GHCiWatcher) recordBootError(line string) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.booting {
+		return false
+	}
+	line = strings.TrimSpace(line)
+	if line != "" && (len(w.bootErrors) > 0 || strings.Contains(line, "error")) && len(w.bootErrors) < 5 {
+		w.bootErrors = append(w.bootErrors, line)
+	}
+	return true
+}
+
 // processOutputLine analyzes GHCi output for execution events
 func (w *GHCiWatcher) processOutputLine(line string) {
 	line = strings.TrimSpace(line)
@@ -218,9 +599,9 @@ func (w *GHCiWatcher) processOutputLine(line string) {
 		return
 	}
 
-	// Check for pattern evaluations
-	if w.isPatternEvaluation(line) {
-		event := w.createPatternExecutionEvent(line, true, "")
+	// Check for pattern evaluations, a :{ :} block being one
+	if content, ok := w.output.Feed(line); ok && w.isPatternEvaluation(content) {
+		event := w.createPatternExecutionEvent(content, true, "")
 		if w.callback != nil {
 			w.callback(event)
 		}
@@ -247,30 +628,14 @@ func (w *GHCiWatcher) processErrorLine(line string) {
 	}
 }
 
+// evaluationPattern matches a pattern sent to a connection, e.g. "d3 $"
+var evaluationPattern = regexp.MustCompile(`\bd\d+\s*\$`)
+
 // isPatternEvaluation checks if the line indicates a pattern was evaluated
 func (w *GHCiWatcher) isPatternEvaluation(line string) bool {
-	// TidalCycles pattern indicators
-	patterns := []string{
-		"d1 $",
-		"d2 $",
-		"d3 $",
-		"d4 $",
-		"d5 $",
-		"d6 $",
-		"d7 $",
-		"d8 $",
-		"d9 $",
-		"hush",
-		"silence",
-	}
-
-	for _, pattern := range patterns {
-		if strings.Contains(line, pattern) {
-			return true
-		}
-	}
-
-	return false
+	return evaluationPattern.MatchString(line) ||
+		strings.Contains(line, "hush") ||
+		strings.Contains(line, "silence")
 }
 
 // isCPSChange checks if the line indicates a CPS (cycles per second) change
@@ -307,7 +672,16 @@ func (w *GHCiWatcher) createPatternExecutionEvent(content string, success bool,
 
 	// Store the pattern for this connection
 	if success && connection != "" {
-		w.lastPatterns[connection] = content
+		w.mutex.Lock()
+		switch {
+		case connection == "all":
+			w.lastPatterns = make(map[string]string)
+		case silences(content):
+			delete(w.lastPatterns, connection)
+		default:
+			w.lastPatterns[connection] = content
+		}
+		w.mutex.Unlock()
 	}
 
 	return common.ExecutionEvent{
@@ -327,11 +701,13 @@ func (w *GHCiWatcher) createPatternExecutionEvent(content string, success bool,
 	}
 }
 
+// connectionPattern matches the connections patterns play on: d1, d2, etc.
+var connectionPattern = regexp.MustCompile(`\b(d\d+)\b`)
+
 // extractConnection extracts the connection name (d1, d2, etc.) from Tidal code
 func (w *GHCiWatcher) extractConnection(content string) string {
 	// Look for d1, d2, etc. in the content
-	connectionRegex := regexp.MustCompile(`\b(d\d+)\b`)
-	matches := connectionRegex.FindStringSubmatch(content)
+	matches := connectionPattern.FindStringSubmatch(content)
 
 	if len(matches) > 1 {
 		return matches[1]
@@ -353,28 +729,116 @@ func (w *GHCiWatcher) calculateCyclesFromStart(timestamp time.Time) float64 {
 	return totalCycles
 }
 
-// ExecutePattern sends a pattern to TidalCycles for execution
+// ExecutePattern sends a pattern to TidalCycles for execution and reports
+// it, with the errors GHCi printed for it, once GHCi has evaluated it. A
+// pattern over several lines, like a do block, is sent as one :{ :} block.
 func (w *GHCiWatcher) ExecutePattern(pattern string) error {
 	if !w.IsRunning() {
 		return fmt.Errorf("watcher is not running")
 	}
 
-	return w.sendCommand(pattern)
+	pattern = strings.TrimRight(pattern, "\n")
+	lines := []string{pattern}
+	if strings.Contains(pattern, "\n") {
+		lines = append(append([]string{":{"}, strings.Split(pattern, "\n")...), ":}")
+	}
+	lines = append(lines, fmt.Sprintf("System.IO.hPutStrLn System.IO.stderr %q", evaluatedSentinel))
+
+	// Lines of concurrent patterns must not interleave
+	w.sendMutex.Lock()
+	defer w.sendMutex.Unlock()
+
+	w.mutex.Lock()
+	w.evaluations = append(w.evaluations, &pendingPattern{content: pattern})
+	w.mutex.Unlock()
+
+	for _, line := range lines {
+		if err := w.sendCommand(line); err != nil {
+			w.mutex.Lock()
+			w.evaluations = w.evaluations[:len(w.evaluations)-1]
+			w.mutex.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+// SetHistory backs GetActivePatterns with the repository's commits
+func (w *GHCiWatcher) SetHistory(history common.History) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.history = history
 }
 
-// GetActivePatterns returns the currently active patterns
+// GetActivePatterns returns the pattern playing on each connection. With a
+// history it's the last successful commit of each of d1 to d16, so patterns
+// played before the watcher started count too; without one, or if the
+// history can't be read, it's the patterns evaluated since Start.
 func (w *GHCiWatcher) GetActivePatterns() map[string]string {
 	w.mutex.RLock()
-	defer w.mutex.RUnlock()
-
+	history := w.history
 	patterns := make(map[string]string)
 	for k, v := range w.lastPatterns {
 		patterns[k] = v
 	}
+	w.mutex.RUnlock()
 
+	if history != nil {
+		if active, err := ActivePatterns(history); err == nil {
+			return active
+		}
+	}
 	return patterns
 }
 
+// ActivePatterns returns the last successful pattern committed on each of d1
+// to d16. A connection set to silence, or hushed afterwards, isn't playing.
+func ActivePatterns(history common.History) (map[string]string, error) {
+	hushed, err := lastSuccess(history, "all")
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := make(map[string]string)
+	for i := 1; i <= Connections; i++ {
+		connection := fmt.Sprintf("d%d", i)
+		commit, err := lastSuccess(history, connection)
+		if err != nil {
+			return nil, err
+		}
+		if commit == nil || silences(commit.Content) {
+			continue
+		}
+		if hushed != nil && !commit.Timestamp.After(hushed.Timestamp) {
+			continue
+		}
+		patterns[connection] = commit.Content
+	}
+	return patterns, nil
+}
+
+// lastSuccess returns the last successful commit of a buffer, or nil
+func lastSuccess(history common.History, buffer string) (*storage.Commit, error) {
+	commits, err := history.GetCommitsByBuffer(buffer)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(commits) - 1; i >= 0; i-- {
+		if commits[i].Metadata.Success {
+			return commits[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// silencePattern matches a connection being stopped, e.g. "d1 $ silence"
+var silencePattern = regexp.MustCompile(`\$\s*silence\s*$`)
+
+// silences reports whether a pattern stops its connection
+func silences(content string) bool {
+	return silencePattern.MatchString(strings.TrimSpace(content))
+}
+
 // Hush stops all active patterns (equivalent to Tidal's hush command)
 func (w *GHCiWatcher) Hush() error {
 	return w.ExecutePattern("hush")
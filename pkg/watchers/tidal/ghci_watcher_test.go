@@ -0,0 +1,265 @@
+package tidal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/storage"
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+// fakeGHCi writes a script that answers putStrLn and hPutStrLn like GHCi
+// and fails on lines containing "broken"
+func fakeGHCi(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ghci")
+	script := `#!/bin/sh
+while read -r line; do
+	case "$line" in
+	*broken*) printf "<interactive>:1:1: error:\n    Not in scope: 'broken'\n" >&2 ;;
+	*hPutStrLn*) echo "$line" | sed 's/.*hPutStrLn System.IO.stderr "\(.*\)"/\1/' >&2 ;;
+	putStrLn*) echo "tidal> $line" | sed 's/.*putStrLn "\(.*\)"/\1/' ;;
+	esac
+done
+`
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake GHCi: %v", err)
+	}
+	return path
+}
+
+func bootWatcher(t *testing.T, options map[string]string) common.LifecycleEvent {
+	t.Helper()
+	watcher := NewGHCiWatcher(options)
+	events := make(chan common.LifecycleEvent, 1)
+	watcher.SetLifecycleCallback(func(event common.LifecycleEvent) { events <- event })
+
+	executions := make(chan common.ExecutionEvent, 10)
+	if err := watcher.Start(func(event common.ExecutionEvent) { executions <- event }); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	select {
+	case event := <-events:
+		if len(executions) != 0 {
+			t.Errorf("Expected no executions while booting, got %d", len(executions))
+		}
+		return event
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Expected a boot event")
+	}
+	return common.LifecycleEvent{}
+}
+
+func TestGHCiWatcherReportsBoot(t *testing.T) {
+	ghci := fakeGHCi(t)
+
+	event := bootWatcher(t, map[string]string{"ghci_command": ghci, "boot_commands": "import Sound.Tidal.Context"})
+	if !event.Success || event.Kind != common.LifecycleBoot || !strings.Contains(event.Message, "boot_commands") {
+		t.Errorf("Expected a successful boot from boot_commands, got %+v", event)
+	}
+
+	event = bootWatcher(t, map[string]string{"ghci_command": ghci, "boot_commands": "import Sound.Tidal.Context\nbroken"})
+	if event.Success || !strings.Contains(event.Message, "Not in scope") {
+		t.Errorf("Expected the boot error to be reported, got %+v", event)
+	}
+
+	bootFile := filepath.Join(t.TempDir(), "BootTidal.hs")
+	if err := os.WriteFile(bootFile, []byte("import Sound.Tidal.Context\n"), 0644); err != nil {
+		t.Fatalf("Failed to write boot file: %v", err)
+	}
+	event = bootWatcher(t, map[string]string{"ghci_command": ghci, "boot_file": bootFile})
+	if !event.Success || !strings.Contains(event.Message, bootFile) {
+		t.Errorf("Expected a boot from %s, got %+v", bootFile, event)
+	}
+}
+
+func TestDefaultBootCommands(t *testing.T) {
+	legacy := strings.Join(DefaultBootCommands("0.9.10"), "\n")
+	if !strings.Contains(legacy, "dirtStream") {
+		t.Errorf("Expected Tidal 0.9 to boot with dirtStream")
+	}
+
+	for _, version := range []string{"1.9.10", ""} {
+		modern := strings.Join(DefaultBootCommands(version), "\n")
+		if strings.Contains(modern, "dirtStream") || !strings.Contains(modern, "startTidal") {
+			t.Errorf("Expected Tidal '%s' to boot with startTidal", version)
+		}
+		if !strings.Contains(modern, "d9 = p 9 . (|< orbit 8)") {
+			t.Errorf("Expected Tidal '%s' to define d9", version)
+		}
+	}
+}
+
+func TestParseTidalVersion(t *testing.T) {
+	version, err := parseTidalVersion("1.9.3\n1.10.1\n1.9.10\n")
+	if err != nil || version != "1.10.1" {
+		t.Errorf("Expected 1.10.1, got %s (%v)", version, err)
+	}
+
+	if _, err := parseTidalVersion(""); err == nil {
+		t.Errorf("Expected an error when Tidal isn't installed")
+	}
+}
+
+func TestGHCPkgCommand(t *testing.T) {
+	tests := map[string]string{
+		"ghci":                "ghc-pkg",
+		"/opt/ghc/bin/ghci":   "/opt/ghc/bin/ghc-pkg",
+		"ghci-9.4.7":          "ghc-pkg-9.4.7",
+		"stack ghci":          "stack exec -- ghc-pkg",
+		"cabal repl":          "cabal exec -- ghc-pkg",
+		"cabal v2-repl":       "cabal exec -- ghc-pkg",
+		"cabal build":         "",
+		"ghci -package-env -": "ghc-pkg",
+	}
+
+	for ghci, expected := range tests {
+		command := strings.Join(ghcPkgCommand(ghci), " ")
+		if expected == "" {
+			if command != "" {
+				t.Errorf("Expected no ghc-pkg for %s, got %s", ghci, command)
+			}
+			continue
+		}
+		if !strings.HasPrefix(command, expected+" field tidal version") {
+			t.Errorf("Expected %s for %s, got %s", expected, ghci, command)
+		}
+	}
+}
+
+func TestResolveBootFile(t *testing.T) {
+	dir := t.TempDir()
+	custom := filepath.Join(dir, "MyBoot.hs")
+	os.WriteFile(custom, []byte("import Sound.Tidal.Context\n"), 0644)
+	if path := ResolveBootFile(custom, "ghci"); path != custom {
+		t.Errorf("Expected %s, got %s", custom, path)
+	}
+	if path := ResolveBootFile(filepath.Join(dir, "missing.hs"), "ghci"); path != "" {
+		t.Errorf("Expected no boot file for a missing one, got %s", path)
+	}
+
+	// Without BootTidal.hs in the working directory, the one Tidal ships
+	dataDir := filepath.Join(dir, "share", "tidal-1.9.10")
+	os.MkdirAll(dataDir, 0755)
+	os.WriteFile(filepath.Join(dataDir, DefaultBootFile), []byte("import Sound.Tidal.Context\n"), 0644)
+	ghcPkg := "#!/bin/sh\necho " + dataDir + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "ghc-pkg"), []byte(ghcPkg), 0755); err != nil {
+		t.Fatalf("Failed to write fake ghc-pkg: %v", err)
+	}
+
+	if path := ResolveBootFile(DefaultBootFile, filepath.Join(dir, "ghci")); path != filepath.Join(dataDir, DefaultBootFile) {
+		t.Errorf("Expected Tidal's own boot file, got %s", path)
+	}
+}
+
+func TestGHCiWatcherExecutePattern(t *testing.T) {
+	watcher := NewGHCiWatcher(map[string]string{"ghci_command": fakeGHCi(t), "boot_commands": "import Sound.Tidal.Context"})
+	booted := make(chan common.LifecycleEvent, 1)
+	watcher.SetLifecycleCallback(func(event common.LifecycleEvent) { booted <- event })
+
+	executions := make(chan common.ExecutionEvent, 10)
+	if err := watcher.Start(func(event common.ExecutionEvent) { executions <- event }); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	defer watcher.Stop()
+	<-booted
+
+	block := "do\n  d1 $ s \"bd*2\"\n  d2 $ s \"hh*4\""
+	if err := watcher.ExecutePattern(block); err != nil {
+		t.Fatalf("Failed to execute: %v", err)
+	}
+	if err := watcher.ExecutePattern("d3 $ broken"); err != nil {
+		t.Fatalf("Failed to execute: %v", err)
+	}
+
+	for _, expected := range []common.ExecutionEvent{
+		{Content: block, Buffer: "d1", Success: true},
+		{Content: "d3 $ broken", Buffer: "d3", ErrorMessage: "<interactive>:1:1: error:\nNot in scope: 'broken'"},
+	} {
+		select {
+		case event := <-executions:
+			if event.Content != expected.Content || event.Buffer != expected.Buffer || event.Success != expected.Success || event.ErrorMessage != expected.ErrorMessage {
+				t.Errorf("Expected %+v, got %+v", expected, event)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected an execution for %q", expected.Content)
+		}
+	}
+
+	select {
+	case event := <-executions:
+		t.Errorf("Expected one execution per pattern, also got %+v", event)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+// bufferHistory is a History over commits held in memory
+type bufferHistory []*storage.Commit
+
+func (h bufferHistory) GetCommitsByBuffer(buffer string) ([]*storage.Commit, error) {
+	var commits []*storage.Commit
+	for _, commit := range h {
+		if commit.Metadata.Buffer == buffer {
+			commits = append(commits, commit)
+		}
+	}
+	return commits, nil
+}
+
+func TestGHCiWatcherActivePatterns(t *testing.T) {
+	start := time.Now()
+	commit := func(minutes int, buffer, content string, success bool) *storage.Commit {
+		return &storage.Commit{
+			Timestamp: start.Add(time.Duration(minutes) * time.Minute),
+			Content:   content,
+			Metadata:  storage.ExecutionMetadata{Buffer: buffer, Success: success},
+		}
+	}
+
+	watcher := NewGHCiWatcher(nil)
+	watcher.SetHistory(bufferHistory{
+		commit(0, "d1", `d1 $ sound "bd"`, true),
+		commit(1, "d2", `d2 $ sound "hh*8"`, true),
+		commit(2, "all", "hush", true),
+		commit(3, "d1", `d1 $ sound "bd*2"`, true),
+		commit(4, "d1", `d1 $ sound "bd*"`, false),
+		commit(5, "d3", `d3 $ sound "arpy"`, true),
+		commit(6, "d3", "d3 $ silence", true),
+		commit(7, "d16", `d16 $ sound "sn"`, true),
+	})
+
+	want := map[string]string{
+		"d1":  `d1 $ sound "bd*2"`,
+		"d16": `d16 $ sound "sn"`,
+	}
+	if got := watcher.GetActivePatterns(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetActivePatterns() = %v, want %v", got, want)
+	}
+}
+
+func TestGHCiWatcherActivePatternsWithoutHistory(t *testing.T) {
+	watcher := NewGHCiWatcher(nil)
+	watcher.createPatternExecutionEvent(`d1 $ sound "bd"`, true, "")
+	watcher.createPatternExecutionEvent(`d2 $ sound "hh"`, true, "")
+	watcher.createPatternExecutionEvent("d2 $ silence", true, "")
+	watcher.createPatternExecutionEvent(`d10 $ sound "cp"`, true, "")
+
+	want := map[string]string{"d1": `d1 $ sound "bd"`, "d10": `d10 $ sound "cp"`}
+	if got := watcher.GetActivePatterns(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetActivePatterns() = %v, want %v", got, want)
+	}
+
+	watcher.createPatternExecutionEvent("hush", true, "")
+	if got := watcher.GetActivePatterns(); len(got) != 0 {
+		t.Errorf("GetActivePatterns() after hush = %v, want none", got)
+	}
+	if !watcher.isPatternEvaluation(`d12 $ sound "bd"`) {
+		t.Error("d12 pattern not recognised as an evaluation")
+	}
+}
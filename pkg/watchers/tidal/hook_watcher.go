@@ -0,0 +1,186 @@
+package tidal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/livecodegit/pkg/watchers/common"
+)
+
+// DefaultHookPort is the UDP port the BootTidal hook reports evaluations to
+const DefaultHookPort = 6061
+
+// HookMessage is the datagram sent by the BootTidal hook for each evaluation
+type HookMessage struct {
+	Buffer       string `json:"buffer"`
+	Content      string `json:"content"`
+	Success      *bool  `json:"success,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+
+	// Set by hooks generated with --author, so performers sharing a
+	// repository are told apart
+	Author      string `json:"author,omitempty"`
+	AuthorEmail string `json:"author_email,omitempty"`
+}
+
+// HookWatcher receives evaluations forwarded from a Tidal session started by
+// the user's editor, via the snippet generated by 'lcg integrate tidal'
+type HookWatcher struct {
+	config   common.WatcherConfig
+	conn     *net.UDPConn
+	running  bool
+	mutex    sync.RWMutex
+	callback func(common.ExecutionEvent)
+
+	port int
+}
+
+// NewHookWatcher creates a new Tidal hook watcher listening on the given port
+func NewHookWatcher(port int) *HookWatcher {
+	return &HookWatcher{
+		config: common.WatcherConfig{
+			Language:    "tidal",
+			Environment: "tidal-hook",
+			Enabled:     true,
+			Options: map[string]string{
+				"hook_port": strconv.Itoa(port),
+			},
+		},
+		port: port,
+	}
+}
+
+// Start begins listening for hook messages
+func (w *HookWatcher) Start(callback func(common.ExecutionEvent)) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.running {
+		return fmt.Errorf("hook watcher is already running")
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("127.0.0.1:%d", w.port))
+	if err != nil {
+		return fmt.Errorf("failed to resolve UDP address: %w", err)
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on UDP port %d: %w", w.port, err)
+	}
+
+	w.conn = conn
+	w.callback = callback
+	w.running = true
+
+	go w.listenForMessages()
+
+	return nil
+}
+
+// Stop stops the hook watcher
+func (w *HookWatcher) Stop() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.running {
+		return nil
+	}
+
+	w.running = false
+
+	if w.conn != nil {
+		return w.conn.Close()
+	}
+
+	return nil
+}
+
+// IsRunning returns true if the watcher is active
+func (w *HookWatcher) IsRunning() bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.running
+}
+
+// GetConfig returns the watcher configuration
+func (w *HookWatcher) GetConfig() common.WatcherConfig {
+	return w.config
+}
+
+// GetLanguage returns "tidal"
+func (w *HookWatcher) GetLanguage() string {
+	return "tidal"
+}
+
+// GetEnvironment returns "tidal-hook"
+func (w *HookWatcher) GetEnvironment() string {
+	return "tidal-hook"
+}
+
+// listenForMessages reads hook datagrams until the watcher stops
+func (w *HookWatcher) listenForMessages() {
+	buffer := make([]byte, 65536)
+
+	for w.IsRunning() {
+		w.conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+		n, err := w.conn.Read(buffer)
+
+		if err != nil {
+			if netError, ok := err.(net.Error); ok && netError.Timeout() {
+				continue // Timeout is expected, continue listening
+			}
+			if w.IsRunning() {
+				fmt.Printf("Error reading hook message: %v\n", err)
+			}
+			continue
+		}
+
+		event, err := ParseHookMessage(buffer[:n])
+		if err != nil {
+			fmt.Printf("Ignoring malformed hook message: %v\n", err)
+			continue
+		}
+
+		if w.callback != nil {
+			w.callback(event)
+		}
+	}
+}
+
+// ParseHookMessage converts a hook datagram into an execution event
+func ParseHookMessage(data []byte) (common.ExecutionEvent, error) {
+	var message HookMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		return common.ExecutionEvent{}, err
+	}
+
+	if message.Buffer == "" {
+		message.Buffer = "unknown"
+	}
+
+	success := true
+	if message.Success != nil {
+		success = *message.Success
+	}
+
+	return common.ExecutionEvent{
+		Timestamp:    time.Now(),
+		Content:      message.Content,
+		Buffer:       message.Buffer,
+		Language:     "tidal",
+		Environment:  "tidal-hook",
+		Success:      success,
+		ErrorMessage: message.ErrorMessage,
+		Author:       message.Author,
+		AuthorEmail:  message.AuthorEmail,
+		ExtraData: map[string]string{
+			"connection":   message.Buffer,
+			"trigger_type": "boot_hook",
+		},
+	}, nil
+}
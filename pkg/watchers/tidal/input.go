@@ -0,0 +1,45 @@
+package tidal
+
+import "strings"
+
+// Input reads the lines an editor sent to GHCi back into evaluations.
+// Editors wrap multi-line blocks in :{ and :}, as GHCi requires, so a block
+// is one evaluation, and so is any other line but GHCi commands.
+type Input struct {
+	inBlock bool
+	block   []string
+}
+
+// Feed reads one line sent to GHCi, returning the evaluation it ends
+func (in *Input) Feed(line string) (string, bool) {
+	line = strings.TrimRight(line, "\r")
+	trimmed := strings.TrimSpace(line)
+
+	if in.inBlock {
+		if trimmed == ":}" {
+			in.inBlock = false
+			return evaluation(strings.Join(in.block, "\n"))
+		}
+		in.block = append(in.block, line)
+		return "", false
+	}
+
+	switch {
+	case trimmed == ":{":
+		in.inBlock = true
+		in.block = nil
+	case trimmed == "" || strings.HasPrefix(trimmed, ":"):
+		// GHCi commands such as :set or :t aren't performances
+	default:
+		return evaluation(line)
+	}
+	return "", false
+}
+
+// evaluation returns content unless it is blank
+func evaluation(content string) (string, bool) {
+	if strings.TrimSpace(content) == "" {
+		return "", false
+	}
+	return content, true
+}
@@ -10,6 +10,13 @@ import (
 type ExecutionEvent = common.ExecutionEvent
 type WatcherConfig = common.WatcherConfig
 type ExecutionWatcher = common.ExecutionWatcher
+type LifecycleEvent = common.LifecycleEvent
+type LifecycleReporter = common.LifecycleReporter
+type Tempo = common.Tempo
+type TempoSource = common.TempoSource
+type SoundSource = common.SoundSource
+type History = common.History
+type HistoryReader = common.HistoryReader
 
 // WatcherManager manages multiple watchers and coordinates their execution
 type WatcherManager struct {
@@ -0,0 +1,60 @@
+package watchers
+
+import (
+	"crypto/sha256"
+	"log"
+)
+
+// lastContents remembers a hash of each buffer's last committed code, for
+// the only_on_change option; it is used with ws.dedup locked
+type lastContents struct {
+	enabled bool
+	hashes  map[string][sha256.Size]byte
+}
+
+// committed remembers the code committed in a buffer
+func (c *lastContents) committed(buffer, content string) {
+	if !c.enabled {
+		return
+	}
+	if c.hashes == nil {
+		c.hashes = make(map[string][sha256.Size]byte)
+	}
+	c.hashes[buffer] = sha256.Sum256([]byte(content))
+}
+
+// unchanged reports whether an execution's code is byte for byte the last
+// code committed in its buffer. A buffer the service hasn't committed yet is
+// compared with its latest commit in the repository.
+func (ws *WatcherService) unchanged(event ExecutionEvent) bool {
+	if !ws.lastContents.enabled {
+		return false
+	}
+
+	last, ok := ws.lastContents.hashes[event.Buffer]
+	if !ok {
+		ws.repoMutex.Lock()
+		head, found := ws.repository.BufferHeads()[event.Buffer]
+		var content string
+		if found {
+			commit, err := ws.repository.GetCommit(head)
+			if err != nil {
+				found = false
+			} else {
+				content = commit.Content
+			}
+		}
+		ws.repoMutex.Unlock()
+		if !found {
+			return false
+		}
+		ws.lastContents.committed(event.Buffer, content)
+		last = ws.lastContents.hashes[event.Buffer]
+	}
+
+	if sha256.Sum256([]byte(event.Content)) != last {
+		return false
+	}
+	log.Printf("Execution unchanged, not committed: %s/%s", event.Language, event.Buffer)
+	return true
+}
@@ -0,0 +1,62 @@
+package watchers
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+)
+
+func TestWatcherServiceOnlyOnChange(t *testing.T) {
+	service, tempDir := createTestWatcherService(t)
+	defer os.RemoveAll(tempDir)
+
+	if err := service.configManager.LoadConfig(); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	config := service.configManager.GetConfig()
+	config.OnlyOnChange = true
+	config.DedupWindow = "0" // re-runs within seconds are the point here
+	service.configManager.UpdateConfig(config)
+	if err := service.configManager.SaveConfig(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if err := service.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize service: %v", err)
+	}
+
+	// Committed before the service started
+	if _, err := service.repository.Commit("play 60", "earlier", core.ExecutionMetadata{Buffer: "workspace_zero", Success: true}); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	for _, execution := range []struct{ buffer, content string }{
+		{"workspace_zero", "play 60"},
+		{"workspace_zero", "play 62"},
+		{"workspace_zero", "play 62"},
+		{"workspace_zero", "play 62\n"},
+		{"workspace_one", "play 62"},
+	} {
+		service.handleExecutionEvent(ExecutionEvent{Timestamp: time.Now(), Content: execution.content,
+			Buffer: execution.buffer, Language: "sonicpi", Success: true})
+	}
+
+	commits, err := service.repository.History()
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	var contents []string
+	for _, commit := range commits {
+		contents = append(contents, commit.Metadata.Buffer+": "+commit.Content)
+	}
+	want := []string{"workspace_zero: play 60", "workspace_zero: play 62", "workspace_zero: play 62\n", "workspace_one: play 62"}
+	if len(contents) != len(want) {
+		t.Fatalf("Expected commits %q, got %q", want, contents)
+	}
+	for i := range want {
+		if contents[i] != want[i] {
+			t.Errorf("Commit %d: expected %q, got %q", i, want[i], contents[i])
+		}
+	}
+}
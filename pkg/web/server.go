@@ -0,0 +1,400 @@
+// Package web serves a repository's history to a browser: a read-only JSON
+// API under /api/ and a small embedded app on top of it that shows the commit
+// timeline, diffs, buffers and performances, e.g. projected during a talk.
+// The repository is read again on every request, so commits recorded by a
+// running watcher show up without restarting the server.
+//
+// The app installs as a progressive web app: a service worker keeps the app
+// itself available offline, and the app keeps the last performance opened
+// as a compact bundle, so a set can be reviewed on a phone right after a
+// gig without the server.
+package web
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/diff"
+	"github.com/livecodegit/pkg/export"
+)
+
+// DefaultLimit is how many commits the timeline lists unless asked for more
+const DefaultLimit = 500
+
+//go:embed static
+var static embed.FS
+
+func init() {
+	// Not among the types Go knows, and browsers want it for the manifest
+	mime.AddExtensionType(".webmanifest", "application/manifest+json")
+}
+
+// Server answers the API and app requests for one repository
+type Server struct {
+	path           string
+	includePrivate bool
+	mux            *http.ServeMux
+}
+
+// CommitSummary is a commit as listed in the timeline
+type CommitSummary struct {
+	Hash      string    `json:"hash"`
+	Parent    string    `json:"parent,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Author    string    `json:"author"`
+	Message   string    `json:"message"`
+	Buffer    string    `json:"buffer"`
+	Language  string    `json:"language"`
+	Success   bool      `json:"success"`
+	Redacted  bool      `json:"redacted,omitempty"`
+	Added     int       `json:"added"`
+	Removed   int       `json:"removed"`
+}
+
+// CommitDetail is a commit with its change to the previous version of its
+// buffer, since livecoders evaluate buffers independently
+type CommitDetail struct {
+	CommitSummary
+	Content      string     `json:"content"`
+	ErrorMessage string     `json:"error_message,omitempty"`
+	BPM          float64    `json:"bpm,omitempty"`
+	Previous     string     `json:"previous,omitempty"`
+	Diff         []DiffLine `json:"diff"`
+}
+
+// DiffLine is one line of a commit's diff; Op is " ", "+" or "-"
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// BufferSummary describes the history of one buffer
+type BufferSummary struct {
+	Name     string    `json:"name"`
+	Language string    `json:"language"`
+	Commits  int       `json:"commits"`
+	Failed   int       `json:"failed"`
+	Head     string    `json:"head"`
+	Updated  time.Time `json:"updated"`
+}
+
+// RepositorySummary describes the repository being served
+type RepositorySummary struct {
+	Name    string `json:"name"`
+	Head    string `json:"head,omitempty"`
+	Commits int    `json:"commits"`
+}
+
+// NewServer serves the repository at path. Unless includePrivate is set,
+// the repository's privacy rules apply as they do to exports: private
+// commits are left out and redacted ones are shown without their code.
+func NewServer(path string, includePrivate bool) *Server {
+	s := &Server{path: path, includePrivate: includePrivate, mux: http.NewServeMux()}
+
+	app, _ := fs.Sub(static, "static")
+	s.mux.Handle("/", http.FileServer(http.FS(app)))
+	s.mux.HandleFunc("/api/repository", s.handleRepository)
+	s.mux.HandleFunc("/api/commits", s.handleCommits)
+	s.mux.HandleFunc("/api/commits/", s.handleCommit)
+	s.mux.HandleFunc("/api/buffers", s.handleBuffers)
+	s.mux.HandleFunc("/api/performances", s.handlePerformances)
+	s.mux.HandleFunc("/api/performances/", s.handleBundle)
+	return s
+}
+
+// ServeHTTP answers read-only requests
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, "the API is read-only")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// history is what the server may show of a repository at one moment
+type history struct {
+	repo      *core.LiveCodeRepository
+	rules     *core.PrivacyRules
+	redaction *export.Redaction
+}
+
+// load reads the repository and applies its privacy rules
+func (s *Server) load() (*history, error) {
+	repo, err := core.LoadRepository(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &core.PrivacyRules{}
+	if !s.includePrivate {
+		if rules, err = repo.Privacy(); err != nil {
+			return nil, err
+		}
+	}
+
+	commits, err := repo.History()
+	if err != nil {
+		return nil, err
+	}
+	return &history{repo: repo, rules: rules, redaction: export.Redact(commits, rules)}, nil
+}
+
+// summaries lists the commits with their diff stats, oldest first
+func (h *history) summaries() []CommitSummary {
+	summaries := make([]CommitSummary, len(h.redaction.Commits))
+	previous := make(map[string][]string) // buffer -> lines of its last commit
+
+	for i, commit := range h.redaction.Commits {
+		lines := diff.Lines(commit.Content)
+		added, removed := diff.Stats(previous[commit.Metadata.Buffer], lines)
+		previous[commit.Metadata.Buffer] = lines
+
+		summaries[i] = CommitSummary{
+			Hash:      commit.Hash,
+			Parent:    commit.Parent,
+			Timestamp: commit.Timestamp,
+			Author:    commit.Author,
+			Message:   commit.Message,
+			Buffer:    commit.Metadata.Buffer,
+			Language:  commit.Metadata.Language,
+			Success:   commit.Metadata.Success,
+			Redacted:  h.rules.Level(commit) == core.PrivacyRedacted,
+			Added:     added,
+			Removed:   removed,
+		}
+	}
+	return summaries
+}
+
+func (s *Server) handleRepository(w http.ResponseWriter, r *http.Request) {
+	h, err := s.load()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	summary := RepositorySummary{Name: filepath.Base(s.path), Commits: len(h.redaction.Commits)}
+	if len(h.redaction.Commits) > 0 {
+		summary.Head = h.redaction.Commits[len(h.redaction.Commits)-1].Hash
+	}
+	writeJSON(w, summary)
+}
+
+// handleCommits lists commits newest first, optionally only those of one
+// buffer (?buffer=) or performance (?performance=, ID or name), up to ?limit=
+func (s *Server) handleCommits(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := DefaultLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit %q", value))
+			return
+		}
+		limit = parsed
+	}
+
+	h, err := s.load()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var performance *core.Performance
+	if ref := query.Get("performance"); ref != "" {
+		if performance, err = h.repo.GetPerformance(ref); err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+	}
+	buffer := query.Get("buffer")
+
+	summaries := h.summaries()
+	commits := make([]CommitSummary, 0, min(limit, len(summaries)))
+	for i := len(summaries) - 1; i >= 0 && len(commits) < limit; i-- {
+		summary := summaries[i]
+		if buffer != "" && summary.Buffer != buffer {
+			continue
+		}
+		if performance != nil && !duringPerformance(summary.Timestamp, performance) {
+			continue
+		}
+		commits = append(commits, summary)
+	}
+	writeJSON(w, commits)
+}
+
+// duringPerformance reports whether a commit made at t belongs to a
+// performance, by the rule the repository uses for its commits
+func duringPerformance(t time.Time, performance *core.Performance) bool {
+	if t.Before(performance.StartTime) {
+		return false
+	}
+	return performance.EndTime.IsZero() || !t.After(performance.EndTime)
+}
+
+// handleCommit shows one commit, by hash or unique hash prefix, with its diff
+func (s *Server) handleCommit(w http.ResponseWriter, r *http.Request) {
+	ref := strings.TrimPrefix(r.URL.Path, "/api/commits/")
+	if ref == "" || strings.Contains(ref, "/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	h, err := s.load()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Private commits were left out, so they can't be looked up either
+	index := -1
+	for i, commit := range h.redaction.Commits {
+		if commit.Hash == ref {
+			index = i
+			break
+		}
+		if strings.HasPrefix(commit.Hash, ref) {
+			if index >= 0 {
+				writeError(w, http.StatusNotFound, fmt.Sprintf("%s matches several commits", ref))
+				return
+			}
+			index = i
+		}
+	}
+	if index < 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no commit matches %s", ref))
+		return
+	}
+
+	commit := h.redaction.Commits[index]
+	detail := CommitDetail{
+		CommitSummary: h.summaries()[index],
+		Content:       commit.Content,
+		ErrorMessage:  commit.Metadata.ErrorMessage,
+		BPM:           commit.Metadata.BPM,
+		Diff:          []DiffLine{},
+	}
+
+	var before []string
+	for i := index - 1; i >= 0; i-- {
+		if previous := h.redaction.Commits[i]; previous.Metadata.Buffer == commit.Metadata.Buffer {
+			detail.Previous = previous.Hash
+			before = diff.Lines(previous.Content)
+			break
+		}
+	}
+	for _, line := range diff.Script(before, diff.Lines(commit.Content)) {
+		detail.Diff = append(detail.Diff, DiffLine{Op: string(line.Op), Text: line.Text})
+	}
+	writeJSON(w, detail)
+}
+
+func (s *Server) handleBuffers(w http.ResponseWriter, r *http.Request) {
+	h, err := s.load()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	byName := make(map[string]*BufferSummary)
+	for _, commit := range h.redaction.Commits {
+		name := commit.Metadata.Buffer
+		buffer, exists := byName[name]
+		if !exists {
+			buffer = &BufferSummary{Name: name}
+			byName[name] = buffer
+		}
+		buffer.Language = commit.Metadata.Language
+		buffer.Commits++
+		if !commit.Metadata.Success {
+			buffer.Failed++
+		}
+		buffer.Head = commit.Hash
+		buffer.Updated = commit.Timestamp
+	}
+
+	buffers := make([]BufferSummary, 0, len(byName))
+	for _, buffer := range byName {
+		buffers = append(buffers, *buffer)
+	}
+	sort.Slice(buffers, func(i, j int) bool { return buffers[i].Name < buffers[j].Name })
+	writeJSON(w, buffers)
+}
+
+// handlePerformances lists performances, newest first
+func (s *Server) handlePerformances(w http.ResponseWriter, r *http.Request) {
+	h, err := s.load()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	performances, err := h.repo.ListPerformances()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sort.Slice(performances, func(i, j int) bool {
+		return performances[i].StartTime.After(performances[j].StartTime)
+	})
+	for i, performance := range performances {
+		performances[i] = h.redaction.Performance(performance)
+	}
+	writeJSON(w, performances)
+}
+
+// handleBundle answers /api/performances/<ID or name>/bundle with the
+// performance packed as an export.Bundle, which the app keeps for offline
+// review
+func (s *Server) handleBundle(w http.ResponseWriter, r *http.Request) {
+	ref, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/performances/"), "/")
+	if ref == "" || rest != "bundle" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	h, err := s.load()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	performance, err := h.repo.GetPerformance(ref)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	commits, err := h.repo.PerformanceCommits(performance)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	redaction := export.Redact(commits, h.rules)
+	writeJSON(w, export.BuildBundle(redaction.Performance(performance), redaction.Commits))
+}
+
+// writeJSON answers with a JSON document
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeError answers with a JSON error and status
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,232 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/livecodegit/pkg/core"
+	"github.com/livecodegit/pkg/export"
+)
+
+func createTestRepository(t *testing.T) (*core.LiveCodeRepository, string) {
+	path := t.TempDir()
+	repo := core.NewRepository(path)
+	if err := repo.Init(path); err != nil {
+		t.Fatalf("Failed to initialize repository: %v", err)
+	}
+	return repo, path
+}
+
+func commit(t *testing.T, repo *core.LiveCodeRepository, buffer, content string) *core.Commit {
+	c, err := repo.Commit(content, "Edit "+buffer, core.ExecutionMetadata{Buffer: buffer, Language: "tidal", Success: true})
+	if err != nil {
+		t.Fatalf("Failed to create commit: %v", err)
+	}
+	return c
+}
+
+// get requests path from the server and decodes the JSON answer into value
+func get(t *testing.T, server http.Handler, path string, value interface{}) int {
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	if value != nil && recorder.Code == http.StatusOK {
+		if err := json.Unmarshal(recorder.Body.Bytes(), value); err != nil {
+			t.Fatalf("Failed to decode %s: %v", path, err)
+		}
+	}
+	return recorder.Code
+}
+
+func TestCommitsAndDiff(t *testing.T) {
+	repo, path := createTestRepository(t)
+	commit(t, repo, "d1", "d1 $ s \"bd\"\n  # gain 1")
+	commit(t, repo, "d2", "d2 $ s \"hh*4\"")
+	second := commit(t, repo, "d1", "d1 $ s \"bd sn\"\n  # gain 1")
+
+	server := NewServer(path, false)
+
+	var commits []CommitSummary
+	if code := get(t, server, "/api/commits", &commits); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if len(commits) != 3 || commits[0].Hash != second.Hash {
+		t.Fatalf("Expected 3 commits newest first, got %+v", commits)
+	}
+	if commits[0].Added != 1 || commits[0].Removed != 1 {
+		t.Errorf("Expected +1 -1 against the previous d1, got +%d -%d", commits[0].Added, commits[0].Removed)
+	}
+
+	get(t, server, "/api/commits?buffer=d2", &commits)
+	if len(commits) != 1 || commits[0].Buffer != "d2" {
+		t.Errorf("Expected only d2, got %+v", commits)
+	}
+	get(t, server, "/api/commits?limit=2", &commits)
+	if len(commits) != 2 {
+		t.Errorf("Expected 2 commits, got %d", len(commits))
+	}
+	if code := get(t, server, "/api/commits?limit=lots", nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid limit, got %d", code)
+	}
+
+	var detail CommitDetail
+	if code := get(t, server, "/api/commits/"+second.Hash[:8], &detail); code != http.StatusOK {
+		t.Fatalf("Expected 200 for a hash prefix, got %d", code)
+	}
+	var rendered []string
+	for _, line := range detail.Diff {
+		rendered = append(rendered, line.Op+line.Text)
+	}
+	expected := []string{"-d1 $ s \"bd\"", "+d1 $ s \"bd sn\"", "   # gain 1"}
+	if strings.Join(rendered, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected diff %q, got %q", expected, rendered)
+	}
+	if detail.Previous == "" || detail.Content != second.Content {
+		t.Errorf("Expected the previous d1 commit and full content, got %+v", detail)
+	}
+
+	if code := get(t, server, "/api/commits/ffffffff", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown commit, got %d", code)
+	}
+}
+
+func TestPrivacy(t *testing.T) {
+	repo, path := createTestRepository(t)
+	commit(t, repo, "d1", "d1 $ s \"bd\"")
+	secret := commit(t, repo, "scratch", "experiment")
+	redacted := commit(t, repo, "d1", "d1 $ s \"bd sn\"")
+
+	if err := repo.SetBufferPrivacy("scratch", core.PrivacyPrivate); err != nil {
+		t.Fatalf("Failed to set privacy: %v", err)
+	}
+	if err := repo.SetCommitPrivacy(redacted.Hash, core.PrivacyRedacted); err != nil {
+		t.Fatalf("Failed to set privacy: %v", err)
+	}
+
+	server := NewServer(path, false)
+
+	var commits []CommitSummary
+	get(t, server, "/api/commits", &commits)
+	if len(commits) != 2 {
+		t.Fatalf("Expected the private commit to be left out, got %d commits", len(commits))
+	}
+	if !commits[0].Redacted {
+		t.Errorf("Expected the newest commit to be marked redacted")
+	}
+
+	if code := get(t, server, "/api/commits/"+secret.Hash, nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a private commit, got %d", code)
+	}
+	var detail CommitDetail
+	get(t, server, "/api/commits/"+redacted.Hash, &detail)
+	if detail.Content != "" {
+		t.Errorf("Expected a redacted commit without code, got '%s'", detail.Content)
+	}
+
+	var buffers []BufferSummary
+	get(t, server, "/api/buffers", &buffers)
+	if len(buffers) != 1 || buffers[0].Name != "d1" || buffers[0].Commits != 2 {
+		t.Errorf("Expected only d1 with 2 commits, got %+v", buffers)
+	}
+
+	// Privacy rules are read on every request, and can be ignored on purpose
+	get(t, NewServer(path, true), "/api/commits", &commits)
+	if len(commits) != 3 {
+		t.Errorf("Expected every commit with --include-private, got %d", len(commits))
+	}
+}
+
+func TestPerformanceBundle(t *testing.T) {
+	repo, path := createTestRepository(t)
+	commit(t, repo, "d1", "before the set")
+	if _, err := repo.StartPerformance("Algorave"); err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+	commit(t, repo, "d1", "d1 $ s \"bd\"")
+	commit(t, repo, "scratch", "experiment")
+	redacted := commit(t, repo, "d1", "d1 $ s \"bd sn\"")
+	commit(t, repo, "d1", "d1 $ s \"bd\"")
+
+	if err := repo.SetBufferPrivacy("scratch", core.PrivacyPrivate); err != nil {
+		t.Fatalf("Failed to set privacy: %v", err)
+	}
+	if err := repo.SetCommitPrivacy(redacted.Hash, core.PrivacyRedacted); err != nil {
+		t.Fatalf("Failed to set privacy: %v", err)
+	}
+
+	server := NewServer(path, false)
+
+	var bundle export.Bundle
+	if code := get(t, server, "/api/performances/Algorave/bundle", &bundle); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if bundle.Performance == nil || bundle.Performance.Name != "Algorave" || len(bundle.Commits) != 3 {
+		t.Fatalf("Expected the 3 public commits of the performance, got %+v", bundle)
+	}
+	if len(bundle.Buffers) != 1 || len(bundle.Code) != 2 {
+		t.Errorf("Expected one buffer and the repeated code stored once, got %+v and %q", bundle.Buffers, bundle.Code)
+	}
+	if expanded, err := bundle.Commit(1); err != nil || expanded.Hash != redacted.Hash || expanded.Content != "" {
+		t.Errorf("Expected the redacted commit without code, got %+v (%v)", expanded, err)
+	}
+
+	for _, path := range []string{"/api/performances/Nope/bundle", "/api/performances/Algorave", "/api/performances/Algorave/other"} {
+		if code := get(t, server, path, nil); code != http.StatusNotFound {
+			t.Errorf("Expected 404 for %s, got %d", path, code)
+		}
+	}
+}
+
+func TestPerformancesAndApp(t *testing.T) {
+	repo, path := createTestRepository(t)
+	commit(t, repo, "d1", "before the set")
+	if _, err := repo.StartPerformance("Algorave"); err != nil {
+		t.Fatalf("Failed to start performance: %v", err)
+	}
+	commit(t, repo, "d1", "during the set")
+
+	server := NewServer(path, false)
+
+	var performances []core.Performance
+	get(t, server, "/api/performances", &performances)
+	if len(performances) != 1 || performances[0].Name != "Algorave" {
+		t.Fatalf("Expected the Algorave performance, got %+v", performances)
+	}
+
+	var commits []CommitSummary
+	get(t, server, "/api/commits?performance=Algorave", &commits)
+	if len(commits) != 1 {
+		t.Errorf("Expected the one commit of the performance, got %d", len(commits))
+	}
+	if code := get(t, server, "/api/commits?performance=Nope", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown performance, got %d", code)
+	}
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "app.js") ||
+		!strings.Contains(recorder.Body.String(), "manifest.webmanifest") {
+		t.Errorf("Expected the embedded app with its manifest, got %d", recorder.Code)
+	}
+
+	// Installable, with a service worker at the root of the app's scope
+	for path, contentType := range map[string]string{
+		"/manifest.webmanifest": "application/manifest+json",
+		"/sw.js":                "javascript",
+		"/icon.svg":             "image/svg+xml",
+	} {
+		recorder = httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		if recorder.Code != http.StatusOK || !strings.Contains(recorder.Header().Get("Content-Type"), contentType) {
+			t.Errorf("Expected %s as %s, got %d %s", path, contentType, recorder.Code, recorder.Header().Get("Content-Type"))
+		}
+	}
+
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/commits", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected the API to be read-only, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,329 @@
+// The history browser served by 'lcg serve'. It only reads the JSON API
+// next to it, so it needs no build step.
+'use strict';
+
+// The last performance opened is kept in Cache Storage as a bundle, and
+// shown from there when the server can't be reached, e.g. on a phone after
+// the gig. Browsers only allow this over HTTPS or on localhost.
+const OFFLINE_CACHE = 'lcg-offline';
+const OFFLINE_BUNDLE = '/offline/bundle.json';
+
+const state = {
+  view: 'timeline',
+  buffer: '',        // timeline filter
+  performance: null, // timeline filter, {id, name}
+  selected: '',      // hash of the commit shown in the detail pane
+  size: 16,
+};
+
+async function api(path) {
+  const response = await fetch(path, { cache: 'no-store' });
+  const body = await response.json();
+  if (!response.ok) {
+    throw new Error(body.error || response.statusText);
+  }
+  return body;
+}
+
+// el builds an element with attributes and children
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [key, value] of Object.entries(attrs || {})) {
+    if (key === 'class') {
+      node.className = value;
+    } else if (key.startsWith('on')) {
+      node.addEventListener(key.slice(2), value);
+    } else {
+      node.setAttribute(key, value);
+    }
+  }
+  for (const child of children) {
+    if (child !== null && child !== undefined && child !== false) {
+      node.append(child);
+    }
+  }
+  return node;
+}
+
+function formatTime(value) {
+  return new Date(value).toLocaleString();
+}
+
+function showError(container, error) {
+  container.replaceChildren(el('p', { class: 'empty' }, 'Error: ' + error.message));
+}
+
+async function renderTimeline() {
+  const list = document.getElementById('list');
+  const params = new URLSearchParams();
+  if (state.buffer) params.set('buffer', state.buffer);
+  if (state.performance) params.set('performance', state.performance.id);
+
+  let commits;
+  try {
+    commits = await api('/api/commits?' + params);
+  } catch (error) {
+    showError(list, error);
+    return;
+  }
+
+  if (commits.length === 0) {
+    list.replaceChildren(el('p', { class: 'empty' }, 'No commits yet.'));
+    return;
+  }
+
+  list.replaceChildren(...commits.map((commit) => el('div', {
+    class: 'item' + (commit.hash === state.selected ? ' selected' : ''),
+    'data-hash': commit.hash,
+    onclick: () => selectCommit(commit.hash),
+  },
+  el('div', {},
+    el('span', { class: 'hash' }, commit.hash.slice(0, 8)), ' ',
+    el('span', { class: commit.success ? '' : 'failed' }, commit.message)),
+  el('div', { class: 'meta' },
+    el('span', { class: 'buffer' }, commit.buffer), ' · ', commit.language, ' · ',
+    formatTime(commit.timestamp), ' · ',
+    el('span', { class: 'stats' },
+      el('span', { class: 'plus' }, '+' + commit.added), ' ',
+      el('span', { class: 'minus' }, '-' + commit.removed)),
+    commit.redacted ? ' · redacted' : null))));
+}
+
+async function selectCommit(hash) {
+  state.selected = hash;
+  for (const item of document.querySelectorAll('#list .item')) {
+    item.classList.toggle('selected', item.dataset.hash === hash);
+  }
+
+  const detail = document.getElementById('detail');
+  let commit;
+  try {
+    commit = await api('/api/commits/' + encodeURIComponent(hash));
+  } catch (error) {
+    showError(detail, error);
+    return;
+  }
+
+  const lines = commit.diff.map((line) => el('div', {
+    class: line.op === '+' ? 'add' : line.op === '-' ? 'del' : '',
+  }, el('span', { class: 'op' }, line.op + ' '), line.text));
+
+  detail.replaceChildren(
+    el('h2', {}, commit.message),
+    el('p', { class: 'meta' },
+      el('span', { class: 'hash' }, commit.hash.slice(0, 12)), ' by ', commit.author || 'unknown', ' at ',
+      formatTime(commit.timestamp), ' in ', el('span', { class: 'buffer' }, commit.buffer),
+      ' (', commit.language, commit.bpm ? ', ' + commit.bpm + ' BPM' : '', ')'),
+    commit.previous ? el('p', { class: 'meta' }, 'Changes since ', el('span', { class: 'hash' }, commit.previous.slice(0, 8))) : null,
+    commit.error_message ? el('p', { class: 'error-message' }, commit.error_message) : null,
+    commit.redacted
+      ? el('p', { class: 'redacted' }, 'The code of this commit is private.')
+      : el('pre', { class: 'diff' }, ...lines));
+}
+
+async function renderBuffers() {
+  const list = document.getElementById('list');
+  let buffers;
+  try {
+    buffers = await api('/api/buffers');
+  } catch (error) {
+    showError(list, error);
+    return;
+  }
+
+  if (buffers.length === 0) {
+    list.replaceChildren(el('p', { class: 'empty' }, 'No buffers yet.'));
+    return;
+  }
+
+  list.replaceChildren(...buffers.map((buffer) => el('div', {
+    class: 'item',
+    onclick: () => {
+      state.buffer = buffer.name;
+      state.performance = null;
+      switchView('timeline');
+      selectCommit(buffer.head);
+    },
+  },
+  el('div', {}, el('span', { class: 'buffer' }, buffer.name), ' ', buffer.language),
+  el('div', { class: 'meta' },
+    buffer.commits + ' commits', buffer.failed ? ', ' + buffer.failed + ' failed' : '',
+    ' · last at ', formatTime(buffer.updated)))));
+}
+
+async function renderPerformances() {
+  const list = document.getElementById('list');
+  let performances;
+  try {
+    performances = await api('/api/performances');
+  } catch (error) {
+    showError(list, error);
+    return;
+  }
+
+  if (performances.length === 0) {
+    list.replaceChildren(el('p', { class: 'empty' }, 'No performances yet.'));
+    return;
+  }
+
+  list.replaceChildren(...performances.map((performance) => {
+    const ended = performance.end_time && !performance.end_time.startsWith('0001-');
+    const markers = performance.markers || [];
+    return el('div', {
+      class: 'item',
+      onclick: () => {
+        state.performance = { id: performance.id, name: performance.name };
+        state.buffer = '';
+        switchView('timeline');
+        keepOffline(performance);
+      },
+    },
+    el('div', {}, performance.name),
+    el('div', { class: 'meta' },
+      formatTime(performance.start_time), ended ? ' – ' + formatTime(performance.end_time) : ' (in progress)',
+      ' · ', performance.commit_count + ' commits'),
+    markers.length > 0 ? el('ul', { class: 'markers' },
+      ...markers.map((marker) => el('li', {}, formatTime(marker.time), ' ', marker.label))) : null);
+  }));
+}
+
+// keepOffline replaces the performance kept for offline review
+async function keepOffline(performance) {
+  if (!('caches' in window)) {
+    return;
+  }
+  try {
+    const response = await fetch('/api/performances/' + encodeURIComponent(performance.id) + '/bundle', { cache: 'no-store' });
+    if (response.ok) {
+      const cache = await caches.open(OFFLINE_CACHE);
+      await cache.put(OFFLINE_BUNDLE, response);
+    }
+  } catch (error) {
+    // Offline already: the performance kept before stays
+  }
+}
+
+// offlineBundle returns the performance kept for offline review, or null
+async function offlineBundle() {
+  if (!('caches' in window)) {
+    return null;
+  }
+  const response = await (await caches.open(OFFLINE_CACHE)).match(OFFLINE_BUNDLE);
+  return response ? response.json() : null;
+}
+
+// renderOffline shows the commits of a kept performance, newest first, with
+// the code of the one selected; bundles carry no diffs
+function renderOffline(bundle) {
+  const start = Date.parse(bundle.performance.start_time);
+  document.getElementById('repository').textContent = bundle.performance.name + ' (offline)';
+  document.querySelector('nav').hidden = true;
+  document.getElementById('live').parentElement.hidden = true;
+
+  const list = document.getElementById('list');
+  const detail = document.getElementById('detail');
+  if (bundle.commits.length === 0) {
+    list.replaceChildren(el('p', { class: 'empty' }, 'No commits in this performance.'));
+    return;
+  }
+
+  const show = (commit, item) => {
+    for (const other of list.querySelectorAll('.item')) {
+      other.classList.toggle('selected', other === item);
+    }
+    const buffer = bundle.buffers[commit.b];
+    detail.replaceChildren(
+      el('h2', {}, commit.m || '(no message)'),
+      el('p', { class: 'meta' },
+        el('span', { class: 'hash' }, commit.h.slice(0, 12)), ' by ', commit.a || bundle.performance.author || 'unknown',
+        ' at ', formatTime(start + commit.t), ' in ', el('span', { class: 'buffer' }, buffer.name),
+        ' (', buffer.lang || 'unknown', commit.p ? ', ' + commit.p + ' BPM' : '', ')'),
+      commit.e ? el('p', { class: 'error-message' }, commit.e) : null,
+      el('pre', { class: 'diff' }, bundle.code[commit.c]));
+  };
+
+  list.replaceChildren(...bundle.commits.slice().reverse().map((commit) => {
+    const item = el('div', { class: 'item' },
+      el('div', {},
+        el('span', { class: 'hash' }, commit.h.slice(0, 8)), ' ',
+        el('span', { class: commit.f ? 'failed' : '' }, commit.m || '(no message)')),
+      el('div', { class: 'meta' },
+        el('span', { class: 'buffer' }, bundle.buffers[commit.b].name), ' · ', formatTime(start + commit.t)));
+    item.addEventListener('click', () => show(commit, item));
+    return item;
+  }));
+}
+
+function renderFilter() {
+  const filter = document.getElementById('filter');
+  const label = state.buffer ? 'buffer ' + state.buffer
+    : state.performance ? 'performance ' + state.performance.name : '';
+  filter.hidden = state.view !== 'timeline' || label === '';
+  document.getElementById('filter-label').textContent = label;
+}
+
+function render() {
+  renderFilter();
+  switch (state.view) {
+    case 'buffers': return renderBuffers();
+    case 'performances': return renderPerformances();
+    default: return renderTimeline();
+  }
+}
+
+function switchView(view) {
+  state.view = view;
+  for (const button of document.querySelectorAll('nav button')) {
+    button.classList.toggle('active', button.dataset.view === view);
+  }
+  render();
+}
+
+function setSize(size) {
+  state.size = Math.min(40, Math.max(10, size));
+  document.documentElement.style.setProperty('--size', state.size + 'px');
+}
+
+async function init() {
+  if ('serviceWorker' in navigator) {
+    navigator.serviceWorker.register('/sw.js').catch(() => {});
+  }
+
+  try {
+    const repository = await api('/api/repository');
+    document.getElementById('repository').textContent = repository.name;
+    document.title = repository.name + ' · LiveCodeGit';
+  } catch (error) {
+    // The server can't be reached: review the performance kept, if any
+    const bundle = error instanceof TypeError ? await offlineBundle().catch(() => null) : null;
+    if (bundle) {
+      renderOffline(bundle);
+      return;
+    }
+    document.getElementById('repository').textContent = error.message;
+  }
+
+  for (const button of document.querySelectorAll('nav button')) {
+    button.addEventListener('click', () => switchView(button.dataset.view));
+  }
+  document.getElementById('clear-filter').addEventListener('click', () => {
+    state.buffer = '';
+    state.performance = null;
+    render();
+  });
+  document.getElementById('smaller').addEventListener('click', () => setSize(state.size - 2));
+  document.getElementById('larger').addEventListener('click', () => setSize(state.size + 2));
+
+  // Live mode follows a performance as it happens
+  let timer = null;
+  document.getElementById('live').addEventListener('change', (event) => {
+    clearInterval(timer);
+    if (event.target.checked) {
+      timer = setInterval(render, 3000);
+    }
+  });
+
+  render();
+}
+
+init();
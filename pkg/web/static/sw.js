@@ -0,0 +1,42 @@
+// Keeps the app itself available offline, so the performance it kept can be
+// reviewed without the server. The API is never cached here: the app keeps
+// its bundle itself, see keepOffline in app.js.
+'use strict';
+
+const SHELL_CACHE = 'lcg-shell-v1';
+const SHELL = ['/', '/index.html', '/app.js', '/style.css', '/manifest.webmanifest', '/icon.svg'];
+
+self.addEventListener('install', (event) => {
+  event.waitUntil(caches.open(SHELL_CACHE)
+    .then((cache) => cache.addAll(SHELL))
+    .then(() => self.skipWaiting()));
+});
+
+self.addEventListener('activate', (event) => {
+  event.waitUntil(caches.keys()
+    .then((names) => Promise.all(names
+      .filter((name) => name.startsWith('lcg-shell-') && name !== SHELL_CACHE)
+      .map((name) => caches.delete(name))))
+    .then(() => self.clients.claim()));
+});
+
+// The app comes from the server while it can be reached, so a newer lcg is
+// picked up, and from the cache when it can't
+self.addEventListener('fetch', (event) => {
+  const url = new URL(event.request.url);
+  if (event.request.method !== 'GET' || url.origin !== self.location.origin ||
+      url.pathname.startsWith('/api/') || url.pathname.startsWith('/sync/')) {
+    return;
+  }
+
+  event.respondWith(fetch(event.request)
+    .then((response) => {
+      if (response.ok) {
+        const copy = response.clone();
+        caches.open(SHELL_CACHE).then((cache) => cache.put(event.request, copy));
+      }
+      return response;
+    })
+    .catch(() => caches.match(event.request, { ignoreSearch: true })
+      .then((cached) => cached || Response.error())));
+});